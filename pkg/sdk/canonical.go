@@ -0,0 +1,199 @@
+package facto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Canonical form versions, carried as proof.canonical_version. V1 (or no
+// version) is the original form in encoding/json's output, V2 the same fields
+// in RFC 8785 JCS, and V3 JCS over every execution_meta field plus the version
+// itself. The SDK signs with CanonicalVersion.
+const (
+	CanonicalV1      = 1
+	CanonicalV2      = 2
+	CanonicalV3      = 3
+	CanonicalVersion = CanonicalV3
+)
+
+// ErrUnknownCanonicalVersion is returned for a canonical_version this SDK
+// doesn't know. Such an event can't be verified.
+var ErrUnknownCanonicalVersion = errors.New("facto: unknown canonical_version")
+
+// marshalJCS returns the RFC 8785 encoding of v, as the Facto services write
+// it: like the spec, except integer literals are written exactly rather than
+// as the nearest double, so nanosecond timestamps survive.
+func marshalJCS(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJCS(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeJCS(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if value {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case json.Number:
+		if isIntegerLiteral(string(value)) {
+			// Exact; big.Int only normalizes -0 to 0
+			n, _ := new(big.Int).SetString(string(value), 10)
+			buf.WriteString(n.String())
+			return nil
+		}
+		f, err := strconv.ParseFloat(value.String(), 64)
+		if err != nil {
+			return fmt.Errorf("facto: invalid number %q: %w", value, err)
+		}
+		formatted, err := formatNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(formatted)
+	case string:
+		encodeString(buf, value)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJCS(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeString(buf, k)
+			buf.WriteByte(':')
+			if err := encodeJCS(buf, value[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("facto: unsupported type %T", v)
+	}
+	return nil
+}
+
+func isIntegerLiteral(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatNumber writes a double as ECMAScript's Number.prototype.toString does
+func formatNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("facto: %v is not representable in JSON", f)
+	}
+	if f == 0 {
+		return "0", nil // also covers -0
+	}
+
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	// Go writes e-07 / e+21, ECMAScript writes e-7 / e+21
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	sign := exponent[:1]
+	digits := strings.TrimLeft(exponent[1:], "0")
+	return mantissa + "e" + sign + digits, nil
+}
+
+// encodeString writes s as a JSON string, escaping only what RFC 8785 requires
+func encodeString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 orders object keys by their UTF-16 code units
+func lessUTF16(a, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
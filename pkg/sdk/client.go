@@ -0,0 +1,240 @@
+// Package facto is the Go SDK for Facto. It signs agent actions with Ed25519,
+// links them into per-session hash chains and publishes them to NATS.
+package facto
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Config holds the client configuration
+type Config struct {
+	AgentID    string
+	PrivateKey ed25519.PrivateKey // Generated when nil
+	Tags       map[string]string  // Added to every event's execution_meta.tags
+}
+
+// ErrUnconfirmed is returned, wrapped, with the event when NATS accepted an
+// event but the flush confirming delivery failed. The session's chain has
+// already advanced past the event, since it may still have been delivered.
+// Retry by re-publishing the returned event (Session.Republish); recording it
+// again as a new event would fork the chain. Events are published with their
+// facto_id as Nats-Msg-Id, so the stream drops a republish within its
+// duplicate window (two minutes by default). A later republish of an event
+// that was delivered is stored twice unless the processor runs with
+// DEDUP_MODE set.
+var ErrUnconfirmed = errors.New("facto: event published but delivery not confirmed")
+
+// Client records signed events for a single agent
+type Client struct {
+	nc         *nats.Conn
+	agentID    string
+	privateKey ed25519.PrivateKey
+	tags       map[string]string
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewClient creates a new client publishing over an existing NATS connection
+func NewClient(nc *nats.Conn, config Config) (*Client, error) {
+	if nc == nil {
+		return nil, errors.New("facto: nil NATS connection")
+	}
+	if config.AgentID == "" {
+		return nil, errors.New("facto: agent ID is required")
+	}
+
+	privateKey := config.PrivateKey
+	if privateKey == nil {
+		var err error
+		if _, privateKey, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Client{
+		nc:         nc,
+		agentID:    config.AgentID,
+		privateKey: privateKey,
+		tags:       config.Tags,
+		sessions:   make(map[string]*Session),
+	}, nil
+}
+
+// PublicKey returns the client's Ed25519 public key
+func (c *Client) PublicKey() ed25519.PublicKey {
+	return c.privateKey.Public().(ed25519.PublicKey)
+}
+
+// Session returns the chain state for a session, creating it on first use
+func (c *Client) Session(sessionID string) *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session, ok := c.sessions[sessionID]
+	if !ok {
+		session = &Session{client: c, id: sessionID, prevHash: GenesisHash}
+		c.sessions[sessionID] = session
+	}
+	return session
+}
+
+// RecordEvent signs an event in the session named by opts.SessionID and
+// publishes it to facto.events.<agent_id>
+func (c *Client) RecordEvent(ctx context.Context, opts EventOptions, options ...EventOption) (*FactoEvent, error) {
+	if opts.SessionID == "" {
+		return nil, errors.New("facto: session ID is required")
+	}
+	return c.Session(opts.SessionID).RecordEvent(ctx, opts, options...)
+}
+
+// Session tracks the prev_hash chain of one session. Events recorded through
+// a Session are signed and published one at a time so the chain stays ordered.
+type Session struct {
+	client *Client
+	id     string
+
+	mu       sync.Mutex
+	prevHash string
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// PrevHash returns the hash the next event will link to
+func (s *Session) PrevHash() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prevHash
+}
+
+// RecordEvent signs and publishes an event in this session. opts.SessionID is
+// ignored. If the publish fails the chain doesn't advance and the call can be
+// retried. Once NATS has accepted the event the chain advances, and a failed
+// flush returns the event with ErrUnconfirmed.
+func (s *Session) RecordEvent(ctx context.Context, opts EventOptions, options ...EventOption) (*FactoEvent, error) {
+	if opts.ActionType == "" {
+		return nil, errors.New("facto: action type is required")
+	}
+
+	factoID, err := GenerateFactoID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	startedAt, completedAt := opts.StartedAt, opts.CompletedAt
+	if startedAt.IsZero() {
+		startedAt = now
+	}
+	if completedAt.IsZero() {
+		completedAt = now
+	}
+
+	status := opts.Status
+	if status == "" {
+		status = "success"
+	}
+
+	input, output := opts.InputData, opts.OutputData
+	if input == nil {
+		input = make(map[string]interface{})
+	}
+	if output == nil {
+		output = make(map[string]interface{})
+	}
+
+	c := s.client
+	event := &FactoEvent{
+		FactoID:    factoID,
+		AgentID:    c.agentID,
+		SessionID:  s.id,
+		ActionType: opts.ActionType,
+		Status:     status,
+		InputData:  input,
+		OutputData: output,
+		ExecutionMeta: ExecutionMeta{
+			ToolCalls:   []interface{}{},
+			SDKVersion:  SDKVersion,
+			SDKLanguage: SDKLanguage,
+			Tags:        make(map[string]string, len(c.tags)),
+		},
+		StartedAt:   timestampNs(startedAt),
+		CompletedAt: timestampNs(completedAt),
+	}
+	for k, v := range c.tags {
+		event.ExecutionMeta.Tags[k] = v
+	}
+	for _, option := range options {
+		option(event)
+	}
+	if event.ExecutionMeta.ToolCalls == nil {
+		event.ExecutionMeta.ToolCalls = []interface{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.Proof.PrevHash = s.prevHash
+	event.Proof.CanonicalVersion = CanonicalVersion
+	if err := signEvent(event, c.privateKey); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.publish(event.FactoID, payload); err != nil {
+		return nil, err
+	}
+
+	// The event is buffered for delivery now, so a later event must link to
+	// it even if the flush fails: anything else forks the chain
+	s.prevHash = event.Proof.EventHash
+	if err := c.nc.FlushWithContext(ctx); err != nil {
+		return event, fmt.Errorf("%w: %v", ErrUnconfirmed, err)
+	}
+	return event, nil
+}
+
+// Republish publishes an already signed event again, for retrying one that
+// RecordEvent returned with ErrUnconfirmed. The chain is left as it is.
+func (s *Session) Republish(ctx context.Context, event *FactoEvent) error {
+	c := s.client
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := c.publish(event.FactoID, payload); err != nil {
+		return err
+	}
+	if err := c.nc.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnconfirmed, err)
+	}
+	return nil
+}
+
+// publish sends an event's payload with its facto_id as the JetStream message
+// ID, so the stream deduplicates a republish
+func (c *Client) publish(factoID string, payload []byte) error {
+	msg := nats.NewMsg(c.subject())
+	msg.Header.Set(nats.MsgIdHdr, factoID)
+	msg.Data = payload
+	return c.nc.PublishMsg(msg)
+}
+
+func (c *Client) subject() string {
+	return "facto.events." + c.agentID
+}
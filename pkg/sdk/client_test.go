@@ -0,0 +1,194 @@
+package facto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+// newTestClient starts an in-process NATS server and returns a client on it
+// and a subscription to the agent's subject
+func newTestClient(t *testing.T) (*Client, *nats.Subscription) {
+	t.Helper()
+	opts := natsserver.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	srv := natsserver.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+
+	sub, err := nc.SubscribeSync("facto.events.agent-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(nc, Config{AgentID: "agent-test", Tags: map[string]string{"env": "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, sub
+}
+
+func nextEvent(t *testing.T, sub *nats.Subscription) *FactoEvent {
+	t.Helper()
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event FactoEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		t.Fatal(err)
+	}
+	return &event
+}
+
+func TestRecordEventPublishesSignedChain(t *testing.T) {
+	client, sub := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session := client.Session("session-test")
+	prevHash := GenesisHash
+	for i := 0; i < 3; i++ {
+		recorded, err := session.RecordEvent(ctx, EventOptions{
+			ActionType: "tool_call",
+			InputData:  map[string]interface{}{"step": i},
+		}, WithModelID("gpt-4"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event := nextEvent(t, sub)
+		if event.FactoID != recorded.FactoID {
+			t.Fatalf("published %s, recorded %s", event.FactoID, recorded.FactoID)
+		}
+		if event.Proof.PrevHash != prevHash {
+			t.Errorf("event %d links to %s, want %s", i, event.Proof.PrevHash, prevHash)
+		}
+		if event.Proof.CanonicalVersion != CanonicalVersion || event.ExecutionMeta.Tags["env"] != "test" {
+			t.Errorf("event %d: version %d, tags %v", i, event.Proof.CanonicalVersion, event.ExecutionMeta.Tags)
+		}
+		if hashValid, signatureValid := VerifyEvent(event); !hashValid || !signatureValid {
+			t.Errorf("event %d doesn't verify after the round trip: hash %v, signature %v", i, hashValid, signatureValid)
+		}
+		prevHash = event.Proof.EventHash
+	}
+	if session.PrevHash() != prevHash {
+		t.Errorf("session prev_hash %s, want %s", session.PrevHash(), prevHash)
+	}
+}
+
+// Once NATS has accepted an event it may be delivered even if the flush
+// fails, so the chain must advance past it or the next event forks
+func TestRecordEventAdvancesChainWhenFlushFails(t *testing.T) {
+	client, sub := newTestClient(t)
+	session := client.Session("session-test")
+
+	// FlushWithContext requires a deadline, so this publish succeeds and the
+	// flush fails
+	unconfirmed, err := session.RecordEvent(context.Background(), EventOptions{ActionType: "llm_call"})
+	if !errors.Is(err, ErrUnconfirmed) || unconfirmed == nil {
+		t.Fatalf("expected the event with ErrUnconfirmed, got %v, %v", unconfirmed, err)
+	}
+	if session.PrevHash() != unconfirmed.Proof.EventHash {
+		t.Fatal("the chain didn't advance past the published event")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := session.Republish(ctx, unconfirmed); err != nil {
+		t.Fatal(err)
+	}
+	next, err := session.RecordEvent(ctx, EventOptions{ActionType: "tool_call"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The unconfirmed event was delivered, then its retry, then the next
+	// event linking to it
+	for _, want := range []*FactoEvent{unconfirmed, unconfirmed, next} {
+		if got := nextEvent(t, sub); got.FactoID != want.FactoID {
+			t.Errorf("received %s, want %s", got.FactoID, want.FactoID)
+		}
+	}
+	if next.Proof.PrevHash != unconfirmed.Proof.EventHash {
+		t.Error("the next event forked the chain")
+	}
+}
+
+func TestRecordEventKeepsChainWhenPublishFails(t *testing.T) {
+	client, _ := newTestClient(t)
+	session := client.Session("session-test")
+	client.nc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := session.RecordEvent(ctx, EventOptions{ActionType: "llm_call"}); err == nil || errors.Is(err, ErrUnconfirmed) {
+		t.Fatalf("expected a publish error, got %v", err)
+	}
+	if session.PrevHash() != GenesisHash {
+		t.Error("a rejected publish advanced the chain")
+	}
+}
+
+// The stream drops a republish of a delivered event by its Nats-Msg-Id
+func TestRepublishDeduplicatedByStream(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natsserver.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "FACTO_EVENTS", Subjects: []string{"facto.events.>"}}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(nc, Config{AgentID: "agent-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	session := client.Session("session-test")
+	event, err := session.RecordEvent(ctx, EventOptions{ActionType: "llm_call"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Republish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := js.GetLastMsg("FACTO_EVENTS", "facto.events.agent-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get(nats.MsgIdHdr); got != event.FactoID {
+		t.Errorf("Nats-Msg-Id %q, want %s", got, event.FactoID)
+	}
+	info, err := js.StreamInfo("FACTO_EVENTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("stream holds %d messages, want 1", info.State.Msgs)
+	}
+}
@@ -0,0 +1,141 @@
+package facto
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// BuildCanonicalForm returns the canonical JSON form that is hashed and
+// signed, for the version in event.Proof.CanonicalVersion (0 means V1). It
+// must stay byte-identical to the services' canonical forms; the tests check
+// it against the vectors in tests/golden. An unknown version returns
+// ErrUnknownCanonicalVersion.
+func BuildCanonicalForm(event *FactoEvent) (string, error) {
+	version := event.Proof.CanonicalVersion
+	if version == 0 {
+		version = CanonicalV1
+	}
+	if version != CanonicalV1 && version != CanonicalV2 && version != CanonicalV3 {
+		return "", fmt.Errorf("%w: %d", ErrUnknownCanonicalVersion, version)
+	}
+
+	canonical := make(map[string]interface{})
+
+	canonical["action_type"] = event.ActionType
+	canonical["agent_id"] = event.AgentID
+	canonical["completed_at"] = event.CompletedAt
+
+	execMeta := make(map[string]interface{})
+	if event.ExecutionMeta.ModelID != nil {
+		execMeta["model_id"] = *event.ExecutionMeta.ModelID
+	}
+	execMeta["seed"] = event.ExecutionMeta.Seed
+	execMeta["sdk_version"] = event.ExecutionMeta.SDKVersion
+	if event.ExecutionMeta.Temperature != nil {
+		execMeta["temperature"] = *event.ExecutionMeta.Temperature
+	}
+	execMeta["tool_calls"] = orEmptySlice(event.ExecutionMeta.ToolCalls)
+	if version == CanonicalV3 {
+		// V3 also signs the fields earlier versions left mutable
+		if event.ExecutionMeta.ModelHash != nil {
+			execMeta["model_hash"] = *event.ExecutionMeta.ModelHash
+		}
+		if event.ExecutionMeta.MaxTokens != nil {
+			execMeta["max_tokens"] = *event.ExecutionMeta.MaxTokens
+		}
+		execMeta["sdk_language"] = event.ExecutionMeta.SDKLanguage
+		execMeta["tags"] = orEmptyTags(event.ExecutionMeta.Tags)
+		canonical["canonical_version"] = version
+	}
+	canonical["execution_meta"] = execMeta
+
+	// Absent input/output data is canonicalized as {} rather than null
+	canonical["input_data"] = orEmptyMap(event.InputData)
+	canonical["output_data"] = orEmptyMap(event.OutputData)
+	canonical["parent_facto_id"] = event.ParentFactoID
+	canonical["prev_hash"] = event.Proof.PrevHash
+	canonical["session_id"] = event.SessionID
+	canonical["started_at"] = event.StartedAt
+	canonical["status"] = event.Status
+	canonical["facto_id"] = event.FactoID
+
+	var form []byte
+	var err error
+	if version == CanonicalV1 {
+		// encoding/json sorts map keys, which gives the canonical key order
+		form, err = json.Marshal(canonical)
+	} else {
+		form, err = marshalJCS(canonical)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(form), nil
+}
+
+func orEmptyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
+
+// orEmptySlice canonicalizes absent tool_calls as [], so events without any
+// model metadata (tool calls, HTTP requests) verify the same in every SDK
+func orEmptySlice(s []interface{}) []interface{} {
+	if s == nil {
+		return []interface{}{}
+	}
+	return s
+}
+
+func orEmptyTags(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+// ComputeHash returns the hex-encoded SHA3-256 of a canonical form
+func ComputeHash(canonical string) string {
+	hash := sha3.Sum256([]byte(canonical))
+	return hex.EncodeToString(hash[:])
+}
+
+// signEvent fills in the event hash, signature and public key of an event
+// whose prev_hash and canonical_version are already set
+func signEvent(event *FactoEvent, privateKey ed25519.PrivateKey) error {
+	canonical, err := BuildCanonicalForm(event)
+	if err != nil {
+		return err
+	}
+	event.Proof.EventHash = ComputeHash(canonical)
+	event.Proof.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(canonical)))
+	event.Proof.PublicKey = base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// VerifyEvent checks an event's hash and Ed25519 signature. An event with an
+// unknown canonical_version fails both.
+func VerifyEvent(event *FactoEvent) (hashValid, signatureValid bool) {
+	canonical, err := BuildCanonicalForm(event)
+	if err != nil {
+		return false, false
+	}
+	hashValid = ComputeHash(canonical) == event.Proof.EventHash
+
+	pubKey, err := base64.StdEncoding.DecodeString(event.Proof.PublicKey)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return hashValid, false
+	}
+	sig, err := base64.StdEncoding.DecodeString(event.Proof.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return hashValid, false
+	}
+	return hashValid, ed25519.Verify(pubKey, []byte(canonical), sig)
+}
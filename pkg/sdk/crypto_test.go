@@ -0,0 +1,149 @@
+package facto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// loadGolden decodes an event from tests/golden the way the services do,
+// keeping numbers exact
+func loadGolden(t *testing.T, name string) *FactoEvent {
+	t.Helper()
+	data, err := os.ReadFile("../../tests/golden/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var event FactoEvent
+	if err := dec.Decode(&event); err != nil {
+		t.Fatal(err)
+	}
+	return &event
+}
+
+func readGolden(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("../../tests/golden/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimRight(string(data), "\n")
+}
+
+// The services pin their canonical forms in tests/golden; the SDK must
+// produce the same bytes for every version
+func TestBuildCanonicalFormMatchesGolden(t *testing.T) {
+	cases := []struct {
+		event, canonical string
+		versions         []int
+	}{
+		// The v1 golden event has nothing the encodings treat differently
+		{"canonical_event.json", "canonical_event.canonical", []int{0, CanonicalV1, CanonicalV2}},
+		{"canonical_event_v3.json", "canonical_event_v3.canonical", []int{CanonicalV3}},
+	}
+	for _, c := range cases {
+		want := readGolden(t, c.canonical)
+		for _, version := range c.versions {
+			event := loadGolden(t, c.event)
+			event.Proof.CanonicalVersion = version
+			form, err := BuildCanonicalForm(event)
+			if err != nil {
+				t.Fatalf("%s, version %d: %v", c.event, version, err)
+			}
+			if form != want {
+				t.Errorf("%s, version %d:\ngot  %s\nwant %s", c.event, version, form, want)
+			}
+			if ComputeHash(form) != event.Proof.EventHash {
+				t.Errorf("%s, version %d: hash doesn't match the golden event_hash", c.event, version)
+			}
+		}
+	}
+}
+
+func TestVerifyEventAgainstSignatureVector(t *testing.T) {
+	data, err := os.ReadFile("../../tests/golden/signature_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors struct {
+		Vectors []struct {
+			SigAlgo   string `json:"sig_algo"`
+			PublicKey string `json:"public_key"`
+			Signature string `json:"signature"`
+			Valid     bool   `json:"valid"`
+		} `json:"vectors"`
+	}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors.Vectors {
+		if vector.SigAlgo != "ed25519" {
+			continue
+		}
+		event := loadGolden(t, "canonical_event.json")
+		event.Proof.PublicKey = vector.PublicKey
+		event.Proof.Signature = vector.Signature
+		hashValid, signatureValid := VerifyEvent(event)
+		if !hashValid || signatureValid != vector.Valid {
+			t.Errorf("vector %s: hash %v, signature %v, want signature %v",
+				vector.Signature, hashValid, signatureValid, vector.Valid)
+		}
+	}
+}
+
+func TestBuildCanonicalFormV3SignsMetadata(t *testing.T) {
+	want := readGolden(t, "canonical_event_v3.canonical")
+	mutations := map[string]func(e *FactoEvent){
+		"model_hash":   func(e *FactoEvent) { e.ExecutionMeta.ModelHash = nil },
+		"max_tokens":   func(e *FactoEvent) { e.ExecutionMeta.MaxTokens = nil },
+		"sdk_language": func(e *FactoEvent) { e.ExecutionMeta.SDKLanguage = "go" },
+		"tags":         func(e *FactoEvent) { e.ExecutionMeta.Tags["env"] = "prod" },
+		"version":      func(e *FactoEvent) { e.Proof.CanonicalVersion = CanonicalV2 },
+	}
+	for name, mutate := range mutations {
+		event := loadGolden(t, "canonical_event_v3.json")
+		mutate(event)
+		if form, _ := BuildCanonicalForm(event); form == want {
+			t.Errorf("changing %s left the V3 form unchanged", name)
+		}
+	}
+}
+
+func TestUnknownCanonicalVersion(t *testing.T) {
+	event := loadGolden(t, "canonical_event.json")
+	event.Proof.CanonicalVersion = 99
+	if _, err := BuildCanonicalForm(event); !errors.Is(err, ErrUnknownCanonicalVersion) {
+		t.Errorf("expected ErrUnknownCanonicalVersion, got %v", err)
+	}
+	if hashValid, signatureValid := VerifyEvent(event); hashValid || signatureValid {
+		t.Error("an unknown version must not verify")
+	}
+}
+
+func TestMarshalJCS(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		want string
+	}{
+		{[]interface{}{1e-7, 1e21, 1e20, 5e-324, 123.456}, `[1e-7,1e+21,100000000000000000000,5e-324,123.456]`},
+		{json.Number("9223372036854775809"), `9223372036854775809`},
+		// UTF-16 order puts the surrogate pair before U+FF01; UTF-8 wouldn't
+		{map[string]interface{}{"\uff01": 1, "z": 2, "\U0001F600": 3}, "{\"z\":2,\"\U0001F600\":3,\"\uff01\":1}"},
+		{"<b>\u00e9\x1f", `"<b>é\u001f"`},
+	}
+	for _, c := range cases {
+		got, err := marshalJCS(c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Basic usage example for the Facto Go SDK.
+//
+// It records two chained events in one session and verifies them locally.
+// Requires a NATS server with JetStream at NATS_URL (default localhost).
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	facto "github.com/facto-ai/facto/pkg/sdk"
+	"github.com/nats-io/nats.go"
+)
+
+func main() {
+	natsURL := os.Getenv("NATS_URL")
+	if natsURL == "" {
+		natsURL = nats.DefaultURL
+	}
+
+	nc, err := nats.Connect(natsURL)
+	if err != nil {
+		log.Fatalf("connect to NATS: %v", err)
+	}
+	defer nc.Close()
+
+	client, err := facto.NewClient(nc, facto.Config{
+		AgentID: "example-agent-go",
+		Tags:    map[string]string{"environment": "development"},
+	})
+	if err != nil {
+		log.Fatalf("create client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session := client.Session(fmt.Sprintf("session-%d", time.Now().UnixNano()))
+
+	llmCall, err := session.RecordEvent(ctx, facto.EventOptions{
+		ActionType: "llm_call",
+		InputData:  map[string]interface{}{"prompt": "What is the weather in SF?"},
+		OutputData: map[string]interface{}{"response": "Let me look that up."},
+	}, facto.WithModelID("gpt-4"))
+	if err != nil {
+		log.Fatalf("record llm_call: %v", err)
+	}
+	fmt.Printf("Recorded: %s (hash %s)\n", llmCall.FactoID, llmCall.Proof.EventHash)
+
+	toolCall, err := session.RecordEvent(ctx, facto.EventOptions{
+		ActionType: "tool_use",
+		InputData:  map[string]interface{}{"tool": "weather", "city": "SF"},
+		OutputData: map[string]interface{}{"forecast": "sunny"},
+	},
+		facto.WithParent(llmCall.FactoID),
+		facto.WithToolCalls(map[string]interface{}{"name": "weather", "args": map[string]interface{}{"city": "SF"}}),
+		facto.WithTags(map[string]string{"tool": "weather"}),
+	)
+	if err != nil {
+		log.Fatalf("record tool_use: %v", err)
+	}
+	fmt.Printf("Recorded: %s (prev_hash %s)\n", toolCall.FactoID, toolCall.Proof.PrevHash)
+
+	for _, event := range []*facto.FactoEvent{llmCall, toolCall} {
+		hashValid, signatureValid := facto.VerifyEvent(event)
+		fmt.Printf("Verify %s: hash=%v signature=%v\n", event.FactoID, hashValid, signatureValid)
+	}
+}
@@ -0,0 +1,117 @@
+package facto
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// SDKVersion is the version reported in execution_meta.sdk_version
+const SDKVersion = "0.1.0"
+
+// SDKLanguage is the language reported in execution_meta.sdk_language
+const SDKLanguage = "go"
+
+// GenesisHash is the prev_hash of the first event in a session
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// FactoEvent is a signed event as published to NATS
+type FactoEvent struct {
+	FactoID       string                 `json:"facto_id"`
+	AgentID       string                 `json:"agent_id"`
+	SessionID     string                 `json:"session_id"`
+	ParentFactoID *string                `json:"parent_facto_id"`
+	ActionType    string                 `json:"action_type"`
+	Status        string                 `json:"status"`
+	InputData     map[string]interface{} `json:"input_data"`
+	OutputData    map[string]interface{} `json:"output_data"`
+	ExecutionMeta ExecutionMeta          `json:"execution_meta"`
+	Proof         Proof                  `json:"proof"`
+	StartedAt     int64                  `json:"started_at"`
+	CompletedAt   int64                  `json:"completed_at"`
+}
+
+// ExecutionMeta contains execution metadata
+type ExecutionMeta struct {
+	ModelID     *string           `json:"model_id"`
+	ModelHash   *string           `json:"model_hash"`
+	Temperature *float64          `json:"temperature"`
+	Seed        *int64            `json:"seed"`
+	MaxTokens   *int32            `json:"max_tokens"`
+	ToolCalls   []interface{}     `json:"tool_calls"`
+	SDKVersion  string            `json:"sdk_version"`
+	SDKLanguage string            `json:"sdk_language"`
+	Tags        map[string]string `json:"tags"`
+}
+
+// Proof contains cryptographic proof
+type Proof struct {
+	Signature string `json:"signature"`
+	PublicKey string `json:"public_key"`
+	PrevHash  string `json:"prev_hash"`
+	EventHash string `json:"event_hash"`
+	// CanonicalVersion is the canonical form the event was signed over; 0
+	// means CanonicalV1
+	CanonicalVersion int `json:"canonical_version,omitempty"`
+}
+
+// EventOptions describes the action being recorded
+type EventOptions struct {
+	SessionID   string
+	ActionType  string
+	Status      string // Defaults to "success"
+	InputData   map[string]interface{}
+	OutputData  map[string]interface{}
+	StartedAt   time.Time // Defaults to now
+	CompletedAt time.Time // Defaults to now
+}
+
+// EventOption customizes optional event fields
+type EventOption func(*FactoEvent)
+
+// WithModelID sets execution_meta.model_id
+func WithModelID(modelID string) EventOption {
+	return func(e *FactoEvent) {
+		e.ExecutionMeta.ModelID = &modelID
+	}
+}
+
+// WithTags merges tags into execution_meta.tags
+func WithTags(tags map[string]string) EventOption {
+	return func(e *FactoEvent) {
+		for k, v := range tags {
+			e.ExecutionMeta.Tags[k] = v
+		}
+	}
+}
+
+// WithParent sets parent_facto_id for nested actions
+func WithParent(parentFactoID string) EventOption {
+	return func(e *FactoEvent) {
+		e.ParentFactoID = &parentFactoID
+	}
+}
+
+// WithToolCalls sets execution_meta.tool_calls
+func WithToolCalls(toolCalls ...interface{}) EventOption {
+	return func(e *FactoEvent) {
+		e.ExecutionMeta.ToolCalls = toolCalls
+	}
+}
+
+// GenerateFactoID returns a new facto ID ("ft-" followed by a UUIDv4)
+func GenerateFactoID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("ft-%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// timestampNs converts a time to nanoseconds since epoch, truncated to
+// millisecond precision to match the ScyllaDB timestamp type
+func timestampNs(t time.Time) int64 {
+	return t.UnixMilli() * int64(time.Millisecond)
+}
@@ -34,7 +34,7 @@ Example usage:
 """
 
 from .client import AsyncFactoClient, FactoClient
-from .crypto import CryptoProvider, generate_keypair, verify_event
+from .crypto import CANONICAL_VERSION, CryptoProvider, generate_keypair, verify_event
 from .cli import verify_evidence_bundle
 from .models import (
     ExecutionMeta,
@@ -60,6 +60,7 @@ __all__ = [
     "Proof",
     # Crypto
     "CryptoProvider",
+    "CANONICAL_VERSION",
     "generate_keypair",
     "verify_event",
     # CLI / Verification
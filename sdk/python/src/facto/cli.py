@@ -53,8 +53,9 @@ def build_canonical_form(event: Dict[str, Any]) -> str:
         - parent_facto_id, prev_hash
         - execution_meta: model_id, seed, sdk_version, temperature, tool_calls
     
-    Mutable metadata (not signed):
-        - execution_meta: model_hash, max_tokens, sdk_language, tags
+    Events with proof.canonical_version 3 also sign canonical_version and
+    execution_meta model_hash, max_tokens, sdk_language and tags; in older
+    versions those are mutable metadata. An unknown version raises ValueError.
     """
     return _crypto.build_canonical_form(event)
 
@@ -70,11 +71,15 @@ def verify_event_hash(event: Dict[str, Any]) -> Tuple[bool, str, str]:
     """
     Verify an event's SHA3-256 hash.
     
-    Returns: (is_valid, computed_hash, stored_hash)
+    Returns: (is_valid, computed_hash, stored_hash); computed_hash is empty
+    when the event's canonical_version is unknown.
     """
-    canonical = build_canonical_form(event)
-    computed = compute_sha3_256(canonical)
     stored = event["proof"]["event_hash"]
+    try:
+        canonical = build_canonical_form(event)
+    except ValueError:
+        return False, "", stored
+    computed = compute_sha3_256(canonical)
     return computed == stored, computed, stored
 
 
@@ -139,15 +144,40 @@ def verify_chain_integrity(events: List[Dict[str, Any]]) -> Tuple[bool, List[str
     return len(errors) == 0, errors
 
 
-def hash_pair(left: str, right: str) -> str:
+# Merkle tree schemes (see the server's merkle package). "legacy" hashes
+# SHA256(left || right) over raw leaves; "rfc6962" hashes leaves as
+# SHA256(0x00 || leaf) and nodes as SHA256(0x01 || left || right).
+MERKLE_SCHEME_LEGACY = "legacy"
+MERKLE_SCHEME_RFC6962 = "rfc6962"
+
+
+def hash_pair(left: str, right: str, scheme: str = MERKLE_SCHEME_LEGACY) -> str:
     """Hash two hex strings together using SHA256."""
     left_bytes = bytes.fromhex(left)
     right_bytes = bytes.fromhex(right)
     combined = left_bytes + right_bytes
+    if scheme == MERKLE_SCHEME_RFC6962:
+        combined = b"\x01" + combined
     return hashlib.sha256(combined).hexdigest()
 
 
-def verify_merkle_proof(event_hash: str, proof: List[Dict[str, str]], root: str) -> bool:
+def hash_leaf(leaf: str, scheme: str = MERKLE_SCHEME_LEGACY) -> str:
+    """Return the tree node for a leaf hash: itself for legacy trees."""
+    if scheme == MERKLE_SCHEME_RFC6962:
+        return hashlib.sha256(b"\x00" + bytes.fromhex(leaf)).hexdigest()
+    return leaf
+
+
+class InvalidProofPositionError(ValueError):
+    """A Merkle proof element's position is neither "left" nor "right"."""
+
+
+def verify_merkle_proof(
+    event_hash: str,
+    proof: List[Dict[str, str]],
+    root: str,
+    scheme: str = MERKLE_SCHEME_LEGACY,
+) -> bool:
     """
     Verify a Merkle inclusion proof.
     
@@ -155,19 +185,31 @@ def verify_merkle_proof(event_hash: str, proof: List[Dict[str, str]], root: str)
         event_hash: The hash of the event leaf
         proof: List of proof elements with 'hash' and 'position' (left/right)
         root: The expected Merkle root
+        scheme: The tree scheme, "legacy" or "rfc6962" (a proof's "scheme")
     
     Returns: True if the proof is valid
+
+    Raises:
+        InvalidProofPositionError: if an element's position is not left/right
+        ValueError: if the scheme is unknown
     """
-    current = event_hash
+    if scheme not in (MERKLE_SCHEME_LEGACY, MERKLE_SCHEME_RFC6962):
+        raise ValueError(f"unknown Merkle tree scheme {scheme!r}")
+
+    current = hash_leaf(event_hash, scheme)
     
-    for element in proof:
+    for i, element in enumerate(proof):
         sibling = element["hash"]
-        position = element["position"]
+        position = element.get("position")
         
         if position == "left":
-            current = hash_pair(sibling, current)
-        else:  # right
-            current = hash_pair(current, sibling)
+            current = hash_pair(sibling, current, scheme)
+        elif position == "right":
+            current = hash_pair(current, sibling, scheme)
+        else:
+            raise InvalidProofPositionError(
+                f"invalid Merkle proof position {position!r} at proof element {i}"
+            )
     
     return current == root
 
@@ -207,7 +249,14 @@ def verify_merkle_proofs(
             errors.append(f"No Merkle root for event {facto_id}")
             continue
         
-        if verify_merkle_proof(event_hash, proof_elements, root):
+        scheme = proof_data.get("scheme") or MERKLE_SCHEME_LEGACY
+        try:
+            proof_valid = verify_merkle_proof(event_hash, proof_elements, root, scheme)
+        except ValueError as e:
+            errors.append(f"Malformed Merkle proof for event {facto_id}: {e}")
+            continue
+
+        if proof_valid:
             valid += 1
         else:
             errors.append(f"Invalid Merkle proof for event {facto_id}")
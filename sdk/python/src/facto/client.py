@@ -10,7 +10,7 @@ from typing import Any, Callable, Dict, Generator, List, Optional, TypeVar, Unio
 
 import httpx
 
-from .crypto import CryptoProvider
+from .crypto import CANONICAL_VERSION, CryptoProvider
 from .models import (
     ExecutionMeta,
     Proof,
@@ -123,6 +123,7 @@ class FactoClient:
             },
             "proof": {
                 "prev_hash": self._crypto.prev_hash,
+                "canonical_version": CANONICAL_VERSION,
             },
             "started_at": started_at or now,
             "completed_at": completed_at or now,
@@ -147,6 +148,7 @@ class FactoClient:
                 public_key=self._crypto.public_key_base64,
                 prev_hash=self._crypto.prev_hash,
                 event_hash=event_hash,
+                canonical_version=CANONICAL_VERSION,
             ),
             started_at=started_at or now,
             completed_at=completed_at or now,
@@ -402,6 +404,7 @@ class AsyncFactoClient:
             },
             "proof": {
                 "prev_hash": self._crypto.prev_hash,
+                "canonical_version": CANONICAL_VERSION,
             },
             "started_at": started_at or now,
             "completed_at": completed_at or now,
@@ -424,6 +427,7 @@ class AsyncFactoClient:
                 public_key=self._crypto.public_key_base64,
                 prev_hash=self._crypto.prev_hash,
                 event_hash=event_hash,
+                canonical_version=CANONICAL_VERSION,
             ),
             started_at=started_at or now,
             completed_at=completed_at or now,
@@ -3,11 +3,89 @@
 import base64
 import hashlib
 import json
-from typing import Any, Dict, Optional, Tuple
+import math
+from decimal import Decimal
+from typing import Any, Dict, List, Optional, Tuple
 
 from nacl.signing import SigningKey, VerifyKey
 from nacl.exceptions import BadSignatureError
 
+# proof.canonical_version: 1 (or absent) is the original serialization, 2 the
+# same fields in RFC 8785 JCS, 3 JCS over every execution_meta field plus the
+# version itself. The SDK signs with CANONICAL_VERSION.
+CANONICAL_V1 = 1
+CANONICAL_V2 = 2
+CANONICAL_V3 = 3
+CANONICAL_VERSION = CANONICAL_V3
+
+
+def _jcs_number(value: Any) -> str:
+    """Format a number as ECMAScript does, which is what JCS requires."""
+    if isinstance(value, int):
+        # Integers are written exactly, as the Go services do
+        return str(value)
+    if not math.isfinite(value):
+        raise ValueError(f"JCS cannot encode {value}")
+    if value == 0:
+        return "0"
+
+    # repr gives the shortest digits that round-trip, as ECMAScript does
+    sign, digits, exponent = Decimal(repr(value)).normalize().as_tuple()
+    digits_str = "".join(str(d) for d in digits)
+    k = len(digits_str)
+    n = k + exponent
+    if k <= n <= 21:
+        out = digits_str + "0" * (n - k)
+    elif 0 < n <= 21:
+        out = digits_str[:n] + "." + digits_str[n:]
+    elif -6 < n <= 0:
+        out = "0." + "0" * -n + digits_str
+    else:
+        e = n - 1
+        mantissa = digits_str if k == 1 else digits_str[0] + "." + digits_str[1:]
+        out = mantissa + "e" + ("+" if e >= 0 else "-") + str(abs(e))
+    return ("-" if sign else "") + out
+
+
+def _write_jcs(value: Any, out: List[str]) -> None:
+    if value is None:
+        out.append("null")
+    elif value is True:
+        out.append("true")
+    elif value is False:
+        out.append("false")
+    elif isinstance(value, (int, float)):
+        out.append(_jcs_number(value))
+    elif isinstance(value, str):
+        out.append(json.dumps(value, ensure_ascii=False))
+    elif isinstance(value, (list, tuple)):
+        out.append("[")
+        for i, item in enumerate(value):
+            if i:
+                out.append(",")
+            _write_jcs(item, out)
+        out.append("]")
+    elif isinstance(value, dict):
+        out.append("{")
+        # JCS sorts keys by UTF-16 code units, which big-endian UTF-16 bytes
+        # compare in the same order as
+        for i, key in enumerate(sorted(value, key=lambda k: k.encode("utf-16-be"))):
+            if i:
+                out.append(",")
+            out.append(json.dumps(key, ensure_ascii=False))
+            out.append(":")
+            _write_jcs(value[key], out)
+        out.append("}")
+    else:
+        raise TypeError(f"JCS cannot encode {type(value).__name__}")
+
+
+def canonicalize_jcs(value: Any) -> str:
+    """Serialize a JSON value as RFC 8785 JCS."""
+    out: List[str] = []
+    _write_jcs(value, out)
+    return "".join(out)
+
 
 class CryptoProvider:
     """Handles cryptographic operations for event signing and verification."""
@@ -64,8 +142,14 @@ class CryptoProvider:
         """
         Build the canonical JSON form for hashing/signing.
 
-        The canonical form has sorted keys and no extra whitespace.
+        The form is the one for the event's proof.canonical_version (absent
+        means 1); an unknown version raises ValueError. It must match the
+        Query API byte for byte; tests/golden pins the expected output.
         """
+        version = (event_dict.get("proof") or {}).get("canonical_version") or CANONICAL_V1
+        if version not in (CANONICAL_V1, CANONICAL_V2, CANONICAL_V3):
+            raise ValueError(f"Unknown canonical_version: {version}")
+
         # Build the canonical structure with specific fields in sorted order
         canonical: Dict[str, Any] = {}
 
@@ -75,18 +159,34 @@ class CryptoProvider:
 
         # Build execution_meta in sorted order
         exec_meta: Dict[str, Any] = {}
-        em = event_dict.get("execution_meta", {})
+        # Non-LLM events may carry no model metadata at all: model_id and
+        # temperature are then omitted, seed is null and tool_calls is [].
+        em = event_dict.get("execution_meta") or {}
         if em.get("model_id") is not None:
             exec_meta["model_id"] = em["model_id"]
         exec_meta["seed"] = em.get("seed")
         exec_meta["sdk_version"] = em.get("sdk_version", "0.1.0")
         if em.get("temperature") is not None:
             exec_meta["temperature"] = em["temperature"]
-        exec_meta["tool_calls"] = em.get("tool_calls", [])
+        exec_meta["tool_calls"] = em.get("tool_calls") or []
+        if version == CANONICAL_V3:
+            # V3 also signs the fields earlier versions left mutable
+            if em.get("model_hash") is not None:
+                exec_meta["model_hash"] = em["model_hash"]
+            if em.get("max_tokens") is not None:
+                exec_meta["max_tokens"] = em["max_tokens"]
+            exec_meta["sdk_language"] = em.get("sdk_language")
+            exec_meta["tags"] = em.get("tags") or {}
+            canonical["canonical_version"] = version
         canonical["execution_meta"] = exec_meta
 
-        canonical["input_data"] = event_dict["input_data"]
-        canonical["output_data"] = event_dict["output_data"]
+        # Absent input/output data is canonicalized as {} rather than null, so a
+        # "started" event without output yet verifies consistently. The signature
+        # covers the event's state at signing time.
+        input_data = event_dict.get("input_data")
+        output_data = event_dict.get("output_data")
+        canonical["input_data"] = input_data if input_data is not None else {}
+        canonical["output_data"] = output_data if output_data is not None else {}
         canonical["parent_facto_id"] = event_dict.get("parent_facto_id")
         canonical["prev_hash"] = event_dict["proof"]["prev_hash"]
         canonical["session_id"] = event_dict["session_id"]
@@ -94,7 +194,9 @@ class CryptoProvider:
         canonical["status"] = event_dict["status"]
         canonical["facto_id"] = event_dict["facto_id"]
 
-        return json.dumps(canonical, sort_keys=True, separators=(",", ":"))
+        if version == CANONICAL_V1:
+            return json.dumps(canonical, sort_keys=True, separators=(",", ":"))
+        return canonicalize_jcs(canonical)
 
     def compute_hash(self, canonical: str) -> str:
         """Compute SHA3-256 hash of the canonical form."""
@@ -126,7 +228,8 @@ class CryptoProvider:
         Sign an event and compute its hash.
 
         Args:
-            event_dict: Event dictionary with proof.prev_hash set
+            event_dict: Event dictionary with proof.prev_hash and
+                proof.canonical_version set
 
         Returns:
             Tuple of (event_hash, signature_base64)
@@ -166,8 +269,11 @@ def verify_event(event_dict: Dict[str, Any]) -> Tuple[bool, bool]:
     """
     crypto = CryptoProvider()
 
-    # Build canonical form
-    canonical = crypto.build_canonical_form(event_dict)
+    # Build canonical form; an unknown canonical_version can't verify
+    try:
+        canonical = crypto.build_canonical_form(event_dict)
+    except ValueError:
+        return False, False
 
     # Verify hash
     computed_hash = crypto.compute_hash(canonical)
@@ -49,6 +49,7 @@ class Proof:
     public_key: str  # Base64-encoded Ed25519 public key
     prev_hash: str  # SHA3-256 hash of previous event (hex)
     event_hash: str  # SHA3-256 hash of this event (hex)
+    canonical_version: int = 1  # Canonical form signed over; see crypto.py
 
 
 @dataclass
@@ -95,6 +96,7 @@ class FactoEvent:
                 "public_key": self.proof.public_key,
                 "prev_hash": self.proof.prev_hash,
                 "event_hash": self.proof.event_hash,
+                "canonical_version": self.proof.canonical_version,
             },
             "started_at": self.started_at,
             "completed_at": self.completed_at,
@@ -10,8 +10,10 @@ from pathlib import Path
 import pytest
 
 from facto.cli import (
+    InvalidProofPositionError,
     build_canonical_form,
     compute_sha3_256,
+    hash_leaf,
     hash_pair,
     verify_chain_integrity,
     verify_event_hash,
@@ -177,6 +179,40 @@ class TestMerkleProof:
         proof = [{"hash": left, "position": "left"}]
         assert verify_merkle_proof(right, proof, root)
 
+    def test_invalid_position_raises(self):
+        """A bogus position is an error, not a silently-accepted "right"."""
+        left = "a" * 64
+        right = "b" * 64
+        root = hash_pair(left, right)
+
+        proof = [{"hash": right, "position": "rihgt"}]
+        with pytest.raises(InvalidProofPositionError):
+            verify_merkle_proof(left, proof, root)
+
+    def test_rfc6962_proof(self):
+        """rfc6962 proofs hash the leaf and nodes with domain prefixes."""
+        left = "a" * 64
+        right = "b" * 64
+        root = hash_pair(hash_leaf(left, "rfc6962"), hash_leaf(right, "rfc6962"), "rfc6962")
+
+        proof = [{"hash": hash_leaf(right, "rfc6962"), "position": "right"}]
+        assert verify_merkle_proof(left, proof, root, "rfc6962")
+        # The same proof checked as legacy doesn't reach the root
+        assert not verify_merkle_proof(left, proof, root)
+
+    def test_rfc6962_rejects_node_as_leaf(self):
+        """An interior node can't be passed off as a leaf under rfc6962."""
+        a, b, c, d = "a" * 64, "b" * 64, "c" * 64, "d" * 64
+        ab = hash_pair(hash_leaf(a, "rfc6962"), hash_leaf(b, "rfc6962"), "rfc6962")
+        cd = hash_pair(hash_leaf(c, "rfc6962"), hash_leaf(d, "rfc6962"), "rfc6962")
+        root = hash_pair(ab, cd, "rfc6962")
+
+        assert not verify_merkle_proof(ab, [{"hash": cd, "position": "right"}], root, "rfc6962")
+
+    def test_unknown_scheme_raises(self):
+        with pytest.raises(ValueError):
+            verify_merkle_proof("a" * 64, [], "a" * 64, "sha1")
+
 
 class TestEvidenceBundle:
     """Tests for full evidence bundle verification."""
@@ -86,6 +86,65 @@ class TestCryptoProvider:
         assert "action_type" in parsed
         assert "agent_id" in parsed
 
+    def test_canonical_form_matches_golden(self):
+        """Test the canonical bytes pinned in tests/golden."""
+        import json
+        from pathlib import Path
+
+        golden = Path(__file__).resolve().parents[3] / "tests" / "golden"
+        event_dict = json.loads((golden / "canonical_event.json").read_text())
+        expected = (golden / "canonical_event.canonical").read_bytes()
+
+        crypto = CryptoProvider()
+        canonical = crypto.build_canonical_form(event_dict)
+        assert canonical.encode("utf-8") == expected
+        assert crypto.compute_hash(canonical) == event_dict["proof"]["event_hash"]
+
+        # Unsigned metadata does not affect the canonical form
+        event_dict["execution_meta"]["max_tokens"] = 1
+        event_dict["execution_meta"]["tags"] = {"env": "prod"}
+        assert crypto.build_canonical_form(event_dict).encode("utf-8") == expected
+
+    def test_canonical_v3_matches_golden(self):
+        """Test the version 3 canonical bytes pinned in tests/golden."""
+        import json
+        from pathlib import Path
+
+        golden = Path(__file__).resolve().parents[3] / "tests" / "golden"
+        event_dict = json.loads((golden / "canonical_event_v3.json").read_text())
+        expected = (golden / "canonical_event_v3.canonical").read_bytes()
+
+        crypto = CryptoProvider()
+        canonical = crypto.build_canonical_form(event_dict)
+        assert canonical.encode("utf-8") == expected
+        assert crypto.compute_hash(canonical) == event_dict["proof"]["event_hash"]
+
+        # Version 3 signs the metadata earlier versions left mutable
+        event_dict["execution_meta"]["tags"] = {"env": "prod"}
+        assert crypto.build_canonical_form(event_dict).encode("utf-8") != expected
+
+    def test_unknown_canonical_version(self):
+        """Test that an unknown canonical_version is rejected, not guessed."""
+        import json
+        from pathlib import Path
+
+        golden = Path(__file__).resolve().parents[3] / "tests" / "golden"
+        event_dict = json.loads((golden / "canonical_event.json").read_text())
+        event_dict["proof"]["canonical_version"] = 99
+
+        with pytest.raises(ValueError):
+            CryptoProvider().build_canonical_form(event_dict)
+        assert verify_event(event_dict) == (False, False)
+
+    def test_jcs_numbers(self):
+        """Test that JCS formats numbers as ECMAScript does."""
+        from facto.crypto import canonicalize_jcs
+
+        assert canonicalize_jcs([1e-7, 1e21, 1e20, 0.1 + 0.2, -0.0]) == (
+            "[1e-7,1e+21,100000000000000000000,0.30000000000000004,0]"
+        )
+        assert canonicalize_jcs(2**63 + 1) == "9223372036854775809"
+
     def test_sign_event(self):
         """Test event signing."""
         crypto = CryptoProvider()
@@ -208,6 +267,101 @@ class TestVerifyEvent:
         assert not hash_valid
         assert not sig_valid
 
+    def _signed_event(self, crypto, output_data):
+        """Build and sign a started-state event with the given output_data."""
+        event_dict = {
+            "facto_id": "ft-test",
+            "agent_id": "agent-test",
+            "session_id": "session-test",
+            "parent_facto_id": None,
+            "action_type": "llm_call",
+            "status": "started",
+            "input_data": {"prompt": "hi"},
+            "output_data": output_data,
+            "execution_meta": {
+                "model_id": None,
+                "temperature": None,
+                "seed": None,
+                "sdk_version": "0.1.0",
+                "tool_calls": [],
+            },
+            "proof": {"prev_hash": "0" * 64},
+            "started_at": 1000000000,
+            "completed_at": 1000000001,
+        }
+        event_hash, signature = crypto.sign_event(event_dict)
+        event_dict["proof"]["event_hash"] = event_hash
+        event_dict["proof"]["signature"] = signature
+        event_dict["proof"]["public_key"] = crypto.public_key_base64
+        return event_dict
+
+    def test_verify_event_with_empty_output(self):
+        """A started event without output verifies, and null equals {}."""
+        crypto = CryptoProvider()
+        event_dict = self._signed_event(crypto, None)
+
+        hash_valid, sig_valid = verify_event(event_dict)
+        assert hash_valid
+        assert sig_valid
+
+        event_dict["output_data"] = {}
+        hash_valid, sig_valid = verify_event(event_dict)
+        assert hash_valid
+        assert sig_valid
+
+    def test_verify_event_with_populated_output(self):
+        """Adding output to a signed started event invalidates it."""
+        crypto = CryptoProvider()
+        event_dict = self._signed_event(crypto, {"response": "hello"})
+
+        hash_valid, sig_valid = verify_event(event_dict)
+        assert hash_valid
+        assert sig_valid
+
+        event_dict["output_data"] = {}
+        hash_valid, sig_valid = verify_event(event_dict)
+        assert not hash_valid
+        assert not sig_valid
+
+    def test_verify_tool_call_event_without_model_meta(self):
+        """A non-LLM event verifies whether model fields are null or absent."""
+        crypto = CryptoProvider()
+        event_dict = {
+            "facto_id": "ft-tool",
+            "agent_id": "agent-test",
+            "session_id": "session-test",
+            "parent_facto_id": None,
+            "action_type": "tool_call",
+            "status": "success",
+            "input_data": {"url": "https://example.com"},
+            "output_data": {"status": 200},
+            "execution_meta": {
+                "model_id": None,
+                "model_hash": None,
+                "temperature": None,
+                "seed": None,
+                "max_tokens": None,
+                "tool_calls": None,
+                "sdk_version": "0.1.0",
+            },
+            "proof": {"prev_hash": "0" * 64},
+            "started_at": 1000000000,
+            "completed_at": 1000000001,
+        }
+        event_hash, signature = crypto.sign_event(event_dict)
+        event_dict["proof"]["event_hash"] = event_hash
+        event_dict["proof"]["signature"] = signature
+        event_dict["proof"]["public_key"] = crypto.public_key_base64
+
+        hash_valid, sig_valid = verify_event(event_dict)
+        assert hash_valid
+        assert sig_valid
+
+        event_dict["execution_meta"] = {"sdk_version": "0.1.0", "tool_calls": []}
+        hash_valid, sig_valid = verify_event(event_dict)
+        assert hash_valid
+        assert sig_valid
+
 
 class TestFactoClient:
     """Tests for the FactoClient (mocked HTTP)."""
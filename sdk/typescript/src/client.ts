@@ -2,7 +2,7 @@
  * Facto client for sending events to the ingestion service.
  */
 
-import { CryptoProvider } from './crypto';
+import { CANONICAL_VERSION, CryptoProvider } from './crypto';
 import {
   type BatchIngestRequest,
   type BatchIngestResponse,
@@ -131,6 +131,7 @@ export class FactoClient {
         public_key: this.crypto.publicKeyBase64,
         prev_hash: this.crypto.prevHash,
         event_hash: '',
+        canonical_version: CANONICAL_VERSION,
       },
       started_at: options.startedAt ?? now,
       completed_at: options.completedAt ?? now,
@@ -155,6 +156,7 @@ export class FactoClient {
         publicKey: this.crypto.publicKeyBase64,
         prevHash: this.crypto.prevHash,
         eventHash,
+        canonicalVersion: CANONICAL_VERSION,
       },
       startedAt: options.startedAt ?? now,
       completedAt: options.completedAt ?? now,
@@ -7,6 +7,17 @@ import { sha3_256 } from '@noble/hashes/sha3';
 import { bytesToHex, hexToBytes } from '@noble/hashes/utils';
 import type { FactoEventWire } from './models';
 
+/**
+ * proof.canonical_version values: 1 (or absent) is the original
+ * serialization, 2 the same fields in RFC 8785 JCS, 3 JCS over every
+ * execution_meta field plus the version itself. The SDK signs with
+ * CANONICAL_VERSION.
+ */
+export const CANONICAL_V1 = 1;
+export const CANONICAL_V2 = 2;
+export const CANONICAL_V3 = 3;
+export const CANONICAL_VERSION = CANONICAL_V3;
+
 // Enable synchronous methods for ed25519
 // @ts-ignore - This is needed for synchronous signing
 ed25519.etc.sha512Sync = (...m: Uint8Array[]) => {
@@ -42,6 +53,31 @@ export function fromBase64(base64: string): Uint8Array {
   return bytes;
 }
 
+/**
+ * Serialize a value as compact JSON with object keys sorted at every level,
+ * matching the Python SDK's json.dumps(sort_keys=True) and the Query API.
+ * A JSON.stringify replacer array can't be used for this: it filters nested
+ * keys too, silently dropping execution_meta and input/output fields.
+ *
+ * This is also RFC 8785 JCS, as versions 2 and 3 require: Array.sort orders
+ * keys by UTF-16 code units and JSON.stringify formats numbers and escapes
+ * strings the way JCS specifies.
+ */
+function stringifySorted(value: unknown): string {
+  if (Array.isArray(value)) {
+    return '[' + value.map((v) => stringifySorted(v ?? null)).join(',') + ']';
+  }
+  if (value !== null && typeof value === 'object') {
+    const obj = value as Record<string, unknown>;
+    const members = Object.keys(obj)
+      .filter((key) => obj[key] !== undefined)
+      .sort()
+      .map((key) => JSON.stringify(key) + ':' + stringifySorted(obj[key]));
+    return '{' + members.join(',') + '}';
+  }
+  return JSON.stringify(value);
+}
+
 /**
  * Handles cryptographic operations for event signing and verification.
  */
@@ -97,9 +133,15 @@ export class CryptoProvider {
   }
 
   /**
-   * Build the canonical JSON form for hashing/signing.
+   * Build the canonical JSON form for hashing/signing, for the event's
+   * proof.canonical_version (absent means 1). Throws for an unknown version.
    */
   buildCanonicalForm(event: FactoEventWire): string {
+    const version = event.proof.canonical_version ?? CANONICAL_V1;
+    if (![CANONICAL_V1, CANONICAL_V2, CANONICAL_V3].includes(version)) {
+      throw new Error(`Unknown canonical_version: ${version}`);
+    }
+
     // Build the canonical structure with sorted keys
     const canonical: Record<string, unknown> = {};
 
@@ -107,21 +149,36 @@ export class CryptoProvider {
     canonical['agent_id'] = event.agent_id;
     canonical['completed_at'] = event.completed_at;
 
-    // Build execution_meta in sorted order
+    // Build execution_meta in sorted order. Non-LLM events may omit every
+    // model field: model_id and temperature are then left out, seed is null
+    // and tool_calls is [].
     const execMeta: Record<string, unknown> = {};
-    if (event.execution_meta.model_id !== null) {
+    if (event.execution_meta.model_id != null) {
       execMeta['model_id'] = event.execution_meta.model_id;
     }
-    execMeta['seed'] = event.execution_meta.seed;
+    execMeta['seed'] = event.execution_meta.seed ?? null;
     execMeta['sdk_version'] = event.execution_meta.sdk_version;
-    if (event.execution_meta.temperature !== null) {
+    if (event.execution_meta.temperature != null) {
       execMeta['temperature'] = event.execution_meta.temperature;
     }
-    execMeta['tool_calls'] = event.execution_meta.tool_calls;
+    execMeta['tool_calls'] = event.execution_meta.tool_calls ?? [];
+    if (version === CANONICAL_V3) {
+      // Version 3 also signs the fields earlier versions left mutable
+      if (event.execution_meta.model_hash != null) {
+        execMeta['model_hash'] = event.execution_meta.model_hash;
+      }
+      if (event.execution_meta.max_tokens != null) {
+        execMeta['max_tokens'] = event.execution_meta.max_tokens;
+      }
+      execMeta['sdk_language'] = event.execution_meta.sdk_language;
+      execMeta['tags'] = event.execution_meta.tags ?? {};
+      canonical['canonical_version'] = version;
+    }
     canonical['execution_meta'] = execMeta;
 
-    canonical['input_data'] = event.input_data;
-    canonical['output_data'] = event.output_data;
+    // Absent input/output data is canonicalized as {} rather than null
+    canonical['input_data'] = event.input_data ?? {};
+    canonical['output_data'] = event.output_data ?? {};
     canonical['parent_facto_id'] = event.parent_facto_id;
     canonical['prev_hash'] = event.proof.prev_hash;
     canonical['session_id'] = event.session_id;
@@ -129,7 +186,7 @@ export class CryptoProvider {
     canonical['status'] = event.status;
     canonical['facto_id'] = event.facto_id;
 
-    return JSON.stringify(canonical, Object.keys(canonical).sort());
+    return stringifySorted(canonical);
   }
 
   /**
@@ -173,7 +230,8 @@ export class CryptoProvider {
   /**
    * Sign an event and compute its hash.
    *
-   * @param event - Event in wire format with proof.prev_hash set
+   * @param event - Event in wire format with proof.prev_hash and
+   *   proof.canonical_version set
    * @returns Tuple of [eventHash, signatureBase64]
    */
   async signEvent(event: FactoEventWire): Promise<[string, string]> {
@@ -206,8 +264,13 @@ export async function verifyEvent(
 ): Promise<[boolean, boolean]> {
   const crypto = new CryptoProvider();
 
-  // Build canonical form
-  const canonical = crypto.buildCanonicalForm(event);
+  // Build canonical form; an unknown canonical_version can't verify
+  let canonical: string;
+  try {
+    canonical = crypto.buildCanonicalForm(event);
+  } catch {
+    return [false, false];
+  }
 
   // Verify hash
   const computedHash = crypto.computeHash(canonical);
@@ -55,6 +55,7 @@ export {
 // Crypto
 export {
   CryptoProvider,
+  CANONICAL_VERSION,
   generateKeypair,
   verifyEvent,
   toBase64,
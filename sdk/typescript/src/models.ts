@@ -55,6 +55,8 @@ export interface Proof {
   prevHash: string;
   /** SHA3-256 hash of this event (hex) */
   eventHash: string;
+  /** Canonical form the event was signed over; absent means 1 */
+  canonicalVersion?: number;
 }
 
 /**
@@ -103,6 +105,7 @@ export interface FactoEventWire {
     public_key: string;
     prev_hash: string;
     event_hash: string;
+    canonical_version?: number;
   };
   started_at: number;
   completed_at: number;
@@ -204,6 +207,7 @@ export function toWireFormat(event: FactoEvent): FactoEventWire {
       public_key: event.proof.publicKey,
       prev_hash: event.proof.prevHash,
       event_hash: event.proof.eventHash,
+      canonical_version: event.proof.canonicalVersion,
     },
     started_at: event.startedAt,
     completed_at: event.completedAt,
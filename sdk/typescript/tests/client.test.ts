@@ -2,6 +2,8 @@
  * Tests for the Facto SDK client.
  */
 
+import { readFileSync } from 'fs';
+import { resolve } from 'path';
 import { describe, it, expect } from 'vitest';
 import {
   FactoClient,
@@ -91,6 +93,47 @@ describe('CryptoProvider', () => {
     expect(canonical).toContain('agent_id');
   });
 
+  it('should match the golden canonical form', () => {
+    const golden = resolve(__dirname, '../../../tests/golden');
+    const event = JSON.parse(
+      readFileSync(resolve(golden, 'canonical_event.json'), 'utf8')
+    ) as FactoEventWire;
+    const expected = readFileSync(resolve(golden, 'canonical_event.canonical'), 'utf8');
+
+    const crypto = new CryptoProvider();
+    const canonical = crypto.buildCanonicalForm(event);
+    expect(canonical).toBe(expected);
+    expect(crypto.computeHash(canonical)).toBe(event.proof.event_hash);
+  });
+
+  it('should match the version 3 golden canonical form', () => {
+    const golden = resolve(__dirname, '../../../tests/golden');
+    const event = JSON.parse(
+      readFileSync(resolve(golden, 'canonical_event_v3.json'), 'utf8')
+    ) as FactoEventWire;
+    const expected = readFileSync(resolve(golden, 'canonical_event_v3.canonical'), 'utf8');
+
+    const crypto = new CryptoProvider();
+    const canonical = crypto.buildCanonicalForm(event);
+    expect(canonical).toBe(expected);
+    expect(crypto.computeHash(canonical)).toBe(event.proof.event_hash);
+
+    // Version 3 signs the metadata earlier versions left mutable
+    event.execution_meta.tags = { env: 'prod' };
+    expect(crypto.buildCanonicalForm(event)).not.toBe(expected);
+  });
+
+  it('should reject an unknown canonical version', async () => {
+    const golden = resolve(__dirname, '../../../tests/golden');
+    const event = JSON.parse(
+      readFileSync(resolve(golden, 'canonical_event.json'), 'utf8')
+    ) as FactoEventWire;
+    event.proof.canonical_version = 99;
+
+    expect(() => new CryptoProvider().buildCanonicalForm(event)).toThrow();
+    expect(await verifyEvent(event)).toEqual([false, false]);
+  });
+
   it('should sign event', async () => {
     const crypto = new CryptoProvider();
     const event: FactoEventWire = {
@@ -226,6 +269,54 @@ describe('verifyEvent', () => {
     expect(hashValid).toBe(false);
     expect(sigValid).toBe(false);
   });
+
+  it('should verify tool-call event without model metadata', async () => {
+    const crypto = new CryptoProvider();
+    const event: FactoEventWire = {
+      facto_id: 'ft-tool',
+      agent_id: 'agent-test',
+      session_id: 'session-test',
+      parent_facto_id: null,
+      action_type: 'tool_call',
+      status: 'success',
+      input_data: { url: 'https://example.com' },
+      output_data: { status: 200 },
+      execution_meta: {
+        model_id: null,
+        model_hash: null,
+        temperature: null,
+        seed: null,
+        max_tokens: null,
+        tool_calls: [],
+        sdk_version: '0.1.0',
+        sdk_language: 'typescript',
+        tags: {},
+      },
+      proof: {
+        signature: '',
+        public_key: crypto.publicKeyBase64,
+        prev_hash: '0'.repeat(64),
+        event_hash: '',
+      },
+      started_at: 1000000000,
+      completed_at: 1000000001,
+    };
+
+    const [eventHash, signature] = await crypto.signEvent(event);
+    event.proof.event_hash = eventHash;
+    event.proof.signature = signature;
+
+    // A producer that omits model fields entirely signs the same form
+    event.execution_meta = {
+      sdk_version: '0.1.0',
+      sdk_language: 'typescript',
+      tags: {},
+    } as unknown as FactoEventWire['execution_meta'];
+
+    const [hashValid, sigValid] = await verifyEvent(event);
+    expect(hashValid).toBe(true);
+    expect(sigValid).toBe(true);
+  });
 });
 
 describe('FactoClient', () => {
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	auditEventsScanned = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_audit_events_scanned_total",
+		Help: "Events verified by full-store audit scans",
+	})
+
+	auditFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_audit_failures_total",
+		Help: "Events that failed verification during full-store audit scans",
+	})
+)
+
+// Deletion manifest statuses
+const (
+	manifestPending   = "pending"
+	manifestCompleted = "completed"
+	manifestFailed    = "failed"
+)
+
+// sessionDeleter is the storage DeleteSession reads a session from, records
+// its deletion manifest in and deletes it from
+type sessionDeleter interface {
+	GetSessionEventKeys(ctx context.Context, sessionID string) ([]SessionEventKey, error)
+	StoreDeletionManifest(ctx context.Context, m *DeletionManifest) error
+	DeleteSessionEvents(ctx context.Context, sessionID string, keys []SessionEventKey) error
+}
+
+// adminAuthMiddleware requires "Authorization: Bearer <ADMIN_TOKEN>"
+func adminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			abortWithError(c, http.StatusUnauthorized, CodeUnauthorized, "unauthorized")
+			return
+		}
+		c.Next()
+	}
+}
+
+// DeleteSession handles DELETE /v1/admin/sessions/:session_id
+//
+// A signed deletion manifest (session id, event count, session Merkle root,
+// timestamp) is stored before any rows are removed, so the deletion itself is
+// attestable. Deletion spans several tables and cannot be rolled back; if it
+// fails part-way the manifest is marked failed and the error logged, and the
+// request can be retried.
+func (h *Handlers) DeleteSession(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("admin_delete_session").Observe(time.Since(start).Seconds())
+	}()
+
+	ctx := c.Request.Context()
+	sessionID := c.Param("session_id")
+
+	keys, err := h.deleter.GetSessionEventKeys(ctx, sessionID)
+	if err != nil {
+		respondError(c, "admin_delete_session", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	}
+
+	if len(keys) == 0 {
+		respondError(c, "admin_delete_session", http.StatusNotFound, CodeNotFound, "no events found for session")
+		return
+	}
+
+	hashes := make([]string, len(keys))
+	for i, key := range keys {
+		hashes[i] = key.EventHash
+	}
+
+	tree, err := merkle.Build(ctx, hashes, h.config.MerkleScheme)
+	if err != nil {
+		respondError(c, "admin_delete_session", http.StatusInternalServerError, CodeInternal, "failed to build session Merkle root")
+		return
+	}
+
+	// Scylla stores timestamps at millisecond precision; sign what is stored
+	deletedAt := time.Now().UTC().Truncate(time.Millisecond)
+	idHash := sha256.Sum256([]byte(sessionID + deletedAt.String()))
+
+	manifest := &DeletionManifest{
+		ManifestID:  "dm-" + hex.EncodeToString(idHash[:8]),
+		SessionID:   sessionID,
+		AgentID:     keys[0].AgentID,
+		EventCount:  len(keys),
+		SessionRoot: tree.Root(),
+		DeletedAt:   deletedAt,
+		Status:      manifestPending,
+	}
+	if err := signDeletionManifest(manifest, h.config.AdminSigningKey); err != nil {
+		respondError(c, "admin_delete_session", http.StatusInternalServerError, CodeInternal, "failed to sign deletion manifest")
+		return
+	}
+
+	if err := h.deleter.StoreDeletionManifest(ctx, manifest); err != nil {
+		respondError(c, "admin_delete_session", http.StatusInternalServerError, CodeStorageError, "failed to store deletion manifest")
+		return
+	}
+
+	if err := h.deleter.DeleteSessionEvents(ctx, sessionID, keys); err != nil {
+		log.Error().Err(err).
+			Str("session_id", sessionID).
+			Str("manifest_id", manifest.ManifestID).
+			Msg("Session deletion failed part-way; rows may be partially deleted")
+
+		manifest.Status = manifestFailed
+		if err := h.deleter.StoreDeletionManifest(ctx, manifest); err != nil {
+			log.Error().Err(err).Str("manifest_id", manifest.ManifestID).Msg("Failed to mark deletion manifest failed")
+		}
+
+		respondErrorDetails(c, "admin_delete_session", http.StatusInternalServerError, CodeStorageError,
+			"failed to delete session", gin.H{"manifest_id": manifest.ManifestID})
+		return
+	}
+
+	manifest.Status = manifestCompleted
+	if err := h.deleter.StoreDeletionManifest(ctx, manifest); err != nil {
+		log.Error().Err(err).Str("manifest_id", manifest.ManifestID).Msg("Failed to mark deletion manifest completed")
+	}
+
+	log.Info().
+		Str("session_id", sessionID).
+		Str("manifest_id", manifest.ManifestID).
+		Int("event_count", manifest.EventCount).
+		Msg("Session deleted")
+
+	apiRequestsTotal.WithLabelValues("admin_delete_session", "200").Inc()
+	c.JSON(http.StatusOK, manifest)
+}
+
+// deletionManifestPayload returns the canonical bytes a manifest is signed over.
+// Status is excluded: it changes as the deletion progresses.
+func deletionManifestPayload(m *DeletionManifest) ([]byte, error) {
+	return canonical.Marshal(map[string]interface{}{
+		"manifest_id":  m.ManifestID,
+		"session_id":   m.SessionID,
+		"agent_id":     m.AgentID,
+		"event_count":  m.EventCount,
+		"session_root": m.SessionRoot,
+		"deleted_at":   m.DeletedAt.Format(time.RFC3339Nano),
+	})
+}
+
+func signDeletionManifest(m *DeletionManifest, key ed25519.PrivateKey) error {
+	payload, err := deletionManifestPayload(m)
+	if err != nil {
+		return err
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	m.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// RawColumnResponse is a stored column's exact value. Blobs are hex-encoded
+// (encoding "hex"), since they are the bytes signature checks actually ran
+// against; nulls are JSON null.
+type RawColumnResponse struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Value    interface{} `json:"value"`
+	Encoding string      `json:"encoding,omitempty"`
+}
+
+// GetRawEvent handles GET /v1/admin/events/:facto_id/raw, returning every
+// stored column of the event for debugging failed verifications
+func (h *Handlers) GetRawEvent(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("admin_raw_event").Observe(time.Since(start).Seconds())
+	}()
+
+	factoID := c.Param("facto_id")
+
+	columns, err := h.storage.GetRawEvent(c.Request.Context(), factoID)
+	if err != nil {
+		respondError(c, "admin_raw_event", http.StatusInternalServerError, CodeStorageError, "failed to fetch event")
+		return
+	}
+
+	if columns == nil {
+		respondError(c, "admin_raw_event", http.StatusNotFound, CodeNotFound, "event not found")
+		return
+	}
+
+	response := make([]RawColumnResponse, len(columns))
+	for i, col := range columns {
+		response[i] = rawColumnResponse(col)
+	}
+
+	apiRequestsTotal.WithLabelValues("admin_raw_event", "200").Inc()
+	c.JSON(http.StatusOK, gin.H{
+		"facto_id": factoID,
+		"table":    "events_by_facto_id",
+		"columns":  response,
+	})
+}
+
+func rawColumnResponse(col RawColumn) RawColumnResponse {
+	resp := RawColumnResponse{Name: col.Name, Type: col.Type}
+
+	switch v := col.Value.(type) {
+	case []byte:
+		if v != nil {
+			resp.Value = hex.EncodeToString(v)
+			resp.Encoding = "hex"
+		}
+	case time.Time:
+		if !v.IsZero() {
+			resp.Value = v.UTC().Format(time.RFC3339Nano)
+		}
+	default:
+		resp.Value = v
+	}
+	return resp
+}
+
+// Audit scan page sizes
+const (
+	defaultAuditPageSize = 500
+	maxAuditPageSize     = 5000
+)
+
+// AuditLine is one NDJSON line of an audit scan. Type is "failure" for an
+// event that failed verification, "checkpoint" after each page, "done" when
+// the table is exhausted, or "error" if the scan stopped early. Restarting
+// with the last checkpoint's cursor resumes the scan.
+type AuditLine struct {
+	Type           string `json:"type"`
+	FactoID        string `json:"facto_id,omitempty"`
+	AgentID        string `json:"agent_id,omitempty"`
+	SessionID      string `json:"session_id,omitempty"`
+	HashValid      *bool  `json:"hash_valid,omitempty"`
+	SignatureValid *bool  `json:"signature_valid,omitempty"`
+	DataCorrupt    bool   `json:"data_corrupt,omitempty"`
+	Cursor         string `json:"cursor,omitempty"`
+	Scanned        int64  `json:"scanned,omitempty"`
+	Failures       int64  `json:"failures,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// AuditScan handles GET /v1/admin/audit/scan?cursor=&page_size=
+//
+// Walks the entire events table in token order, verifying the hash and
+// signature of every event, and streams failures as NDJSON. A checkpoint line
+// carrying the paging cursor follows each page, so a multi-hour audit that is
+// interrupted can be restarted from the last checkpoint. Scanned and failure
+// counts in checkpoints cover this request only.
+func (h *Handlers) AuditScan(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("admin_audit_scan").Observe(time.Since(start).Seconds())
+	}()
+
+	pageSize := defaultAuditPageSize
+	if v := c.Query("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditPageSize {
+			respondError(c, "admin_audit_scan", http.StatusBadRequest, CodeInvalidParameter, "page_size must be between 1 and "+strconv.Itoa(maxAuditPageSize))
+			return
+		}
+		pageSize = n
+	}
+
+	ctx := c.Request.Context()
+	cursor := c.Query("cursor")
+
+	// Fetch the first page before committing to a streamed 200, so a bad
+	// cursor or an unreachable store is still reported with a status code
+	events, next, err := h.scanAllEvents(ctx, pageSize, cursor)
+	if err == ErrInvalidCursor {
+		respondError(c, "admin_audit_scan", http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+		return
+	}
+	if err != nil {
+		respondError(c, "admin_audit_scan", http.StatusInternalServerError, CodeStorageError, "failed to scan events")
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("admin_audit_scan", "200").Inc()
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	var scanned, failures int64
+
+	for {
+		for i := range events {
+			event := &events[i]
+			scanned++
+
+			hashValid := verifyHash(event)
+			signatureValid := verifySignature(event)
+			if hashValid && signatureValid && !event.DataCorrupt {
+				continue
+			}
+
+			failures++
+			auditFailures.Inc()
+			enc.Encode(AuditLine{
+				Type:           "failure",
+				FactoID:        event.FactoID,
+				AgentID:        event.AgentID,
+				SessionID:      event.SessionID,
+				HashValid:      &hashValid,
+				SignatureValid: &signatureValid,
+				DataCorrupt:    event.DataCorrupt,
+			})
+		}
+		auditEventsScanned.Add(float64(len(events)))
+
+		if next == nil {
+			break
+		}
+		cursor = *next
+
+		enc.Encode(AuditLine{Type: "checkpoint", Cursor: cursor, Scanned: scanned, Failures: failures})
+		c.Writer.Flush()
+
+		events, next, err = h.scanAllEvents(ctx, pageSize, cursor)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error().Err(err).Str("cursor", cursor).Msg("Audit scan stopped early")
+				enc.Encode(AuditLine{Type: "error", Error: "failed to scan events", Cursor: cursor, Scanned: scanned, Failures: failures})
+			}
+			return
+		}
+	}
+
+	enc.Encode(AuditLine{Type: "done", Scanned: scanned, Failures: failures})
+
+	log.Info().
+		Int64("scanned", scanned).
+		Int64("failures", failures).
+		Dur("duration", time.Since(start)).
+		Msg("Audit scan completed")
+}
+
+// Index rebuild paging
+const (
+	rebuildPageSize     = 500
+	defaultRebuildPages = 10
+	maxRebuildPages     = 100
+)
+
+// RebuildIndexResponse reports one run of an index rebuild. While NextCursor
+// is set the rebuild is incomplete; repeat the request with it as cursor.
+type RebuildIndexResponse struct {
+	Table       string  `json:"table"`
+	RowsWritten int     `json:"rows_written"`
+	Pages       int     `json:"pages"`
+	NextCursor  *string `json:"next_cursor"`
+	Done        bool    `json:"done"`
+}
+
+// RebuildIndex handles POST /v1/admin/rebuild-index?table=&cursor=&pages=
+//
+// Backfills a lookup table (events_by_session or events_by_facto_id) from the
+// authoritative events table, or merkle_roots_by_hash from merkle_roots. Each
+// request copies up to pages pages of source rows and returns a cursor to
+// continue from, so a rebuild of any size is driven by repeating the request
+// until done. Rows are upserted, so pages can
+// be safely replayed after a failure. Canonical form snapshots are not in the
+// events table and are not restored.
+func (h *Handlers) RebuildIndex(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("admin_rebuild_index").Observe(time.Since(start).Seconds())
+	}()
+
+	table := c.Query("table")
+	if _, ok := rebuildColumns[table]; !ok {
+		respondError(c, "admin_rebuild_index", http.StatusBadRequest, CodeInvalidParameter, "table must be events_by_session, events_by_facto_id or merkle_roots_by_hash")
+		return
+	}
+
+	pages := defaultRebuildPages
+	if v := c.Query("pages"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxRebuildPages {
+			respondError(c, "admin_rebuild_index", http.StatusBadRequest, CodeInvalidParameter, "pages must be between 1 and "+strconv.Itoa(maxRebuildPages))
+			return
+		}
+		pages = n
+	}
+
+	ctx := c.Request.Context()
+	response := RebuildIndexResponse{Table: table}
+	cursor := c.Query("cursor")
+
+	for response.Pages < pages {
+		written, next, err := h.rebuildIndexPage(ctx, table, rebuildPageSize, cursor)
+		response.RowsWritten += written
+		if err == ErrInvalidCursor {
+			respondError(c, "admin_rebuild_index", http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Str("table", table).Str("cursor", cursor).Msg("Index rebuild failed")
+			// The failed page is retried by resuming from the cursor it started at
+			respondErrorDetails(c, "admin_rebuild_index", http.StatusInternalServerError, CodeStorageError,
+				"failed to rebuild index", gin.H{"cursor": cursor})
+			return
+		}
+
+		response.Pages++
+		response.NextCursor = next
+		if next == nil {
+			response.Done = true
+			break
+		}
+		cursor = *next
+	}
+
+	log.Info().
+		Str("table", table).
+		Int("rows_written", response.RowsWritten).
+		Int("pages", response.Pages).
+		Bool("done", response.Done).
+		Msg("Index rebuild progressed")
+
+	apiRequestsTotal.WithLabelValues("admin_rebuild_index", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/gin-gonic/gin"
+)
+
+// memSessionStore keeps session event keys and deletion manifests in memory
+type memSessionStore struct {
+	sessions  map[string][]SessionEventKey
+	deleteErr error
+
+	// manifests holds a copy of every manifest write, with whether the
+	// session's rows still existed when it was written
+	manifests   []DeletionManifest
+	rowsPresent []bool
+}
+
+func (m *memSessionStore) GetSessionEventKeys(ctx context.Context, sessionID string) ([]SessionEventKey, error) {
+	return m.sessions[sessionID], nil
+}
+
+func (m *memSessionStore) StoreDeletionManifest(ctx context.Context, manifest *DeletionManifest) error {
+	m.manifests = append(m.manifests, *manifest)
+	m.rowsPresent = append(m.rowsPresent, len(m.sessions[manifest.SessionID]) > 0)
+	return nil
+}
+
+func (m *memSessionStore) DeleteSessionEvents(ctx context.Context, sessionID string, keys []SessionEventKey) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+func newDeleteTest(t *testing.T, store *memSessionStore) (ed25519.PublicKey, func(r *gin.Engine)) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandlers(nil, &Config{AdminSigningKey: priv, MerkleScheme: merkle.SchemeRFC6962})
+	h.deleter = store
+	return pub, func(r *gin.Engine) { r.DELETE("/v1/admin/sessions/:session_id", h.DeleteSession) }
+}
+
+func TestDeleteSessionWritesManifest(t *testing.T) {
+	completedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &memSessionStore{sessions: map[string][]SessionEventKey{
+		"session-1": {
+			{AgentID: "agent", FactoID: "ft-1", EventHash: "aa", CompletedAt: completedAt},
+			{AgentID: "agent", FactoID: "ft-2", EventHash: "bb", CompletedAt: completedAt.Add(time.Second)},
+		},
+	}}
+	pub, register := newDeleteTest(t, store)
+
+	rec := serveTest(t, register, http.MethodDelete, "/v1/admin/sessions/session-1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := store.sessions["session-1"]; ok {
+		t.Error("session rows not deleted")
+	}
+
+	// Written pending before any rows were removed, then marked completed
+	if len(store.manifests) != 2 || !store.rowsPresent[0] {
+		t.Fatalf("manifest writes %+v, rows present %v", store.manifests, store.rowsPresent)
+	}
+	if store.manifests[0].Status != manifestPending || store.manifests[1].Status != manifestCompleted {
+		t.Errorf("manifest statuses %s then %s", store.manifests[0].Status, store.manifests[1].Status)
+	}
+
+	var manifest DeletionManifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := merkle.Build(context.Background(), []string{"aa", "bb"}, merkle.SchemeRFC6962)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.SessionID != "session-1" || manifest.AgentID != "agent" || manifest.EventCount != 2 || manifest.SessionRoot != tree.Root() {
+		t.Errorf("got manifest %+v", manifest)
+	}
+
+	payload, err := deletionManifestPayload(&manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(manifest.Signature)
+	if !ed25519.Verify(pub, payload, sig) || manifest.PublicKey != base64.StdEncoding.EncodeToString(pub) {
+		t.Error("manifest signature doesn't verify")
+	}
+}
+
+func TestDeleteSessionMarksManifestFailed(t *testing.T) {
+	store := &memSessionStore{
+		sessions: map[string][]SessionEventKey{
+			"session-1": {{AgentID: "agent", FactoID: "ft-1", EventHash: "aa", CompletedAt: time.Unix(0, 0)}},
+		},
+		deleteErr: errors.New("write timeout"),
+	}
+	_, register := newDeleteTest(t, store)
+
+	rec := serveTest(t, register, http.MethodDelete, "/v1/admin/sessions/session-1", nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if len(store.manifests) != 2 || store.manifests[1].Status != manifestFailed {
+		t.Errorf("manifest writes %+v", store.manifests)
+	}
+
+	// A session with no events has nothing to attest
+	rec = serveTest(t, register, http.MethodDelete, "/v1/admin/sessions/session-2", nil)
+	if rec.Code != http.StatusNotFound || len(store.manifests) != 2 {
+		t.Errorf("status %d with %d manifest writes", rec.Code, len(store.manifests))
+	}
+}
+
+func TestRawColumnResponseKeepsBytes(t *testing.T) {
+	signature := []byte{0x00, 0xff, 0x10, 0x80} // Not valid UTF-8
+	completedAt := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+
+	cases := []struct {
+		col          RawColumn
+		wantValue    interface{}
+		wantEncoding string
+	}{
+		{RawColumn{Name: "signature", Type: "blob", Value: signature}, "00ff1080", "hex"},
+		{RawColumn{Name: "input_data", Type: "blob", Value: []byte(nil)}, nil, ""},
+		{RawColumn{Name: "completed_at", Type: "timestamp", Value: completedAt}, "2026-03-01T12:00:00.123456789Z", ""},
+		{RawColumn{Name: "received_at", Type: "timestamp", Value: time.Time{}}, nil, ""},
+		{RawColumn{Name: "agent_id", Type: "text", Value: "agent"}, "agent", ""},
+	}
+	for _, tc := range cases {
+		resp := rawColumnResponse(tc.col)
+		if resp.Name != tc.col.Name || resp.Type != tc.col.Type || resp.Value != tc.wantValue || resp.Encoding != tc.wantEncoding {
+			t.Errorf("%s: got %+v, want value %v encoding %q", tc.col.Name, resp, tc.wantValue, tc.wantEncoding)
+		}
+	}
+}
+
+func TestAdminAuthRequiresToken(t *testing.T) {
+	register := func(r *gin.Engine) {
+		r.GET("/v1/admin/events/:facto_id/raw", adminAuthMiddleware("secret"), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+	}
+	if rec := serveTest(t, register, http.MethodGet, "/v1/admin/events/ft-1/raw", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d", rec.Code)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	register(router)
+	req := httptest.NewRequest(http.MethodGet, "/v1/admin/events/ft-1/raw", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("admin token: status %d", rec.Code)
+	}
+}
+
+// seededScan pages through events the way ScanAllEvents pages through the
+// events table, with the next index as cursor
+func seededScan(events []EventResponse) func(ctx context.Context, pageSize int, cursor string) ([]EventResponse, *string, error) {
+	return func(ctx context.Context, pageSize int, cursor string) ([]EventResponse, *string, error) {
+		start := 0
+		if cursor != "" {
+			var err error
+			if start, err = strconv.Atoi(cursor); err != nil {
+				return nil, nil, ErrInvalidCursor
+			}
+		}
+		end := min(start+pageSize, len(events))
+		var next *string
+		if end < len(events) {
+			cursor := strconv.Itoa(end)
+			next = &cursor
+		}
+		return events[start:end], next, nil
+	}
+}
+
+// readAuditLines runs an audit scan, returning its NDJSON lines
+func readAuditLines(t *testing.T, h *Handlers, target string) []AuditLine {
+	t.Helper()
+	register := func(r *gin.Engine) { r.GET("/v1/admin/audit/scan", h.AuditScan) }
+	rec := serveTest(t, register, http.MethodGet, target, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var lines []AuditLine
+	for _, raw := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+		var line AuditLine
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("line %q: %v", raw, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestAuditScanReportsFailures(t *testing.T) {
+	// The golden event, signed with a fresh key
+	var valid EventResponse
+	if err := json.Unmarshal(readGolden(t, "canonical_event.json"), &valid); err != nil {
+		t.Fatal(err)
+	}
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, _, err := canonicalForm(&valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid.Proof.PublicKey = base64.StdEncoding.EncodeToString(pub)
+	valid.Proof.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(form)))
+
+	events := make([]EventResponse, 5)
+	for i := range events {
+		events[i] = valid
+	}
+	events[1].OutputData = map[string]interface{}{"tampered": true}
+	events[1].FactoID = "ft-tampered"
+	events[3].Proof.Signature = base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+
+	h := NewHandlers(nil, &Config{})
+	h.scanAllEvents = seededScan(events)
+
+	lines := readAuditLines(t, h, "/v1/admin/audit/scan?page_size=2")
+	var failures, checkpoints []AuditLine
+	for _, line := range lines {
+		switch line.Type {
+		case "failure":
+			failures = append(failures, line)
+		case "checkpoint":
+			checkpoints = append(checkpoints, line)
+		}
+	}
+	last := lines[len(lines)-1]
+	if last.Type != "done" || last.Scanned != 5 || last.Failures != 2 {
+		t.Errorf("scan ended with %+v", last)
+	}
+	if len(failures) != 2 || failures[0].FactoID != "ft-tampered" || *failures[0].HashValid ||
+		failures[1].FactoID != valid.FactoID || !*failures[1].HashValid || *failures[1].SignatureValid {
+		t.Errorf("got failures %+v", failures)
+	}
+	if len(checkpoints) != 2 || checkpoints[0].Cursor != "2" || checkpoints[0].Scanned != 2 {
+		t.Fatalf("got checkpoints %+v", checkpoints)
+	}
+
+	// Restarting from the first checkpoint scans the rest of the table
+	lines = readAuditLines(t, h, "/v1/admin/audit/scan?page_size=2&cursor="+checkpoints[0].Cursor)
+	if last := lines[len(lines)-1]; last.Type != "done" || last.Scanned != 3 || last.Failures != 1 {
+		t.Errorf("resumed scan ended with %+v", last)
+	}
+}
+
+// memLookupTable rebuilds a lookup table from source rows the way
+// RebuildIndexPage does, upserting by facto_id with the next index as cursor
+type memLookupTable struct {
+	source []string
+	rows   map[string]bool
+}
+
+func (m *memLookupTable) rebuildPage(ctx context.Context, table string, pageSize int, cursor string) (int, *string, error) {
+	start := 0
+	if cursor != "" {
+		var err error
+		if start, err = strconv.Atoi(cursor); err != nil {
+			return 0, nil, ErrInvalidCursor
+		}
+	}
+	end := min(start+pageSize, len(m.source))
+	for _, factoID := range m.source[start:end] {
+		m.rows[factoID] = true
+	}
+	var next *string
+	if end < len(m.source) {
+		cursor := strconv.Itoa(end)
+		next = &cursor
+	}
+	return end - start, next, nil
+}
+
+func TestRebuildIndexRebuildsWipedTable(t *testing.T) {
+	table := &memLookupTable{rows: map[string]bool{}}
+	for i := 0; i < 3*rebuildPageSize+10; i++ {
+		table.source = append(table.source, fmt.Sprintf("ft-%d", i))
+	}
+	h := NewHandlers(nil, &Config{})
+	h.rebuildIndexPage = table.rebuildPage
+	register := func(r *gin.Engine) { r.POST("/v1/admin/rebuild-index", h.RebuildIndex) }
+
+	rebuild := func(cursor string) RebuildIndexResponse {
+		t.Helper()
+		rec := serveTest(t, register, http.MethodPost, "/v1/admin/rebuild-index?table=events_by_session&pages=2&cursor="+cursor, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+		var resp RebuildIndexResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Two pages per request, so the rebuild is resumed once
+	first := rebuild("")
+	if first.Done || first.NextCursor == nil || first.RowsWritten != 2*rebuildPageSize {
+		t.Fatalf("first request: %+v", first)
+	}
+	second := rebuild(*first.NextCursor)
+	if !second.Done || second.NextCursor != nil || second.RowsWritten != rebuildPageSize+10 {
+		t.Fatalf("second request: %+v", second)
+	}
+	if len(table.rows) != len(table.source) {
+		t.Errorf("rebuilt %d of %d rows", len(table.rows), len(table.source))
+	}
+
+	// Replaying a page upserts the same rows
+	rebuild(*first.NextCursor)
+	if len(table.rows) != len(table.source) {
+		t.Errorf("replay left %d rows, want %d", len(table.rows), len(table.source))
+	}
+
+	rec := serveTest(t, register, http.MethodPost, "/v1/admin/rebuild-index?table=events", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("source table accepted as a rebuild target: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyContextKey is the gin context key holding the caller's *APIKey
+const apiKeyContextKey = "api_key"
+
+// apiKeyWildcard grants a key every agent_id
+const apiKeyWildcard = "*"
+
+// APIKey is a caller credential scoped to a set of agent_ids
+type APIKey struct {
+	Key       string
+	AllAgents bool
+	Agents    map[string]bool
+}
+
+// Allows reports whether the key may read the agent's events
+func (k *APIKey) Allows(agentID string) bool {
+	return k.AllAgents || k.Agents[agentID]
+}
+
+// parseAPIKeys parses API_KEYS: comma-separated key=agents entries, where
+// agents is a |-separated list of agent_ids or * for every agent
+func parseAPIKeys(value string) ([]*APIKey, error) {
+	var keys []*APIKey
+	for _, entry := range splitList(value) {
+		key, agents, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("API_KEYS entry %q is not key=agents", entry)
+		}
+
+		apiKey := &APIKey{Key: key, Agents: make(map[string]bool)}
+		for _, agent := range strings.Split(agents, "|") {
+			switch agent = strings.TrimSpace(agent); agent {
+			case "":
+			case apiKeyWildcard:
+				apiKey.AllAgents = true
+			default:
+				apiKey.Agents[agent] = true
+			}
+		}
+		if !apiKey.AllAgents && len(apiKey.Agents) == 0 {
+			return nil, fmt.Errorf("API_KEYS entry for key %q allows no agents", key)
+		}
+		keys = append(keys, apiKey)
+	}
+	return keys, nil
+}
+
+// apiKeyMiddleware requires an "X-API-Key" header naming a configured key and
+// exposes it to handlers. Requests naming an agent_id (path or query) the key
+// doesn't allow are rejected; handlers check events looked up by facto_id or
+// session_id with rejectForbidden.
+func apiKeyMiddleware(keys []*APIKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := []byte(c.GetHeader("X-API-Key"))
+
+		var apiKey *APIKey
+		for _, k := range keys {
+			if subtle.ConstantTimeCompare(provided, []byte(k.Key)) == 1 {
+				apiKey = k
+			}
+		}
+		if apiKey == nil {
+			abortWithError(c, http.StatusUnauthorized, CodeUnauthorized, "valid X-API-Key required")
+			return
+		}
+		c.Set(apiKeyContextKey, apiKey)
+
+		agentID := firstNonEmpty(c.Param("agent_id"), c.Query("agent_id"))
+		if agentID != "" && !apiKey.Allows(agentID) {
+			abortWithError(c, http.StatusForbidden, CodeForbidden, "API key not authorized for agent")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// requestAPIKey returns the caller's API key, or nil when API keys are off
+func requestAPIKey(c *gin.Context) *APIKey {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		return v.(*APIKey)
+	}
+	return nil
+}
+
+// rejectForbidden fails the request with 403 when the caller's API key or,
+// with MTLS_AGENT_SCOPE, client certificate does not allow every agent_id
+// given, so events found by facto_id or session_id can't leak across agents
+func (h *Handlers) rejectForbidden(c *gin.Context, endpoint string, agentIDs ...string) bool {
+	for _, agentID := range agentIDs {
+		if msg := h.agentForbidden(c, agentID); msg != "" {
+			respondError(c, endpoint, http.StatusForbidden, CodeForbidden, msg)
+			return true
+		}
+	}
+	return false
+}
+
+// agentForbidden returns why the caller may not read the agent's events, or
+// "" when it may
+func (h *Handlers) agentForbidden(c *gin.Context, agentID string) string {
+	if apiKey := requestAPIKey(c); apiKey != nil && !apiKey.Allows(agentID) {
+		return "API key not authorized for agent"
+	}
+	if h.config.MTLSAgentScope {
+		if identity := clientIdentity(c); identity != nil && !identity.Allows(agentID) {
+			return "client certificate not authorized for agent"
+		}
+	}
+	return ""
+}
+
+// eventAgentIDs returns the agent_id of each event
+func eventAgentIDs(events []EventResponse) []string {
+	ids := make([]string, len(events))
+	for i, e := range events {
+		ids[i] = e.AgentID
+	}
+	return ids
+}
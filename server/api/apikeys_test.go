@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fixture events owned by agent-a and agent-c, one session each
+const (
+	scopedEventA = "ft-00000000-0000-4000-8000-00000000000a"
+	scopedEventC = "ft-00000000-0000-4000-8000-00000000000c"
+)
+
+// scopedHandlers returns handlers over an in-memory store of scopedEventA
+// (session-a) and scopedEventC (session-c)
+func scopedHandlers(config *Config) *Handlers {
+	events := map[string]EventResponse{
+		scopedEventA: {FactoID: scopedEventA, AgentID: "agent-a", SessionID: "session-a"},
+		scopedEventC: {FactoID: scopedEventC, AgentID: "agent-c", SessionID: "session-c"},
+	}
+	h := &Handlers{config: config}
+	h.getEvents = func(ctx context.Context, agentID string, start, end time.Time, limit int, cursor string, concurrency int, filter EventFilter, order SortOrder) ([]EventResponse, *string, error) {
+		var page []EventResponse
+		for _, e := range events {
+			if e.AgentID == agentID {
+				page = append(page, e)
+			}
+		}
+		return page, nil, nil
+	}
+	h.getEventByFactoID = func(ctx context.Context, factoID string) (*EventResponse, error) {
+		if e, ok := events[factoID]; ok {
+			return &e, nil
+		}
+		return nil, nil
+	}
+	h.getSessionEvents = func(ctx context.Context, sessionID string, limit int, cursor string, order SortOrder) ([]EventResponse, *string, error) {
+		var page []EventResponse
+		for _, e := range events {
+			if e.SessionID == sessionID {
+				page = append(page, e)
+			}
+		}
+		return page, nil, nil
+	}
+	h.getAgents = func(ctx context.Context, limit int, cursor string) ([]AgentSummary, *string, error) {
+		return []AgentSummary{{AgentID: "agent-a"}, {AgentID: "agent-b"}, {AgentID: "agent-c"}}, nil, nil
+	}
+	return h
+}
+
+// scopedRouter serves the agent-scoped handlers behind the given middleware,
+// as main.go registers them
+func scopedRouter(h *Handlers, middleware ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/v1", middleware...)
+	v1.GET("/events", h.GetEvents)
+	v1.GET("/events/:facto_id", h.GetEventByFactoID)
+	v1.GET("/agents", h.ListAgents)
+	v1.GET("/sessions/:session_id/events", h.GetSessionEvents)
+	return router
+}
+
+// listedAgents returns the agent_ids of a ListAgents response
+func listedAgents(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp AgentsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(resp.Agents))
+	for i, a := range resp.Agents {
+		ids[i] = a.AgentID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestAPIKeyAgentScopes(t *testing.T) {
+	keys, err := parseAPIKeys("reader=agent-a|agent-b, admin=*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := scopedRouter(scopedHandlers(&Config{}), apiKeyMiddleware(keys))
+	const window = "&start=2026-03-01T00:00:00Z&end=2026-03-01T01:00:00Z"
+
+	for _, tc := range []struct {
+		name   string
+		key    string
+		target string
+		want   int
+	}{
+		{"allowed agent by query", "reader", "/v1/events?agent_id=agent-a" + window, http.StatusOK},
+		{"allowed agent by facto_id", "reader", "/v1/events/" + scopedEventA, http.StatusOK},
+		{"allowed agent by session", "reader", "/v1/sessions/session-a/events", http.StatusOK},
+		{"forbidden agent by query", "reader", "/v1/events?agent_id=agent-c" + window, http.StatusForbidden},
+		{"forbidden agent by facto_id", "reader", "/v1/events/" + scopedEventC, http.StatusForbidden},
+		{"forbidden agent by session", "reader", "/v1/sessions/session-c/events", http.StatusForbidden},
+		{"admin wildcard by query", "admin", "/v1/events?agent_id=agent-c" + window, http.StatusOK},
+		{"admin wildcard by facto_id", "admin", "/v1/events/" + scopedEventC, http.StatusOK},
+		{"admin wildcard by session", "admin", "/v1/sessions/session-c/events", http.StatusOK},
+		{"unknown key", "other", "/v1/events?agent_id=agent-a" + window, http.StatusUnauthorized},
+		{"no key", "", "/v1/events?agent_id=agent-a" + window, http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIKeyListAgentsFiltered(t *testing.T) {
+	keys, err := parseAPIKeys("reader=agent-a|agent-b, admin=*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := scopedRouter(scopedHandlers(&Config{}), apiKeyMiddleware(keys))
+	for key, want := range map[string]string{"reader": "agent-a,agent-b", "admin": "agent-a,agent-b,agent-c"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/agents", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if got := listedAgents(t, rec); rec.Code != http.StatusOK || got != want {
+			t.Errorf("%s: status %d, agents %q, want %q", key, rec.Code, got, want)
+		}
+	}
+}
+
+func TestParseAPIKeysRejectsEmptyScope(t *testing.T) {
+	for _, value := range []string{"reader=", "reader", "=agent-a"} {
+		if _, err := parseAPIKeys(value); err == nil {
+			t.Errorf("parseAPIKeys(%q) accepted", value)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+)
+
+// The processor appends every batch root to the batch log, an append-only
+// Merkle tree hashed as in RFC 6962 (see server/processor/merklelog.go).
+// Consistency proofs show that a later log root extends an earlier one:
+// every batch root committed by the earlier log is still in the later one,
+// in the same place.
+
+// ErrInvalidConsistencyProof is returned by VerifyConsistency for a proof
+// that is malformed for the two tree sizes
+var ErrInvalidConsistencyProof = errors.New("invalid consistency proof")
+
+// logNodeReader reads a stored perfect subtree of the batch log
+type logNodeReader func(ctx context.Context, level int, index int64) (string, error)
+
+// largestPowerOfTwoBelow returns the largest power of two smaller than n (n > 1)
+func largestPowerOfTwoBelow(n int64) int64 {
+	return 1 << (bits.Len64(uint64(n-1)) - 1)
+}
+
+// logRangeHash returns the RFC 6962 hash of the n leaves starting at start,
+// built from stored perfect subtrees. start must be a multiple of the
+// largest power of two not above n, as every range in a proof is.
+func logRangeHash(ctx context.Context, read logNodeReader, start, n int64) (string, error) {
+	if n&(n-1) == 0 {
+		level := bits.TrailingZeros64(uint64(n))
+		return read(ctx, level, start>>level)
+	}
+	k := largestPowerOfTwoBelow(n)
+	left, err := logRangeHash(ctx, read, start, k)
+	if err != nil {
+		return "", err
+	}
+	right, err := logRangeHash(ctx, read, start+k, n-k)
+	if err != nil {
+		return "", err
+	}
+	return merkle.SchemeRFC6962.NodeHash(left, right), nil
+}
+
+// consistencyProof returns the RFC 6962 (section 2.1.2) proof that the log
+// of size first is a prefix of the log of size second, 0 < first <= second
+func consistencyProof(ctx context.Context, read logNodeReader, first, second int64) ([]string, error) {
+	if first == second {
+		return []string{}, nil
+	}
+	return logSubproof(ctx, read, first, 0, second, true)
+}
+
+// logSubproof is SUBPROOF(m, D[start:start+n], b) from RFC 6962
+func logSubproof(ctx context.Context, read logNodeReader, m, start, n int64, complete bool) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m == n {
+		if complete {
+			return []string{}, nil
+		}
+		hash, err := logRangeHash(ctx, read, start, n)
+		if err != nil {
+			return nil, err
+		}
+		return []string{hash}, nil
+	}
+
+	k := largestPowerOfTwoBelow(n)
+	if m <= k {
+		proof, err := logSubproof(ctx, read, m, start, k, complete)
+		if err != nil {
+			return nil, err
+		}
+		right, err := logRangeHash(ctx, read, start+k, n-k)
+		if err != nil {
+			return nil, err
+		}
+		return append(proof, right), nil
+	}
+
+	proof, err := logSubproof(ctx, read, m-k, start+k, n-k, false)
+	if err != nil {
+		return nil, err
+	}
+	left, err := logRangeHash(ctx, read, start, k)
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, left), nil
+}
+
+// VerifyConsistency checks an RFC 6962 consistency proof that the log of
+// size first with root firstRoot is a prefix of the log of size second with
+// root secondRoot, following RFC 9162 section 2.1.4.2. A proof that can't
+// apply to the two sizes is an error rather than a mismatch.
+func VerifyConsistency(first, second int64, firstRoot, secondRoot string, proof []string) (bool, error) {
+	if first <= 0 || first > second {
+		return false, ErrInvalidConsistencyProof
+	}
+	if first == second {
+		if len(proof) != 0 {
+			return false, ErrInvalidConsistencyProof
+		}
+		return firstRoot == secondRoot, nil
+	}
+	if len(proof) == 0 {
+		return false, ErrInvalidConsistencyProof
+	}
+
+	// A first tree that is a perfect subtree of the second is its own
+	// starting node
+	if first&(first-1) == 0 {
+		proof = append([]string{firstRoot}, proof...)
+	}
+
+	fn, sn := uint64(first-1), uint64(second-1)
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false, ErrInvalidConsistencyProof
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = merkle.SchemeRFC6962.NodeHash(c, fr)
+			sr = merkle.SchemeRFC6962.NodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = merkle.SchemeRFC6962.NodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return false, ErrInvalidConsistencyProof
+	}
+
+	return fr == firstRoot && sr == secondRoot, nil
+}
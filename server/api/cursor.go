@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrCursorExpired is returned for a correctly signed cursor older than
+// CURSOR_TTL_MS
+var ErrCursorExpired = errors.New("cursor expired")
+
+// cursorVersion is the version of the signed cursor format
+const cursorVersion = 1
+
+// minCursorSecretLength is the shortest CURSOR_SECRET accepted, in bytes
+const minCursorSecretLength = 32
+
+// What a cursor pages through; a cursor is only accepted by the listing kind,
+// agent or session, and order it was issued for
+const (
+	cursorKindEvents  = "events"  // GET /v1/events, scoped to an agent_id
+	cursorKindSession = "session" // GET /v1/sessions/:session_id/events
+	cursorKindDate    = "date"    // GET /v1/agents/:agent_id/events, scoped to an agent_id and date
+)
+
+// cursorToken is the signed payload of a pagination cursor: the position of
+// the last event returned, or the driver page state to resume from, and when
+// the cursor was issued
+type cursorToken struct {
+	Version     int       `json:"v"`
+	Kind        string    `json:"k"`
+	Scope       string    `json:"s"` // agent_id or session_id
+	Order       SortOrder `json:"o"`
+	Date        string    `json:"d,omitempty"` // date partition (events and date only)
+	CompletedAt int64     `json:"t"`           // unix nanos, as in EventResponse
+	FactoID     string    `json:"f"`
+	PageState   []byte    `json:"p,omitempty"` // driver page state (date only)
+	IssuedAt    int64     `json:"i"`           // unix seconds
+}
+
+// CursorSigner issues and checks pagination cursors. A cursor is
+// base64url(JSON token) "." base64url(HMAC-SHA256(secret, first part)), so a
+// client can't forge or alter one to read from an arbitrary position, and a
+// cursor stops working ttl after it was issued.
+//
+// With allowLegacy, cursors in the unsigned format used before are still
+// accepted for one release, so clients part-way through a listing during the
+// upgrade carry on.
+type CursorSigner struct {
+	secret      []byte
+	ttl         time.Duration
+	allowLegacy bool
+}
+
+// NewCursorSigner creates a signer. An empty secret is replaced by a random
+// one, which makes cursors valid only on this instance until it restarts.
+func NewCursorSigner(secret []byte, ttl time.Duration, allowLegacy bool) (*CursorSigner, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, minCursorSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &CursorSigner{secret: secret, ttl: ttl, allowLegacy: allowLegacy}, nil
+}
+
+// sign returns the cursor for token, stamped with the current time
+func (s *CursorSigner) sign(token cursorToken) string {
+	token.Version = cursorVersion
+	token.IssuedAt = time.Now().Unix()
+	data, _ := json.Marshal(token)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// verify checks cursor's signature, kind, scope, order and age and returns
+// its token
+func (s *CursorSigner) verify(cursor, kind, scope string, order SortOrder) (*cursorToken, error) {
+	payload, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.mac(payload)) {
+		return nil, ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil ||
+		token.Version != cursorVersion || token.Kind != kind || token.Scope != scope ||
+		token.Order != order || token.FactoID == "" && len(token.PageState) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if s.ttl > 0 && time.Since(time.Unix(token.IssuedAt, 0)) > s.ttl {
+		return nil, ErrCursorExpired
+	}
+	return &token, nil
+}
+
+// isLegacy reports whether cursor is in the unsigned format and accepted
+func (s *CursorSigner) isLegacy(cursor string) bool {
+	return s.allowLegacy && !strings.Contains(cursor, ".")
+}
+
+func (s *CursorSigner) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// eventsCursor returns the cursor resuming an agent's GetEvents listing in
+// order after event
+func (s *CursorSigner) eventsCursor(agentID string, order SortOrder, event EventResponse) string {
+	return s.sign(cursorToken{
+		Kind:        cursorKindEvents,
+		Scope:       agentID,
+		Order:       order,
+		Date:        time.Unix(0, event.CompletedAt).UTC().Format("2006-01-02"),
+		CompletedAt: event.CompletedAt,
+		FactoID:     event.FactoID,
+	})
+}
+
+// decodeEventsCursor returns the date partition and token of an agent's
+// GetEvents cursor for order. Legacy cursors were only issued newest first,
+// from before asc became the default, so they need an explicit order=desc.
+func (s *CursorSigner) decodeEventsCursor(cursor, agentID string, order SortOrder) (time.Time, *cursorToken, error) {
+	if s.isLegacy(cursor) {
+		if order != OrderDesc {
+			return time.Time{}, nil, ErrInvalidCursor
+		}
+		return decodeLegacyEventsCursor(cursor)
+	}
+	token, err := s.verify(cursor, cursorKindEvents, agentID, order)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	date, err := time.Parse("2006-01-02", token.Date)
+	if err != nil {
+		return time.Time{}, nil, ErrInvalidCursor
+	}
+	return date, token, nil
+}
+
+// sessionCursor returns the cursor resuming a GetSessionEvents listing in
+// order after event
+func (s *CursorSigner) sessionCursor(sessionID string, order SortOrder, event EventResponse) string {
+	return s.sign(cursorToken{
+		Kind:        cursorKindSession,
+		Scope:       sessionID,
+		Order:       order,
+		CompletedAt: event.CompletedAt,
+		FactoID:     event.FactoID,
+	})
+}
+
+// decodeSessionCursor returns the token of a GetSessionEvents cursor for
+// order. A legacy session cursor yields nil: it never resumed anything, so
+// the listing starts over as it did before.
+func (s *CursorSigner) decodeSessionCursor(cursor, sessionID string, order SortOrder) (*cursorToken, error) {
+	if s.isLegacy(cursor) {
+		return nil, nil
+	}
+	return s.verify(cursor, cursorKindSession, sessionID, order)
+}
+
+// dateCursor returns the cursor resuming an agent's GetEventsByDate listing
+// of date from the driver page state pageState
+func (s *CursorSigner) dateCursor(agentID string, date time.Time, pageState []byte) string {
+	return s.sign(cursorToken{
+		Kind:      cursorKindDate,
+		Scope:     agentID,
+		Date:      date.Format("2006-01-02"),
+		PageState: pageState,
+	})
+}
+
+// decodeDateCursor returns the driver page state of an agent's
+// GetEventsByDate cursor for date. A legacy cursor is the bare page state.
+func (s *CursorSigner) decodeDateCursor(cursor, agentID string, date time.Time) ([]byte, error) {
+	if s.isLegacy(cursor) {
+		pageState, err := base64.URLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return pageState, nil
+	}
+	token, err := s.verify(cursor, cursorKindDate, agentID, "")
+	if err != nil {
+		return nil, err
+	}
+	if token.Date != date.Format("2006-01-02") || len(token.PageState) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return token.PageState, nil
+}
+
+// legacyEventsCursor is the unsigned GetEvents cursor format, accepted with
+// CURSOR_ALLOW_LEGACY=true
+type legacyEventsCursor struct {
+	Date        string `json:"d"`
+	CompletedAt int64  `json:"t"`
+	FactoID     string `json:"f"`
+}
+
+func decodeLegacyEventsCursor(cursor string) (time.Time, *cursorToken, error) {
+	data, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, nil, ErrInvalidCursor
+	}
+	var legacy legacyEventsCursor
+	if err := json.Unmarshal(data, &legacy); err != nil || legacy.FactoID == "" {
+		return time.Time{}, nil, ErrInvalidCursor
+	}
+	date, err := time.Parse("2006-01-02", legacy.Date)
+	if err != nil {
+		return time.Time{}, nil, ErrInvalidCursor
+	}
+	return date, &cursorToken{Date: legacy.Date, CompletedAt: legacy.CompletedAt, FactoID: legacy.FactoID}, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Legacy cursors were issued when GET /v1/events listed newest first; they
+// resume only with an explicit order=desc now that asc is the default
+func TestLegacyEventsCursorNeedsDesc(t *testing.T) {
+	signer, err := NewCursorSigner(nil, time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := base64.URLEncoding.EncodeToString([]byte(`{"d":"2024-01-01","t":1704067200000000000,"f":"ft-legacy"}`))
+
+	order, _ := parseSortOrder("", OrderAsc)
+	if _, _, err := signer.decodeEventsCursor(legacy, "agent", order); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("a legacy cursor resumed the default order: %v", err)
+	}
+
+	date, token, err := signer.decodeEventsCursor(legacy, "agent", OrderDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if date.Format("2006-01-02") != "2024-01-01" || token.FactoID != "ft-legacy" || token.CompletedAt != 1704067200000000000 {
+		t.Errorf("decoded %v, %+v", date, token)
+	}
+}
+
+func TestEventsCursorScopedToOrder(t *testing.T) {
+	signer, err := NewCursorSigner([]byte("0123456789abcdef0123456789abcdef"), time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := EventResponse{FactoID: "ft-1", CompletedAt: 1704067200000000000}
+	cursor := signer.eventsCursor("agent", OrderAsc, event)
+
+	if _, token, err := signer.decodeEventsCursor(cursor, "agent", OrderAsc); err != nil || token.FactoID != "ft-1" {
+		t.Fatalf("got %+v, %v", token, err)
+	}
+	for _, c := range []struct {
+		agentID string
+		order   SortOrder
+	}{{"agent", OrderDesc}, {"other", OrderAsc}} {
+		if _, _, err := signer.decodeEventsCursor(cursor, c.agentID, c.order); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s/%s accepted the cursor: %v", c.agentID, c.order, err)
+		}
+	}
+}
+
+func TestDateCursorScopedToAgentAndDate(t *testing.T) {
+	signer, err := NewCursorSigner([]byte("0123456789abcdef0123456789abcdef"), time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pageState := []byte{0x01, 0x02, 0x03}
+	cursor := signer.dateCursor("agent", date, pageState)
+
+	got, err := signer.decodeDateCursor(cursor, "agent", date)
+	if err != nil || string(got) != string(pageState) {
+		t.Fatalf("got %x, %v", got, err)
+	}
+	for _, c := range []struct {
+		agentID string
+		date    time.Time
+		cursor  string
+	}{
+		{"other", date, cursor},
+		{"agent", date.AddDate(0, 0, 1), cursor},
+		// The bare page state is only a legacy cursor with CURSOR_ALLOW_LEGACY
+		{"agent", date, base64.URLEncoding.EncodeToString(pageState)},
+		// An events cursor isn't a date cursor
+		{"agent", date, signer.eventsCursor("agent", OrderAsc, EventResponse{FactoID: "ft-1"})},
+	} {
+		if _, err := signer.decodeDateCursor(c.cursor, c.agentID, c.date); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s on %s accepted %s: %v", c.agentID, c.date.Format("2006-01-02"), c.cursor, err)
+		}
+	}
+}
+
+// flipByte returns the base64url part with byte i of its decoded bytes
+// inverted
+func flipByte(t *testing.T, part string, i int) string {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[i] ^= 0xff
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestCursorSignatureAndExpiry(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	signer, err := NewCursorSigner(secret, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := EventResponse{FactoID: "ft-1", CompletedAt: 1704067200000000000}
+	cursor := signer.sessionCursor("session-1", OrderAsc, event)
+
+	token, err := signer.decodeSessionCursor(cursor, "session-1", OrderAsc)
+	if err != nil || token.FactoID != "ft-1" || token.CompletedAt != event.CompletedAt {
+		t.Fatalf("valid cursor: got %+v, %v", token, err)
+	}
+
+	payload, sig, _ := strings.Cut(cursor, ".")
+	for name, tampered := range map[string]string{
+		"payload first byte": flipByte(t, payload, 0) + "." + sig,
+		"payload last byte":  flipByte(t, payload, len(payload)*3/4-1) + "." + sig,
+		"mac first byte":     payload + "." + flipByte(t, sig, 0),
+		"mac last byte":      payload + "." + flipByte(t, sig, len(sig)*3/4-1),
+		"mac truncated":      payload + "." + sig[:len(sig)-2],
+		"mac missing":        payload,
+	} {
+		if _, err := signer.decodeSessionCursor(tampered, "session-1", OrderAsc); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: got %v, want ErrInvalidCursor", name, err)
+		}
+	}
+
+	// Another secret doesn't accept the cursor
+	other, err := NewCursorSigner(nil, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.decodeSessionCursor(cursor, "session-1", OrderAsc); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("other secret: got %v, want ErrInvalidCursor", err)
+	}
+
+	// IssuedAt has second precision, so any wait outlasts a 1ms TTL
+	short, err := NewCursorSigner(secret, time.Millisecond, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiring := short.sessionCursor("session-1", OrderAsc, event)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := short.decodeSessionCursor(expiring, "session-1", OrderAsc); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("expired cursor: got %v, want ErrCursorExpired", err)
+	}
+	// A tampered cursor is invalid, not expired
+	payload, sig, _ = strings.Cut(expiring, ".")
+	if _, err := short.decodeSessionCursor(payload+"."+flipByte(t, sig, 0), "session-1", OrderAsc); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("tampered expired cursor: got %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode is the machine-readable reason in an error response. Codes are
+// stable; messages may be reworded, so clients should branch on the code.
+type ErrorCode string
+
+const (
+	// 400: the request itself is wrong
+	CodeInvalidRequest    ErrorCode = "invalid_request"     // Malformed or missing query/body fields
+	CodeInvalidTimeFormat ErrorCode = "invalid_time_format" // start/end not RFC 3339
+	CodeInvalidDateFormat ErrorCode = "invalid_date_format" // date not YYYY-MM-DD
+	CodeInvalidTimeRange  ErrorCode = "invalid_time_range"  // Range reversed or over the span/partition limits
+	CodeInvalidCursor     ErrorCode = "invalid_cursor"      // Cursor not from this query, or altered
+	CodeCursorExpired     ErrorCode = "cursor_expired"      // Cursor older than CURSOR_TTL_MS
+	CodeInvalidFilter     ErrorCode = "invalid_filter"      // Bad json_path or tag filter
+	CodeInvalidID         ErrorCode = "invalid_id"          // Malformed facto_id, agent_id or session_id
+	CodeInvalidHash       ErrorCode = "invalid_hash"        // A hash that isn't 64 hex characters
+	CodeInvalidFormat     ErrorCode = "invalid_format"      // Unsupported format parameter
+	CodeInvalidParameter  ErrorCode = "invalid_parameter"   // Other parameter out of range
+	CodeFilteringDisabled ErrorCode = "filtering_disabled"  // Needs ALLOW_FILTERING_ENABLED=true
+
+	// 401/403: authentication and API key or certificate scoping
+	CodeUnauthorized ErrorCode = "unauthorized"
+	CodeForbidden    ErrorCode = "forbidden"
+
+	// 404/416
+	CodeNotFound            ErrorCode = "not_found"
+	CodeNotAnchored         ErrorCode = "not_anchored" // Event not yet in a stored Merkle root, or root not yet anchored externally
+	CodeRangeNotSatisfiable ErrorCode = "range_not_satisfiable"
+
+	// 413: the request would read or return too much
+	CodeTooLarge ErrorCode = "too_large"
+
+	// 5xx
+	CodeCorruptData   ErrorCode = "corrupt_data"   // Stored data failed to parse or verify
+	CodeStorageError  ErrorCode = "storage_error"  // ScyllaDB read or write failed
+	CodeUpstreamError ErrorCode = "upstream_error" // Search index request failed
+	CodeTimeout       ErrorCode = "timeout"
+	CodeInternal      ErrorCode = "internal_error"
+)
+
+// APIError is the body of every error response. Error repeats Message for
+// clients written before codes were added.
+type APIError struct {
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	Error   string      `json:"error"`
+}
+
+// newAPIError builds an error body
+func newAPIError(code ErrorCode, message string, details interface{}) APIError {
+	return APIError{Code: code, Message: message, Details: details, Error: message}
+}
+
+// respondError counts a failed request under endpoint and writes the error
+// body with status
+func respondError(c *gin.Context, endpoint string, status int, code ErrorCode, message string) {
+	respondErrorDetails(c, endpoint, status, code, message, nil)
+}
+
+// respondErrorDetails is respondError with extra, code-specific details
+func respondErrorDetails(c *gin.Context, endpoint string, status int, code ErrorCode, message string, details interface{}) {
+	apiRequestsTotal.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+	c.JSON(status, newAPIError(code, message, details))
+}
+
+// abortWithError stops the middleware chain with an error body. Middlewares
+// that count their rejections do so themselves.
+func abortWithError(c *gin.Context, status int, code ErrorCode, message string) {
+	c.AbortWithStatusJSON(status, newAPIError(code, message, nil))
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// exportRangeUnit is the Range unit for session exports: offsets count
+// events in chain order, not bytes
+const exportRangeUnit = "events"
+
+// exportCSVHeader is the column order of CSV exports. JSON-valued fields are
+// embedded as compact JSON strings.
+var exportCSVHeader = []string{
+	"facto_id", "agent_id", "session_id", "parent_facto_id",
+	"action_type", "status", "started_at", "completed_at",
+	"input_data", "output_data", "execution_meta",
+	"signature", "public_key", "prev_hash", "event_hash",
+}
+
+// ExportSession handles GET /v1/sessions/:session_id/export?format=ndjson|csv
+//
+// Exports a session's events in chain order, one event per NDJSON line or CSV
+// row, streamed from storage like the ndjson session listing, so sessions of
+// any size export in full and MAX_EVENTS_PER_SESSION doesn't apply.
+// Interrupted downloads are resumed with an event-offset range rather than a
+// byte range:
+//
+//	Range: events=100-    events from offset 100 (0-based) to the end
+//	Range: events=100-199 events 100 through 199 inclusive
+//
+// Ranged responses are 206 with "Content-Range: events 100-199/1234", the
+// total being the session's event count; a client that has received n
+// complete lines resumes with "events=n-". A range starting past the last
+// event is 416. Other range units are ignored and the full export returned.
+// CSV exports only include the header row in responses starting at offset 0,
+// so resumed downloads can be appended as-is.
+//
+// As with the ndjson listing, the status is committed with the first event
+// written, so a failure after that ends the export early.
+func (h *Handlers) ExportSession(c *gin.Context) {
+	const endpoint = "export_session"
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}()
+
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "csv" {
+		respondError(c, endpoint, http.StatusBadRequest, CodeInvalidFormat, "format must be ndjson or csv")
+		return
+	}
+
+	ctx := c.Request.Context()
+	sessionID := c.Param("session_id")
+	total, err := h.sessionExportTotal(ctx, sessionID)
+	if err != nil {
+		respondError(c, endpoint, http.StatusInternalServerError, CodeStorageError, "failed to fetch session size")
+		return
+	}
+	if total == 0 {
+		respondError(c, endpoint, http.StatusNotFound, CodeNotFound, "no events found for session")
+		return
+	}
+
+	first, last, ranged, ok := parseEventRange(c.GetHeader("Range"), total)
+	if !ok {
+		h.rejectRange(c, total)
+		return
+	}
+
+	status := http.StatusOK
+	cw := csv.NewWriter(c.Writer)
+	enc := json.NewEncoder(c.Writer)
+	offset, written := 0, 0
+	err = h.forEachSessionEvent(ctx, sessionID, func(e EventResponse) error {
+		offset++
+		if offset <= first {
+			return nil
+		}
+		if written == 0 {
+			if h.rejectForbidden(c, endpoint, e.AgentID) {
+				return errStreamAborted
+			}
+			if ranged {
+				status = http.StatusPartialContent
+				c.Header("Content-Range", fmt.Sprintf("%s %d-%d/%d", exportRangeUnit, first, last, total))
+			}
+			c.Header("Accept-Ranges", exportRangeUnit)
+			if format == "csv" {
+				c.Header("Content-Type", "text/csv")
+				c.Status(status)
+				if first == 0 {
+					cw.Write(exportCSVHeader)
+				}
+			} else {
+				c.Header("Content-Type", "application/x-ndjson")
+				c.Status(status)
+			}
+		} else if msg := h.agentForbidden(c, e.AgentID); msg != "" {
+			return fmt.Errorf("%w: %s %s", errStreamAborted, msg, e.AgentID)
+		}
+
+		var err error
+		if format == "csv" {
+			writeEventCSV(cw, e)
+			err = cw.Error()
+		} else {
+			err = enc.Encode(e)
+		}
+		if err != nil {
+			return err
+		}
+		written++
+		if written%ndjsonFlushEvery == 0 {
+			cw.Flush()
+			c.Writer.Flush()
+		}
+		if first+written > last {
+			return errStopScan
+		}
+		return ctx.Err()
+	})
+
+	switch {
+	case written == 0 && errors.Is(err, errStreamAborted):
+		// The first event's check already wrote the response
+		return
+	case written == 0 && err != nil:
+		respondError(c, endpoint, http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	case written == 0:
+		// The session holds fewer events than its count said
+		h.rejectRange(c, offset)
+		return
+	case err != nil:
+		log.Warn().Err(err).Str("session_id", sessionID).Int("written", written).
+			Msg("Session export ended early")
+	}
+
+	cw.Flush()
+	c.Writer.Flush()
+	apiRequestsTotal.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+}
+
+// sessionExportTotal returns the number of events in a session for
+// Content-Range, from its session_summaries count. A session without a
+// summary row is counted by reading it through.
+func (h *Handlers) sessionExportTotal(ctx context.Context, sessionID string) (int, error) {
+	count, err := h.getSessionEventCount(ctx, sessionID)
+	if err != nil || count > 0 {
+		return int(count), err
+	}
+	total := 0
+	err = h.forEachSessionEvent(ctx, sessionID, func(EventResponse) error {
+		total++
+		return nil
+	})
+	return total, err
+}
+
+// rejectRange responds 416 to an export range outside a session of total
+// events
+func (h *Handlers) rejectRange(c *gin.Context, total int) {
+	c.Header("Content-Range", fmt.Sprintf("%s */%d", exportRangeUnit, total))
+	respondError(c, "export_session", http.StatusRequestedRangeNotSatisfiable, CodeRangeNotSatisfiable, "range not satisfiable")
+}
+
+// ndjsonFlushEvery is how many streamed events are written between flushes
+const ndjsonFlushEvery = 100
+
+// errStreamAborted stops a session stream after a check fails mid-response
+var errStreamAborted = errors.New("stream aborted")
+
+// streamSessionEvents serves GET /v1/sessions/:session_id/events?format=ndjson.
+// It writes every event of the session, in chain order, as one JSON object
+// per line straight from the storage iterator, flushing every
+// ndjsonFlushEvery events, so memory stays at one driver page whatever the
+// session size. limit, cursor and include_session_hash don't apply, and
+// MAX_EVENTS_PER_SESSION isn't enforced, since nothing is held in memory.
+//
+// The status is committed with the first event: API key and corrupt data
+// checks on the first event still get a 403/500, but a failure after that
+// can only end the stream early, leaving a truncated last line or a short
+// body. A session with no events is an empty 200. The stream stops when the
+// client goes away.
+func (h *Handlers) streamSessionEvents(c *gin.Context, sessionID string) {
+	const endpoint = "get_session_events_ndjson"
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}()
+
+	ctx := c.Request.Context()
+	enc := json.NewEncoder(c.Writer)
+	written := 0
+	err := h.forEachSessionEvent(ctx, sessionID, func(e EventResponse) error {
+		if written == 0 {
+			if h.rejectForbidden(c, endpoint, e.AgentID) ||
+				h.rejectCorrupt(c, endpoint, []EventResponse{e}) {
+				return errStreamAborted
+			}
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		} else if msg := h.agentForbidden(c, e.AgentID); msg != "" {
+			return fmt.Errorf("%w: %s %s", errStreamAborted, msg, e.AgentID)
+		} else if e.DataCorrupt && h.config.CorruptDataMode == "error" {
+			return fmt.Errorf("%w: stored event data is corrupt: %s", errStreamAborted, e.FactoID)
+		}
+
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		written++
+		if written%ndjsonFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return ctx.Err()
+	})
+
+	switch {
+	case written == 0 && errors.Is(err, errStreamAborted):
+		// The first event's check already wrote the response
+		return
+	case written == 0 && err != nil:
+		respondError(c, endpoint, http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	case written == 0:
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	case err != nil:
+		log.Warn().Err(err).Str("session_id", sessionID).Int("written", written).
+			Msg("Session event stream ended early")
+	}
+
+	c.Writer.Flush()
+	apiRequestsTotal.WithLabelValues(endpoint, "200").Inc()
+}
+
+// EvidenceManifest is manifest.json in a ZIP evidence package. Files maps
+// each other entry's name to the hex SHA-256 of its contents.
+type EvidenceManifest struct {
+	PackageID  string            `json:"package_id"`
+	SessionID  string            `json:"session_id"`
+	ExportedAt string            `json:"exported_at"`
+	EventCount int               `json:"event_count"`
+	ProofCount int               `json:"proof_count"`
+	MerkleRoot string            `json:"merkle_root"`
+	Files      map[string]string `json:"files"`
+}
+
+// writeEvidenceZip writes pkg as a ZIP attachment holding events.json,
+// merkle_proofs.json, VERIFY.txt and, last so it can carry their digests,
+// manifest.json. Entries are encoded straight into the response as the
+// archive is written; only the events already loaded for the package are in
+// memory. An error part-way leaves a truncated archive, which unzip rejects.
+func writeEvidenceZip(c *gin.Context, pkg *EvidencePackageResponse, merkleRoot string) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="facto-evidence-%s.zip"`, pkg.PackageID))
+	c.Status(http.StatusOK)
+
+	manifest := EvidenceManifest{
+		PackageID:  pkg.PackageID,
+		SessionID:  pkg.SessionID,
+		ExportedAt: pkg.ExportedAt,
+		EventCount: len(pkg.Events),
+		ProofCount: len(pkg.MerkleProofs),
+		MerkleRoot: merkleRoot,
+		Files:      make(map[string]string),
+	}
+
+	zw := zip.NewWriter(c.Writer)
+	entries := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"events.json", func(w io.Writer) error { return json.NewEncoder(w).Encode(pkg.Events) }},
+		{"merkle_proofs.json", func(w io.Writer) error { return json.NewEncoder(w).Encode(pkg.MerkleProofs) }},
+		{"VERIFY.txt", func(w io.Writer) error {
+			_, err := io.WriteString(w, pkg.VerificationInstructions+"\n")
+			return err
+		}},
+	}
+	for _, entry := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     entry.name,
+			Method:   zip.Deflate,
+			Modified: time.Now().UTC(),
+		})
+		if err != nil {
+			log.Error().Err(err).Str("session_id", pkg.SessionID).Msg("Failed to write evidence archive")
+			return
+		}
+		digest := sha256.New()
+		if err := entry.write(io.MultiWriter(w, digest)); err != nil {
+			log.Error().Err(err).Str("session_id", pkg.SessionID).Msg("Failed to write evidence archive")
+			return
+		}
+		manifest.Files[entry.name] = hex.EncodeToString(digest.Sum(nil))
+	}
+
+	w, err := zw.Create("manifest.json")
+	if err == nil {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(manifest)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Error().Err(err).Str("session_id", pkg.SessionID).Msg("Failed to write evidence archive")
+	}
+}
+
+// parseEventRange resolves a Range header against total events, returning the
+// inclusive offsets to send. ranged is false when the header is absent or not
+// an events range, in which case everything is sent; ok is false when the
+// range can't be satisfied.
+func parseEventRange(header string, total int) (first, last int, ranged, ok bool) {
+	spec, found := strings.CutPrefix(header, exportRangeUnit+"=")
+	if !found {
+		return 0, total - 1, false, true
+	}
+
+	startStr, endStr, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, true, false
+	}
+
+	first, err := strconv.Atoi(startStr)
+	if err != nil || first < 0 || first >= total {
+		return 0, 0, true, false
+	}
+
+	last = total - 1
+	if endStr != "" {
+		end, err := strconv.Atoi(endStr)
+		if err != nil || end < first {
+			return 0, 0, true, false
+		}
+		if end < last {
+			last = end
+		}
+	}
+
+	return first, last, true, true
+}
+
+// writeEventCSV writes an event as a CSV row in exportCSVHeader order
+func writeEventCSV(cw *csv.Writer, e EventResponse) {
+	parent := ""
+	if e.ParentFactoID != nil {
+		parent = *e.ParentFactoID
+	}
+	input, _ := json.Marshal(e.InputData)
+	output, _ := json.Marshal(e.OutputData)
+	meta, _ := json.Marshal(e.ExecutionMeta)
+
+	cw.Write([]string{
+		e.FactoID, e.AgentID, e.SessionID, parent,
+		e.ActionType, e.Status,
+		strconv.FormatInt(e.StartedAt, 10), strconv.FormatInt(e.CompletedAt, 10),
+		string(input), string(output), string(meta),
+		e.Proof.Signature, e.Proof.PublicKey, e.Proof.PrevHash, e.Proof.EventHash,
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportHandlers serves the export of a session of n events, ft-0 to
+// ft-<n-1>, whose session_summaries row counts count events
+func exportHandlers(n int, count int64) *Handlers {
+	h := NewHandlers(nil, &Config{})
+	h.getSessionEventCount = func(ctx context.Context, sessionID string) (int64, error) {
+		return count, nil
+	}
+	h.forEachSessionEvent = func(ctx context.Context, sessionID string, fn func(EventResponse) error) error {
+		for i := 0; i < n; i++ {
+			err := fn(EventResponse{FactoID: fmt.Sprintf("ft-%d", i), AgentID: "agent", SessionID: sessionID})
+			if err == errStopScan {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return h
+}
+
+// exportRange requests a session export with a Range header, if any
+func exportRange(t *testing.T, h *Handlers, format, rangeHeader string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/v1/sessions/:session_id/export", h.ExportSession)
+	req := httptest.NewRequest(http.MethodGet, "/v1/sessions/session-1/export?format="+format, nil)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// exportedIDs returns the facto_ids of an NDJSON export, in order
+func exportedIDs(t *testing.T, body string) []string {
+	t.Helper()
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		var e EventResponse
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		ids = append(ids, e.FactoID)
+	}
+	return ids
+}
+
+func TestExportSessionRanges(t *testing.T) {
+	// Larger than any page, so a resumed export must skip to its offset
+	const n = 12001
+	h := exportHandlers(n, n)
+
+	full := exportRange(t, h, "ndjson", "")
+	if full.Code != http.StatusOK || full.Header().Get("Content-Range") != "" {
+		t.Fatalf("full export: status %d, Content-Range %q", full.Code, full.Header().Get("Content-Range"))
+	}
+	if ids := exportedIDs(t, full.Body.String()); len(ids) != n || ids[n-1] != "ft-12000" {
+		t.Errorf("full export has %d events", len(ids))
+	}
+
+	resumed := exportRange(t, h, "ndjson", "events=10000-")
+	if resumed.Code != http.StatusPartialContent {
+		t.Fatalf("resumed export: status %d: %s", resumed.Code, resumed.Body)
+	}
+	if got, want := resumed.Header().Get("Content-Range"), "events 10000-12000/12001"; got != want {
+		t.Errorf("Content-Range %q, want %q", got, want)
+	}
+	if ids := exportedIDs(t, resumed.Body.String()); len(ids) != 2001 || ids[0] != "ft-10000" || ids[2000] != "ft-12000" {
+		t.Errorf("resumed export has %d events from %s", len(ids), ids[0])
+	}
+
+	slice := exportRange(t, h, "ndjson", "events=5-7")
+	if got := strings.Join(exportedIDs(t, slice.Body.String()), ","); got != "ft-5,ft-6,ft-7" {
+		t.Errorf("events=5-7 exported %s", got)
+	}
+	if got, want := slice.Header().Get("Content-Range"), "events 5-7/12001"; got != want {
+		t.Errorf("Content-Range %q, want %q", got, want)
+	}
+
+	past := exportRange(t, h, "ndjson", "events=12001-")
+	if past.Code != http.StatusRequestedRangeNotSatisfiable || past.Header().Get("Content-Range") != "events */12001" {
+		t.Errorf("range past the end: status %d, Content-Range %q", past.Code, past.Header().Get("Content-Range"))
+	}
+}
+
+func TestExportSessionCSVHeaderOnlyAtStart(t *testing.T) {
+	h := exportHandlers(10, 10)
+	for rangeHeader, want := range map[string][]string{
+		"events=0-1": {"facto_id", "ft-0", "ft-1"},
+		"events=5-6": {"ft-5", "ft-6"},
+	} {
+		rec := exportRange(t, h, "csv", rangeHeader)
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, row := range rows {
+			got = append(got, row[0])
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: rows %v, want %v", rangeHeader, got, want)
+		}
+	}
+}
+
+func TestExportSessionWithoutSummary(t *testing.T) {
+	// No session_summaries row: the total is counted from the events
+	rec := exportRange(t, exportHandlers(4, 0), "ndjson", "events=2-")
+	if got, want := rec.Header().Get("Content-Range"), "events 2-3/4"; got != want {
+		t.Errorf("Content-Range %q, want %q", got, want)
+	}
+	if got := strings.Join(exportedIDs(t, rec.Body.String()), ","); got != "ft-2,ft-3" {
+		t.Errorf("exported %s", got)
+	}
+
+	if rec := exportRange(t, exportHandlers(0, 0), "ndjson", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("empty session: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/factoid"
+	"github.com/facto-ai/facto/server/shared/factopb"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// apiKeyMetadata is the gRPC metadata key carrying the caller's API key, the
+// counterpart of the X-API-Key header
+const apiKeyMetadata = "x-api-key"
+
+// GRPCQuery serves the facto.v1.Query service on GRPC_PORT. It applies the
+// checks the matching HTTP routes apply: API keys (sent as x-api-key
+// metadata) must allow each returned event's agent, session_id must pass the
+// ID rules, and CORRUPT_DATA_MODE=error fails requests hitting corrupt rows.
+// TLS and mTLS use the HTTP server's configuration.
+type GRPCQuery struct {
+	factopb.UnimplementedQueryServer
+	storage *Storage
+	config  *Config
+	ids     idRules
+}
+
+// NewGRPCQuery creates the Query service reading from storage
+func NewGRPCQuery(storage *Storage, config *Config) *GRPCQuery {
+	return &GRPCQuery{
+		storage: storage,
+		config:  config,
+		ids:     newIDRules(config.IDMaxLength, config.IDAllowedChars),
+	}
+}
+
+// newGRPCServer creates the gRPC server, with TLS when tlsConfig is set
+func newGRPCServer(config *Config, tlsConfig *tls.Config) (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS key pair: %w", err)
+		}
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	return grpc.NewServer(opts...), nil
+}
+
+// GetEvent returns one event by facto_id, like GET /v1/events/:facto_id
+func (q *GRPCQuery) GetEvent(ctx context.Context, req *factopb.GetEventRequest) (event *factopb.Event, err error) {
+	const endpoint = "grpc_get_event"
+	defer observeGRPC(endpoint, time.Now(), &err)
+
+	apiKey, err := q.apiKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetFactoId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "facto_id is required")
+	}
+	if !factoid.Valid(req.GetFactoId()) {
+		return nil, status.Error(codes.InvalidArgument, "malformed facto_id")
+	}
+
+	e, err := q.storage.GetEventByFactoID(ctx, req.GetFactoId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch event")
+	}
+	if e == nil {
+		return nil, status.Error(codes.NotFound, "event not found")
+	}
+	if err := q.checkEvent(apiKey, e); err != nil {
+		return nil, err
+	}
+	return eventToProto(e)
+}
+
+// GetSessionEvents streams a session's events in chain order, like
+// GET /v1/sessions/:session_id/events?format=ndjson: straight from the
+// storage iterator, so MAX_EVENTS_PER_SESSION doesn't apply. A failed check
+// ends the stream with an error status after the events already sent.
+func (q *GRPCQuery) GetSessionEvents(req *factopb.GetSessionEventsRequest, stream factopb.Query_GetSessionEventsServer) (err error) {
+	const endpoint = "grpc_get_session_events"
+	defer observeGRPC(endpoint, time.Now(), &err)
+
+	ctx := stream.Context()
+	apiKey, err := q.apiKey(ctx)
+	if err != nil {
+		return err
+	}
+	if req.GetSessionId() == "" {
+		return status.Error(codes.InvalidArgument, "session_id is required")
+	}
+	if problem := q.ids.check("session_id", req.GetSessionId()); problem != "" {
+		return status.Error(codes.InvalidArgument, problem)
+	}
+
+	sent := 0
+	err = q.storage.StreamSessionEvents(ctx, req.GetSessionId(), func(e EventResponse) error {
+		if err := q.checkEvent(apiKey, &e); err != nil {
+			return err
+		}
+		msg, err := eventToProto(&e)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		sent++
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	log.Warn().Err(err).Str("session_id", req.GetSessionId()).Int("sent", sent).
+		Msg("Session event stream ended early")
+	return status.Error(codes.Internal, "failed to fetch events")
+}
+
+// apiKey returns the caller's API key, or nil when API keys are off
+func (q *GRPCQuery) apiKey(ctx context.Context) (*APIKey, error) {
+	if len(q.config.APIKeys) == 0 {
+		return nil, nil
+	}
+
+	var provided []byte
+	if values := metadata.ValueFromIncomingContext(ctx, apiKeyMetadata); len(values) > 0 {
+		provided = []byte(values[0])
+	}
+	var apiKey *APIKey
+	for _, k := range q.config.APIKeys {
+		if subtle.ConstantTimeCompare(provided, []byte(k.Key)) == 1 {
+			apiKey = k
+		}
+	}
+	if apiKey == nil {
+		return nil, status.Error(codes.Unauthenticated, "valid x-api-key required")
+	}
+	return apiKey, nil
+}
+
+// checkEvent applies the API key and corrupt data checks to a fetched event
+func (q *GRPCQuery) checkEvent(apiKey *APIKey, e *EventResponse) error {
+	if apiKey != nil && !apiKey.Allows(e.AgentID) {
+		return status.Error(codes.PermissionDenied, "API key not authorized for agent")
+	}
+	if e.DataCorrupt && q.config.CorruptDataMode == "error" {
+		return status.Errorf(codes.DataLoss, "stored event data is corrupt: %s", e.FactoID)
+	}
+	return nil
+}
+
+// observeGRPC records a gRPC call in the API request metrics, labelled with
+// the gRPC status code
+func observeGRPC(endpoint string, start time.Time, err *error) {
+	apiRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	apiRequestsTotal.WithLabelValues(endpoint, status.Code(*err).String()).Inc()
+}
+
+// eventToProto converts an API event to its Event message. Unset maps and
+// lists stay unset, so a client re-encoding the message as JSON gets the
+// same canonical form.
+func eventToProto(e *EventResponse) (*factopb.Event, error) {
+	msg := &factopb.Event{
+		FactoId:       e.FactoID,
+		AgentId:       e.AgentID,
+		SessionId:     e.SessionID,
+		ParentFactoId: e.ParentFactoID,
+		ActionType:    e.ActionType,
+		Status:        e.Status,
+		ExecutionMeta: &factopb.ExecutionMeta{
+			ModelId:     e.ExecutionMeta.ModelID,
+			ModelHash:   e.ExecutionMeta.ModelHash,
+			Temperature: e.ExecutionMeta.Temperature,
+			Seed:        e.ExecutionMeta.Seed,
+			MaxTokens:   e.ExecutionMeta.MaxTokens,
+			SdkVersion:  e.ExecutionMeta.SDKVersion,
+			SdkLanguage: e.ExecutionMeta.SDKLanguage,
+			Tags:        e.ExecutionMeta.Tags,
+		},
+		Proof: &factopb.Proof{
+			Signature: e.Proof.Signature,
+			PublicKey: e.Proof.PublicKey,
+			SigAlgo:   e.Proof.SigAlgo,
+			PrevHash:  e.Proof.PrevHash,
+			EventHash: e.Proof.EventHash,
+
+			CanonicalVersion: int32(e.Proof.CanonicalVersion),
+		},
+		StartedAt:   e.StartedAt,
+		CompletedAt: e.CompletedAt,
+		DataCorrupt: e.DataCorrupt,
+		StreamSeq:   e.StreamSeq,
+	}
+
+	var err error
+	if e.InputData != nil {
+		if msg.InputData, err = structpb.NewStruct(protoNumbers(e.InputData).(map[string]interface{})); err != nil {
+			return nil, eventConversionError(e, err)
+		}
+	}
+	if e.OutputData != nil {
+		if msg.OutputData, err = structpb.NewStruct(protoNumbers(e.OutputData).(map[string]interface{})); err != nil {
+			return nil, eventConversionError(e, err)
+		}
+	}
+	if e.ExecutionMeta.ToolCalls != nil {
+		if msg.ExecutionMeta.ToolCalls, err = structpb.NewList(protoNumbers(e.ExecutionMeta.ToolCalls).([]interface{})); err != nil {
+			return nil, eventConversionError(e, err)
+		}
+	}
+	return msg, nil
+}
+
+// protoNumbers returns v with the exact json.Number values storage decodes
+// converted to float64, the only number a protobuf Value holds. Integers
+// beyond 2^53 lose precision on the gRPC API; the REST API returns them
+// exactly.
+func protoNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v // left for structpb to reject
+		}
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			out[k] = protoNumbers(elem)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = protoNumbers(elem)
+		}
+		return out
+	}
+	return v
+}
+
+// eventConversionError logs stored data with no protobuf form and returns
+// the status to send instead
+func eventConversionError(e *EventResponse, err error) error {
+	log.Error().Err(err).Str("facto_id", e.FactoID).Msg("Failed to convert event for gRPC")
+	return status.Error(codes.Internal, "failed to encode event")
+}
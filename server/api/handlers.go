@@ -1,18 +1,32 @@
 package main
 
 import (
-	"crypto/ed25519"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/factoid"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/facto-ai/facto/server/shared/signature"
+	"github.com/facto-ai/facto/server/shared/webhook"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -27,31 +41,114 @@ var (
 		Help:    "Duration of API requests in seconds",
 		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
 	}, []string{"endpoint"})
+
+	corruptEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_api_corrupt_events_total",
+		Help: "Total number of events read whose stored JSON could not be parsed",
+	})
 )
 
 // Handlers contains the API handlers
 type Handlers struct {
-	storage *Storage
+	storage   *Storage
+	config    *Config
+	treeCache *merkleTreeCache
+	search    *SearchIndex // nil unless SEARCH_INDEX_URL is set
+
+	// getEvents lists an agent's events for GetEvents; Storage.GetEvents
+	// outside tests
+	getEvents func(ctx context.Context, agentID string, start, end time.Time, limit int, cursor string, concurrency int, filter EventFilter, order SortOrder) ([]EventResponse, *string, error)
+
+	// getEventByFactoID, getSessionEvents and getAgents back the event,
+	// session and agent lookups; Storage outside tests
+	getEventByFactoID func(ctx context.Context, factoID string) (*EventResponse, error)
+	getSessionEvents  func(ctx context.Context, sessionID string, limit int, cursor string, order SortOrder) ([]EventResponse, *string, error)
+	getAgents         func(ctx context.Context, limit int, cursor string) ([]AgentSummary, *string, error)
+
+	// forEachSessionEvent and getSessionEventCount back the streamed session
+	// reads and exports; Storage.StreamSessionEvents and
+	// Storage.GetSessionEventCount outside tests
+	forEachSessionEvent  func(ctx context.Context, sessionID string, fn func(EventResponse) error) error
+	getSessionEventCount func(ctx context.Context, sessionID string) (int64, error)
+
+	// isEventAnchored looks an event hash up in merkle_roots_by_event for
+	// VERIFY_ANCHORED; Storage.IsEventAnchored outside tests
+	isEventAnchored func(ctx context.Context, eventHash string) (bool, error)
+
+	// deleter backs DeleteSession; storage outside tests
+	deleter sessionDeleter
+
+	// scanAllEvents pages through the events table for AuditScan;
+	// Storage.ScanAllEvents outside tests
+	scanAllEvents func(ctx context.Context, pageSize int, cursor string) ([]EventResponse, *string, error)
+
+	// rebuildIndexPage copies a page of source rows for RebuildIndex;
+	// Storage.RebuildIndexPage outside tests
+	rebuildIndexPage func(ctx context.Context, table string, pageSize int, cursor string) (int, *string, error)
 }
 
 // NewHandlers creates a new Handlers instance
-func NewHandlers(storage *Storage) *Handlers {
-	return &Handlers{storage: storage}
+func NewHandlers(storage *Storage, config *Config) *Handlers {
+	h := &Handlers{
+		storage:   storage,
+		config:    config,
+		treeCache: newMerkleTreeCache(config.MerkleTreeCacheSize, config.MerkleScheme),
+	}
+	h.getEvents = storage.GetEvents
+	h.getEventByFactoID = storage.GetEventByFactoID
+	h.getSessionEvents = storage.GetSessionEvents
+	h.getAgents = storage.GetAgents
+	h.forEachSessionEvent = storage.StreamSessionEvents
+	h.getSessionEventCount = storage.GetSessionEventCount
+	h.isEventAnchored = storage.IsEventAnchored
+	h.deleter = storage
+	h.scanAllEvents = storage.ScanAllEvents
+	h.rebuildIndexPage = storage.RebuildIndexPage
+	if config.SearchIndexURL != "" {
+		h.search = NewSearchIndex(config.SearchIndexURL, config.SearchIndexName)
+	}
+	return h
 }
 
 // EventsQuery represents query parameters for events listing
 type EventsQuery struct {
-	AgentID string `form:"agent_id" binding:"required"`
-	Start   string `form:"start" binding:"required"`
-	End     string `form:"end" binding:"required"`
-	Limit   int    `form:"limit"`
-	Cursor  string `form:"cursor"`
+	AgentID   string `form:"agent_id" binding:"required"`
+	Start     string `form:"start" binding:"required"`
+	End       string `form:"end" binding:"required"`
+	Limit     int    `form:"limit"`
+	Cursor    string `form:"cursor"`
+	JSONPath  string `form:"json_path"`
+	JSONValue string `form:"json_value"`
+
+	// ActionType and Status keep only events with that action_type/status
+	ActionType string `form:"action_type"`
+	Status     string `form:"status"`
+
+	// Tags are key:value pairs an event's tags must all contain. The key
+	// ends at the first colon, so values may contain colons.
+	Tags []string `form:"tag"`
+
+	// IncludeTotal adds the number of matching events in the whole range,
+	// counted with a COUNT(*) over every partition in range
+	IncludeTotal bool `form:"include_total"`
+
+	// Order is "asc" (default, oldest first) or "desc" (newest first)
+	Order string `form:"order"`
 }
 
+// postFilterScanFactor is how many rows are scanned per requested row when
+// filtering events in memory; postFilterMaxScan caps the rows scanned
+const (
+	postFilterScanFactor = 10
+	postFilterMaxScan    = 5000
+)
+
 // EventsResponse represents the response for events listing
 type EventsResponse struct {
-	Events     []EventResponse `json:"events"`
-	NextCursor *string         `json:"next_cursor"`
+	Events      []EventResponse `json:"events"`
+	NextCursor  *string         `json:"next_cursor"`
+	SessionHash string          `json:"session_hash,omitempty"`
+	Total       *int64          `json:"total,omitempty"` // with include_total=true
 }
 
 // EventResponse represents a single event in API responses
@@ -68,6 +165,24 @@ type EventResponse struct {
 	Proof         ProofResponse          `json:"proof"`
 	StartedAt     int64                  `json:"started_at"`
 	CompletedAt   int64                  `json:"completed_at"`
+	DataCorrupt   bool                   `json:"data_corrupt,omitempty"`
+
+	// StreamSeq is the JetStream stream sequence the event was consumed at,
+	// when the processor runs with STORE_STREAM_SEQ=true
+	StreamSeq *int64 `json:"stream_seq,omitempty"`
+
+	// CustomFields are unsigned fields the processor stored with the event,
+	// such as NATS headers allowlisted by HEADER_TAGS. They aren't part of
+	// the canonical form, so verification ignores them.
+	CustomFields map[string]string `json:"custom_fields,omitempty"`
+
+	// ReceivedAt is when the processor stored the event (session reads only)
+	ReceivedAt int64 `json:"-"`
+
+	// Canonical form snapshot taken at ingest, if the processor stored one
+	// (session reads only)
+	StoredCanonicalForm     string `json:"-"`
+	StoredCanonicalEncoding string `json:"-"`
 }
 
 // ExecutionMetaResponse represents execution metadata in API responses
@@ -87,8 +202,13 @@ type ExecutionMetaResponse struct {
 type ProofResponse struct {
 	Signature string `json:"signature"`
 	PublicKey string `json:"public_key"`
+	SigAlgo   string `json:"sig_algo,omitempty"` // empty means ed25519
 	PrevHash  string `json:"prev_hash"`
 	EventHash string `json:"event_hash"`
+
+	// CanonicalVersion is the canonical form the hash and signature cover;
+	// 0 (absent) means canonical.V1
+	CanonicalVersion canonical.Version `json:"canonical_version,omitempty"`
 }
 
 // GetEvents handles GET /v1/events
@@ -100,8 +220,7 @@ func (h *Handlers) GetEvents(c *gin.Context) {
 
 	var query EventsQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
-		apiRequestsTotal.WithLabelValues("get_events", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, "get_events", http.StatusBadRequest, CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -111,32 +230,311 @@ func (h *Handlers) GetEvents(c *gin.Context) {
 
 	startTime, err := time.Parse(time.RFC3339, query.Start)
 	if err != nil {
-		apiRequestsTotal.WithLabelValues("get_events", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start time format"})
+		respondError(c, "get_events", http.StatusBadRequest, CodeInvalidTimeFormat, "invalid start time format")
 		return
 	}
 
 	endTime, err := time.Parse(time.RFC3339, query.End)
 	if err != nil {
-		apiRequestsTotal.WithLabelValues("get_events", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end time format"})
+		respondError(c, "get_events", http.StatusBadRequest, CodeInvalidTimeFormat, "invalid end time format")
+		return
+	}
+
+	if msg := h.checkQueryRange(startTime, endTime); msg != "" {
+		respondError(c, "get_events", http.StatusBadRequest, CodeInvalidTimeRange, msg)
+		return
+	}
+
+	order, ok := parseSortOrder(query.Order, OrderAsc)
+	if !ok {
+		respondError(c, "get_events", http.StatusBadRequest, CodeInvalidParameter, "order must be asc or desc")
+		return
+	}
+
+	// json_path filtering happens in memory after the partition scan, since
+	// ScyllaDB can't look inside the input/output blobs
+	var path *jsonPath
+	if query.JSONPath != "" {
+		if path, err = compileJSONPath(query.JSONPath); err != nil {
+			respondError(c, "get_events", http.StatusBadRequest, CodeInvalidFilter, err.Error())
+			return
+		}
+	}
+
+	tags, err := parseTagFilters(query.Tags)
+	if err != nil {
+		respondError(c, "get_events", http.StatusBadRequest, CodeInvalidFilter, err.Error())
+		return
+	}
+
+	// action_type and status are regular columns, matched by ScyllaDB within
+	// each partition read; that needs ALLOW FILTERING. Tags are matched in
+	// memory after the scan, like json_path.
+	dbFilter := EventFilter{ActionType: query.ActionType, Status: query.Status}
+	if !dbFilter.IsZero() && !h.requireFiltering(c, "get_events", "filtering by action_type or status") {
 		return
 	}
+	memFilter := EventFilter{Tags: tags}
+
+	postFilter := path != nil || !memFilter.IsZero()
+	if query.IncludeTotal && postFilter {
+		// COUNT(*) can only apply the filters ScyllaDB matches
+		respondError(c, "get_events", http.StatusBadRequest, CodeInvalidFilter,
+			"include_total can't be combined with json_path or tag")
+		return
+	}
+
+	fetchLimit := query.Limit
+	if postFilter {
+		fetchLimit = query.Limit * postFilterScanFactor
+		if fetchLimit > postFilterMaxScan {
+			respondErrorDetails(c, "get_events", http.StatusRequestEntityTooLarge, CodeTooLarge,
+				fmt.Sprintf("in-memory filtering scans %d rows per requested row and at most %d rows; lower the limit",
+					postFilterScanFactor, postFilterMaxScan),
+				gin.H{"scan_factor": postFilterScanFactor, "max_scan": postFilterMaxScan})
+			return
+		}
+	}
 
-	events, nextCursor, err := h.storage.GetEvents(c.Request.Context(), query.AgentID, startTime, endTime, query.Limit, query.Cursor)
+	events, nextCursor, err := h.getEvents(c.Request.Context(), query.AgentID, startTime, endTime, fetchLimit, query.Cursor, h.config.PartitionConcurrency, dbFilter, order)
+	if h.rejectCursor(c, "get_events", err) {
+		return
+	}
 	if err != nil {
-		apiRequestsTotal.WithLabelValues("get_events", "500").Inc()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch events"})
+		respondError(c, "get_events", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	}
+
+	if postFilter {
+		events = filterEvents(events, path, query.JSONValue, memFilter, query.Limit)
+		// A full page may have left scanned matches behind, so the next
+		// page resumes after the last match returned rather than the scan
+		if len(events) == query.Limit {
+			next := h.storage.cursors.eventsCursor(query.AgentID, order, events[len(events)-1])
+			nextCursor = &next
+		}
+	}
+
+	if h.rejectCorrupt(c, "get_events", events) {
 		return
 	}
 
+	response := EventsResponse{
+		Events:     events,
+		NextCursor: nextCursor,
+	}
+	if query.IncludeTotal {
+		total, err := h.storage.CountEvents(c.Request.Context(), query.AgentID, startTime, endTime, h.config.PartitionConcurrency, dbFilter)
+		if err != nil {
+			respondError(c, "get_events", http.StatusInternalServerError, CodeStorageError, "failed to count events")
+			return
+		}
+		response.Total = &total
+	}
+
 	apiRequestsTotal.WithLabelValues("get_events", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// AgentDateEventsQuery represents query parameters for a single-day events listing
+type AgentDateEventsQuery struct {
+	Date   string `form:"date" binding:"required"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+// GetAgentEventsByDate handles GET /v1/agents/:agent_id/events?date=YYYY-MM-DD
+func (h *Handlers) GetAgentEventsByDate(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("get_agent_events_by_date").Observe(time.Since(start).Seconds())
+	}()
+
+	var query AgentDateEventsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "get_agent_events_by_date", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if query.Limit <= 0 || query.Limit > 1000 {
+		query.Limit = 100
+	}
+
+	date, err := time.Parse("2006-01-02", query.Date)
+	if err != nil {
+		respondError(c, "get_agent_events_by_date", http.StatusBadRequest, CodeInvalidDateFormat, "invalid date format, expected YYYY-MM-DD")
+		return
+	}
+
+	events, nextCursor, err := h.storage.GetEventsByDate(c.Request.Context(), c.Param("agent_id"), date, query.Limit, query.Cursor)
+	if h.rejectCursor(c, "get_agent_events_by_date", err) {
+		return
+	}
+	if err != nil {
+		respondError(c, "get_agent_events_by_date", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	}
+
+	if h.rejectCorrupt(c, "get_agent_events_by_date", events) {
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("get_agent_events_by_date", "200").Inc()
 	c.JSON(http.StatusOK, EventsResponse{
 		Events:     events,
 		NextCursor: nextCursor,
 	})
 }
 
+// parseSortOrder parses an order parameter, returning def when it is empty
+func parseSortOrder(value string, def SortOrder) (SortOrder, bool) {
+	switch SortOrder(value) {
+	case "":
+		return def, true
+	case OrderAsc, OrderDesc:
+		return SortOrder(value), true
+	}
+	return "", false
+}
+
+// parseTagFilters parses tag=key:value query values into the tags an event
+// must all carry. Repeating a key with a different value is rejected, since
+// no event could match both.
+func parseTagFilters(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tag %q must be key:value", v)
+		}
+		if existing, dup := tags[key]; dup && existing != value {
+			return nil, fmt.Errorf("tag key %q given with conflicting values", key)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// filterEvents keeps up to limit events that pass filter and, when path is
+// set, whose input/output data matches path = value. The path is evaluated
+// against {"input_data": …, "output_data": …}.
+func filterEvents(events []EventResponse, path *jsonPath, value string, filter EventFilter, limit int) []EventResponse {
+	filtered := make([]EventResponse, 0, limit)
+	for _, e := range events {
+		if !filter.Matches(&e) {
+			continue
+		}
+		doc := map[string]interface{}{
+			"input_data":  e.InputData,
+			"output_data": e.OutputData,
+		}
+		if path == nil || path.Matches(doc, value) {
+			filtered = append(filtered, e)
+			if len(filtered) == limit {
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// checkQueryRange enforces the configured span and partition limits on an
+// events query, returning a client-facing error message when exceeded
+func (h *Handlers) checkQueryRange(start, end time.Time) string {
+	if end.Before(start) {
+		return "end time must not be before start time"
+	}
+
+	if h.config.MaxQuerySpan > 0 && end.Sub(start) > h.config.MaxQuerySpan {
+		return fmt.Sprintf("time range exceeds maximum span of %d days", int(h.config.MaxQuerySpan.Hours()/24))
+	}
+
+	if h.config.MaxPartitionsPerQuery > 0 {
+		if partitions := countDatePartitions(start, end); partitions > h.config.MaxPartitionsPerQuery {
+			return fmt.Sprintf("time range covers %d date partitions, maximum is %d", partitions, h.config.MaxPartitionsPerQuery)
+		}
+	}
+
+	return ""
+}
+
+// requireFiltering guards query features that can only be served with CQL
+// ALLOW FILTERING. It writes a 400 and returns false when filtering is disabled.
+func (h *Handlers) requireFiltering(c *gin.Context, endpoint, feature string) bool {
+	if h.config.AllowFiltering {
+		return true
+	}
+
+	respondError(c, endpoint, http.StatusBadRequest, CodeFilteringDisabled,
+		feature+" requires a filtered scan, which is disabled on this server; "+
+			"add an index for this query pattern or set ALLOW_FILTERING_ENABLED=true")
+	return false
+}
+
+// rejectCursor fails the request with 400 when err is a cursor error from
+// GetEvents, GetEventsByDate or GetSessionEvents
+func (h *Handlers) rejectCursor(c *gin.Context, endpoint string, err error) bool {
+	switch err {
+	case ErrInvalidCursor:
+		respondError(c, endpoint, http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+	case ErrCursorExpired:
+		respondError(c, endpoint, http.StatusBadRequest, CodeCursorExpired, "cursor expired; restart the listing")
+	default:
+		return false
+	}
+	return true
+}
+
+// rejectCorrupt fails the request when CORRUPT_DATA_MODE=error and any event
+// has unparseable stored JSON. In the default "flag" mode the events are
+// returned with data_corrupt set instead.
+func (h *Handlers) rejectCorrupt(c *gin.Context, endpoint string, events []EventResponse) bool {
+	if h.config.CorruptDataMode != "error" {
+		return false
+	}
+
+	var corrupt []string
+	for _, e := range events {
+		if e.DataCorrupt {
+			corrupt = append(corrupt, e.FactoID)
+		}
+	}
+	if len(corrupt) == 0 {
+		return false
+	}
+
+	respondErrorDetails(c, endpoint, http.StatusInternalServerError, CodeCorruptData,
+		"stored event data is corrupt", gin.H{"facto_ids": corrupt})
+	return true
+}
+
+// checkSessionSize rejects requests that would load a session larger than
+// MAX_EVENTS_PER_SESSION into memory. It writes the response and returns false
+// when the request must stop.
+func (h *Handlers) checkSessionSize(c *gin.Context, endpoint, sessionID string) bool {
+	if h.config.MaxEventsPerSession <= 0 {
+		return true
+	}
+
+	count, err := h.storage.GetSessionEventCount(c.Request.Context(), sessionID)
+	if err != nil {
+		respondError(c, endpoint, http.StatusInternalServerError, CodeStorageError, "failed to fetch session size")
+		return false
+	}
+
+	if count > h.config.MaxEventsPerSession {
+		respondErrorDetails(c, endpoint, http.StatusRequestEntityTooLarge, CodeTooLarge,
+			"session too large", gin.H{"event_count": count, "limit": h.config.MaxEventsPerSession})
+		return false
+	}
+
+	return true
+}
+
 // GetEventByFactoID handles GET /v1/events/:facto_id
 func (h *Handlers) GetEventByFactoID(c *gin.Context) {
 	start := time.Now()
@@ -146,21 +544,30 @@ func (h *Handlers) GetEventByFactoID(c *gin.Context) {
 
 	factoID := c.Param("facto_id")
 	if factoID == "" {
-		apiRequestsTotal.WithLabelValues("get_event", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "facto_id is required"})
+		respondError(c, "get_event", http.StatusBadRequest, CodeInvalidRequest, "facto_id is required")
+		return
+	}
+	if !factoid.Valid(factoID) {
+		respondError(c, "get_event", http.StatusBadRequest, CodeInvalidID, "malformed facto_id")
 		return
 	}
 
-	event, err := h.storage.GetEventByFactoID(c.Request.Context(), factoID)
+	event, err := h.getEventByFactoID(c.Request.Context(), factoID)
 	if err != nil {
-		apiRequestsTotal.WithLabelValues("get_event", "500").Inc()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch event"})
+		respondError(c, "get_event", http.StatusInternalServerError, CodeStorageError, "failed to fetch event")
 		return
 	}
 
 	if event == nil {
-		apiRequestsTotal.WithLabelValues("get_event", "404").Inc()
-		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		respondError(c, "get_event", http.StatusNotFound, CodeNotFound, "event not found")
+		return
+	}
+
+	if h.rejectForbidden(c, "get_event", event.AgentID) {
+		return
+	}
+
+	if h.rejectCorrupt(c, "get_event", []EventResponse{*event}) {
 		return
 	}
 
@@ -170,8 +577,20 @@ func (h *Handlers) GetEventByFactoID(c *gin.Context) {
 
 // SessionEventsQuery represents query parameters for session events
 type SessionEventsQuery struct {
-	Limit  int    `form:"limit"`
-	Cursor string `form:"cursor"`
+	Limit              int    `form:"limit"`
+	Cursor             string `form:"cursor"`
+	IncludeSessionHash bool   `form:"include_session_hash"`
+
+	// IncludeTotal adds the session's event count, as maintained by the
+	// processor in session_summaries
+	IncludeTotal bool `form:"include_total"`
+
+	// Format is "json" (default) or "ndjson" to stream the whole session
+	Format string `form:"format"`
+
+	// Order is "asc" (default, chain order) or "desc" (newest first). ndjson
+	// always streams in chain order.
+	Order string `form:"order"`
 }
 
 // GetSessionEvents handles GET /v1/sessions/:session_id/events
@@ -183,142 +602,581 @@ func (h *Handlers) GetSessionEvents(c *gin.Context) {
 
 	sessionID := c.Param("session_id")
 	if sessionID == "" {
-		apiRequestsTotal.WithLabelValues("get_session_events", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id is required"})
+		respondError(c, "get_session_events", http.StatusBadRequest, CodeInvalidRequest, "session_id is required")
 		return
 	}
 
 	var query SessionEventsQuery
 	c.ShouldBindQuery(&query)
 
+	switch query.Format {
+	case "", "json":
+	case "ndjson":
+		h.streamSessionEvents(c, sessionID)
+		return
+	default:
+		respondError(c, "get_session_events", http.StatusBadRequest, CodeInvalidFormat, "format must be json or ndjson")
+		return
+	}
+
 	if query.Limit <= 0 || query.Limit > 1000 {
 		query.Limit = 100
 	}
 
-	events, nextCursor, err := h.storage.GetSessionEvents(c.Request.Context(), sessionID, query.Limit, query.Cursor)
+	order, ok := parseSortOrder(query.Order, OrderAsc)
+	if !ok {
+		respondError(c, "get_session_events", http.StatusBadRequest, CodeInvalidParameter, "order must be asc or desc")
+		return
+	}
+
+	events, nextCursor, err := h.getSessionEvents(c.Request.Context(), sessionID, query.Limit, query.Cursor, order)
+	if h.rejectCursor(c, "get_session_events", err) {
+		return
+	}
 	if err != nil {
-		apiRequestsTotal.WithLabelValues("get_session_events", "500").Inc()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch events"})
+		respondError(c, "get_session_events", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
 		return
 	}
 
-	apiRequestsTotal.WithLabelValues("get_session_events", "200").Inc()
-	c.JSON(http.StatusOK, EventsResponse{
+	if h.rejectForbidden(c, "get_session_events", eventAgentIDs(events)...) {
+		return
+	}
+
+	if h.rejectCorrupt(c, "get_session_events", events) {
+		return
+	}
+
+	response := EventsResponse{
 		Events:     events,
 		NextCursor: nextCursor,
-	})
-}
+	}
 
-// VerifyRequest represents a verification request
-type VerifyRequest struct {
-	Event EventResponse `json:"event" binding:"required"`
-}
+	// The session hash covers the events in this response. It equals the
+	// session_hash from /v1/verify/chain only when the whole session fits in
+	// one page (no next_cursor), and is only stable once the session is
+	// complete: any later event changes it.
+	if query.IncludeSessionHash {
+		response.SessionHash = pageSessionHash(events, order)
+	}
 
-// VerifyResponse represents a verification response
-type VerifyResponse struct {
-	Valid  bool        `json:"valid"`
-	Checks VerifyCheck `json:"checks"`
-}
+	if query.IncludeTotal {
+		total, err := h.storage.GetSessionEventCount(c.Request.Context(), sessionID)
+		if err != nil {
+			respondError(c, "get_session_events", http.StatusInternalServerError, CodeStorageError, "failed to count events")
+			return
+		}
+		response.Total = &total
+	}
 
-// VerifyCheck represents individual verification checks
-type VerifyCheck struct {
-	HashValid      bool  `json:"hash_valid"`
-	SignatureValid bool  `json:"signature_valid"`
-	ChainValid     *bool `json:"chain_valid"`
+	apiRequestsTotal.WithLabelValues("get_session_events", "200").Inc()
+	c.JSON(http.StatusOK, response)
 }
 
-// VerifyEvent handles POST /v1/verify
-func (h *Handlers) VerifyEvent(c *gin.Context) {
+// GetSessionStart handles GET /v1/sessions/:session_id/start
+func (h *Handlers) GetSessionStart(c *gin.Context) {
 	start := time.Now()
 	defer func() {
-		apiRequestDuration.WithLabelValues("verify").Observe(time.Since(start).Seconds())
+		apiRequestDuration.WithLabelValues("get_session_start").Observe(time.Since(start).Seconds())
 	}()
 
-	var req VerifyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		apiRequestsTotal.WithLabelValues("verify", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	sessionStart, err := h.storage.GetSessionStart(c.Request.Context(), c.Param("session_id"))
+	if err != nil {
+		respondError(c, "get_session_start", http.StatusInternalServerError, CodeStorageError, "failed to fetch session start")
 		return
 	}
 
-	// Verify hash
-	hashValid := verifyHash(&req.Event)
-
-	// Verify signature
-	signatureValid := verifySignature(&req.Event)
+	if sessionStart == nil {
+		respondError(c, "get_session_start", http.StatusNotFound, CodeNotFound, "session start not found")
+		return
+	}
 
-	response := VerifyResponse{
-		Valid: hashValid && signatureValid,
-		Checks: VerifyCheck{
-			HashValid:      hashValid,
-			SignatureValid: signatureValid,
-			ChainValid:     nil, // Would need previous event to verify chain
-		},
+	if h.rejectForbidden(c, "get_session_start", sessionStart.AgentID) {
+		return
 	}
 
-	apiRequestsTotal.WithLabelValues("verify", "200").Inc()
-	c.JSON(http.StatusOK, response)
+	apiRequestsTotal.WithLabelValues("get_session_start", "200").Inc()
+	c.JSON(http.StatusOK, sessionStart)
 }
 
-// ChainVerifyQuery represents query parameters for chain verification
-type ChainVerifyQuery struct {
-	SessionID string `form:"session_id" binding:"required"`
+// SessionMetadataUpdate represents a session metadata PATCH body
+type SessionMetadataUpdate struct {
+	Tags        map[string]string `json:"tags"`
+	Description *string           `json:"description"`
 }
 
-// ChainVerifyResponse represents the chain verification result
-type ChainVerifyResponse struct {
-	Valid       bool              `json:"valid"`
-	EventCount  int               `json:"event_count"`
-	Checks      ChainVerifyChecks `json:"checks"`
-	FirstEvent  string            `json:"first_event,omitempty"`
-	LastEvent   string            `json:"last_event,omitempty"`
-	SessionHash string            `json:"session_hash,omitempty"`
-	Errors      []string          `json:"errors,omitempty"`
-}
+// GetSessionMetadata handles GET /v1/sessions/:session_id/metadata
+func (h *Handlers) GetSessionMetadata(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("get_session_metadata").Observe(time.Since(start).Seconds())
+	}()
 
-// ChainVerifyChecks represents individual chain verification checks
-type ChainVerifyChecks struct {
-	AllHashesValid      bool `json:"all_hashes_valid"`
-	AllSignaturesValid  bool `json:"all_signatures_valid"`
-	ChainIntegrityValid bool `json:"chain_integrity_valid"`
+	meta, err := h.storage.GetSessionMetadata(c.Request.Context(), c.Param("session_id"))
+	if err != nil {
+		respondError(c, "get_session_metadata", http.StatusInternalServerError, CodeStorageError, "failed to fetch session metadata")
+		return
+	}
+
+	if meta == nil {
+		respondError(c, "get_session_metadata", http.StatusNotFound, CodeNotFound, "session not found")
+		return
+	}
+
+	if h.rejectForbidden(c, "get_session_metadata", meta.AgentID) {
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("get_session_metadata", "200").Inc()
+	c.JSON(http.StatusOK, meta)
 }
 
-// VerifyChain handles GET /v1/verify/chain
-func (h *Handlers) VerifyChain(c *gin.Context) {
+// UpdateSessionMetadata handles PATCH /v1/sessions/:session_id/metadata
+// Tags are merged into the existing set; description is replaced when present.
+func (h *Handlers) UpdateSessionMetadata(c *gin.Context) {
 	start := time.Now()
 	defer func() {
-		apiRequestDuration.WithLabelValues("verify_chain").Observe(time.Since(start).Seconds())
+		apiRequestDuration.WithLabelValues("update_session_metadata").Observe(time.Since(start).Seconds())
 	}()
 
-	var query ChainVerifyQuery
-	if err := c.ShouldBindQuery(&query); err != nil {
-		apiRequestsTotal.WithLabelValues("verify_chain", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	sessionID := c.Param("session_id")
+
+	var req SessionMetadataUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, "update_session_metadata", http.StatusBadRequest, CodeInvalidRequest, err.Error())
 		return
 	}
 
-	// Get all events for the session
-	events, _, err := h.storage.GetSessionEvents(c.Request.Context(), query.SessionID, 10000, "")
+	// Metadata rows are created by the processor, so a missing row means the
+	// session has no stored events
+	existing, err := h.storage.GetSessionMetadata(c.Request.Context(), sessionID)
 	if err != nil {
-		apiRequestsTotal.WithLabelValues("verify_chain", "500").Inc()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch events"})
+		respondError(c, "update_session_metadata", http.StatusInternalServerError, CodeStorageError, "failed to fetch session metadata")
 		return
 	}
 
-	if len(events) == 0 {
-		apiRequestsTotal.WithLabelValues("verify_chain", "404").Inc()
-		c.JSON(http.StatusNotFound, gin.H{"error": "no events found for session"})
+	if existing == nil {
+		respondError(c, "update_session_metadata", http.StatusNotFound, CodeNotFound, "session not found")
 		return
 	}
 
-	// Sort events by completed_at (oldest first for chain verification)
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].CompletedAt < events[j].CompletedAt
-	})
+	if h.rejectForbidden(c, "update_session_metadata", existing.AgentID) {
+		return
+	}
 
-	response := ChainVerifyResponse{
-		EventCount: len(events),
-		FirstEvent: events[0].FactoID,
+	if req.Tags == nil {
+		req.Tags = map[string]string{}
+	}
+
+	updated, err := h.storage.UpdateSessionMetadata(c.Request.Context(), sessionID, req.Tags, req.Description)
+	if err != nil {
+		respondError(c, "update_session_metadata", http.StatusInternalServerError, CodeStorageError, "failed to update session metadata")
+		return
+	}
+	if !updated {
+		// Deleted since it was read
+		respondError(c, "update_session_metadata", http.StatusNotFound, CodeNotFound, "session not found")
+		return
+	}
+
+	meta, err := h.storage.GetSessionMetadata(c.Request.Context(), sessionID)
+	if err != nil || meta == nil {
+		respondError(c, "update_session_metadata", http.StatusInternalServerError, CodeStorageError, "failed to fetch session metadata")
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("update_session_metadata", "200").Inc()
+	c.JSON(http.StatusOK, meta)
+}
+
+// VerifyRequest represents a verification request
+type VerifyRequest struct {
+	Event EventResponse `json:"event" binding:"required"`
+
+	// SignedForm is the canonical form the caller signed. With diff=true the
+	// fields that differ from the recomputed form are listed.
+	SignedForm string `json:"signed_form,omitempty"`
+}
+
+// VerifyResponse represents a verification response
+type VerifyResponse struct {
+	Valid  bool        `json:"valid"`
+	Checks VerifyCheck `json:"checks"`
+
+	// Anchored reports whether the event hash is committed in a stored Merkle
+	// root, i.e. it was actually ingested. Only set when VERIFY_ANCHORED=true;
+	// it does not affect Valid, which covers internal consistency.
+	Anchored *bool `json:"anchored,omitempty"`
+}
+
+// VerifyDryRunResponse extends VerifyResponse with the intermediate values used
+// during verification, so SDK authors can debug hash mismatches
+type VerifyDryRunResponse struct {
+	VerifyResponse
+	CanonicalForm string `json:"canonical_form"`
+	ComputedHash  string `json:"computed_hash"`
+	StoredHash    string `json:"stored_hash"`
+	HashAlgorithm string `json:"hash_algorithm"`
+
+	// CanonicalVersion is the version the form was built for, and
+	// CanonicalEncoding its encoding ("jcs", or "legacy" for v1 events)
+	CanonicalVersion  canonical.Version  `json:"canonical_version"`
+	CanonicalEncoding canonical.Encoding `json:"canonical_encoding"`
+}
+
+// VerifyCheck represents individual verification checks
+type VerifyCheck struct {
+	HashValid      bool  `json:"hash_valid"`
+	SignatureValid bool  `json:"signature_valid"`
+	ChainValid     *bool `json:"chain_valid"`
+}
+
+// VerifyEvent handles POST /v1/verify
+func (h *Handlers) VerifyEvent(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify").Observe(time.Since(start).Seconds())
+	}()
+
+	var req VerifyRequest
+	if err := bindEventsJSON(c, &req); err != nil {
+		respondError(c, "verify", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	response, err := h.verifyEvent(c.Request.Context(), &req.Event)
+	if err != nil {
+		respondError(c, "verify", http.StatusInternalServerError, CodeStorageError, "failed to look up Merkle roots")
+		return
+	}
+
+	if c.Query("diff") == "true" {
+		diff, err := buildVerifyDiff(&req.Event, req.SignedForm)
+		if errors.Is(err, canonical.ErrUnknownVersion) {
+			respondError(c, "verify", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		if err != nil {
+			respondError(c, "verify", http.StatusBadRequest, CodeInvalidRequest, "signed_form is not a JSON object")
+			return
+		}
+		apiRequestsTotal.WithLabelValues("verify", "200").Inc()
+		c.JSON(http.StatusOK, VerifyDiffResponse{VerifyResponse: response, Diff: diff})
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("verify", "200").Inc()
+
+	if c.Query("dry_run") == "true" {
+		form, version, err := canonicalForm(&req.Event)
+		if err != nil {
+			respondError(c, "verify", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, VerifyDryRunResponse{
+			VerifyResponse:    response,
+			CanonicalForm:     form,
+			ComputedHash:      computeHash(form),
+			StoredHash:        req.Event.Proof.EventHash,
+			HashAlgorithm:     "sha3-256",
+			CanonicalVersion:  version,
+			CanonicalEncoding: version.Encoding(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// verifyEvent checks an event's hash and signature and, with
+// VERIFY_ANCHORED, whether its hash is in a stored Merkle root. The error is
+// only from the anchor lookup.
+func (h *Handlers) verifyEvent(ctx context.Context, event *EventResponse) (VerifyResponse, error) {
+	hashValid := verifyHash(event)
+	signatureValid := verifySignature(event)
+
+	response := VerifyResponse{
+		Valid: hashValid && signatureValid,
+		Checks: VerifyCheck{
+			HashValid:      hashValid,
+			SignatureValid: signatureValid,
+			ChainValid:     nil, // Would need previous event to verify chain
+		},
+	}
+
+	if h.config.VerifyAnchored {
+		anchored, err := h.isEventAnchored(ctx, event.Proof.EventHash)
+		if err != nil {
+			return VerifyResponse{}, err
+		}
+		response.Anchored = &anchored
+	}
+	return response, nil
+}
+
+// VerifyBatchRequest represents a batch verification request
+type VerifyBatchRequest struct {
+	Events []EventResponse `json:"events" binding:"required"`
+}
+
+// VerifyBatchResponse holds one result per request event, in request order
+type VerifyBatchResponse struct {
+	Results []VerifyResponse   `json:"results"`
+	Summary VerifyBatchSummary `json:"summary"`
+}
+
+// VerifyBatchSummary counts the batch's results
+type VerifyBatchSummary struct {
+	Total   int `json:"total"`
+	Valid   int `json:"valid"`
+	Invalid int `json:"invalid"`
+}
+
+// VerifyBatch handles POST /v1/verify/batch. Events are verified
+// concurrently, VERIFY_CONCURRENCY at a time, and the results keep the
+// request order.
+func (h *Handlers) VerifyBatch(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify_batch").Observe(time.Since(start).Seconds())
+	}()
+
+	var req VerifyBatchRequest
+	if err := bindEventsJSON(c, &req); err != nil {
+		respondError(c, "verify_batch", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if len(req.Events) == 0 {
+		respondError(c, "verify_batch", http.StatusBadRequest, CodeInvalidRequest, "events must not be empty")
+		return
+	}
+
+	if len(req.Events) > h.config.MaxBatchVerifyEvents {
+		respondErrorDetails(c, "verify_batch", http.StatusRequestEntityTooLarge, CodeTooLarge,
+			fmt.Sprintf("batch has %d events, maximum is %d", len(req.Events), h.config.MaxBatchVerifyEvents),
+			gin.H{"event_count": len(req.Events), "limit": h.config.MaxBatchVerifyEvents})
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := make([]VerifyResponse, len(req.Events))
+	errs := make([]error, len(req.Events))
+
+	sem := make(chan struct{}, h.config.VerifyConcurrency)
+	var wg sync.WaitGroup
+	for i := range req.Events {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = h.verifyEvent(ctx, &req.Events[i])
+		}(i)
+	}
+	wg.Wait()
+
+	response := VerifyBatchResponse{
+		Results: results,
+		Summary: VerifyBatchSummary{Total: len(results)},
+	}
+	for i, result := range results {
+		if errs[i] != nil {
+			respondError(c, "verify_batch", http.StatusInternalServerError, CodeStorageError, "failed to look up Merkle roots")
+			return
+		}
+		if result.Valid {
+			response.Summary.Valid++
+		} else {
+			response.Summary.Invalid++
+		}
+	}
+
+	apiRequestsTotal.WithLabelValues("verify_batch", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// ChainVerifyQuery represents query parameters for chain verification
+type ChainVerifyQuery struct {
+	SessionID string `form:"session_id" binding:"required"`
+
+	// Genesis, when set, is the prev_hash the first event must carry.
+	// Otherwise the first event's own prev_hash anchors the chain.
+	Genesis string `form:"genesis"`
+}
+
+// ChainVerifyResponse represents the chain verification result
+type ChainVerifyResponse struct {
+	Valid       bool              `json:"valid"`
+	EventCount  int               `json:"event_count"`
+	Checks      ChainVerifyChecks `json:"checks"`
+	FirstEvent  string            `json:"first_event,omitempty"`
+	LastEvent   string            `json:"last_event,omitempty"`
+	SessionHash string            `json:"session_hash,omitempty"`
+	Errors      []string          `json:"errors,omitempty"`
+	Warnings    []ClockWarning    `json:"warnings,omitempty"`
+
+	// OrphanLinks lists events whose prev_hash matches no event_hash in the
+	// session (REJECT_ORPHAN_LINKS=true only)
+	OrphanLinks []string `json:"orphan_links,omitempty"`
+}
+
+// ChainVerifyChecks represents individual chain verification checks
+type ChainVerifyChecks struct {
+	AllHashesValid      bool `json:"all_hashes_valid"`
+	AllSignaturesValid  bool `json:"all_signatures_valid"`
+	ChainIntegrityValid bool `json:"chain_integrity_valid"`
+
+	// NoOrphanLinks is set only with REJECT_ORPHAN_LINKS=true
+	NoOrphanLinks *bool `json:"no_orphan_links,omitempty"`
+}
+
+// VerifyChain handles GET /v1/verify/chain
+func (h *Handlers) VerifyChain(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify_chain").Observe(time.Since(start).Seconds())
+	}()
+
+	var query ChainVerifyQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "verify_chain", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if query.Genesis != "" && !isHexHash(query.Genesis) {
+		respondError(c, "verify_chain", http.StatusBadRequest, CodeInvalidHash, "genesis must be a 64-character hex hash")
+		return
+	}
+
+	if !h.checkSessionSize(c, "verify_chain", query.SessionID) {
+		return
+	}
+
+	// Get all events for the session
+	events, _, err := h.storage.GetSessionEvents(c.Request.Context(), query.SessionID, 10000, "", OrderAsc)
+	if err != nil {
+		respondError(c, "verify_chain", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	}
+
+	if len(events) == 0 {
+		respondError(c, "verify_chain", http.StatusNotFound, CodeNotFound, "no events found for session")
+		return
+	}
+
+	if h.rejectForbidden(c, "verify_chain", eventAgentIDs(events)...) {
+		return
+	}
+
+	_, span := tracer.Start(c.Request.Context(), "verifyChainEvents",
+		trace.WithAttributes(attribute.Int("facto.event_count", len(events))))
+	response := verifyChainEvents(events, query.Genesis, h.config.RejectOrphanLinks)
+	span.SetAttributes(attribute.Bool("facto.valid", response.Valid))
+	span.End()
+	response.Warnings = checkClockSkew(events, h.config.ClockSkewTolerance)
+
+	apiRequestsTotal.WithLabelValues("verify_chain", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// BatchChainVerifyQuery represents query parameters for batch chain verification
+type BatchChainVerifyQuery struct {
+	AgentID string `form:"agent_id" binding:"required"`
+	Date    string `form:"date" binding:"required"`
+}
+
+// BatchChainVerifyResponse represents the verification of all session chains
+// of an agent on one day
+type BatchChainVerifyResponse struct {
+	VerifiedSessions int                  `json:"verified_sessions"`
+	FailedSessions   []FailedSessionChain `json:"failed_sessions"`
+	AllValid         bool                 `json:"all_valid"`
+}
+
+// FailedSessionChain describes a session whose chain failed verification
+type FailedSessionChain struct {
+	SessionID string   `json:"session_id"`
+	Errors    []string `json:"errors"`
+}
+
+// VerifyBatchChain handles GET /v1/verify/batch-chain
+func (h *Handlers) VerifyBatchChain(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify_batch_chain").Observe(time.Since(start).Seconds())
+	}()
+
+	var query BatchChainVerifyQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "verify_batch_chain", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", query.Date)
+	if err != nil {
+		respondError(c, "verify_batch_chain", http.StatusBadRequest, CodeInvalidDateFormat, "invalid date format, expected YYYY-MM-DD")
+		return
+	}
+
+	ctx := c.Request.Context()
+	sessionIDs, err := h.storage.GetAgentSessionIDs(ctx, query.AgentID, date)
+	if err != nil {
+		respondError(c, "verify_batch_chain", http.StatusInternalServerError, CodeStorageError, "failed to fetch sessions")
+		return
+	}
+
+	// Verify sessions concurrently, capped at CHAIN_VERIFY_CONCURRENCY
+	results := make([]*FailedSessionChain, len(sessionIDs))
+	sem := make(chan struct{}, h.config.ChainVerifyConcurrency)
+	var wg sync.WaitGroup
+	for i, sessionID := range sessionIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, sessionID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			events, _, err := h.storage.GetSessionEvents(ctx, sessionID, 10000, "", OrderAsc)
+			if err != nil {
+				results[i] = &FailedSessionChain{SessionID: sessionID, Errors: []string{"failed to fetch events"}}
+				return
+			}
+			if len(events) == 0 {
+				return
+			}
+			if chain := verifyChainEvents(events, "", h.config.RejectOrphanLinks); !chain.Valid {
+				results[i] = &FailedSessionChain{SessionID: sessionID, Errors: chain.Errors}
+			}
+		}(i, sessionID)
+	}
+	wg.Wait()
+
+	response := BatchChainVerifyResponse{
+		VerifiedSessions: len(sessionIDs),
+		FailedSessions:   []FailedSessionChain{},
+	}
+	for _, failed := range results {
+		if failed != nil {
+			response.FailedSessions = append(response.FailedSessions, *failed)
+		}
+	}
+	response.AllValid = len(response.FailedSessions) == 0
+
+	apiRequestsTotal.WithLabelValues("verify_batch_chain", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// verifyChainEvents checks hashes, signatures and prev_hash links of a
+// session's events. events must be non-empty; it is sorted in place.
+func verifyChainEvents(events []EventResponse, genesis string, rejectOrphans bool) ChainVerifyResponse {
+	// Sort events by completed_at (oldest first for chain verification)
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CompletedAt < events[j].CompletedAt
+	})
+
+	response := ChainVerifyResponse{
+		EventCount: len(events),
+		FirstEvent: events[0].FactoID,
 		LastEvent:  events[len(events)-1].FactoID,
 		Checks: ChainVerifyChecks{
 			AllHashesValid:      true,
@@ -328,325 +1186,1454 @@ func (h *Handlers) VerifyChain(c *gin.Context) {
 		Errors: []string{},
 	}
 
-	// Verify all hashes
-	for _, event := range events {
-		if !verifyHash(&event) {
-			response.Checks.AllHashesValid = false
-			response.Errors = append(response.Errors, "Hash invalid for event: "+event.FactoID)
-		}
+	// Verify all hashes
+	for _, event := range events {
+		if !verifyHash(&event) {
+			response.Checks.AllHashesValid = false
+			response.Errors = append(response.Errors, "Hash invalid for event: "+event.FactoID)
+		}
+	}
+
+	// Verify all signatures
+	for _, event := range events {
+		if !verifySignature(&event) {
+			response.Checks.AllSignaturesValid = false
+			response.Errors = append(response.Errors, "Signature invalid for event: "+event.FactoID)
+		}
+	}
+
+	// Verify chain integrity (prev_hash links). Chains may start mid-stream,
+	// so the first event is only checked against an explicit genesis.
+	if genesis != "" && events[0].Proof.PrevHash != genesis {
+		response.Checks.ChainIntegrityValid = false
+		response.Errors = append(response.Errors,
+			"Genesis mismatch at event: "+events[0].FactoID+
+				" (expected prev_hash: "+shortHash(genesis)+", got: "+shortHash(events[0].Proof.PrevHash)+")")
+	}
+	var sessionHashes map[string]bool
+	if rejectOrphans {
+		sessionHashes = make(map[string]bool, len(events))
+		for _, event := range events {
+			sessionHashes[event.Proof.EventHash] = true
+		}
+		noOrphans := true
+		response.Checks.NoOrphanLinks = &noOrphans
+	}
+	for i := 1; i < len(events); i++ {
+		expectedPrevHash := events[i-1].Proof.EventHash
+		if events[i].Proof.PrevHash == expectedPrevHash {
+			continue
+		}
+		response.Checks.ChainIntegrityValid = false
+
+		// A prev_hash naming no event of the session points at another
+		// session's chain or a fabricated hash, not just a reordered link
+		if rejectOrphans && !sessionHashes[events[i].Proof.PrevHash] {
+			*response.Checks.NoOrphanLinks = false
+			response.OrphanLinks = append(response.OrphanLinks, events[i].FactoID)
+			response.Errors = append(response.Errors,
+				"Orphan link at event: "+events[i].FactoID+
+					" (prev_hash "+shortHash(events[i].Proof.PrevHash)+" matches no event in session)")
+			continue
+		}
+		response.Errors = append(response.Errors,
+			"Chain link broken at event: "+events[i].FactoID+
+				" (expected prev_hash: "+shortHash(expectedPrevHash)+", got: "+shortHash(events[i].Proof.PrevHash)+")")
+	}
+
+	response.SessionHash = computeSessionHash(events)
+
+	// Overall validity
+	response.Valid = response.Checks.AllHashesValid &&
+		response.Checks.AllSignaturesValid &&
+		response.Checks.ChainIntegrityValid
+
+	return response
+}
+
+// shortHash abbreviates a hash for error messages
+func shortHash(hash string) string {
+	if len(hash) <= 16 {
+		return hash
+	}
+	return hash[:16] + "..."
+}
+
+// EventVerifyResult represents the verification result of a single event
+type EventVerifyResult struct {
+	FactoID        string `json:"facto_id"`
+	HashValid      bool   `json:"hash_valid"`
+	SignatureValid bool   `json:"signature_valid"`
+	LinkValid      bool   `json:"link_valid"`
+
+	// CanonicalDrift is set when a canonical form snapshot was stored at
+	// ingest: true means today's canonicalization no longer reproduces it, so
+	// a hash mismatch may come from a canonicalization change, not tampering
+	CanonicalDrift *bool `json:"canonical_drift,omitempty"`
+}
+
+// SessionVerifyResponse represents per-event verification of a session
+type SessionVerifyResponse struct {
+	SessionID    string              `json:"session_id"`
+	Valid        bool                `json:"valid"`
+	EventCount   int                 `json:"event_count"`
+	InvalidCount int                 `json:"invalid_count"`
+	Events       []EventVerifyResult `json:"events"`
+	Warnings     []ClockWarning      `json:"warnings,omitempty"`
+}
+
+// VerifySessionEvents handles GET /v1/sessions/:session_id/verify
+// Unlike VerifyChain it reports the outcome of every event individually.
+func (h *Handlers) VerifySessionEvents(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify_session").Observe(time.Since(start).Seconds())
+	}()
+
+	sessionID := c.Param("session_id")
+
+	if !h.checkSessionSize(c, "verify_session", sessionID) {
+		return
+	}
+
+	events, _, err := h.storage.GetSessionEvents(c.Request.Context(), sessionID, 10000, "", OrderAsc)
+	if err != nil {
+		respondError(c, "verify_session", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	}
+
+	if len(events) == 0 {
+		respondError(c, "verify_session", http.StatusNotFound, CodeNotFound, "no events found for session")
+		return
+	}
+
+	if h.rejectForbidden(c, "verify_session", eventAgentIDs(events)...) {
+		return
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CompletedAt < events[j].CompletedAt
+	})
+
+	_, span := tracer.Start(c.Request.Context(), "verifySessionEvents",
+		trace.WithAttributes(attribute.Int("facto.event_count", len(events))))
+	results := make([]EventVerifyResult, len(events))
+	sem := make(chan struct{}, h.config.VerifyConcurrency)
+	var wg sync.WaitGroup
+	for i := range events {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// The first event anchors the chain, so its link is valid by definition
+			linkValid := i == 0 || events[i].Proof.PrevHash == events[i-1].Proof.EventHash
+			results[i] = EventVerifyResult{
+				FactoID:        events[i].FactoID,
+				HashValid:      verifyHash(&events[i]),
+				SignatureValid: verifySignature(&events[i]),
+				LinkValid:      linkValid,
+			}
+			results[i].CanonicalDrift = checkCanonicalDrift(&events[i])
+		}(i)
+	}
+	wg.Wait()
+	span.End()
+
+	response := SessionVerifyResponse{
+		SessionID:  sessionID,
+		EventCount: len(results),
+		Events:     results,
+	}
+	for _, r := range results {
+		if !r.HashValid || !r.SignatureValid || !r.LinkValid {
+			response.InvalidCount++
+		}
+	}
+	response.Valid = response.InvalidCount == 0
+	response.Warnings = checkClockSkew(events, h.config.ClockSkewTolerance)
+
+	apiRequestsTotal.WithLabelValues("verify_session", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// SessionSummaryResponse represents aggregates over a session's events
+type SessionSummaryResponse struct {
+	SessionID   string            `json:"session_id"`
+	AgentID     string            `json:"agent_id"`
+	EventCount  int               `json:"event_count"`
+	ActionTypes map[string]int    `json:"action_types"`
+	Statuses    map[string]int    `json:"statuses"`
+	Models      []string          `json:"models"`
+	StartedAt   int64             `json:"started_at"`
+	CompletedAt int64             `json:"completed_at"`
+	DurationMs  int64             `json:"duration_ms"`
+	ChainValid  bool              `json:"chain_valid"`
+	Chain       ChainVerifyChecks `json:"chain"`
+}
+
+// GetSessionSummary handles GET /v1/sessions/:session_id/summary
+// Aggregates the session's events in one fetch: counts per action_type and
+// status, distinct model_ids, the span from the earliest started_at to the
+// latest completed_at, and the /v1/verify/chain result.
+func (h *Handlers) GetSessionSummary(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("session_summary").Observe(time.Since(start).Seconds())
+	}()
+
+	sessionID := c.Param("session_id")
+
+	if !h.checkSessionSize(c, "session_summary", sessionID) {
+		return
+	}
+
+	events, _, err := h.storage.GetSessionEvents(c.Request.Context(), sessionID, 10000, "", OrderAsc)
+	if err != nil {
+		respondError(c, "session_summary", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	}
+
+	if len(events) == 0 {
+		respondError(c, "session_summary", http.StatusNotFound, CodeNotFound, "no events found for session")
+		return
+	}
+
+	if h.rejectForbidden(c, "session_summary", eventAgentIDs(events)...) {
+		return
+	}
+
+	response := summarizeSession(events)
+	response.SessionID = sessionID
+
+	chain := verifyChainEvents(events, "", h.config.RejectOrphanLinks)
+	response.ChainValid = chain.Valid
+	response.Chain = chain.Checks
+
+	apiRequestsTotal.WithLabelValues("session_summary", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// summarizeSession computes the per-event aggregates of a session summary.
+// events must be non-empty.
+func summarizeSession(events []EventResponse) SessionSummaryResponse {
+	summary := SessionSummaryResponse{
+		AgentID:     events[0].AgentID,
+		EventCount:  len(events),
+		ActionTypes: make(map[string]int),
+		Statuses:    make(map[string]int),
+		Models:      []string{},
+		StartedAt:   events[0].StartedAt,
+		CompletedAt: events[0].CompletedAt,
+	}
+
+	models := make(map[string]bool)
+	for _, e := range events {
+		summary.ActionTypes[e.ActionType]++
+		summary.Statuses[e.Status]++
+		if e.ExecutionMeta.ModelID != nil && *e.ExecutionMeta.ModelID != "" && !models[*e.ExecutionMeta.ModelID] {
+			models[*e.ExecutionMeta.ModelID] = true
+			summary.Models = append(summary.Models, *e.ExecutionMeta.ModelID)
+		}
+		if e.StartedAt < summary.StartedAt {
+			summary.StartedAt = e.StartedAt
+		}
+		if e.CompletedAt > summary.CompletedAt {
+			summary.CompletedAt = e.CompletedAt
+		}
+	}
+	sort.Strings(summary.Models)
+
+	summary.DurationMs = time.Duration(summary.CompletedAt - summary.StartedAt).Milliseconds()
+	return summary
+}
+
+// checkCanonicalDrift compares an event's stored canonical form snapshot with
+// the form rebuilt now, returning nil when no snapshot was stored
+func checkCanonicalDrift(event *EventResponse) *bool {
+	if event.StoredCanonicalForm == "" {
+		return nil
+	}
+	form, _, err := canonicalForm(event)
+	drift := err != nil || form != event.StoredCanonicalForm
+	return &drift
+}
+
+// ClockWarning flags an event the processor received before its claimed
+// completion time, which points to a manipulated client clock
+type ClockWarning struct {
+	FactoID     string `json:"facto_id"`
+	Warning     string `json:"warning"`
+	CompletedAt int64  `json:"completed_at"`
+	ReceivedAt  int64  `json:"received_at"`
+	DeltaMs     int64  `json:"delta_ms"`
+}
+
+// checkClockSkew returns a warning for every event whose received_at precedes
+// completed_at by more than tolerance. Warnings don't affect validity: the
+// signature still proves who produced the event, only its timing is suspect.
+func checkClockSkew(events []EventResponse, tolerance time.Duration) []ClockWarning {
+	var warnings []ClockWarning
+	for _, e := range events {
+		if e.ReceivedAt == 0 {
+			continue
+		}
+		delta := time.Duration(e.CompletedAt - e.ReceivedAt)
+		if delta > tolerance {
+			warnings = append(warnings, ClockWarning{
+				FactoID:     e.FactoID,
+				Warning:     "received_at precedes completed_at",
+				CompletedAt: e.CompletedAt,
+				ReceivedAt:  e.ReceivedAt,
+				DeltaMs:     delta.Milliseconds(),
+			})
+		}
+	}
+	return warnings
+}
+
+// EvidencePackageQuery represents query parameters for evidence package
+type EvidencePackageQuery struct {
+	SessionID string `form:"session_id" binding:"required"`
+
+	// Format is "json" (default) or "zip" for a downloadable archive
+	Format string `form:"format"`
+}
+
+// EvidencePackageResponse represents an evidence package
+type EvidencePackageResponse struct {
+	PackageID                string          `json:"package_id"`
+	SessionID                string          `json:"session_id"`
+	Events                   []EventResponse `json:"events"`
+	MerkleProofs             []MerkleProof   `json:"merkle_proofs"`
+	ExportedAt               string          `json:"exported_at"`
+	VerificationInstructions string          `json:"verification_instructions"`
+}
+
+// MerkleProof represents a Merkle proof for an event. Scheme is the tree
+// scheme the proof is checked with; proofs without one are legacy.
+type MerkleProof struct {
+	FactoID   string                `json:"facto_id"`
+	EventHash string                `json:"event_hash"`
+	Proof     []merkle.ProofElement `json:"proof"`
+	Root      string                `json:"root"`
+	Scheme    merkle.Scheme         `json:"scheme,omitempty"`
+}
+
+// GetEvidencePackage handles GET /v1/evidence-package
+func (h *Handlers) GetEvidencePackage(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("evidence_package").Observe(time.Since(start).Seconds())
+	}()
+
+	var query EvidencePackageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "evidence_package", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if query.Format != "" && query.Format != "json" && query.Format != "zip" {
+		respondError(c, "evidence_package", http.StatusBadRequest, CodeInvalidFormat, "format must be json or zip")
+		return
+	}
+
+	if !h.checkSessionSize(c, "evidence_package", query.SessionID) {
+		return
+	}
+
+	// Get all events for the session
+	events, _, err := h.storage.GetSessionEvents(c.Request.Context(), query.SessionID, 10000, "", OrderAsc)
+	if err != nil {
+		respondError(c, "evidence_package", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+		return
+	}
+
+	if len(events) == 0 {
+		respondError(c, "evidence_package", http.StatusNotFound, CodeNotFound, "no events found for session")
+		return
+	}
+
+	if h.rejectForbidden(c, "evidence_package", eventAgentIDs(events)...) {
+		return
+	}
+
+	// Build Merkle tree and proofs
+	hashes := make([]string, len(events))
+	for i, e := range events {
+		hashes[i] = e.Proof.EventHash
+	}
+
+	buildCtx := c.Request.Context()
+	if h.config.EvidenceBuildTimeout > 0 {
+		var cancel context.CancelFunc
+		buildCtx, cancel = context.WithTimeout(buildCtx, h.config.EvidenceBuildTimeout)
+		defer cancel()
+	}
+
+	tree, err := h.treeCache.get(buildCtx, query.SessionID, hashes)
+	if err != nil {
+		h.abortEvidenceBuild(c, query.SessionID, err)
+		return
+	}
+	merkleRoot := tree.Root()
+
+	proofs := make([]MerkleProof, len(events))
+	for i, e := range events {
+		proof, err := tree.Proof(buildCtx, i)
+		if err != nil {
+			h.abortEvidenceBuild(c, query.SessionID, err)
+			return
+		}
+		proofs[i] = MerkleProof{
+			FactoID:   e.FactoID,
+			EventHash: e.Proof.EventHash,
+			Proof:     proof,
+			Root:      merkleRoot,
+			Scheme:    h.config.MerkleScheme,
+		}
+	}
+
+	// Generate package ID
+	packageHash := sha256.Sum256([]byte(query.SessionID + time.Now().String()))
+	packageID := "ev-" + hex.EncodeToString(packageHash[:8])
+
+	response := EvidencePackageResponse{
+		PackageID:                packageID,
+		SessionID:                query.SessionID,
+		Events:                   events,
+		MerkleProofs:             proofs,
+		ExportedAt:               time.Now().UTC().Format(time.RFC3339),
+		VerificationInstructions: evidenceInstructions(events, proofs),
+	}
+
+	if query.Format == "zip" {
+		writeEvidenceZip(c, &response, merkleRoot)
+		apiRequestsTotal.WithLabelValues("evidence_package", "200").Inc()
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("evidence_package", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// EvidencePackageVerifyResponse is the result of verifying an evidence package
+type EvidencePackageVerifyResponse struct {
+	PackageID   string              `json:"package_id"`
+	SessionID   string              `json:"session_id"`
+	Valid       bool                `json:"valid"`
+	Chain       ChainVerifyResponse `json:"chain"`
+	ProofsValid bool                `json:"proofs_valid"`
+	Errors      []string            `json:"errors,omitempty"`
+	Live        *LiveComparison     `json:"live,omitempty"`
+
+	// Events has one result per packaged event, in package order
+	Events []PackageEventResult `json:"events"`
+}
+
+// PackageEventResult is the verification of one evidence package event
+type PackageEventResult struct {
+	FactoID        string `json:"facto_id"`
+	HashValid      bool   `json:"hash_valid"`
+	SignatureValid bool   `json:"signature_valid"`
+	ProofValid     bool   `json:"proof_valid"`
+}
+
+// LiveComparison reports how a package's events differ from the store
+type LiveComparison struct {
+	Matches    bool           `json:"matches"`
+	Mismatched []LiveMismatch `json:"mismatched"`
+	Missing    []string       `json:"missing"`
+}
+
+// LiveMismatch is a packaged event whose stored event_hash differs
+type LiveMismatch struct {
+	FactoID     string `json:"facto_id"`
+	PackageHash string `json:"package_hash"`
+	StoredHash  string `json:"stored_hash"`
+}
+
+// VerifyEvidencePackage handles POST /v1/evidence-package/verify and its
+// deprecated alias POST /v1/verify/evidence-package
+//
+// The body is an evidence package as returned by GET /v1/evidence-package.
+// Hashes, signatures, prev_hash links and Merkle proofs are checked within
+// the package, and reported per event as well as overall. With live=true
+// each event is also re-fetched by facto_id and its stored event_hash
+// compared, so silent divergence between an exported package and the store
+// is reported.
+func (h *Handlers) VerifyEvidencePackage(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify_evidence_package").Observe(time.Since(start).Seconds())
+	}()
+
+	var pkg EvidencePackageResponse
+	if err := bindEventsJSON(c, &pkg); err != nil {
+		respondError(c, "verify_evidence_package", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if len(pkg.Events) == 0 {
+		respondError(c, "verify_evidence_package", http.StatusBadRequest, CodeInvalidRequest, "evidence package has no events")
+		return
+	}
+
+	if h.config.MaxEventsPerSession > 0 && int64(len(pkg.Events)) > h.config.MaxEventsPerSession {
+		respondErrorDetails(c, "verify_evidence_package", http.StatusRequestEntityTooLarge, CodeTooLarge,
+			fmt.Sprintf("evidence package has %d events, maximum is %d", len(pkg.Events), h.config.MaxEventsPerSession),
+			gin.H{"event_count": len(pkg.Events), "limit": h.config.MaxEventsPerSession})
+		return
+	}
+
+	for _, e := range pkg.Events {
+		if !isHexHash(e.Proof.EventHash) || !isHexHash(e.Proof.PrevHash) {
+			respondError(c, "verify_evidence_package", http.StatusBadRequest, CodeInvalidHash, "malformed event_hash or prev_hash for event: "+e.FactoID)
+			return
+		}
+	}
+
+	response := EvidencePackageVerifyResponse{
+		PackageID: pkg.PackageID,
+		SessionID: pkg.SessionID,
+	}
+
+	// verifyChainEvents sorts in place; keep the package order for proofs
+	events := make([]EventResponse, len(pkg.Events))
+	copy(events, pkg.Events)
+	response.Chain = verifyChainEvents(events, "", h.config.RejectOrphanLinks)
+
+	proofsValid, proofErrs := verifyPackageProofs(pkg.Events, pkg.MerkleProofs)
+	response.Errors = proofErrs
+	response.ProofsValid = len(response.Errors) == 0
+
+	response.Events = make([]PackageEventResult, len(pkg.Events))
+	sem := make(chan struct{}, h.config.VerifyConcurrency)
+	var wg sync.WaitGroup
+	for i := range pkg.Events {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			response.Events[i] = PackageEventResult{
+				FactoID:        pkg.Events[i].FactoID,
+				HashValid:      verifyHash(&pkg.Events[i]),
+				SignatureValid: verifySignature(&pkg.Events[i]),
+				ProofValid:     proofsValid[i],
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if c.Query("live") == "true" {
+		live, err := h.compareLive(c.Request.Context(), pkg.Events)
+		if err != nil {
+			respondError(c, "verify_evidence_package", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+			return
+		}
+		response.Live = live
+	}
+
+	response.Valid = response.Chain.Valid && response.ProofsValid &&
+		(response.Live == nil || response.Live.Matches)
+
+	apiRequestsTotal.WithLabelValues("verify_evidence_package", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// verifyPackageProofs checks that every event has a Merkle proof for its
+// hash and that all proofs lead to the same root. It returns whether each
+// event's proof passed, in event order, and any errors.
+func verifyPackageProofs(events []EventResponse, proofs []MerkleProof) ([]bool, []string) {
+	var errs []string
+	valid := make([]bool, len(events))
+
+	if len(proofs) != len(events) {
+		errs = append(errs, fmt.Sprintf("Package has %d events but %d Merkle proofs", len(events), len(proofs)))
+	}
+
+	byFactoID := make(map[string]MerkleProof, len(proofs))
+	for _, p := range proofs {
+		byFactoID[p.FactoID] = p
+	}
+
+	root := ""
+	for i, e := range events {
+		p, ok := byFactoID[e.FactoID]
+		if !ok {
+			errs = append(errs, "Missing Merkle proof for event: "+e.FactoID)
+			continue
+		}
+		if p.EventHash != e.Proof.EventHash {
+			errs = append(errs, "Merkle proof hash does not match event: "+e.FactoID)
+			continue
+		}
+
+		scheme, err := merkle.ParseScheme(string(p.Scheme))
+		if err != nil {
+			errs = append(errs, "Malformed Merkle proof for event: "+e.FactoID+" ("+err.Error()+")")
+			continue
+		}
+		matches, err := merkle.Verify(scheme, p.EventHash, p.Proof, p.Root)
+		if err != nil {
+			errs = append(errs, "Malformed Merkle proof for event: "+e.FactoID+" ("+err.Error()+")")
+			continue
+		}
+		if !matches {
+			errs = append(errs, "Merkle proof invalid for event: "+e.FactoID)
+			continue
+		}
+
+		if root == "" {
+			root = p.Root
+		} else if p.Root != root {
+			errs = append(errs, "Merkle proof root differs from package root for event: "+e.FactoID)
+			continue
+		}
+		valid[i] = true
+	}
+
+	return valid, errs
+}
+
+// compareLive re-fetches each event by facto_id and compares event hashes
+// with the store
+func (h *Handlers) compareLive(ctx context.Context, events []EventResponse) (*LiveComparison, error) {
+	stored := make([]*EventResponse, len(events))
+	errs := make([]error, len(events))
+
+	sem := make(chan struct{}, h.config.VerifyConcurrency)
+	var wg sync.WaitGroup
+	for i, e := range events {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, factoID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stored[i], errs[i] = h.storage.GetEventByFactoID(ctx, factoID)
+		}(i, e.FactoID)
+	}
+	wg.Wait()
+
+	live := &LiveComparison{
+		Mismatched: []LiveMismatch{},
+		Missing:    []string{},
+	}
+	for i, e := range events {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		switch {
+		case stored[i] == nil:
+			live.Missing = append(live.Missing, e.FactoID)
+		case stored[i].Proof.EventHash != e.Proof.EventHash:
+			live.Mismatched = append(live.Mismatched, LiveMismatch{
+				FactoID:     e.FactoID,
+				PackageHash: e.Proof.EventHash,
+				StoredHash:  stored[i].Proof.EventHash,
+			})
+		}
+	}
+	live.Matches = len(live.Missing) == 0 && len(live.Mismatched) == 0
+
+	return live, nil
+}
+
+// abortEvidenceBuild responds to an evidence package Merkle build stopped by
+// EVIDENCE_BUILD_TIMEOUT_MS or by the client going away
+func (h *Handlers) abortEvidenceBuild(c *gin.Context, sessionID string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Warn().Str("session_id", sessionID).Dur("timeout", h.config.EvidenceBuildTimeout).Msg("Evidence package Merkle build timed out")
+		respondError(c, "evidence_package", http.StatusServiceUnavailable, CodeTimeout, "evidence package build timed out")
+		return
+	}
+
+	// Cancelled by the client; nobody is left to read a response
+	apiRequestsTotal.WithLabelValues("evidence_package", "499").Inc()
+	c.Abort()
+}
+
+// MerkleProofVerifyRequest is the body of POST /v1/verify/merkle-proof.
+// Scheme defaults to legacy.
+type MerkleProofVerifyRequest struct {
+	LeafHash string                `json:"leaf_hash" binding:"required"`
+	Proof    []merkle.ProofElement `json:"proof"`
+	Root     string                `json:"root" binding:"required"`
+	Scheme   string                `json:"scheme"`
+}
+
+// MerkleProofVerifyResponse represents the result of a Merkle proof check.
+// Trace starts with the leaf itself; with the rfc6962 scheme a hash_leaf
+// step follows, and each later step is a node hash over the hex-decoded
+// inputs (see package merkle).
+type MerkleProofVerifyResponse struct {
+	Valid        bool               `json:"valid"`
+	ComputedRoot string             `json:"computed_root"`
+	Scheme       merkle.Scheme      `json:"scheme"`
+	Trace        []merkle.TraceStep `json:"trace,omitempty"`
+}
+
+// VerifyMerkleProof handles POST /v1/verify/merkle-proof
+// Recomputes the root from a leaf hash and its proof path. With trace=true the
+// response lists every intermediate hash so auditors can follow the
+// computation by hand.
+func (h *Handlers) VerifyMerkleProof(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify_merkle_proof").Observe(time.Since(start).Seconds())
+	}()
+
+	var req MerkleProofVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, "verify_merkle_proof", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if !isHexHash(req.LeafHash) || !isHexHash(req.Root) {
+		respondError(c, "verify_merkle_proof", http.StatusBadRequest, CodeInvalidHash, "leaf_hash and root must be 64-character hex hashes")
+		return
+	}
+	for i, element := range req.Proof {
+		if !isHexHash(element.Hash) {
+			respondError(c, "verify_merkle_proof", http.StatusBadRequest, CodeInvalidHash, fmt.Sprintf("malformed hash at proof element %d", i))
+			return
+		}
+	}
+
+	scheme, err := merkle.ParseScheme(req.Scheme)
+	if err != nil {
+		respondError(c, "verify_merkle_proof", http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	trace, err := merkle.Trace(scheme, req.LeafHash, req.Proof)
+	if err != nil {
+		respondError(c, "verify_merkle_proof", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	computed := trace[len(trace)-1].Hash
+	response := MerkleProofVerifyResponse{
+		Valid:        computed == req.Root,
+		ComputedRoot: computed,
+		Scheme:       scheme,
+	}
+	if c.Query("trace") == "true" {
+		response.Trace = trace
+	}
+
+	apiRequestsTotal.WithLabelValues("verify_merkle_proof", "200").Inc()
+	c.JSON(http.StatusOK, response)
+}
+
+// EventProofResponse is an event's inclusion proof in a stored Merkle root
+type EventProofResponse struct {
+	MerkleProof
+	RootType   string    `json:"root_type"`
+	BucketTime time.Time `json:"bucket_time"`
+	LeafIndex  int       `json:"leaf_index"`
+	EventCount int       `json:"event_count"`
+}
+
+// rootTypePreference orders the roots an event proof is served from: the
+// processor's own batch root, then the daily root that replaces it once
+// pruned, then an interval root
+var rootTypePreference = []string{RootTypeBatch, RootTypeDaily, RootTypeInterval}
+
+// GetEventProof handles GET /v1/events/:facto_id/proof
+// Proves a single event's inclusion in the stored Merkle root committing it,
+// without the session-wide tree an evidence package builds. root_type picks
+// a root when the event is committed by several (batch, interval, daily).
+func (h *Handlers) GetEventProof(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("get_event_proof").Observe(time.Since(start).Seconds())
+	}()
+
+	factoID := c.Param("facto_id")
+	if !factoid.Valid(factoID) {
+		respondError(c, "get_event_proof", http.StatusBadRequest, CodeInvalidID, "malformed facto_id")
+		return
+	}
+
+	ctx := c.Request.Context()
+	event, err := h.storage.GetEventByFactoID(ctx, factoID)
+	if err != nil {
+		respondError(c, "get_event_proof", http.StatusInternalServerError, CodeStorageError, "failed to fetch event")
+		return
+	}
+	if event == nil {
+		respondError(c, "get_event_proof", http.StatusNotFound, CodeNotFound, "event not found")
+		return
+	}
+
+	if h.rejectForbidden(c, "get_event_proof", event.AgentID) {
+		return
+	}
+
+	refs, err := h.storage.GetEventRootRefs(ctx, event.Proof.EventHash)
+	if err != nil {
+		respondError(c, "get_event_proof", http.StatusInternalServerError, CodeStorageError, "failed to fetch Merkle roots")
+		return
+	}
+
+	preference := rootTypePreference
+	if rootType := c.Query("root_type"); rootType != "" {
+		preference = []string{rootType}
+	}
+	var ref *EventRootRef
+	for _, rootType := range preference {
+		for i := range refs {
+			if refs[i].RootType == rootType {
+				ref = &refs[i]
+				break
+			}
+		}
+		if ref != nil {
+			break
+		}
+	}
+	if ref == nil {
+		respondError(c, "get_event_proof", http.StatusNotFound, CodeNotAnchored, "event is not yet anchored in a Merkle root")
+		return
+	}
+
+	getRoot := h.storage.GetMerkleRoot
+	if ref.RootType == RootTypeInterval {
+		getRoot = h.storage.GetIntervalRoot
+	}
+	root, err := getRoot(ctx, ref.Date, ref.BucketTime)
+	if err != nil {
+		respondError(c, "get_event_proof", http.StatusInternalServerError, CodeStorageError, "failed to fetch Merkle root")
+		return
+	}
+	if root == nil || root.RootHash != ref.RootHash ||
+		ref.LeafIndex >= len(root.EventHashes) || root.EventHashes[ref.LeafIndex] != event.Proof.EventHash {
+		// The index row outlived or disagrees with its root, e.g. a pruned
+		// batch root whose index cleanup was interrupted
+		respondError(c, "get_event_proof", http.StatusNotFound, CodeNotAnchored, "event is not yet anchored in a Merkle root")
+		return
+	}
+
+	scheme, err := merkle.ParseScheme(root.TreeScheme)
+	if err != nil {
+		log.Error().Err(err).Str("root_hash", root.RootHash).Msg("Stored Merkle root has an unknown tree scheme")
+		respondError(c, "get_event_proof", http.StatusInternalServerError, CodeCorruptData, "stored Merkle root has an unknown tree scheme")
+		return
+	}
+	tree, err := merkle.Build(ctx, root.EventHashes, scheme)
+	var proof []merkle.ProofElement
+	if err == nil {
+		proof, err = tree.Proof(ctx, ref.LeafIndex)
+	}
+	if err != nil {
+		respondError(c, "get_event_proof", http.StatusInternalServerError, CodeInternal, "failed to build Merkle proof")
+		return
+	}
+	if tree.Root() != root.RootHash {
+		log.Error().Str("root_hash", root.RootHash).Str("rebuilt", tree.Root()).Msg("Stored Merkle root does not match its event hashes")
+		respondError(c, "get_event_proof", http.StatusInternalServerError, CodeCorruptData, "stored Merkle root does not match its event hashes")
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("get_event_proof", "200").Inc()
+	c.JSON(http.StatusOK, EventProofResponse{
+		MerkleProof: MerkleProof{
+			FactoID:   factoID,
+			EventHash: event.Proof.EventHash,
+			Proof:     proof,
+			Root:      root.RootHash,
+			Scheme:    scheme,
+		},
+		RootType:   root.RootType,
+		BucketTime: root.BucketTime,
+		LeafIndex:  ref.LeafIndex,
+		EventCount: root.EventCount,
+	})
+}
+
+// MerkleRootsQuery represents query parameters for a day's Merkle roots
+type MerkleRootsQuery struct {
+	Date   string `form:"date" binding:"required"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+// MerkleRootsResponse represents a page of a day's stored Merkle roots
+type MerkleRootsResponse struct {
+	Date       string       `json:"date"`
+	Roots      []MerkleRoot `json:"roots"`
+	NextCursor *string      `json:"next_cursor"`
+}
+
+// ListMerkleRoots handles GET /v1/merkle-roots?date=YYYY-MM-DD
+// Lists the roots stored on a UTC day, newest first, with their bucket time,
+// event count and facto_id range. Event hashes are left out; fetch a single
+// root for them.
+func (h *Handlers) ListMerkleRoots(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("list_merkle_roots").Observe(time.Since(start).Seconds())
+	}()
+
+	var query MerkleRootsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "list_merkle_roots", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
 	}
 
-	// Verify all signatures
-	for _, event := range events {
-		if !verifySignature(&event) {
-			response.Checks.AllSignaturesValid = false
-			response.Errors = append(response.Errors, "Signature invalid for event: "+event.FactoID)
-		}
+	if query.Limit <= 0 || query.Limit > 1000 {
+		query.Limit = 100
 	}
 
-	// Verify chain integrity (prev_hash links)
-	expectedPrevHash := "0000000000000000000000000000000000000000000000000000000000000000"
-	for _, event := range events {
-		if event.Proof.PrevHash != expectedPrevHash {
-			response.Checks.ChainIntegrityValid = false
-			response.Errors = append(response.Errors,
-				"Chain broken at event: "+event.FactoID+
-					" (expected prev_hash: "+expectedPrevHash[:16]+"..., got: "+event.Proof.PrevHash[:16]+"...)")
-		}
-		expectedPrevHash = event.Proof.EventHash
+	date, err := time.Parse("2006-01-02", query.Date)
+	if err != nil {
+		respondError(c, "list_merkle_roots", http.StatusBadRequest, CodeInvalidDateFormat, "invalid date format, expected YYYY-MM-DD")
+		return
 	}
 
-	// Compute session hash (hash of all event hashes concatenated)
-	var hashConcat string
-	for _, event := range events {
-		hashConcat += event.Proof.EventHash
+	roots, nextCursor, err := h.storage.GetMerkleRoots(c.Request.Context(), date, query.Limit, query.Cursor)
+	if err == ErrInvalidCursor {
+		respondError(c, "list_merkle_roots", http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+		return
+	}
+	if err != nil {
+		respondError(c, "list_merkle_roots", http.StatusInternalServerError, CodeStorageError, "failed to fetch Merkle roots")
+		return
 	}
-	sessionHashBytes := sha256.Sum256([]byte(hashConcat))
-	response.SessionHash = hex.EncodeToString(sessionHashBytes[:])
 
-	// Overall validity
-	response.Valid = response.Checks.AllHashesValid &&
-		response.Checks.AllSignaturesValid &&
-		response.Checks.ChainIntegrityValid
+	apiRequestsTotal.WithLabelValues("list_merkle_roots", "200").Inc()
+	c.JSON(http.StatusOK, MerkleRootsResponse{
+		Date:       query.Date,
+		Roots:      roots,
+		NextCursor: nextCursor,
+	})
+}
 
-	apiRequestsTotal.WithLabelValues("verify_chain", "200").Inc()
-	c.JSON(http.StatusOK, response)
+// AgentsQuery represents query parameters for the agents listing
+type AgentsQuery struct {
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
 }
 
-// EvidencePackageQuery represents query parameters for evidence package
-type EvidencePackageQuery struct {
-	SessionID string `form:"session_id" binding:"required"`
+// AgentsResponse represents a page of agents with stored events
+type AgentsResponse struct {
+	Agents     []AgentSummary `json:"agents"`
+	NextCursor *string        `json:"next_cursor"`
 }
 
-// EvidencePackageResponse represents an evidence package
-type EvidencePackageResponse struct {
-	PackageID                string          `json:"package_id"`
-	SessionID                string          `json:"session_id"`
-	Events                   []EventResponse `json:"events"`
-	MerkleProofs             []MerkleProof   `json:"merkle_proofs"`
-	ExportedAt               string          `json:"exported_at"`
-	VerificationInstructions string          `json:"verification_instructions"`
+// ListAgents handles GET /v1/agents
+// Lists the agents with stored events, with their event count and earliest
+// and latest completed_at. With API keys, agents the key doesn't allow are
+// left out, so a page may hold fewer than limit agents.
+func (h *Handlers) ListAgents(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("list_agents").Observe(time.Since(start).Seconds())
+	}()
+
+	var query AgentsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "list_agents", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if query.Limit <= 0 || query.Limit > 1000 {
+		query.Limit = 100
+	}
+
+	agents, nextCursor, err := h.getAgents(c.Request.Context(), query.Limit, query.Cursor)
+	if err == ErrInvalidCursor {
+		respondError(c, "list_agents", http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+		return
+	}
+	if err != nil {
+		respondError(c, "list_agents", http.StatusInternalServerError, CodeStorageError, "failed to fetch agents")
+		return
+	}
+
+	// Scoped callers only see the agents their API key or certificate allows
+	allowed := agents[:0]
+	for _, agent := range agents {
+		if h.agentForbidden(c, agent.AgentID) == "" {
+			allowed = append(allowed, agent)
+		}
+	}
+	agents = allowed
+
+	apiRequestsTotal.WithLabelValues("list_agents", "200").Inc()
+	c.JSON(http.StatusOK, AgentsResponse{
+		Agents:     agents,
+		NextCursor: nextCursor,
+	})
 }
 
-// MerkleProof represents a Merkle proof for an event
-type MerkleProof struct {
-	FactoID   string         `json:"facto_id"`
-	EventHash string         `json:"event_hash"`
-	Proof     []ProofElement `json:"proof"`
-	Root      string         `json:"root"`
+// AgentSessionsQuery represents query parameters for an agent's session listing
+type AgentSessionsQuery struct {
+	Start  string `form:"start"`
+	End    string `form:"end"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
 }
 
-// ProofElement represents an element in a Merkle proof
-type ProofElement struct {
-	Hash     string `json:"hash"`
-	Position string `json:"position"`
+// AgentSessionsResponse represents a page of an agent's sessions
+type AgentSessionsResponse struct {
+	AgentID    string           `json:"agent_id"`
+	Sessions   []SessionSummary `json:"sessions"`
+	NextCursor *string          `json:"next_cursor"`
 }
 
-// GetEvidencePackage handles GET /v1/evidence-package
-func (h *Handlers) GetEvidencePackage(c *gin.Context) {
+// ListAgentSessions handles GET /v1/agents/:agent_id/sessions
+// Lists the agent's sessions with event count, first/last completed_at and
+// status. Optional RFC 3339 start and end keep sessions with events in that
+// range.
+func (h *Handlers) ListAgentSessions(c *gin.Context) {
 	start := time.Now()
 	defer func() {
-		apiRequestDuration.WithLabelValues("evidence_package").Observe(time.Since(start).Seconds())
+		apiRequestDuration.WithLabelValues("list_agent_sessions").Observe(time.Since(start).Seconds())
 	}()
 
-	var query EvidencePackageQuery
+	agentID := c.Param("agent_id")
+
+	var query AgentSessionsQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
-		apiRequestsTotal.WithLabelValues("evidence_package", "400").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, "list_agent_sessions", http.StatusBadRequest, CodeInvalidRequest, err.Error())
 		return
 	}
 
-	// Get all events for the session
-	events, _, err := h.storage.GetSessionEvents(c.Request.Context(), query.SessionID, 10000, "")
+	var startTime, endTime time.Time
+	var err error
+	if query.Start != "" {
+		if startTime, err = time.Parse(time.RFC3339, query.Start); err != nil {
+			respondError(c, "list_agent_sessions", http.StatusBadRequest, CodeInvalidTimeFormat, "invalid start time format")
+			return
+		}
+	}
+	if query.End != "" {
+		if endTime, err = time.Parse(time.RFC3339, query.End); err != nil {
+			respondError(c, "list_agent_sessions", http.StatusBadRequest, CodeInvalidTimeFormat, "invalid end time format")
+			return
+		}
+	}
+
+	if query.Limit <= 0 || query.Limit > 1000 {
+		query.Limit = 100
+	}
+
+	sessions, nextCursor, err := h.storage.GetAgentSessions(c.Request.Context(), agentID, startTime, endTime, query.Limit, query.Cursor)
+	if err == ErrInvalidCursor {
+		respondError(c, "list_agent_sessions", http.StatusBadRequest, CodeInvalidCursor, "invalid cursor")
+		return
+	}
 	if err != nil {
-		apiRequestsTotal.WithLabelValues("evidence_package", "500").Inc()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch events"})
+		respondError(c, "list_agent_sessions", http.StatusInternalServerError, CodeStorageError, "failed to fetch sessions")
 		return
 	}
 
-	if len(events) == 0 {
-		apiRequestsTotal.WithLabelValues("evidence_package", "404").Inc()
-		c.JSON(http.StatusNotFound, gin.H{"error": "no events found for session"})
+	apiRequestsTotal.WithLabelValues("list_agent_sessions", "200").Inc()
+	c.JSON(http.StatusOK, AgentSessionsResponse{
+		AgentID:    agentID,
+		Sessions:   sessions,
+		NextCursor: nextCursor,
+	})
+}
+
+// GetMerkleRoot handles GET /v1/merkle-roots/:root_hash
+// Returns a stored root with the event hashes it commits, in leaf order. When
+// the hash is stored more than once (a daily root over a single batch), the
+// root_type preference of event proofs picks the row.
+func (h *Handlers) GetMerkleRoot(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("get_merkle_root").Observe(time.Since(start).Seconds())
+	}()
+
+	rootHash := c.Param("root_hash")
+	if !isHexHash(rootHash) {
+		respondError(c, "get_merkle_root", http.StatusBadRequest, CodeInvalidHash, "root_hash must be a 64-character hex hash")
 		return
 	}
 
-	// Build Merkle tree and proofs
-	hashes := make([]string, len(events))
-	for i, e := range events {
-		hashes[i] = e.Proof.EventHash
+	roots, err := h.storage.GetMerkleRootsByHash(c.Request.Context(), rootHash)
+	if err != nil {
+		respondError(c, "get_merkle_root", http.StatusInternalServerError, CodeStorageError, "failed to fetch Merkle root")
+		return
 	}
 
-	tree := buildMerkleTree(hashes)
-	merkleRoot := tree.root
-
-	proofs := make([]MerkleProof, len(events))
-	for i, e := range events {
-		proofs[i] = MerkleProof{
-			FactoID:   e.FactoID,
-			EventHash: e.Proof.EventHash,
-			Proof:     tree.getProof(i),
-			Root:      merkleRoot,
+	for _, rootType := range rootTypePreference {
+		for _, root := range roots {
+			if root.RootType == rootType {
+				apiRequestsTotal.WithLabelValues("get_merkle_root", "200").Inc()
+				c.JSON(http.StatusOK, root)
+				return
+			}
 		}
 	}
 
-	// Generate package ID
-	packageHash := sha256.Sum256([]byte(query.SessionID + time.Now().String()))
-	packageID := "ev-" + hex.EncodeToString(packageHash[:8])
+	respondError(c, "get_merkle_root", http.StatusNotFound, CodeNotFound, "Merkle root not found")
+}
 
-	response := EvidencePackageResponse{
-		PackageID:    packageID,
-		SessionID:    query.SessionID,
-		Events:       events,
-		MerkleProofs: proofs,
-		ExportedAt:   time.Now().UTC().Format(time.RFC3339),
-		VerificationInstructions: `To verify this evidence package:
+// MerkleRootAnchorResponse is the external anchor receipt of a Merkle root
+type MerkleRootAnchorResponse struct {
+	RootHash   string          `json:"root_hash"`
+	RootType   string          `json:"root_type"`
+	Date       string          `json:"date"`
+	BucketTime time.Time       `json:"bucket_time"`
+	Receipt    json.RawMessage `json:"receipt"`
+}
 
-1. For each event:
-   a. Reconstruct the canonical JSON form (sorted keys, no whitespace)
-   b. Compute SHA3-256 hash and compare with event_hash
-   c. Verify Ed25519 signature using the public_key
-   d. Verify prev_hash links to previous event's event_hash
+// GetMerkleRootAnchor handles GET /v1/merkle-roots/:root_hash/anchor
+// Returns the receipt the processor stored when it anchored the root to the
+// external log at ANCHOR_URL. Anchoring is asynchronous, so a root that
+// exists may not have a receipt yet.
+func (h *Handlers) GetMerkleRootAnchor(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("get_merkle_root_anchor").Observe(time.Since(start).Seconds())
+	}()
 
-2. Verify the Merkle proofs:
-   a. For each event, use the proof to compute the root
-   b. All computed roots should match the package Merkle root
+	rootHash := c.Param("root_hash")
+	if !isHexHash(rootHash) {
+		respondError(c, "get_merkle_root_anchor", http.StatusBadRequest, CodeInvalidHash, "root_hash must be a 64-character hex hash")
+		return
+	}
 
-3. The chain of events is tamper-evident:
-   - Any modification would break the hash chain
-   - Any modification would invalidate the signature
-   - Any modification would invalidate the Merkle proof`,
+	roots, err := h.storage.GetMerkleRootsByHash(c.Request.Context(), rootHash)
+	if err != nil {
+		respondError(c, "get_merkle_root_anchor", http.StatusInternalServerError, CodeStorageError, "failed to fetch Merkle root")
+		return
+	}
+	if len(roots) == 0 {
+		respondError(c, "get_merkle_root_anchor", http.StatusNotFound, CodeNotFound, "Merkle root not found")
+		return
 	}
 
-	apiRequestsTotal.WithLabelValues("evidence_package", "200").Inc()
-	c.JSON(http.StatusOK, response)
+	for _, rootType := range rootTypePreference {
+		for _, root := range roots {
+			if root.RootType == rootType && root.AnchorReceipt != nil {
+				apiRequestsTotal.WithLabelValues("get_merkle_root_anchor", "200").Inc()
+				c.JSON(http.StatusOK, MerkleRootAnchorResponse{
+					RootHash:   root.RootHash,
+					RootType:   root.RootType,
+					Date:       root.Date,
+					BucketTime: root.BucketTime,
+					Receipt:    root.AnchorReceipt,
+				})
+				return
+			}
+		}
+	}
+
+	respondError(c, "get_merkle_root_anchor", http.StatusNotFound, CodeNotAnchored, "Merkle root is not yet anchored")
 }
 
-// Helper functions for verification
+// MerkleRootChainQuery represents query parameters for the root chain check
+type MerkleRootChainQuery struct {
+	Date string `form:"date" binding:"required"`
+}
 
-func verifyHash(event *EventResponse) bool {
-	canonical := buildCanonicalForm(event)
-	hash := sha3.Sum256([]byte(canonical))
-	computedHash := hex.EncodeToString(hash[:])
+// GetMerkleRootChain handles GET /v1/merkle-roots/chain?date=YYYY-MM-DD
+// Checks that the day's batch roots link one to the next through
+// prev_root_hash, back to the genesis root or a root on an earlier day, so a
+// deleted or inserted root shows up as a broken link. A day whose batch roots
+// were compacted into a daily root can't be checked.
+func (h *Handlers) GetMerkleRootChain(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("get_merkle_root_chain").Observe(time.Since(start).Seconds())
+	}()
 
-	return computedHash == event.Proof.EventHash
-}
+	var query MerkleRootChainQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "get_merkle_root_chain", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
 
-func verifySignature(event *EventResponse) bool {
-	// Decode public key
-	pubKeyBytes, err := base64.StdEncoding.DecodeString(event.Proof.PublicKey)
-	if err != nil || len(pubKeyBytes) != ed25519.PublicKeySize {
-		return false
+	date, err := time.Parse("2006-01-02", query.Date)
+	if err != nil {
+		respondError(c, "get_merkle_root_chain", http.StatusBadRequest, CodeInvalidDateFormat, "invalid date format, expected YYYY-MM-DD")
+		return
 	}
 
-	// Decode signature
-	sigBytes, err := base64.StdEncoding.DecodeString(event.Proof.Signature)
-	if err != nil || len(sigBytes) != ed25519.SignatureSize {
-		return false
+	resp, err := h.checkRootChain(c.Request.Context(), date)
+	if err != nil {
+		respondError(c, "get_merkle_root_chain", http.StatusInternalServerError, CodeStorageError, "failed to fetch Merkle roots")
+		return
 	}
 
-	// Build canonical form and verify
-	canonical := buildCanonicalForm(event)
-	return ed25519.Verify(pubKeyBytes, []byte(canonical), sigBytes)
+	apiRequestsTotal.WithLabelValues("get_merkle_root_chain", "200").Inc()
+	c.JSON(http.StatusOK, resp)
+}
+
+// MerkleConsistencyQuery represents query parameters for a consistency proof
+type MerkleConsistencyQuery struct {
+	From string `form:"from" binding:"required"`
+	To   string `form:"to" binding:"required"`
 }
 
-func buildCanonicalForm(event *EventResponse) string {
-	// Build a map with sorted keys
-	canonical := make(map[string]interface{})
+// MerkleConsistencyResponse is an RFC 6962 consistency proof between two
+// batch log roots. Check it with VerifyConsistency(from.log_size,
+// to.log_size, from.log_root, to.log_root, proof) or any RFC 9162 verifier.
+type MerkleConsistencyResponse struct {
+	From          LogRoot  `json:"from"`
+	To            LogRoot  `json:"to"`
+	Proof         []string `json:"proof"`
+	HashAlgorithm string   `json:"hash_algorithm"`
+}
+
+// GetMerkleConsistency handles GET /v1/merkle-consistency?from=<root>&to=<root>
+// Proves that the batch log at to extends the log at from without rewriting
+// it. Each root may be a batch log root or a batch root, which stands for
+// the log root right after it was appended.
+func (h *Handlers) GetMerkleConsistency(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("merkle_consistency").Observe(time.Since(start).Seconds())
+	}()
+
+	var query MerkleConsistencyQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "merkle_consistency", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if !isHexHash(query.From) || !isHexHash(query.To) {
+		respondError(c, "merkle_consistency", http.StatusBadRequest, CodeInvalidHash, "from and to must be 64-character hex hashes")
+		return
+	}
 
-	canonical["action_type"] = event.ActionType
-	canonical["agent_id"] = event.AgentID
-	canonical["completed_at"] = event.CompletedAt
+	ctx := c.Request.Context()
+	from, err := h.resolveLogRoot(ctx, query.From)
+	if err != nil {
+		respondError(c, "merkle_consistency", http.StatusInternalServerError, CodeStorageError, "failed to fetch log root")
+		return
+	}
+	to, err := h.resolveLogRoot(ctx, query.To)
+	if err != nil {
+		respondError(c, "merkle_consistency", http.StatusInternalServerError, CodeStorageError, "failed to fetch log root")
+		return
+	}
+	if from == nil || to == nil {
+		respondError(c, "merkle_consistency", http.StatusNotFound, CodeNotFound, "root not found in the batch log")
+		return
+	}
+	if from.LogSize > to.LogSize {
+		respondErrorDetails(c, "merkle_consistency", http.StatusBadRequest, CodeInvalidParameter, "from must not be a later log root than to", gin.H{
+			"from_log_size": from.LogSize,
+			"to_log_size":   to.LogSize,
+		})
+		return
+	}
 
-	// Build execution_meta
-	execMeta := make(map[string]interface{})
-	if event.ExecutionMeta.ModelID != nil {
-		execMeta["model_id"] = *event.ExecutionMeta.ModelID
+	proof, err := consistencyProof(ctx, h.storage.GetLogNode, from.LogSize, to.LogSize)
+	if errors.Is(err, ErrLogNodeMissing) {
+		respondError(c, "merkle_consistency", http.StatusInternalServerError, CodeCorruptData, "batch log is missing a node")
+		return
 	}
-	execMeta["seed"] = event.ExecutionMeta.Seed
-	execMeta["sdk_version"] = event.ExecutionMeta.SDKVersion
-	if event.ExecutionMeta.Temperature != nil {
-		execMeta["temperature"] = *event.ExecutionMeta.Temperature
+	if err != nil {
+		respondError(c, "merkle_consistency", http.StatusInternalServerError, CodeStorageError, "failed to build consistency proof")
+		return
 	}
-	execMeta["tool_calls"] = event.ExecutionMeta.ToolCalls
-	canonical["execution_meta"] = execMeta
 
-	canonical["input_data"] = event.InputData
-	canonical["output_data"] = event.OutputData
-	canonical["parent_facto_id"] = event.ParentFactoID
-	canonical["prev_hash"] = event.Proof.PrevHash
-	canonical["session_id"] = event.SessionID
-	canonical["started_at"] = event.StartedAt
-	canonical["status"] = event.Status
-	canonical["facto_id"] = event.FactoID
+	// The stored log roots must agree with the stored nodes
+	if ok, err := VerifyConsistency(from.LogSize, to.LogSize, from.LogRoot, to.LogRoot, proof); !ok || err != nil {
+		log.Error().Err(err).Str("from", from.LogRoot).Str("to", to.LogRoot).Msg("Batch log consistency proof does not verify")
+		respondError(c, "merkle_consistency", http.StatusInternalServerError, CodeCorruptData, "stored batch log roots are not consistent")
+		return
+	}
 
-	// Serialize with sorted keys
-	bytes, _ := json.Marshal(sortedMap(canonical))
-	return string(bytes)
+	apiRequestsTotal.WithLabelValues("merkle_consistency", "200").Inc()
+	c.JSON(http.StatusOK, MerkleConsistencyResponse{
+		From:          *from,
+		To:            *to,
+		Proof:         proof,
+		HashAlgorithm: "rfc6962-sha256",
+	})
 }
 
-func sortedMap(m map[string]interface{}) map[string]interface{} {
-	// Get sorted keys
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+// resolveLogRoot looks hash up as a batch log root, then as a batch root
+// appended to the log. It returns nil if it is neither.
+func (h *Handlers) resolveLogRoot(ctx context.Context, hash string) (*LogRoot, error) {
+	logRoot, err := h.storage.GetLogRoot(ctx, hash)
+	if err != nil || logRoot != nil {
+		return logRoot, err
 	}
-	sort.Strings(keys)
 
-	// Build new map (Go 1.12+ preserves insertion order for JSON marshal)
-	result := make(map[string]interface{}, len(m))
-	for _, k := range keys {
-		v := m[k]
-		if nested, ok := v.(map[string]interface{}); ok {
-			result[k] = sortedMap(nested)
-		} else {
-			result[k] = v
+	roots, err := h.storage.GetMerkleRootsByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		if root.RootType == RootTypeBatch && root.LogRoot != "" {
+			return &LogRoot{LogRoot: root.LogRoot, LogSize: root.LogSize, RootHash: root.RootHash}, nil
 		}
 	}
-	return result
+	return nil, nil
+}
+
+// GetStatuses handles GET /v1/statuses
+func (h *Handlers) GetStatuses(c *gin.Context) {
+	apiRequestsTotal.WithLabelValues("get_statuses", "200").Inc()
+	c.JSON(http.StatusOK, gin.H{"statuses": h.config.AllowedStatuses})
 }
 
-// Merkle tree helpers for evidence package
+// WebhookSignatureRequest represents a webhook signature verification
+// request: the raw body and X-Facto-Signature header value of a delivery
+type WebhookSignatureRequest struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+	Secret    string `json:"secret" binding:"required"`
+}
 
-type merkleTree struct {
-	root   string
-	leaves []string
-	levels [][]string
+// WebhookSignatureResponse represents the webhook signature verification
+// result; Reason says why an invalid signature failed
+type WebhookSignatureResponse struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
 }
 
-func buildMerkleTree(hashes []string) *merkleTree {
-	if len(hashes) == 0 {
-		return &merkleTree{root: ""}
+// VerifyWebhookSignature handles POST /v1/webhooks/verify-signature
+// The caller supplies their own secret; the server's WEBHOOK_SECRET is never used here.
+func (h *Handlers) VerifyWebhookSignature(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("verify_webhook_signature").Observe(time.Since(start).Seconds())
+	}()
+
+	var req WebhookSignatureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, "verify_webhook_signature", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
 	}
 
-	tree := &merkleTree{
-		leaves: hashes,
-		levels: [][]string{hashes},
+	resp := WebhookSignatureResponse{Valid: true}
+	if err := webhook.Verify(req.Secret, []byte(req.Payload), req.Signature); err != nil {
+		resp = WebhookSignatureResponse{Valid: false, Reason: err.Error()}
 	}
+	apiRequestsTotal.WithLabelValues("verify_webhook_signature", "200").Inc()
+	c.JSON(http.StatusOK, resp)
+}
 
-	current := hashes
-	for len(current) > 1 {
-		// If odd, duplicate last
-		if len(current)%2 != 0 {
-			current = append(current, current[len(current)-1])
-		}
+// Helper functions for verification
 
-		var next []string
-		for i := 0; i < len(current); i += 2 {
-			combined := hashPair(current[i], current[i+1])
-			next = append(next, combined)
-		}
-		tree.levels = append(tree.levels, next)
-		current = next
+// computeSessionHash returns SHA-256 over the concatenated event hashes, in order
+func computeSessionHash(events []EventResponse) string {
+	hashes := make([]string, len(events))
+	for i, event := range events {
+		hashes[i] = event.Proof.EventHash
 	}
-
-	tree.root = current[0]
-	return tree
+	return hashSetDigest(hashes)
 }
 
-func hashPair(left, right string) string {
-	leftBytes, _ := hex.DecodeString(left)
-	rightBytes, _ := hex.DecodeString(right)
-	combined := append(leftBytes, rightBytes...)
-	hash := sha256.Sum256(combined)
-	return hex.EncodeToString(hash[:])
+// pageSessionHash returns the session hash of a page of events listed in
+// order. It is always taken in chain order, whatever order the page is in.
+func pageSessionHash(events []EventResponse, order SortOrder) string {
+	if order != OrderDesc {
+		return computeSessionHash(events)
+	}
+	chain := make([]EventResponse, len(events))
+	for i, e := range events {
+		chain[len(events)-1-i] = e
+	}
+	return computeSessionHash(chain)
 }
 
-func (t *merkleTree) getProof(index int) []ProofElement {
-	if index < 0 || index >= len(t.leaves) {
-		return nil
+// hashSetDigest returns SHA-256 over the concatenated hashes, in order
+func hashSetDigest(hashes []string) string {
+	var hashConcat strings.Builder
+	for _, hash := range hashes {
+		hashConcat.WriteString(hash)
 	}
+	digest := sha256.Sum256([]byte(hashConcat.String()))
+	return hex.EncodeToString(digest[:])
+}
 
-	var proof []ProofElement
-	idx := index
+// verifyHash reports whether the event's hash matches its canonical form. An
+// unknown canonical_version never verifies.
+func verifyHash(event *EventResponse) bool {
+	form, _, err := canonicalForm(event)
+	return err == nil && computeHash(form) == event.Proof.EventHash
+}
 
-	for level := 0; level < len(t.levels)-1; level++ {
-		levelNodes := t.levels[level]
+// computeHash returns the hex-encoded SHA3-256 of a canonical form
+func computeHash(canonical string) string {
+	hash := sha3.Sum256([]byte(canonical))
+	return hex.EncodeToString(hash[:])
+}
 
-		// If odd number of nodes, duplicate last
-		if len(levelNodes)%2 != 0 {
-			levelNodes = append(levelNodes, levelNodes[len(levelNodes)-1])
-		}
+func verifySignature(event *EventResponse) bool {
+	// Decode public key
+	pubKeyBytes, err := base64.StdEncoding.DecodeString(event.Proof.PublicKey)
+	if err != nil {
+		return false
+	}
 
-		var siblingIdx int
-		var position string
+	// Decode signature
+	sigBytes, err := base64.StdEncoding.DecodeString(event.Proof.Signature)
+	if err != nil {
+		return false
+	}
 
-		if idx%2 == 0 {
-			siblingIdx = idx + 1
-			position = "right"
-		} else {
-			siblingIdx = idx - 1
-			position = "left"
-		}
+	// Build canonical form and verify with the event's signature algorithm
+	form, _, err := canonicalForm(event)
+	if err != nil {
+		return false
+	}
+	return signature.Verify(event.Proof.SigAlgo, pubKeyBytes, []byte(form), sigBytes)
+}
 
-		if siblingIdx < len(levelNodes) {
-			proof = append(proof, ProofElement{
-				Hash:     levelNodes[siblingIdx],
-				Position: position,
-			})
-		}
+// canonicalForm returns the form an event is hashed and signed over, built
+// for the version in its proof.canonical_version (see canonical.Event.Form),
+// and that version. It errors for a version this build doesn't know, rather
+// than guessing one.
+func canonicalForm(event *EventResponse) (string, canonical.Version, error) {
+	meta := event.ExecutionMeta
+	e := canonical.Event{
+		FactoID:       event.FactoID,
+		AgentID:       event.AgentID,
+		SessionID:     event.SessionID,
+		ParentFactoID: event.ParentFactoID,
+		ActionType:    event.ActionType,
+		Status:        event.Status,
+		InputData:     event.InputData,
+		OutputData:    event.OutputData,
+		ModelID:       meta.ModelID,
+		ModelHash:     meta.ModelHash,
+		Temperature:   meta.Temperature,
+		Seed:          meta.Seed,
+		MaxTokens:     meta.MaxTokens,
+		ToolCalls:     meta.ToolCalls,
+		SDKVersion:    meta.SDKVersion,
+		SDKLanguage:   meta.SDKLanguage,
+		Tags:          meta.Tags,
+		PrevHash:      event.Proof.PrevHash,
+		StartedAt:     event.StartedAt,
+		CompletedAt:   event.CompletedAt,
+		Version:       event.Proof.CanonicalVersion,
+	}
+	return e.Form()
+}
 
-		idx = idx / 2
+// bindEventsJSON is ShouldBindJSON for request bodies carrying events to
+// verify. Numbers are decoded exactly (see canonical.Unmarshal), so an int64
+// in input_data hashes as the client sent it rather than as a float64.
+func bindEventsJSON(c *gin.Context, v interface{}) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
 	}
+	if err := canonical.Unmarshal(body, v); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(v)
+}
 
-	return proof
+// isHexHash reports whether s is a 64-character hex digest
+func isHexHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
 }
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// serveTest runs one request through a router with route registered by
+// register, returning the recorded response
+func serveTest(t *testing.T, register func(r *gin.Engine), method, target string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	register(router)
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func readGolden(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../tests/golden/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyEventDryRunReturnsCanonicalForm(t *testing.T) {
+	h := NewHandlers(nil, &Config{})
+	body := []byte(`{"event":` + string(readGolden(t, "canonical_event.json")) + `}`)
+	register := func(r *gin.Engine) { r.POST("/v1/verify", h.VerifyEvent) }
+
+	rec := serveTest(t, register, http.MethodPost, "/v1/verify?dry_run=true", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp VerifyDryRunResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.TrimRight(string(readGolden(t, "canonical_event.canonical")), "\n")
+	if resp.CanonicalForm != want {
+		t.Errorf("canonical_form:\ngot  %s\nwant %s", resp.CanonicalForm, want)
+	}
+	if resp.ComputedHash != resp.StoredHash || !resp.Checks.HashValid || resp.HashAlgorithm != "sha3-256" {
+		t.Errorf("computed %s, stored %s, hash_valid %v, algorithm %s",
+			resp.ComputedHash, resp.StoredHash, resp.Checks.HashValid, resp.HashAlgorithm)
+	}
+
+	// Without dry_run the plain VerifyResponse comes back
+	rec = serveTest(t, register, http.MethodPost, "/v1/verify", body)
+	if strings.Contains(rec.Body.String(), "canonical_form") {
+		t.Errorf("canonical_form returned without dry_run: %s", rec.Body)
+	}
+}
+
+func TestCheckQueryRangePartitionLimit(t *testing.T) {
+	h := NewHandlers(nil, &Config{MaxQuerySpan: 365 * 24 * time.Hour, MaxPartitionsPerQuery: 3})
+	start := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	// Three days touched, within the span and the partition limit
+	if msg := h.checkQueryRange(start, start.Add(25*time.Hour)); msg != "" {
+		t.Errorf("allowed range rejected: %s", msg)
+	}
+	// Under two days long, but a fourth partition
+	end := start.Add(49*time.Hour + 30*time.Minute)
+	if msg := h.checkQueryRange(start, end); !strings.Contains(msg, "4 date partitions") {
+		t.Errorf("expected a partition count rejection, got %q", msg)
+	}
+	if n := countDatePartitions(start, end); n != len(getDateRange(start, end)) {
+		t.Errorf("counted %d partitions, scan covers %d", n, len(getDateRange(start, end)))
+	}
+	// The span limit still applies first
+	if msg := h.checkQueryRange(start, start.AddDate(2, 0, 0)); !strings.Contains(msg, "maximum span") {
+		t.Errorf("expected a span rejection, got %q", msg)
+	}
+}
+
+func TestPageSessionHashInChainOrder(t *testing.T) {
+	hashes := []string{"aa", "bb", "cc"}
+	asc := make([]EventResponse, len(hashes))
+	desc := make([]EventResponse, len(hashes))
+	for i, hash := range hashes {
+		asc[i].Proof.EventHash = hash
+		desc[len(hashes)-1-i].Proof.EventHash = hash
+	}
+
+	digest := sha256.Sum256([]byte("aabbcc"))
+	want := hex.EncodeToString(digest[:])
+	if got := pageSessionHash(asc, OrderAsc); got != want {
+		t.Errorf("asc page: got %s, want %s", got, want)
+	}
+	if got := pageSessionHash(desc, OrderDesc); got != want {
+		t.Errorf("desc page: got %s, want %s", got, want)
+	}
+	if desc[0].Proof.EventHash != "cc" {
+		t.Error("the desc page was reordered in place")
+	}
+}
+
+func TestRejectCorruptInErrorMode(t *testing.T) {
+	events := []EventResponse{{FactoID: "ft-ok"}, {FactoID: "ft-bad", DataCorrupt: true}}
+	for mode, wantStatus := range map[string]int{"flag": http.StatusOK, "error": http.StatusInternalServerError} {
+		h := NewHandlers(nil, &Config{CorruptDataMode: mode})
+		rec := serveTest(t, func(r *gin.Engine) {
+			r.GET("/events", func(c *gin.Context) {
+				if !h.rejectCorrupt(c, "get_events", events) {
+					c.JSON(http.StatusOK, events)
+				}
+			})
+		}, http.MethodGet, "/events", nil)
+
+		if rec.Code != wantStatus {
+			t.Errorf("%s mode: status %d, want %d", mode, rec.Code, wantStatus)
+		}
+		if mode == "error" && !strings.Contains(rec.Body.String(), `"ft-bad"`) {
+			t.Errorf("error response doesn't name the corrupt event: %s", rec.Body)
+		}
+	}
+}
+
+func TestVerifyEventAnchored(t *testing.T) {
+	h := NewHandlers(nil, &Config{VerifyAnchored: true})
+	anchoredHashes := map[string]bool{}
+	h.isEventAnchored = func(ctx context.Context, eventHash string) (bool, error) {
+		return anchoredHashes[eventHash], nil
+	}
+	golden := readGolden(t, "canonical_event.json")
+	body := []byte(`{"event":` + string(golden) + `}`)
+	register := func(r *gin.Engine) { r.POST("/v1/verify", h.VerifyEvent) }
+
+	verify := func() VerifyResponse {
+		t.Helper()
+		rec := serveTest(t, register, http.MethodPost, "/v1/verify", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+		var resp VerifyResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Internally valid, but never ingested
+	resp := verify()
+	if !resp.Checks.HashValid || resp.Anchored == nil || *resp.Anchored {
+		t.Errorf("never-ingested event: hash_valid %v, anchored %v", resp.Checks.HashValid, resp.Anchored)
+	}
+
+	var event EventResponse
+	if err := json.Unmarshal(golden, &event); err != nil {
+		t.Fatal(err)
+	}
+	anchoredHashes[event.Proof.EventHash] = true
+	resp = verify()
+	if resp.Anchored == nil || !*resp.Anchored {
+		t.Errorf("anchored event reported anchored %v", resp.Anchored)
+	}
+
+	// Without VERIFY_ANCHORED the field is left out
+	h.config.VerifyAnchored = false
+	if resp := verify(); resp.Anchored != nil {
+		t.Errorf("anchored set with VERIFY_ANCHORED off: %v", *resp.Anchored)
+	}
+}
+
+func TestCheckClockSkewFlagsBackdatedEvent(t *testing.T) {
+	completed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []EventResponse{
+		// Received a minute before it claims to have completed
+		{FactoID: "ft-backdated", CompletedAt: completed.UnixNano(), ReceivedAt: completed.Add(-time.Minute).UnixNano()},
+		// Within tolerance of a slightly fast client clock
+		{FactoID: "ft-skewed", CompletedAt: completed.UnixNano(), ReceivedAt: completed.Add(-2 * time.Second).UnixNano()},
+		{FactoID: "ft-ok", CompletedAt: completed.UnixNano(), ReceivedAt: completed.Add(time.Second).UnixNano()},
+		// Not a session read, so no received_at to compare
+		{FactoID: "ft-unknown", CompletedAt: completed.UnixNano()},
+	}
+
+	warnings := checkClockSkew(events, 5*time.Second)
+	if len(warnings) != 1 {
+		t.Fatalf("got warnings %+v, want one", warnings)
+	}
+	if w := warnings[0]; w.FactoID != "ft-backdated" || w.DeltaMs != time.Minute.Milliseconds() {
+		t.Errorf("got warning %+v", w)
+	}
+}
+
+func TestCheckCanonicalDrift(t *testing.T) {
+	var event EventResponse
+	if err := json.Unmarshal(readGolden(t, "canonical_event.json"), &event); err != nil {
+		t.Fatal(err)
+	}
+	if drift := checkCanonicalDrift(&event); drift != nil {
+		t.Errorf("drift reported without a stored snapshot: %v", *drift)
+	}
+
+	event.StoredCanonicalForm = strings.TrimRight(string(readGolden(t, "canonical_event.canonical")), "\n")
+	if drift := checkCanonicalDrift(&event); drift == nil || *drift {
+		t.Errorf("drift for a matching snapshot: %v", drift)
+	}
+
+	// A snapshot taken under different canonicalization rules
+	event.StoredCanonicalForm = strings.Replace(event.StoredCanonicalForm, `"agent_id"`, `"agentId"`, 1)
+	if drift := checkCanonicalDrift(&event); drift == nil || !*drift {
+		t.Errorf("no drift for a differing snapshot: %v", drift)
+	}
+}
+
+func TestAbortEvidenceBuild(t *testing.T) {
+	h := NewHandlers(nil, &Config{EvidenceBuildTimeout: time.Second})
+	abortWith := func(err error) *httptest.ResponseRecorder {
+		register := func(r *gin.Engine) {
+			r.GET("/v1/evidence-package", func(c *gin.Context) { h.abortEvidenceBuild(c, "session", err) })
+		}
+		return serveTest(t, register, http.MethodGet, "/v1/evidence-package", nil)
+	}
+
+	rec := abortWith(context.DeadlineExceeded)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), string(CodeTimeout)) {
+		t.Errorf("timed out build: status %d: %s", rec.Code, rec.Body)
+	}
+	// A cancelled client gets no body
+	if rec := abortWith(context.Canceled); rec.Body.Len() != 0 {
+		t.Errorf("cancelled build wrote %s", rec.Body)
+	}
+}
+
+func TestGetEventsRequiresFiltering(t *testing.T) {
+	const query = "/v1/events?agent_id=agent-1&start=2026-03-01T00:00:00Z&end=2026-03-01T01:00:00Z"
+	for _, allow := range []bool{false, true} {
+		var scanned EventFilter
+		calls := 0
+		h := &Handlers{config: &Config{AllowFiltering: allow}}
+		h.getEvents = func(ctx context.Context, agentID string, start, end time.Time, limit int, cursor string, concurrency int, filter EventFilter, order SortOrder) ([]EventResponse, *string, error) {
+			calls++
+			scanned = filter
+			return nil, nil, nil
+		}
+		register := func(r *gin.Engine) { r.GET("/v1/events", h.GetEvents) }
+
+		for _, filter := range []string{"&action_type=llm_call", "&status=error"} {
+			calls = 0
+			rec := serveTest(t, register, http.MethodGet, query+filter, nil)
+			if !allow {
+				if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), string(CodeFilteringDisabled)) {
+					t.Errorf("filtering off, %s: status %d %s", filter, rec.Code, rec.Body.String())
+				}
+				if calls != 0 {
+					t.Errorf("filtering off, %s: scanned anyway", filter)
+				}
+				continue
+			}
+			if rec.Code != http.StatusOK || calls != 1 || scanned.IsZero() {
+				t.Errorf("filtering on, %s: status %d, scanned %d times with %+v", filter, rec.Code, calls, scanned)
+			}
+		}
+
+		// Unfiltered listings never need it
+		if rec := serveTest(t, register, http.MethodGet, query, nil); rec.Code != http.StatusOK {
+			t.Errorf("unfiltered, allow=%v: status %d", allow, rec.Code)
+		}
+	}
+}
+
+func TestGetEventRejectsMalformedFactoID(t *testing.T) {
+	h := &Handlers{config: &Config{}}
+	register := func(r *gin.Engine) { r.GET("/v1/events/:facto_id", h.GetEventByFactoID) }
+	for _, id := range []string{
+		"ft-1",
+		"00000000-0000-4000-8000-000000000001",
+		"ft-00000000-0000-4000-8000-00000000000Z",
+		"ft-00000000-0000-4000-8000-000000000001%27%20OR%201=1",
+	} {
+		rec := serveTest(t, register, http.MethodGet, "/v1/events/"+id, nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), string(CodeInvalidID)) {
+			t.Errorf("%s: status %d %s", id, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestGetStatuses(t *testing.T) {
+	h := &Handlers{config: &Config{AllowedStatuses: splitList(defaultAllowedStatuses)}}
+	rec := serveTest(t, func(r *gin.Engine) { r.GET("/v1/statuses", h.GetStatuses) }, http.MethodGet, "/v1/statuses", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var resp struct {
+		Statuses []string `json:"statuses"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(resp.Statuses, ",") != defaultAllowedStatuses {
+		t.Errorf("got statuses %v", resp.Statuses)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const secret = "whsec-test"
+	payload := `{"merkle_root":"ab12","event_count":3}`
+	// A captured delivery, signed HMAC-SHA256(secret, body), verifies
+	// however long ago it was sent
+	const captured = "sha256=dca3d342d8225456451052fbed6e9efa0e7b3777445d899112b8cb31eb46bb2f"
+	h := &Handlers{config: &Config{}}
+	register := func(r *gin.Engine) { r.POST("/v1/webhooks/verify-signature", h.VerifyWebhookSignature) }
+
+	for _, tc := range []struct {
+		name string
+		req  WebhookSignatureRequest
+		want error
+	}{
+		{"valid", WebhookSignatureRequest{Payload: payload, Signature: webhook.Sign(secret, []byte(payload)), Secret: secret}, nil},
+		{"captured delivery", WebhookSignatureRequest{Payload: payload, Signature: captured, Secret: secret}, nil},
+		{"tampered body", WebhookSignatureRequest{Payload: strings.Replace(payload, "3", "4", 1), Signature: captured, Secret: secret}, webhook.ErrInvalidSignature},
+		{"wrong secret", WebhookSignatureRequest{Payload: payload, Signature: captured, Secret: "other"}, webhook.ErrInvalidSignature},
+		{"missing header", WebhookSignatureRequest{Payload: payload, Secret: secret}, webhook.ErrMissingSignature},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(tc.req)
+			rec := serveTest(t, register, http.MethodPost, "/v1/webhooks/verify-signature", body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var resp WebhookSignatureResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Valid != (tc.want == nil) || (tc.want != nil && resp.Reason != tc.want.Error()) {
+				t.Errorf("got %+v, want error %v", resp, tc.want)
+			}
+		})
+	}
+}
+
+// BenchmarkVerifyHash measures the per-event hash check run on every verify:
+// building the canonical form and hashing it
+func BenchmarkVerifyHash(b *testing.B) {
+	var event EventResponse
+	if err := json.Unmarshal(readGolden(b, "canonical_event.json"), &event); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !verifyHash(&event) {
+			b.Fatal("golden event hash doesn't verify")
+		}
+	}
+}
+
+func TestVerifyEvidencePackageTooLarge(t *testing.T) {
+	h := &Handlers{config: &Config{MaxEventsPerSession: 2}}
+	register := func(r *gin.Engine) { r.POST("/v1/evidence-package/verify", h.VerifyEvidencePackage) }
+	body, _ := json.Marshal(EvidencePackageResponse{Events: make([]EventResponse, 3)})
+	rec := serveTest(t, register, http.MethodPost, "/v1/evidence-package/verify", body)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), string(CodeTooLarge)) {
+		t.Errorf("status %d %s", rec.Code, rec.Body)
+	}
+}
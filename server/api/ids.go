@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idRules limits the length and characters of agent_id and session_id values,
+// which become partition keys and are embedded in pagination cursors
+type idRules struct {
+	maxLength int
+	allowed   string // characters permitted besides ASCII letters and digits
+}
+
+func newIDRules(maxLength int, allowed string) idRules {
+	// Control characters and whitespace are never allowed
+	allowed = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, allowed)
+	return idRules{maxLength: maxLength, allowed: allowed}
+}
+
+// check returns a client-facing problem with id, or "" if it is acceptable
+func (r idRules) check(field, id string) string {
+	if len(id) > r.maxLength {
+		return fmt.Sprintf("%s exceeds %d characters", field, r.maxLength)
+	}
+	for _, c := range id {
+		if c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			continue
+		}
+		if !strings.ContainsRune(r.allowed, c) {
+			return fmt.Sprintf("%s contains disallowed character %q", field, c)
+		}
+	}
+	return ""
+}
+
+// idValidationMiddleware rejects requests whose agent_id or session_id path
+// or query parameters break the configured rules
+func idValidationMiddleware(rules idRules) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, field := range []string{"agent_id", "session_id"} {
+			for _, id := range []string{c.Param(field), c.Query(field)} {
+				if id == "" {
+					continue
+				}
+				if problem := rules.check(field, id); problem != "" {
+					apiRequestsTotal.WithLabelValues("id_validation", "400").Inc()
+					abortWithError(c, http.StatusBadRequest, CodeInvalidID, problem)
+					return
+				}
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/facto-ai/facto/server/shared/signature"
+)
+
+// canonicalFormSteps describes how to rebuild each canonical_version's form
+var canonicalFormSteps = map[canonical.Version]string{
+	canonical.V1: `canonical_version 1 (or absent): the JSON object of action_type,
+        agent_id, completed_at, execution_meta, facto_id, input_data,
+        output_data, parent_facto_id, prev_hash, session_id, started_at and
+        status, with execution_meta holding model_id (omitted when absent),
+        seed, sdk_version, temperature (omitted when absent) and tool_calls.
+        Encoded as compact JSON with keys sorted bytewise and <, > and &
+        escaped as \u003c, \u003e and \u0026 (Go's encoding/json
+        output).`,
+	canonical.V2: `canonical_version 2: the same fields as version 1, encoded with
+        the JSON Canonicalization Scheme (RFC 8785), except that integers
+        are written digit for digit rather than as doubles.`,
+	canonical.V3: `canonical_version 3: the version 1 fields plus canonical_version
+        at the top level, and model_hash and max_tokens (both omitted when
+        absent), sdk_language and tags in execution_meta. Encoded with the
+        JSON Canonicalization Scheme (RFC 8785), integers digit for digit.`,
+}
+
+// canonicalFormNote applies to every canonical_version
+const canonicalFormNote = `Absent input_data and output_data are {}, absent tool_calls
+      [] and absent tags {}; an absent parent_facto_id or seed is null.
+      Timestamps are integer nanoseconds.`
+
+// signatureSteps describes each sig_algo's key, signature and message
+var signatureSteps = map[string]string{
+	signature.AlgoEd25519: `ed25519 (or absent): raw 32-byte public key and 64-byte
+        signature over the canonical form itself.`,
+	signature.AlgoECDSAP256: `ecdsa-p256: DER SubjectPublicKeyInfo public key and ASN.1 DER
+        signature over the SHA-256 digest of the canonical form.`,
+	signature.AlgoSecp256k1: `secp256k1: SEC 1 public key (33 bytes compressed or 65
+        uncompressed) and a DER or 64-byte r||s signature over the SHA-256
+        digest of the canonical form.`,
+	signature.AlgoRSAPSS: fmt.Sprintf(`rsa-pss: DER SubjectPublicKeyInfo public key of at least %d
+        bits; RSASSA-PSS with SHA-256, MGF1-SHA256 and a 32-byte salt over
+        the SHA-256 digest of the canonical form.`, signature.MinRSAKeyBits),
+}
+
+// merkleSteps describes how each proof scheme hashes up to the root
+var merkleSteps = map[merkle.Scheme]string{
+	merkle.SchemeLegacy: `legacy (or absent): start from event_hash; each element gives
+        SHA-256(left || right).`,
+	merkle.SchemeRFC6962: `rfc6962: start from SHA-256(0x00 || event_hash); each element
+        gives SHA-256(0x01 || left || right).`,
+}
+
+// evidenceInstructions returns the verification instructions for an
+// evidence package, covering the canonical versions, signature algorithms
+// and Merkle proof schemes its events and proofs use
+func evidenceInstructions(events []EventResponse, proofs []MerkleProof) string {
+	var versions []canonical.Version
+	var algos []string
+	for _, e := range events {
+		version, err := e.Proof.CanonicalVersion.Resolve()
+		if err != nil {
+			version = e.Proof.CanonicalVersion
+		}
+		versions = appendUnique(versions, version)
+		algo := e.Proof.SigAlgo
+		if algo == "" {
+			algo = signature.AlgoEd25519
+		}
+		algos = appendUnique(algos, algo)
+	}
+	var schemes []merkle.Scheme
+	for _, p := range proofs {
+		scheme, err := merkle.ParseScheme(string(p.Scheme))
+		if err != nil {
+			scheme = p.Scheme
+		}
+		schemes = appendUnique(schemes, scheme)
+	}
+
+	var b strings.Builder
+	b.WriteString(`To verify this evidence package:
+
+1. For each event:
+   a. Rebuild its canonical form; proof.canonical_version selects it:
+`)
+	for _, v := range versions {
+		writeStep(&b, canonicalFormSteps[v], fmt.Sprintf("canonical_version %d: unknown to this server.", int(v)))
+	}
+	b.WriteString("      " + canonicalFormNote + `
+   b. Compute the SHA3-256 of the canonical form; its hex must equal
+      event_hash
+   c. Verify the signature with public_key (both base64); proof.sig_algo
+      selects the scheme:
+`)
+	for _, algo := range algos {
+		writeStep(&b, signatureSteps[algo], fmt.Sprintf("%s: unknown to this server.", algo))
+	}
+	b.WriteString(`   d. Verify prev_hash links to the previous event's event_hash
+
+2. Verify the Merkle proofs. Hashes are hex; hash their decoded bytes. Each
+   proof element's position says which side its hash goes on, the running
+   hash taking the other. The proof's scheme selects the hashing:
+`)
+	for _, scheme := range schemes {
+		writeStep(&b, merkleSteps[scheme], fmt.Sprintf("%s: unknown to this server.", scheme))
+	}
+	b.WriteString(`   The final hash must equal the proof's root, and all roots should match
+   the package Merkle root.
+
+3. The chain of events is tamper-evident:
+   - Any modification would break the hash chain
+   - Any modification would invalidate the signature
+   - Any modification would invalidate the Merkle proof`)
+	return b.String()
+}
+
+// writeStep writes one variant of a step as a bullet, or unknown when the
+// variant has no description
+func writeStep(b *strings.Builder, step, unknown string) {
+	if step == "" {
+		step = unknown
+	}
+	b.WriteString("      - " + step + "\n")
+}
+
+// appendUnique appends v to s unless s already holds it
+func appendUnique[T comparable](s []T, v T) []T {
+	for _, have := range s {
+		if have == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
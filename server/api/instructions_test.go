@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/facto-ai/facto/server/shared/signature"
+)
+
+func TestEvidenceInstructionsCoverPackageVariants(t *testing.T) {
+	// A package of pre-versioning events: legacy form, Ed25519, legacy proofs
+	var legacy EventResponse
+	text := evidenceInstructions([]EventResponse{legacy}, []MerkleProof{{}})
+	for _, want := range []string{"canonical_version 1 (or absent)", "ed25519 (or absent)", "legacy (or absent)", "SHA3-256"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("legacy package instructions lack %q", want)
+		}
+	}
+	for _, unwanted := range []string{"canonical_version 3", "secp256k1", "rfc6962"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("legacy package instructions mention %q", unwanted)
+		}
+	}
+
+	// A package mixing versions and algorithms describes each once
+	var v3, k1 EventResponse
+	v3.Proof.CanonicalVersion = canonical.V3
+	v3.Proof.SigAlgo = signature.AlgoRSAPSS
+	k1.Proof.CanonicalVersion = canonical.V2
+	k1.Proof.SigAlgo = signature.AlgoSecp256k1
+	text = evidenceInstructions([]EventResponse{v3, k1, v3}, []MerkleProof{{Scheme: merkle.SchemeRFC6962}, {Scheme: merkle.SchemeRFC6962}})
+	for _, want := range []string{"canonical_version 2:", "canonical_version 3:", "rsa-pss:", "at least 2048", "secp256k1:", "SHA-256(0x00 || event_hash)"} {
+		if strings.Count(text, want) != 1 {
+			t.Errorf("mixed package instructions mention %q %d times, want once", want, strings.Count(text, want))
+		}
+	}
+	if strings.Contains(text, "ed25519") || strings.Contains(text, "legacy (or absent)") {
+		t.Errorf("mixed package instructions describe unused variants:\n%s", text)
+	}
+
+	// A canonical_version this server doesn't know is called out
+	var future EventResponse
+	future.Proof.CanonicalVersion = 9
+	if text := evidenceInstructions([]EventResponse{future}, nil); !strings.Contains(text, "canonical_version 9: unknown") {
+		t.Errorf("unknown version not called out:\n%s", text)
+	}
+}
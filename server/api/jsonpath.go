@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/PaesslerAG/jsonpath"
+)
+
+// jsonPath is a compiled JSONPath expression, evaluated with
+// github.com/PaesslerAG/jsonpath, e.g. $.input_data.messages[0].role or
+// $["output_data"]["finish reason"]. A path selecting several values, such
+// as one with a wildcard, resolves to the array of those values.
+type jsonPath struct {
+	expr string
+	eval gval.Evaluable
+}
+
+// compileJSONPath parses a JSONPath expression, returning an error describing
+// the syntax problem
+func compileJSONPath(expr string) (*jsonPath, error) {
+	eval, err := jsonpath.New(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json_path %q: %v", expr, err)
+	}
+	return &jsonPath{expr: expr, eval: eval}, nil
+}
+
+// Lookup evaluates the path against a decoded JSON document
+func (p *jsonPath) Lookup(doc interface{}) (interface{}, bool) {
+	found, err := p.eval(context.Background(), doc)
+	if err != nil {
+		return nil, false
+	}
+	return found, true
+}
+
+// Matches reports whether the path resolves to value in doc. Strings compare
+// directly; any other JSON value compares by its compact JSON encoding, so
+// json_value=3 matches the number 3 and json_value=true the boolean.
+func (p *jsonPath) Matches(doc interface{}, value string) bool {
+	found, ok := p.Lookup(doc)
+	if !ok {
+		return false
+	}
+	if s, isString := found.(string); isString {
+		return s == value
+	}
+	encoded, err := json.Marshal(found)
+	return err == nil && string(encoded) == value
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJSONPathMatches(t *testing.T) {
+	doc := map[string]interface{}{
+		"input_data": map[string]interface{}{
+			"messages": []interface{}{
+				map[string]interface{}{"role": "system"},
+				map[string]interface{}{"role": "user", "tokens": 3.0},
+			},
+		},
+		"output_data": map[string]interface{}{"finish reason": "stop", "cached": true},
+	}
+	cases := []struct {
+		path, value string
+		want        bool
+	}{
+		{"$.input_data.messages[1].role", "user", true},
+		{"$.input_data.messages[0].role", "user", false},
+		{"$.input_data.messages[1].tokens", "3", true},
+		{`$["output_data"]["finish reason"]`, "stop", true},
+		{"$.output_data.cached", "true", true},
+		{"$.input_data.messages[*].role", `["system","user"]`, true},
+		{"$.input_data.missing", "", false},
+		{"$.input_data.messages[5].role", "user", false},
+	}
+	for _, c := range cases {
+		path, err := compileJSONPath(c.path)
+		if err != nil {
+			t.Fatalf("%s: %v", c.path, err)
+		}
+		if got := path.Matches(doc, c.value); got != c.want {
+			t.Errorf("%s = %s: got %v, want %v", c.path, c.value, got, c.want)
+		}
+	}
+}
+
+func TestCompileJSONPathRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"$.input_data[", "$.input_data..[0", "$[?(@.role ==]"} {
+		if _, err := compileJSONPath(expr); err == nil {
+			t.Errorf("%s compiled", expr)
+		}
+	}
+}
+
+// BenchmarkFilterEvents post-filters 1000 events, the most a request with
+// the default limit scans
+func BenchmarkFilterEvents(b *testing.B) {
+	events := make([]EventResponse, 1000)
+	for i := range events {
+		role := "assistant"
+		if i%10 == 0 {
+			role = "user"
+		}
+		events[i] = EventResponse{
+			FactoID: fmt.Sprintf("ft-%d", i),
+			InputData: map[string]interface{}{
+				"messages": []interface{}{map[string]interface{}{"role": role, "content": "hello"}},
+			},
+			OutputData: map[string]interface{}{"text": "hi"},
+		}
+	}
+	path, err := compileJSONPath("$.input_data.messages[0].role")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if got := filterEvents(events, path, "user", EventFilter{}, len(events)); len(got) != 100 {
+			b.Fatalf("matched %d events, want 100", len(got))
+		}
+	}
+}
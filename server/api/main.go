@@ -2,25 +2,131 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/facto-ai/facto/server/shared/cqlconn"
+	"github.com/facto-ai/facto/server/shared/factopb"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/facto-ai/facto/server/shared/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"google.golang.org/grpc"
 )
 
 // Config holds the API configuration
 type Config struct {
-	Port        int
-	ScyllaHosts []string
+	Port                   int
+	ScyllaHosts            []string
+	ScyllaConns            int // connections per Scylla host
+	ScyllaMaxRequests      int // requests in flight per connection (0 = driver limit)
+	ScyllaKeyspace         string
+	ReadConsistency        gocql.Consistency
+	WriteConsistency       gocql.Consistency
+	RedactParams           []string
+	MaxQuerySpan           time.Duration
+	MaxPartitionsPerQuery  int
+	AllowedStatuses        []string
+	MaxEventsPerSession    int64
+	VerifyConcurrency      int
+	MaxBatchVerifyEvents   int // events accepted by POST /v1/verify/batch
+	ChainVerifyConcurrency int
+	PartitionConcurrency   int    // date partitions an events query reads at once
+	CorruptDataMode        string // "flag" or "error"
+	StoragePingInterval    time.Duration
+	ReadyTimeout           time.Duration // bound on the /ready storage check
+
+	// AllowFiltering permits queries that need CQL ALLOW FILTERING. When
+	// false, such queries are rejected instead of scanning partitions.
+	AllowFiltering bool
+
+	// VerifyAnchored makes /v1/verify also check that the event hash is
+	// committed in a stored Merkle root.
+	VerifyAnchored bool
+
+	// MerkleTreeCacheSize is how many session Merkle trees are kept for
+	// repeated proof requests (0 disables the cache)
+	MerkleTreeCacheSize int
+
+	// Admin endpoints are only registered when both are set. AdminSigningKey
+	// signs deletion manifests.
+	AdminToken      string
+	AdminSigningKey ed25519.PrivateKey
+
+	// ClockSkewTolerance is how far received_at may precede completed_at
+	// before verification warns about the event's timestamps
+	ClockSkewTolerance time.Duration
+
+	// APIKeys, when set, require an X-API-Key header on /v1 routes; each key
+	// only sees the agent_ids it is scoped to
+	APIKeys []*APIKey
+
+	// TLS serving; ClientCA additionally requires client certificates (mTLS).
+	// MTLSAgentScope restricts every agent-owned read, and the ListAgents
+	// listing, to the agents named by the certificate's CN/SANs.
+	TLSCert        string
+	TLSKey         string
+	ClientCA       string
+	MTLSAgentScope bool
+
+	// IDMaxLength and IDAllowedChars limit agent_id and session_id values;
+	// besides ASCII letters and digits only IDAllowedChars are accepted
+	IDMaxLength    int
+	IDAllowedChars string
+
+	// EvidenceBuildTimeout bounds the evidence package Merkle tree and proof
+	// build (0 = only bounded by the request)
+	EvidenceBuildTimeout time.Duration
+
+	// MerkleScheme builds session trees (evidence packages, deletion
+	// manifests). Set it to the processor's MERKLE_TREE_SCHEME so a session
+	// tree matches a stored root over the same events; stored roots are
+	// rebuilt with the scheme recorded on them.
+	MerkleScheme merkle.Scheme
+
+	// RejectOrphanLinks makes chain verification fail events whose prev_hash
+	// matches no event_hash in their session, reported apart from broken links
+	RejectOrphanLinks bool
+
+	// SearchIndexURL enables GET /v1/search against the SearchIndexName index
+	// the processor pushes events to
+	SearchIndexURL  string
+	SearchIndexName string
+
+	// GRPCPort, when non-zero, serves the facto.v1.Query gRPC service there
+	// alongside the HTTP server
+	GRPCPort int
+
+	// CursorSecret signs pagination cursors, which expire after CursorTTL
+	// (0 = never). Empty means a random per-process secret, so cursors only
+	// work against the instance that issued them. CursorAllowLegacy still
+	// accepts the old unsigned cursors; it is for the upgrade release only.
+	CursorSecret      []byte
+	CursorTTL         time.Duration
+	CursorAllowLegacy bool
+}
+
+func (c *Config) adminEnabled() bool {
+	return c.AdminToken != "" && c.AdminSigningKey != nil
 }
 
+// defaultAllowedStatuses must match the processor's default
+const defaultAllowedStatuses = "success,error,failed,pending,started,completed"
+
 func loadConfig() *Config {
 	port := 8082
 	if p := os.Getenv("PORT"); p != "" {
@@ -34,12 +140,249 @@ func loadConfig() *Config {
 		scyllaHosts = "localhost:9042"
 	}
 
+	// Query parameters whose values are masked in request logs
+	redactParams := []string{"token", "cursor"}
+	if rp, ok := os.LookupEnv("LOG_REDACT_PARAMS"); ok {
+		redactParams = splitList(rp)
+	}
+
+	scyllaConns := 2
+	if v := os.Getenv("SCYLLA_NUM_CONNS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			scyllaConns = parsed
+		}
+	}
+
+	// Requests in flight per Scylla connection; 0 leaves the driver's
+	// stream limit
+	scyllaMaxRequests := 0
+	if v := os.Getenv("SCYLLA_MAX_REQUESTS_PER_CONN"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			scyllaMaxRequests = parsed
+		}
+	}
+
+	scyllaKeyspace := cqlconn.KeyspaceEnv("SCYLLA_KEYSPACE", "facto")
+
+	// Reads default to LOCAL_ONE for latency; audits wanting reads that see
+	// every acknowledged write can raise this to LOCAL_QUORUM
+	readConsistency := cqlconn.ConsistencyEnv("SCYLLA_READ_CONSISTENCY", gocql.LocalOne)
+	writeConsistency := cqlconn.ConsistencyEnv("SCYLLA_WRITE_CONSISTENCY", gocql.LocalQuorum)
+
+	// Bounds on the time range of a single events query. Each UTC day in the
+	// range is a separate partition scan.
+	maxQuerySpanDays := 92
+	if v := os.Getenv("MAX_QUERY_SPAN_DAYS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			maxQuerySpanDays = parsed
+		}
+	}
+
+	maxPartitions := 93
+	if v := os.Getenv("MAX_PARTITIONS_PER_QUERY"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			maxPartitions = parsed
+		}
+	}
+
+	partitionConcurrency := 8
+	if v := os.Getenv("PARTITION_QUERY_CONCURRENCY"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			partitionConcurrency = parsed
+		}
+	}
+
+	allowedStatuses := os.Getenv("ALLOWED_STATUSES")
+	if allowedStatuses == "" {
+		allowedStatuses = defaultAllowedStatuses
+	}
+
+	var maxEventsPerSession int64
+	if v := os.Getenv("MAX_EVENTS_PER_SESSION"); v != "" {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			maxEventsPerSession = parsed
+		}
+	}
+
+	verifyConcurrency := 8
+	if v := os.Getenv("VERIFY_CONCURRENCY"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			verifyConcurrency = parsed
+		}
+	}
+
+	maxBatchVerifyEvents := 1000
+	if v := os.Getenv("MAX_BATCH_VERIFY_EVENTS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			maxBatchVerifyEvents = parsed
+		}
+	}
+
+	corruptDataMode := os.Getenv("CORRUPT_DATA_MODE")
+	if corruptDataMode != "error" {
+		corruptDataMode = "flag"
+	}
+
+	allowFiltering := os.Getenv("ALLOW_FILTERING_ENABLED") == "true"
+	verifyAnchored := os.Getenv("VERIFY_ANCHORED") == "true"
+	rejectOrphanLinks := os.Getenv("REJECT_ORPHAN_LINKS") == "true"
+
+	treeCacheSize := 256
+	if v := os.Getenv("MERKLE_TREE_CACHE_SIZE"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			treeCacheSize = parsed
+		}
+	}
+
+	var adminSigningKey ed25519.PrivateKey
+	if v := os.Getenv("ADMIN_SIGNING_KEY"); v != "" {
+		seed, err := base64.StdEncoding.DecodeString(v)
+		if err == nil && len(seed) == ed25519.SeedSize {
+			adminSigningKey = ed25519.NewKeyFromSeed(seed)
+		} else {
+			log.Warn().Msg("ADMIN_SIGNING_KEY must be a base64 32-byte Ed25519 seed; admin endpoints disabled")
+		}
+	}
+
+	clockSkewMs := 5000
+	if v := os.Getenv("CLOCK_SKEW_TOLERANCE_MS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			clockSkewMs = parsed
+		}
+	}
+
+	idMaxLength := 128
+	if v := os.Getenv("ID_MAX_LENGTH"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			idMaxLength = parsed
+		}
+	}
+
+	idAllowedChars := "-_.:"
+	if v, ok := os.LookupEnv("ID_ALLOWED_CHARS"); ok {
+		idAllowedChars = v
+	}
+
+	evidenceBuildTimeoutMs := 30000
+	if v := os.Getenv("EVIDENCE_BUILD_TIMEOUT_MS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			evidenceBuildTimeoutMs = parsed
+		}
+	}
+
+	merkleScheme, err := merkle.ParseScheme(os.Getenv("MERKLE_TREE_SCHEME"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid MERKLE_TREE_SCHEME")
+	}
+
+	storagePingMs := 10000
+	if v := os.Getenv("STORAGE_PING_INTERVAL_MS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			storagePingMs = parsed
+		}
+	}
+
+	apiKeys, err := parseAPIKeys(os.Getenv("API_KEYS"))
+	if err != nil {
+		// Starting without the keys would leave the API open
+		log.Fatal().Err(err).Msg("Invalid API_KEYS")
+	}
+
+	readyTimeoutMs := 2000
+	if v := os.Getenv("READY_TIMEOUT_MS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			readyTimeoutMs = parsed
+		}
+	}
+
+	searchIndexName := os.Getenv("SEARCH_INDEX_NAME")
+	if searchIndexName == "" {
+		searchIndexName = "facto-events"
+	}
+
+	chainVerifyConcurrency := 5
+	if v := os.Getenv("CHAIN_VERIFY_CONCURRENCY"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			chainVerifyConcurrency = parsed
+		}
+	}
+
+	cursorSecret := os.Getenv("CURSOR_SECRET")
+	if cursorSecret != "" && len(cursorSecret) < minCursorSecretLength {
+		// A short secret would make cursors forgeable
+		log.Fatal().Int("min_length", minCursorSecretLength).Msg("CURSOR_SECRET is too short")
+	}
+
+	cursorTTLMs := 3600000
+	if v := os.Getenv("CURSOR_TTL_MS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			cursorTTLMs = parsed
+		}
+	}
+
+	grpcPort := 0
+	if v := os.Getenv("GRPC_PORT"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			grpcPort = parsed
+		}
+	}
+
 	return &Config{
-		Port:        port,
-		ScyllaHosts: []string{scyllaHosts},
+		Port:                   port,
+		ScyllaHosts:            []string{scyllaHosts},
+		ScyllaConns:            scyllaConns,
+		ScyllaMaxRequests:      scyllaMaxRequests,
+		ScyllaKeyspace:         scyllaKeyspace,
+		ReadConsistency:        readConsistency,
+		WriteConsistency:       writeConsistency,
+		RedactParams:           redactParams,
+		MaxQuerySpan:           time.Duration(maxQuerySpanDays) * 24 * time.Hour,
+		MaxPartitionsPerQuery:  maxPartitions,
+		AllowedStatuses:        splitList(allowedStatuses),
+		MaxEventsPerSession:    maxEventsPerSession,
+		VerifyConcurrency:      verifyConcurrency,
+		MaxBatchVerifyEvents:   maxBatchVerifyEvents,
+		ChainVerifyConcurrency: chainVerifyConcurrency,
+		PartitionConcurrency:   partitionConcurrency,
+		CorruptDataMode:        corruptDataMode,
+		AllowFiltering:         allowFiltering,
+		StoragePingInterval:    time.Duration(storagePingMs) * time.Millisecond,
+		ReadyTimeout:           time.Duration(readyTimeoutMs) * time.Millisecond,
+		APIKeys:                apiKeys,
+		VerifyAnchored:         verifyAnchored,
+		MerkleTreeCacheSize:    treeCacheSize,
+		ClockSkewTolerance:     time.Duration(clockSkewMs) * time.Millisecond,
+		AdminToken:             os.Getenv("ADMIN_TOKEN"),
+		AdminSigningKey:        adminSigningKey,
+		TLSCert:                os.Getenv("API_TLS_CERT"),
+		TLSKey:                 os.Getenv("API_TLS_KEY"),
+		ClientCA:               os.Getenv("API_CLIENT_CA"),
+		MTLSAgentScope:         os.Getenv("MTLS_AGENT_SCOPE") == "true",
+		IDMaxLength:            idMaxLength,
+		IDAllowedChars:         idAllowedChars,
+		EvidenceBuildTimeout:   time.Duration(evidenceBuildTimeoutMs) * time.Millisecond,
+		MerkleScheme:           merkleScheme,
+		RejectOrphanLinks:      rejectOrphanLinks,
+		SearchIndexURL:         os.Getenv("SEARCH_INDEX_URL"),
+		SearchIndexName:        searchIndexName,
+		GRPCPort:               grpcPort,
+		CursorSecret:           []byte(cursorSecret),
+		CursorTTL:              time.Duration(cursorTTLMs) * time.Millisecond,
+		CursorAllowLegacy:      os.Getenv("CURSOR_ALLOW_LEGACY") == "true",
 	}
 }
 
+// splitList splits a comma-separated env value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
 	// Setup logging
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -52,56 +395,198 @@ func main() {
 	log.Info().
 		Int("port", config.Port).
 		Strs("scylla_hosts", config.ScyllaHosts).
+		Int("scylla_num_conns", config.ScyllaConns).
+		Int("scylla_max_requests_per_conn", config.ScyllaMaxRequests).
+		Str("scylla_keyspace", config.ScyllaKeyspace).
+		Str("scylla_read_consistency", config.ReadConsistency.String()).
+		Str("scylla_write_consistency", config.WriteConsistency.String()).
+		Strs("redact_params", config.RedactParams).
+		Dur("max_query_span", config.MaxQuerySpan).
+		Int("max_partitions_per_query", config.MaxPartitionsPerQuery).
+		Int("partition_query_concurrency", config.PartitionConcurrency).
+		Int64("max_events_per_session", config.MaxEventsPerSession).
+		Int("verify_concurrency", config.VerifyConcurrency).
+		Int("max_batch_verify_events", config.MaxBatchVerifyEvents).
+		Int("chain_verify_concurrency", config.ChainVerifyConcurrency).
+		Str("corrupt_data_mode", config.CorruptDataMode).
+		Bool("allow_filtering", config.AllowFiltering).
+		Dur("storage_ping_interval", config.StoragePingInterval).
+		Dur("ready_timeout", config.ReadyTimeout).
+		Bool("verify_anchored", config.VerifyAnchored).
+		Int("merkle_tree_cache_size", config.MerkleTreeCacheSize).
+		Dur("clock_skew_tolerance", config.ClockSkewTolerance).
+		Bool("admin_enabled", config.adminEnabled()).
+		Int("api_keys", len(config.APIKeys)).
+		Bool("tls", config.TLSCert != "").
+		Bool("mtls", config.ClientCA != "").
+		Bool("mtls_agent_scope", config.MTLSAgentScope).
+		Int("id_max_length", config.IDMaxLength).
+		Str("id_allowed_chars", config.IDAllowedChars).
+		Dur("evidence_build_timeout", config.EvidenceBuildTimeout).
+		Str("merkle_tree_scheme", string(config.MerkleScheme)).
+		Bool("reject_orphan_links", config.RejectOrphanLinks).
+		Bool("search_index", config.SearchIndexURL != "").
+		Str("search_index_name", config.SearchIndexName).
+		Int("grpc_port", config.GRPCPort).
+		Bool("cursor_secret", len(config.CursorSecret) > 0).
+		Dur("cursor_ttl", config.CursorTTL).
+		Bool("cursor_allow_legacy", config.CursorAllowLegacy).
 		Msg("Configuration loaded")
 
+	shutdownTracing, err := tracing.Init(context.Background(), tracingServiceName)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+	if tracing.Enabled() {
+		log.Info().Msg("OpenTelemetry tracing enabled")
+	}
+
+	if len(config.CursorSecret) == 0 {
+		log.Warn().Msg("CURSOR_SECRET not set; pagination cursors only work against this instance until it restarts")
+	}
+	cursors, err := NewCursorSigner(config.CursorSecret, config.CursorTTL, config.CursorAllowLegacy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create cursor secret")
+	}
+
 	// Initialize storage
-	storage, err := NewStorage(config.ScyllaHosts)
+	storage, err := NewStorage(config.ScyllaHosts, StorageOptions{
+		NumConns:           config.ScyllaConns,
+		MaxRequestsPerConn: config.ScyllaMaxRequests,
+		Keyspace:           config.ScyllaKeyspace,
+		ReadConsistency:    config.ReadConsistency,
+		WriteConsistency:   config.WriteConsistency,
+		Cursors:            cursors,
+	})
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize storage")
 	}
 	defer storage.Close()
 	log.Info().Msg("Connected to ScyllaDB")
 
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go storage.MonitorHealth(monitorCtx, config.StoragePingInterval)
+
+	tlsConfig, err := buildTLSConfig(config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid TLS configuration")
+	}
+
 	// Create handlers
-	handlers := NewHandlers(storage)
+	handlers := NewHandlers(storage, config)
 
 	// Setup Gin
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
-	router.Use(loggerMiddleware())
+	// Spans per request, continuing any trace in the incoming headers
+	router.Use(otelgin.Middleware(tracingServiceName))
+	router.Use(loggerMiddleware(config.RedactParams))
+	router.Use(routingHeadersMiddleware())
 
 	// Health and metrics endpoints
 	router.GET("/health", func(c *gin.Context) {
+		if !storage.IsOpen() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "degraded", "storage": "closed"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
+	// Readiness, unlike /health, checks that ScyllaDB answers a query
+	router.GET("/ready", readyHandler(storage, config.ReadyTimeout))
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/metrics.json", metricsJSONHandler(prometheus.DefaultGatherer))
 
 	// API v1 routes
-	v1 := router.Group("/v1")
+	v1 := router.Group("/v1", idValidationMiddleware(newIDRules(config.IDMaxLength, config.IDAllowedChars)))
+	if config.ClientCA != "" {
+		v1.Use(clientCertMiddleware(config.MTLSAgentScope))
+	}
+	if len(config.APIKeys) > 0 {
+		v1.Use(apiKeyMiddleware(config.APIKeys))
+	}
 	{
 		v1.GET("/events", handlers.GetEvents)
 		v1.GET("/events/:facto_id", handlers.GetEventByFactoID)
+		v1.GET("/events/:facto_id/proof", handlers.GetEventProof)
+		v1.GET("/agents", handlers.ListAgents)
+		v1.GET("/agents/:agent_id/events", handlers.GetAgentEventsByDate)
+		v1.GET("/agents/:agent_id/sessions", handlers.ListAgentSessions)
 		v1.GET("/sessions/:session_id/events", handlers.GetSessionEvents)
+		v1.GET("/sessions/:session_id/export", handlers.ExportSession)
+		v1.GET("/sessions/:session_id/verify", handlers.VerifySessionEvents)
+		v1.GET("/sessions/:session_id/summary", handlers.GetSessionSummary)
+		v1.GET("/sessions/:session_id/start", handlers.GetSessionStart)
+		v1.GET("/sessions/:session_id/metadata", handlers.GetSessionMetadata)
+		v1.PATCH("/sessions/:session_id/metadata", handlers.UpdateSessionMetadata)
 		v1.POST("/verify", handlers.VerifyEvent)
+		v1.POST("/verify/batch", handlers.VerifyBatch)
 		v1.GET("/verify/chain", handlers.VerifyChain)
-		v1.GET("/evidence-package", handlers.GetEvidencePackage)
+		v1.GET("/verify/batch-chain", handlers.VerifyBatchChain)
+		registerEvidencePackageRoutes(v1, handlers)
+		v1.POST("/verify/merkle-proof", handlers.VerifyMerkleProof)
+		v1.GET("/merkle-roots", handlers.ListMerkleRoots)
+		v1.GET("/merkle-roots/chain", handlers.GetMerkleRootChain)
+		v1.GET("/merkle-roots/:root_hash", handlers.GetMerkleRoot)
+		v1.GET("/merkle-roots/:root_hash/anchor", handlers.GetMerkleRootAnchor)
+		v1.GET("/merkle-consistency", handlers.GetMerkleConsistency)
+		v1.POST("/webhooks/verify-signature", handlers.VerifyWebhookSignature)
+		v1.GET("/statuses", handlers.GetStatuses)
+	}
+
+	if handlers.search != nil {
+		v1.GET("/search", handlers.SearchEvents)
+	}
+
+	if config.adminEnabled() {
+		admin := v1.Group("/admin", adminAuthMiddleware(config.AdminToken))
+		admin.DELETE("/sessions/:session_id", handlers.DeleteSession)
+		admin.GET("/events/:facto_id/raw", handlers.GetRawEvent)
+		admin.GET("/audit/scan", handlers.AuditScan)
+		admin.POST("/rebuild-index", handlers.RebuildIndex)
 	}
 
 	// Create server
 	srv := &http.Server{
-		Addr:    ":" + strconv.Itoa(config.Port),
-		Handler: router,
+		Addr:      ":" + strconv.Itoa(config.Port),
+		Handler:   router,
+		TLSConfig: tlsConfig,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Info().Int("port", config.Port).Msg("Starting HTTP server")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Info().Int("port", config.Port).Bool("tls", tlsConfig != nil).Msg("Starting HTTP server")
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Server error")
 		}
 	}()
 
+	// Start gRPC server
+	var grpcServer *grpc.Server
+	if config.GRPCPort > 0 {
+		lis, err := net.Listen("tcp", ":"+strconv.Itoa(config.GRPCPort))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to listen for gRPC")
+		}
+		if grpcServer, err = newGRPCServer(config, tlsConfig); err != nil {
+			log.Fatal().Err(err).Msg("Invalid TLS configuration")
+		}
+		factopb.RegisterQueryServer(grpcServer, NewGRPCQuery(storage, config))
+		go func() {
+			log.Info().Int("port", config.GRPCPort).Bool("tls", tlsConfig != nil).Msg("Starting gRPC server")
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal().Err(err).Msg("gRPC server error")
+			}
+		}()
+	}
+
 	// Wait for shutdown signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -116,11 +601,63 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error().Err(err).Msg("Server forced to shutdown")
 	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcServer.Stop()
+		}
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
 
 	log.Info().Msg("Server exited")
 }
 
-func loggerMiddleware() gin.HandlerFunc {
+// Response headers identifying the agent/session a response belongs to, so
+// dashboards pooling requests across agents can route responses
+const (
+	headerAgentID   = "X-Facto-Agent-Id"
+	headerSessionID = "X-Facto-Session-Id"
+)
+
+func routingHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Set before c.Next(): headers can't be added once the body is written
+		if agentID := firstNonEmpty(c.Param("agent_id"), c.Query("agent_id")); agentID != "" {
+			c.Header(headerAgentID, agentID)
+		}
+		if sessionID := firstNonEmpty(c.Param("session_id"), c.Query("session_id")); sessionID != "" {
+			c.Header(headerSessionID, sessionID)
+		}
+		c.Header("Access-Control-Expose-Headers", headerAgentID+", "+headerSessionID)
+
+		c.Next()
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func loggerMiddleware(redactParams []string) gin.HandlerFunc {
+	redact := make(map[string]bool, len(redactParams))
+	for _, p := range redactParams {
+		redact[p] = true
+	}
+
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -133,15 +670,79 @@ func loggerMiddleware() gin.HandlerFunc {
 		method := c.Request.Method
 
 		if raw != "" {
-			path = path + "?" + raw
+			path = path + "?" + redactQuery(raw, redact)
 		}
 
-		log.Info().
+		event := log.Info().
 			Str("method", method).
 			Str("path", path).
 			Int("status", status).
 			Dur("latency", latency).
-			Str("ip", c.ClientIP()).
-			Msg("Request")
+			Str("ip", c.ClientIP())
+		if identity := clientIdentity(c); identity != nil {
+			event = event.Str("client_cn", identity.CommonName)
+		}
+		event.Msg("Request")
+	}
+}
+
+// redactQuery replaces the values of sensitive query parameters with "***",
+// keeping the original parameter order so log lines stay readable
+func redactQuery(raw string, redact map[string]bool) string {
+	if len(redact) == 0 {
+		return raw
+	}
+
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		rawKey, _, hasValue := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if hasValue && redact[key] {
+			pairs[i] = rawKey + "=***"
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// storagePinger is the storage /ready checks; *Storage outside tests
+type storagePinger interface {
+	Ping(ctx context.Context) error
+}
+
+// readyHandler reports whether the API can serve queries: ScyllaDB answers a
+// query within timeout. Unlike /health it returns 503 when it doesn't.
+func readyHandler(storage storagePinger, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		err := storage.Ping(ctx)
+		setStorageUp(err)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready", "storage": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "storage": "ok"})
+	}
+}
+
+// registerEvidencePackageRoutes registers the evidence package export and
+// verification. POST /v1/evidence-package/verify is the canonical route;
+// POST /v1/verify/evidence-package is a deprecated alias pointing to it.
+func registerEvidencePackageRoutes(v1 *gin.RouterGroup, handlers *Handlers) {
+	v1.GET("/evidence-package", handlers.GetEvidencePackage)
+	v1.POST("/evidence-package/verify", handlers.VerifyEvidencePackage)
+	v1.POST("/verify/evidence-package", deprecatedRoute("/v1/evidence-package/verify"), handlers.VerifyEvidencePackage)
+}
+
+// deprecatedRoute marks responses from a route that will be removed with a
+// Deprecation header and a Link to the route replacing it
+func deprecatedRoute(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
 	}
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// The access log must not carry the values of REDACT_PARAMS, however the
+// parameter name is encoded
+func TestAccessLogRedactsQueryParams(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = logger }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(loggerMiddleware([]string{"token", "cursor"}))
+	router.GET("/v1/events", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/v1/events?agent_id=agent-1&token=secret-token&cursor=secret.cursor&%74oken=secret-encoded", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	logged := buf.String()
+	for _, secret := range []string{"secret-token", "secret.cursor", "secret-encoded"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("access log contains %q: %s", secret, logged)
+		}
+	}
+	if !strings.Contains(logged, "/v1/events?agent_id=agent-1&token=***&cursor=***&%74oken=***") {
+		t.Errorf("unexpected path in access log: %s", logged)
+	}
+}
+
+func TestRoutingHeaders(t *testing.T) {
+	register := func(r *gin.Engine) {
+		r.Use(routingHeadersMiddleware())
+		r.GET("/v1/events", func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.GET("/v1/agents/:agent_id/events", func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.GET("/v1/sessions/:session_id/events", func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	cases := []struct {
+		target, agentID, sessionID string
+	}{
+		{"/v1/events?agent_id=agent-q", "agent-q", ""},
+		{"/v1/agents/agent-p/events?date=2024-01-01", "agent-p", ""},
+		{"/v1/sessions/session-p/events", "", "session-p"},
+	}
+	for _, c := range cases {
+		rec := serveTest(t, register, http.MethodGet, c.target, nil)
+		if got := rec.Header().Get(headerAgentID); got != c.agentID {
+			t.Errorf("%s: %s = %q, want %q", c.target, headerAgentID, got, c.agentID)
+		}
+		if got := rec.Header().Get(headerSessionID); got != c.sessionID {
+			t.Errorf("%s: %s = %q, want %q", c.target, headerSessionID, got, c.sessionID)
+		}
+		exposed := rec.Header().Get("Access-Control-Expose-Headers")
+		if !strings.Contains(exposed, headerAgentID) || !strings.Contains(exposed, headerSessionID) {
+			t.Errorf("%s: exposed headers %q", c.target, exposed)
+		}
+	}
+}
+
+// stubPinger answers Ping with err, or blocks until the context ends when
+// hang is set
+type stubPinger struct {
+	err  error
+	hang bool
+}
+
+func (p stubPinger) Ping(ctx context.Context) error {
+	if p.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+// /ready is 200 only while storage answers within the timeout, and a hung
+// ping is cut off by the timeout rather than holding the probe open
+func TestReadyHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		storage     stubPinger
+		wantCode    int
+		wantStorage string
+		wantUp      float64
+	}{
+		{"ok", stubPinger{}, http.StatusOK, "ok", 1},
+		{"failing", stubPinger{err: errors.New("no hosts available")}, http.StatusServiceUnavailable, "no hosts available", 0},
+		{"hanging", stubPinger{hang: true}, http.StatusServiceUnavailable, context.DeadlineExceeded.Error(), 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			rec := serveTest(t, func(r *gin.Engine) {
+				r.GET("/ready", readyHandler(tc.storage, 50*time.Millisecond))
+			}, http.MethodGet, "/ready", nil)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("/ready took %v with a 50ms timeout", elapsed)
+			}
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			wantStatus := "ready"
+			if tc.wantCode != http.StatusOK {
+				wantStatus = "not_ready"
+			}
+			if body["status"] != wantStatus || body["storage"] != tc.wantStorage {
+				t.Errorf("body = %v, want status %q storage %q", body, wantStatus, tc.wantStorage)
+			}
+			if got := testutil.ToFloat64(storageUp); got != tc.wantUp {
+				t.Errorf("facto_storage_up = %v, want %v", got, tc.wantUp)
+			}
+		})
+	}
+}
+
+// Both evidence package verify routes serve the same handler; only the
+// alias carries the deprecation headers
+func TestEvidencePackageVerifyRoutes(t *testing.T) {
+	h := NewHandlers(nil, &Config{VerifyConcurrency: 1})
+	register := func(r *gin.Engine) { registerEvidencePackageRoutes(r.Group("/v1"), h) }
+	body := []byte(`{"package_id":"pkg-1","session_id":"session-golden","events":[` +
+		string(readGolden(t, "canonical_event.json")) + `]}`)
+
+	canonical := serveTest(t, register, http.MethodPost, "/v1/evidence-package/verify", body)
+	alias := serveTest(t, register, http.MethodPost, "/v1/verify/evidence-package", body)
+	for name, rec := range map[string]*httptest.ResponseRecorder{"canonical": canonical, "alias": alias} {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s route: status = %d, want 200: %s", name, rec.Code, rec.Body)
+		}
+	}
+	if canonical.Body.String() != alias.Body.String() {
+		t.Errorf("alias body differs from canonical:\n%s\n%s", alias.Body, canonical.Body)
+	}
+	var resp EvidencePackageVerifyResponse
+	if err := json.Unmarshal(canonical.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.PackageID != "pkg-1" || len(resp.Events) != 1 {
+		t.Errorf("response = %+v, want package pkg-1 with one event", resp)
+	}
+
+	if got := canonical.Header().Get("Deprecation"); got != "" {
+		t.Errorf("canonical route Deprecation = %q, want none", got)
+	}
+	if got := alias.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("alias Deprecation = %q, want true", got)
+	}
+	if got, want := alias.Header().Get("Link"), `</v1/evidence-package/verify>; rel="successor-version"`; got != want {
+		t.Errorf("alias Link = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/facto-ai/facto/server/shared/metricsjson"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsJSONHandler serves a point-in-time JSON view of gatherer, as
+// flattened by metricsjson.Snapshot
+func metricsJSONHandler(gatherer prometheus.Gatherer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		snapshot, err := metricsjson.Snapshot(gatherer)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, newAPIError(CodeInternal, "failed to gather metrics", nil))
+			return
+		}
+		c.JSON(http.StatusOK, snapshot)
+	}
+}
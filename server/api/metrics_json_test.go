@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetricsJSON(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests_total", Help: "test"},
+		[]string{"status", "endpoint"})
+	registry.MustRegister(requests)
+	requests.WithLabelValues("200", "get_events").Inc()
+
+	rec := serveTest(t, func(r *gin.Engine) {
+		r.GET("/metrics.json", metricsJSONHandler(registry))
+	}, http.MethodGet, "/metrics.json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var snapshot map[string]float64
+	if err := json.Unmarshal(rec.Body.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot) != 1 || snapshot[`test_requests_total{endpoint="get_events",status="200"}`] != 1 {
+		t.Errorf("snapshot %v", snapshot)
+	}
+}
+
+func TestMetricsJSONGatherError(t *testing.T) {
+	failing := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, errors.New("collector failed")
+	})
+	rec := serveTest(t, func(r *gin.Engine) {
+		r.GET("/metrics.json", metricsJSONHandler(failing))
+	}, http.MethodGet, "/metrics.json", nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
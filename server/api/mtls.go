@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIdentityKey is the gin context key holding the caller's *ClientIdentity
+const clientIdentityKey = "client_identity"
+
+// ClientIdentity is the verified subject of a client certificate
+type ClientIdentity struct {
+	CommonName string
+	DNSNames   []string
+	URIs       []string
+}
+
+// Names returns the CN followed by every SAN
+func (id *ClientIdentity) Names() []string {
+	names := make([]string, 0, 1+len(id.DNSNames)+len(id.URIs))
+	if id.CommonName != "" {
+		names = append(names, id.CommonName)
+	}
+	names = append(names, id.DNSNames...)
+	return append(names, id.URIs...)
+}
+
+// Allows reports whether the certificate names the agent in its CN or a SAN
+func (id *ClientIdentity) Allows(agentID string) bool {
+	for _, name := range id.Names() {
+		if name == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTLSConfig returns the server TLS configuration, or nil when TLS is not
+// configured. Setting API_CLIENT_CA turns on mutual TLS: every client must
+// present a certificate signed by that bundle.
+func buildTLSConfig(config *Config) (*tls.Config, error) {
+	if config.TLSCert == "" && config.TLSKey == "" {
+		if config.ClientCA != "" {
+			return nil, errors.New("API_CLIENT_CA requires API_TLS_CERT and API_TLS_KEY")
+		}
+		return nil, nil
+	}
+	if config.TLSCert == "" || config.TLSKey == "" {
+		return nil, errors.New("API_TLS_CERT and API_TLS_KEY must be set together")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.ClientCA == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(config.ClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", config.ClientCA)
+	}
+
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// clientCertMiddleware exposes the verified client certificate to handlers as
+// a *ClientIdentity. With agentScope, requests naming an agent_id (path or
+// query) are rejected unless the certificate's CN or a SAN is that agent;
+// handlers check events looked up by facto_id or session_id, and filter
+// ListAgents, with rejectForbidden and agentForbidden.
+func clientCertMiddleware(agentScope bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			// Unreachable with RequireAndVerifyClientCert; guards misconfiguration
+			abortWithError(c, http.StatusUnauthorized, CodeUnauthorized, "client certificate required")
+			return
+		}
+
+		cert := c.Request.TLS.VerifiedChains[0][0]
+		identity := &ClientIdentity{
+			CommonName: cert.Subject.CommonName,
+			DNSNames:   cert.DNSNames,
+		}
+		for _, uri := range cert.URIs {
+			identity.URIs = append(identity.URIs, uri.String())
+		}
+		c.Set(clientIdentityKey, identity)
+
+		if agentScope {
+			agentID := firstNonEmpty(c.Param("agent_id"), c.Query("agent_id"))
+			if agentID != "" && !identity.Allows(agentID) {
+				abortWithError(c, http.StatusForbidden, CodeForbidden, "client certificate not authorized for agent")
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// clientIdentity returns the caller's certificate identity, or nil without mTLS
+func clientIdentity(c *gin.Context) *ClientIdentity {
+	if v, ok := c.Get(clientIdentityKey); ok {
+		return v.(*ClientIdentity)
+	}
+	return nil
+}
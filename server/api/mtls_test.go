@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCA is a certificate authority issuing client certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a client certificate for commonName signed by the CA
+func (ca *testCA) issue(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestClientCertAuth(t *testing.T) {
+	trusted := newTestCA(t, "trusted-ca")
+	untrusted := newTestCA(t, "untrusted-ca")
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, trusted.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := buildTLSConfig(&Config{TLSCert: "server.pem", TLSKey: "server.key", ClientCA: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(clientCertMiddleware(true))
+	router.GET("/v1/events", func(c *gin.Context) {
+		c.String(http.StatusOK, clientIdentity(c).CommonName)
+	})
+
+	// httptest supplies the server certificate
+	server := httptest.NewUnstartedServer(router)
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(cert tls.Certificate, agentID string) (*http.Response, error) {
+		// A fresh transport per request, so each one handshakes with its
+		// cert, sent even when the server doesn't list its issuer
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &cert, nil
+		}
+		client := &http.Client{Transport: transport}
+		defer transport.CloseIdleConnections()
+		return client.Get(server.URL + "/v1/events?agent_id=" + agentID)
+	}
+
+	resp, err := get(trusted.issue(t, "agent-1"), "agent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "agent-1" {
+		t.Errorf("valid certificate: status %d, identity %q", resp.StatusCode, body)
+	}
+
+	// Trusted, but scoped to another agent
+	resp, err = get(trusted.issue(t, "agent-1"), "agent-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("certificate for another agent: status %d", resp.StatusCode)
+	}
+
+	// The handshake itself fails for an untrusted certificate
+	if resp, err := get(untrusted.issue(t, "agent-1"), "agent-1"); err == nil {
+		resp.Body.Close()
+		t.Errorf("untrusted certificate accepted with status %d", resp.StatusCode)
+	}
+}
+
+func TestClientCertAgentScopeOnHandlers(t *testing.T) {
+	ca := newTestCA(t, "trusted-ca")
+	issued := ca.issue(t, "agent-a")
+	cert, err := x509.ParseCertificate(issued.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The verified chain stands in for the handshake TestClientCertAuth covers
+	serve := func(router *gin.Engine, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert, ca.cert}}}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	scoped := scopedRouter(scopedHandlers(&Config{MTLSAgentScope: true}), clientCertMiddleware(true))
+	unscoped := scopedRouter(scopedHandlers(&Config{}), clientCertMiddleware(false))
+	for _, tc := range []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"own event by facto_id", "/v1/events/" + scopedEventA, http.StatusOK},
+		{"own session", "/v1/sessions/session-a/events", http.StatusOK},
+		{"other agent by query", "/v1/events?agent_id=agent-c&start=2026-03-01T00:00:00Z&end=2026-03-01T01:00:00Z", http.StatusForbidden},
+		{"other agent's event by facto_id", "/v1/events/" + scopedEventC, http.StatusForbidden},
+		{"other agent's session", "/v1/sessions/session-c/events", http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := serve(scoped, tc.target); rec.Code != tc.want {
+				t.Errorf("scoped: status %d, want %d: %s", rec.Code, tc.want, rec.Body)
+			}
+			if rec := serve(unscoped, tc.target); rec.Code != http.StatusOK {
+				t.Errorf("unscoped: status %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+
+	if got := listedAgents(t, serve(scoped, "/v1/agents")); got != "agent-a" {
+		t.Errorf("scoped ListAgents returned %q, want agent-a", got)
+	}
+	if got := listedAgents(t, serve(unscoped, "/v1/agents")); got != "agent-a,agent-b,agent-c" {
+		t.Errorf("unscoped ListAgents returned %q", got)
+	}
+}
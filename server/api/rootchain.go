@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// rootLinkPendingWindow is how long a freshly stored batch root may go
+// without a prev_root_hash before it counts as unlinked; the processor links
+// each root right after storing it
+const rootLinkPendingWindow = time.Minute
+
+// RootChainResponse reports whether a day's batch roots form an unbroken
+// chain through their prev_root_hash links
+type RootChainResponse struct {
+	Date      string `json:"date"`
+	Valid     bool   `json:"valid"`
+	RootCount int    `json:"root_count"`
+
+	// FirstRoot and LastRoot are the ends of the day's chain. PrevRootHash is
+	// FirstRoot's link into an earlier day, and Genesis is set when FirstRoot
+	// starts the whole chain instead.
+	FirstRoot    string `json:"first_root,omitempty"`
+	LastRoot     string `json:"last_root,omitempty"`
+	PrevRootHash string `json:"prev_root_hash,omitempty"`
+	Genesis      bool   `json:"genesis"`
+
+	// Unlinked lists batch roots with no prev_root_hash that are not errors:
+	// roots stored before linking existed, and roots stored moments ago
+	Unlinked []string `json:"unlinked"`
+	Errors   []string `json:"errors"`
+	Note     string   `json:"note,omitempty"`
+}
+
+// checkRootChain walks the batch roots stored on date back from the day's
+// last root. Roots are ordered by their links, not their bucket times:
+// processors link roots in the order they store them, which may differ
+// slightly from batch order.
+func (h *Handlers) checkRootChain(ctx context.Context, date time.Time) (*RootChainResponse, error) {
+	all, err := h.storage.GetRootLinks(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	head, err := h.storage.GetRootChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &RootChainResponse{
+		Date:     date.Format("2006-01-02"),
+		Unlinked: []string{},
+		Errors:   []string{},
+	}
+
+	var roots []MerkleRoot
+	for _, root := range all {
+		if root.RootType == RootTypeBatch {
+			roots = append(roots, root)
+		}
+	}
+	resp.RootCount = len(roots)
+	if len(roots) == 0 {
+		if len(all) > 0 {
+			resp.Note = "batch roots for this date were compacted into its daily root; their links can no longer be checked"
+		}
+		resp.Valid = true
+		return resp, nil
+	}
+
+	headToday := head != nil && head.Date.UTC().Equal(date)
+	byHash := make(map[string]*MerkleRoot, len(roots))
+	referenced := make(map[string]bool, len(roots))
+	for i := range roots {
+		byHash[roots[i].RootHash] = &roots[i]
+		if roots[i].PrevRootHash != "" {
+			referenced[roots[i].PrevRootHash] = true
+		}
+	}
+
+	// A root is on the chain if it links back or something links to it. The
+	// genesis root and the head of a one-root chain link nowhere.
+	isLinked := func(root *MerkleRoot) bool {
+		return root.PrevRootHash != "" || referenced[root.RootHash] ||
+			(head != nil && head.RootHash == root.RootHash)
+	}
+
+	var firstLinked *time.Time
+	for i := range roots {
+		if isLinked(&roots[i]) {
+			firstLinked = &roots[i].BucketTime
+			break
+		}
+	}
+
+	now := time.Now()
+	var tails []*MerkleRoot
+	for i := range roots {
+		root := &roots[i]
+		if !isLinked(root) {
+			switch {
+			case firstLinked == nil || root.BucketTime.Before(*firstLinked):
+				// Stored before linking existed
+				resp.Unlinked = append(resp.Unlinked, root.RootHash)
+			case now.Sub(root.BucketTime) < rootLinkPendingWindow:
+				resp.Unlinked = append(resp.Unlinked, root.RootHash)
+			default:
+				resp.Errors = append(resp.Errors, fmt.Sprintf("root %s (%s) is not linked into the chain", root.RootHash, root.BucketTime.Format(time.RFC3339)))
+			}
+			continue
+		}
+		if !referenced[root.RootHash] {
+			tails = append(tails, root)
+		}
+	}
+
+	if headToday {
+		if _, ok := byHash[head.RootHash]; !ok {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("chain head %s is missing", head.RootHash))
+		}
+	}
+
+	// The day's chain ends at the head if it is today, else at the latest
+	// root nothing links to. Any other such root ends a broken-off branch.
+	var tail *MerkleRoot
+	for _, t := range tails {
+		if headToday && t.RootHash == head.RootHash {
+			tail = t
+		}
+	}
+	if tail == nil && len(tails) > 0 {
+		tail = tails[len(tails)-1]
+	}
+	for _, t := range tails {
+		if t != tail {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("root %s (%s) is not linked to by any later root", t.RootHash, t.BucketTime.Format(time.RFC3339)))
+		}
+	}
+
+	if tail != nil {
+		resp.LastRoot = tail.RootHash
+		if err := h.walkRootChain(ctx, date, byHash, tail, resp); err != nil {
+			return nil, err
+		}
+	}
+
+	resp.Valid = len(resp.Errors) == 0
+	return resp, nil
+}
+
+// walkRootChain follows prev_root_hash links from tail until they reach the
+// genesis root or leave the day, recording the first root and any broken link
+func (h *Handlers) walkRootChain(ctx context.Context, date time.Time, byHash map[string]*MerkleRoot, tail *MerkleRoot, resp *RootChainResponse) error {
+	visited := make(map[string]bool, len(byHash))
+	cur := tail
+	for {
+		visited[cur.RootHash] = true
+		prev := cur.PrevRootHash
+		if prev == "" {
+			resp.FirstRoot = cur.RootHash
+			resp.Genesis = true
+			return nil
+		}
+		if next, ok := byHash[prev]; ok {
+			if visited[prev] {
+				resp.Errors = append(resp.Errors, fmt.Sprintf("root %s links back into a cycle at %s", cur.RootHash, prev))
+				return nil
+			}
+			cur = next
+			continue
+		}
+
+		// The link leaves the day: it must reach an earlier stored batch root
+		resp.FirstRoot = cur.RootHash
+		resp.PrevRootHash = prev
+		earlier, err := h.storage.GetMerkleRootsByHash(ctx, prev)
+		if err != nil {
+			return err
+		}
+		for _, root := range earlier {
+			if root.RootType == RootTypeBatch && root.BucketTime.Before(cur.BucketTime) {
+				return nil
+			}
+		}
+		if len(earlier) > 0 {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("root %s links to %s, which is not an earlier batch root", cur.RootHash, prev))
+			return nil
+		}
+
+		// A missing root from an earlier day may have been compacted away
+		compacted, err := h.batchRootsCompacted(ctx, date.AddDate(0, 0, -1))
+		if err != nil {
+			return err
+		}
+		if compacted && cur.RootHash == firstOfDay(byHash) {
+			resp.Note = "the previous day's batch roots were compacted into its daily root; the link into it can no longer be checked"
+			return nil
+		}
+		resp.Errors = append(resp.Errors, fmt.Sprintf("root %s links to %s, which is not stored", cur.RootHash, prev))
+		return nil
+	}
+}
+
+// batchRootsCompacted reports whether date has roots but no batch roots
+func (h *Handlers) batchRootsCompacted(ctx context.Context, date time.Time) (bool, error) {
+	roots, err := h.storage.GetRootLinks(ctx, date)
+	if err != nil {
+		return false, err
+	}
+	for _, root := range roots {
+		if root.RootType == RootTypeBatch {
+			return false, nil
+		}
+	}
+	return len(roots) > 0, nil
+}
+
+// firstOfDay returns the hash of the earliest root in byHash
+func firstOfDay(byHash map[string]*MerkleRoot) string {
+	var first *MerkleRoot
+	for _, root := range byHash {
+		if first == nil || root.BucketTime.Before(first.BucketTime) {
+			first = root
+		}
+	}
+	return first.RootHash
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchLimit caps the hits hydrated per search request
+const maxSearchLimit = 100
+
+// SearchIndex queries the Elasticsearch/OpenSearch index the processor pushes
+// events to with SEARCH_INDEX_URL. The index only holds facto_ids and
+// searchable text; events are hydrated from ScyllaDB. The index is expected
+// to be created with infrastructure/search/index.json, which maps the ids as
+// keywords so agent_id filters match exactly.
+type SearchIndex struct {
+	url    string
+	client *http.Client
+}
+
+// NewSearchIndex creates a client for the named index at baseURL. Credentials
+// may be given as URL userinfo.
+func NewSearchIndex(baseURL, index string) *SearchIndex {
+	return &SearchIndex{
+		url:    strings.TrimRight(baseURL, "/") + "/" + index + "/_search",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Search returns the facto_ids of up to limit events whose input or output
+// text contains the phrase q, best match first. agentID, when set, restricts
+// hits to that agent.
+func (s *SearchIndex) Search(ctx context.Context, q, agentID string, limit int) ([]string, error) {
+	must := []interface{}{
+		map[string]interface{}{"match_phrase": map[string]string{"text": q}},
+	}
+	filter := []interface{}{}
+	if agentID != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]string{"agent_id": agentID}})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"size":    limit,
+		"_source": false,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"must": must, "filter": filter},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("search index returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding search response: %w", err)
+	}
+
+	ids := make([]string, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids, nil
+}
+
+// SearchQuery represents query parameters for event search
+type SearchQuery struct {
+	Q       string `form:"q" binding:"required"`
+	AgentID string `form:"agent_id"`
+	Limit   int    `form:"limit"`
+}
+
+// SearchEvents handles GET /v1/search?q=
+//
+// Returns events whose input or output data contains q, in index relevance
+// order, optionally restricted to one agent_id. Hits the index holds but
+// ScyllaDB doesn't (deleted sessions) are skipped.
+func (h *Handlers) SearchEvents(c *gin.Context) {
+	start := time.Now()
+	defer func() {
+		apiRequestDuration.WithLabelValues("search_events").Observe(time.Since(start).Seconds())
+	}()
+
+	var query SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondError(c, "search_events", http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if query.Limit <= 0 || query.Limit > maxSearchLimit {
+		query.Limit = 20
+	}
+	// An unscoped search would return other agents' events
+	apiKey := requestAPIKey(c)
+	if (h.config.MTLSAgentScope || apiKey != nil && !apiKey.AllAgents) && query.AgentID == "" {
+		respondError(c, "search_events", http.StatusBadRequest, CodeInvalidRequest, "agent_id is required when requests are agent-scoped")
+		return
+	}
+
+	ids, err := h.search.Search(c.Request.Context(), query.Q, query.AgentID, query.Limit)
+	if err != nil {
+		respondError(c, "search_events", http.StatusBadGateway, CodeUpstreamError, "search index query failed")
+		return
+	}
+
+	events := make([]EventResponse, 0, len(ids))
+	for _, id := range ids {
+		event, err := h.storage.GetEventByFactoID(c.Request.Context(), id)
+		if err != nil {
+			respondError(c, "search_events", http.StatusInternalServerError, CodeStorageError, "failed to fetch events")
+			return
+		}
+		if event != nil && (query.AgentID == "" || event.AgentID == query.AgentID) {
+			events = append(events, *event)
+		}
+	}
+
+	if h.rejectCorrupt(c, "search_events", events) {
+		return
+	}
+
+	apiRequestsTotal.WithLabelValues("search_events", "200").Inc()
+	c.JSON(http.StatusOK, EventsResponse{Events: events})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/facto-ai/facto/server/shared/signature"
+)
+
+// signedGolden returns the golden event signed under algo by sign, which
+// receives the canonical form and returns the DER or raw public key and the
+// signature
+func signedGolden(t *testing.T, algo string, sign func(form []byte) (pubKey, sig []byte)) *EventResponse {
+	t.Helper()
+	var event EventResponse
+	if err := json.Unmarshal(readGolden(t, "canonical_event.json"), &event); err != nil {
+		t.Fatal(err)
+	}
+	form, _, err := canonicalForm(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, sig := sign([]byte(form))
+	event.Proof.SigAlgo = algo
+	event.Proof.PublicKey = base64.StdEncoding.EncodeToString(pubKey)
+	event.Proof.Signature = base64.StdEncoding.EncodeToString(sig)
+	return &event
+}
+
+func TestVerifySignatureAlgorithms(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der := func(pub interface{}) []byte {
+		b, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	events := map[string]*EventResponse{
+		// An empty sig_algo is Ed25519
+		"default": signedGolden(t, "", func(form []byte) ([]byte, []byte) {
+			return edPub, ed25519.Sign(edPriv, form)
+		}),
+		signature.AlgoEd25519: signedGolden(t, signature.AlgoEd25519, func(form []byte) ([]byte, []byte) {
+			return edPub, ed25519.Sign(edPriv, form)
+		}),
+		signature.AlgoECDSAP256: signedGolden(t, signature.AlgoECDSAP256, func(form []byte) ([]byte, []byte) {
+			digest := sha256.Sum256(form)
+			sig, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return der(&ecKey.PublicKey), sig
+		}),
+		signature.AlgoRSAPSS: signedGolden(t, signature.AlgoRSAPSS, func(form []byte) ([]byte, []byte) {
+			digest := sha256.Sum256(form)
+			sig, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: 32})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return der(&rsaKey.PublicKey), sig
+		}),
+	}
+	for name, event := range events {
+		if !verifySignature(event) {
+			t.Errorf("%s: valid signature rejected", name)
+		}
+	}
+
+	// The same signature under another or an unknown sig_algo never verifies
+	mislabeled := *events[signature.AlgoECDSAP256]
+	mislabeled.Proof.SigAlgo = signature.AlgoEd25519
+	if verifySignature(&mislabeled) {
+		t.Error("ECDSA signature verified as Ed25519")
+	}
+	unknown := *events[signature.AlgoEd25519]
+	unknown.Proof.SigAlgo = "dsa"
+	if verifySignature(&unknown) {
+		t.Error("unknown sig_algo verified")
+	}
+	if _, ok := signature.VerifierFor("dsa"); ok {
+		t.Error("verifier found for an unknown sig_algo")
+	}
+
+	// Tampering breaks each scheme
+	for name, event := range events {
+		tampered := *event
+		tampered.Status = "tampered"
+		if verifySignature(&tampered) {
+			t.Errorf("%s: tampered event verified", name)
+		}
+	}
+}
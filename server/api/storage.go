@@ -4,159 +4,1374 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/cqlconn"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/facto-ai/facto/server/shared/tracing"
 	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	storageUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "facto_storage_up",
+		Help: "Whether the last ScyllaDB ping succeeded (1) or failed (0)",
+	})
+
+	// storageUpValue mirrors storageUp so state changes can be logged once
+	storageUpValue atomic.Bool
+
+	queryPartitions = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "facto_api_query_partitions",
+		Help:    "Date partitions scanned per GetEvents call",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 8),
+	})
+
+	partitionScanDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "facto_api_partition_scan_duration_seconds",
+		Help:    "Duration of one date partition scan in GetEvents",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	})
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrLogNodeMissing is returned when a batch log node that should exist
+// hasn't been written
+var ErrLogNodeMissing = errors.New("merkle log node missing")
+
 // Storage handles ScyllaDB operations for the Query API
 type Storage struct {
-	session *gocql.Session
+	session          *gocql.Session
+	writeConsistency gocql.Consistency
+	cursors          *CursorSigner
+}
+
+// StorageOptions configures the ScyllaDB session
+type StorageOptions struct {
+	// NumConns is the number of connections per host (0 = driver default)
+	NumConns int
+	// MaxRequestsPerConn caps the requests in flight on each connection
+	// (0 = the driver's stream limit)
+	MaxRequestsPerConn int
+	// Keyspace holds the Facto tables
+	Keyspace string
+	// ReadConsistency is the session default; WriteConsistency is set on the
+	// API's few writes (admin deletions, metadata updates, table rebuilds)
+	ReadConsistency  gocql.Consistency
+	WriteConsistency gocql.Consistency
+	// Cursors signs and checks GetEvents, GetEventsByDate and
+	// GetSessionEvents cursors
+	Cursors *CursorSigner
+}
+
+// newClusterConfig returns the cluster configuration for opts
+func newClusterConfig(hosts []string, opts StorageOptions) *gocql.ClusterConfig {
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Keyspace = opts.Keyspace
+	cluster.Consistency = opts.ReadConsistency
+	cluster.Timeout = 10 * time.Second
+	cluster.ConnectTimeout = 30 * time.Second
+	if opts.NumConns > 0 {
+		cluster.NumConns = opts.NumConns
+	}
+	if opts.MaxRequestsPerConn > 0 {
+		// Dialed as gocql would without a Dialer
+		cluster.Dialer = &cqlconn.LimitDialer{
+			Dialer:      &net.Dialer{Timeout: cluster.ConnectTimeout, KeepAlive: cluster.SocketKeepalive},
+			MaxRequests: opts.MaxRequestsPerConn,
+		}
+	}
+	cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{
+		Min:        100 * time.Millisecond,
+		Max:        10 * time.Second,
+		NumRetries: 3,
+	}
+	if tracing.Enabled() {
+		cluster.QueryObserver = tracing.CQLObserver{}
+		cluster.BatchObserver = tracing.CQLObserver{}
+	}
+	return cluster
+}
+
+// NewStorage creates a new storage instance
+func NewStorage(hosts []string, opts StorageOptions) (*Storage, error) {
+	cluster := newClusterConfig(hosts, opts)
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{session: session, writeConsistency: opts.WriteConsistency, cursors: opts.Cursors}, nil
+}
+
+// eventColumns is the column list scanEvents expects
+const eventColumns = `facto_id, agent_id, session_id, parent_facto_id,
+	       action_type, status, input_data, output_data,
+	       model_id, model_hash, temperature, seed, max_tokens, tool_calls, meta_present,
+	       sdk_version, sdk_language, tags,
+	       signature, public_key, sig_algo, canonical_version, prev_hash, event_hash,
+	       started_at, completed_at, stream_seq, custom_fields`
+
+// EventFilter narrows an events query to one action_type and/or status and
+// to events carrying every tag in Tags. Empty fields match everything.
+type EventFilter struct {
+	ActionType string
+	Status     string
+	Tags       map[string]string
+}
+
+// IsZero reports whether the filter matches every event
+func (f EventFilter) IsZero() bool {
+	return f.ActionType == "" && f.Status == "" && len(f.Tags) == 0
+}
+
+// Matches reports whether the event passes the filter
+func (f EventFilter) Matches(e *EventResponse) bool {
+	if f.ActionType != "" && e.ActionType != f.ActionType {
+		return false
+	}
+	if f.Status != "" && e.Status != f.Status {
+		return false
+	}
+	for key, value := range f.Tags {
+		if got, ok := e.ExecutionMeta.Tags[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// cql returns the filter's predicates, to append after a WHERE clause, and
+// their bind values. action_type and status are regular columns, so a query
+// using them must end in ALLOW FILTERING (see filteringClause). Tags have no
+// CQL form here and are left to Matches.
+func (f EventFilter) cql() (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if f.ActionType != "" {
+		clause += " AND action_type = ?"
+		args = append(args, f.ActionType)
+	}
+	if f.Status != "" {
+		clause += " AND status = ?"
+		args = append(args, f.Status)
+	}
+	return clause, args
+}
+
+// filteringClause returns " ALLOW FILTERING" for a non-empty filter. Every
+// filtered events query is restricted to one (agent_id, date) partition, so
+// the filtering scan is bounded by that partition.
+func (f EventFilter) filteringClause() string {
+	if clause, _ := f.cql(); clause == "" {
+		return ""
+	}
+	return " ALLOW FILTERING"
+}
+
+// SortOrder is the completed_at order of an event listing
+type SortOrder string
+
+const (
+	OrderAsc  SortOrder = "asc"  // oldest first
+	OrderDesc SortOrder = "desc" // newest first
+)
+
+// eventsOrderBy returns the ORDER BY clause reading the events table in o.
+// The table clusters on (completed_at DESC, facto_id ASC), and CQL can only
+// read that order or its exact reverse, so ascending ties on completed_at
+// come in descending facto_id order.
+func (o SortOrder) eventsOrderBy() string {
+	if o == OrderAsc {
+		return " ORDER BY completed_at ASC, facto_id DESC"
+	}
+	return ""
+}
+
+// sessionOrderBy returns the ORDER BY clause reading events_by_session, which
+// clusters on (completed_at ASC, facto_id ASC), in o
+func (o SortOrder) sessionOrderBy() string {
+	if o == OrderDesc {
+		return " ORDER BY completed_at DESC, facto_id DESC"
+	}
+	return ""
+}
+
+// GetEvents retrieves events for an agent within a time range in order.
+// Date partitions are read in that order, up to concurrency at a time; each
+// partition is read in completed_at order (its clustering order, or its
+// reverse) and partitions don't overlap, so concatenating them in date order
+// is the merge. A wave of partitions is only started while fewer than limit
+// events have been collected, so a narrow page doesn't read every partition
+// in range.
+//
+// cursor, from a previous page's next cursor, resumes right after the last
+// event that page returned; a cursor whose row has since been deleted still
+// resumes at the right place, since it is a clustering key bound rather than
+// a row lookup.
+//
+// A non-empty filter's action_type and status are applied in each partition
+// query with ALLOW FILTERING; callers that don't allow filtering pass a zero
+// filter and check it themselves, as they must for tags. limit and the cursor
+// count only events matching the CQL predicates. A cursor only resumes a
+// listing in the order it was issued for.
+func (s *Storage) GetEvents(ctx context.Context, agentID string, start, end time.Time, limit int, cursor string, concurrency int, filter EventFilter, order SortOrder) (events []EventResponse, nextCursor *string, err error) {
+	ctx, span := tracer.Start(ctx, "Storage.GetEvents", trace.WithAttributes(
+		attribute.String("facto.agent_id", agentID),
+		attribute.Int("facto.limit", limit),
+	))
+	// Partitions scanned, which the limit can make fewer than the range
+	// spans; observed whether or not the call succeeds
+	partitions := 0
+	defer func() {
+		queryPartitions.Observe(float64(partitions))
+		span.SetAttributes(
+			attribute.Int("facto.event_count", len(events)),
+			attribute.Int("facto.partitions", partitions),
+		)
+		tracing.EndSpan(span, err)
+	}()
+
+	filterCQL, filterArgs := filter.cql()
+
+	// Calculate the dates to query (partition keys), in order
+	dates := getDateRange(start, end)
+	if order == OrderDesc {
+		for i, j := 0, len(dates)-1; i < j; i, j = i+1, j-1 {
+			dates[i], dates[j] = dates[j], dates[i]
+		}
+	}
+
+	if cursor != "" {
+		cursorDate, token, err := s.cursors.decodeEventsCursor(cursor, agentID, order)
+		if err != nil {
+			return nil, nil, err
+		}
+		for len(dates) > 0 && (order == OrderDesc && dates[0].After(cursorDate) ||
+			order == OrderAsc && dates[0].Before(cursorDate)) {
+			dates = dates[1:]
+		}
+		if len(dates) == 0 || !dates[0].Equal(cursorDate) {
+			// The cursor's partition is outside this query's range
+			return nil, nil, ErrInvalidCursor
+		}
+		dates = dates[1:]
+
+		// Resume inside the cursor's partition: first the rows tied on
+		// completed_at that come after facto_id in the read order, then the
+		// rows between the cursor and the end of the range in that order
+		cursorTime := time.Unix(0, token.CompletedAt)
+		tieCQL := ` AND completed_at = ? AND facto_id > ?`
+		restCQL := ` AND completed_at >= ? AND completed_at < ?`
+		restArgs := []interface{}{agentID, cursorDate, start, cursorTime}
+		if order == OrderAsc {
+			tieCQL = ` AND completed_at = ? AND facto_id < ?`
+			restCQL = ` AND completed_at > ? AND completed_at <= ?`
+			restArgs = []interface{}{agentID, cursorDate, cursorTime, end}
+		}
+
+		partitions++
+		scanStart := time.Now()
+		args := append([]interface{}{agentID, cursorDate, cursorTime, token.FactoID}, filterArgs...)
+		ties := s.session.Query(`SELECT `+eventColumns+`
+			FROM events
+			WHERE agent_id = ? AND date = ?`+tieCQL+filterCQL+order.eventsOrderBy()+`
+			LIMIT ?`+filter.filteringClause(),
+			append(args, limit+1)...).WithContext(ctx).Iter()
+		events = append(events, scanEvents(ties, limit+1)...)
+		if err := ties.Close(); err != nil {
+			log.Error().Err(err).Msg("Error iterating events")
+			return nil, nil, err
+		}
+
+		if len(events) <= limit {
+			args := append(restArgs, filterArgs...)
+			rest := s.session.Query(`SELECT `+eventColumns+`
+				FROM events
+				WHERE agent_id = ? AND date = ?`+restCQL+filterCQL+order.eventsOrderBy()+`
+				LIMIT ?`+filter.filteringClause(),
+				append(args, limit+1-len(events))...).WithContext(ctx).Iter()
+			events = append(events, scanEvents(rest, limit+1-len(events))...)
+			if err := rest.Close(); err != nil {
+				log.Error().Err(err).Msg("Error iterating events")
+				return nil, nil, err
+			}
+		}
+		partitionScanDuration.Observe(time.Since(scanStart).Seconds())
+	}
+
+	// One row past limit is read to tell whether there is a next page
+	events, read, err := readPartitions(ctx, events, dates, limit+1, concurrency,
+		func(ctx context.Context, date time.Time, want int) ([]EventResponse, error) {
+			return s.getPartitionEvents(ctx, agentID, date, start, end, want, filter, order)
+		})
+	partitions += read
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Handle pagination
+	if len(events) > limit {
+		events = events[:limit]
+		next := s.cursors.eventsCursor(agentID, order, events[len(events)-1])
+		nextCursor = &next
+	}
+
+	return events, nextCursor, nil
+}
+
+// readPartitions appends the events read from each of dates, in order, until
+// events holds want or the dates run out. Partitions are read up to
+// concurrency at a time, each asked for what the page still needs, and a new
+// wave starts only while the page is short. It returns the events and the
+// number of partitions read.
+func readPartitions(ctx context.Context, events []EventResponse, dates []time.Time, want, concurrency int, read func(ctx context.Context, date time.Time, limit int) ([]EventResponse, error)) ([]EventResponse, int, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	partitions := 0
+	for len(dates) > 0 && len(events) < want {
+		wave := dates
+		if len(wave) > concurrency {
+			wave = wave[:concurrency]
+		}
+		dates = dates[len(wave):]
+		partitions += len(wave)
+
+		need := want - len(events)
+		pages := make([][]EventResponse, len(wave))
+		errs := make([]error, len(wave))
+		var wg sync.WaitGroup
+		for i, date := range wave {
+			wg.Add(1)
+			go func(i int, date time.Time) {
+				defer wg.Done()
+				scanStart := time.Now()
+				pages[i], errs[i] = read(ctx, date, need)
+				partitionScanDuration.Observe(time.Since(scanStart).Seconds())
+			}(i, date)
+		}
+		wg.Wait()
+
+		for i := range wave {
+			if errs[i] != nil {
+				return nil, partitions, errs[i]
+			}
+			events = append(events, pages[i]...)
+		}
+	}
+	return events, partitions, nil
+}
+
+// getPartitionEvents reads up to limit of an agent's events in [start, end]
+// from one date partition that pass filter, in order
+func (s *Storage) getPartitionEvents(ctx context.Context, agentID string, date, start, end time.Time, limit int, filter EventFilter, order SortOrder) ([]EventResponse, error) {
+	filterCQL, filterArgs := filter.cql()
+	args := append([]interface{}{agentID, date, start, end}, filterArgs...)
+	iter := s.session.Query(`SELECT `+eventColumns+`
+		FROM events
+		WHERE agent_id = ? AND date = ?
+		  AND completed_at >= ? AND completed_at <= ?`+filterCQL+order.eventsOrderBy()+`
+		LIMIT ?`+filter.filteringClause(),
+		append(args, limit)...).WithContext(ctx).Iter()
+	events := scanEvents(iter, limit)
+
+	if err := iter.Close(); err != nil {
+		log.Error().Err(err).Msg("Error iterating events")
+		return nil, err
+	}
+	return events, nil
+}
+
+// CountEvents counts an agent's events in [start, end] that pass filter, for
+// GetEvents' include_total. Unlike GetEvents it can't stop at a page: it runs
+// a COUNT(*) over every date partition in range, up to concurrency at a time,
+// and each of those reads the partition's matching rows on the replicas. The
+// cost therefore grows with the whole range, not the page size.
+func (s *Storage) CountEvents(ctx context.Context, agentID string, start, end time.Time, concurrency int, filter EventFilter) (total int64, err error) {
+	ctx, span := tracer.Start(ctx, "Storage.CountEvents", trace.WithAttributes(
+		attribute.String("facto.agent_id", agentID),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int64("facto.event_count", total))
+		tracing.EndSpan(span, err)
+	}()
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	filterCQL, filterArgs := filter.cql()
+	dates := getDateRange(start, end)
+	for len(dates) > 0 {
+		wave := dates
+		if len(wave) > concurrency {
+			wave = wave[:concurrency]
+		}
+		dates = dates[len(wave):]
+
+		counts := make([]int64, len(wave))
+		errs := make([]error, len(wave))
+		var wg sync.WaitGroup
+		for i, date := range wave {
+			wg.Add(1)
+			go func(i int, date time.Time) {
+				defer wg.Done()
+				args := append([]interface{}{agentID, date, start, end}, filterArgs...)
+				errs[i] = s.session.Query(`SELECT COUNT(*)
+					FROM events
+					WHERE agent_id = ? AND date = ?
+					  AND completed_at >= ? AND completed_at <= ?`+filterCQL+filter.filteringClause(),
+					args...).WithContext(ctx).Scan(&counts[i])
+			}(i, date)
+		}
+		wg.Wait()
+
+		for i := range wave {
+			if errs[i] != nil {
+				log.Error().Err(errs[i]).Msg("Error counting events")
+				return 0, errs[i]
+			}
+			total += counts[i]
+		}
+	}
+	return total, nil
+}
+
+// GetEventsByDate retrieves an agent's events for one day. This reads a single
+// (agent_id, date) partition, the cheapest access pattern; cursor is a signed
+// driver page state, only accepted for the same agent and date.
+func (s *Storage) GetEventsByDate(ctx context.Context, agentID string, date time.Time, limit int, cursor string) ([]EventResponse, *string, error) {
+	var pageState []byte
+	if cursor != "" {
+		var err error
+		if pageState, err = s.cursors.decodeDateCursor(cursor, agentID, date); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	iter := s.session.Query(`SELECT `+eventColumns+`
+		FROM events
+		WHERE agent_id = ? AND date = ?`,
+		agentID, date).WithContext(ctx).PageSize(limit).PageState(pageState).Iter()
+
+	events, nextPage, err := readEventPage(iter)
+	if err != nil {
+		log.Error().Err(err).Msg("Error iterating events")
+		return nil, nil, err
+	}
+
+	var nextCursor *string
+	if len(nextPage) > 0 {
+		next := s.cursors.dateCursor(agentID, date, nextPage)
+		nextCursor = &next
+	}
+
+	return events, nextCursor, nil
+}
+
+// ScanAllEvents reads one page of the whole events table in token order, for
+// full-store audits. The returned cursor resumes the scan after this page and
+// is nil once the table is exhausted.
+func (s *Storage) ScanAllEvents(ctx context.Context, pageSize int, cursor string) ([]EventResponse, *string, error) {
+	var pageState []byte
+	if cursor != "" {
+		var err error
+		if pageState, err = base64.URLEncoding.DecodeString(cursor); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
+
+	iter := s.session.Query(`SELECT ` + eventColumns + `
+		FROM events`).WithContext(ctx).PageSize(pageSize).PageState(pageState).Iter()
+
+	events, nextPage, err := readEventPage(iter)
+	if err != nil {
+		log.Error().Err(err).Msg("Error scanning events")
+		return nil, nil, err
+	}
+
+	var nextCursor *string
+	if len(nextPage) > 0 {
+		encoded := base64.URLEncoding.EncodeToString(nextPage)
+		nextCursor = &encoded
+	}
+
+	return events, nextCursor, nil
+}
+
+// pageIter is the part of *gocql.Iter that readEventPage uses
+type pageIter interface {
+	rowScanner
+	NumRows() int
+	PageState() []byte
+	Close() error
+}
+
+// readEventPage reads exactly the rows of the page iter fetched, returning
+// them with the paging state resuming after them. A page may come back
+// short of its page size, and reading past it would make the iterator fetch
+// the next one and advance the paging state beyond rows never returned.
+func readEventPage(iter pageIter) ([]EventResponse, []byte, error) {
+	events := scanEvents(iter, iter.NumRows())
+	nextPage := iter.PageState()
+	if err := iter.Close(); err != nil {
+		return nil, nil, err
+	}
+	return events, nextPage, nil
+}
+
+// rowScanner is the part of *gocql.Iter that scanEvents uses
+type rowScanner interface {
+	Scan(dest ...interface{}) bool
+}
+
+// scanEvents reads up to limit rows of the events table column list used by
+// GetEvents, GetEventsByDate and ScanAllEvents
+func scanEvents(iter rowScanner, limit int) []EventResponse {
+	var events []EventResponse
+
+	var (
+		factoID, agentID, sessionID, parentFactoID string
+		actionType, status                         string
+		inputData, outputData                      []byte
+		modelID, modelHash                         string
+		temperature                                float32
+		seed                                       int64
+		maxTokens                                  int32
+		toolCalls                                  string
+		metaPresent                                []string
+		sdkVersion, sdkLanguage                    string
+		tags                                       map[string]string
+		signature, publicKey                       []byte
+		sigAlgo, prevHash, eventHash               string
+		startedAt, completedAt                     time.Time
+		streamSeq                                  int64
+		canonicalVersion                           int
+		customFields                               map[string]string
+	)
+
+	for len(events) < limit && iter.Scan(
+		&factoID, &agentID, &sessionID, &parentFactoID,
+		&actionType, &status, &inputData, &outputData,
+		&modelID, &modelHash, &temperature, &seed, &maxTokens, &toolCalls, &metaPresent,
+		&sdkVersion, &sdkLanguage, &tags,
+		&signature, &publicKey, &sigAlgo, &canonicalVersion, &prevHash, &eventHash,
+		&startedAt, &completedAt, &streamSeq, &customFields,
+	) {
+		event := buildEventResponse(
+			factoID, agentID, sessionID, parentFactoID,
+			actionType, status, inputData, outputData,
+			modelID, modelHash, temperature, seed, maxTokens, toolCalls, metaPresent,
+			sdkVersion, sdkLanguage, tags,
+			signature, publicKey, sigAlgo, prevHash, eventHash,
+			startedAt, completedAt,
+		)
+		event.StreamSeq = optionalStreamSeq(streamSeq)
+		event.Proof.CanonicalVersion = canonical.Version(canonicalVersion)
+		event.CustomFields = customFields
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// GetEventByFactoID retrieves a single event by facto_id
+func (s *Storage) GetEventByFactoID(ctx context.Context, factoID string) (*EventResponse, error) {
+	query := s.session.Query(`
+		SELECT facto_id, agent_id, date, completed_at, session_id,
+		       action_type, status, input_data, output_data,
+		       model_id, model_hash, temperature, seed, max_tokens, tool_calls, meta_present,
+		       sdk_version, sdk_language, tags,
+		       signature, public_key, sig_algo, canonical_version, prev_hash, event_hash,
+		       parent_facto_id, started_at, stream_seq, custom_fields
+		FROM events_by_facto_id
+		WHERE facto_id = ?
+	`, factoID).WithContext(ctx)
+
+	var (
+		agentID, sessionID, parentFactoID string
+		date                              time.Time
+		completedAt, startedAt            time.Time
+		actionType, status                string
+		inputData, outputData             []byte
+		modelID, modelHash                string
+		temperature                       float32
+		seed                              int64
+		maxTokens                         int32
+		toolCalls                         string
+		metaPresent                       []string
+		sdkVersion, sdkLanguage           string
+		tags                              map[string]string
+		signature, publicKey              []byte
+		sigAlgo, prevHash, eventHash      string
+		streamSeq                         int64
+		canonicalVersion                  int
+		customFields                      map[string]string
+	)
+
+	if err := query.Scan(
+		&factoID, &agentID, &date, &completedAt, &sessionID,
+		&actionType, &status, &inputData, &outputData,
+		&modelID, &modelHash, &temperature, &seed, &maxTokens, &toolCalls, &metaPresent,
+		&sdkVersion, &sdkLanguage, &tags,
+		&signature, &publicKey, &sigAlgo, &canonicalVersion, &prevHash, &eventHash,
+		&parentFactoID, &startedAt, &streamSeq, &customFields,
+	); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	event := buildEventResponse(
+		factoID, agentID, sessionID, parentFactoID,
+		actionType, status, inputData, outputData,
+		modelID, modelHash, temperature, seed, maxTokens, toolCalls, metaPresent,
+		sdkVersion, sdkLanguage, tags,
+		signature, publicKey, sigAlgo, prevHash, eventHash,
+		startedAt, completedAt,
+	)
+	event.StreamSeq = optionalStreamSeq(streamSeq)
+	event.Proof.CanonicalVersion = canonical.Version(canonicalVersion)
+	event.CustomFields = customFields
+
+	return &event, nil
+}
+
+// sessionEventColumns is the events_by_session column list scanSessionEvents
+// expects
+const sessionEventColumns = `session_id, completed_at, facto_id, agent_id,
+	       action_type, status, event_hash,
+	       input_data, output_data,
+	       model_id, model_hash, temperature, seed, max_tokens, tool_calls, meta_present,
+	       sdk_version, sdk_language, tags,
+	       signature, public_key, sig_algo, canonical_version, prev_hash,
+	       parent_facto_id, started_at, received_at, stream_seq,
+	       canonical_form, canonical_encoding, custom_fields`
+
+// GetSessionEvents retrieves a page of a session's events, in chain order
+// for OrderAsc or newest first for OrderDesc; cursor, from a previous page's
+// next cursor in the same order, resumes after its last event
+func (s *Storage) GetSessionEvents(ctx context.Context, sessionID string, limit int, cursor string, order SortOrder) (events []EventResponse, nextCursor *string, err error) {
+	ctx, span := tracer.Start(ctx, "Storage.GetSessionEvents", trace.WithAttributes(
+		attribute.String("facto.session_id", sessionID),
+		attribute.Int("facto.limit", limit),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("facto.event_count", len(events)))
+		tracing.EndSpan(span, err)
+	}()
+
+	token, err := s.cursors.decodeSessionCursor(cursor, sessionID, order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var iter *gocql.Iter
+	if token == nil {
+		iter = s.session.Query(`
+			SELECT `+sessionEventColumns+`
+			FROM events_by_session
+			WHERE session_id = ?`+order.sessionOrderBy()+`
+			LIMIT ?
+		`, sessionID, limit+1).WithContext(ctx).Iter()
+	} else {
+		// Both clustering columns run the same way, so one slice resumes
+		// after the cursor's (completed_at, facto_id)
+		slice := ">"
+		if order == OrderDesc {
+			slice = "<"
+		}
+		iter = s.session.Query(`
+			SELECT `+sessionEventColumns+`
+			FROM events_by_session
+			WHERE session_id = ? AND (completed_at, facto_id) `+slice+` (?, ?)`+order.sessionOrderBy()+`
+			LIMIT ?
+		`, sessionID, time.Unix(0, token.CompletedAt), token.FactoID, limit+1).WithContext(ctx).Iter()
+	}
+
+	err = scanSessionEvents(iter, func(event EventResponse) error {
+		events = append(events, event)
+		if len(events) > limit {
+			return errStopScan
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Error iterating session events")
+		return nil, nil, err
+	}
+
+	// Handle pagination
+	if len(events) > limit {
+		events = events[:limit]
+		next := s.cursors.sessionCursor(sessionID, order, events[len(events)-1])
+		nextCursor = &next
+	}
+
+	return events, nextCursor, nil
+}
+
+// sessionStreamPageSize is the driver page size when streaming a session, so
+// only one page of rows is held at a time
+const sessionStreamPageSize = 500
+
+// StreamSessionEvents calls fn for each of a session's events in chain order
+// without collecting them, fetching pages from ScyllaDB as fn consumes them.
+// It stops at the first error fn returns and returns it; cancelling ctx
+// stops the next page fetch.
+func (s *Storage) StreamSessionEvents(ctx context.Context, sessionID string, fn func(EventResponse) error) (err error) {
+	ctx, span := tracer.Start(ctx, "Storage.StreamSessionEvents", trace.WithAttributes(
+		attribute.String("facto.session_id", sessionID),
+	))
+	defer func() { tracing.EndSpan(span, err) }()
+
+	iter := s.session.Query(`
+		SELECT `+sessionEventColumns+`
+		FROM events_by_session
+		WHERE session_id = ?
+	`, sessionID).WithContext(ctx).PageSize(sessionStreamPageSize).Iter()
+
+	return scanSessionEvents(iter, fn)
+}
+
+// errStopScan ends scanSessionEvents early without an error
+var errStopScan = errors.New("stop scan")
+
+// scanSessionEvents passes each row of a sessionEventColumns query to fn and
+// closes iter. fn returning errStopScan ends the scan cleanly; any other
+// error is returned.
+func scanSessionEvents(iter *gocql.Iter, fn func(EventResponse) error) error {
+	var (
+		sessionID, factoID, agentID, parentFactoID string
+		completedAt, startedAt                     time.Time
+		receivedAt                                 time.Time
+		streamSeq                                  int64
+		canonicalVersion                           int
+		canonicalForm, canonicalEnc                string
+		customFields                               map[string]string
+		actionType, status                         string
+		eventHash                                  string
+		inputData, outputData                      []byte
+		modelID, modelHash                         string
+		temperature                                float32
+		seed                                       int64
+		maxTokens                                  int32
+		toolCalls                                  string
+		metaPresent                                []string
+		sdkVersion, sdkLanguage                    string
+		tags                                       map[string]string
+		signature, publicKey                       []byte
+		sigAlgo, prevHash                          string
+	)
+
+	var fnErr error
+	for iter.Scan(
+		&sessionID, &completedAt, &factoID, &agentID,
+		&actionType, &status, &eventHash,
+		&inputData, &outputData,
+		&modelID, &modelHash, &temperature, &seed, &maxTokens, &toolCalls, &metaPresent,
+		&sdkVersion, &sdkLanguage, &tags,
+		&signature, &publicKey, &sigAlgo, &canonicalVersion, &prevHash,
+		&parentFactoID, &startedAt, &receivedAt, &streamSeq,
+		&canonicalForm, &canonicalEnc, &customFields,
+	) {
+		event := buildEventResponse(
+			factoID, agentID, sessionID, parentFactoID,
+			actionType, status, inputData, outputData,
+			modelID, modelHash, temperature, seed, maxTokens, toolCalls, metaPresent,
+			sdkVersion, sdkLanguage, tags,
+			signature, publicKey, sigAlgo, prevHash, eventHash,
+			startedAt, completedAt,
+		)
+		event.ReceivedAt = receivedAt.UnixNano()
+		event.StreamSeq = optionalStreamSeq(streamSeq)
+		event.Proof.CanonicalVersion = canonical.Version(canonicalVersion)
+		event.StoredCanonicalForm = canonicalForm
+		event.StoredCanonicalEncoding = canonicalEnc
+		event.CustomFields = customFields
+
+		if fnErr = fn(event); fnErr != nil {
+			break
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	if fnErr == errStopScan {
+		return nil
+	}
+	return fnErr
+}
+
+// GetAgentSessionIDs returns the IDs of an agent's sessions with events on a
+// given day. It reads the agent's processor-maintained sessions_by_agent
+// partition rather than the day's events, so a session that started before
+// the day and ended after it is included even without events on the day.
+func (s *Storage) GetAgentSessionIDs(ctx context.Context, agentID string, date time.Time) ([]string, error) {
+	iter := s.session.Query(`
+		SELECT session_id, first_event_at, last_event_at FROM sessions_by_agent WHERE agent_id = ?
+	`, agentID).WithContext(ctx).Iter()
+
+	var sessionIDs []string
+	var sessionID string
+	var first, last time.Time
+	for iter.Scan(&sessionID, &first, &last) {
+		if spansDay(first, last, date) {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		log.Error().Err(err).Msg("Error iterating agent sessions")
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
+// spansDay reports whether [first, last] overlaps the UTC day holding date
+func spansDay(first, last, date time.Time) bool {
+	day := date.UTC().Truncate(24 * time.Hour)
+	return first.Before(day.Add(24*time.Hour)) && !last.Before(day)
+}
+
+// IsEventAnchored reports whether an event hash is committed in any stored Merkle root
+func (s *Storage) IsEventAnchored(ctx context.Context, eventHash string) (bool, error) {
+	var rootHash string
+	err := s.session.Query(`
+		SELECT root_hash FROM merkle_roots_by_event WHERE event_hash = ? LIMIT 1
+	`, eventHash).WithContext(ctx).Scan(&rootHash)
+	if err == gocql.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Merkle root types, matching the processor's root_type values. Roots
+// written before root_type existed have none and are batch roots.
+const (
+	RootTypeBatch    = "batch"
+	RootTypeInterval = "interval"
+	RootTypeDaily    = "daily"
+)
+
+// rootChainBatch is the merkle_root_chain row batch roots are linked through,
+// and the merkle_log_nodes log they are appended to, matching the processor
+const rootChainBatch = "batch"
+
+// logNodeChunkBits matches the processor's merkle_log_nodes partitioning
+const logNodeChunkBits = 16
+
+// EventRootRef is a merkle_roots_by_event row: a Merkle root committing an
+// event hash and the event's leaf position in it
+type EventRootRef struct {
+	RootHash   string
+	RootType   string
+	Date       time.Time
+	BucketTime time.Time
+	LeafIndex  int
+}
+
+// GetEventRootRefs returns every Merkle root committing eventHash
+func (s *Storage) GetEventRootRefs(ctx context.Context, eventHash string) ([]EventRootRef, error) {
+	iter := s.session.Query(`
+		SELECT root_hash, root_type, date, bucket_time, leaf_index
+		FROM merkle_roots_by_event
+		WHERE event_hash = ?
+	`, eventHash).WithContext(ctx).Iter()
+
+	var refs []EventRootRef
+	var ref EventRootRef
+	for iter.Scan(&ref.RootHash, &ref.RootType, &ref.Date, &ref.BucketTime, &ref.LeafIndex) {
+		if ref.RootType == "" {
+			ref.RootType = RootTypeBatch
+		}
+		refs = append(refs, ref)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// MerkleRoot is a stored merkle_roots row
+type MerkleRoot struct {
+	Date         string    `json:"date"`
+	BucketTime   time.Time `json:"bucket_time"`
+	RootHash     string    `json:"root_hash"`
+	RootType     string    `json:"root_type"`
+	EventCount   int       `json:"event_count"`
+	FirstFactoID string    `json:"first_facto_id"`
+	LastFactoID  string    `json:"last_facto_id"`
+	EventHashes  []string  `json:"event_hashes,omitempty"`
+
+	// AnchorReceipt is the external anchor's receipt, once the processor has
+	// anchored the root (ANCHOR_URL)
+	AnchorReceipt json.RawMessage `json:"anchor_receipt,omitempty"`
+
+	// TSAToken is the DER RFC 3161 timestamp token over the root (TSA_URL),
+	// base64 in JSON, and TSATime the time it asserts. The token's imprint is
+	// the SHA-256 of the root_hash string; verify it against the TSA's
+	// certificate chain, e.g. with openssl ts -verify.
+	TSAToken []byte     `json:"tsa_token,omitempty"`
+	TSATime  *time.Time `json:"tsa_time,omitempty"`
+
+	// PrevRootHash is the batch root linked before this one, across all
+	// processors; empty for the first root and for roots stored before
+	// linking existed. See GET /v1/merkle-roots/chain.
+	PrevRootHash string `json:"prev_root_hash,omitempty"`
+
+	// LogRoot is the batch log's root right after this root was appended,
+	// and LogSize its size then; see GET /v1/merkle-consistency
+	LogRoot string `json:"log_root,omitempty"`
+	LogSize int64  `json:"log_size,omitempty"`
+
+	// TreeScheme is the merkle.Scheme the root was built with
+	TreeScheme string `json:"tree_scheme"`
+}
+
+// GetMerkleRoot retrieves the root stored at (date, bucketTime), or nil if
+// none exists
+func (s *Storage) GetMerkleRoot(ctx context.Context, date, bucketTime time.Time) (*MerkleRoot, error) {
+	root := MerkleRoot{Date: date.UTC().Format("2006-01-02")}
+
+	var receipt string
+	var tsaTime time.Time
+	if err := s.session.Query(`
+		SELECT bucket_time, root_hash, root_type, event_count,
+		       first_facto_id, last_facto_id, event_hashes, anchor_receipt,
+		       tsa_token, tsa_time, prev_root_hash, log_root, log_size, tree_scheme
+		FROM merkle_roots
+		WHERE date = ? AND bucket_time = ?
+	`, date, bucketTime).WithContext(ctx).Scan(
+		&root.BucketTime, &root.RootHash, &root.RootType, &root.EventCount,
+		&root.FirstFactoID, &root.LastFactoID, &root.EventHashes, &receipt,
+		&root.TSAToken, &tsaTime, &root.PrevRootHash, &root.LogRoot, &root.LogSize,
+		&root.TreeScheme,
+	); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if root.RootType == "" {
+		root.RootType = RootTypeBatch
+	}
+	if root.TreeScheme == "" {
+		root.TreeScheme = string(merkle.SchemeLegacy)
+	}
+	if receipt != "" {
+		root.AnchorReceipt = json.RawMessage(receipt)
+	}
+	if len(root.TSAToken) > 0 {
+		root.TSATime = &tsaTime
+	}
+	return &root, nil
+}
+
+// GetIntervalRoot retrieves the interval root stored at (date, bucketTime) in
+// merkle_interval_roots, or nil if none exists
+func (s *Storage) GetIntervalRoot(ctx context.Context, date, bucketTime time.Time) (*MerkleRoot, error) {
+	root := MerkleRoot{Date: date.UTC().Format("2006-01-02"), RootType: RootTypeInterval}
+	if err := s.session.Query(`
+		SELECT bucket_time, root_hash, event_count, first_facto_id, last_facto_id, event_hashes, tree_scheme
+		FROM merkle_interval_roots
+		WHERE date = ? AND bucket_time = ?
+	`, date, bucketTime).WithContext(ctx).Scan(
+		&root.BucketTime, &root.RootHash, &root.EventCount,
+		&root.FirstFactoID, &root.LastFactoID, &root.EventHashes, &root.TreeScheme,
+	); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if root.TreeScheme == "" {
+		root.TreeScheme = string(merkle.SchemeLegacy)
+	}
+	return &root, nil
+}
+
+// GetMerkleRoots lists the roots stored on date, newest first, without their
+// event hashes. cursor is an opaque driver page state.
+func (s *Storage) GetMerkleRoots(ctx context.Context, date time.Time, limit int, cursor string) ([]MerkleRoot, *string, error) {
+	var pageState []byte
+	if cursor != "" {
+		var err error
+		if pageState, err = base64.URLEncoding.DecodeString(cursor); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
+
+	iter := s.session.Query(`
+		SELECT bucket_time, root_hash, root_type, event_count, first_facto_id, last_facto_id, prev_root_hash, tree_scheme
+		FROM merkle_roots
+		WHERE date = ?
+	`, date).WithContext(ctx).PageSize(limit).PageState(pageState).Iter()
+
+	// Only this page's rows; see ScanAllEvents
+	rows := iter.NumRows()
+	roots := make([]MerkleRoot, 0, rows)
+	dateStr := date.UTC().Format("2006-01-02")
+	for i := 0; i < rows; i++ {
+		root := MerkleRoot{Date: dateStr}
+		if !iter.Scan(&root.BucketTime, &root.RootHash, &root.RootType, &root.EventCount, &root.FirstFactoID, &root.LastFactoID, &root.PrevRootHash, &root.TreeScheme) {
+			break
+		}
+		if root.RootType == "" {
+			root.RootType = RootTypeBatch
+		}
+		if root.TreeScheme == "" {
+			root.TreeScheme = string(merkle.SchemeLegacy)
+		}
+		roots = append(roots, root)
+	}
+
+	nextPage := iter.PageState()
+	if err := iter.Close(); err != nil {
+		log.Error().Err(err).Msg("Error iterating Merkle roots")
+		return nil, nil, err
+	}
+
+	var nextCursor *string
+	if len(nextPage) > 0 {
+		encoded := base64.URLEncoding.EncodeToString(nextPage)
+		nextCursor = &encoded
+	}
+
+	return roots, nextCursor, nil
+}
+
+// GetRootLinks returns every root stored on date, oldest first, with only
+// the fields needed to check the root chain
+func (s *Storage) GetRootLinks(ctx context.Context, date time.Time) ([]MerkleRoot, error) {
+	iter := s.session.Query(`
+		SELECT bucket_time, root_hash, root_type, prev_root_hash
+		FROM merkle_roots
+		WHERE date = ?
+	`, date).WithContext(ctx).Iter()
+
+	var roots []MerkleRoot
+	dateStr := date.UTC().Format("2006-01-02")
+	root := MerkleRoot{Date: dateStr}
+	for iter.Scan(&root.BucketTime, &root.RootHash, &root.RootType, &root.PrevRootHash) {
+		if root.RootType == "" {
+			root.RootType = RootTypeBatch
+		}
+		roots = append(roots, root)
+		root = MerkleRoot{Date: dateStr}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	// Rows are clustered newest first
+	for i, j := 0, len(roots)-1; i < j; i, j = i+1, j-1 {
+		roots[i], roots[j] = roots[j], roots[i]
+	}
+	return roots, nil
+}
+
+// RootChainHead is the most recently linked batch root
+type RootChainHead struct {
+	RootHash   string
+	Date       time.Time
+	BucketTime time.Time
+}
+
+// GetRootChainHead returns the head of the batch root chain, or nil before
+// any root has been linked
+func (s *Storage) GetRootChainHead(ctx context.Context) (*RootChainHead, error) {
+	var head RootChainHead
+	if err := s.session.Query(`
+		SELECT root_hash, date, bucket_time FROM merkle_root_chain WHERE chain = ?
+	`, rootChainBatch).WithContext(ctx).Scan(&head.RootHash, &head.Date, &head.BucketTime); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &head, nil
+}
+
+// LogRoot is a merkle_log_roots row: a root of the batch log
+type LogRoot struct {
+	LogRoot  string `json:"log_root"`
+	LogSize  int64  `json:"log_size"`
+	RootHash string `json:"root_hash"` // Batch root whose append produced it
+}
+
+// GetLogRoot looks up a batch log root, or returns nil if none matches
+func (s *Storage) GetLogRoot(ctx context.Context, logRoot string) (*LogRoot, error) {
+	root := LogRoot{LogRoot: logRoot}
+	if err := s.session.Query(`
+		SELECT log_size, root_hash FROM merkle_log_roots WHERE log_root = ?
+	`, logRoot).WithContext(ctx).Scan(&root.LogSize, &root.RootHash); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &root, nil
+}
+
+// GetLogNode returns the batch log's perfect subtree hash at (level, index),
+// or ErrLogNodeMissing
+func (s *Storage) GetLogNode(ctx context.Context, level int, index int64) (string, error) {
+	var hash string
+	if err := s.session.Query(`
+		SELECT hash FROM merkle_log_nodes WHERE log = ? AND level = ? AND chunk = ? AND idx = ?
+	`, rootChainBatch, level, index>>logNodeChunkBits, index).WithContext(ctx).Scan(&hash); err != nil {
+		if err == gocql.ErrNotFound {
+			return "", ErrLogNodeMissing
+		}
+		return "", err
+	}
+	return hash, nil
 }
 
-// NewStorage creates a new storage instance
-func NewStorage(hosts []string) (*Storage, error) {
-	cluster := gocql.NewCluster(hosts...)
-	cluster.Keyspace = "facto"
-	cluster.Consistency = gocql.LocalOne // Use LocalOne for reads for lower latency
-	cluster.Timeout = 10 * time.Second
-	cluster.ConnectTimeout = 30 * time.Second
-	cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{
-		Min:        100 * time.Millisecond,
-		Max:        10 * time.Second,
-		NumRetries: 3,
+// AgentSummary describes an agent with stored events
+type AgentSummary struct {
+	AgentID      string    `json:"agent_id"`
+	EventCount   int64     `json:"event_count"`
+	FirstEventAt time.Time `json:"first_event_at"`
+	LastEventAt  time.Time `json:"last_event_at"`
+}
+
+// GetAgents lists agents from the processor-maintained agent_event_ranges and
+// agent_summaries tables, in token order. cursor is an opaque driver page
+// state.
+func (s *Storage) GetAgents(ctx context.Context, limit int, cursor string) ([]AgentSummary, *string, error) {
+	var pageState []byte
+	if cursor != "" {
+		var err error
+		if pageState, err = base64.URLEncoding.DecodeString(cursor); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
 	}
 
-	session, err := cluster.CreateSession()
-	if err != nil {
-		return nil, err
+	iter := s.session.Query(`
+		SELECT agent_id, first_event_at, last_event_at FROM agent_event_ranges
+	`).WithContext(ctx).PageSize(limit).PageState(pageState).Iter()
+
+	// Only this page's rows; see ScanAllEvents
+	rows := iter.NumRows()
+	agents := make([]AgentSummary, 0, rows)
+	for i := 0; i < rows; i++ {
+		var agent AgentSummary
+		if !iter.Scan(&agent.AgentID, &agent.FirstEventAt, &agent.LastEventAt) {
+			break
+		}
+		agents = append(agents, agent)
+	}
+
+	nextPage := iter.PageState()
+	if err := iter.Close(); err != nil {
+		log.Error().Err(err).Msg("Error iterating agents")
+		return nil, nil, err
+	}
+
+	if len(agents) > 0 {
+		ids := make([]string, len(agents))
+		for i, agent := range agents {
+			ids[i] = agent.AgentID
+		}
+
+		counts := make(map[string]int64, len(ids))
+		iter := s.session.Query(`
+			SELECT agent_id, event_count FROM agent_summaries WHERE agent_id IN ?
+		`, ids).WithContext(ctx).Iter()
+		var agentID string
+		var count int64
+		for iter.Scan(&agentID, &count) {
+			counts[agentID] = count
+		}
+		if err := iter.Close(); err != nil {
+			return nil, nil, err
+		}
+
+		for i := range agents {
+			agents[i].EventCount = counts[agents[i].AgentID]
+		}
 	}
 
-	return &Storage{session: session}, nil
+	var nextCursor *string
+	if len(nextPage) > 0 {
+		encoded := base64.URLEncoding.EncodeToString(nextPage)
+		nextCursor = &encoded
+	}
+
+	return agents, nextCursor, nil
 }
 
-// GetEvents retrieves events for an agent within a time range
-func (s *Storage) GetEvents(ctx context.Context, agentID string, start, end time.Time, limit int, cursor string) ([]EventResponse, *string, error) {
-	var events []EventResponse
+// SessionSummary describes one of an agent's sessions
+type SessionSummary struct {
+	SessionID    string    `json:"session_id"`
+	EventCount   int64     `json:"event_count"`
+	FirstEventAt time.Time `json:"first_event_at"`
+	LastEventAt  time.Time `json:"last_event_at"`
+	Status       string    `json:"status"`
+}
 
-	// Calculate the dates to query (partition keys)
-	dates := getDateRange(start, end)
+// Session statuses reported by GetAgentSessions
+const (
+	SessionStatusOK     = "ok"     // No event with an error or failed status
+	SessionStatusFailed = "failed" // At least one error or failed event
+)
 
-	for _, date := range dates {
-		query := s.session.Query(`
-			SELECT facto_id, agent_id, session_id, parent_facto_id,
-			       action_type, status, input_data, output_data,
-			       model_id, model_hash, temperature, seed, max_tokens, tool_calls,
-			       sdk_version, sdk_language, tags,
-			       signature, public_key, prev_hash, event_hash,
-			       started_at, completed_at
-			FROM events
-			WHERE agent_id = ? AND date = ?
-			  AND completed_at >= ? AND completed_at <= ?
-			LIMIT ?
-		`, agentID, date, start, end, limit+1).WithContext(ctx)
-
-		iter := query.Iter()
-
-		var (
-			factoID, sessionID, parentFactoID string
-			actionType, status                string
-			inputData, outputData             []byte
-			modelID, modelHash                string
-			temperature                       float32
-			seed                              int64
-			maxTokens                         int32
-			toolCalls                         string
-			sdkVersion, sdkLanguage           string
-			tags                              map[string]string
-			signature, publicKey              []byte
-			prevHash, eventHash               string
-			startedAt, completedAt            time.Time
-		)
+// GetAgentSessions lists an agent's sessions from the processor-maintained
+// sessions_by_agent table, in session_id order, with counts from
+// session_summaries. Sessions outside [start, end] are dropped from each page
+// after it is read, so a page may hold fewer than limit sessions while
+// next_cursor is still set. A zero start or end leaves that side open.
+func (s *Storage) GetAgentSessions(ctx context.Context, agentID string, start, end time.Time, limit int, cursor string) ([]SessionSummary, *string, error) {
+	var pageState []byte
+	if cursor != "" {
+		var err error
+		if pageState, err = base64.URLEncoding.DecodeString(cursor); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
 
-		for iter.Scan(
-			&factoID, &agentID, &sessionID, &parentFactoID,
-			&actionType, &status, &inputData, &outputData,
-			&modelID, &modelHash, &temperature, &seed, &maxTokens, &toolCalls,
-			&sdkVersion, &sdkLanguage, &tags,
-			&signature, &publicKey, &prevHash, &eventHash,
-			&startedAt, &completedAt,
-		) {
-			event := buildEventResponse(
-				factoID, agentID, sessionID, parentFactoID,
-				actionType, status, inputData, outputData,
-				modelID, modelHash, temperature, seed, maxTokens, toolCalls,
-				sdkVersion, sdkLanguage, tags,
-				signature, publicKey, prevHash, eventHash,
-				startedAt, completedAt,
-			)
-			events = append(events, event)
-
-			if len(events) >= limit {
-				break
-			}
+	iter := s.session.Query(`
+		SELECT session_id, first_event_at, last_event_at, has_failures
+		FROM sessions_by_agent WHERE agent_id = ?
+	`, agentID).WithContext(ctx).PageSize(limit).PageState(pageState).Iter()
+
+	// Only this page's rows; see ScanAllEvents
+	rows := iter.NumRows()
+	sessions := make([]SessionSummary, 0, rows)
+	for i := 0; i < rows; i++ {
+		var session SessionSummary
+		var failed bool
+		if !iter.Scan(&session.SessionID, &session.FirstEventAt, &session.LastEventAt, &failed) {
+			break
+		}
+		if !start.IsZero() && session.LastEventAt.Before(start) {
+			continue
+		}
+		if !end.IsZero() && session.FirstEventAt.After(end) {
+			continue
+		}
+		session.Status = SessionStatusOK
+		if failed {
+			session.Status = SessionStatusFailed
+		}
+		sessions = append(sessions, session)
+	}
+
+	nextPage := iter.PageState()
+	if err := iter.Close(); err != nil {
+		log.Error().Err(err).Str("agent_id", agentID).Msg("Error iterating agent sessions")
+		return nil, nil, err
+	}
+
+	if len(sessions) > 0 {
+		ids := make([]string, len(sessions))
+		for i, session := range sessions {
+			ids[i] = session.SessionID
 		}
 
+		counts := make(map[string]int64, len(ids))
+		iter := s.session.Query(`
+			SELECT session_id, event_count FROM session_summaries WHERE session_id IN ?
+		`, ids).WithContext(ctx).Iter()
+		var sessionID string
+		var count int64
+		for iter.Scan(&sessionID, &count) {
+			counts[sessionID] = count
+		}
 		if err := iter.Close(); err != nil {
-			log.Error().Err(err).Msg("Error iterating events")
 			return nil, nil, err
 		}
 
-		if len(events) >= limit {
-			break
+		for i := range sessions {
+			sessions[i].EventCount = counts[sessions[i].SessionID]
 		}
 	}
 
-	// Handle pagination
 	var nextCursor *string
-	if len(events) > limit {
-		events = events[:limit]
-		lastEvent := events[len(events)-1]
-		cursor := base64.StdEncoding.EncodeToString([]byte(lastEvent.FactoID))
-		nextCursor = &cursor
+	if len(nextPage) > 0 {
+		encoded := base64.URLEncoding.EncodeToString(nextPage)
+		nextCursor = &encoded
 	}
 
-	return events, nextCursor, nil
+	return sessions, nextCursor, nil
 }
 
-// GetEventByFactoID retrieves a single event by facto_id
-func (s *Storage) GetEventByFactoID(ctx context.Context, factoID string) (*EventResponse, error) {
-	query := s.session.Query(`
-		SELECT facto_id, agent_id, date, completed_at, session_id,
-		       action_type, status, input_data, output_data,
-		       model_id, model_hash, temperature, seed, max_tokens, tool_calls,
-		       sdk_version, sdk_language, tags,
-		       signature, public_key, prev_hash, event_hash,
-		       parent_facto_id, started_at
-		FROM events_by_facto_id
-		WHERE facto_id = ?
-	`, factoID).WithContext(ctx)
+// GetMerkleRootsByHash returns every stored root with rootHash, with event
+// hashes. Usually there is one; a daily root over a single batch repeats
+// the batch root's hash.
+func (s *Storage) GetMerkleRootsByHash(ctx context.Context, rootHash string) ([]MerkleRoot, error) {
+	iter := s.session.Query(`
+		SELECT date, bucket_time FROM merkle_roots_by_hash WHERE root_hash = ?
+	`, rootHash).WithContext(ctx).Iter()
 
-	var (
-		agentID, sessionID, parentFactoID string
-		date                              time.Time
-		completedAt, startedAt            time.Time
-		actionType, status                string
-		inputData, outputData             []byte
-		modelID, modelHash                string
-		temperature                       float32
-		seed                              int64
-		maxTokens                         int32
-		toolCalls                         string
-		sdkVersion, sdkLanguage           string
-		tags                              map[string]string
-		signature, publicKey              []byte
-		prevHash, eventHash               string
-	)
+	type location struct{ date, bucketTime time.Time }
+	var locations []location
+	var loc location
+	for iter.Scan(&loc.date, &loc.bucketTime) {
+		locations = append(locations, loc)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
 
-	if err := query.Scan(
-		&factoID, &agentID, &date, &completedAt, &sessionID,
-		&actionType, &status, &inputData, &outputData,
-		&modelID, &modelHash, &temperature, &seed, &maxTokens, &toolCalls,
-		&sdkVersion, &sdkLanguage, &tags,
-		&signature, &publicKey, &prevHash, &eventHash,
-		&parentFactoID, &startedAt,
+	var roots []MerkleRoot
+	for _, loc := range locations {
+		root, err := s.GetMerkleRoot(ctx, loc.date, loc.bucketTime)
+		if err != nil {
+			return nil, err
+		}
+		// Skip index rows left behind by an interrupted prune
+		if root != nil && root.RootHash == rootHash {
+			roots = append(roots, *root)
+		}
+	}
+	return roots, nil
+}
+
+// SessionStart marks the genesis event of a session
+type SessionStart struct {
+	SessionID    string    `json:"session_id"`
+	AgentID      string    `json:"agent_id"`
+	FirstFactoID string    `json:"first_facto_id"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// GetSessionStart retrieves the start marker of a session, or nil if none exists
+func (s *Storage) GetSessionStart(ctx context.Context, sessionID string) (*SessionStart, error) {
+	start := SessionStart{SessionID: sessionID}
+
+	if err := s.session.Query(`
+		SELECT agent_id, first_facto_id, started_at
+		FROM session_starts
+		WHERE session_id = ?
+	`, sessionID).WithContext(ctx).Scan(
+		&start.AgentID, &start.FirstFactoID, &start.StartedAt,
 	); err != nil {
 		if err == gocql.ErrNotFound {
 			return nil, nil
@@ -164,90 +1379,401 @@ func (s *Storage) GetEventByFactoID(ctx context.Context, factoID string) (*Event
 		return nil, err
 	}
 
-	event := buildEventResponse(
-		factoID, agentID, sessionID, parentFactoID,
-		actionType, status, inputData, outputData,
-		modelID, modelHash, temperature, seed, maxTokens, toolCalls,
-		sdkVersion, sdkLanguage, tags,
-		signature, publicKey, prevHash, eventHash,
-		startedAt, completedAt,
-	)
+	return &start, nil
+}
 
-	return &event, nil
+// GetSessionEventCount returns the processor-maintained event count of a session
+func (s *Storage) GetSessionEventCount(ctx context.Context, sessionID string) (int64, error) {
+	var count int64
+	err := s.session.Query(`
+		SELECT event_count FROM session_summaries WHERE session_id = ?
+	`, sessionID).WithContext(ctx).Scan(&count)
+	if err == gocql.ErrNotFound {
+		return 0, nil
+	}
+	return count, err
 }
 
-// GetSessionEvents retrieves all events for a session
-func (s *Storage) GetSessionEvents(ctx context.Context, sessionID string, limit int, cursor string) ([]EventResponse, *string, error) {
-	var events []EventResponse
+// SessionMetadata represents session-level metadata
+type SessionMetadata struct {
+	SessionID   string            `json:"session_id"`
+	AgentID     string            `json:"agent_id"`
+	Tags        map[string]string `json:"tags"`
+	Description string            `json:"description"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
 
-	query := s.session.Query(`
-		SELECT session_id, completed_at, facto_id, agent_id,
-		       action_type, status, event_hash,
-		       input_data, output_data,
-		       model_id, temperature,
-		       sdk_version, sdk_language, tags,
-		       signature, public_key, prev_hash,
-		       parent_facto_id, started_at
+// GetSessionMetadata retrieves the metadata of a session, or nil if none exists
+func (s *Storage) GetSessionMetadata(ctx context.Context, sessionID string) (*SessionMetadata, error) {
+	meta := SessionMetadata{SessionID: sessionID}
+
+	if err := s.session.Query(`
+		SELECT agent_id, tags, description, created_at, updated_at
+		FROM session_metadata
+		WHERE session_id = ?
+	`, sessionID).WithContext(ctx).Scan(
+		&meta.AgentID, &meta.Tags, &meta.Description, &meta.CreatedAt, &meta.UpdatedAt,
+	); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if meta.Tags == nil {
+		meta.Tags = make(map[string]string)
+	}
+
+	return &meta, nil
+}
+
+// UpdateSessionMetadata merges tags into a session's metadata and, when
+// description is non-nil, replaces its description. It returns false when
+// the session has no metadata row. The processor creates rows with IF NOT
+// EXISTS, and mixing lightweight transactions with plain writes on a row
+// can lose either, so this is one too.
+func (s *Storage) UpdateSessionMetadata(ctx context.Context, sessionID string, tags map[string]string, description *string) (bool, error) {
+	var query *gocql.Query
+	if description != nil {
+		query = s.session.Query(`
+			UPDATE session_metadata
+			SET tags = tags + ?, description = ?, updated_at = ?
+			WHERE session_id = ?
+			IF EXISTS
+		`, tags, *description, time.Now(), sessionID)
+	} else {
+		query = s.session.Query(`
+			UPDATE session_metadata
+			SET tags = tags + ?, updated_at = ?
+			WHERE session_id = ?
+			IF EXISTS
+		`, tags, time.Now(), sessionID)
+	}
+
+	return query.WithContext(ctx).Consistency(s.writeConsistency).MapScanCAS(map[string]interface{}{})
+}
+
+// RawColumn is one stored column as read from ScyllaDB
+type RawColumn struct {
+	Name  string
+	Type  string
+	Value interface{}
+}
+
+// GetRawEvent returns every column of an event's events_by_facto_id row, in
+// table order, without any decoding. Returns nil if the event doesn't exist.
+func (s *Storage) GetRawEvent(ctx context.Context, factoID string) ([]RawColumn, error) {
+	iter := s.session.Query(`
+		SELECT * FROM events_by_facto_id WHERE facto_id = ?
+	`, factoID).WithContext(ctx).Iter()
+
+	row := make(map[string]interface{})
+	found := iter.MapScan(row)
+	columns := iter.Columns()
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+
+	raw := make([]RawColumn, len(columns))
+	for i, col := range columns {
+		raw[i] = RawColumn{Name: col.Name, Type: col.TypeInfo.Type().String(), Value: row[col.Name]}
+	}
+	return raw, nil
+}
+
+// SessionEventKey holds the primary key columns needed to delete one event
+// from every event table
+type SessionEventKey struct {
+	AgentID     string
+	FactoID     string
+	EventHash   string
+	CompletedAt time.Time
+}
+
+// GetSessionEventKeys returns the keys of all of a session's events, in chain order
+func (s *Storage) GetSessionEventKeys(ctx context.Context, sessionID string) ([]SessionEventKey, error) {
+	iter := s.session.Query(`
+		SELECT agent_id, facto_id, event_hash, completed_at
 		FROM events_by_session
 		WHERE session_id = ?
-		LIMIT ?
-	`, sessionID, limit+1).WithContext(ctx)
+	`, sessionID).WithContext(ctx).Iter()
 
-	iter := query.Iter()
+	var keys []SessionEventKey
+	var key SessionEventKey
+	for iter.Scan(&key.AgentID, &key.FactoID, &key.EventHash, &key.CompletedAt) {
+		keys = append(keys, key)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
 
-	var (
-		factoID, agentID, parentFactoID string
-		completedAt, startedAt          time.Time
-		actionType, status              string
-		eventHash                       string
-		inputData, outputData           []byte
-		modelID                         string
-		temperature                     float32
-		sdkVersion, sdkLanguage         string
-		tags                            map[string]string
-		signature, publicKey            []byte
-		prevHash                        string
-	)
+// DeletionManifest is the signed record of an admin session deletion
+type DeletionManifest struct {
+	ManifestID  string    `json:"manifest_id"`
+	SessionID   string    `json:"session_id"`
+	AgentID     string    `json:"agent_id"`
+	EventCount  int       `json:"event_count"`
+	SessionRoot string    `json:"session_root"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	Signature   string    `json:"signature"`
+	PublicKey   string    `json:"public_key"`
+	Status      string    `json:"status"`
+}
 
-	for iter.Scan(
-		&sessionID, &completedAt, &factoID, &agentID,
-		&actionType, &status, &eventHash,
-		&inputData, &outputData,
-		&modelID, &temperature,
-		&sdkVersion, &sdkLanguage, &tags,
-		&signature, &publicKey, &prevHash,
-		&parentFactoID, &startedAt,
-	) {
-		event := buildEventResponse(
-			factoID, agentID, sessionID, parentFactoID,
-			actionType, status, inputData, outputData,
-			modelID, "", temperature, 0, 0, "[]",
-			sdkVersion, sdkLanguage, tags,
-			signature, publicKey, prevHash, eventHash,
-			startedAt, completedAt,
-		)
-		events = append(events, event)
+// StoreDeletionManifest writes (or overwrites) a deletion manifest
+func (s *Storage) StoreDeletionManifest(ctx context.Context, m *DeletionManifest) error {
+	return s.session.Query(`
+		INSERT INTO deletion_manifests (
+			session_id, deleted_at, manifest_id, agent_id, event_count,
+			session_root, signature, public_key, status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, m.SessionID, m.DeletedAt, m.ManifestID, m.AgentID, m.EventCount,
+		m.SessionRoot, m.Signature, m.PublicKey, m.Status,
+	).WithContext(ctx).Consistency(s.writeConsistency).Exec()
+}
 
-		if len(events) > limit {
+// deleteBatchSize bounds the statements per unlogged delete batch
+const deleteBatchSize = 50
+
+// DeleteSessionEvents deletes a session's events from the events and
+// events_by_facto_id tables and its sessions_by_agent rows, then its
+// events_by_session partition and session-level rows
+func (s *Storage) DeleteSessionEvents(ctx context.Context, sessionID string, keys []SessionEventKey) error {
+	newBatch := func() *gocql.Batch {
+		batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		batch.SetConsistency(s.writeConsistency)
+		return batch
+	}
+	if err := deleteEventChunks(sessionID, keys, newBatch, s.session.ExecuteBatch); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"events_by_session", "session_starts", "session_summaries"} {
+		if err := s.session.Query(
+			"DELETE FROM "+table+" WHERE session_id = ?", sessionID,
+		).WithContext(ctx).Consistency(s.writeConsistency).Exec(); err != nil {
+			return fmt.Errorf("deleting from %s: %w", table, err)
+		}
+	}
+	// session_metadata is only written with lightweight transactions
+	if _, err := s.session.Query(
+		"DELETE FROM session_metadata WHERE session_id = ? IF EXISTS", sessionID,
+	).WithContext(ctx).Consistency(s.writeConsistency).MapScanCAS(map[string]interface{}{}); err != nil {
+		return fmt.Errorf("deleting from session_metadata: %w", err)
+	}
+	return nil
+}
+
+// deleteEventChunks deletes keys from the events and events_by_facto_id
+// tables in batches from newBatch of deleteBatchSize events each. The last
+// batch also deletes the session's sessions_by_agent row for every agent
+// with an event in it, so the session leaves the agent listings once its
+// events are gone.
+func deleteEventChunks(sessionID string, keys []SessionEventKey, newBatch func() *gocql.Batch, execute func(*gocql.Batch) error) error {
+	var agentIDs []string
+	for _, key := range keys {
+		agentIDs = appendUnique(agentIDs, key.AgentID)
+	}
+
+	for i := 0; i < len(keys); i += deleteBatchSize {
+		end := i + deleteBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		batch := newBatch()
+		for _, key := range keys[i:end] {
+			date := key.CompletedAt.UTC().Truncate(24 * time.Hour)
+			batch.Query(`
+				DELETE FROM events
+				WHERE agent_id = ? AND date = ? AND completed_at = ? AND facto_id = ?
+			`, key.AgentID, date, key.CompletedAt, key.FactoID)
+			batch.Query(`DELETE FROM events_by_facto_id WHERE facto_id = ?`, key.FactoID)
+		}
+		if end == len(keys) {
+			for _, agentID := range agentIDs {
+				batch.Query(`DELETE FROM sessions_by_agent WHERE agent_id = ? AND session_id = ?`, agentID, sessionID)
+			}
+		}
+		if err := execute(batch); err != nil {
+			return fmt.Errorf("deleting events %d-%d: %w", i, end, err)
+		}
+	}
+	return nil
+}
+
+// rebuildColumns lists, for each lookup table that can be rebuilt, the
+// columns copied into it. Every one is also a column of its source table.
+var rebuildColumns = map[string][]string{
+	"merkle_roots_by_hash": {"root_hash", "date", "bucket_time"},
+	"events_by_facto_id": {
+		"facto_id", "agent_id", "date", "completed_at", "session_id",
+		"action_type", "status", "input_data", "output_data",
+		"model_id", "model_hash", "temperature", "seed", "max_tokens", "tool_calls", "meta_present",
+		"sdk_version", "sdk_language", "tags", "custom_fields",
+		"signature", "public_key", "sig_algo", "canonical_version", "prev_hash", "event_hash",
+		"parent_facto_id", "started_at", "received_at", "stream_seq",
+	},
+	"events_by_session": {
+		"session_id", "completed_at", "facto_id", "agent_id",
+		"action_type", "status", "event_hash",
+		"input_data", "output_data",
+		"model_id", "model_hash", "temperature", "seed", "max_tokens", "tool_calls", "meta_present",
+		"sdk_version", "sdk_language", "tags", "custom_fields",
+		"signature", "public_key", "sig_algo", "canonical_version", "prev_hash",
+		"parent_facto_id", "started_at", "received_at", "stream_seq",
+	},
+}
+
+// rebuildSources maps lookup tables not rebuilt from the events table to
+// their source table
+var rebuildSources = map[string]string{
+	"merkle_roots_by_hash": "merkle_roots",
+}
+
+// rebuildBatchSize bounds the statements per unlogged rebuild batch
+const rebuildBatchSize = 50
+
+// RebuildIndexPage copies one page of a lookup table's source table (the
+// events table unless listed in rebuildSources), in token order, into it. Rows are upserted, so re-running a page is harmless. The
+// returned cursor continues after this page and is nil when the scan is done.
+func (s *Storage) RebuildIndexPage(ctx context.Context, table string, pageSize int, cursor string) (int, *string, error) {
+	columns, ok := rebuildColumns[table]
+	if !ok {
+		return 0, nil, fmt.Errorf("table %s cannot be rebuilt", table)
+	}
+
+	var pageState []byte
+	if cursor != "" {
+		var err error
+		if pageState, err = base64.URLEncoding.DecodeString(cursor); err != nil {
+			return 0, nil, ErrInvalidCursor
+		}
+	}
+
+	columnList := strings.Join(columns, ", ")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	insert := "INSERT INTO " + table + " (" + columnList + ") VALUES (" + placeholders + ")"
+
+	source := "events"
+	if src, ok := rebuildSources[table]; ok {
+		source = src
+	}
+
+	iter := s.session.Query(
+		"SELECT " + columnList + " FROM " + source,
+	).WithContext(ctx).PageSize(pageSize).PageState(pageState).Iter()
+
+	// Only this page's rows; see ScanAllEvents
+	rows := iter.NumRows()
+	batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+	batch.SetConsistency(s.writeConsistency)
+	written := 0
+
+	for i := 0; i < rows; i++ {
+		row := make(map[string]interface{}, len(columns))
+		if !iter.MapScan(row) {
 			break
 		}
+
+		values := make([]interface{}, len(columns))
+		for j, col := range columns {
+			values[j] = row[col]
+			// Null timestamps scan as the zero time; keep them null
+			if t, ok := values[j].(time.Time); ok && t.IsZero() {
+				values[j] = nil
+			}
+		}
+		batch.Query(insert, values...)
+
+		if batch.Size() == rebuildBatchSize {
+			if err := s.session.ExecuteBatch(batch); err != nil {
+				iter.Close()
+				return written, nil, err
+			}
+			written += batch.Size()
+			batch = s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+			batch.SetConsistency(s.writeConsistency)
+		}
 	}
 
+	nextPage := iter.PageState()
 	if err := iter.Close(); err != nil {
-		log.Error().Err(err).Msg("Error iterating session events")
-		return nil, nil, err
+		return written, nil, err
+	}
+
+	if batch.Size() > 0 {
+		if err := s.session.ExecuteBatch(batch); err != nil {
+			return written, nil, err
+		}
+		written += batch.Size()
 	}
 
-	// Handle pagination
 	var nextCursor *string
-	if len(events) > limit {
-		events = events[:limit]
-		lastEvent := events[len(events)-1]
-		cursor := base64.StdEncoding.EncodeToString([]byte(lastEvent.FactoID))
-		nextCursor = &cursor
+	if len(nextPage) > 0 {
+		encoded := base64.URLEncoding.EncodeToString(nextPage)
+		nextCursor = &encoded
 	}
 
-	return events, nextCursor, nil
+	return written, nextCursor, nil
+}
+
+// Ping runs a lightweight query to check that ScyllaDB is reachable
+func (s *Storage) Ping(ctx context.Context) error {
+	if !s.IsOpen() {
+		return errors.New("storage session is closed")
+	}
+	return s.session.Query(`SELECT now() FROM system.local`).WithContext(ctx).Exec()
+}
+
+// MonitorHealth pings ScyllaDB every interval until ctx is cancelled and
+// publishes the result as the facto_storage_up gauge. gocql reconnects on its
+// own, so the gauge returns to 1 as soon as a ping succeeds again.
+func (s *Storage) MonitorHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		setStorageUp(s.Ping(pingCtx))
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setStorageUp publishes a ping result as the facto_storage_up gauge,
+// logging only when it changes
+func setStorageUp(err error) {
+	if err != nil {
+		if storageUpValue.Load() {
+			log.Warn().Err(err).Msg("Storage ping failed")
+		}
+		storageUp.Set(0)
+		storageUpValue.Store(false)
+		return
+	}
+
+	if !storageUpValue.Load() {
+		log.Info().Msg("Storage ping succeeded")
+	}
+	storageUp.Set(1)
+	storageUpValue.Store(true)
+}
+
+// IsOpen reports whether the storage session exists and hasn't been closed
+func (s *Storage) IsOpen() bool {
+	return s.session != nil && !s.session.Closed()
 }
 
 // Close closes the storage connection
@@ -273,6 +1799,17 @@ func getDateRange(start, end time.Time) []time.Time {
 	return dates
 }
 
+// countDatePartitions returns the number of date partitions getDateRange
+// would produce, without materializing them
+func countDatePartitions(start, end time.Time) int {
+	startDate := start.UTC().Truncate(24 * time.Hour)
+	endDate := end.UTC().Truncate(24 * time.Hour)
+	if endDate.Before(startDate) {
+		return 0
+	}
+	return int(endDate.Sub(startDate)/(24*time.Hour)) + 1
+}
+
 func buildEventResponse(
 	factoID, agentID, sessionID, parentFactoID string,
 	actionType, status string,
@@ -282,20 +1819,29 @@ func buildEventResponse(
 	seed int64,
 	maxTokens int32,
 	toolCalls string,
+	metaPresent []string,
 	sdkVersion, sdkLanguage string,
 	tags map[string]string,
 	signature, publicKey []byte,
+	sigAlgo string,
 	prevHash, eventHash string,
 	startedAt, completedAt time.Time,
 ) EventResponse {
-	// Parse input/output data
+	// Parse input/output data. Unparseable blobs are flagged rather than
+	// silently replaced, so verification failures can be told apart from
+	// storage corruption.
 	var input, output map[string]interface{}
-	json.Unmarshal(inputData, &input)
-	json.Unmarshal(outputData, &output)
+	corrupt := unmarshalStored(inputData, &input)
+	corrupt = unmarshalStored(outputData, &output) || corrupt
 
 	// Parse tool calls
 	var tools []interface{}
-	json.Unmarshal([]byte(toolCalls), &tools)
+	corrupt = unmarshalStored([]byte(toolCalls), &tools) || corrupt
+
+	if corrupt {
+		corruptEventsTotal.Inc()
+		log.Warn().Str("facto_id", factoID).Msg("Stored event data is not valid JSON")
+	}
 
 	// Build execution meta
 	var modelIDPtr *string
@@ -308,19 +1854,36 @@ func buildEventResponse(
 		modelHashPtr = &modelHash
 	}
 
+	// meta_present says which numeric fields the event set, so 0 round-trips.
+	// Rows written before it existed stored 0 for absent values and have no
+	// meta_present, so for them a zero value still means absent.
+	present := func(field string, nonZero bool) bool {
+		if len(metaPresent) == 0 {
+			return nonZero
+		}
+		for _, f := range metaPresent {
+			if f == field {
+				return true
+			}
+		}
+		return false
+	}
+
 	var tempPtr *float64
-	if temperature != 0 {
-		t := float64(temperature)
+	if present("temperature", temperature != 0) {
+		// Widen via the shortest float32 decimal, so a stored 0.7 reads back
+		// as 0.7 and not 0.699999988079071
+		t, _ := strconv.ParseFloat(strconv.FormatFloat(float64(temperature), 'g', -1, 32), 64)
 		tempPtr = &t
 	}
 
 	var seedPtr *int64
-	if seed != 0 {
+	if present("seed", seed != 0) {
 		seedPtr = &seed
 	}
 
 	var maxTokensPtr *int32
-	if maxTokens != 0 {
+	if present("max_tokens", maxTokens != 0) {
 		maxTokensPtr = &maxTokens
 	}
 
@@ -368,10 +1931,30 @@ func buildEventResponse(
 		Proof: ProofResponse{
 			Signature: string(signature), // Stored as base64 bytes, no need to re-encode
 			PublicKey: string(publicKey), // Stored as base64 bytes, no need to re-encode
+			SigAlgo:   sigAlgo,
 			PrevHash:  prevHash,
 			EventHash: eventHash,
 		},
 		StartedAt:   startedAt.UnixNano(),
 		CompletedAt: completedAt.UnixNano(),
+		DataCorrupt: corrupt,
+	}
+}
+
+// optionalStreamSeq maps a stored stream_seq to the response field. JetStream
+// sequences start at 1, so 0 (or null) means it wasn't recorded.
+func optionalStreamSeq(seq int64) *int64 {
+	if seq == 0 {
+		return nil
+	}
+	return &seq
+}
+
+// unmarshalStored decodes a stored JSON column, reporting whether it is
+// corrupt. Empty columns are treated as absent, not corrupt.
+func unmarshalStored(data []byte, v interface{}) bool {
+	if len(data) == 0 {
+		return false
 	}
+	return canonical.Unmarshal(data, v) != nil
 }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/cqlconn"
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// buildTestEvent builds an EventResponse from stored blobs, with every other
+// column empty
+func buildTestEvent(inputData, outputData []byte, toolCalls string) EventResponse {
+	return buildEventResponse(
+		"ft-1", "agent", "session", "",
+		"llm_call", "success", inputData, outputData,
+		"", "", 0, 0, 0, toolCalls, nil,
+		"", "", nil,
+		nil, nil, "", "", "",
+		time.Unix(0, 0), time.Unix(0, 0),
+	)
+}
+
+func TestBuildEventResponseFlagsCorruptJSON(t *testing.T) {
+	before := testutil.ToFloat64(corruptEventsTotal)
+
+	valid := buildTestEvent([]byte(`{"prompt":"hi"}`), []byte(`{}`), `[]`)
+	if valid.DataCorrupt || valid.InputData["prompt"] != "hi" {
+		t.Errorf("valid blobs flagged or lost: %+v", valid)
+	}
+	if got := testutil.ToFloat64(corruptEventsTotal); got != before {
+		t.Errorf("a valid event counted as corrupt")
+	}
+
+	for name, event := range map[string]EventResponse{
+		"input_data":  buildTestEvent([]byte(`{"prompt":`), []byte(`{}`), `[]`),
+		"output_data": buildTestEvent([]byte(`{}`), []byte{0xff, 0xfe}, `[]`),
+		"tool_calls":  buildTestEvent([]byte(`{}`), []byte(`{}`), `[{"name"`),
+	} {
+		if !event.DataCorrupt {
+			t.Errorf("corrupt %s not flagged", name)
+		}
+	}
+	if got := testutil.ToFloat64(corruptEventsTotal); got != before+3 {
+		t.Errorf("corrupt events metric rose by %v, want 3", got-before)
+	}
+}
+
+func TestStorageUpFollowsPing(t *testing.T) {
+	setStorageUp(errors.New("no hosts available"))
+	if got := testutil.ToFloat64(storageUp); got != 0 {
+		t.Errorf("after a failed ping facto_storage_up = %v, want 0", got)
+	}
+	setStorageUp(nil)
+	if got := testutil.ToFloat64(storageUp); got != 1 {
+		t.Errorf("after a successful ping facto_storage_up = %v, want 1", got)
+	}
+	setStorageUp(errors.New("no hosts available"))
+	if got := testutil.ToFloat64(storageUp); got != 0 {
+		t.Errorf("after the storage went down facto_storage_up = %v, want 0", got)
+	}
+}
+
+func TestClusterConfigNumConns(t *testing.T) {
+	cluster := newClusterConfig([]string{"scylla"}, StorageOptions{NumConns: 8, MaxRequestsPerConn: 256, Keyspace: "facto", ReadConsistency: gocql.LocalQuorum})
+	if cluster.NumConns != 8 || cluster.Keyspace != "facto" || cluster.Consistency != gocql.LocalQuorum {
+		t.Errorf("got num_conns %d, keyspace %q, consistency %v", cluster.NumConns, cluster.Keyspace, cluster.Consistency)
+	}
+	dialer, ok := cluster.Dialer.(*cqlconn.LimitDialer)
+	if !ok || dialer.MaxRequests != 256 {
+		t.Fatalf("dialer %#v doesn't limit connections to 256 requests", cluster.Dialer)
+	}
+	if dialer.Dialer.Timeout != cluster.ConnectTimeout {
+		t.Errorf("dial timeout %v, want the connect timeout %v", dialer.Dialer.Timeout, cluster.ConnectTimeout)
+	}
+
+	// Unset keeps the driver defaults
+	cluster = newClusterConfig([]string{"scylla"}, StorageOptions{})
+	if cluster.NumConns != gocql.NewCluster().NumConns {
+		t.Errorf("num_conns %d without SCYLLA_NUM_CONNS", cluster.NumConns)
+	}
+	if cluster.Dialer != nil {
+		t.Errorf("dialer %#v without SCYLLA_MAX_REQUESTS_PER_CONN", cluster.Dialer)
+	}
+}
+
+func TestSpansDay(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time { return day.Add(time.Duration(hours * float64(time.Hour))) }
+	cases := []struct {
+		name        string
+		first, last time.Time
+		want        bool
+	}{
+		{"within the day", at(1), at(2), true},
+		{"ends on the day", at(-5), at(0), true},
+		{"starts at the last instant", at(24).Add(-time.Nanosecond), at(30), true},
+		{"covers the day", at(-24), at(48), true},
+		{"ends the day before", at(-5), at(0).Add(-time.Nanosecond), false},
+		{"starts the day after", at(24), at(25), false},
+	}
+	for _, tc := range cases {
+		// The time of day in date doesn't matter
+		if got := spansDay(tc.first, tc.last, at(13)); got != tc.want {
+			t.Errorf("%s: spansDay = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// pagedIter stands in for a *gocql.Iter over pages of facto_ids, fetching
+// the next page, as the driver does, once a scan runs past the current one
+type pagedIter struct {
+	pages  [][]string
+	states []string
+	page   int
+	row    int
+}
+
+func (it *pagedIter) Scan(dest ...interface{}) bool {
+	if it.row == len(it.pages[it.page]) {
+		if it.page == len(it.pages)-1 {
+			return false
+		}
+		it.page, it.row = it.page+1, 0
+	}
+	*dest[0].(*string) = it.pages[it.page][it.row]
+	it.row++
+	return true
+}
+
+func (it *pagedIter) NumRows() int      { return len(it.pages[it.page]) }
+func (it *pagedIter) PageState() []byte { return []byte(it.states[it.page]) }
+func (it *pagedIter) Close() error      { return nil }
+
+func TestReadEventPageStopsAtShortPage(t *testing.T) {
+	// A page of 2 fetched for a page size of 3, with more rows behind it
+	iter := &pagedIter{
+		pages:  [][]string{{"ft-1", "ft-2"}, {"ft-3", "ft-4", "ft-5"}},
+		states: []string{"after-ft-2", "after-ft-5"},
+	}
+	events, nextPage, err := readEventPage(iter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].FactoID != "ft-1" || events[1].FactoID != "ft-2" {
+		t.Errorf("read %+v, want ft-1 and ft-2", events)
+	}
+	if string(nextPage) != "after-ft-2" {
+		t.Errorf("paging state %q, want the one after ft-2", nextPage)
+	}
+}
+
+// partitionReader stands in for getPartitionEvents, returning up to perDate
+// events for a date after delay, the round trip to ScyllaDB
+func partitionReader(perDate int, delay time.Duration) func(ctx context.Context, date time.Time, limit int) ([]EventResponse, error) {
+	return func(ctx context.Context, date time.Time, limit int) ([]EventResponse, error) {
+		time.Sleep(delay)
+		events := make([]EventResponse, min(perDate, limit))
+		for i := range events {
+			events[i].FactoID = fmt.Sprintf("%s-%d", date.Format("2006-01-02"), i)
+		}
+		return events, nil
+	}
+}
+
+func TestReadPartitionsStopsOnceFull(t *testing.T) {
+	dates := getDateRange(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC))
+
+	// Two waves of two: the first fills 6 of 7, the second is asked for 1
+	// from each of its partitions, and the rest are never read
+	events, partitions, err := readPartitions(context.Background(), nil, dates, 7, 2, partitionReader(3, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitions != 4 {
+		t.Errorf("read %d partitions, want 4", partitions)
+	}
+	want := []string{"2026-01-01-0", "2026-01-01-1", "2026-01-01-2", "2026-01-02-0", "2026-01-02-1", "2026-01-02-2", "2026-01-03-0", "2026-01-04-0"}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, e := range events {
+		if e.FactoID != want[i] {
+			t.Errorf("event %d is %s, want %s", i, e.FactoID, want[i])
+		}
+	}
+
+	failing := func(ctx context.Context, date time.Time, limit int) ([]EventResponse, error) {
+		return nil, errors.New("timeout")
+	}
+	if _, _, err := readPartitions(context.Background(), nil, dates, 7, 2, failing); err == nil {
+		t.Error("a failed partition read wasn't returned")
+	}
+}
+
+// BenchmarkReadPartitions compares reading a 30-day events query one
+// partition at a time with the default PARTITION_QUERY_CONCURRENCY, for a
+// page that needs every partition
+func BenchmarkReadPartitions(b *testing.B) {
+	end := time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC)
+	dates := getDateRange(end.AddDate(0, 0, -29), end)
+	read := partitionReader(10, time.Millisecond)
+	for _, bc := range []struct {
+		name        string
+		concurrency int
+	}{{"serial", 1}, {"concurrent", 8}} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := readPartitions(context.Background(), nil, dates, 1001, bc.concurrency, read); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteEventChunksCoversEveryTable(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	keys := make([]SessionEventKey, deleteBatchSize+1)
+	for i := range keys {
+		keys[i] = SessionEventKey{AgentID: fmt.Sprintf("agent-%d", i%2), CompletedAt: base.Add(time.Duration(i) * time.Second), FactoID: fmt.Sprintf("ft-%d", i)}
+	}
+
+	session := &gocql.Session{}
+	newBatch := func() *gocql.Batch { return session.NewBatch(gocql.UnloggedBatch) }
+	var batches []*gocql.Batch
+	execute := func(b *gocql.Batch) error {
+		batches = append(batches, b)
+		return nil
+	}
+	if err := deleteEventChunks("session-1", keys, newBatch, execute); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 {
+		t.Fatalf("%d batches, want 2", len(batches))
+	}
+
+	// Rows deleted per table, keyed by facto_id or agent_id/session_id
+	deleted := map[string]map[string]int{}
+	for i, batch := range batches {
+		for _, entry := range batch.Entries {
+			table := strings.Fields(entry.Stmt)[2]
+			if deleted[table] == nil {
+				deleted[table] = map[string]int{}
+			}
+			switch table {
+			case "events":
+				deleted[table][entry.Args[3].(string)]++
+			case "events_by_facto_id":
+				deleted[table][entry.Args[0].(string)]++
+			case "sessions_by_agent":
+				if i != len(batches)-1 {
+					t.Errorf("sessions_by_agent deleted in batch %d, before the events are gone", i)
+				}
+				deleted[table][entry.Args[0].(string)+"/"+entry.Args[1].(string)]++
+			default:
+				t.Errorf("unexpected delete from %s", table)
+			}
+		}
+	}
+	for _, table := range []string{"events", "events_by_facto_id"} {
+		for _, key := range keys {
+			if deleted[table][key.FactoID] != 1 {
+				t.Errorf("%s deleted from %s %d times", key.FactoID, table, deleted[table][key.FactoID])
+			}
+		}
+	}
+	if got := deleted["sessions_by_agent"]; len(got) != 2 || got["agent-0/session-1"] != 1 || got["agent-1/session-1"] != 1 {
+		t.Errorf("sessions_by_agent deletes %v, want one per agent", got)
+	}
+
+	// A failed batch stops the deletion
+	batches = nil
+	failing := func(b *gocql.Batch) error {
+		batches = append(batches, b)
+		return errors.New("timeout")
+	}
+	if err := deleteEventChunks("session-1", keys, newBatch, failing); err == nil || len(batches) != 1 {
+		t.Errorf("got %v after %d batches", err, len(batches))
+	}
+}
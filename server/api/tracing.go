@@ -0,0 +1,13 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel"
+)
+
+// tracingServiceName is the service.name spans are reported under, unless
+// OTEL_SERVICE_NAME overrides it
+const tracingServiceName = "facto-query-api"
+
+// tracer creates the API's own spans. Until tracing.Init installs a provider
+// it is a no-op.
+var tracer = otel.Tracer("github.com/facto-ai/facto/server/api")
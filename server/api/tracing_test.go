@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testProviderOnce sync.Once
+	testProvider     *sdktrace.TracerProvider
+)
+
+// recordSpans records the spans ended during the test. The provider is
+// installed once, as tracing.Init would, since the package tracer binds to
+// the first global provider.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	testProviderOnce.Do(func() {
+		testProvider = sdktrace.NewTracerProvider()
+		otel.SetTracerProvider(testProvider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	recorder := tracetest.NewSpanRecorder()
+	testProvider.RegisterSpanProcessor(recorder)
+	t.Cleanup(func() { testProvider.UnregisterSpanProcessor(recorder) })
+	return recorder
+}
+
+func TestSpanPerRequest(t *testing.T) {
+	recorder := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(otelgin.Middleware(tracingServiceName))
+	h := &Handlers{config: &Config{AllowedStatuses: []string{"success"}}}
+	router.GET("/v1/statuses", h.GetStatuses)
+	// Stands in for a storage call: the statement is recorded under the
+	// request's span
+	router.GET("/v1/query", func(c *gin.Context) {
+		now := time.Now()
+		tracing.CQLObserver{}.ObserveQuery(c.Request.Context(), gocql.ObservedQuery{Start: now, End: now, Err: errors.New("timeout")})
+		c.Status(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	for _, target := range []string{"/v1/statuses", "/v1/statuses", "/v1/query"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if target == "/v1/query" {
+			req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", target, rec.Code)
+		}
+	}
+
+	var requests []sdktrace.ReadOnlySpan
+	var cql sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.SpanKind() {
+		case trace.SpanKindServer:
+			requests = append(requests, span)
+		case trace.SpanKindClient:
+			cql = span
+		}
+	}
+	if len(requests) != 3 {
+		t.Fatalf("got %d request spans, want 3", len(requests))
+	}
+	if requests[0].Name() != "/v1/statuses" {
+		t.Errorf("request span named %q", requests[0].Name())
+	}
+	if requests[0].SpanContext().TraceID() == requests[1].SpanContext().TraceID() {
+		t.Errorf("separate requests share a trace")
+	}
+
+	// Trace context propagates from the incoming headers
+	query := requests[2]
+	if got := query.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("request span in trace %s, want the caller's %s", got, traceID)
+	}
+	if cql == nil {
+		t.Fatal("no span for the CQL statement")
+	}
+	if cql.Parent().SpanID() != query.SpanContext().SpanID() {
+		t.Errorf("CQL span isn't a child of the request span")
+	}
+	if cql.Status().Code.String() != "Error" {
+		t.Errorf("CQL span status %v, want the statement's error", cql.Status())
+	}
+}
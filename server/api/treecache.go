@@ -0,0 +1,102 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	treeCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_api_merkle_tree_cache_hits_total",
+		Help: "Total number of session Merkle tree cache hits",
+	})
+
+	treeCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_api_merkle_tree_cache_misses_total",
+		Help: "Total number of session Merkle tree cache misses",
+	})
+
+	treeCacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_api_merkle_tree_cache_evictions_total",
+		Help: "Total number of session Merkle trees evicted from the cache",
+	})
+)
+
+// treeCacheKey identifies a session's tree for one exact event set. The set
+// hash changes whenever an event is added, so stale trees are never served.
+type treeCacheKey struct {
+	sessionID string
+	setHash   string
+}
+
+type treeCacheEntry struct {
+	key  treeCacheKey
+	tree *merkle.Tree
+}
+
+// merkleTreeCache is an LRU of built session Merkle trees, so repeated proof
+// requests for an unchanged session don't rebuild the tree each time
+type merkleTreeCache struct {
+	mu       sync.Mutex
+	capacity int
+	scheme   merkle.Scheme
+	order    *list.List // front = most recently used
+	entries  map[treeCacheKey]*list.Element
+}
+
+// newMerkleTreeCache creates a cache holding up to capacity trees built with
+// scheme. A capacity of 0 disables caching.
+func newMerkleTreeCache(capacity int, scheme merkle.Scheme) *merkleTreeCache {
+	return &merkleTreeCache{
+		capacity: capacity,
+		scheme:   scheme,
+		order:    list.New(),
+		entries:  make(map[treeCacheKey]*list.Element),
+	}
+}
+
+// get returns the session's tree over hashes, building and caching it on a
+// miss. A build cancelled through ctx is not cached.
+func (tc *merkleTreeCache) get(ctx context.Context, sessionID string, hashes []string) (*merkle.Tree, error) {
+	if tc.capacity <= 0 {
+		return merkle.Build(ctx, hashes, tc.scheme)
+	}
+
+	key := treeCacheKey{sessionID: sessionID, setHash: hashSetDigest(hashes)}
+
+	tc.mu.Lock()
+	if elem, ok := tc.entries[key]; ok {
+		tc.order.MoveToFront(elem)
+		tc.mu.Unlock()
+		treeCacheHits.Inc()
+		return elem.Value.(*treeCacheEntry).tree, nil
+	}
+	tc.mu.Unlock()
+
+	treeCacheMisses.Inc()
+	tree, err := merkle.Build(ctx, hashes, tc.scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if elem, ok := tc.entries[key]; ok {
+		// Built concurrently by another request
+		tc.order.MoveToFront(elem)
+		return elem.Value.(*treeCacheEntry).tree, nil
+	}
+	tc.entries[key] = tc.order.PushFront(&treeCacheEntry{key: key, tree: tree})
+	for tc.order.Len() > tc.capacity {
+		oldest := tc.order.Back()
+		tc.order.Remove(oldest)
+		delete(tc.entries, oldest.Value.(*treeCacheEntry).key)
+		treeCacheEvictions.Inc()
+	}
+	return tree, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMerkleTreeCacheHit(t *testing.T) {
+	ctx := context.Background()
+	tc := newMerkleTreeCache(1, merkle.SchemeRFC6962)
+	hits, misses := testutil.ToFloat64(treeCacheHits), testutil.ToFloat64(treeCacheMisses)
+	evictions := testutil.ToFloat64(treeCacheEvictions)
+
+	first, err := tc.get(ctx, "session-1", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := tc.get(ctx, "session-1", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Error("second proof request rebuilt the tree")
+	}
+	if got := testutil.ToFloat64(treeCacheHits) - hits; got != 1 {
+		t.Errorf("%v hits, want 1", got)
+	}
+	if got := testutil.ToFloat64(treeCacheMisses) - misses; got != 1 {
+		t.Errorf("%v misses, want 1", got)
+	}
+
+	// A new event changes the set, so the tree is rebuilt and the stale one
+	// evicted
+	third, err := tc.get(ctx, "session-1", []string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first || third.Root() == first.Root() {
+		t.Error("stale tree served after the event set changed")
+	}
+	if got := testutil.ToFloat64(treeCacheEvictions) - evictions; got != 1 {
+		t.Errorf("%v evictions, want 1", got)
+	}
+}
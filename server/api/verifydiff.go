@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+)
+
+// VerifyDiff explains a hash mismatch in POST /v1/verify?diff=true. It is
+// built only from the event in the request, so it never reveals stored data.
+type VerifyDiff struct {
+	CanonicalForm     string             `json:"canonical_form"`
+	CanonicalVersion  canonical.Version  `json:"canonical_version"`
+	CanonicalEncoding canonical.Encoding `json:"canonical_encoding"`
+	ComputedHash      string             `json:"computed_hash"`
+	ClaimedHash       string             `json:"claimed_hash"`
+
+	// ChangedFields lists the canonical fields that differ from the request's
+	// signed_form, as dotted paths (e.g. "execution_meta.seed"). Only set
+	// when signed_form is supplied.
+	ChangedFields []string `json:"changed_fields,omitempty"`
+}
+
+// VerifyDiffResponse extends VerifyResponse with a diff when the hash doesn't
+// match
+type VerifyDiffResponse struct {
+	VerifyResponse
+	Diff *VerifyDiff `json:"diff,omitempty"`
+}
+
+// buildVerifyDiff recomputes the event's canonical form and, when its hash
+// differs from the claimed one, returns what was hashed. signedForm, if set,
+// is the canonical form the caller signed, and is compared field by field.
+// An event with an unknown canonical_version has no form to diff, and errors
+// with canonical.ErrUnknownVersion.
+func buildVerifyDiff(event *EventResponse, signedForm string) (*VerifyDiff, error) {
+	form, version, err := canonicalForm(event)
+	if err != nil {
+		return nil, err
+	}
+	computed := computeHash(form)
+	if computed == event.Proof.EventHash {
+		return nil, nil
+	}
+
+	diff := &VerifyDiff{
+		CanonicalForm:     form,
+		CanonicalVersion:  version,
+		CanonicalEncoding: version.Encoding(),
+		ComputedHash:      computed,
+		ClaimedHash:       event.Proof.EventHash,
+	}
+	if signedForm != "" {
+		changed, err := changedCanonicalFields(form, signedForm)
+		if err != nil {
+			return nil, err
+		}
+		diff.ChangedFields = changed
+	}
+	return diff, nil
+}
+
+// changedCanonicalFields compares two canonical forms, descending into
+// objects and comparing arrays and scalars whole. Fields present on only one
+// side count as changed.
+func changedCanonicalFields(computed, signed string) ([]string, error) {
+	a, err := decodeCanonicalObject(computed)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decodeCanonicalObject(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	diffObjects("", a, b, &changed)
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// decodeCanonicalObject parses a canonical form, keeping numbers as written
+func decodeCanonicalObject(form string) (map[string]interface{}, error) {
+	var obj map[string]interface{}
+	if err := canonical.Unmarshal([]byte(form), &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func diffObjects(prefix string, a, b map[string]interface{}, changed *[]string) {
+	for key, av := range a {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		bv, ok := b[key]
+		if !ok {
+			*changed = append(*changed, path)
+			continue
+		}
+		ao, aIsObj := av.(map[string]interface{})
+		bo, bIsObj := bv.(map[string]interface{})
+		if aIsObj && bIsObj {
+			diffObjects(path, ao, bo, changed)
+		} else if !reflect.DeepEqual(av, bv) {
+			*changed = append(*changed, path)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			*changed = append(*changed, path)
+		}
+	}
+}
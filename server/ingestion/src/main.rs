@@ -60,18 +60,34 @@ pub struct ExecutionMeta {
     pub temperature: Option<f64>,
     pub seed: Option<i64>,
     pub max_tokens: Option<i32>,
+    // Non-LLM events may omit tool_calls or send null; both mean []
+    #[serde(default, deserialize_with = "null_as_empty_vec")]
     pub tool_calls: Vec<serde_json::Value>,
     pub sdk_version: String,
     pub sdk_language: String,
     pub tags: BTreeMap<String, String>,
 }
 
+fn null_as_empty_vec<'de, D>(deserializer: D) -> Result<Vec<serde_json::Value>, D::Error>
+where
+    D: serde::Deserializer<'de>,
+{
+    Ok(Option::<Vec<serde_json::Value>>::deserialize(deserializer)?.unwrap_or_default())
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct Proof {
     pub signature: String,
     pub public_key: String,
+    /// Signature algorithm (see verify_signature); absent means Ed25519
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub sig_algo: Option<String>,
     pub prev_hash: String,
     pub event_hash: String,
+    /// Canonical form the hash and signature cover (see build_canonical_form);
+    /// absent means CANONICAL_V1
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub canonical_version: Option<u32>,
 }
 
 #[derive(Debug, Deserialize)]
@@ -101,6 +117,23 @@ pub struct SingleIngestResponse {
     pub reason: Option<String>,
 }
 
+#[derive(Debug, Deserialize)]
+pub struct FactoIdsRequest {
+    #[serde(default = "default_facto_id_count")]
+    pub count: usize,
+}
+
+fn default_facto_id_count() -> usize {
+    1
+}
+
+#[derive(Debug, Serialize)]
+pub struct FactoIdsResponse {
+    pub facto_ids: Vec<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+}
+
 #[derive(Debug, Serialize)]
 pub struct HealthResponse {
     pub status: String,
@@ -128,10 +161,11 @@ pub struct AppState {
     nats_client: RwLock<Option<async_nats::Client>>,
     rate_limiter: AgentRateLimiter,
     rate_limit_per_agent: NonZeroU32,
+    id_rules: IdRules,
 }
 
 impl AppState {
-    fn new(rate_limit_per_agent: u32) -> Self {
+    fn new(rate_limit_per_agent: u32, id_rules: IdRules) -> Self {
         let rate_limit = NonZeroU32::new(rate_limit_per_agent).unwrap_or(nonzero!(10000u32));
         let quota = Quota::per_second(rate_limit);
         let rate_limiter = RateLimiter::dashmap(quota);
@@ -140,6 +174,7 @@ impl AppState {
             nats_client: RwLock::new(None),
             rate_limiter,
             rate_limit_per_agent: rate_limit,
+            id_rules,
         }
     }
 
@@ -159,9 +194,30 @@ impl AppState {
 // Cryptographic Verification
 // ============================================================================
 
-/// Build the canonical form of an event for hashing/signing
-/// The canonical form has sorted keys and no extra whitespace
+fn or_empty_object(value: &serde_json::Value) -> serde_json::Value {
+    if value.is_null() {
+        serde_json::json!({})
+    } else {
+        value.clone()
+    }
+}
+
+/// Canonical form versions, as in the Go services' canonical package. V1 is
+/// the original serialization, V2 the same fields in RFC 8785 JCS.
+const CANONICAL_V1: u32 = 1;
+const CANONICAL_V2: u32 = 2;
+/// JCS over every execution_meta field, with canonical_version itself signed
+const CANONICAL_V3: u32 = 3;
+
+/// Build the canonical form of an event for hashing/signing, for the version
+/// in proof.canonical_version. Unknown versions are an error, so an event is
+/// never verified against a guessed form.
 fn build_canonical_form(event: &FactoEvent) -> Result<String, String> {
+    let version = event.proof.canonical_version.unwrap_or(CANONICAL_V1);
+    if !matches!(version, CANONICAL_V1 | CANONICAL_V2 | CANONICAL_V3) {
+        return Err(format!("Unknown canonical_version: {}", version));
+    }
+
     // Build a sorted map with the fields that should be included in the hash
     let mut canonical = serde_json::Map::new();
 
@@ -169,7 +225,6 @@ fn build_canonical_form(event: &FactoEvent) -> Result<String, String> {
     canonical.insert("agent_id".to_string(), serde_json::json!(event.agent_id));
     canonical.insert("completed_at".to_string(), serde_json::json!(event.completed_at));
 
-    // Build execution_meta in sorted order
     // Build execution_meta in sorted order
     let mut exec_meta = serde_json::Map::new();
     if let Some(ref model_id) = event.execution_meta.model_id {
@@ -181,10 +236,22 @@ fn build_canonical_form(event: &FactoEvent) -> Result<String, String> {
         exec_meta.insert("temperature".to_string(), serde_json::json!(temp));
     }
     exec_meta.insert("tool_calls".to_string(), serde_json::json!(event.execution_meta.tool_calls));
+    if version == CANONICAL_V3 {
+        if let Some(ref model_hash) = event.execution_meta.model_hash {
+            exec_meta.insert("model_hash".to_string(), serde_json::json!(model_hash));
+        }
+        if let Some(max_tokens) = event.execution_meta.max_tokens {
+            exec_meta.insert("max_tokens".to_string(), serde_json::json!(max_tokens));
+        }
+        exec_meta.insert("sdk_language".to_string(), serde_json::json!(event.execution_meta.sdk_language));
+        exec_meta.insert("tags".to_string(), serde_json::json!(event.execution_meta.tags));
+        canonical.insert("canonical_version".to_string(), serde_json::json!(version));
+    }
     canonical.insert("execution_meta".to_string(), serde_json::Value::Object(exec_meta));
 
-    canonical.insert("input_data".to_string(), event.input_data.clone());
-    canonical.insert("output_data".to_string(), event.output_data.clone());
+    // Absent input/output data is canonicalized as {} rather than null
+    canonical.insert("input_data".to_string(), or_empty_object(&event.input_data));
+    canonical.insert("output_data".to_string(), or_empty_object(&event.output_data));
     canonical.insert("parent_facto_id".to_string(), serde_json::json!(event.parent_facto_id));
     canonical.insert("prev_hash".to_string(), serde_json::json!(event.proof.prev_hash));
     canonical.insert("session_id".to_string(), serde_json::json!(event.session_id));
@@ -192,10 +259,87 @@ fn build_canonical_form(event: &FactoEvent) -> Result<String, String> {
     canonical.insert("status".to_string(), serde_json::json!(event.status));
     canonical.insert("facto_id".to_string(), serde_json::json!(event.facto_id));
 
-    // Serialize to JSON with sorted keys (serde_json::Map maintains insertion order,
-    // and we inserted in sorted order)
-    serde_json::to_string(&serde_json::Value::Object(canonical))
-        .map_err(|e| format!("Failed to serialize canonical form: {}", e))
+    let canonical = serde_json::Value::Object(canonical);
+    if version == CANONICAL_V1 {
+        // serde_json::Map is a BTreeMap, so keys serialize sorted
+        return serde_json::to_string(&canonical)
+            .map_err(|e| format!("Failed to serialize canonical form: {}", e));
+    }
+    let mut out = String::new();
+    write_jcs(&canonical, &mut out)?;
+    Ok(out)
+}
+
+/// Serialize a value as RFC 8785 JCS: object keys sorted by UTF-16 code
+/// units, ECMAScript number formatting and minimal string escaping.
+/// Integers are written exactly, as the Go services do; integers outside the
+/// i64/u64 range arrive here as floats and lose that exactness.
+fn write_jcs(value: &serde_json::Value, out: &mut String) -> Result<(), String> {
+    match value {
+        serde_json::Value::Null => out.push_str("null"),
+        serde_json::Value::Bool(b) => out.push_str(if *b { "true" } else { "false" }),
+        serde_json::Value::Number(n) => out.push_str(&jcs_number(n)?),
+        serde_json::Value::String(s) => {
+            // serde_json escapes only '"', '\\' and control characters, as JCS does
+            out.push_str(&serde_json::to_string(s).map_err(|e| e.to_string())?)
+        }
+        serde_json::Value::Array(items) => {
+            out.push('[');
+            for (i, item) in items.iter().enumerate() {
+                if i > 0 {
+                    out.push(',');
+                }
+                write_jcs(item, out)?;
+            }
+            out.push(']');
+        }
+        serde_json::Value::Object(map) => {
+            let mut entries: Vec<_> = map.iter().collect();
+            entries.sort_by(|a, b| a.0.encode_utf16().cmp(b.0.encode_utf16()));
+            out.push('{');
+            for (i, (key, item)) in entries.into_iter().enumerate() {
+                if i > 0 {
+                    out.push(',');
+                }
+                out.push_str(&serde_json::to_string(key).map_err(|e| e.to_string())?);
+                out.push(':');
+                write_jcs(item, out)?;
+            }
+            out.push('}');
+        }
+    }
+    Ok(())
+}
+
+/// Format a number as ECMAScript's Number.prototype.toString does (RFC 8785
+/// section 3.2.2.3), keeping integers exact
+fn jcs_number(n: &serde_json::Number) -> Result<String, String> {
+    if let Some(i) = n.as_i64() {
+        return Ok(i.to_string());
+    }
+    if let Some(u) = n.as_u64() {
+        return Ok(u.to_string());
+    }
+    let f = n
+        .as_f64()
+        .ok_or_else(|| format!("Unrepresentable number: {}", n))?;
+    if !f.is_finite() {
+        return Err(format!("Unrepresentable number: {}", n));
+    }
+    if f == 0.0 {
+        return Ok("0".to_string());
+    }
+    let abs = f.abs();
+    if (1e-6..1e21).contains(&abs) {
+        // Display prints the shortest round-trip digits without an exponent
+        return Ok(format!("{}", f));
+    }
+    // LowerExp gives "1e21" and "1.5e-7"; ECMAScript signs positive exponents
+    let formatted = format!("{:e}", f);
+    Ok(match formatted.split_once('e') {
+        Some((mantissa, exp)) if !exp.starts_with('-') => format!("{}e+{}", mantissa, exp),
+        _ => formatted,
+    })
 }
 
 /// Compute SHA3-256 hash of the canonical form
@@ -221,67 +365,244 @@ fn verify_hash(event: &FactoEvent) -> Result<(), String> {
     Ok(())
 }
 
-/// Verify the Ed25519 signature
+/// Signature algorithms accepted in proof.sig_algo
+const SIG_ALGO_ED25519: &str = "ed25519";
+const SIG_ALGO_ECDSA_P256: &str = "ecdsa-p256";
+const SIG_ALGO_SECP256K1: &str = "secp256k1";
+const SIG_ALGO_RSA_PSS: &str = "rsa-pss";
+
+/// Smallest RSA modulus accepted for rsa-pss
+const MIN_RSA_KEY_BITS: usize = 2048;
+
+/// Verify the event signature with the algorithm named in proof.sig_algo.
+///
+/// Ed25519 keys and signatures are raw (32 and 64 bytes). ECDSA P-256 and
+/// RSA-PSS public keys are DER SubjectPublicKeyInfo; both sign the SHA-256
+/// digest of the canonical form, ECDSA signatures are ASN.1 DER and RSA-PSS
+/// uses MGF1-SHA256 with a 32-byte salt. secp256k1 public keys are SEC 1
+/// points (33 bytes compressed or 65 uncompressed) and also sign the SHA-256
+/// digest, with either a DER or a 64-byte r||s signature. Unknown algorithms
+/// are rejected.
 fn verify_signature(event: &FactoEvent) -> Result<(), String> {
-    // Decode the public key from base64
+    // Decode the public key and signature from base64
     let public_key_bytes = BASE64
         .decode(&event.proof.public_key)
         .map_err(|e| format!("Invalid public key encoding: {}", e))?;
 
-    if public_key_bytes.len() != 32 {
-        return Err(format!(
-            "Invalid public key length: expected 32, got {}",
-            public_key_bytes.len()
-        ));
+    let signature_bytes = BASE64
+        .decode(&event.proof.signature)
+        .map_err(|e| format!("Invalid signature encoding: {}", e))?;
+
+    // Build the canonical form and verify the signature
+    let canonical = build_canonical_form(event)?;
+    let message = canonical.as_bytes();
+
+    match event.proof.sig_algo.as_deref() {
+        None | Some(SIG_ALGO_ED25519) => {
+            verify_ed25519(&public_key_bytes, &signature_bytes, message)
+        }
+        Some(SIG_ALGO_ECDSA_P256) => {
+            verify_ecdsa_p256(&public_key_bytes, &signature_bytes, message)
+        }
+        Some(SIG_ALGO_SECP256K1) => {
+            verify_secp256k1(&public_key_bytes, &signature_bytes, message)
+        }
+        Some(SIG_ALGO_RSA_PSS) => verify_rsa_pss(&public_key_bytes, &signature_bytes, message),
+        Some(other) => Err(format!("Unsupported sig_algo: {}", other)),
     }
+}
 
-    let public_key_array: [u8; 32] = public_key_bytes
-        .try_into()
-        .map_err(|_| "Failed to convert public key to array")?;
+fn verify_ed25519(
+    public_key_bytes: &[u8],
+    signature_bytes: &[u8],
+    message: &[u8],
+) -> Result<(), String> {
+    let public_key_array: [u8; 32] = public_key_bytes.try_into().map_err(|_| {
+        format!(
+            "Invalid public key length: expected 32, got {}",
+            public_key_bytes.len()
+        )
+    })?;
 
     let verifying_key = VerifyingKey::from_bytes(&public_key_array)
         .map_err(|e| format!("Invalid public key: {}", e))?;
 
-    // Decode the signature from base64
-    let signature_bytes = BASE64
-        .decode(&event.proof.signature)
-        .map_err(|e| format!("Invalid signature encoding: {}", e))?;
-
-    if signature_bytes.len() != 64 {
-        return Err(format!(
+    let signature_array: [u8; 64] = signature_bytes.try_into().map_err(|_| {
+        format!(
             "Invalid signature length: expected 64, got {}",
             signature_bytes.len()
-        ));
+        )
+    })?;
+
+    let signature = Signature::from_bytes(&signature_array);
+
+    verifying_key
+        .verify_strict(message, &signature)
+        .map_err(|e| format!("Signature verification failed: {}", e))
+}
+
+fn verify_ecdsa_p256(
+    public_key_der: &[u8],
+    signature_der: &[u8],
+    message: &[u8],
+) -> Result<(), String> {
+    use p256::ecdsa::signature::Verifier;
+    use p256::pkcs8::DecodePublicKey;
+
+    let verifying_key = p256::ecdsa::VerifyingKey::from_public_key_der(public_key_der)
+        .map_err(|e| format!("Invalid ECDSA P-256 public key: {}", e))?;
+
+    let signature = p256::ecdsa::Signature::from_der(signature_der)
+        .map_err(|e| format!("Invalid ECDSA signature: {}", e))?;
+
+    verifying_key
+        .verify(message, &signature)
+        .map_err(|e| format!("Signature verification failed: {}", e))
+}
+
+fn verify_secp256k1(
+    public_key_sec1: &[u8],
+    signature_bytes: &[u8],
+    message: &[u8],
+) -> Result<(), String> {
+    use k256::ecdsa::signature::Verifier;
+
+    let verifying_key = k256::ecdsa::VerifyingKey::from_sec1_bytes(public_key_sec1)
+        .map_err(|e| format!("Invalid secp256k1 public key: {}", e))?;
+
+    let signature = if signature_bytes.len() == 64 {
+        k256::ecdsa::Signature::from_slice(signature_bytes)
+    } else {
+        k256::ecdsa::Signature::from_der(signature_bytes)
     }
+    .map_err(|e| format!("Invalid secp256k1 signature: {}", e))?;
 
-    let signature_array: [u8; 64] = signature_bytes
-        .try_into()
-        .map_err(|_| "Failed to convert signature to array")?;
+    // Wallets may emit high-S signatures; the Go verifiers accept both forms
+    let signature = signature.normalize_s().unwrap_or(signature);
 
-    let signature = Signature::from_bytes(&signature_array);
+    verifying_key
+        .verify(message, &signature)
+        .map_err(|e| format!("Signature verification failed: {}", e))
+}
 
-    // Build the canonical form and verify the signature
-    let canonical = build_canonical_form(event)?;
+fn verify_rsa_pss(
+    public_key_der: &[u8],
+    signature_bytes: &[u8],
+    message: &[u8],
+) -> Result<(), String> {
+    use rsa::pkcs8::DecodePublicKey;
+    use rsa::signature::Verifier;
+    use rsa::traits::PublicKeyParts;
+
+    let public_key = rsa::RsaPublicKey::from_public_key_der(public_key_der)
+        .map_err(|e| format!("Invalid RSA public key: {}", e))?;
+
+    if public_key.size() * 8 < MIN_RSA_KEY_BITS {
+        return Err(format!(
+            "RSA key too small: minimum {} bits",
+            MIN_RSA_KEY_BITS
+        ));
+    }
+
+    let verifying_key = rsa::pss::VerifyingKey::<sha2::Sha256>::new(public_key);
+
+    let signature = rsa::pss::Signature::try_from(signature_bytes)
+        .map_err(|e| format!("Invalid RSA-PSS signature: {}", e))?;
 
     verifying_key
-        .verify_strict(canonical.as_bytes(), &signature)
-        .map_err(|e| format!("Signature verification failed: {}", e))?;
+        .verify(message, &signature)
+        .map_err(|e| format!("Signature verification failed: {}", e))
+}
 
-    Ok(())
+/// Prefix of every facto_id
+const FACTO_ID_PREFIX: &str = "ft-";
+
+/// Check that a facto_id has the canonical format: "ft-" followed by a
+/// lowercase, hyphenated UUID (v4 from the SDKs, v7 from generate_facto_id)
+fn is_valid_facto_id(facto_id: &str) -> bool {
+    let Some(uuid) = facto_id.strip_prefix(FACTO_ID_PREFIX) else {
+        return false;
+    };
+    match uuid::Uuid::try_parse(uuid) {
+        Ok(parsed) => uuid == parsed.hyphenated().to_string(),
+        Err(_) => false,
+    }
+}
+
+/// Generate a new facto_id for server-side ingest/backfill. UUIDv7 keeps ids
+/// roughly time-ordered.
+fn generate_facto_id() -> String {
+    format!("{}{}", FACTO_ID_PREFIX, uuid::Uuid::now_v7().hyphenated())
+}
+
+/// Most facto_ids issued by one /v1/ingest/facto-ids request
+const MAX_FACTO_IDS_PER_REQUEST: usize = 1000;
+
+/// Generate count facto_ids. The facto_id is part of the signed canonical
+/// form, so the server can't assign one to an event it receives; ingest and
+/// backfill clients that want server-issued ids request them before signing.
+fn generate_facto_ids(count: usize) -> Result<Vec<String>, String> {
+    if count == 0 || count > MAX_FACTO_IDS_PER_REQUEST {
+        return Err(format!(
+            "count must be between 1 and {}",
+            MAX_FACTO_IDS_PER_REQUEST
+        ));
+    }
+    Ok((0..count).map(|_| generate_facto_id()).collect())
+}
+
+/// Length and character limits for agent_id and session_id, which become
+/// partition keys, pagination cursors and NATS subject tokens
+#[derive(Debug, Clone)]
+pub struct IdRules {
+    max_length: usize,
+    /// Characters permitted in addition to ASCII letters and digits
+    allowed_chars: String,
+}
+
+impl IdRules {
+    fn new(max_length: usize, allowed_chars: &str) -> Self {
+        Self {
+            max_length,
+            // Control characters and whitespace are never allowed
+            allowed_chars: allowed_chars
+                .chars()
+                .filter(|c| !c.is_control() && !c.is_whitespace())
+                .collect(),
+        }
+    }
+
+    fn check(&self, field: &str, id: &str) -> Result<(), String> {
+        if id.len() > self.max_length {
+            return Err(format!("{} exceeds {} characters", field, self.max_length));
+        }
+        if let Some(c) = id
+            .chars()
+            .find(|c| !c.is_ascii_alphanumeric() && !self.allowed_chars.contains(*c))
+        {
+            return Err(format!("{} contains disallowed character {:?}", field, c));
+        }
+        Ok(())
+    }
 }
 
 /// Validate a single event
-fn validate_event(event: &FactoEvent) -> Result<(), String> {
+fn validate_event(event: &FactoEvent, id_rules: &IdRules) -> Result<(), String> {
     // Check required fields
     if event.facto_id.is_empty() {
         return Err("Missing facto_id".to_string());
     }
+    if !is_valid_facto_id(&event.facto_id) {
+        return Err(format!("Malformed facto_id: {}", event.facto_id));
+    }
     if event.agent_id.is_empty() {
         return Err("Missing agent_id".to_string());
     }
     if event.session_id.is_empty() {
         return Err("Missing session_id".to_string());
     }
+    id_rules.check("agent_id", &event.agent_id)?;
+    id_rules.check("session_id", &event.session_id)?;
     if event.action_type.is_empty() {
         return Err("Missing action_type".to_string());
     }
@@ -367,7 +688,7 @@ async fn ingest_single_handler(
     }
 
     // Validate event
-    if let Err(reason) = validate_event(&event) {
+    if let Err(reason) = validate_event(&event, &state.id_rules) {
         counter!("facto_ingest_rejected_total", "reason" => "validation").increment(1);
         return (
             StatusCode::BAD_REQUEST,
@@ -423,6 +744,27 @@ async fn ingest_single_handler(
     )
 }
 
+async fn facto_ids_handler(Json(request): Json<FactoIdsRequest>) -> impl IntoResponse {
+    counter!("facto_ingest_requests_total", "type" => "facto_ids").increment(1);
+
+    match generate_facto_ids(request.count) {
+        Ok(facto_ids) => (
+            StatusCode::OK,
+            Json(FactoIdsResponse {
+                facto_ids,
+                reason: None,
+            }),
+        ),
+        Err(reason) => (
+            StatusCode::BAD_REQUEST,
+            Json(FactoIdsResponse {
+                facto_ids: Vec::new(),
+                reason: Some(reason),
+            }),
+        ),
+    }
+}
+
 async fn ingest_batch_handler(
     State(state): State<Arc<AppState>>,
     Json(request): Json<BatchIngestRequest>,
@@ -448,7 +790,7 @@ async fn ingest_batch_handler(
         }
 
         // Validate event
-        match validate_event(&event) {
+        match validate_event(&event, &state.id_rules) {
             Ok(()) => {
                 accepted_events.push(event);
             }
@@ -609,6 +951,13 @@ async fn main() -> anyhow::Result<()> {
         .parse()
         .expect("Invalid RATE_LIMIT_PER_AGENT");
 
+    let id_max_length: usize = std::env::var("ID_MAX_LENGTH")
+        .unwrap_or_else(|_| "128".to_string())
+        .parse()
+        .expect("Invalid ID_MAX_LENGTH");
+
+    let id_allowed_chars = std::env::var("ID_ALLOWED_CHARS").unwrap_or_else(|_| "-_.:".to_string());
+
     info!(
         "Starting Facto Ingestion Service v{}",
         env!("CARGO_PKG_VERSION")
@@ -616,9 +965,11 @@ async fn main() -> anyhow::Result<()> {
     info!("Port: {}", port);
     info!("NATS URL: {}", nats_url);
     info!("Rate limit per agent: {} req/sec", rate_limit_per_agent);
+    info!("ID max length: {}, extra allowed characters: {:?}", id_max_length, id_allowed_chars);
 
     // Initialize application state
-    let state = Arc::new(AppState::new(rate_limit_per_agent));
+    let id_rules = IdRules::new(id_max_length, &id_allowed_chars);
+    let state = Arc::new(AppState::new(rate_limit_per_agent, id_rules));
 
     // Spawn NATS connection task
     let nats_state = state.clone();
@@ -634,6 +985,7 @@ async fn main() -> anyhow::Result<()> {
         .route("/metrics", get(metrics_handler))
         .route("/v1/ingest", post(ingest_single_handler))
         .route("/v1/ingest/batch", post(ingest_batch_handler))
+        .route("/v1/ingest/facto-ids", post(facto_ids_handler))
         .layer(CompressionLayer::new())
         .layer(
             CorsLayer::new()
@@ -683,8 +1035,10 @@ mod tests {
             proof: Proof {
                 signature: "".to_string(),
                 public_key: "".to_string(),
+                sig_algo: None,
                 prev_hash: "0".repeat(64),
                 event_hash: "".to_string(),
+                canonical_version: None,
             },
             started_at: 1000000000,
             completed_at: 1000000001,
@@ -695,6 +1049,269 @@ mod tests {
         assert!(canonical.contains("agent_id"));
     }
 
+    #[test]
+    fn test_canonical_form_without_model_meta() {
+        let event: FactoEvent = serde_json::from_value(serde_json::json!({
+            "facto_id": "ft-test-tool",
+            "agent_id": "agent-test",
+            "session_id": "session-test",
+            "parent_facto_id": null,
+            "action_type": "tool_call",
+            "status": "success",
+            "input_data": {"url": "https://example.com"},
+            "output_data": {"status": 200},
+            "execution_meta": {
+                "tool_calls": null,
+                "sdk_version": "0.1.0",
+                "sdk_language": "python",
+                "tags": {}
+            },
+            "proof": {
+                "signature": "",
+                "public_key": "",
+                "prev_hash": "0".repeat(64),
+                "event_hash": ""
+            },
+            "started_at": 1000000000,
+            "completed_at": 1000000001
+        }))
+        .unwrap();
+
+        let canonical = build_canonical_form(&event).unwrap();
+        assert!(canonical.contains(r#""execution_meta":{"sdk_version":"0.1.0","seed":null,"tool_calls":[]}"#));
+    }
+
+    #[test]
+    fn test_jcs_numbers() {
+        // RFC 8785 appendix B, plus exact int64 timestamps
+        let cases = [
+            (0x0000000000000000u64, "0"),
+            (0x8000000000000000, "0"),
+            (0x0000000000000001, "5e-324"),
+            (0x7fefffffffffffff, "1.7976931348623157e+308"),
+            (0x4340000000000000, "9007199254740992"),
+            (0x4430000000000000, "295147905179352830000"),
+            (0x44b52d02c7e14af6, "1e+23"),
+            (0x444b1ae4d6e2ef50, "1e+21"),
+            (0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"),
+            (0x3eb0c6f7a0b5ed8d, "0.000001"),
+            (0x41b3de4355555555, "333333333.3333333"),
+            (0xbecbf647612f3696, "-0.0000033333333333333333"),
+        ];
+        for (bits, expected) in cases {
+            let n = serde_json::Number::from_f64(f64::from_bits(bits)).unwrap();
+            assert_eq!(jcs_number(&n).unwrap(), expected, "{:#018x}", bits);
+        }
+        let timestamp = serde_json::Number::from(1718000000123456789i64);
+        assert_eq!(jcs_number(&timestamp).unwrap(), "1718000000123456789");
+    }
+
+    #[test]
+    fn test_jcs_sorts_by_utf16() {
+        let value = serde_json::json!({"\u{fb33}": 1, "\u{1f600}": 2, "a": "<&>"});
+        let mut out = String::new();
+        write_jcs(&value, &mut out).unwrap();
+        assert_eq!(out, "{\"a\":\"<&>\",\"\u{1f600}\":2,\"\u{fb33}\":1}");
+    }
+
+    #[test]
+    fn test_canonical_version_selects_form() {
+        let mut event = signing_test_event(None);
+        event.input_data = serde_json::json!({"ratio": 1e21});
+        let v1 = build_canonical_form(&event).unwrap();
+
+        event.proof.canonical_version = Some(CANONICAL_V2);
+        let v2 = build_canonical_form(&event).unwrap();
+        assert!(v2.contains(r#""input_data":{"ratio":1e+21}"#), "{}", v2);
+        assert_ne!(v1, v2);
+
+        event.proof.canonical_version = Some(99);
+        assert_eq!(
+            build_canonical_form(&event),
+            Err("Unknown canonical_version: 99".to_string())
+        );
+    }
+
+    #[test]
+    fn test_canonical_v3_matches_golden() {
+        let event: FactoEvent = serde_json::from_str(include_str!(
+            "../../../tests/golden/canonical_event_v3.json"
+        ))
+        .unwrap();
+        let expected = include_str!("../../../tests/golden/canonical_event_v3.canonical");
+        let canonical = build_canonical_form(&event).unwrap();
+        assert_eq!(canonical, expected.trim_end());
+        assert_eq!(compute_event_hash(&canonical), event.proof.event_hash);
+
+        // The execution_meta fields V1 and V2 leave unsigned are covered
+        let mut altered = event.clone();
+        altered.execution_meta.sdk_language = "rust".to_string();
+        assert_ne!(build_canonical_form(&altered).unwrap(), canonical);
+    }
+
+    fn signing_test_event(sig_algo: Option<&str>) -> FactoEvent {
+        serde_json::from_value(serde_json::json!({
+            "facto_id": "ft-0b7e4c1a-5f3d-4e2b-9a6c-1d2e3f4a5b6c",
+            "agent_id": "agent-test",
+            "session_id": "session-test",
+            "action_type": "llm_call",
+            "status": "success",
+            "input_data": {"prompt": "test"},
+            "output_data": {"response": "test"},
+            "execution_meta": {
+                "model_id": "gpt-4",
+                "sdk_version": "0.1.0",
+                "sdk_language": "python",
+                "tags": {}
+            },
+            "proof": {
+                "signature": "",
+                "public_key": "",
+                "sig_algo": sig_algo,
+                "prev_hash": "0".repeat(64),
+                "event_hash": ""
+            },
+            "started_at": 1000000000,
+            "completed_at": 1000000001
+        }))
+        .unwrap()
+    }
+
+    #[test]
+    fn test_ed25519_signature_verifies() {
+        use ed25519_dalek::{Signer, SigningKey};
+
+        let signing_key = SigningKey::from_bytes(&[7u8; 32]);
+        let mut event = signing_test_event(None);
+        let canonical = build_canonical_form(&event).unwrap();
+        event.proof.public_key = BASE64.encode(signing_key.verifying_key().as_bytes());
+        event.proof.signature = BASE64.encode(signing_key.sign(canonical.as_bytes()).to_bytes());
+
+        assert_eq!(verify_signature(&event), Ok(()));
+
+        event.proof.sig_algo = Some(SIG_ALGO_ED25519.to_string());
+        assert_eq!(verify_signature(&event), Ok(()));
+    }
+
+    #[test]
+    fn test_ecdsa_p256_signature_verifies() {
+        use p256::ecdsa::signature::Signer;
+        use p256::pkcs8::EncodePublicKey;
+
+        let signing_key = p256::ecdsa::SigningKey::from_bytes(&[1u8; 32].into()).unwrap();
+        let mut event = signing_test_event(Some(SIG_ALGO_ECDSA_P256));
+        let canonical = build_canonical_form(&event).unwrap();
+        let signature: p256::ecdsa::Signature = signing_key.sign(canonical.as_bytes());
+        let public_key_der = signing_key.verifying_key().to_public_key_der().unwrap();
+        event.proof.public_key = BASE64.encode(public_key_der.as_bytes());
+        event.proof.signature = BASE64.encode(signature.to_der().as_bytes());
+
+        assert_eq!(verify_signature(&event), Ok(()));
+
+        // The same signature doesn't verify as another algorithm
+        event.proof.sig_algo = Some(SIG_ALGO_ED25519.to_string());
+        assert!(verify_signature(&event).is_err());
+    }
+
+    #[test]
+    fn test_secp256k1_signature_verifies() {
+        use k256::ecdsa::signature::Signer;
+
+        let signing_key = k256::ecdsa::SigningKey::from_bytes(&[3u8; 32].into()).unwrap();
+        let mut event = signing_test_event(Some(SIG_ALGO_SECP256K1));
+        let canonical = build_canonical_form(&event).unwrap();
+        let signature: k256::ecdsa::Signature = signing_key.sign(canonical.as_bytes());
+        let compressed = signing_key.verifying_key().to_encoded_point(true);
+        event.proof.public_key = BASE64.encode(compressed.as_bytes());
+
+        // Both the DER and the raw r||s encodings verify
+        event.proof.signature = BASE64.encode(signature.to_der().as_bytes());
+        assert_eq!(verify_signature(&event), Ok(()));
+        event.proof.signature = BASE64.encode(signature.to_bytes());
+        assert_eq!(verify_signature(&event), Ok(()));
+
+        // So does an uncompressed public key
+        let uncompressed = signing_key.verifying_key().to_encoded_point(false);
+        event.proof.public_key = BASE64.encode(uncompressed.as_bytes());
+        assert_eq!(verify_signature(&event), Ok(()));
+
+        // A tampered canonical form doesn't
+        event.status = "error".to_string();
+        assert!(verify_signature(&event).is_err());
+    }
+
+    #[test]
+    fn test_unknown_sig_algo_rejected() {
+        let event = signing_test_event(Some("dsa"));
+        assert_eq!(
+            verify_signature(&event),
+            Err("Unsupported sig_algo: dsa".to_string())
+        );
+    }
+
+    #[test]
+    fn test_valid_facto_ids() {
+        assert!(is_valid_facto_id("ft-0b7e4c1a-5f3d-4e2b-9a6c-1d2e3f4a5b6c"));
+        assert!(is_valid_facto_id(&generate_facto_id()));
+    }
+
+    #[test]
+    fn test_generate_facto_ids() {
+        let ids = generate_facto_ids(3).unwrap();
+        assert_eq!(ids.len(), 3);
+        assert!(ids.iter().all(|id| is_valid_facto_id(id)));
+        let unique: std::collections::HashSet<_> = ids.iter().collect();
+        assert_eq!(unique.len(), ids.len());
+
+        assert!(generate_facto_ids(0).is_err());
+        assert!(generate_facto_ids(MAX_FACTO_IDS_PER_REQUEST + 1).is_err());
+    }
+
+    #[test]
+    fn test_invalid_facto_ids() {
+        for id in [
+            "",
+            "ft-",
+            "ft-test-123",
+            "tr-0b7e4c1a-5f3d-4e2b-9a6c-1d2e3f4a5b6c",
+            "ft-0B7E4C1A-5F3D-4E2B-9A6C-1D2E3F4A5B6C",
+            "ft-0b7e4c1a5f3d4e2b9a6c1d2e3f4a5b6c",
+            "ft-{0b7e4c1a-5f3d-4e2b-9a6c-1d2e3f4a5b6c}",
+            "ft-0b7e4c1a-5f3d-4e2b-9a6c-1d2e3f4a5b6c' OR '1'='1",
+        ] {
+            assert!(!is_valid_facto_id(id), "{id} should be rejected");
+        }
+    }
+
+    #[test]
+    fn test_valid_ids() {
+        let rules = IdRules::new(128, "-_.:");
+        let longest = "a".repeat(128);
+        for id in ["agent-1", "session_2024.01:a", "A", longest.as_str()] {
+            assert!(rules.check("agent_id", id).is_ok(), "{id} should be accepted");
+        }
+    }
+
+    #[test]
+    fn test_overly_long_ids() {
+        let rules = IdRules::new(128, "-_.:");
+        let err = rules.check("session_id", &"a".repeat(129)).unwrap_err();
+        assert_eq!(err, "session_id exceeds 128 characters");
+    }
+
+    #[test]
+    fn test_control_character_ids() {
+        let rules = IdRules::new(128, "-_.:");
+        for id in ["agent\n1", "agent\u{0}", "agent\t1", "agent\u{7f}", "agent 1", "agent*", "agent>"] {
+            assert!(rules.check("agent_id", id).is_err(), "{id:?} should be rejected");
+        }
+
+        // Control characters can't be allowed through configuration
+        let rules = IdRules::new(128, "-\n\t ");
+        assert!(rules.check("agent_id", "agent\n1").is_err());
+        assert!(rules.check("agent_id", "agent-1").is_ok());
+    }
+
     #[test]
     fn test_compute_hash() {
         let data = r#"{"test":"data"}"#;
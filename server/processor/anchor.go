@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var rootAnchorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "facto_processor_root_anchors_total",
+	Help: "Total number of Merkle roots anchored externally by result",
+}, []string{"result"})
+
+// maxAnchorReceiptSize bounds the receipt read from a transparency log
+const maxAnchorReceiptSize = 64 << 10
+
+// AnchorRequest is what a Merkle root is anchored with
+type AnchorRequest struct {
+	RootHash   string    `json:"root_hash"`
+	BucketTime time.Time `json:"bucket_time"`
+	EventCount int       `json:"event_count"`
+}
+
+// Anchor commits a Merkle root to an external append-only system, such as a
+// transparency log or blockchain, and returns its receipt (a transaction id,
+// inclusion proof, ...) as JSON. A nil receipt means nothing was anchored.
+type Anchor interface {
+	Anchor(ctx context.Context, req AnchorRequest) (json.RawMessage, error)
+}
+
+// noopAnchor anchors nothing. It is used when no anchor endpoint is set.
+type noopAnchor struct{}
+
+func (noopAnchor) Anchor(context.Context, AnchorRequest) (json.RawMessage, error) {
+	return nil, nil
+}
+
+// HTTPAnchor posts each root as JSON to a transparency log endpoint and takes
+// the JSON response body as the receipt
+type HTTPAnchor struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPAnchor creates an anchor for the given endpoint
+func NewHTTPAnchor(url string) *HTTPAnchor {
+	return &HTTPAnchor{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *HTTPAnchor) Anchor(ctx context.Context, anchorReq AnchorRequest) (json.RawMessage, error) {
+	body, err := json.Marshal(anchorReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("anchor endpoint returned status %d", resp.StatusCode)
+	}
+
+	receipt, err := io.ReadAll(io.LimitReader(resp.Body, maxAnchorReceiptSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(receipt) > maxAnchorReceiptSize {
+		return nil, fmt.Errorf("anchor receipt exceeds %d bytes", maxAnchorReceiptSize)
+	}
+	if !json.Valid(receipt) {
+		return nil, fmt.Errorf("anchor receipt is not JSON")
+	}
+	return receipt, nil
+}
+
+// RootAnchorer anchors stored Merkle roots in the background and saves each
+// receipt on its merkle_roots row. Like commit webhooks, anchoring is retried
+// with backoff and never blocks or fails the batch.
+type RootAnchorer struct {
+	anchor  Anchor
+	storage *Storage
+	retries int
+}
+
+// NewRootAnchorer creates an anchorer that retries each root up to retries
+// times
+func NewRootAnchorer(anchor Anchor, storage *Storage, retries int) *RootAnchorer {
+	return &RootAnchorer{anchor: anchor, storage: storage, retries: retries}
+}
+
+// Submit anchors the root stored at req.BucketTime in the background
+func (r *RootAnchorer) Submit(ctx context.Context, req AnchorRequest) {
+	if _, ok := r.anchor.(noopAnchor); ok {
+		return
+	}
+	go r.deliver(ctx, req)
+}
+
+func (r *RootAnchorer) deliver(ctx context.Context, req AnchorRequest) {
+	var receipt json.RawMessage
+	var err error
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if receipt, err = r.anchor.Anchor(ctx, req); err == nil {
+			break
+		}
+		if attempt >= r.retries || !sleepContext(ctx, backoff) {
+			log.Warn().Err(err).Str("root_hash", req.RootHash).Msg("Failed to anchor Merkle root")
+			rootAnchorsTotal.WithLabelValues("failed").Inc()
+			return
+		}
+		rootAnchorsTotal.WithLabelValues("retried").Inc()
+		backoff *= 2
+	}
+
+	if receipt == nil {
+		return
+	}
+	if err := r.storage.StoreAnchorReceipt(ctx, req.BucketTime, req.RootHash, string(receipt)); err != nil {
+		log.Error().Err(err).Str("root_hash", req.RootHash).Msg("Failed to store anchor receipt")
+		rootAnchorsTotal.WithLabelValues("failed").Inc()
+		return
+	}
+	rootAnchorsTotal.WithLabelValues("anchored").Inc()
+}
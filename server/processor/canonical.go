@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/hex"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"golang.org/x/crypto/sha3"
+)
+
+// canonicalForm returns the form an event was hashed and signed over, built
+// for the version in its proof.canonical_version, and that version. It
+// errors for a version this build doesn't know.
+func canonicalForm(event *FactoEvent) (string, canonical.Version, error) {
+	meta := event.ExecutionMeta
+	e := canonical.Event{
+		FactoID:       event.FactoID,
+		AgentID:       event.AgentID,
+		SessionID:     event.SessionID,
+		ParentFactoID: event.ParentFactoID,
+		ActionType:    event.ActionType,
+		Status:        event.Status,
+		InputData:     event.InputData,
+		OutputData:    event.OutputData,
+		ModelID:       meta.ModelID,
+		ModelHash:     meta.ModelHash,
+		Temperature:   meta.Temperature,
+		Seed:          meta.Seed,
+		MaxTokens:     meta.MaxTokens,
+		ToolCalls:     meta.ToolCalls,
+		SDKVersion:    meta.SDKVersion,
+		SDKLanguage:   meta.SDKLanguage,
+		Tags:          meta.Tags,
+		PrevHash:      event.Proof.PrevHash,
+		StartedAt:     event.StartedAt,
+		CompletedAt:   event.CompletedAt,
+		Version:       event.Proof.CanonicalVersion,
+	}
+	return e.Form()
+}
+
+func computeHash(canonical string) string {
+	hash := sha3.Sum256([]byte(canonical))
+	return hex.EncodeToString(hash[:])
+}
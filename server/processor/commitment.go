@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var intervalRootsCreated = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "facto_processor_interval_roots_created_total",
+	Help: "Total number of consolidated interval Merkle roots stored",
+})
+
+// intervalSettle is how far behind now an interval window ends. A batch
+// root's bucket_time is chosen just before the root is written, so a window
+// ending at now could miss a root that is still being stored.
+const intervalSettle = 10 * time.Second
+
+// intervalStore is the storage an IntervalCommitter reads batch roots from
+// and commits interval roots to
+type intervalStore interface {
+	GetLastIntervalEnd(ctx context.Context) (time.Time, error)
+	GetBatchRootsBetween(ctx context.Context, after, upTo time.Time) ([]MerkleRootRow, error)
+	ClaimInterval(ctx context.Context, prevEnd time.Time, root *IntervalRoot) (bool, error)
+	StoreIntervalRoot(ctx context.Context, root *IntervalRoot) error
+	BuildMerkleRoot(hashes []string) string
+}
+
+// IntervalCommitter periodically writes one consolidated Merkle root over
+// every event hash stored since the last commitment. This gives time-aligned
+// roots for anchoring, independent of how events happened to be batched.
+//
+// The hashes are read back from the batch roots in merkle_roots, so nothing
+// is lost when a processor restarts. Windows are contiguous and claimed
+// through merkle_root_chain with a lightweight transaction, so each batch
+// root lands in exactly one interval root even with several processors.
+type IntervalCommitter struct {
+	storage  intervalStore
+	interval time.Duration
+
+	// unstored is a claimed window whose root failed to store; it is retried
+	// before the next window. Only Run's goroutine touches it.
+	unstored *IntervalRoot
+}
+
+// NewIntervalCommitter creates a new interval committer
+func NewIntervalCommitter(storage intervalStore, interval time.Duration) *IntervalCommitter {
+	return &IntervalCommitter{
+		storage:  storage,
+		interval: interval,
+	}
+}
+
+// Run commits on every interval tick until the context is cancelled, then
+// commits whatever is still pending
+func (ic *IntervalCommitter) Run(ctx context.Context) {
+	ticker := time.NewTicker(ic.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Use a fresh context so the final commitment isn't cancelled too
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			ic.commitLogged(shutdownCtx, time.Now())
+			cancel()
+			return
+		case now := <-ticker.C:
+			ic.commitLogged(ctx, now)
+		}
+	}
+}
+
+func (ic *IntervalCommitter) commitLogged(ctx context.Context, now time.Time) {
+	if err := ic.commit(ctx, now); err != nil {
+		log.Error().Err(err).Msg("Failed to commit interval root, will retry")
+	}
+}
+
+// commit stores the root over the batch roots stored since the last
+// committed window, up to now less intervalSettle. An empty window isn't
+// committed; the next one starts where the last commitment ended.
+func (ic *IntervalCommitter) commit(ctx context.Context, now time.Time) error {
+	if ic.unstored != nil {
+		if err := ic.store(ctx, ic.unstored); err != nil {
+			return err
+		}
+		ic.unstored = nil
+	}
+
+	end := now.Add(-intervalSettle).Truncate(time.Millisecond)
+	for attempt := 0; attempt < maxRootLinkAttempts; attempt++ {
+		prevEnd, err := ic.storage.GetLastIntervalEnd(ctx)
+		if err != nil {
+			return err
+		}
+		start := prevEnd
+		if start.IsZero() {
+			// The first commitment covers one interval
+			start = end.Add(-ic.interval)
+		}
+		if !end.After(start) {
+			return nil
+		}
+
+		batches, err := ic.storage.GetBatchRootsBetween(ctx, start, end)
+		if err != nil {
+			return err
+		}
+
+		// A redelivered event may sit in two batch roots, so hashes are
+		// deduped, as for daily roots
+		var hashes []string
+		seen := make(map[string]bool)
+		for _, batch := range batches {
+			for _, hash := range batch.EventHashes {
+				if !seen[hash] {
+					seen[hash] = true
+					hashes = append(hashes, hash)
+				}
+			}
+		}
+		if len(hashes) == 0 {
+			return nil
+		}
+
+		root := &IntervalRoot{
+			WindowStart:  start,
+			BucketTime:   end,
+			RootHash:     ic.storage.BuildMerkleRoot(hashes),
+			FirstFactoID: batches[0].FirstFactoID,
+			LastFactoID:  batches[len(batches)-1].LastFactoID,
+			EventHashes:  hashes,
+		}
+		applied, err := ic.storage.ClaimInterval(ctx, prevEnd, root)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			// Another processor committed first; continue from its window
+			continue
+		}
+
+		if err := ic.store(ctx, root); err != nil {
+			ic.unstored = root
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("interval chain kept moving after %d attempts", maxRootLinkAttempts)
+}
+
+func (ic *IntervalCommitter) store(ctx context.Context, root *IntervalRoot) error {
+	if err := ic.storage.StoreIntervalRoot(ctx, root); err != nil {
+		return err
+	}
+
+	intervalRootsCreated.Inc()
+	log.Info().
+		Int("count", len(root.EventHashes)).
+		Str("merkle_root", root.RootHash).
+		Time("window_start", root.WindowStart).
+		Time("bucket_time", root.BucketTime).
+		Msg("Interval root committed")
+	return nil
+}
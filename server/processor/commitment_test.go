@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+)
+
+// memIntervalStore keeps batch roots, the interval chain row and interval
+// roots in memory, with the same window semantics as Storage
+type memIntervalStore struct {
+	batches  []MerkleRootRow
+	lastEnd  time.Time
+	roots    []*IntervalRoot
+	storeErr error
+
+	// beforeClaim runs once before the next claim, to let another processor
+	// move the chain first
+	beforeClaim func()
+}
+
+func (m *memIntervalStore) addBatch(bucketTime time.Time, hashes ...string) {
+	m.batches = append(m.batches, MerkleRootRow{
+		BucketTime:   bucketTime,
+		RootType:     RootTypeBatch,
+		FirstFactoID: "ft-" + hashes[0],
+		LastFactoID:  "ft-" + hashes[len(hashes)-1],
+		EventHashes:  hashes,
+	})
+}
+
+func (m *memIntervalStore) GetLastIntervalEnd(ctx context.Context) (time.Time, error) {
+	return m.lastEnd, nil
+}
+
+func (m *memIntervalStore) GetBatchRootsBetween(ctx context.Context, after, upTo time.Time) ([]MerkleRootRow, error) {
+	var rows []MerkleRootRow
+	for _, batch := range m.batches {
+		if batch.BucketTime.After(after) && !batch.BucketTime.After(upTo) {
+			rows = append(rows, batch)
+		}
+	}
+	return rows, nil
+}
+
+func (m *memIntervalStore) ClaimInterval(ctx context.Context, prevEnd time.Time, root *IntervalRoot) (bool, error) {
+	if m.beforeClaim != nil {
+		m.beforeClaim()
+		m.beforeClaim = nil
+	}
+	if !m.lastEnd.Equal(prevEnd) {
+		return false, nil
+	}
+	m.lastEnd = root.BucketTime
+	return true, nil
+}
+
+func (m *memIntervalStore) StoreIntervalRoot(ctx context.Context, root *IntervalRoot) error {
+	if m.storeErr != nil {
+		return m.storeErr
+	}
+	m.roots = append(m.roots, root)
+	return nil
+}
+
+func (m *memIntervalStore) BuildMerkleRoot(hashes []string) string {
+	tree, _ := merkle.Build(context.Background(), hashes, merkle.SchemeRFC6962)
+	return tree.Root()
+}
+
+func TestIntervalCommitterTwoIntervals(t *testing.T) {
+	ctx := context.Background()
+	store := &memIntervalStore{}
+	ic := NewIntervalCommitter(store, time.Minute)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// First interval: two batches, one event redelivered into both
+	store.addBatch(t0.Add(-50*time.Second), "a", "b")
+	store.addBatch(t0.Add(-30*time.Second), "b", "c")
+	if err := ic.commit(ctx, t0.Add(intervalSettle)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Second interval; a restarted processor has no memory of the first
+	store.addBatch(t0.Add(20*time.Second), "d")
+	store.addBatch(t0.Add(40*time.Second), "e", "f")
+	ic = NewIntervalCommitter(store, time.Minute)
+	if err := ic.commit(ctx, t0.Add(time.Minute+intervalSettle)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.roots) != 2 {
+		t.Fatalf("got %d interval roots, want 2", len(store.roots))
+	}
+	want := [][]string{{"a", "b", "c"}, {"d", "e", "f"}}
+	for i, root := range store.roots {
+		if fmt.Sprint(root.EventHashes) != fmt.Sprint(want[i]) {
+			t.Errorf("interval %d commits %v, want %v", i, root.EventHashes, want[i])
+		}
+		if root.RootHash != store.BuildMerkleRoot(want[i]) {
+			t.Errorf("interval %d: root doesn't match its hashes", i)
+		}
+	}
+	if first, second := store.roots[0], store.roots[1]; !second.WindowStart.Equal(first.BucketTime) {
+		t.Errorf("windows aren't contiguous: %v then %v", first.BucketTime, second.WindowStart)
+	}
+	if store.roots[1].FirstFactoID != "ft-d" || store.roots[1].LastFactoID != "ft-f" {
+		t.Errorf("second interval spans %s..%s", store.roots[1].FirstFactoID, store.roots[1].LastFactoID)
+	}
+
+	// Nothing new: no third root, and nothing counted again
+	if err := ic.commit(ctx, t0.Add(2*time.Minute+intervalSettle)); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.roots) != 2 {
+		t.Errorf("an empty interval stored a root")
+	}
+}
+
+func TestIntervalCommitterLeavesUnsettledBatches(t *testing.T) {
+	ctx := context.Background()
+	store := &memIntervalStore{}
+	ic := NewIntervalCommitter(store, time.Minute)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	store.addBatch(t0.Add(-time.Second), "a")
+	store.addBatch(t0.Add(time.Second), "b") // Within intervalSettle of now
+	if err := ic.commit(ctx, t0.Add(intervalSettle)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ic.commit(ctx, t0.Add(time.Minute+intervalSettle)); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.roots) != 2 || store.roots[0].EventHashes[0] != "a" || store.roots[1].EventHashes[0] != "b" {
+		t.Errorf("got roots %+v", store.roots)
+	}
+}
+
+// When another processor claims a window first, the committer continues from
+// the other processor's window instead of committing the same batches again
+func TestIntervalCommitterLosesClaimRace(t *testing.T) {
+	ctx := context.Background()
+	store := &memIntervalStore{}
+	ic := NewIntervalCommitter(store, time.Minute)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	store.addBatch(t0.Add(-40*time.Second), "a")
+	store.addBatch(t0.Add(-5*time.Second), "b")
+	store.beforeClaim = func() {
+		store.lastEnd = t0.Add(-20 * time.Second)
+		store.roots = append(store.roots, &IntervalRoot{BucketTime: store.lastEnd, EventHashes: []string{"a"}})
+	}
+	if err := ic.commit(ctx, t0.Add(intervalSettle)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.roots) != 2 || fmt.Sprint(store.roots[1].EventHashes) != "[b]" {
+		t.Fatalf("got roots %+v", store.roots)
+	}
+	if !store.roots[1].WindowStart.Equal(t0.Add(-20 * time.Second)) {
+		t.Errorf("window starts at %v", store.roots[1].WindowStart)
+	}
+}
+
+func TestIntervalCommitterRetriesClaimedRoot(t *testing.T) {
+	ctx := context.Background()
+	store := &memIntervalStore{storeErr: errors.New("unavailable")}
+	ic := NewIntervalCommitter(store, time.Minute)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	store.addBatch(t0.Add(-30*time.Second), "a")
+	if err := ic.commit(ctx, t0.Add(intervalSettle)); err == nil {
+		t.Fatal("expected the store error")
+	}
+
+	// The window stays claimed; the same root is stored on the next tick,
+	// before the next window is committed
+	store.storeErr = nil
+	store.addBatch(t0.Add(30*time.Second), "b")
+	if err := ic.commit(ctx, t0.Add(time.Minute+intervalSettle)); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.roots) != 2 || fmt.Sprint(store.roots[0].EventHashes) != "[a]" || fmt.Sprint(store.roots[1].EventHashes) != "[b]" {
+		t.Errorf("got roots %+v", store.roots)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	dailyRootsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_processor_daily_roots_created_total",
+		Help: "Total number of daily Merkle roots compacted from batch roots",
+	})
+
+	batchRootsPruned = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_processor_batch_roots_pruned_total",
+		Help: "Total number of batch Merkle roots deleted after daily compaction",
+	})
+)
+
+// dailyStore is the storage a DailyCompactor reads a day's roots from and
+// writes its daily root to
+type dailyStore interface {
+	GetMerkleRoots(ctx context.Context, date time.Time) ([]MerkleRootRow, error)
+	BuildMerkleRoot(hashes []string) string
+	StoreMerkleRoot(ctx context.Context, rootType string, bucketTime time.Time, rootHash string, eventCount int, firstFactoID, lastFactoID string, eventHashes []string) error
+	DeleteMerkleRoot(ctx context.Context, date time.Time, root MerkleRootRow, withIndex bool) error
+}
+
+// DailyCompactor replaces a completed day's many per-batch roots with a single
+// daily root: a flat Merkle tree over every event hash the day's batch roots
+// commit, in commit order. A day's merkle_roots partition is keyed by the time
+// roots were stored, so once the day is over no new batch roots land in it and
+// compaction is deterministic; compactors on several processors write the same
+// daily root.
+type DailyCompactor struct {
+	storage  dailyStore
+	interval time.Duration
+	lookback int
+	prune    bool
+}
+
+// NewDailyCompactor creates a compactor that, every interval, compacts each of
+// the last lookback completed days not yet compacted. With prune the batch
+// roots (and their reverse index rows) are deleted once the daily root is
+// stored.
+func NewDailyCompactor(storage dailyStore, interval time.Duration, lookback int, prune bool) *DailyCompactor {
+	return &DailyCompactor{
+		storage:  storage,
+		interval: interval,
+		lookback: lookback,
+		prune:    prune,
+	}
+}
+
+// Run compacts immediately and then on every interval tick until the context
+// is cancelled
+func (dc *DailyCompactor) Run(ctx context.Context) {
+	dc.compactCompletedDays(ctx, time.Now())
+
+	ticker := time.NewTicker(dc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			dc.compactCompletedDays(ctx, now)
+		}
+	}
+}
+
+// compactCompletedDays compacts the lookback days before now's day, oldest
+// first. A failed day is logged and retried on the next tick.
+func (dc *DailyCompactor) compactCompletedDays(ctx context.Context, now time.Time) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	for i := dc.lookback; i >= 1; i-- {
+		date := today.AddDate(0, 0, -i)
+		if err := dc.compactDay(ctx, date); err != nil {
+			log.Error().Err(err).Time("date", date).Msg("Failed to compact daily root")
+		}
+	}
+}
+
+// compactDay stores the daily root for date unless one exists already, then
+// prunes the day's batch roots if enabled. Batch roots left over from an
+// interrupted prune are pruned on a later run.
+func (dc *DailyCompactor) compactDay(ctx context.Context, date time.Time) error {
+	roots, err := dc.storage.GetMerkleRoots(ctx, date)
+	if err != nil {
+		return err
+	}
+
+	// Only batch roots feed the daily root. Interval roots stored here
+	// before they moved to merkle_interval_roots commit the same events, so
+	// counting them too would include hashes twice.
+	var batches []MerkleRootRow
+	daily := ""
+	for _, root := range roots {
+		switch root.RootType {
+		case RootTypeDaily:
+			daily = root.RootHash
+		case RootTypeBatch, "":
+			batches = append(batches, root)
+		}
+	}
+	if len(batches) == 0 {
+		return nil
+	}
+	if daily != "" {
+		return dc.pruneBatches(ctx, date, batches, daily)
+	}
+
+	// Rows come back newest first; the daily tree commits in stored order.
+	// A redelivered event may sit in two batch roots, so hashes are deduped.
+	var hashes []string
+	seen := make(map[string]bool)
+	for i := len(batches) - 1; i >= 0; i-- {
+		for _, hash := range batches[i].EventHashes {
+			if !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+
+	first, last := batches[len(batches)-1], batches[0]
+	bucketTime := date.Add(24*time.Hour - time.Millisecond)
+	root := dc.storage.BuildMerkleRoot(hashes)
+	if err := dc.storage.StoreMerkleRoot(ctx, RootTypeDaily, bucketTime, root, len(hashes), first.FirstFactoID, last.LastFactoID, hashes); err != nil {
+		return err
+	}
+
+	dailyRootsCreated.Inc()
+	log.Info().
+		Time("date", date).
+		Int("batch_roots", len(batches)).
+		Int("count", len(hashes)).
+		Str("merkle_root", root).
+		Msg("Daily root compacted")
+
+	return dc.pruneBatches(ctx, date, batches, root)
+}
+
+// pruneBatches deletes compacted batch roots when pruning is enabled. A day
+// with a single batch has a daily root equal to the batch root, so their
+// merkle_roots_by_event rows are shared and kept.
+func (dc *DailyCompactor) pruneBatches(ctx context.Context, date time.Time, batches []MerkleRootRow, dailyRoot string) error {
+	if !dc.prune {
+		return nil
+	}
+	for _, batch := range batches {
+		if err := dc.storage.DeleteMerkleRoot(ctx, date, batch, batch.RootHash != dailyRoot); err != nil {
+			return err
+		}
+		batchRootsPruned.Inc()
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+)
+
+// memDailyStore keeps one day's merkle_roots rows in memory, newest first
+// like GetMerkleRoots
+type memDailyStore struct {
+	roots   []MerkleRootRow
+	deleted []string
+}
+
+func (m *memDailyStore) GetMerkleRoots(ctx context.Context, date time.Time) ([]MerkleRootRow, error) {
+	return m.roots, nil
+}
+
+func (m *memDailyStore) BuildMerkleRoot(hashes []string) string {
+	tree, _ := merkle.Build(context.Background(), hashes, merkle.SchemeRFC6962)
+	return tree.Root()
+}
+
+func (m *memDailyStore) StoreMerkleRoot(ctx context.Context, rootType string, bucketTime time.Time, rootHash string, eventCount int, firstFactoID, lastFactoID string, eventHashes []string) error {
+	m.roots = append([]MerkleRootRow{{
+		BucketTime:   bucketTime,
+		RootHash:     rootHash,
+		RootType:     rootType,
+		FirstFactoID: firstFactoID,
+		LastFactoID:  lastFactoID,
+		EventHashes:  eventHashes,
+	}}, m.roots...)
+	return nil
+}
+
+func (m *memDailyStore) DeleteMerkleRoot(ctx context.Context, date time.Time, root MerkleRootRow, withIndex bool) error {
+	m.deleted = append(m.deleted, fmt.Sprintf("%s index=%v", root.RootHash, withIndex))
+	for i, r := range m.roots {
+		if r.RootHash == root.RootHash {
+			m.roots = append(m.roots[:i], m.roots[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestDailyCompactorCompactsBatchRoots(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := &memDailyStore{}
+	// Newest first; "b" was redelivered into both batches
+	store.roots = []MerkleRootRow{
+		{BucketTime: date.Add(14 * time.Hour), RootHash: "batch-2", RootType: RootTypeBatch, FirstFactoID: "ft-b", LastFactoID: "ft-c", EventHashes: []string{"b", "c"}},
+		{BucketTime: date.Add(9 * time.Hour), RootHash: "batch-1", RootType: RootTypeBatch, FirstFactoID: "ft-a", LastFactoID: "ft-b", EventHashes: []string{"a", "b"}},
+	}
+	dc := NewDailyCompactor(store, time.Hour, 1, true)
+
+	if err := dc.compactDay(ctx, date); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.roots) != 1 {
+		t.Fatalf("got roots %+v, want only the daily root", store.roots)
+	}
+	daily := store.roots[0]
+	if daily.RootType != RootTypeDaily || fmt.Sprint(daily.EventHashes) != "[a b c]" {
+		t.Errorf("daily root %+v", daily)
+	}
+	if daily.RootHash != store.BuildMerkleRoot([]string{"a", "b", "c"}) {
+		t.Error("daily root doesn't match the day's event hashes")
+	}
+	if daily.FirstFactoID != "ft-a" || daily.LastFactoID != "ft-c" || !daily.BucketTime.Equal(date.Add(24*time.Hour-time.Millisecond)) {
+		t.Errorf("daily root spans %s..%s at %v", daily.FirstFactoID, daily.LastFactoID, daily.BucketTime)
+	}
+	if fmt.Sprint(store.deleted) != "[batch-2 index=true batch-1 index=true]" {
+		t.Errorf("pruned %v", store.deleted)
+	}
+
+	// A second run finds the daily root and stores nothing new
+	if err := dc.compactDay(ctx, date); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.roots) != 1 {
+		t.Errorf("second run left roots %+v", store.roots)
+	}
+}
@@ -3,14 +3,24 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/factoid"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -50,6 +60,56 @@ var (
 		Name: "facto_processor_merkle_trees_created_total",
 		Help: "Total number of Merkle trees created",
 	})
+
+	sessionLimitRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_processor_session_limit_rejected_total",
+		Help: "Total number of events rejected because their session exceeded MAX_EVENTS_PER_SESSION",
+	})
+
+	unknownStatusTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "facto_processor_unknown_status_total",
+		Help: "Total number of events with a status outside the allowed set",
+	}, []string{"action"})
+
+	invalidFactoIDTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_processor_invalid_facto_id_total",
+		Help: "Total number of events rejected for a malformed facto_id",
+	})
+
+	toolCallsExceededTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_processor_tool_calls_exceeded_total",
+		Help: "Total number of events rejected for exceeding MAX_TOOL_CALLS",
+	})
+
+	eventsRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "facto_processor_events_rejected_total",
+		Help: "Total number of events dead-lettered for failing ingest-time verification",
+	}, []string{"reason"})
+
+	eventsDuplicateTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_processor_events_duplicate_total",
+		Help: "Total number of redelivered events skipped because their facto_id was already stored",
+	})
+
+	eventsDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "facto_processor_events_deadlettered_total",
+		Help: "Total number of messages published to DEAD_LETTER_SUBJECT",
+	}, []string{"reason"})
+
+	natsErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "facto_processor_nats_errors_total",
+		Help: "Total number of asynchronous NATS client errors",
+	}, []string{"kind"})
+
+	eventsRepublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "facto_processor_events_republished_total",
+		Help: "Total number of stored events republished to OUTPUT_SUBJECT",
+	}, []string{"result"})
+
+	workersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "facto_processor_workers_busy",
+		Help: "Number of workers currently flushing a batch",
+	})
 )
 
 // FactoEvent represents an event received from NATS
@@ -66,6 +126,15 @@ type FactoEvent struct {
 	Proof         Proof                  `json:"proof"`
 	StartedAt     int64                  `json:"started_at"`
 	CompletedAt   int64                  `json:"completed_at"`
+
+	// CustomFields holds unsigned per-event fields, such as allowlisted NATS
+	// message headers. They aren't part of the canonical form, so they are
+	// stored apart from ExecutionMeta.Tags, which is signed.
+	CustomFields map[string]string `json:"-"`
+
+	// StreamSeq is the JetStream stream sequence of the message, set only
+	// with STORE_STREAM_SEQ=true (0 = not recorded)
+	StreamSeq uint64 `json:"-"`
 }
 
 // ExecutionMeta contains execution metadata
@@ -85,24 +154,140 @@ type ExecutionMeta struct {
 type Proof struct {
 	Signature string `json:"signature"`
 	PublicKey string `json:"public_key"`
+	SigAlgo   string `json:"sig_algo,omitempty"` // empty means ed25519
 	PrevHash  string `json:"prev_hash"`
 	EventHash string `json:"event_hash"`
+
+	// CanonicalVersion is the canonical form the hash and signature cover;
+	// 0 (absent) means canonical.V1
+	CanonicalVersion canonical.Version `json:"canonical_version,omitempty"`
+}
+
+// batchStore is the storage a Consumer writes batches, their Merkle roots
+// and session and agent summaries to
+type batchStore interface {
+	GetSessionEventCount(ctx context.Context, sessionID string) (int64, error)
+	StoreBatch(ctx context.Context, events []FactoEvent) (inserted int, duplicate []bool, err error)
+	BuildMerkleRoot(hashes []string) string
+	StoreMerkleRoot(ctx context.Context, rootType string, bucketTime time.Time, rootHash string, eventCount int, firstFactoID, lastFactoID string, eventHashes []string) error
+	LinkBatchRoot(ctx context.Context, bucketTime time.Time, rootHash string, state *RootChainState) (*RootChainState, string, error)
+	IncrementSessionEventCounts(ctx context.Context, counts map[string]int64) error
+	UpdateAgentSummaries(ctx context.Context, events []FactoEvent) error
 }
 
 // Consumer handles NATS message consumption
 type Consumer struct {
 	nc            *nats.Conn
 	js            jetstream.JetStream
-	storage       *Storage
+	storage       batchStore
 	batchSize     int
-	flushInterval time.Duration
-	events        []FactoEvent
-	messages      []jetstream.Msg
+	workerCount   int
+	pacer         *FlushPacer
+	adaptive      bool
+	lagInterval   time.Duration
+	lag           *LagMonitor // set by Start
+	warmupWait    time.Duration
+	headerTags    []string
+	statuses      map[string]bool
+	statusMode    string
+	maxPerSession int64
+	maxToolCalls  int
+	notifier      *CommitNotifier
+	anchorer      *RootAnchorer
+	timestamper   *RootTimestamper
+	indexer       *SearchIndexer
+	outputSubject string
+	outputMode    string
+	slowPause     time.Duration
+	rootsOnly     bool
+	streamSeq     bool
+	verifyIngest  bool
+	deadLetter    string
+	maxDeliver    int
+	pausedUntil   atomic.Int64 // unix nanos; fetches wait until then
+	lastBucket    atomic.Int64 // unix millis of the last batch root's bucket time
+
+	// publishMsg sends to OUTPUT_SUBJECT and the dead-letter subject;
+	// nc.PublishMsg outside tests
+	publishMsg func(msg *nats.Msg) error
+
+	// Workers link their roots one at a time under chainMu, so they don't
+	// race each other's lightweight transactions. rootChain is the chain row
+	// after this consumer's last link; a guess at the current one.
+	chainMu   sync.Mutex
+	rootChain *RootChainState
+}
+
+// batchWorker buffers and flushes batches of the messages routed to it. Each
+// worker owns its buffer, so batches are stored concurrently and each gets its
+// own Merkle root. Messages are routed by session (see sessionWorker), so a
+// session's events are stored by one worker in stream order.
+type batchWorker struct {
+	*Consumer
+	id       int
+	events   []FactoEvent
+	messages []jetstream.Msg
 }
 
+// drainTimeout bounds how long workers spend storing their last batches once
+// the consumer is stopped
+const drainTimeout = 5 * time.Second
+
 // NewConsumer creates a new NATS consumer
-func NewConsumer(natsURL string, storage *Storage, batchSize int, flushInterval time.Duration) (*Consumer, error) {
-	nc, err := nats.Connect(natsURL,
+func NewConsumer(config *Config, storage *Storage) (*Consumer, error) {
+	var notifier *CommitNotifier
+	if config.CommitWebhookURL != "" {
+		notifier = NewCommitNotifier(config.CommitWebhookURL, config.WebhookSecret, config.CommitWebhookRetries)
+	}
+
+	var anchor Anchor = noopAnchor{}
+	if config.AnchorURL != "" {
+		anchor = NewHTTPAnchor(config.AnchorURL)
+	}
+
+	var timestamper *RootTimestamper
+	if config.TSAURL != "" {
+		timestamper = NewRootTimestamper(NewTSAClient(config.TSAURL), storage, config.TSARetries)
+	}
+
+	var indexer *SearchIndexer
+	if config.SearchIndexURL != "" {
+		indexer = NewSearchIndexer(config.SearchIndexURL, config.SearchIndexName)
+	}
+
+	pacer := NewFlushPacer(config.FlushInterval, config.FlushInterval, config.FlushInterval)
+	if config.FlushMode == FlushAdaptive {
+		pacer = NewFlushPacer(config.FlushInterval, config.FlushIntervalMin, config.FlushIntervalMax)
+	}
+
+	c := &Consumer{
+		storage:       storage,
+		batchSize:     config.BatchSize,
+		workerCount:   config.WorkerCount,
+		pacer:         pacer,
+		adaptive:      config.FlushMode == FlushAdaptive,
+		lagInterval:   config.ConsumerLagInterval,
+		warmupWait:    config.PrefetchWarmupWait,
+		headerTags:    config.HeaderTags,
+		statuses:      toSet(config.AllowedStatuses),
+		statusMode:    config.StatusValidation,
+		maxPerSession: config.MaxEventsPerSession,
+		maxToolCalls:  config.MaxToolCalls,
+		notifier:      notifier,
+		anchorer:      NewRootAnchorer(anchor, storage, config.AnchorRetries),
+		timestamper:   timestamper,
+		indexer:       indexer,
+		outputSubject: config.OutputSubject,
+		outputMode:    config.OutputMode,
+		slowPause:     config.SlowConsumerPause,
+		rootsOnly:     config.RootsOnly,
+		streamSeq:     config.StoreStreamSeq,
+		verifyIngest:  config.VerifyOnIngest,
+		deadLetter:    config.DeadLetterSubject,
+		maxDeliver:    config.DeadLetterMaxDeliveries,
+	}
+
+	nc, err := nats.Connect(config.NatsURL,
 		nats.RetryOnFailedConnect(true),
 		nats.MaxReconnects(-1),
 		nats.ReconnectWait(time.Second),
@@ -112,6 +297,7 @@ func NewConsumer(natsURL string, storage *Storage, batchSize int, flushInterval
 		nats.ReconnectHandler(func(nc *nats.Conn) {
 			log.Info().Msg("NATS reconnected")
 		}),
+		nats.ErrorHandler(c.handleNATSError),
 	)
 	if err != nil {
 		return nil, err
@@ -123,15 +309,41 @@ func NewConsumer(natsURL string, storage *Storage, batchSize int, flushInterval
 		return nil, err
 	}
 
-	return &Consumer{
-		nc:            nc,
-		js:            js,
-		storage:       storage,
-		batchSize:     batchSize,
-		flushInterval: flushInterval,
-		events:        make([]FactoEvent, 0, batchSize),
-		messages:      make([]jetstream.Msg, 0, batchSize),
-	}, nil
+	c.nc = nc
+	c.js = js
+	c.publishMsg = nc.PublishMsg
+	return c, nil
+}
+
+// handleNATSError handles asynchronous NATS errors. A slow consumer means
+// messages are arriving faster than they are drained, so fetching pauses
+// briefly to let the pending backlog (and the database) catch up.
+func (c *Consumer) handleNATSError(_ *nats.Conn, sub *nats.Subscription, err error) {
+	kind := "other"
+	if errors.Is(err, nats.ErrSlowConsumer) {
+		kind = "slow_consumer"
+		if c.slowPause > 0 {
+			c.pausedUntil.Store(time.Now().Add(c.slowPause).UnixNano())
+		}
+	}
+	natsErrorsTotal.WithLabelValues(kind).Inc()
+
+	logEvent := log.Warn().Err(err).Str("kind", kind)
+	if sub != nil {
+		logEvent = logEvent.Str("subject", sub.Subject)
+	}
+	logEvent.Msg("NATS async error")
+}
+
+// waitIfPaused blocks while a slow-consumer pause is in effect
+func (c *Consumer) waitIfPaused(ctx context.Context) {
+	until := time.Unix(0, c.pausedUntil.Load())
+	if wait := time.Until(until); wait > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
 }
 
 // Start begins consuming messages
@@ -178,140 +390,762 @@ func (c *Consumer) Start(ctx context.Context) error {
 		// If I change the FilterSubject, I update the consumer.
 		FilterSubject: subject,
 		AckPolicy:     jetstream.AckExplicitPolicy,
-		MaxAckPending: c.batchSize * 2,
+		MaxAckPending: c.batchSize * 2 * c.workerCount,
 		AckWait:       30 * time.Second,
 	})
 	if err != nil {
 		return err
 	}
 
-	log.Info().Msg("Started consuming from FACTO_EVENTS stream")
+	log.Info().Int("workers", c.workerCount).Msg("Started consuming from FACTO_EVENTS stream")
 
-	// Create ticker for flush interval
-	ticker := time.NewTicker(c.flushInterval)
-	defer ticker.Stop()
+	var observe func(*jetstream.ConsumerInfo)
+	if c.adaptive {
+		observe = c.pacer.Observe
+	}
+	c.lag = NewLagMonitor(consumer, c.lagInterval, observe)
+	go c.lag.Run(ctx)
 
-	// Consume messages
-	msgChan := make(chan jetstream.Msg, c.batchSize)
+	// Consume messages, routing each to its session's worker
+	workerChans := make([]chan jetstream.Msg, c.workerCount)
+	for i := range workerChans {
+		workerChans[i] = make(chan jetstream.Msg, c.batchSize)
+	}
 	go func() {
+		warmup := newFetchWarmup(c.warmupWait, c.batchSize)
 		for {
 			select {
 			case <-ctx.Done():
-				close(msgChan)
+				for _, ch := range workerChans {
+					close(ch)
+				}
 				return
 			default:
-				msgs, err := consumer.Fetch(c.batchSize, jetstream.FetchMaxWait(c.flushInterval))
+				c.waitIfPaused(ctx)
+
+				msgs, err := consumer.Fetch(c.batchSize, jetstream.FetchMaxWait(warmup.maxWait(c.pacer.Interval())))
 				if err != nil {
 					if err != context.Canceled {
 						log.Debug().Err(err).Msg("Fetch returned")
 					}
 					continue
 				}
+				// A busy worker's full channel holds up routing to the
+				// others; the order within each session is worth that
+				fetched := 0
 				for msg := range msgs.Messages() {
-					msgChan <- msg
+					workerChans[c.workerFor(msg)] <- msg
+					fetched++
 				}
+				warmup.add(fetched)
 			}
 		}
 	}()
 
+	// Start returns once every worker has stored its last batch
+	c.runWorkers(ctx, workerChans)
+	return ctx.Err()
+}
+
+// runWorkers runs a batchWorker on each channel and returns once every worker
+// has stopped and stored its last batch
+func (c *Consumer) runWorkers(ctx context.Context, workerChans []chan jetstream.Msg) {
+	var wg sync.WaitGroup
+	for i, msgs := range workerChans {
+		w := &batchWorker{
+			Consumer: c,
+			id:       i,
+			events:   make([]FactoEvent, 0, c.batchSize),
+			messages: make([]jetstream.Msg, 0, c.batchSize),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx, msgs)
+		}()
+	}
+	wg.Wait()
+}
+
+// fetchWarmup picks the fetch max-wait. Until the first batch has been
+// fetched it polls with a short max-wait, so a backlog queued before startup
+// is picked up immediately rather than after a full flush interval.
+type fetchWarmup struct {
+	wait      time.Duration
+	batchSize int
+	fetched   int
+	warm      bool
+}
+
+// newFetchWarmup creates a warmup polling every wait; a zero wait skips it
+func newFetchWarmup(wait time.Duration, batchSize int) *fetchWarmup {
+	return &fetchWarmup{wait: wait, batchSize: batchSize, warm: wait <= 0}
+}
+
+// maxWait returns the max-wait for the next fetch, given the steady-state one
+func (f *fetchWarmup) maxWait(steady time.Duration) time.Duration {
+	if f.warm {
+		return steady
+	}
+	return f.wait
+}
+
+// add records n fetched messages, ending the warmup once a batch has arrived
+func (f *fetchWarmup) add(n int) {
+	if f.warm {
+		return
+	}
+	f.fetched += n
+	if f.fetched >= f.batchSize {
+		f.warm = true
+		log.Debug().Int("fetched", f.fetched).Msg("Prefetch warmup complete")
+	}
+}
+
+// workerFor returns the worker for a message: the worker of its session_id.
+// A message whose session_id can't be read goes to worker 0, where
+// handleMessage rejects it.
+func (c *Consumer) workerFor(msg jetstream.Msg) int {
+	if c.workerCount == 1 {
+		return 0
+	}
+	var routing struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(msg.Data(), &routing); err != nil {
+		return 0
+	}
+	return sessionWorker(routing.SessionID, c.workerCount)
+}
+
+// sessionWorker hashes a session ID onto one of workers workers
+func sessionWorker(sessionID string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(sessionID))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// run handles messages until ctx is done, flushing whenever the buffer fills
+// and on every flush interval. The ticker follows the pacer's interval.
+func (w *batchWorker) run(ctx context.Context, msgs <-chan jetstream.Msg) {
+	interval := w.pacer.Interval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
-			// Flush remaining events
-			if len(c.events) > 0 {
-				c.flush(ctx)
-			}
-			return ctx.Err()
+			w.drain(msgs)
+			return
 
-		case msg, ok := <-msgChan:
+		case msg, ok := <-msgs:
 			if !ok {
-				return nil
+				// Closed on shutdown; the buffered batch is still stored
+				w.drain(msgs)
+				return
 			}
-			c.handleMessage(ctx, msg)
+			w.handleMessage(ctx, msg)
 
 		case <-ticker.C:
-			if len(c.events) > 0 {
-				c.flush(ctx)
+			if len(w.events) > 0 {
+				w.flush(ctx)
 			}
 		}
+
+		if next := w.pacer.Interval(); next != interval {
+			interval = next
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// drain handles the messages still queued once the consumer is stopped and
+// flushes what is buffered. It uses a fresh context so the final writes
+// aren't cancelled too; messages left over are redelivered after AckWait.
+func (w *batchWorker) drain(msgs <-chan jetstream.Msg) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	for msg := range msgs {
+		w.handleMessage(ctx, msg)
+	}
+	if len(w.events) > 0 {
+		w.flush(ctx)
 	}
 }
 
-func (c *Consumer) handleMessage(ctx context.Context, msg jetstream.Msg) {
+func (w *batchWorker) handleMessage(ctx context.Context, msg jetstream.Msg) {
 	eventsConsumed.Inc()
 
+	// The span covers validation only; the batch write is traced by flush
+	_, span := tracer.Start(messageContext(ctx, msg), "Consumer.handleMessage",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", msg.Subject())))
+	defer span.End()
+
+	// canonical.Unmarshal keeps numbers exact, so integers in input_data and
+	// tool_calls hash as sent
 	var event FactoEvent
-	if err := json.Unmarshal(msg.Data(), &event); err != nil {
-		log.Error().Err(err).Msg("Failed to unmarshal event")
-		msg.Nak()
+	if err := canonical.Unmarshal(msg.Data(), &event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unmarshal failed")
+		delivered := deliveries(msg)
+		log.Error().Err(err).Uint64("delivered", delivered).Msg("Failed to unmarshal event")
+		eventsFailedTotal.Inc()
+		if delivered < uint64(w.maxDeliver) {
+			msg.Nak()
+			return
+		}
+		// Ack once dead-lettered so a poison message can't block the consumer
+		if w.deadLetterMessage(msg, deadLetterUnmarshal, err.Error()) {
+			msg.Ack()
+		}
+		return
+	}
+	span.SetAttributes(
+		attribute.String("facto.facto_id", event.FactoID),
+		attribute.Int("facto.worker", w.id),
+	)
+
+	if !factoid.Valid(event.FactoID) {
+		// facto_id is a primary key in every table; never store a malformed one
+		log.Error().Str("facto_id", event.FactoID).Msg("Event has malformed facto_id, dead-lettering")
+		if w.deadLetterMessage(msg, deadLetterFactoID, "malformed facto_id") {
+			msg.Term()
+			invalidFactoIDTotal.Inc()
+		}
+		eventsFailedTotal.Inc()
+		return
+	}
+
+	if w.maxToolCalls > 0 && len(event.ExecutionMeta.ToolCalls) > w.maxToolCalls {
+		log.Error().
+			Str("facto_id", event.FactoID).
+			Int("tool_calls", len(event.ExecutionMeta.ToolCalls)).
+			Int("limit", w.maxToolCalls).
+			Msg("Event exceeds tool call limit, dead-lettering")
+		detail := fmt.Sprintf("%d tool calls exceed the limit of %d", len(event.ExecutionMeta.ToolCalls), w.maxToolCalls)
+		if w.deadLetterMessage(msg, deadLetterToolCalls, detail) {
+			msg.Term()
+			toolCallsExceededTotal.Inc()
+		}
+		eventsFailedTotal.Inc()
+		return
+	}
+
+	if w.verifyIngest {
+		if reason := verifyEvent(&event); reason != "" {
+			log.Error().
+				Str("facto_id", event.FactoID).
+				Str("reason", reason).
+				Msg("Event failed verification, dead-lettering")
+			if w.deadLetterMessage(msg, reason, "") {
+				msg.Term()
+				eventsRejectedTotal.WithLabelValues(reason).Inc()
+			}
+			eventsFailedTotal.Inc()
+			return
+		}
+	}
+
+	if !w.checkStatus(&event) {
+		// Dead-letter rather than Nak: redelivery can't fix the status
+		if w.deadLetterMessage(msg, deadLetterStatus, "unknown status "+event.Status) {
+			msg.Term()
+		}
 		eventsFailedTotal.Inc()
 		return
 	}
 
-	c.events = append(c.events, event)
-	c.messages = append(c.messages, msg)
+	event.CustomFields = w.extractHeaders(msg)
+	if w.streamSeq {
+		if meta, err := msg.Metadata(); err == nil {
+			event.StreamSeq = meta.Sequence.Stream
+		} else {
+			log.Warn().Err(err).Str("facto_id", event.FactoID).Msg("Failed to read message metadata")
+		}
+	}
+
+	log.Debug().
+		Str("facto_id", event.FactoID).
+		Str("session_id", event.SessionID).
+		Int("worker", w.id).
+		Msg("Event buffered")
+
+	w.events = append(w.events, event)
+	w.messages = append(w.messages, msg)
+
+	if len(w.events) >= w.batchSize {
+		w.flush(ctx)
+	}
+}
+
+// deadLetterUnmarshal is the dead-letter reason for messages that aren't a
+// valid event
+const deadLetterUnmarshal = "unmarshal"
+
+// deadLetterFactoID is the dead-letter reason for events whose facto_id
+// isn't in the canonical format
+const deadLetterFactoID = "facto_id"
+
+// deadLetterToolCalls is the dead-letter reason for events with more than
+// MAX_TOOL_CALLS tool calls
+const deadLetterToolCalls = "tool_calls"
+
+// deadLetterStatus is the dead-letter reason for events rejected by
+// STATUS_VALIDATION=reject
+const deadLetterStatus = "status"
+
+// deadLetterMessage publishes a message's raw bytes to the dead-letter
+// subject with the reason and error in its headers. If the publish fails the
+// message is NAKed so it is dead-lettered on redelivery instead of being lost,
+// and false is returned; the caller settles the message otherwise.
+func (c *Consumer) deadLetterMessage(msg jetstream.Msg, reason, detail string) bool {
+	dead := nats.NewMsg(c.deadLetter)
+	dead.Data = msg.Data()
+	dead.Header.Set("Facto-Reject-Reason", reason)
+	dead.Header.Set("Facto-Original-Subject", msg.Subject())
+	if detail != "" {
+		dead.Header.Set("Facto-Error", detail)
+	}
+	if err := c.publishMsg(dead); err != nil {
+		log.Error().Err(err).Str("reason", reason).Msg("Failed to publish to dead-letter subject")
+		msg.Nak()
+		return false
+	}
+
+	eventsDeadLettered.WithLabelValues(reason).Inc()
+	return true
+}
+
+// deliveries returns how many times a message has been delivered, or 1 when
+// its metadata can't be read
+func deliveries(msg jetstream.Msg) uint64 {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return 1
+	}
+	return meta.NumDelivered
+}
+
+// checkStatus validates an event's status against the allowed set. It returns
+// false only when the event should be rejected.
+func (c *Consumer) checkStatus(event *FactoEvent) bool {
+	if c.statusMode == "off" || c.statuses[event.Status] {
+		return true
+	}
+
+	logEvent := log.Warn()
+	if c.statusMode == "reject" {
+		logEvent = log.Error()
+	}
+	logEvent.
+		Str("facto_id", event.FactoID).
+		Str("status", event.Status).
+		Str("action", c.statusMode).
+		Msg("Event has unknown status")
+	unknownStatusTotal.WithLabelValues(c.statusMode).Inc()
+
+	return c.statusMode != "reject"
+}
+
+// deadLetterSessionLimit is the dead-letter reason for events beyond
+// MAX_EVENTS_PER_SESSION
+const deadLetterSessionLimit = "session_limit"
+
+// enforceSessionLimit drops buffered events whose session would exceed
+// maxPerSession, dead-lettering their messages so they aren't redelivered
+func (w *batchWorker) enforceSessionLimit(ctx context.Context) {
+	exceeded := w.sessionLimitExceeded(ctx, w.events)
+	events := w.events[:0]
+	messages := w.messages[:0]
+	detail := fmt.Sprintf("session exceeds %d events", w.maxPerSession)
+
+	for i, event := range w.events {
+		if exceeded[i] {
+			if w.deadLetterMessage(w.messages[i], deadLetterSessionLimit, detail) {
+				w.messages[i].Term()
+			}
+			continue
+		}
+		events = append(events, event)
+		messages = append(messages, w.messages[i])
+	}
+
+	w.events = events
+	w.messages = messages
+}
+
+// sessionLimitExceeded flags the events whose session would exceed
+// maxPerSession, counting the events before them. Counts are read per batch;
+// a session's batches are all flushed by one worker, but other processors
+// and bulk ingest requests can still overshoot the limit by a batch each.
+func (c *Consumer) sessionLimitExceeded(ctx context.Context, events []FactoEvent) []bool {
+	stored := make(map[string]int64)
+	exceeded := make([]bool, len(events))
+
+	for i, event := range events {
+		count, ok := stored[event.SessionID]
+		if !ok {
+			var err error
+			if count, err = c.storage.GetSessionEventCount(ctx, event.SessionID); err != nil {
+				// Fail open: a missing count must not block ingestion
+				log.Warn().Err(err).Str("session_id", event.SessionID).Msg("Failed to read session event count")
+				count = 0
+			}
+		}
+
+		if count >= c.maxPerSession {
+			log.Error().
+				Str("facto_id", event.FactoID).
+				Str("session_id", event.SessionID).
+				Int64("limit", c.maxPerSession).
+				Msg("Session event limit exceeded, rejecting event")
+			exceeded[i] = true
+			sessionLimitRejected.Inc()
+			eventsFailedTotal.Inc()
+			stored[event.SessionID] = count
+			continue
+		}
+
+		stored[event.SessionID] = count + 1
+	}
+	return exceeded
+}
+
+// dropDuplicates acks and removes the buffered events StoreBatch flagged as
+// already stored. They were durably stored by an earlier delivery, so acking
+// is safe.
+func (w *batchWorker) dropDuplicates(duplicate []bool) {
+	events := w.events[:0]
+	messages := w.messages[:0]
+
+	for i, event := range w.events {
+		if duplicate[i] {
+			log.Debug().Str("facto_id", event.FactoID).Msg("Skipping duplicate event")
+			w.messages[i].Ack()
+			eventsDuplicateTotal.Inc()
+			continue
+		}
+		events = append(events, event)
+		messages = append(messages, w.messages[i])
+	}
+
+	w.events = events
+	w.messages = messages
+}
+
+// sessionCounts counts events per session
+func sessionCounts(events []FactoEvent) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, event := range events {
+		counts[event.SessionID]++
+	}
+	return counts
+}
+
+// extractHeaders returns the allowlisted NATS headers of a message, keyed
+// by header name as configured, for the event's custom fields
+func (c *Consumer) extractHeaders(msg jetstream.Msg) map[string]string {
+	if len(c.headerTags) == 0 {
+		return nil
+	}
+
+	headers := msg.Headers()
+	if headers == nil {
+		return nil
+	}
 
-	if len(c.events) >= c.batchSize {
-		c.flush(ctx)
+	var extracted map[string]string
+	for _, name := range c.headerTags {
+		if value := headers.Get(name); value != "" {
+			if extracted == nil {
+				extracted = make(map[string]string, len(c.headerTags))
+			}
+			extracted[name] = value
+		}
 	}
+	return extracted
 }
 
-func (c *Consumer) flush(ctx context.Context) {
-	if len(c.events) == 0 {
+func (w *batchWorker) flush(ctx context.Context) {
+	if len(w.events) == 0 {
 		return
 	}
 
 	start := time.Now()
-	eventCount := len(c.events)
+	workersBusy.Inc()
+	defer workersBusy.Dec()
 
-	log.Debug().Int("count", eventCount).Msg("Processing batch")
+	ctx, span := tracer.Start(ctx, "Consumer.flush",
+		trace.WithAttributes(
+			attribute.Int("facto.batch_size", len(w.events)),
+			attribute.Int("facto.worker", w.id),
+		))
+	defer span.End()
 
-	// Build Merkle tree from event hashes
-	hashes := make([]string, len(c.events))
-	for i, event := range c.events {
-		hashes[i] = event.Proof.EventHash
+	if w.maxPerSession > 0 {
+		w.enforceSessionLimit(ctx)
+		if len(w.events) == 0 {
+			return
+		}
 	}
-	tree := BuildMerkleTree(hashes)
-	merkleRoot := tree.Root()
-	merkleTreesCreated.Inc()
 
-	// Store events in ScyllaDB
-	if err := c.storage.StoreBatch(ctx, c.events); err != nil {
-		log.Error().Err(err).Msg("Failed to store batch")
+	log.Debug().Int("count", len(w.events)).Int("worker", w.id).Msg("Processing batch")
+
+	data := make([][]byte, len(w.messages))
+	for i, msg := range w.messages {
+		data[i] = msg.Data()
+	}
+	merkleRoot, duplicate, err := w.commitBatch(ctx, span, w.events, data)
+	if duplicate != nil {
+		w.dropDuplicates(duplicate)
+		if len(w.events) == 0 {
+			return
+		}
+	}
+	eventCount := len(w.events)
+
+	if err != nil {
 		// NAK all messages
-		for _, msg := range c.messages {
+		for _, msg := range w.messages {
 			msg.Nak()
 		}
 		eventsFailedTotal.Add(float64(eventCount))
 	} else {
-		// Store Merkle root
-		if len(c.events) > 0 {
-			if err := c.storage.StoreMerkleRoot(ctx, time.Now(), merkleRoot, eventCount, c.events[0].FactoID, c.events[len(c.events)-1].FactoID, hashes); err != nil {
-				log.Error().Err(err).Msg("Failed to store Merkle root")
-			}
-		}
-
 		// ACK all messages
-		for _, msg := range c.messages {
+		for _, msg := range w.messages {
 			msg.Ack()
 		}
 		eventsProcessed.Add(float64(eventCount))
 	}
 
 	// Update metrics
+	w.lag.Refresh()
 	batchesProcessed.Inc()
 	batchSize.Observe(float64(eventCount))
 	processingLatency.Observe(time.Since(start).Seconds())
 
 	log.Info().
 		Int("count", eventCount).
+		Int("worker", w.id).
 		Str("merkle_root", merkleRoot).
 		Dur("duration", time.Since(start)).
 		Msg("Batch processed")
 
 	// Clear the batch
-	c.events = c.events[:0]
-	c.messages = c.messages[:0]
+	w.events = w.events[:0]
+	w.messages = w.messages[:0]
+}
+
+// commitBatch stores a batch of validated events and commits them to a batch
+// Merkle root, then runs everything that follows a durable store: interval
+// commitments, republishing, search indexing and session and agent summaries.
+// data holds each event's JSON as received, for republishing.
+//
+// Events already stored by an earlier delivery are flagged in duplicate (nil
+// when there are none) and left out of the root. A non-nil error means the
+// rest of the batch was not durably stored and must be retried.
+func (c *Consumer) commitBatch(ctx context.Context, span trace.Span, events []FactoEvent, data [][]byte) (string, []bool, error) {
+	// Store events in ScyllaDB before building the tree, so events already
+	// stored by an earlier delivery are left out of the Merkle root. In
+	// roots-only mode event bodies are kept elsewhere and only the Merkle
+	// root is written.
+	var err error
+	var duplicate []bool
+	if !c.rootsOnly {
+		var inserted int
+		if inserted, duplicate, err = c.storage.StoreBatch(ctx, events); err != nil {
+			log.Error().Err(err).Msg("Failed to store batch")
+			duplicate = nil
+		} else if inserted < len(events) {
+			events, data = withoutDuplicates(events, data, duplicate)
+			if len(events) == 0 {
+				return "", duplicate, nil
+			}
+		} else {
+			duplicate = nil
+		}
+	}
+
+	eventCount := len(events)
+
+	// Build Merkle tree from event hashes
+	hashes := make([]string, len(events))
+	for i, event := range events {
+		hashes[i] = event.Proof.EventHash
+	}
+	merkleRoot := c.storage.BuildMerkleRoot(hashes)
+	merkleTreesCreated.Inc()
+	span.SetAttributes(
+		attribute.String("facto.merkle_root", merkleRoot),
+		attribute.Int("facto.event_count", eventCount),
+	)
+
+	if err == nil {
+		// Store Merkle root
+		bucketTime := c.nextBucketTime()
+		firstFactoID, lastFactoID := events[0].FactoID, events[len(events)-1].FactoID
+		if rootErr := c.storage.StoreMerkleRoot(ctx, RootTypeBatch, bucketTime, merkleRoot, eventCount, firstFactoID, lastFactoID, hashes); rootErr != nil {
+			log.Error().Err(rootErr).Msg("Failed to store Merkle root")
+			// With no event rows written the root is the batch's only
+			// record, so it must be retried
+			if c.rootsOnly {
+				err = rootErr
+			}
+		} else {
+			c.linkBatchRoot(ctx, span, bucketTime, merkleRoot)
+			c.anchorer.Submit(ctx, AnchorRequest{
+				RootHash:   merkleRoot,
+				BucketTime: bucketTime,
+				EventCount: eventCount,
+			})
+			if c.timestamper != nil {
+				c.timestamper.Submit(ctx, bucketTime, merkleRoot)
+			}
+			if c.notifier != nil {
+				c.notifier.Notify(ctx, CommitSummary{
+					MerkleRoot:   merkleRoot,
+					EventCount:   eventCount,
+					FirstFactoID: firstFactoID,
+					LastFactoID:  lastFactoID,
+					BucketTime:   bucketTime,
+				})
+			}
+		}
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return merkleRoot, duplicate, err
+	}
+
+	if c.outputSubject != "" {
+		c.republish(events, data, merkleRoot)
+	}
+
+	// Search hits are hydrated from the event tables, so roots-only
+	// batches have nothing to index
+	if c.indexer != nil && !c.rootsOnly {
+		c.indexer.Index(ctx, events)
+	}
+
+	if err := c.storage.IncrementSessionEventCounts(ctx, sessionCounts(events)); err != nil {
+		log.Error().Err(err).Msg("Failed to update session event counts")
+	}
+
+	if err := c.storage.UpdateAgentSummaries(ctx, events); err != nil {
+		log.Error().Err(err).Msg("Failed to update agent summaries")
+	}
+
+	return merkleRoot, duplicate, nil
+}
+
+// withoutDuplicates returns copies of events and data without the entries
+// flagged in duplicate
+func withoutDuplicates(events []FactoEvent, data [][]byte, duplicate []bool) ([]FactoEvent, [][]byte) {
+	keptEvents := make([]FactoEvent, 0, len(events))
+	keptData := make([][]byte, 0, len(data))
+	for i := range events {
+		if !duplicate[i] {
+			keptEvents = append(keptEvents, events[i])
+			keptData = append(keptData, data[i])
+		}
+	}
+	return keptEvents, keptData
+}
+
+// nextBucketTime returns the bucket time for a new batch root. merkle_roots is
+// keyed by millisecond bucket_time, so workers flushing within the same
+// millisecond get successive milliseconds instead of overwriting each other.
+func (c *Consumer) nextBucketTime() time.Time {
+	for {
+		last := c.lastBucket.Load()
+		next := time.Now().UnixMilli()
+		if next <= last {
+			next = last + 1
+		}
+		if c.lastBucket.CompareAndSwap(last, next) {
+			return time.UnixMilli(next)
+		}
+	}
+}
+
+// linkBatchRoot links a stored batch root into the root chain, one worker at
+// a time
+func (c *Consumer) linkBatchRoot(ctx context.Context, span trace.Span, bucketTime time.Time, merkleRoot string) {
+	c.chainMu.Lock()
+	defer c.chainMu.Unlock()
+
+	chain, prev, err := c.storage.LinkBatchRoot(ctx, bucketTime, merkleRoot, c.rootChain)
+	if err != nil {
+		// The root and its events are stored; only its chain link or log
+		// entry is missing, which GET /v1/merkle-roots/chain reports and the
+		// next append repairs
+		log.Error().Err(err).Str("merkle_root", merkleRoot).Msg("Failed to link Merkle root into the root chain")
+		return
+	}
+	c.rootChain = chain
+	span.SetAttributes(
+		attribute.String("facto.prev_root_hash", prev),
+		attribute.Int64("facto.log_size", chain.LogSize),
+	)
+}
+
+// CommittedNotification is the compact message published to OUTPUT_SUBJECT
+// when OUTPUT_MODE is "notification"
+type CommittedNotification struct {
+	FactoID    string `json:"facto_id"`
+	AgentID    string `json:"agent_id"`
+	SessionID  string `json:"session_id"`
+	EventHash  string `json:"event_hash"`
+	MerkleRoot string `json:"merkle_root"`
+}
+
+// republish publishes a committed batch's events to the output subject. It
+// runs only after StoreBatch succeeded, so consumers of the subject can rely
+// on every event they see being durably stored (in roots-only mode, anchored
+// in a stored Merkle root). Publishing is best-effort: a failure is counted
+// but does not fail the batch.
+func (c *Consumer) republish(events []FactoEvent, data [][]byte, merkleRoot string) {
+	for i, event := range events {
+		data := data[i]
+		if c.outputMode == "notification" {
+			var err error
+			data, err = json.Marshal(CommittedNotification{
+				FactoID:    event.FactoID,
+				AgentID:    event.AgentID,
+				SessionID:  event.SessionID,
+				EventHash:  event.Proof.EventHash,
+				MerkleRoot: merkleRoot,
+			})
+			if err != nil {
+				eventsRepublished.WithLabelValues("failed").Inc()
+				continue
+			}
+		}
+
+		if err := c.publishMsg(&nats.Msg{Subject: c.outputSubject, Data: data}); err != nil {
+			log.Warn().Err(err).Str("facto_id", event.FactoID).Msg("Failed to republish event")
+			eventsRepublished.WithLabelValues("failed").Inc()
+			continue
+		}
+		eventsRepublished.WithLabelValues("published").Inc()
+	}
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// Connected reports whether the NATS connection is currently up
+func (c *Consumer) Connected() bool {
+	return c.nc != nil && c.nc.IsConnected()
 }
 
 // Close closes the consumer
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/factoid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeMsg is a delivered JetStream message carrying data and headers. Only
+// the methods handleMessage calls are implemented.
+type fakeMsg struct {
+	jetstream.Msg
+	data      []byte
+	headers   nats.Header
+	delivered uint64 // 0 reads as the first delivery
+	acked     string
+}
+
+func (m *fakeMsg) Data() []byte         { return m.data }
+func (m *fakeMsg) Headers() nats.Header { return m.headers }
+func (m *fakeMsg) Subject() string      { return "facto.events.agent-golden" }
+func (m *fakeMsg) Ack() error           { m.acked = "ack"; return nil }
+func (m *fakeMsg) Nak() error           { m.acked = "nak"; return nil }
+func (m *fakeMsg) Term() error          { m.acked = "term"; return nil }
+
+func (m *fakeMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{NumDelivered: max(m.delivered, 1)}, nil
+}
+
+// Allowlisted headers are buffered as custom fields and bound to the
+// custom_fields column, never merged into the signed tags
+func TestHandleMessageStoresHeadersAsCustomFields(t *testing.T) {
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &batchWorker{Consumer: &Consumer{
+		batchSize:  10,
+		headerTags: []string{"X-Tenant", "X-Trace-Id"},
+	}}
+	msg := &fakeMsg{data: data, headers: nats.Header{}}
+	msg.headers.Set("X-Tenant", "acme")
+	msg.headers.Set("X-Other", "not allowlisted")
+
+	w.handleMessage(context.Background(), msg)
+
+	if len(w.events) != 1 || msg.acked != "" {
+		t.Fatalf("event not buffered: %d events, message %q", len(w.events), msg.acked)
+	}
+	event := w.events[0]
+	if len(event.CustomFields) != 1 || event.CustomFields["X-Tenant"] != "acme" {
+		t.Errorf("custom fields %v", event.CustomFields)
+	}
+	if len(event.ExecutionMeta.Tags) != 1 || event.ExecutionMeta.Tags["env"] != "test" {
+		t.Errorf("signed tags changed: %v", event.ExecutionMeta.Tags)
+	}
+
+	columns := strings.Split(insertByFactoIDCQL[strings.Index(insertByFactoIDCQL, "(")+1:strings.Index(insertByFactoIDCQL, ")")], ",")
+	values := byFactoIDValues(eventData{event: event})
+	if len(columns) != len(values) {
+		t.Fatalf("%d columns, %d values", len(columns), len(values))
+	}
+	for i, column := range columns {
+		if strings.TrimSpace(column) != "custom_fields" {
+			continue
+		}
+		if stored, ok := values[i].(map[string]string); !ok || stored["X-Tenant"] != "acme" {
+			t.Errorf("custom_fields bound to %v", values[i])
+		}
+		return
+	}
+	t.Error("events_by_facto_id insert has no custom_fields column")
+}
+
+// simulateFetches runs fetches against a stream that already holds queued
+// messages, returning how long the first fetch took and every max-wait used.
+// Like JetStream, a fetch returns as soon as it fills a batch, otherwise after
+// its max-wait.
+func simulateFetches(warmup *fetchWarmup, queued, batchSize, fetches int, steady time.Duration) (time.Duration, []time.Duration) {
+	var first time.Duration
+	var waits []time.Duration
+	for i := 0; i < fetches; i++ {
+		maxWait := warmup.maxWait(steady)
+		waits = append(waits, maxWait)
+		n := min(queued, batchSize)
+		queued -= n
+		if i == 0 && n < batchSize {
+			first = maxWait
+		}
+		warmup.add(n)
+	}
+	return first, waits
+}
+
+func TestFetchWarmupSpeedsUpFirstBatch(t *testing.T) {
+	const batchSize = 100
+	steady := time.Second
+
+	// 60 messages queued before startup, under one batch
+	cold, _ := simulateFetches(newFetchWarmup(0, batchSize), 60, batchSize, 1, steady)
+	warm, waits := simulateFetches(newFetchWarmup(50*time.Millisecond, batchSize), 60, batchSize, 1, steady)
+	if cold != steady || warm != 50*time.Millisecond {
+		t.Errorf("first fetch took %v without warmup and %v with it", cold, warm)
+	}
+	if waits[0] != 50*time.Millisecond {
+		t.Errorf("first fetch waited %v", waits[0])
+	}
+
+	// The warmup lasts until a batch has been fetched in total, then the
+	// steady max-wait is used
+	warmup := newFetchWarmup(50*time.Millisecond, batchSize)
+	warmup.add(60)
+	if got := warmup.maxWait(steady); got != 50*time.Millisecond {
+		t.Errorf("max-wait %v after 60 of %d messages", got, batchSize)
+	}
+	warmup.add(40)
+	if got := warmup.maxWait(steady); got != steady {
+		t.Errorf("max-wait %v after a full batch", got)
+	}
+}
+
+// memBatchStore records the order of event and root writes, failing
+// StoreBatch with storeErr. Everything else succeeds.
+type memBatchStore struct {
+	storeErr error
+	calls    *[]string
+}
+
+func (m *memBatchStore) GetSessionEventCount(ctx context.Context, sessionID string) (int64, error) {
+	return 0, nil
+}
+
+func (m *memBatchStore) StoreBatch(ctx context.Context, events []FactoEvent) (int, []bool, error) {
+	if m.storeErr != nil {
+		return 0, nil, m.storeErr
+	}
+	*m.calls = append(*m.calls, "store")
+	return len(events), nil, nil
+}
+
+func (m *memBatchStore) BuildMerkleRoot(hashes []string) string {
+	return "root-" + strings.Join(hashes, "")
+}
+
+func (m *memBatchStore) StoreMerkleRoot(ctx context.Context, rootType string, bucketTime time.Time, rootHash string, eventCount int, firstFactoID, lastFactoID string, eventHashes []string) error {
+	*m.calls = append(*m.calls, "root "+rootHash)
+	return nil
+}
+
+func (m *memBatchStore) LinkBatchRoot(ctx context.Context, bucketTime time.Time, rootHash string, state *RootChainState) (*RootChainState, string, error) {
+	return &RootChainState{}, "", nil
+}
+
+func (m *memBatchStore) IncrementSessionEventCounts(ctx context.Context, counts map[string]int64) error {
+	return nil
+}
+
+func (m *memBatchStore) UpdateAgentSummaries(ctx context.Context, events []FactoEvent) error {
+	return nil
+}
+
+func TestRepublishOnlyAfterStore(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	store := &memBatchStore{storeErr: errors.New("write timeout"), calls: &calls}
+	c := &Consumer{
+		storage:       store,
+		anchorer:      NewRootAnchorer(noopAnchor{}, nil, 0),
+		outputSubject: "facto.committed",
+		publishMsg: func(msg *nats.Msg) error {
+			calls = append(calls, msg.Subject+" "+string(msg.Data))
+			return nil
+		},
+	}
+	events := []FactoEvent{
+		{FactoID: "ft-1", SessionID: "session", Proof: Proof{EventHash: "aa"}},
+		{FactoID: "ft-2", SessionID: "session", Proof: Proof{EventHash: "bb"}},
+	}
+	data := [][]byte{[]byte(`{"facto_id":"ft-1"}`), []byte(`{"facto_id":"ft-2"}`)}
+	span := trace.SpanFromContext(ctx)
+
+	// A failed store publishes nothing; the batch is redelivered instead
+	if _, _, err := c.commitBatch(ctx, span, events, data); err == nil {
+		t.Fatal("expected the store error")
+	}
+	if len(calls) != 0 {
+		t.Fatalf("published before the batch was stored: %v", calls)
+	}
+
+	store.storeErr = nil
+	if _, _, err := c.commitBatch(ctx, span, events, data); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"store", "root root-aabb", `facto.committed {"facto_id":"ft-1"}`, `facto.committed {"facto_id":"ft-2"}`}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+
+	// Notification mode publishes the compact committed notification
+	calls = nil
+	c.outputMode = "notification"
+	if _, _, err := c.commitBatch(ctx, span, events[:1], data[:1]); err != nil {
+		t.Fatal(err)
+	}
+	wantNotification := `facto.committed {"facto_id":"ft-1","agent_id":"","session_id":"session","event_hash":"aa","merkle_root":"root-aa"}`
+	if len(calls) != 3 || calls[2] != wantNotification {
+		t.Errorf("got calls %q", calls)
+	}
+}
+
+func TestHandleNATSErrorCountsAndPauses(t *testing.T) {
+	c := &Consumer{slowPause: time.Minute}
+	slow := testutil.ToFloat64(natsErrorsTotal.WithLabelValues("slow_consumer"))
+	other := testutil.ToFloat64(natsErrorsTotal.WithLabelValues("other"))
+
+	c.handleNATSError(nil, nil, errors.New("permissions violation"))
+	if got := testutil.ToFloat64(natsErrorsTotal.WithLabelValues("other")) - other; got != 1 {
+		t.Errorf("other errors counted %v times, want 1", got)
+	}
+	if c.pausedUntil.Load() != 0 {
+		t.Error("fetching paused for an error other than a slow consumer")
+	}
+
+	c.handleNATSError(nil, &nats.Subscription{Subject: "facto.events.>"}, nats.ErrSlowConsumer)
+	if got := testutil.ToFloat64(natsErrorsTotal.WithLabelValues("slow_consumer")) - slow; got != 1 {
+		t.Errorf("slow consumer errors counted %v times, want 1", got)
+	}
+	if until := time.Unix(0, c.pausedUntil.Load()); time.Until(until) < 50*time.Second {
+		t.Errorf("fetching paused until %v", until)
+	}
+}
+
+func TestRootsOnlyStoresRootsWithoutEvents(t *testing.T) {
+	var calls []string
+	w := &batchWorker{Consumer: &Consumer{
+		storage:   &memBatchStore{calls: &calls},
+		anchorer:  NewRootAnchorer(noopAnchor{}, nil, 0),
+		rootsOnly: true,
+		lag:       &LagMonitor{refresh: make(chan struct{}, 1)},
+	}}
+	msgs := []*fakeMsg{{data: []byte(`{}`)}, {data: []byte(`{}`)}}
+	for i, msg := range msgs {
+		w.events = append(w.events, FactoEvent{
+			FactoID:   fmt.Sprintf("ft-%d", i),
+			SessionID: "session",
+			Proof:     Proof{EventHash: fmt.Sprintf("%02d", i)},
+		})
+		w.messages = append(w.messages, msg)
+	}
+	processed := testutil.ToFloat64(eventsProcessed)
+
+	w.flush(context.Background())
+
+	if strings.Join(calls, ",") != "root root-0001" {
+		t.Errorf("got writes %v, want only the batch root", calls)
+	}
+	for i, msg := range msgs {
+		if msg.acked != "ack" {
+			t.Errorf("message %d: %q", i, msg.acked)
+		}
+	}
+	if got := testutil.ToFloat64(eventsProcessed) - processed; got != 2 {
+		t.Errorf("processed counted %v events, want 2", got)
+	}
+}
+
+// signedGoldenData returns the golden event JSON signed with signer and
+// presenting pub as its public key
+func signedGoldenData(t *testing.T, signer ed25519.PrivateKey, pub ed25519.PublicKey) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event FactoEvent
+	if err := canonical.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	form, _, err := canonicalForm(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(signer, []byte(form)))
+	signed := strings.Replace(string(data), `"signature": ""`, `"signature": "`+sig+`"`, 1)
+	return []byte(strings.Replace(signed, `"public_key": ""`, `"public_key": "`+base64.StdEncoding.EncodeToString(pub)+`"`, 1))
+}
+
+// newDeadLetterWorker returns a worker dead-lettering to facto.events.dlq,
+// with every dead-lettered message appended to dead
+func newDeadLetterWorker(dead *[]*nats.Msg) *batchWorker {
+	return &batchWorker{Consumer: &Consumer{
+		batchSize:  10,
+		deadLetter: "facto.events.dlq",
+		maxDeliver: 3,
+		publishMsg: func(msg *nats.Msg) error {
+			*dead = append(*dead, msg)
+			return nil
+		},
+	}}
+}
+
+func TestVerifyOnIngest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := signedGoldenData(t, priv, pub)
+
+	cases := []struct {
+		name   string
+		data   []byte
+		reason string
+	}{
+		{"valid", valid, ""},
+		{"bad signature", signedGoldenData(t, otherKey, pub), rejectBadSignature},
+		// Signed, but the content no longer matches event_hash
+		{"hash mismatch", []byte(strings.Replace(string(valid), `"status": "success"`, `"status": "error"`, 1)), rejectHashMismatch},
+	}
+	for _, tc := range cases {
+		var dead []*nats.Msg
+		w := newDeadLetterWorker(&dead)
+		w.verifyIngest = true
+		msg := &fakeMsg{data: tc.data}
+		var rejected float64
+		if tc.reason != "" {
+			rejected = testutil.ToFloat64(eventsRejectedTotal.WithLabelValues(tc.reason))
+		}
+
+		w.handleMessage(context.Background(), msg)
+
+		if tc.reason == "" {
+			if len(w.events) != 1 || len(dead) != 0 {
+				t.Errorf("%s: %d events buffered, %d dead-lettered", tc.name, len(w.events), len(dead))
+			}
+			continue
+		}
+		if len(w.events) != 0 || msg.acked != "term" {
+			t.Errorf("%s: %d events buffered, message %q", tc.name, len(w.events), msg.acked)
+		}
+		if len(dead) != 1 || dead[0].Subject != "facto.events.dlq" || dead[0].Header.Get("Facto-Reject-Reason") != tc.reason {
+			t.Errorf("%s: dead-lettered %v", tc.name, dead)
+		}
+		if got := testutil.ToFloat64(eventsRejectedTotal.WithLabelValues(tc.reason)) - rejected; got != 1 {
+			t.Errorf("%s: rejected counted %v times, want 1", tc.name, got)
+		}
+	}
+}
+
+func TestUnmarshalFailureDeadLettered(t *testing.T) {
+	var dead []*nats.Msg
+	w := newDeadLetterWorker(&dead)
+	deadLettered := testutil.ToFloat64(eventsDeadLettered.WithLabelValues(deadLetterUnmarshal))
+
+	// Redelivered until the max-redelivery threshold
+	msg := &fakeMsg{data: []byte(`{"facto_id": `), delivered: 2}
+	w.handleMessage(context.Background(), msg)
+	if msg.acked != "nak" || len(dead) != 0 {
+		t.Fatalf("delivery 2 of 3: message %q, %d dead-lettered", msg.acked, len(dead))
+	}
+
+	msg.delivered = 3
+	w.handleMessage(context.Background(), msg)
+	if msg.acked != "ack" {
+		t.Errorf("dead-lettered message settled with %q, want ack", msg.acked)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("%d messages dead-lettered", len(dead))
+	}
+	if d := dead[0]; d.Subject != "facto.events.dlq" || string(d.Data) != `{"facto_id": ` ||
+		d.Header.Get("Facto-Reject-Reason") != deadLetterUnmarshal || d.Header.Get("Facto-Error") == "" ||
+		d.Header.Get("Facto-Original-Subject") != "facto.events.agent-golden" {
+		t.Errorf("dead letter %q with headers %v", d.Data, d.Header)
+	}
+	if got := testutil.ToFloat64(eventsDeadLettered.WithLabelValues(deadLetterUnmarshal)) - deadLettered; got != 1 {
+		t.Errorf("dead-lettered counted %v times, want 1", got)
+	}
+
+	// If the dead-letter publish fails the message is kept for redelivery
+	w.publishMsg = func(*nats.Msg) error { return nats.ErrConnectionClosed }
+	msg = &fakeMsg{data: []byte(`not json`), delivered: 3}
+	w.handleMessage(context.Background(), msg)
+	if msg.acked != "nak" {
+		t.Errorf("message %q after a failed dead-letter publish, want nak", msg.acked)
+	}
+}
+
+// slowBatchStore is a batchStore whose batch writes take latency, as a
+// ScyllaDB round trip does. It counts what concurrent workers store.
+type slowBatchStore struct {
+	latency time.Duration
+
+	mu          sync.Mutex
+	stored      map[string]int // facto_id -> times stored
+	rootEvents  int            // events committed across batch roots
+	inFlight    int
+	maxInFlight int
+}
+
+func (m *slowBatchStore) GetSessionEventCount(ctx context.Context, sessionID string) (int64, error) {
+	return 0, nil
+}
+
+func (m *slowBatchStore) StoreBatch(ctx context.Context, events []FactoEvent) (int, []bool, error) {
+	m.mu.Lock()
+	m.inFlight++
+	m.maxInFlight = max(m.maxInFlight, m.inFlight)
+	m.mu.Unlock()
+
+	time.Sleep(m.latency)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	for _, event := range events {
+		m.stored[event.FactoID]++
+	}
+	return len(events), nil, nil
+}
+
+func (m *slowBatchStore) BuildMerkleRoot(hashes []string) string {
+	return "root-" + strings.Join(hashes, "")
+}
+
+func (m *slowBatchStore) StoreMerkleRoot(ctx context.Context, rootType string, bucketTime time.Time, rootHash string, eventCount int, firstFactoID, lastFactoID string, eventHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rootEvents += eventCount
+	return nil
+}
+
+func (m *slowBatchStore) LinkBatchRoot(ctx context.Context, bucketTime time.Time, rootHash string, state *RootChainState) (*RootChainState, string, error) {
+	return &RootChainState{}, "", nil
+}
+
+func (m *slowBatchStore) IncrementSessionEventCounts(ctx context.Context, counts map[string]int64) error {
+	return nil
+}
+
+func (m *slowBatchStore) UpdateAgentSummaries(ctx context.Context, events []FactoEvent) error {
+	return nil
+}
+
+// sessionMessages returns n copies of the golden event spread over sessions,
+// each with its own facto_id
+func sessionMessages(tb testing.TB, n, sessions int) []*fakeMsg {
+	tb.Helper()
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	msgs := make([]*fakeMsg, n)
+	for i := range msgs {
+		event := strings.Replace(string(data), `"facto_id": "ft-00000000-0000-4000-8000-000000000001"`,
+			fmt.Sprintf(`"facto_id": "ft-00000000-0000-4000-8000-%012d"`, i), 1)
+		event = strings.Replace(event, `"session-golden"`, fmt.Sprintf(`"session-%d"`, i%sessions), 1)
+		msgs[i] = &fakeMsg{data: []byte(event)}
+	}
+	return msgs
+}
+
+// consumeWithWorkers routes msgs to workers the way Start does, closes their
+// channels and returns how long the workers took to store everything
+func consumeWithWorkers(store batchStore, workers int, msgs []*fakeMsg) time.Duration {
+	c := &Consumer{
+		storage:     store,
+		batchSize:   10,
+		workerCount: workers,
+		pacer:       NewFlushPacer(time.Hour, time.Hour, time.Hour),
+		anchorer:    NewRootAnchorer(noopAnchor{}, nil, 0),
+		lag:         &LagMonitor{refresh: make(chan struct{}, 1)},
+	}
+	workerChans := make([]chan jetstream.Msg, workers)
+	for i := range workerChans {
+		workerChans[i] = make(chan jetstream.Msg, c.batchSize)
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		c.runWorkers(context.Background(), workerChans)
+		close(done)
+	}()
+	for _, msg := range msgs {
+		workerChans[c.workerFor(msg)] <- msg
+	}
+	for _, ch := range workerChans {
+		close(ch)
+	}
+	<-done
+	return time.Since(start)
+}
+
+func TestWorkerPoolThroughput(t *testing.T) {
+	const events = 400
+	elapsed := make(map[int]time.Duration)
+	for _, workers := range []int{1, 4} {
+		store := &slowBatchStore{latency: 5 * time.Millisecond, stored: make(map[string]int)}
+		msgs := sessionMessages(t, events, 40)
+		elapsed[workers] = consumeWithWorkers(store, workers, msgs)
+
+		// Every event is stored and committed exactly once, whichever
+		// worker's batch it landed in
+		for i, msg := range msgs {
+			if msg.acked != "ack" {
+				t.Fatalf("%d workers: message %d settled with %q", workers, i, msg.acked)
+			}
+		}
+		if len(store.stored) != events {
+			t.Errorf("%d workers: stored %d distinct events, want %d", workers, len(store.stored), events)
+		}
+		for factoID, n := range store.stored {
+			if n != 1 {
+				t.Errorf("%d workers: %s stored %d times", workers, factoID, n)
+			}
+		}
+		if store.rootEvents != events {
+			t.Errorf("%d workers: batch roots commit %d events, want %d", workers, store.rootEvents, events)
+		}
+		if workers > 1 && store.maxInFlight < 2 {
+			t.Errorf("%d workers never stored batches concurrently", workers)
+		}
+	}
+
+	// 40 batches of 5ms take at least 200ms on one worker
+	if elapsed[4] > elapsed[1]*3/4 {
+		t.Errorf("4 workers took %v, 1 worker %v", elapsed[4], elapsed[1])
+	}
+}
+
+func BenchmarkWorkerPool(b *testing.B) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	b.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	const events = 200
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			var elapsed time.Duration
+			for i := 0; i < b.N; i++ {
+				store := &slowBatchStore{latency: 2 * time.Millisecond, stored: make(map[string]int)}
+				msgs := sessionMessages(b, events, 40)
+				elapsed += consumeWithWorkers(store, workers, msgs)
+			}
+			b.ReportMetric(float64(events*b.N)/elapsed.Seconds(), "events/s")
+		})
+	}
+}
+
+func TestMalformedFactoIDDeadLettered(t *testing.T) {
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const golden = "ft-00000000-0000-4000-8000-000000000001"
+
+	for _, tc := range []struct {
+		factoID string
+		valid   bool
+	}{
+		{golden, true},
+		{factoid.New(), true},
+		{"ft-00000000-0000-4000-8000-00000000000Z", false},
+		{"00000000-0000-4000-8000-000000000001", false},
+		{"ft-1", false},
+		{"", false},
+	} {
+		var dead []*nats.Msg
+		w := newDeadLetterWorker(&dead)
+		msg := &fakeMsg{data: []byte(strings.Replace(string(data), golden, tc.factoID, 1))}
+		invalid := testutil.ToFloat64(invalidFactoIDTotal)
+		deadLettered := testutil.ToFloat64(eventsDeadLettered.WithLabelValues(deadLetterFactoID))
+
+		w.handleMessage(context.Background(), msg)
+
+		if tc.valid {
+			if len(w.events) != 1 || len(dead) != 0 || msg.acked != "" {
+				t.Errorf("%q: %d events buffered, %d dead-lettered, message %q", tc.factoID, len(w.events), len(dead), msg.acked)
+			}
+			continue
+		}
+		if len(w.events) != 0 || msg.acked != "term" {
+			t.Errorf("%q: %d events buffered, message %q", tc.factoID, len(w.events), msg.acked)
+		}
+		if len(dead) != 1 || dead[0].Header.Get("Facto-Reject-Reason") != deadLetterFactoID {
+			t.Errorf("%q: dead-lettered %v", tc.factoID, dead)
+		}
+		if got := testutil.ToFloat64(invalidFactoIDTotal) - invalid; got != 1 {
+			t.Errorf("%q: invalid facto_id counted %v times", tc.factoID, got)
+		}
+		if got := testutil.ToFloat64(eventsDeadLettered.WithLabelValues(deadLetterFactoID)) - deadLettered; got != 1 {
+			t.Errorf("%q: dead-lettered counted %v times", tc.factoID, got)
+		}
+	}
+}
+
+func TestStatusValidation(t *testing.T) {
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := []byte(strings.Replace(string(data), `"status": "success"`, `"status": "bogus"`, 1))
+
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		data     []byte
+		buffered bool
+		counted  bool
+	}{
+		{"known, warn", "warn", data, true, false},
+		{"known, reject", "reject", data, true, false},
+		{"unknown, warn", "warn", unknown, true, true},
+		{"unknown, reject", "reject", unknown, false, true},
+		{"unknown, off", "off", unknown, true, false},
+	} {
+		var dead []*nats.Msg
+		w := newDeadLetterWorker(&dead)
+		w.statuses = toSet([]string{"success", "error"})
+		w.statusMode = tc.mode
+		msg := &fakeMsg{data: tc.data}
+		before := testutil.ToFloat64(unknownStatusTotal.WithLabelValues(tc.mode))
+
+		w.handleMessage(context.Background(), msg)
+
+		name := tc.name
+		if tc.buffered {
+			if len(w.events) != 1 || len(dead) != 0 || msg.acked != "" {
+				t.Errorf("%s: %d events buffered, %d dead-lettered, message %q", name, len(w.events), len(dead), msg.acked)
+			}
+		} else {
+			if len(w.events) != 0 || msg.acked != "term" {
+				t.Errorf("%s: %d events buffered, message %q", name, len(w.events), msg.acked)
+			}
+			if len(dead) != 1 || dead[0].Header.Get("Facto-Reject-Reason") != deadLetterStatus {
+				t.Errorf("%s: dead-lettered %v", name, dead)
+			}
+		}
+		want := 0.0
+		if tc.counted {
+			want = 1
+		}
+		if got := testutil.ToFloat64(unknownStatusTotal.WithLabelValues(tc.mode)) - before; got != want {
+			t.Errorf("%s: unknown status counted %v times, want %v", name, got, want)
+		}
+	}
+}
+
+// withToolCalls returns the golden event JSON with n tool calls
+func withToolCalls(t *testing.T, n int) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]any
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	calls := make([]any, n)
+	for i := range calls {
+		calls[i] = map[string]any{"name": "calculator", "args": map[string]any{"expr": fmt.Sprint(i)}}
+	}
+	event["execution_meta"].(map[string]any)["tool_calls"] = calls
+	data, err = json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestMaxToolCallsDeadLettered(t *testing.T) {
+	for _, tc := range []struct {
+		calls    int
+		buffered bool
+	}{
+		{2, true},
+		{3, false},
+	} {
+		var dead []*nats.Msg
+		w := newDeadLetterWorker(&dead)
+		w.maxToolCalls = 2
+		msg := &fakeMsg{data: withToolCalls(t, tc.calls)}
+		exceeded := testutil.ToFloat64(toolCallsExceededTotal)
+		deadLettered := testutil.ToFloat64(eventsDeadLettered.WithLabelValues(deadLetterToolCalls))
+
+		w.handleMessage(context.Background(), msg)
+
+		want := 1.0
+		if tc.buffered {
+			if len(w.events) != 1 || len(dead) != 0 || msg.acked != "" {
+				t.Errorf("%d tool calls: %d events buffered, %d dead-lettered, message %q", tc.calls, len(w.events), len(dead), msg.acked)
+			}
+			want = 0
+		} else {
+			if len(w.events) != 0 || msg.acked != "term" {
+				t.Errorf("%d tool calls: %d events buffered, message %q", tc.calls, len(w.events), msg.acked)
+			}
+			if len(dead) != 1 || dead[0].Header.Get("Facto-Reject-Reason") != deadLetterToolCalls ||
+				dead[0].Header.Get("Facto-Error") != "3 tool calls exceed the limit of 2" {
+				t.Errorf("%d tool calls: dead-lettered %v", tc.calls, dead)
+			}
+		}
+		if got := testutil.ToFloat64(toolCallsExceededTotal) - exceeded; got != want {
+			t.Errorf("%d tool calls: exceeded counted %v times, want %v", tc.calls, got, want)
+		}
+		if got := testutil.ToFloat64(eventsDeadLettered.WithLabelValues(deadLetterToolCalls)) - deadLettered; got != want {
+			t.Errorf("%d tool calls: dead-lettered counted %v times, want %v", tc.calls, got, want)
+		}
+	}
+}
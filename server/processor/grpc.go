@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/factopb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// GRPCIngester serves the facto.v1.Ingest service on GRPC_PORT. Each
+// IngestEvents stream is committed as one batch through the bulk ingester, so
+// it gets the same checks, limits and per-event results as POST /v1/events,
+// and the same caveat: a session must not be fed over gRPC and NATS at the
+// same time.
+type GRPCIngester struct {
+	factopb.UnimplementedIngestServer
+	bulk *BulkIngester
+}
+
+// NewGRPCIngester creates the Ingest service committing through bulk
+func NewGRPCIngester(bulk *BulkIngester) *GRPCIngester {
+	return &GRPCIngester{bulk: bulk}
+}
+
+// IngestEvents reads events until the client closes the stream, then
+// commits them. Only the event count is limited (BULK_INGEST_MAX_EVENTS);
+// gRPC caps each message's size.
+func (g *GRPCIngester) IngestEvents(stream factopb.Ingest_IngestEventsServer) error {
+	ctx, span := tracer.Start(stream.Context(), "GRPCIngester.IngestEvents", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	var resp BulkIngestResponse
+	var events []FactoEvent
+	var data [][]byte
+	var indexes []int // position in the stream of each entry of events
+	for i := 0; ; i++ {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if i >= g.bulk.maxEvents {
+			return status.Errorf(codes.ResourceExhausted, "stream contains more than %d events", g.bulk.maxEvents)
+		}
+
+		resp.Results = append(resp.Results, BulkIngestResult{Index: i, FactoID: msg.GetFactoId()})
+		result := &resp.Results[i]
+
+		if !exactNumbers(msg) {
+			result.Status, result.Reason = bulkRejected, rejectInexactNumber
+			continue
+		}
+		event := eventFromProto(msg)
+		// The JSON form is what gets republished, as for NATS messages
+		item, err := json.Marshal(event)
+		if err != nil {
+			result.Status, result.Reason = bulkRejected, rejectMalformed
+			continue
+		}
+		if reason := g.bulk.consumer.rejectReason(&event); reason != "" {
+			result.Status, result.Reason = bulkRejected, reason
+			continue
+		}
+		events = append(events, event)
+		data = append(data, item)
+		indexes = append(indexes, i)
+	}
+	if len(resp.Results) == 0 {
+		return status.Error(codes.InvalidArgument, "stream must contain at least one event")
+	}
+	span.SetAttributes(attribute.Int("facto.batch_size", len(resp.Results)))
+
+	if err := g.bulk.ingest(ctx, span, &resp, events, data, indexes); err != nil {
+		// As over HTTP, the client retries the whole stream
+		return status.Error(codes.Unavailable, "failed to store events")
+	}
+	return stream.SendAndClose(ingestResponseToProto(&resp))
+}
+
+// rejectInexactNumber is the bulk ingest reason for a gRPC event whose
+// input_data, output_data or tool_calls hold an integer beyond 2^53
+const rejectInexactNumber = "inexact_number"
+
+// maxExactInteger is the largest magnitude below which a float64 holds every
+// integer
+const maxExactInteger = 1 << 53
+
+// exactNumbers reports whether every number in msg's input_data, output_data
+// and tool_calls is exact. Struct and ListValue numbers are doubles, so an
+// integer beyond 2^53 may already have been rounded by the client; the JSON
+// form it was signed over kept every digit, so its hash would fail to verify.
+func exactNumbers(msg *factopb.Event) bool {
+	values := []*structpb.Value{
+		structpb.NewStructValue(msg.GetInputData()),
+		structpb.NewStructValue(msg.GetOutputData()),
+	}
+	if toolCalls := msg.GetExecutionMeta().GetToolCalls(); toolCalls != nil {
+		values = append(values, structpb.NewListValue(toolCalls))
+	}
+	for _, v := range values {
+		if !exactValue(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func exactValue(v *structpb.Value) bool {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		n := kind.NumberValue
+		return n != math.Trunc(n) || math.Abs(n) <= maxExactInteger
+	case *structpb.Value_StructValue:
+		for _, field := range kind.StructValue.GetFields() {
+			if !exactValue(field) {
+				return false
+			}
+		}
+	case *structpb.Value_ListValue:
+		for _, item := range kind.ListValue.GetValues() {
+			if !exactValue(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// eventFromProto converts an Event message to the FactoEvent its JSON form
+// decodes to. Struct and ListValue numbers become float64; exactNumbers must
+// hold, or integers beyond 2^53 differ from the JSON form's.
+func eventFromProto(msg *factopb.Event) FactoEvent {
+	event := FactoEvent{
+		FactoID:       msg.GetFactoId(),
+		AgentID:       msg.GetAgentId(),
+		SessionID:     msg.GetSessionId(),
+		ParentFactoID: msg.ParentFactoId,
+		ActionType:    msg.GetActionType(),
+		Status:        msg.GetStatus(),
+		InputData:     structMap(msg.GetInputData()),
+		OutputData:    structMap(msg.GetOutputData()),
+		StartedAt:     msg.GetStartedAt(),
+		CompletedAt:   msg.GetCompletedAt(),
+	}
+	if meta := msg.GetExecutionMeta(); meta != nil {
+		event.ExecutionMeta = ExecutionMeta{
+			ModelID:     meta.ModelId,
+			ModelHash:   meta.ModelHash,
+			Temperature: meta.Temperature,
+			Seed:        meta.Seed,
+			MaxTokens:   meta.MaxTokens,
+			SDKVersion:  meta.GetSdkVersion(),
+			SDKLanguage: meta.GetSdkLanguage(),
+			Tags:        meta.GetTags(),
+		}
+		if toolCalls := meta.GetToolCalls(); toolCalls != nil {
+			event.ExecutionMeta.ToolCalls = toolCalls.AsSlice()
+		}
+	}
+	if proof := msg.GetProof(); proof != nil {
+		event.Proof = Proof{
+			Signature: proof.GetSignature(),
+			PublicKey: proof.GetPublicKey(),
+			SigAlgo:   proof.GetSigAlgo(),
+			PrevHash:  proof.GetPrevHash(),
+			EventHash: proof.GetEventHash(),
+
+			CanonicalVersion: canonical.Version(proof.GetCanonicalVersion()),
+		}
+	}
+	return event
+}
+
+// structMap returns s as a map, keeping an unset Struct nil like a JSON null
+func structMap(s *structpb.Struct) map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	return s.AsMap()
+}
+
+// ingestStatuses maps bulk ingest result statuses to their proto values
+var ingestStatuses = map[string]factopb.IngestResult_Status{
+	bulkAccepted:  factopb.IngestResult_STATUS_ACCEPTED,
+	bulkRejected:  factopb.IngestResult_STATUS_REJECTED,
+	bulkDuplicate: factopb.IngestResult_STATUS_DUPLICATE,
+}
+
+func ingestResponseToProto(resp *BulkIngestResponse) *factopb.IngestEventsResponse {
+	out := &factopb.IngestEventsResponse{
+		MerkleRoot: resp.MerkleRoot,
+		Accepted:   int32(resp.Accepted),
+		Rejected:   int32(resp.Rejected),
+		Duplicates: int32(resp.Duplicates),
+		Results:    make([]*factopb.IngestResult, len(resp.Results)),
+	}
+	for i, result := range resp.Results {
+		out.Results[i] = &factopb.IngestResult{
+			Index:   int32(result.Index),
+			FactoId: result.FactoID,
+			Status:  ingestStatuses[result.Status],
+			Reason:  result.Reason,
+		}
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net"
+	"testing"
+
+	"github.com/facto-ai/facto/server/shared/factopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ingestClient serves the Ingest service over an in-process bufconn
+// listener, committing through c, and returns a client for it
+func ingestClient(t *testing.T, c *Consumer) factopb.IngestClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	factopb.RegisterIngestServer(server, NewGRPCIngester(NewBulkIngester(c, 1<<20, 10)))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return factopb.NewIngestClient(conn)
+}
+
+func TestGRPCIngestEvents(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden factopb.Event
+	if err := protojson.Unmarshal(signedGoldenData(t, priv, pub), &golden); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	client := ingestClient(t, &Consumer{
+		storage:    &memBatchStore{calls: &calls},
+		anchorer:   NewRootAnchorer(noopAnchor{}, nil, 0),
+		statusMode: "off",
+	})
+
+	// The golden event verifies; changing its status breaks its hash
+	tampered := proto.Clone(&golden).(*factopb.Event)
+	tampered.Status = "error"
+	malformed := proto.Clone(&golden).(*factopb.Event)
+	malformed.FactoId = "ft-1"
+	// An integer a double can't hold exactly is refused outright
+	inexact := proto.Clone(&golden).(*factopb.Event)
+	inexact.InputData = &structpb.Struct{Fields: map[string]*structpb.Value{
+		"ids": structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(1 << 60)}}),
+	}}
+
+	ctx := context.Background()
+	stream, err := client.IngestEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []*factopb.Event{&golden, tampered, malformed, inexact} {
+		if err := stream.Send(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Accepted != 1 || resp.Rejected != 3 || resp.Duplicates != 0 {
+		t.Errorf("accepted %d, rejected %d, duplicates %d", resp.Accepted, resp.Rejected, resp.Duplicates)
+	}
+	if want := "root-" + golden.Proof.EventHash; resp.MerkleRoot != want {
+		t.Errorf("merkle_root %q, want %q", resp.MerkleRoot, want)
+	}
+	want := []struct {
+		status factopb.IngestResult_Status
+		reason string
+	}{
+		{factopb.IngestResult_STATUS_ACCEPTED, ""},
+		{factopb.IngestResult_STATUS_REJECTED, rejectHashMismatch},
+		{factopb.IngestResult_STATUS_REJECTED, rejectInvalidFactoID},
+		{factopb.IngestResult_STATUS_REJECTED, rejectInexactNumber},
+	}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("got %d results", len(resp.Results))
+	}
+	for i, result := range resp.Results {
+		if int(result.Index) != i || result.Status != want[i].status || result.Reason != want[i].reason {
+			t.Errorf("result %d: %v", i, result)
+		}
+	}
+	if len(calls) == 0 || calls[0] != "store" {
+		t.Errorf("store calls %q", calls)
+	}
+
+	// An empty stream is refused
+	empty, err := client.IngestEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := empty.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("empty stream: %v", err)
+	}
+}
+
+func TestExactNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		n    float64
+		want bool
+	}{
+		{0, true},
+		{-3, true},
+		{1.5, true},
+		{1 << 53, true},
+		{-(1 << 53), true},
+		{1<<53 + 2, false},
+		{-(1 << 60), false},
+		{1e300, false},
+	} {
+		nested := structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{"n": structpb.NewNumberValue(tc.n)}})
+		for name, msg := range map[string]*factopb.Event{
+			"input_data":  {InputData: &structpb.Struct{Fields: map[string]*structpb.Value{"a": nested}}},
+			"output_data": {OutputData: &structpb.Struct{Fields: map[string]*structpb.Value{"n": structpb.NewNumberValue(tc.n)}}},
+			"tool_calls":  {ExecutionMeta: &factopb.ExecutionMeta{ToolCalls: &structpb.ListValue{Values: []*structpb.Value{nested}}}},
+		} {
+			if got := exactNumbers(msg); got != tc.want {
+				t.Errorf("%v in %s: exact %v, want %v", tc.n, name, got, tc.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/canonical"
+	"github.com/facto-ai/facto/server/shared/factoid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var bulkIngestedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "facto_processor_bulk_ingested_total",
+	Help: "Total number of events submitted to POST /v1/events by result",
+}, []string{"result"})
+
+// Reasons a bulk-ingested event is rejected, besides the verification
+// reasons (rejectHashMismatch, rejectBadSignature)
+const (
+	rejectMalformed      = "malformed"
+	rejectInvalidFactoID = "invalid_facto_id"
+	rejectToolCalls      = "too_many_tool_calls"
+	rejectUnknownStatus  = "unknown_status"
+	rejectSessionLimit   = "session_limit"
+)
+
+// Per-event results of a bulk ingest request
+const (
+	bulkAccepted  = "accepted"
+	bulkRejected  = "rejected"
+	bulkDuplicate = "duplicate"
+)
+
+// BulkIngestResult is the outcome for one submitted event
+type BulkIngestResult struct {
+	Index   int    `json:"index"`
+	FactoID string `json:"facto_id,omitempty"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// BulkIngestResponse is the body of a POST /v1/events response. MerkleRoot is
+// the batch root over the accepted events.
+type BulkIngestResponse struct {
+	MerkleRoot string             `json:"merkle_root,omitempty"`
+	Accepted   int                `json:"accepted"`
+	Rejected   int                `json:"rejected"`
+	Duplicates int                `json:"duplicates"`
+	Results    []BulkIngestResult `json:"results"`
+}
+
+// BulkIngester serves POST /v1/events for clients that push events over HTTP
+// instead of NATS, such as batch importers. A request is one JSON array of
+// events, committed as one batch through the same path as NATS batches.
+// Every signature is verified whatever VERIFY_ON_INGEST says, since nothing
+// upstream has checked these events. Rejected events are reported and
+// skipped; the rest of the array is still stored.
+//
+// Bulk-ingested sessions are not routed through the workers, so a session
+// must not be fed over HTTP and NATS at the same time.
+type BulkIngester struct {
+	consumer  *Consumer
+	maxBytes  int64
+	maxEvents int
+}
+
+// NewBulkIngester creates a bulk ingester committing through consumer
+func NewBulkIngester(consumer *Consumer, maxBytes int64, maxEvents int) *BulkIngester {
+	return &BulkIngester{consumer: consumer, maxBytes: maxBytes, maxEvents: maxEvents}
+}
+
+func (b *BulkIngester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "BulkIngester.ServeHTTP", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	var raw []json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, b.maxBytes)).Decode(&raw); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "request body exceeds "+strconv.FormatInt(b.maxBytes, 10)+" bytes")
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "request body must be a JSON array of events")
+		return
+	}
+	if len(raw) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "request body must contain at least one event")
+		return
+	}
+	if len(raw) > b.maxEvents {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "request contains more than "+strconv.Itoa(b.maxEvents)+" events")
+		return
+	}
+	span.SetAttributes(attribute.Int("facto.batch_size", len(raw)))
+
+	resp := BulkIngestResponse{Results: make([]BulkIngestResult, len(raw))}
+	var events []FactoEvent
+	var data [][]byte
+	var indexes []int // position in raw of each entry of events
+	for i, item := range raw {
+		result := &resp.Results[i]
+		result.Index = i
+
+		var event FactoEvent
+		if err := canonical.Unmarshal(item, &event); err != nil {
+			result.Status, result.Reason = bulkRejected, rejectMalformed
+			continue
+		}
+		result.FactoID = event.FactoID
+		if reason := b.consumer.rejectReason(&event); reason != "" {
+			result.Status, result.Reason = bulkRejected, reason
+			continue
+		}
+		events = append(events, event)
+		data = append(data, item)
+		indexes = append(indexes, i)
+	}
+
+	if err := b.ingest(ctx, span, &resp, events, data, indexes); err != nil {
+		// Nothing is reported as accepted unless it is durably stored;
+		// the client retries the whole request
+		writeJSONError(w, http.StatusServiceUnavailable, "failed to store events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ingest commits the submitted events that passed rejectReason and fills in
+// resp. resp.Results already holds one entry per submitted event, rejected
+// ones included; indexes maps each of events to its entry. On error nothing
+// was durably stored and the whole submission must be retried.
+func (b *BulkIngester) ingest(ctx context.Context, span trace.Span, resp *BulkIngestResponse, events []FactoEvent, data [][]byte, indexes []int) error {
+	start := time.Now()
+
+	if b.consumer.maxPerSession > 0 && len(events) > 0 {
+		exceeded := b.consumer.sessionLimitExceeded(ctx, events)
+		keptEvents, keptData, keptIndexes := events[:0], data[:0], indexes[:0]
+		for j := range events {
+			if exceeded[j] {
+				resp.Results[indexes[j]].Status = bulkRejected
+				resp.Results[indexes[j]].Reason = rejectSessionLimit
+				continue
+			}
+			keptEvents = append(keptEvents, events[j])
+			keptData = append(keptData, data[j])
+			keptIndexes = append(keptIndexes, indexes[j])
+		}
+		events, data, indexes = keptEvents, keptData, keptIndexes
+	}
+
+	if len(events) > 0 {
+		merkleRoot, duplicate, err := b.consumer.commitBatch(ctx, span, events, data)
+		if err != nil {
+			eventsFailedTotal.Add(float64(len(events)))
+			return err
+		}
+		stored := 0
+		for j, i := range indexes {
+			if duplicate != nil && duplicate[j] {
+				resp.Results[i].Status = bulkDuplicate
+				eventsDuplicateTotal.Inc()
+				continue
+			}
+			resp.Results[i].Status = bulkAccepted
+			stored++
+		}
+		if stored > 0 {
+			resp.MerkleRoot = merkleRoot
+			eventsProcessed.Add(float64(stored))
+			batchesProcessed.Inc()
+			batchSize.Observe(float64(stored))
+		}
+	}
+
+	for _, result := range resp.Results {
+		switch result.Status {
+		case bulkAccepted:
+			resp.Accepted++
+		case bulkDuplicate:
+			resp.Duplicates++
+		default:
+			resp.Rejected++
+		}
+		bulkIngestedTotal.WithLabelValues(result.Status).Inc()
+	}
+
+	log.Info().
+		Int("accepted", resp.Accepted).
+		Int("rejected", resp.Rejected).
+		Int("duplicates", resp.Duplicates).
+		Str("merkle_root", resp.MerkleRoot).
+		Dur("duration", time.Since(start)).
+		Msg("Bulk ingest processed")
+
+	return nil
+}
+
+// rejectReason applies the checks handleMessage applies to NATS messages,
+// always including signature verification, and returns why event must be
+// rejected or "" to accept it
+func (c *Consumer) rejectReason(event *FactoEvent) string {
+	if !factoid.Valid(event.FactoID) {
+		invalidFactoIDTotal.Inc()
+		return rejectInvalidFactoID
+	}
+	if c.maxToolCalls > 0 && len(event.ExecutionMeta.ToolCalls) > c.maxToolCalls {
+		toolCallsExceededTotal.Inc()
+		return rejectToolCalls
+	}
+	if reason := verifyEvent(event); reason != "" {
+		return reason
+	}
+	if !c.checkStatus(event) {
+		return rejectUnknownStatus
+	}
+	return ""
+}
+
+// writeJSONError writes {"error": msg} with the given status
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
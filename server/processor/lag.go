@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	consumerPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "facto_processor_consumer_pending",
+		Help: "Messages in the stream not yet delivered to the durable consumer",
+	})
+
+	consumerAckPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "facto_processor_consumer_ack_pending",
+		Help: "Messages delivered to the durable consumer but not yet acknowledged",
+	})
+
+	consumerRedelivered = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "facto_processor_consumer_redelivered",
+		Help: "Messages delivered more than once and not yet acknowledged",
+	})
+
+	consumerInfoErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "facto_processor_consumer_info_errors_total",
+		Help: "Total number of failed JetStream consumer info requests",
+	})
+)
+
+// consumerInfoSource reads the durable consumer's state; jetstream.Consumer
+// implements it
+type consumerInfoSource interface {
+	Info(ctx context.Context) (*jetstream.ConsumerInfo, error)
+}
+
+// LagMonitor exports how far the processor is behind the stream. The
+// consumer info is read on every interval tick and after every flush; a
+// failed read is counted and leaves the gauges at their last values.
+type LagMonitor struct {
+	source   consumerInfoSource
+	interval time.Duration
+	observe  func(*jetstream.ConsumerInfo) // called with every successful read; may be nil
+	refresh  chan struct{}
+}
+
+// NewLagMonitor creates a lag monitor reading source every interval
+func NewLagMonitor(source consumerInfoSource, interval time.Duration, observe func(*jetstream.ConsumerInfo)) *LagMonitor {
+	return &LagMonitor{
+		source:   source,
+		interval: interval,
+		observe:  observe,
+		refresh:  make(chan struct{}, 1),
+	}
+}
+
+// Run updates the gauges until the context is cancelled
+func (m *LagMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		if err := m.update(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			consumerInfoErrors.Inc()
+			// Log once per outage; NATS disconnects are logged separately
+			if !failing {
+				log.Warn().Err(err).Msg("Failed to read consumer info")
+			}
+			failing = true
+		} else {
+			failing = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.refresh:
+		}
+	}
+}
+
+// Refresh asks Run to read the consumer info now. It never blocks, so
+// flushes don't wait on NATS; refreshes requested while one is pending are
+// merged.
+func (m *LagMonitor) Refresh() {
+	select {
+	case m.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *LagMonitor) update(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.interval)
+	defer cancel()
+
+	info, err := m.source.Info(ctx)
+	if err != nil {
+		return err
+	}
+	consumerPending.Set(float64(info.NumPending))
+	consumerAckPending.Set(float64(info.NumAckPending))
+	consumerRedelivered.Set(float64(info.NumRedelivered))
+	if m.observe != nil {
+		m.observe(info)
+	}
+	return nil
+}
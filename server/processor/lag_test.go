@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeInfoSource returns infos in order, then err once they run out
+type fakeInfoSource struct {
+	mu    sync.Mutex
+	infos []*jetstream.ConsumerInfo
+	err   error
+	calls int
+}
+
+func (s *fakeInfoSource) Info(ctx context.Context) (*jetstream.ConsumerInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.infos) == 0 {
+		return nil, s.err
+	}
+	info := s.infos[0]
+	s.infos = s.infos[1:]
+	return info, nil
+}
+
+func (s *fakeInfoSource) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func lagGauges() [3]float64 {
+	return [3]float64{
+		testutil.ToFloat64(consumerPending),
+		testutil.ToFloat64(consumerAckPending),
+		testutil.ToFloat64(consumerRedelivered),
+	}
+}
+
+func TestLagMonitorSetsGauges(t *testing.T) {
+	source := &fakeInfoSource{infos: []*jetstream.ConsumerInfo{
+		{NumPending: 120, NumAckPending: 7, NumRedelivered: 2},
+		{NumPending: 0, NumAckPending: 1, NumRedelivered: 0},
+	}}
+	var observed []*jetstream.ConsumerInfo
+	m := NewLagMonitor(source, time.Second, func(info *jetstream.ConsumerInfo) {
+		observed = append(observed, info)
+	})
+
+	if err := m.update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lagGauges(), [3]float64{120, 7, 2}; got != want {
+		t.Errorf("pending, ack_pending, redelivered = %v, want %v", got, want)
+	}
+
+	// The gauges follow the source down as well as up
+	if err := m.update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lagGauges(), [3]float64{0, 1, 0}; got != want {
+		t.Errorf("pending, ack_pending, redelivered = %v, want %v", got, want)
+	}
+	if len(observed) != 2 || observed[0].NumPending != 120 {
+		t.Errorf("observe saw %d reads, want both", len(observed))
+	}
+}
+
+// A failed read is counted and leaves the gauges at their last values
+func TestLagMonitorSourceError(t *testing.T) {
+	source := &fakeInfoSource{
+		infos: []*jetstream.ConsumerInfo{{NumPending: 40, NumAckPending: 3, NumRedelivered: 1}},
+		err:   errors.New("nats: timeout"),
+	}
+	m := NewLagMonitor(source, time.Hour, nil)
+	errorsBefore := testutil.ToFloat64(consumerInfoErrors)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The first read succeeds; each Refresh then fails against the source
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor("the first read", func() bool { return source.Calls() >= 1 })
+	for failed := 1.0; failed <= 2; failed++ {
+		m.Refresh()
+		waitFor("a failed read to be counted", func() bool {
+			return testutil.ToFloat64(consumerInfoErrors)-errorsBefore >= failed
+		})
+	}
+	cancel()
+	<-done
+
+	if got := testutil.ToFloat64(consumerInfoErrors) - errorsBefore; got != 2 {
+		t.Errorf("consumer info errors = %v, want 2", got)
+	}
+	if got, want := lagGauges(), [3]float64{40, 3, 1}; got != want {
+		t.Errorf("pending, ack_pending, redelivered = %v after errors, want %v kept", got, want)
+	}
+}
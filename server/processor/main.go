@@ -2,27 +2,187 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/facto-ai/facto/server/shared/cqlconn"
+	"github.com/facto-ai/facto/server/shared/factopb"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/facto-ai/facto/server/shared/tracing"
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 )
 
 // Config holds the processor configuration
 type Config struct {
-	NatsURL       string
-	ScyllaHosts   []string
-	BatchSize     int
-	FlushInterval time.Duration
-	MetricsPort   int
+	NatsURL           string
+	ScyllaHosts       []string
+	ScyllaConns       int // connections per Scylla host
+	ScyllaMaxRequests int // requests in flight per connection (0 = driver limit)
+	BatchSize         int
+	FlushInterval     time.Duration
+	MetricsPort       int
+	HeaderTags        []string
+
+	// FlushMode is FlushFixed or FlushAdaptive. Adaptive flushing varies the
+	// interval between FlushIntervalMin and FlushIntervalMax, starting at
+	// FlushInterval (see FlushPacer).
+	FlushMode        string
+	FlushIntervalMin time.Duration
+	FlushIntervalMax time.Duration
+
+	// WorkerCount is how many workers drain fetched messages, each
+	// buffering and flushing its own batches. Messages are routed by
+	// session_id, so one session's events always go to the same worker.
+	WorkerCount int
+
+	// ScyllaKeyspace holds the Facto tables; writes use WriteConsistency and
+	// the processor's reads ReadConsistency
+	ScyllaKeyspace   string
+	ReadConsistency  gocql.Consistency
+	WriteConsistency gocql.Consistency
+
+	// AutoMigrate creates the keyspace (with ReplicationFactor replicas) and
+	// tables from the embedded schema on startup
+	AutoMigrate       bool
+	ReplicationFactor int
+
+	// PrefetchWarmupWait is the fetch max-wait used until the first batch
+	// fills (0 = always use FlushInterval)
+	PrefetchWarmupWait time.Duration
+
+	// Known event statuses and what to do with events using any other status
+	AllowedStatuses  []string
+	StatusValidation string // "off", "warn" or "reject"
+
+	// CommitInterval enables time-aligned interval roots when non-zero
+	CommitInterval time.Duration
+
+	// CompactionInterval enables compacting completed days' batch roots into
+	// one daily root when non-zero; CompactionLookbackDays is how many
+	// completed days each run covers and PruneBatchRoots deletes the batch
+	// roots once compacted
+	CompactionInterval     time.Duration
+	CompactionLookbackDays int
+	PruneBatchRoots        bool
+
+	// StoragePingInterval is how often facto_storage_up is refreshed
+	StoragePingInterval time.Duration
+
+	// ConsumerLagInterval is how often the consumer lag gauges are refreshed
+	// between flushes
+	ConsumerLagInterval time.Duration
+
+	// ReadyTimeout bounds the storage check of the /ready endpoint
+	ReadyTimeout time.Duration
+
+	// SlowConsumerPause is how long fetching pauses after a NATS slow
+	// consumer error (0 = never pause)
+	SlowConsumerPause time.Duration
+
+	// AtomicWrites writes the three event tables in logged batches so a
+	// failure can't leave them inconsistent, at a throughput cost
+	AtomicWrites bool
+
+	// StoreCanonical snapshots each event's canonical form at ingest so the
+	// Query API can detect canonicalization drift
+	StoreCanonical bool
+
+	// Dedup skips events whose facto_id is already stored: "off", "select"
+	// (best-effort lookup) or "lwt" (exact, via lightweight transactions)
+	Dedup string
+
+	// StoreStreamSeq records the JetStream stream sequence of each event so a
+	// stored event can be traced back to its stream position for replay
+	StoreStreamSeq bool
+
+	// EventTTL and MerkleRootTTL expire stored event rows and Merkle roots
+	// after a retention window, in seconds (0 = no expiry)
+	EventTTL      int
+	MerkleRootTTL int
+
+	// SessionCacheSize is how many session IDs are remembered as having a
+	// session_metadata row (0 = insert for every batch)
+	SessionCacheSize int
+
+	// MerkleScheme is the tree scheme Merkle roots are built with; each root
+	// records its scheme, so it can change without breaking stored roots
+	MerkleScheme merkle.Scheme
+
+	// RootsOnly skips storing event rows and only builds and stores Merkle
+	// roots, for deployments that keep event bodies elsewhere
+	RootsOnly bool
+
+	// VerifyOnIngest recomputes each event's hash and checks its signature
+	// before buffering; failing events go to DeadLetterSubject, not storage
+	VerifyOnIngest bool
+
+	// DeadLetterSubject receives messages that are never stored: events
+	// failing verification, rejected for their status, over MaxToolCalls or
+	// over MaxEventsPerSession, and messages that don't unmarshal once they
+	// have been delivered DeadLetterMaxDeliveries times
+	DeadLetterSubject       string
+	DeadLetterMaxDeliveries int
+
+	// MaxEventsPerSession rejects events beyond this count per session (0 = unlimited)
+	MaxEventsPerSession int64
+
+	// MaxToolCalls terminates events with more tool_calls than this (0 = unlimited)
+	MaxToolCalls int
+
+	// CommitWebhookURL receives a summary of every durably stored batch
+	CommitWebhookURL     string
+	CommitWebhookRetries int
+
+	// WebhookSecret keys the HMAC-SHA256 signature on webhook deliveries
+	// (empty = unsigned)
+	WebhookSecret string
+
+	// AnchorURL is a transparency log endpoint each batch Merkle root is
+	// posted to; the receipt it returns is stored with the root
+	AnchorURL     string
+	AnchorRetries int
+
+	// TSAURL is an RFC 3161 Time Stamping Authority each batch Merkle root is
+	// timestamped by; the token is stored with the root
+	TSAURL     string
+	TSARetries int
+
+	// OutputSubject, when set, receives each event after it is stored, either
+	// as the original message ("event") or a compact "notification"
+	OutputSubject string
+	OutputMode    string
+
+	// SearchIndexURL, when set, receives each stored event's searchable text
+	// in the SearchIndexName Elasticsearch/OpenSearch index
+	SearchIndexURL  string
+	SearchIndexName string
+
+	// BulkIngest serves POST /v1/events on the metrics port, accepting JSON
+	// arrays of up to BulkIngestMaxEvents events in BulkIngestMaxBytes
+	BulkIngest          bool
+	BulkIngestMaxBytes  int64
+	BulkIngestMaxEvents int
+
+	// GRPCPort, when non-zero, serves the facto.v1.Ingest gRPC service there,
+	// with the bulk ingest event limit
+	GRPCPort int
 }
 
+// defaultAllowedStatuses must match the Query API's default
+const defaultAllowedStatuses = "success,error,failed,pending,started,completed"
+
 func loadConfig() *Config {
 	natsURL := os.Getenv("NATS_URL")
 	if natsURL == "" {
@@ -41,6 +201,13 @@ func loadConfig() *Config {
 		}
 	}
 
+	workerCount := 1
+	if wc := os.Getenv("WORKER_COUNT"); wc != "" {
+		if parsed, err := strconv.Atoi(wc); err == nil && parsed > 0 {
+			workerCount = parsed
+		}
+	}
+
 	flushIntervalMs := 1000
 	if fi := os.Getenv("FLUSH_INTERVAL_MS"); fi != "" {
 		if parsed, err := strconv.Atoi(fi); err == nil {
@@ -48,6 +215,37 @@ func loadConfig() *Config {
 		}
 	}
 
+	flushMode := os.Getenv("FLUSH_MODE")
+	switch flushMode {
+	case FlushFixed, FlushAdaptive:
+	default:
+		flushMode = FlushFixed
+	}
+
+	flushIntervalMinMs := 100
+	if fm := os.Getenv("FLUSH_INTERVAL_MIN_MS"); fm != "" {
+		if parsed, err := strconv.Atoi(fm); err == nil && parsed > 0 {
+			flushIntervalMinMs = parsed
+		}
+	}
+
+	flushIntervalMaxMs := 5000
+	if fm := os.Getenv("FLUSH_INTERVAL_MAX_MS"); fm != "" {
+		if parsed, err := strconv.Atoi(fm); err == nil && parsed > 0 {
+			flushIntervalMaxMs = parsed
+		}
+	}
+	if flushIntervalMaxMs < flushIntervalMinMs {
+		flushIntervalMaxMs = flushIntervalMinMs
+	}
+
+	warmupWaitMs := 50
+	if ww := os.Getenv("PREFETCH_WARMUP_WAIT_MS"); ww != "" {
+		if parsed, err := strconv.Atoi(ww); err == nil && parsed >= 0 {
+			warmupWaitMs = parsed
+		}
+	}
+
 	metricsPort := 8081
 	if mp := os.Getenv("METRICS_PORT"); mp != "" {
 		if parsed, err := strconv.Atoi(mp); err == nil {
@@ -55,12 +253,370 @@ func loadConfig() *Config {
 		}
 	}
 
+	// NATS headers copied into the stored event's custom fields
+	headerTags := splitList(os.Getenv("HEADER_TAGS"))
+
+	allowedStatuses := os.Getenv("ALLOWED_STATUSES")
+	if allowedStatuses == "" {
+		allowedStatuses = defaultAllowedStatuses
+	}
+
+	statusValidation := os.Getenv("STATUS_VALIDATION")
+	switch statusValidation {
+	case "off", "warn", "reject":
+	default:
+		statusValidation = "warn"
+	}
+
+	commitIntervalMs := 0
+	if ci := os.Getenv("COMMIT_INTERVAL_MS"); ci != "" {
+		if parsed, err := strconv.Atoi(ci); err == nil {
+			commitIntervalMs = parsed
+		}
+	}
+
+	scyllaConns := 2
+	if nc := os.Getenv("SCYLLA_NUM_CONNS"); nc != "" {
+		if parsed, err := strconv.Atoi(nc); err == nil && parsed > 0 {
+			scyllaConns = parsed
+		}
+	}
+
+	// Requests in flight per Scylla connection; 0 leaves the driver's
+	// stream limit
+	scyllaMaxRequests := 0
+	if v := os.Getenv("SCYLLA_MAX_REQUESTS_PER_CONN"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			scyllaMaxRequests = parsed
+		}
+	}
+
+	scyllaKeyspace := cqlconn.KeyspaceEnv("SCYLLA_KEYSPACE", "facto")
+
+	// Reads also default to LOCAL_QUORUM: session limits and deduplication
+	// decide on what they read, so they must see acknowledged writes
+	readConsistency := cqlconn.ConsistencyEnv("SCYLLA_READ_CONSISTENCY", gocql.LocalQuorum)
+	writeConsistency := cqlconn.ConsistencyEnv("SCYLLA_WRITE_CONSISTENCY", gocql.LocalQuorum)
+
+	// Only used when AUTO_MIGRATE creates the keyspace; an existing keyspace
+	// keeps its replication
+	replicationFactor := 1
+	if rf := os.Getenv("SCYLLA_REPLICATION_FACTOR"); rf != "" {
+		if parsed, err := strconv.Atoi(rf); err == nil && parsed > 0 {
+			replicationFactor = parsed
+		} else {
+			log.Warn().Str("value", rf).Msg("SCYLLA_REPLICATION_FACTOR must be a positive integer, using 1")
+		}
+	}
+
+	compactionIntervalMs := 0
+	if ci := os.Getenv("DAILY_COMPACTION_INTERVAL_MS"); ci != "" {
+		if parsed, err := strconv.Atoi(ci); err == nil && parsed >= 0 {
+			compactionIntervalMs = parsed
+		}
+	}
+
+	compactionLookbackDays := 1
+	if lb := os.Getenv("DAILY_COMPACTION_LOOKBACK_DAYS"); lb != "" {
+		if parsed, err := strconv.Atoi(lb); err == nil && parsed > 0 {
+			compactionLookbackDays = parsed
+		}
+	}
+
+	storagePingMs := 10000
+	if sp := os.Getenv("STORAGE_PING_INTERVAL_MS"); sp != "" {
+		if parsed, err := strconv.Atoi(sp); err == nil && parsed > 0 {
+			storagePingMs = parsed
+		}
+	}
+
+	consumerLagMs := 5000
+	if cl := os.Getenv("CONSUMER_LAG_INTERVAL_MS"); cl != "" {
+		if parsed, err := strconv.Atoi(cl); err == nil && parsed > 0 {
+			consumerLagMs = parsed
+		}
+	}
+
+	readyTimeoutMs := 2000
+	if rt := os.Getenv("READY_TIMEOUT_MS"); rt != "" {
+		if parsed, err := strconv.Atoi(rt); err == nil && parsed > 0 {
+			readyTimeoutMs = parsed
+		}
+	}
+
+	slowPauseMs := 1000
+	if sp := os.Getenv("SLOW_CONSUMER_PAUSE_MS"); sp != "" {
+		if parsed, err := strconv.Atoi(sp); err == nil && parsed >= 0 {
+			slowPauseMs = parsed
+		}
+	}
+
+	var maxEventsPerSession int64
+	if me := os.Getenv("MAX_EVENTS_PER_SESSION"); me != "" {
+		if parsed, err := strconv.ParseInt(me, 10, 64); err == nil {
+			maxEventsPerSession = parsed
+		}
+	}
+
+	var maxToolCalls int
+	if mt := os.Getenv("MAX_TOOL_CALLS"); mt != "" {
+		if parsed, err := strconv.Atoi(mt); err == nil && parsed >= 0 {
+			maxToolCalls = parsed
+		}
+	}
+
+	commitWebhookRetries := 3
+	if wr := os.Getenv("COMMIT_WEBHOOK_RETRIES"); wr != "" {
+		if parsed, err := strconv.Atoi(wr); err == nil && parsed >= 0 {
+			commitWebhookRetries = parsed
+		}
+	}
+
+	anchorRetries := 3
+	if ar := os.Getenv("ANCHOR_RETRIES"); ar != "" {
+		if parsed, err := strconv.Atoi(ar); err == nil && parsed >= 0 {
+			anchorRetries = parsed
+		}
+	}
+
+	tsaRetries := 3
+	if tr := os.Getenv("TSA_RETRIES"); tr != "" {
+		if parsed, err := strconv.Atoi(tr); err == nil && parsed >= 0 {
+			tsaRetries = parsed
+		}
+	}
+
+	outputSubject := os.Getenv("OUTPUT_SUBJECT")
+	if strings.HasPrefix(outputSubject, "facto.events.") {
+		// Republishing into the ingest stream would feed events back in
+		log.Warn().Str("output_subject", outputSubject).Msg("OUTPUT_SUBJECT overlaps facto.events.>, republishing disabled")
+		outputSubject = ""
+	}
+
+	outputMode := os.Getenv("OUTPUT_MODE")
+	if outputMode != "notification" {
+		outputMode = "event"
+	}
+
+	deadLetterSubject := os.Getenv("DEAD_LETTER_SUBJECT")
+	if deadLetterSubject == "" {
+		deadLetterSubject = "facto.deadletter.events"
+	}
+	if strings.HasPrefix(deadLetterSubject, "facto.events.") {
+		// Dead-lettering into the ingest stream would feed events back in
+		log.Warn().Str("dead_letter_subject", deadLetterSubject).Msg("DEAD_LETTER_SUBJECT overlaps facto.events.>, using the default")
+		deadLetterSubject = "facto.deadletter.events"
+	}
+
+	deadLetterMaxDeliveries := 1
+	if md := os.Getenv("DEAD_LETTER_MAX_DELIVERIES"); md != "" {
+		if parsed, err := strconv.Atoi(md); err == nil && parsed > 0 {
+			deadLetterMaxDeliveries = parsed
+		}
+	}
+
+	var eventTTL int
+	if et := os.Getenv("EVENT_TTL_SECONDS"); et != "" {
+		if parsed, err := strconv.Atoi(et); err == nil && parsed >= 0 {
+			eventTTL = parsed
+		}
+	}
+
+	var merkleRootTTL int
+	if mt := os.Getenv("MERKLE_ROOT_TTL_SECONDS"); mt != "" {
+		if parsed, err := strconv.Atoi(mt); err == nil && parsed >= 0 {
+			merkleRootTTL = parsed
+		}
+	}
+
+	sessionCacheSize := 100000
+	if sc := os.Getenv("SESSION_CACHE_SIZE"); sc != "" {
+		if parsed, err := strconv.Atoi(sc); err == nil && parsed >= 0 {
+			sessionCacheSize = parsed
+		}
+	}
+
+	// Unlike most settings an unknown scheme is fatal: falling back would
+	// quietly store roots under a scheme the operator didn't choose
+	merkleScheme, err := merkle.ParseScheme(os.Getenv("MERKLE_TREE_SCHEME"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid MERKLE_TREE_SCHEME")
+	}
+
+	dedup := os.Getenv("DEDUP_MODE")
+	switch dedup {
+	case DedupOff, DedupSelect, DedupLWT:
+	default:
+		dedup = DedupOff
+	}
+
+	searchIndexName := os.Getenv("SEARCH_INDEX_NAME")
+	if searchIndexName == "" {
+		searchIndexName = "facto-events"
+	}
+
+	var bulkMaxBytes int64 = 10 << 20
+	if mb := os.Getenv("BULK_INGEST_MAX_BYTES"); mb != "" {
+		if parsed, err := strconv.ParseInt(mb, 10, 64); err == nil && parsed > 0 {
+			bulkMaxBytes = parsed
+		}
+	}
+
+	bulkMaxEvents := 1000
+	if me := os.Getenv("BULK_INGEST_MAX_EVENTS"); me != "" {
+		if parsed, err := strconv.Atoi(me); err == nil && parsed > 0 {
+			bulkMaxEvents = parsed
+		}
+	}
+
+	grpcPort := 0
+	if gp := os.Getenv("GRPC_PORT"); gp != "" {
+		if parsed, err := strconv.Atoi(gp); err == nil && parsed > 0 {
+			grpcPort = parsed
+		}
+	}
+
 	return &Config{
-		NatsURL:       natsURL,
-		ScyllaHosts:   []string{scyllaHosts},
-		BatchSize:     batchSize,
-		FlushInterval: time.Duration(flushIntervalMs) * time.Millisecond,
-		MetricsPort:   metricsPort,
+		NatsURL:           natsURL,
+		ScyllaHosts:       []string{scyllaHosts},
+		ScyllaConns:       scyllaConns,
+		ScyllaMaxRequests: scyllaMaxRequests,
+		BatchSize:         batchSize,
+		WorkerCount:       workerCount,
+		FlushInterval:     time.Duration(flushIntervalMs) * time.Millisecond,
+		MetricsPort:       metricsPort,
+		HeaderTags:        headerTags,
+
+		FlushMode:        flushMode,
+		FlushIntervalMin: time.Duration(flushIntervalMinMs) * time.Millisecond,
+		FlushIntervalMax: time.Duration(flushIntervalMaxMs) * time.Millisecond,
+
+		ScyllaKeyspace:   scyllaKeyspace,
+		ReadConsistency:  readConsistency,
+		WriteConsistency: writeConsistency,
+
+		AutoMigrate:       os.Getenv("AUTO_MIGRATE") == "true",
+		ReplicationFactor: replicationFactor,
+
+		PrefetchWarmupWait: time.Duration(warmupWaitMs) * time.Millisecond,
+
+		AllowedStatuses:  splitList(allowedStatuses),
+		StatusValidation: statusValidation,
+		CommitInterval:   time.Duration(commitIntervalMs) * time.Millisecond,
+
+		CompactionInterval:     time.Duration(compactionIntervalMs) * time.Millisecond,
+		CompactionLookbackDays: compactionLookbackDays,
+		PruneBatchRoots:        os.Getenv("PRUNE_BATCH_ROOTS") == "true",
+
+		StoragePingInterval: time.Duration(storagePingMs) * time.Millisecond,
+		ConsumerLagInterval: time.Duration(consumerLagMs) * time.Millisecond,
+		ReadyTimeout:        time.Duration(readyTimeoutMs) * time.Millisecond,
+		MaxEventsPerSession: maxEventsPerSession,
+		MaxToolCalls:        maxToolCalls,
+		AtomicWrites:        os.Getenv("ATOMIC_WRITES") == "true",
+		StoreCanonical:      os.Getenv("STORE_CANONICAL") == "true",
+		StoreStreamSeq:      os.Getenv("STORE_STREAM_SEQ") == "true",
+		Dedup:               dedup,
+		EventTTL:            eventTTL,
+		MerkleRootTTL:       merkleRootTTL,
+		SessionCacheSize:    sessionCacheSize,
+		MerkleScheme:        merkleScheme,
+		RootsOnly:           os.Getenv("ROOTS_ONLY") == "true",
+		SlowConsumerPause:   time.Duration(slowPauseMs) * time.Millisecond,
+
+		VerifyOnIngest:          os.Getenv("VERIFY_ON_INGEST") == "true",
+		DeadLetterSubject:       deadLetterSubject,
+		DeadLetterMaxDeliveries: deadLetterMaxDeliveries,
+
+		CommitWebhookURL:     os.Getenv("COMMIT_WEBHOOK_URL"),
+		CommitWebhookRetries: commitWebhookRetries,
+		WebhookSecret:        os.Getenv("WEBHOOK_SECRET"),
+		AnchorURL:            os.Getenv("ANCHOR_URL"),
+		AnchorRetries:        anchorRetries,
+		TSAURL:               os.Getenv("TSA_URL"),
+		TSARetries:           tsaRetries,
+
+		OutputSubject: outputSubject,
+		OutputMode:    outputMode,
+
+		SearchIndexURL:  os.Getenv("SEARCH_INDEX_URL"),
+		SearchIndexName: searchIndexName,
+
+		BulkIngest:          os.Getenv("BULK_INGEST") == "true",
+		BulkIngestMaxBytes:  bulkMaxBytes,
+		BulkIngestMaxEvents: bulkMaxEvents,
+
+		GRPCPort: grpcPort,
+	}
+}
+
+// splitList splits a comma-separated env value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// startMetricsServer serves handler on addr until the returned server is
+// shut down. It listens before returning, so a port already in use fails
+// startup rather than leaving the processor without metrics.
+func startMetricsServer(addr string, handler http.Handler) (*http.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Addr: lis.Addr().String(), Handler: handler}
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("Starting metrics server")
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("Metrics server error")
+		}
+	}()
+	return srv, nil
+}
+
+// storagePinger is the storage /ready checks; *Storage outside tests
+type storagePinger interface {
+	Ping(ctx context.Context) error
+}
+
+// natsStatus is the NATS connection /ready checks; *Consumer outside tests
+type natsStatus interface {
+	Connected() bool
+}
+
+// readyHandler reports whether the processor can do work: ScyllaDB answers a
+// query within timeout and the NATS connection is up. Unlike /health it
+// returns 503 naming the dependency that is down.
+func readyHandler(storage storagePinger, consumer natsStatus, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := map[string]string{"status": "ready", "storage": "ok", "nats": "ok"}
+		code := http.StatusOK
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		err := storage.Ping(ctx)
+		setStorageUp(err)
+		if err != nil {
+			status["storage"] = err.Error()
+			code = http.StatusServiceUnavailable
+		}
+		if !consumer.Connected() {
+			status["nats"] = "disconnected"
+			code = http.StatusServiceUnavailable
+		}
+		if code != http.StatusOK {
+			status["status"] = "not_ready"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(status)
 	}
 }
 
@@ -76,25 +632,113 @@ func main() {
 	log.Info().
 		Str("nats_url", config.NatsURL).
 		Strs("scylla_hosts", config.ScyllaHosts).
+		Int("scylla_num_conns", config.ScyllaConns).
+		Int("scylla_max_requests_per_conn", config.ScyllaMaxRequests).
+		Str("scylla_keyspace", config.ScyllaKeyspace).
+		Str("scylla_read_consistency", config.ReadConsistency.String()).
+		Str("scylla_write_consistency", config.WriteConsistency.String()).
+		Bool("auto_migrate", config.AutoMigrate).
+		Int("scylla_replication_factor", config.ReplicationFactor).
 		Int("batch_size", config.BatchSize).
+		Int("worker_count", config.WorkerCount).
 		Dur("flush_interval", config.FlushInterval).
+		Str("flush_mode", config.FlushMode).
+		Dur("flush_interval_min", config.FlushIntervalMin).
+		Dur("flush_interval_max", config.FlushIntervalMax).
+		Dur("prefetch_warmup_wait", config.PrefetchWarmupWait).
 		Int("metrics_port", config.MetricsPort).
+		Strs("header_tags", config.HeaderTags).
+		Strs("allowed_statuses", config.AllowedStatuses).
+		Str("status_validation", config.StatusValidation).
+		Dur("commit_interval", config.CommitInterval).
+		Dur("daily_compaction_interval", config.CompactionInterval).
+		Int("daily_compaction_lookback_days", config.CompactionLookbackDays).
+		Bool("prune_batch_roots", config.PruneBatchRoots).
+		Int64("max_events_per_session", config.MaxEventsPerSession).
+		Int("max_tool_calls", config.MaxToolCalls).
+		Bool("atomic_writes", config.AtomicWrites).
+		Bool("store_canonical", config.StoreCanonical).
+		Bool("store_stream_seq", config.StoreStreamSeq).
+		Str("dedup_mode", config.Dedup).
+		Int("event_ttl_seconds", config.EventTTL).
+		Int("merkle_root_ttl_seconds", config.MerkleRootTTL).
+		Int("session_cache_size", config.SessionCacheSize).
+		Str("merkle_tree_scheme", string(config.MerkleScheme)).
+		Bool("roots_only", config.RootsOnly).
+		Bool("verify_on_ingest", config.VerifyOnIngest).
+		Str("dead_letter_subject", config.DeadLetterSubject).
+		Int("dead_letter_max_deliveries", config.DeadLetterMaxDeliveries).
+		Dur("slow_consumer_pause", config.SlowConsumerPause).
+		Dur("storage_ping_interval", config.StoragePingInterval).
+		Dur("consumer_lag_interval", config.ConsumerLagInterval).
+		Dur("ready_timeout", config.ReadyTimeout).
+		Bool("commit_webhook", config.CommitWebhookURL != "").
+		Int("commit_webhook_retries", config.CommitWebhookRetries).
+		Bool("webhook_signed", config.WebhookSecret != "").
+		Bool("anchor", config.AnchorURL != "").
+		Int("anchor_retries", config.AnchorRetries).
+		Bool("tsa", config.TSAURL != "").
+		Int("tsa_retries", config.TSARetries).
+		Str("output_subject", config.OutputSubject).
+		Str("output_mode", config.OutputMode).
+		Bool("search_index", config.SearchIndexURL != "").
+		Str("search_index_name", config.SearchIndexName).
+		Bool("bulk_ingest", config.BulkIngest).
+		Int64("bulk_ingest_max_bytes", config.BulkIngestMaxBytes).
+		Int("bulk_ingest_max_events", config.BulkIngestMaxEvents).
+		Int("grpc_port", config.GRPCPort).
 		Msg("Configuration loaded")
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	shutdownTracing, err := tracing.Init(ctx, tracingServiceName)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+	if tracing.Enabled() {
+		log.Info().Msg("OpenTelemetry tracing enabled")
+	}
+
 	// Initialize storage
-	storage, err := NewStorage(config.ScyllaHosts)
+	storage, err := NewStorage(config.ScyllaHosts, StorageOptions{
+		NumConns:           config.ScyllaConns,
+		MaxRequestsPerConn: config.ScyllaMaxRequests,
+		Keyspace:           config.ScyllaKeyspace,
+		ReadConsistency:    config.ReadConsistency,
+		WriteConsistency:   config.WriteConsistency,
+		AutoMigrate:        config.AutoMigrate,
+		ReplicationFactor:  config.ReplicationFactor,
+		AtomicWrites:       config.AtomicWrites,
+		StoreCanonical:     config.StoreCanonical,
+		Dedup:              config.Dedup,
+		EventTTL:           config.EventTTL,
+		MerkleRootTTL:      config.MerkleRootTTL,
+		MerkleScheme:       config.MerkleScheme,
+		SessionCacheSize:   config.SessionCacheSize,
+	})
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize storage")
 	}
 	defer storage.Close()
 	log.Info().Msg("Connected to ScyllaDB")
+	go storage.MonitorHealth(ctx, config.StoragePingInterval)
+
+	// Start interval commitments
+	if config.CommitInterval > 0 {
+		committer := NewIntervalCommitter(storage, config.CommitInterval)
+		go committer.Run(ctx)
+	}
+
+	// Start daily root compaction
+	if config.CompactionInterval > 0 {
+		compactor := NewDailyCompactor(storage, config.CompactionInterval, config.CompactionLookbackDays, config.PruneBatchRoots)
+		go compactor.Run(ctx)
+	}
 
 	// Initialize consumer
-	consumer, err := NewConsumer(config.NatsURL, storage, config.BatchSize, config.FlushInterval)
+	consumer, err := NewConsumer(config, storage)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize consumer")
 	}
@@ -102,22 +746,50 @@ func main() {
 	log.Info().Msg("Connected to NATS")
 
 	// Start metrics server
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"status":"healthy"}`))
-		})
-		addr := ":" + strconv.Itoa(config.MetricsPort)
-		log.Info().Str("addr", addr).Msg("Starting metrics server")
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			log.Error().Err(err).Msg("Metrics server error")
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/metrics.json", metricsJSONHandler(prometheus.DefaultGatherer))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if !storage.IsOpen() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"degraded","storage":"closed"}`))
+			return
 		}
-	}()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"healthy"}`))
+	})
+	mux.HandleFunc("/ready", readyHandler(storage, consumer, config.ReadyTimeout))
+	bulkIngester := NewBulkIngester(consumer, config.BulkIngestMaxBytes, config.BulkIngestMaxEvents)
+	if config.BulkIngest {
+		mux.Handle("POST /v1/events", bulkIngester)
+	}
+	metricsServer, err := startMetricsServer(":"+strconv.Itoa(config.MetricsPort), mux)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to listen for metrics")
+	}
+
+	// Start gRPC server
+	var grpcServer *grpc.Server
+	if config.GRPCPort > 0 {
+		lis, err := net.Listen("tcp", ":"+strconv.Itoa(config.GRPCPort))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to listen for gRPC")
+		}
+		grpcServer = grpc.NewServer()
+		factopb.RegisterIngestServer(grpcServer, NewGRPCIngester(bulkIngester))
+		go func() {
+			log.Info().Str("addr", lis.Addr().String()).Msg("Starting gRPC server")
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Error().Err(err).Msg("gRPC server error")
+			}
+		}()
+	}
 
 	// Start consuming messages
+	consumerDone := make(chan struct{})
 	go func() {
-		if err := consumer.Start(ctx); err != nil {
+		defer close(consumerDone)
+		if err := consumer.Start(ctx); err != nil && err != context.Canceled {
 			log.Error().Err(err).Msg("Consumer error")
 			cancel()
 		}
@@ -131,7 +803,37 @@ func main() {
 	log.Info().Msg("Shutting down...")
 	cancel()
 
-	// Give time for graceful shutdown
-	time.Sleep(2 * time.Second)
+	// Let in-flight scrapes finish while the consumer drains
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer shutdownCancel()
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Metrics server forced to shutdown")
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+
+	// Wait for the workers to store their last batches
+	select {
+	case <-consumerDone:
+	case <-time.After(drainTimeout + time.Second):
+		log.Warn().Msg("Timed out waiting for workers to drain")
+	}
+
+	// Flush spans from the consumer's final batch
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer tracingCancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
 	log.Info().Msg("Shutdown complete")
 }
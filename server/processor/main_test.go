@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsServerShutsDownCleanly(t *testing.T) {
+	// A scrape in flight when shutdown starts still completes
+	inFlight := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			close(inFlight)
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Write([]byte("facto_processor_up 1\n"))
+	})
+	srv, err := startMetricsServer("127.0.0.1:0", mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + srv.Addr + "/metrics"
+	// Without keep-alives no spare connection sits unused, which Shutdown
+	// would wait on
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+
+	slow := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(url + "?slow=1")
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		slow <- err
+	}()
+	<-inFlight
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := <-slow; err != nil {
+		t.Errorf("in-flight scrape failed: %v", err)
+	}
+
+	// The listener is closed
+	if conn, err := net.DialTimeout("tcp", srv.Addr, time.Second); err == nil {
+		conn.Close()
+		t.Errorf("metrics port still accepting connections")
+	}
+}
+
+func TestMetricsServerPortInUse(t *testing.T) {
+	srv, err := startMetricsServer("127.0.0.1:0", http.NewServeMux())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	if _, err := startMetricsServer(srv.Addr, http.NewServeMux()); err == nil {
+		t.Errorf("second server started on %s", srv.Addr)
+	}
+}
+
+// stubPinger answers Ping with err, or blocks until the context ends when
+// hang is set
+type stubPinger struct {
+	err  error
+	hang bool
+}
+
+func (p stubPinger) Ping(ctx context.Context) error {
+	if p.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+type stubNATS bool
+
+func (n stubNATS) Connected() bool { return bool(n) }
+
+// /ready is 200 only while storage answers within the timeout and NATS is
+// connected, and the 503 body names the dependency that is down
+func TestReadyHandler(t *testing.T) {
+	cases := []struct {
+		name    string
+		storage stubPinger
+		nats    stubNATS
+		code    int
+		want    map[string]string
+	}{
+		{"ok", stubPinger{}, true, http.StatusOK,
+			map[string]string{"status": "ready", "storage": "ok", "nats": "ok"}},
+		{"storage failing", stubPinger{err: errors.New("no hosts available")}, true, http.StatusServiceUnavailable,
+			map[string]string{"status": "not_ready", "storage": "no hosts available", "nats": "ok"}},
+		{"storage hanging", stubPinger{hang: true}, true, http.StatusServiceUnavailable,
+			map[string]string{"status": "not_ready", "storage": context.DeadlineExceeded.Error(), "nats": "ok"}},
+		{"nats disconnected", stubPinger{}, false, http.StatusServiceUnavailable,
+			map[string]string{"status": "not_ready", "storage": "ok", "nats": "disconnected"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			readyHandler(tc.storage, tc.nats, 50*time.Millisecond)(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("/ready took %v with a 50ms timeout", elapsed)
+			}
+
+			if rec.Code != tc.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.code, rec.Body)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.want {
+				if body[k] != v {
+					t.Errorf("%s = %q, want %q", k, body[k], v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+)
+
+// The batch log is an append-only Merkle tree over every batch root, in the
+// order roots join the root chain, hashed as in RFC 6962
+// (merkle.SchemeRFC6962): a leaf is SHA-256(0x00 || root) and an interior
+// node SHA-256(0x01 || left || right).
+// Unlike the per-batch trees, which are independent, a later log root
+// commits to every earlier one, so the API can prove that one log root
+// extends another (GET /v1/merkle-consistency).
+//
+// Only perfect subtrees are stored (merkle_log_nodes), addressed by level
+// and index: node (l, i) covers leaves [i*2^l, (i+1)*2^l). Appending a leaf
+// completes the leaf node and one node per level for each trailing one bit
+// of its index.
+
+// logLeafHash returns the log leaf hash of a batch root
+func logLeafHash(rootHash string) string {
+	return merkle.SchemeRFC6962.LeafHash(rootHash)
+}
+
+// logNodeHash returns the log hash of an interior node
+func logNodeHash(left, right string) string {
+	return merkle.SchemeRFC6962.NodeHash(left, right)
+}
+
+// RootChainState is the merkle_root_chain row: the chain head and the batch
+// log it has been appended to
+type RootChainState struct {
+	RootHash string // Last linked batch root, also the log's last leaf
+
+	// LogSize is the number of leaves in the log. LogFrontier holds the
+	// roots of the perfect subtrees the log decomposes into, largest first,
+	// one per set bit of LogSize.
+	LogSize     int64
+	LogFrontier []string
+
+	// LogTail holds the nodes the last append completed, the leaf first.
+	// They are kept until the next append so a processor that stops between
+	// the lightweight transaction and writing them can't leave a hole.
+	LogTail []string
+}
+
+// appendLeaf returns the state after appending rootHash to the log
+func (st *RootChainState) appendLeaf(rootHash string) *RootChainState {
+	frontier := make([]string, len(st.LogFrontier), len(st.LogFrontier)+1)
+	copy(frontier, st.LogFrontier)
+
+	hash := logLeafHash(rootHash)
+	tail := []string{hash}
+	for index := st.LogSize; index&1 == 1; index >>= 1 {
+		hash = logNodeHash(frontier[len(frontier)-1], hash)
+		frontier = frontier[:len(frontier)-1]
+		tail = append(tail, hash)
+	}
+
+	return &RootChainState{
+		RootHash:    rootHash,
+		LogSize:     st.LogSize + 1,
+		LogFrontier: append(frontier, hash),
+		LogTail:     tail,
+	}
+}
+
+// logRoot returns the log's RFC 6962 root: the frontier folded right to left
+func (st *RootChainState) logRoot() string {
+	if len(st.LogFrontier) == 0 {
+		hash := sha256.Sum256(nil)
+		return hex.EncodeToString(hash[:])
+	}
+	root := st.LogFrontier[len(st.LogFrontier)-1]
+	for i := len(st.LogFrontier) - 2; i >= 0; i-- {
+		root = logNodeHash(st.LogFrontier[i], root)
+	}
+	return root
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/facto-ai/facto/server/shared/merkle"
+)
+
+// The processor grows the batch log one leaf at a time, while the Query API
+// proves consistency over whole RFC 6962 trees. After every append the log
+// root must be the root merkle.Build gives for the same batch roots.
+func TestLogRootMatchesBuiltTree(t *testing.T) {
+	state := &RootChainState{}
+	var roots []string
+	for n := 1; n <= 70; n++ {
+		hash := sha256.Sum256([]byte(fmt.Sprint("batch-", n)))
+		root := hex.EncodeToString(hash[:])
+		roots = append(roots, root)
+
+		state = state.appendLeaf(root)
+		tree, err := merkle.Build(context.Background(), roots, merkle.SchemeRFC6962)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.logRoot() != tree.Root() {
+			t.Fatalf("size %d: log root %s, tree root %s", n, state.logRoot(), tree.Root())
+		}
+		if state.LogSize != int64(n) || state.RootHash != root {
+			t.Fatalf("size %d: state %+v", n, state)
+		}
+
+		// The last node the append completed covers the perfect subtree
+		// ending at the new leaf
+		width := 1 << (len(state.LogTail) - 1)
+		subtree, _ := merkle.Build(context.Background(), roots[n-width:], merkle.SchemeRFC6962)
+		if got := state.LogTail[len(state.LogTail)-1]; got != subtree.Root() {
+			t.Fatalf("size %d: tail node %s, subtree root %s", n, got, subtree.Root())
+		}
+	}
+}
+
+func TestEmptyLogRoot(t *testing.T) {
+	tree, _ := merkle.Build(context.Background(), nil, merkle.SchemeRFC6962)
+	if got := (&RootChainState{}).logRoot(); got != tree.Root() {
+		t.Errorf("got %s, want %s", got, tree.Root())
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/facto-ai/facto/server/shared/metricsjson"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsJSONHandler serves a point-in-time JSON view of gatherer, as
+// flattened by metricsjson.Snapshot
+func metricsJSONHandler(gatherer prometheus.Gatherer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := metricsjson.Snapshot(gatherer)
+		if err != nil {
+			http.Error(w, `{"error":"failed to gather metrics"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshot)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetricsJSON(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	batches := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_batches_total", Help: "test"},
+		[]string{"worker", "result"})
+	registry.MustRegister(batches)
+	batches.WithLabelValues("0", "stored").Inc()
+
+	rec := httptest.NewRecorder()
+	metricsJSONHandler(registry)(rec, httptest.NewRequest(http.MethodGet, "/metrics.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var snapshot map[string]float64
+	if err := json.Unmarshal(rec.Body.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot) != 1 || snapshot[`test_batches_total{result="stored",worker="0"}`] != 1 {
+		t.Errorf("snapshot %v", snapshot)
+	}
+}
+
+func TestMetricsJSONGatherError(t *testing.T) {
+	failing := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, errors.New("collector failed")
+	})
+	rec := httptest.NewRecorder()
+	metricsJSONHandler(failing)(rec, httptest.NewRequest(http.MethodGet, "/metrics.json", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var flushIntervalSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "facto_processor_flush_interval_seconds",
+	Help: "Current flush interval; varies only with FLUSH_MODE=adaptive",
+})
+
+// Flush modes (FLUSH_MODE)
+const (
+	FlushFixed    = "fixed"    // Flush every FLUSH_INTERVAL_MS
+	FlushAdaptive = "adaptive" // Vary the interval with ack pressure, see FlushPacer
+)
+
+// Ack pressure, the share of MaxAckPending in use, above which the flush
+// interval shrinks and below which it grows
+const (
+	flushPressureHigh = 0.5
+	flushPressureLow  = 0.1
+)
+
+// FlushPacer holds the flush interval workers and fetches wait on. In
+// adaptive mode each consumer info read adjusts it: under ack pressure it is
+// halved, so smaller batches are flushed and acknowledged sooner before
+// MaxAckPending stops deliveries; while the consumer keeps up it grows by
+// half, saving round trips. It stays within [min, max]. A fixed pacer has
+// min = max.
+type FlushPacer struct {
+	min, max time.Duration
+	current  atomic.Int64 // nanoseconds
+}
+
+// NewFlushPacer creates a pacer starting at initial, clamped to [min, max]
+func NewFlushPacer(initial, min, max time.Duration) *FlushPacer {
+	p := &FlushPacer{min: min, max: max}
+	p.set(initial)
+	return p
+}
+
+// Interval returns the current flush interval
+func (p *FlushPacer) Interval() time.Duration {
+	return time.Duration(p.current.Load())
+}
+
+// Observe adjusts the interval to the consumer's ack pressure
+func (p *FlushPacer) Observe(info *jetstream.ConsumerInfo) {
+	if info.Config.MaxAckPending <= 0 {
+		return
+	}
+	pressure := float64(info.NumAckPending) / float64(info.Config.MaxAckPending)
+
+	interval := p.Interval()
+	switch {
+	case pressure >= flushPressureHigh:
+		p.set(interval / 2)
+	case pressure <= flushPressureLow:
+		p.set(interval + interval/2)
+	}
+}
+
+func (p *FlushPacer) set(interval time.Duration) {
+	if interval < p.min {
+		interval = p.min
+	}
+	if interval > p.max {
+		interval = p.max
+	}
+	p.current.Store(int64(interval))
+	flushIntervalSeconds.Set(interval.Seconds())
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// maxAckPending is the simulated consumer's MaxAckPending
+const maxAckPending = 1000
+
+// paceTraffic feeds the pacer one consumer info read per rate, in messages
+// per second, and returns the interval after each. Messages delivered over
+// an interval wait unacknowledged until its flush, so the ack pending count
+// is the rate times the interval, up to MaxAckPending.
+func paceTraffic(p *FlushPacer, rates ...float64) []time.Duration {
+	intervals := make([]time.Duration, len(rates))
+	for i, rate := range rates {
+		pending := min(int(rate*p.Interval().Seconds()), maxAckPending)
+		p.Observe(&jetstream.ConsumerInfo{
+			Config:        jetstream.ConsumerConfig{MaxAckPending: maxAckPending},
+			NumAckPending: pending,
+		})
+		intervals[i] = p.Interval()
+	}
+	return intervals
+}
+
+// ackPressure is the share of MaxAckPending a rate fills over interval
+func ackPressure(rate float64, interval time.Duration) float64 {
+	return min(rate*interval.Seconds()/maxAckPending, 1)
+}
+
+// constantRate returns n reads at rate
+func constantRate(rate float64, n int) []float64 {
+	rates := make([]float64, n)
+	for i := range rates {
+		rates[i] = rate
+	}
+	return rates
+}
+
+func TestFlushPacerIdleTrafficGrowsToMax(t *testing.T) {
+	p := NewFlushPacer(time.Second, 50*time.Millisecond, 5*time.Second)
+	intervals := paceTraffic(p, constantRate(0, 10)...)
+
+	for i := 1; i < len(intervals); i++ {
+		if intervals[i] < intervals[i-1] {
+			t.Errorf("idle interval shrank from %v to %v", intervals[i-1], intervals[i])
+		}
+	}
+	if last := intervals[len(intervals)-1]; last != 5*time.Second {
+		t.Errorf("idle interval settled at %v, want the 5s maximum", last)
+	}
+}
+
+func TestFlushPacerSteadyTrafficSettles(t *testing.T) {
+	p := NewFlushPacer(time.Second, 50*time.Millisecond, 5*time.Second)
+	intervals := paceTraffic(p, constantRate(1000, 20)...)
+
+	// A full second of 1000 msg/s saturates MaxAckPending; the interval
+	// shrinks until the pending share is moderate, then holds
+	settled := intervals[len(intervals)-1]
+	if settled >= time.Second || settled <= 50*time.Millisecond {
+		t.Fatalf("steady interval settled at %v, want between the bounds", settled)
+	}
+	for _, interval := range intervals[10:] {
+		if interval != settled {
+			t.Errorf("steady interval still moving: %v", intervals)
+			break
+		}
+	}
+	if pressure := ackPressure(1000, settled); pressure >= flushPressureHigh || pressure <= flushPressureLow {
+		t.Errorf("settled at %v with ack pressure %.2f", settled, pressure)
+	}
+}
+
+func TestFlushPacerBurstyTraffic(t *testing.T) {
+	p := NewFlushPacer(time.Second, 50*time.Millisecond, 5*time.Second)
+	before := paceTraffic(p, constantRate(100, 10)...)
+	quiet := before[len(before)-1]
+
+	// A burst shrinks the interval to the minimum, so smaller batches are
+	// acknowledged before MaxAckPending stops deliveries
+	burst := paceTraffic(p, constantRate(20000, 10)...)
+	if burst[0] >= quiet {
+		t.Errorf("first read of the burst kept the interval at %v", burst[0])
+	}
+	if last := burst[len(burst)-1]; last != 50*time.Millisecond {
+		t.Errorf("burst interval settled at %v, want the 50ms minimum", last)
+	}
+
+	// Once the burst passes the interval grows back until the quiet
+	// traffic's pending share is moderate again
+	after := paceTraffic(p, constantRate(100, 10)...)
+	last := after[len(after)-1]
+	if pressure := ackPressure(100, last); last <= 50*time.Millisecond || pressure <= flushPressureLow || pressure >= flushPressureHigh {
+		t.Errorf("interval after the burst settled at %v with ack pressure %.2f", last, pressure)
+	}
+}
+
+// The fixed mode's pacer has min = max, so no traffic moves the interval
+func TestFlushPacerFixed(t *testing.T) {
+	p := NewFlushPacer(time.Second, time.Second, time.Second)
+	rates := append(append(constantRate(0, 5), constantRate(20000, 5)...), constantRate(1000, 5)...)
+	for _, interval := range paceTraffic(p, rates...) {
+		if interval != time.Second {
+			t.Fatalf("fixed interval moved to %v", interval)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed schema.cql
+var schemaCQL string
+
+var (
+	createKeyspaceRe = regexp.MustCompile(`^CREATE KEYSPACE IF NOT EXISTS \w+`)
+	replicationRe    = regexp.MustCompile(`'replication_factor':\s*\d+`)
+	createTableRe    = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+)`)
+	createIndexRe    = regexp.MustCompile(`^(CREATE INDEX IF NOT EXISTS \w+ ON) (\w+)`)
+	alterTableRe     = regexp.MustCompile(`^ALTER TABLE \w+ ADD \w+ `)
+)
+
+// EnsureSchema creates the keyspace and every table and index of the embedded
+// schema that doesn't exist yet, then adds the columns of its ALTER TABLE
+// upgrade statements to tables created before them. Statements run one at a
+// time, in file order; creates are IF NOT EXISTS and adding a column that
+// exists is skipped, so running it against a migrated cluster changes
+// nothing. Table names are qualified with the keyspace, so the session need
+// not be bound to it.
+func (s *Storage) EnsureSchema(ctx context.Context) error {
+	return applySchema(s.opts.Keyspace, s.opts.ReplicationFactor, func(stmt string) error {
+		return s.session.Query(stmt).WithContext(ctx).Exec()
+	})
+}
+
+// schemaSession is a session schema statements run over; cqlSchemaSession
+// outside tests
+type schemaSession interface {
+	Exec(ctx context.Context, stmt string) error
+	Close()
+}
+
+// cqlSchemaSession runs schema statements over a gocql session
+type cqlSchemaSession struct {
+	*gocql.Session
+}
+
+func (s cqlSchemaSession) Exec(ctx context.Context, stmt string) error {
+	return s.Query(stmt).WithContext(ctx).Exec()
+}
+
+// migrate runs the embedded schema for opts over session and closes the
+// session, whether the migration succeeds, fails or panics
+func migrate(ctx context.Context, session schemaSession, opts StorageOptions) error {
+	defer session.Close()
+	return applySchema(opts.Keyspace, opts.ReplicationFactor, func(stmt string) error {
+		return session.Exec(ctx, stmt)
+	})
+}
+
+// applySchema runs the embedded schema's statements for keyspace through exec
+func applySchema(keyspace string, replicationFactor int, exec func(stmt string) error) error {
+	statements, err := schemaStatements(schemaCQL, keyspace, replicationFactor)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range statements {
+		summary, _, _ := strings.Cut(stmt, "\n")
+		log.Info().Str("statement", summary).Msg("Applying schema statement")
+		if err := exec(stmt); err != nil {
+			if strings.HasPrefix(stmt, "ALTER TABLE ") && columnExists(err) {
+				continue
+			}
+			return fmt.Errorf("applying %q: %w", summary, err)
+		}
+	}
+	return nil
+}
+
+// schemaStatements splits a CQL file into statements, dropping comments and
+// USE statements, and rewrites them for keyspace: the keyspace is created
+// with replicationFactor replicas and tables and indexes are qualified with it
+func schemaStatements(cql, keyspace string, replicationFactor int) ([]string, error) {
+	var lines []string
+	for _, line := range strings.Split(cql, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case stmt == "", strings.HasPrefix(stmt, "USE "):
+			continue
+		case createKeyspaceRe.MatchString(stmt):
+			stmt = createKeyspaceRe.ReplaceAllString(stmt, "CREATE KEYSPACE IF NOT EXISTS "+keyspace)
+			stmt = replicationRe.ReplaceAllString(stmt, "'replication_factor': "+strconv.Itoa(replicationFactor))
+		case createTableRe.MatchString(stmt):
+			stmt = createTableRe.ReplaceAllString(stmt, "CREATE TABLE IF NOT EXISTS "+keyspace+".$1")
+		case createIndexRe.MatchString(stmt):
+			stmt = createIndexRe.ReplaceAllString(stmt, "$1 "+keyspace+".$2")
+		case alterTableRe.MatchString(stmt):
+			stmt = strings.Replace(stmt, "ALTER TABLE ", "ALTER TABLE "+keyspace+".", 1)
+		default:
+			return nil, fmt.Errorf("unexpected schema statement %q", stmt)
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
+
+// columnExists reports whether an ALTER TABLE ... ADD failed because the
+// column is already there: ScyllaDB says it "conflicts with an existing
+// column", Cassandra that it "already exists"
+func columnExists(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "conflicts with an existing column") || strings.Contains(msg, "already exists")
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// tableStatement returns the CREATE TABLE statement for table in the
+// embedded schema, as EnsureSchema runs it
+func tableStatement(t *testing.T, table string) string {
+	t.Helper()
+	statements, err := schemaStatements(schemaCQL, "facto", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range statements {
+		if strings.HasPrefix(stmt, "CREATE TABLE IF NOT EXISTS facto."+table+" (") {
+			return stmt
+		}
+	}
+	t.Fatalf("no %s table in the schema", table)
+	return ""
+}
+
+func TestSchemaSessionMetadataTable(t *testing.T) {
+	stmt := tableStatement(t, "session_metadata")
+	for _, column := range []string{
+		"session_id text PRIMARY KEY",
+		"agent_id text",
+		"tags map<text, text>",
+		"description text",
+		"created_at timestamp",
+		"updated_at timestamp",
+	} {
+		if !strings.Contains(stmt, column) {
+			t.Errorf("session_metadata lacks %q:\n%s", column, stmt)
+		}
+	}
+}
+
+// memCluster applies schema statements to an in-memory catalog of tables
+// and their columns, rejecting tables and indexes outside a created keyspace
+// the way an unbound session would, and columns added twice the way ScyllaDB
+// does
+type memCluster struct {
+	keyspaces map[string]bool
+	tables    map[string]map[string]bool
+	indexes   map[string]bool
+}
+
+func newMemCluster() *memCluster {
+	return &memCluster{keyspaces: map[string]bool{}, tables: map[string]map[string]bool{}, indexes: map[string]bool{}}
+}
+
+var (
+	memKeyspaceRe = regexp.MustCompile(`^CREATE KEYSPACE IF NOT EXISTS (\w+)`)
+	memTableRe    = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+)\.(\w+)`)
+	memIndexRe    = regexp.MustCompile(`^CREATE INDEX IF NOT EXISTS (\w+) ON (\w+)\.(\w+)`)
+	memAlterRe    = regexp.MustCompile(`^ALTER TABLE (\w+)\.(\w+) ADD (\w+) `)
+	memColumnRe   = regexp.MustCompile(`(?m)^\s+(\w+) \w`)
+)
+
+func (c *memCluster) exec(stmt string) error {
+	if m := memKeyspaceRe.FindStringSubmatch(stmt); m != nil {
+		c.keyspaces[m[1]] = true
+		return nil
+	}
+	if m := memTableRe.FindStringSubmatch(stmt); m != nil {
+		if !c.keyspaces[m[1]] {
+			return fmt.Errorf("keyspace %s does not exist", m[1])
+		}
+		table := m[1] + "." + m[2]
+		if c.tables[table] != nil {
+			return nil
+		}
+		columns := map[string]bool{}
+		for _, col := range memColumnRe.FindAllStringSubmatch(stmt, -1) {
+			if col[1] != "PRIMARY" {
+				columns[col[1]] = true
+			}
+		}
+		c.tables[table] = columns
+		return nil
+	}
+	if m := memIndexRe.FindStringSubmatch(stmt); m != nil {
+		if c.tables[m[2]+"."+m[3]] == nil {
+			return fmt.Errorf("table %s.%s does not exist", m[2], m[3])
+		}
+		c.indexes[m[2]+"."+m[1]] = true
+		return nil
+	}
+	if m := memAlterRe.FindStringSubmatch(stmt); m != nil {
+		columns := c.tables[m[1]+"."+m[2]]
+		if columns == nil {
+			return fmt.Errorf("table %s.%s does not exist", m[1], m[2])
+		}
+		if columns[m[3]] {
+			return fmt.Errorf("Invalid column name %s because it conflicts with an existing column", m[3])
+		}
+		columns[m[3]] = true
+		return nil
+	}
+	return fmt.Errorf("unsupported statement %q", stmt)
+}
+
+// queriedTables returns every table the processor's queries read or write
+func queriedTables(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`(?:INSERT INTO|FROM|UPDATE) ([a-z_]+)\b`)
+	seen := map[string]bool{}
+	var tables []string
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range re.FindAllStringSubmatch(string(src), -1) {
+			// system.local is the cluster's own table
+			if m[1] != "system" && !seen[m[1]] {
+				seen[m[1]] = true
+				tables = append(tables, m[1])
+			}
+		}
+	}
+	return tables
+}
+
+func TestApplySchemaCreatesTables(t *testing.T) {
+	cluster := newMemCluster()
+	if err := applySchema("facto_test", 3, cluster.exec); err != nil {
+		t.Fatal(err)
+	}
+
+	if !cluster.keyspaces["facto_test"] || len(cluster.keyspaces) != 1 {
+		t.Errorf("created keyspaces %v", cluster.keyspaces)
+	}
+	tables := queriedTables(t)
+	if len(tables) == 0 {
+		t.Fatal("found no queried tables")
+	}
+	for _, table := range tables {
+		if cluster.tables["facto_test."+table] == nil {
+			t.Errorf("table %s is queried but not created", table)
+		}
+	}
+
+	// A second run against the migrated cluster changes nothing
+	created := len(cluster.tables) + len(cluster.indexes)
+	if err := applySchema("facto_test", 3, cluster.exec); err != nil {
+		t.Fatal(err)
+	}
+	if len(cluster.tables)+len(cluster.indexes) != created {
+		t.Errorf("re-running the schema created more tables or indexes")
+	}
+}
+
+// insertedColumns returns the columns every INSERT in the processor's
+// queries writes, by table
+func insertedColumns(t *testing.T) map[string][]string {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`INSERT INTO ([a-z_]+) \(([^)]*)\)`)
+	inserted := map[string][]string{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range re.FindAllStringSubmatch(string(src), -1) {
+			for _, col := range strings.Split(m[2], ",") {
+				inserted[m[1]] = append(inserted[m[1]], strings.TrimSpace(col))
+			}
+		}
+	}
+	return inserted
+}
+
+func TestApplySchemaUpgradesReleasedTables(t *testing.T) {
+	released, err := os.ReadFile("testdata/schema_v1.cql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := schemaStatements(string(released), "facto_test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := newMemCluster()
+	for _, stmt := range statements {
+		if err := cluster.exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cluster.tables["facto_test.events"]["stream_seq"] {
+		t.Fatal("released events table already has stream_seq")
+	}
+
+	// Migrating adds the columns the processor writes, and migrating again
+	// skips every ALTER TABLE as already applied
+	for run := 1; run <= 2; run++ {
+		if err := applySchema("facto_test", 1, cluster.exec); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+	inserted := insertedColumns(t)
+	if len(inserted["events"]) == 0 {
+		t.Fatal("found no events insert")
+	}
+	for table, columns := range inserted {
+		for _, col := range columns {
+			if !cluster.tables["facto_test."+table][col] {
+				t.Errorf("%s.%s is written but missing after the upgrade", table, col)
+			}
+		}
+	}
+
+	// Errors other than an existing column still fail the migration
+	failing := func(stmt string) error {
+		if strings.HasPrefix(stmt, "ALTER TABLE ") {
+			return errors.New("unavailable")
+		}
+		return cluster.exec(stmt)
+	}
+	if err := applySchema("facto_test", 1, failing); err == nil {
+		t.Error("a failed ALTER TABLE was skipped")
+	}
+}
+
+func TestSchemaStatementsReplicationFactor(t *testing.T) {
+	statements, err := schemaStatements(schemaCQL, "facto_test", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyspace := statements[0]
+	if !strings.HasPrefix(keyspace, "CREATE KEYSPACE IF NOT EXISTS facto_test") {
+		t.Fatalf("first statement is %q", keyspace)
+	}
+	if !strings.Contains(keyspace, "'replication_factor': 3") {
+		t.Errorf("keyspace isn't created with 3 replicas:\n%s", keyspace)
+	}
+}
+
+// memSchemaSession is a schemaSession whose failAt-th statement fails, or
+// panics with panicAt
+type memSchemaSession struct {
+	failAt   int
+	panicAt  int
+	executed int
+	closed   bool
+}
+
+func (s *memSchemaSession) Exec(ctx context.Context, stmt string) error {
+	s.executed++
+	switch s.executed {
+	case s.failAt:
+		return errors.New("unavailable")
+	case s.panicAt:
+		panic("driver bug")
+	}
+	return nil
+}
+
+func (s *memSchemaSession) Close() { s.closed = true }
+
+func TestMigrateClosesSession(t *testing.T) {
+	opts := StorageOptions{Keyspace: "facto", ReplicationFactor: 1}
+
+	failing := &memSchemaSession{failAt: 3}
+	if err := migrate(context.Background(), failing, opts); err == nil {
+		t.Fatal("expected the statement error")
+	}
+	if !failing.closed || failing.executed != 3 {
+		t.Errorf("failed migration: closed %v after %d statements", failing.closed, failing.executed)
+	}
+
+	panicking := &memSchemaSession{panicAt: 2}
+	func() {
+		defer func() { recover() }()
+		migrate(context.Background(), panicking, opts)
+	}()
+	if !panicking.closed {
+		t.Errorf("panicking migration left its session open")
+	}
+
+	ok := &memSchemaSession{}
+	if err := migrate(context.Background(), ok, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !ok.closed {
+		t.Errorf("migration left its session open")
+	}
+}
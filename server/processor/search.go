@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var searchIndexedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "facto_processor_search_indexed_total",
+	Help: "Total number of events pushed to the search index by result",
+}, []string{"result"})
+
+// searchIndexRetries is how many times a failed bulk push is retried
+const searchIndexRetries = 3
+
+// SearchDocument is the document pushed to the search index for each stored
+// event. Only identifying fields and the searchable text are indexed; the
+// Query API hydrates results from ScyllaDB by facto_id.
+type SearchDocument struct {
+	FactoID     string `json:"facto_id"`
+	AgentID     string `json:"agent_id"`
+	SessionID   string `json:"session_id"`
+	ActionType  string `json:"action_type"`
+	CompletedAt int64  `json:"completed_at"`
+	Text        string `json:"text"`
+}
+
+// SearchIndexer pushes stored events to an Elasticsearch/OpenSearch index
+// with the _bulk API. Documents are keyed by facto_id, so a retried or
+// redelivered batch overwrites rather than duplicates. Like commit webhooks,
+// indexing is best-effort and never blocks or fails the batch.
+type SearchIndexer struct {
+	url    string
+	index  string
+	client *http.Client
+}
+
+// NewSearchIndexer creates an indexer for the index at baseURL. Credentials
+// may be given as URL userinfo.
+func NewSearchIndexer(baseURL, index string) *SearchIndexer {
+	return &SearchIndexer{
+		url:    strings.TrimRight(baseURL, "/") + "/_bulk",
+		index:  index,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Index pushes the events in the background. The bulk body is built before
+// returning, since the consumer reuses its event slice for the next batch.
+func (s *SearchIndexer) Index(ctx context.Context, events []FactoEvent) {
+	body, err := s.bulkBody(events)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode search documents")
+		searchIndexedTotal.WithLabelValues("failed").Add(float64(len(events)))
+		return
+	}
+	go s.deliver(ctx, body, len(events))
+}
+
+func (s *SearchIndexer) bulkBody(events []FactoEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		action := map[string]map[string]string{
+			"index": {"_index": s.index, "_id": event.FactoID},
+		}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		doc := SearchDocument{
+			FactoID:     event.FactoID,
+			AgentID:     event.AgentID,
+			SessionID:   event.SessionID,
+			ActionType:  event.ActionType,
+			CompletedAt: event.CompletedAt,
+			Text:        searchableText(event),
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *SearchIndexer) deliver(ctx context.Context, body []byte, count int) {
+	var err error
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if err = s.post(ctx, body); err == nil {
+			searchIndexedTotal.WithLabelValues("indexed").Add(float64(count))
+			return
+		}
+		if attempt >= searchIndexRetries || !sleepContext(ctx, backoff) {
+			break
+		}
+		backoff *= 2
+	}
+
+	log.Warn().Err(err).Int("count", count).Msg("Failed to push events to search index")
+	searchIndexedTotal.WithLabelValues("failed").Add(float64(count))
+}
+
+func (s *SearchIndexer) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("search index returned status %d", resp.StatusCode)
+	}
+
+	// _bulk answers 200 even when individual documents fail
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding bulk response: %w", err)
+	}
+	if result.Errors {
+		return fmt.Errorf("search index rejected some documents")
+	}
+	return nil
+}
+
+// searchableText joins the string values found in an event's input and output
+// data, one per line, visiting map keys in sorted order so the text is stable
+func searchableText(event FactoEvent) string {
+	var parts []string
+	collectStrings(event.InputData, &parts)
+	collectStrings(event.OutputData, &parts)
+	return strings.Join(parts, "\n")
+}
+
+func collectStrings(value interface{}, parts *[]string) {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			*parts = append(*parts, v)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectStrings(v[key], parts)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectStrings(item, parts)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// seenSessionCache is an LRU of session IDs whose session_metadata row is
+// known to exist, so ensureSessionMetadata skips the lightweight transaction
+// for every batch after a session's first. A session evicted from the cache
+// only costs one more IF NOT EXISTS insert, which doesn't apply.
+type seenSessionCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front = most recently used
+	entries  map[string]*list.Element
+}
+
+// newSeenSessionCache creates a cache holding up to capacity session IDs. A
+// capacity of 0 disables caching.
+func newSeenSessionCache(capacity int) *seenSessionCache {
+	return &seenSessionCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// contains reports whether sessionID was added and not yet evicted
+func (sc *seenSessionCache) contains(sessionID string) bool {
+	if sc.capacity <= 0 {
+		return false
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	elem, ok := sc.entries[sessionID]
+	if ok {
+		sc.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// add records sessionID, evicting the least recently used IDs beyond capacity
+func (sc *seenSessionCache) add(sessionID string) {
+	if sc.capacity <= 0 {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if elem, ok := sc.entries[sessionID]; ok {
+		sc.order.MoveToFront(elem)
+		return
+	}
+	sc.entries[sessionID] = sc.order.PushFront(sessionID)
+	for sc.order.Len() > sc.capacity {
+		oldest := sc.order.Back()
+		sc.order.Remove(oldest)
+		delete(sc.entries, oldest.Value.(string))
+	}
+}
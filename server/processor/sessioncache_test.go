@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestSeenSessionCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newSeenSessionCache(2)
+	cache.add("a")
+	cache.add("b")
+	if !cache.contains("a") { // a is now the most recently used
+		t.Fatal("a missing")
+	}
+	cache.add("c")
+
+	if cache.contains("b") {
+		t.Error("b should have been evicted")
+	}
+	if !cache.contains("a") || !cache.contains("c") {
+		t.Error("a and c should still be cached")
+	}
+}
+
+func TestSeenSessionCacheDisabled(t *testing.T) {
+	cache := newSeenSessionCache(0)
+	cache.add("a")
+	if cache.contains("a") {
+		t.Error("a zero-capacity cache must not remember sessions")
+	}
+}
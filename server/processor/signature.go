@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/base64"
+
+	"github.com/facto-ai/facto/server/shared/signature"
+)
+
+// Reasons an event fails ingest-time verification, used as the reason label
+// of facto_processor_events_rejected_total and the dead-letter headers
+const (
+	rejectHashMismatch     = "hash_mismatch"
+	rejectBadSignature     = "bad_signature"
+	rejectUnknownCanonical = "unknown_canonical_version"
+)
+
+// verifyEvent recomputes an event's canonical hash and checks its signature
+// over the canonical form. It returns the rejection reason, or "" when the
+// event verifies.
+func verifyEvent(event *FactoEvent) string {
+	form, _, err := canonicalForm(event)
+	if err != nil {
+		return rejectUnknownCanonical
+	}
+	if computeHash(form) != event.Proof.EventHash {
+		return rejectHashMismatch
+	}
+
+	pubKey, err := base64.StdEncoding.DecodeString(event.Proof.PublicKey)
+	if err != nil {
+		return rejectBadSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(event.Proof.Signature)
+	if err != nil {
+		return rejectBadSignature
+	}
+	if !signature.Verify(event.Proof.SigAlgo, pubKey, []byte(form), sig) {
+		return rejectBadSignature
+	}
+	return ""
+}
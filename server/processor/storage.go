@@ -3,42 +3,164 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/facto-ai/facto/server/shared/cqlconn"
+	"github.com/facto-ai/facto/server/shared/merkle"
+	"github.com/facto-ai/facto/server/shared/tracing"
 	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
+// genesisHash is the prev_hash of the first event in a session
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
 // maxBatchSize is the maximum number of statements per ScyllaDB batch
 // ScyllaDB default batch size warning is at 128KB, we limit to 50 statements
 // to stay well under the limit with typical event sizes
 const maxBatchSize = 50
 
+var (
+	storageUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "facto_storage_up",
+		Help: "Whether the last ScyllaDB ping succeeded (1) or failed (0)",
+	})
+
+	// storageUpValue mirrors storageUp so state changes can be logged once
+	storageUpValue atomic.Bool
+)
+
 // Storage handles ScyllaDB operations
 type Storage struct {
 	session *gocql.Session
+	opts    StorageOptions
+
+	// seenSessions holds sessions whose session_metadata row exists
+	seenSessions *seenSessionCache
 }
 
-// NewStorage creates a new storage instance
-func NewStorage(hosts []string) (*Storage, error) {
+// StorageOptions selects optional write behaviour
+type StorageOptions struct {
+	// NumConns is the number of connections per host (0 = driver default)
+	NumConns int
+	// MaxRequestsPerConn caps the requests in flight on each connection
+	// (0 = the driver's stream limit)
+	MaxRequestsPerConn int
+	// Keyspace holds the Facto tables
+	Keyspace string
+	// WriteConsistency is the session default; ReadConsistency is set on the
+	// processor's reads (session counts, dedup lookups, compaction)
+	ReadConsistency  gocql.Consistency
+	WriteConsistency gocql.Consistency
+	// AtomicWrites uses logged cross-table batches (see storeAtomicBatch)
+	AtomicWrites bool
+	// StoreCanonical records each event's canonical form in events_by_session
+	StoreCanonical bool
+	// Dedup skips events whose facto_id is already stored (see DedupSelect
+	// and DedupLWT)
+	Dedup string
+	// EventTTL expires the rows of the three event tables this many seconds
+	// after they are written; MerkleRootTTL does the same for Merkle roots
+	// and their indexes (0 = no expiry)
+	EventTTL      int
+	MerkleRootTTL int
+	// MerkleScheme builds every Merkle root (see BuildMerkleRoot) and is
+	// recorded with it
+	MerkleScheme merkle.Scheme
+	// AutoMigrate creates the keyspace and tables before connecting (see
+	// EnsureSchema), the keyspace with ReplicationFactor replicas
+	AutoMigrate       bool
+	ReplicationFactor int
+	// SessionCacheSize is how many session IDs are remembered as having a
+	// session_metadata row, sparing their later batches the insert
+	SessionCacheSize int
+}
+
+// Deduplication modes for StorageOptions.Dedup. JetStream redelivers messages
+// whose ack was lost, so without deduplication the same event is written
+// again and its hash lands in a second Merkle root.
+//
+// DedupSelect looks the batch's facto_ids up in events_by_facto_id before
+// writing. It costs one read per chunk of the batch but is best-effort: two
+// processors handling the same event concurrently can both miss the other's
+// write, so a duplicate can still slip through during redelivery races.
+//
+// DedupLWT claims each facto_id with an INSERT ... IF NOT EXISTS into
+// events_by_facto_id, which Paxos makes exact across processors. Every event
+// then costs a serial round trip (four replica round trips per claim), so
+// expect a large throughput drop. Claims are released if the rest of the
+// batch fails to store, so a retried event isn't mistaken for a duplicate;
+// a processor crash between claiming and storing can still leave a claimed
+// event without its other rows.
+const (
+	DedupOff    = "off"
+	DedupSelect = "select"
+	DedupLWT    = "lwt"
+)
+
+// newClusterConfig returns the cluster configuration for opts
+func newClusterConfig(hosts []string, opts StorageOptions) *gocql.ClusterConfig {
 	cluster := gocql.NewCluster(hosts...)
-	cluster.Keyspace = "facto"
-	cluster.Consistency = gocql.LocalQuorum
+	cluster.Keyspace = opts.Keyspace
+	cluster.Consistency = opts.WriteConsistency
 	cluster.Timeout = 10 * time.Second
 	cluster.ConnectTimeout = 30 * time.Second
+	if opts.NumConns > 0 {
+		cluster.NumConns = opts.NumConns
+	}
+	if opts.MaxRequestsPerConn > 0 {
+		// Dialed as gocql would without a Dialer
+		cluster.Dialer = &cqlconn.LimitDialer{
+			Dialer:      &net.Dialer{Timeout: cluster.ConnectTimeout, KeepAlive: cluster.SocketKeepalive},
+			MaxRequests: opts.MaxRequestsPerConn,
+		}
+	}
 	cluster.RetryPolicy = &gocql.ExponentialBackoffRetryPolicy{
 		Min:        100 * time.Millisecond,
 		Max:        10 * time.Second,
 		NumRetries: 5,
 	}
+	if tracing.Enabled() {
+		cluster.QueryObserver = tracing.CQLObserver{}
+		cluster.BatchObserver = tracing.CQLObserver{}
+	}
+	return cluster
+}
+
+// NewStorage creates a new storage instance
+func NewStorage(hosts []string, opts StorageOptions) (*Storage, error) {
+	cluster := newClusterConfig(hosts, opts)
+
+	if opts.AutoMigrate {
+		// The keyspace may not exist yet, so migrate over a session that
+		// isn't bound to it
+		bootstrap := *cluster
+		bootstrap.Keyspace = ""
+		session, err := bootstrap.CreateSession()
+		if err != nil {
+			return nil, err
+		}
+		if err := migrate(context.Background(), cqlSchemaSession{session}, opts); err != nil {
+			return nil, err
+		}
+	}
 
 	session, err := cluster.CreateSession()
 	if err != nil {
 		return nil, err
 	}
-
-	return &Storage{session: session}, nil
+	return &Storage{session: session, opts: opts, seenSessions: newSeenSessionCache(opts.SessionCacheSize)}, nil
 }
 
 // eventData holds pre-processed event data to avoid recomputation
@@ -57,12 +179,34 @@ type eventData struct {
 	seed          int64
 	maxTokens     int32
 	parentFactoID string
+	metaPresent   []string
+
+	// canonicalVersion is proof.canonical_version, nil when absent (V1)
+	canonicalVersion *int
+
+	// Set only with STORE_CANONICAL=true
+	canonicalForm     string
+	canonicalEncoding string
+
+	// ttl is the row TTL in seconds (0 = no expiry)
+	ttl int
 }
 
 // StoreBatch stores a batch of events using concurrent per-table batches
 // This allows processing 1000 events (3000 total inserts) by splitting into
-// 3 concurrent batches of 1000 inserts each, staying within ScyllaDB limits
-func (s *Storage) StoreBatch(ctx context.Context, events []FactoEvent) error {
+// 3 concurrent batches of 1000 inserts each, staying within ScyllaDB limits.
+//
+// With deduplication enabled, events whose facto_id is already stored or
+// repeats earlier in the batch are skipped and flagged in duplicate (nil when
+// deduplication is off). It returns the number of events inserted.
+func (s *Storage) StoreBatch(ctx context.Context, events []FactoEvent) (inserted int, duplicate []bool, err error) {
+	ctx, span := tracer.Start(ctx, "Storage.StoreBatch",
+		trace.WithAttributes(attribute.Int("facto.batch_size", len(events))))
+	defer func() {
+		span.SetAttributes(attribute.Int("facto.inserted", inserted))
+		tracing.EndSpan(span, err)
+	}()
+
 	// Pre-process all events once
 	processedEvents := make([]eventData, len(events))
 	for i, event := range events {
@@ -77,6 +221,7 @@ func (s *Storage) StoreBatch(ctx context.Context, events []FactoEvent) error {
 		var temperature float32
 		var seed int64
 		var maxTokens int32
+		var metaPresent []string
 
 		if event.ExecutionMeta.ModelID != nil {
 			modelID = *event.ExecutionMeta.ModelID
@@ -86,12 +231,15 @@ func (s *Storage) StoreBatch(ctx context.Context, events []FactoEvent) error {
 		}
 		if event.ExecutionMeta.Temperature != nil {
 			temperature = float32(*event.ExecutionMeta.Temperature)
+			metaPresent = append(metaPresent, "temperature")
 		}
 		if event.ExecutionMeta.Seed != nil {
 			seed = *event.ExecutionMeta.Seed
+			metaPresent = append(metaPresent, "seed")
 		}
 		if event.ExecutionMeta.MaxTokens != nil {
 			maxTokens = *event.ExecutionMeta.MaxTokens
+			metaPresent = append(metaPresent, "max_tokens")
 		}
 		sdkVersion = event.ExecutionMeta.SDKVersion
 		sdkLanguage = event.ExecutionMeta.SDKLanguage
@@ -116,33 +264,166 @@ func (s *Storage) StoreBatch(ctx context.Context, events []FactoEvent) error {
 			seed:          seed,
 			maxTokens:     maxTokens,
 			parentFactoID: parentFactoID,
+			metaPresent:   metaPresent,
+			ttl:           s.opts.EventTTL,
+		}
+		if version := int(event.Proof.CanonicalVersion); version != 0 {
+			processedEvents[i].canonicalVersion = &version
+		}
+
+		if s.opts.StoreCanonical {
+			processedEvents[i].snapshotCanonical()
+		}
+	}
+
+	// In LWT mode the claims write events_by_facto_id, so it is left out of
+	// the batches below
+	claimed := s.opts.Dedup == DedupLWT
+	switch s.opts.Dedup {
+	case DedupSelect:
+		duplicate, err = s.findDuplicates(ctx, processedEvents)
+	case DedupLWT:
+		duplicate, err = s.claimFactoIDs(ctx, processedEvents)
+	}
+	if err != nil {
+		log.Error().Err(err).Int("batch_size", len(events)).Msg("Failed to deduplicate batch")
+		return 0, nil, err
+	}
+	if duplicate != nil {
+		fresh := make([]eventData, 0, len(processedEvents))
+		for i, e := range processedEvents {
+			if !duplicate[i] {
+				fresh = append(fresh, e)
+			}
 		}
+		processedEvents = fresh
+	}
+	if len(processedEvents) == 0 {
+		return 0, duplicate, nil
 	}
 
 	// Execute 3 table batches concurrently
-	g, ctx := errgroup.WithContext(ctx)
+	g, gctx := errgroup.WithContext(ctx)
+
+	if s.opts.AtomicWrites {
+		// All three event tables in cross-table logged batches
+		g.Go(func() error {
+			return s.storeAtomicBatch(gctx, processedEvents, !claimed)
+		})
+	} else {
+		// Batch 1: Main events table
+		g.Go(func() error {
+			return s.storeEventsBatch(gctx, processedEvents)
+		})
+
+		// Batch 2: events_by_facto_id lookup table
+		if !claimed {
+			g.Go(func() error {
+				return s.storeByFactoIDBatch(gctx, processedEvents)
+			})
+		}
+
+		// Batch 3: events_by_session lookup table
+		g.Go(func() error {
+			return s.storeBySessionBatch(gctx, processedEvents)
+		})
+	}
 
-	// Batch 1: Main events table
+	// Session metadata rows for sessions seen for the first time
 	g.Go(func() error {
-		return s.storeEventsBatch(ctx, processedEvents)
+		return s.ensureSessionMetadata(gctx, processedEvents)
 	})
 
-	// Batch 2: events_by_facto_id lookup table
+	// Session start markers for genesis events
 	g.Go(func() error {
-		return s.storeByFactoIDBatch(ctx, processedEvents)
+		return s.storeSessionStarts(gctx, processedEvents)
 	})
 
-	// Batch 3: events_by_session lookup table
+	// Per-agent session bounds for the session listing
 	g.Go(func() error {
-		return s.storeBySessionBatch(ctx, processedEvents)
+		return s.storeSessionsByAgent(gctx, processedEvents)
 	})
 
 	if err := g.Wait(); err != nil {
 		log.Error().Err(err).Int("batch_size", len(events)).Msg("Failed to store batch")
-		return err
+		if claimed {
+			s.releaseClaims(ctx, processedEvents)
+		}
+		return 0, nil, err
 	}
 
-	return nil
+	return len(processedEvents), duplicate, nil
+}
+
+// findDuplicates flags events whose facto_id is already in
+// events_by_facto_id or repeats earlier in the batch
+func (s *Storage) findDuplicates(ctx context.Context, events []eventData) ([]bool, error) {
+	stored := make(map[string]bool)
+	for i := 0; i < len(events); i += maxBatchSize {
+		end := i + maxBatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		ids := make([]string, 0, end-i)
+		for _, e := range events[i:end] {
+			ids = append(ids, e.event.FactoID)
+		}
+
+		iter := s.session.Query(`
+			SELECT facto_id FROM events_by_facto_id WHERE facto_id IN ?
+		`, ids).WithContext(ctx).Consistency(s.opts.ReadConsistency).Iter()
+		var factoID string
+		for iter.Scan(&factoID) {
+			stored[factoID] = true
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	duplicate := make([]bool, len(events))
+	for i, e := range events {
+		duplicate[i] = stored[e.event.FactoID]
+		stored[e.event.FactoID] = true
+	}
+	return duplicate, nil
+}
+
+// claimFactoIDs writes each event's events_by_facto_id row with IF NOT
+// EXISTS and flags the events whose row was already there. Claims made before
+// an error are released.
+func (s *Storage) claimFactoIDs(ctx context.Context, events []eventData) ([]bool, error) {
+	duplicate := make([]bool, len(events))
+	for i, e := range events {
+		applied, err := s.session.Query(insertByFactoIDCQL+` IF NOT EXISTS`+usingTTL(e.ttl), byFactoIDValues(e)...).
+			WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			var claimed []eventData
+			for j := range events[:i] {
+				if !duplicate[j] {
+					claimed = append(claimed, events[j])
+				}
+			}
+			s.releaseClaims(ctx, claimed)
+			return nil, err
+		}
+		duplicate[i] = !applied
+	}
+	return duplicate, nil
+}
+
+// releaseClaims deletes events_by_facto_id rows claimed by claimFactoIDs, so
+// events whose batch failed to store aren't taken for duplicates on
+// redelivery. It is best-effort; failures are logged.
+func (s *Storage) releaseClaims(ctx context.Context, events []eventData) {
+	for _, e := range events {
+		if _, err := s.session.Query(`
+			DELETE FROM events_by_facto_id WHERE facto_id = ? IF EXISTS
+		`, e.event.FactoID).WithContext(ctx).MapScanCAS(map[string]interface{}{}); err != nil {
+			log.Warn().Err(err).Str("facto_id", e.event.FactoID).Msg("Failed to release facto_id claim")
+		}
+	}
 }
 
 // storeEventsBatch inserts into the main events table
@@ -157,24 +438,7 @@ func (s *Storage) storeEventsBatch(ctx context.Context, events []eventData) erro
 
 		batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
 		for _, e := range chunk {
-			batch.Query(`
-				INSERT INTO events (
-					agent_id, date, facto_id, session_id, parent_facto_id,
-					action_type, status, input_data, output_data,
-					model_id, model_hash, temperature, seed, max_tokens, tool_calls,
-					sdk_version, sdk_language, tags,
-					signature, public_key, prev_hash, event_hash,
-					started_at, completed_at, received_at
-				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-			`,
-				e.event.AgentID, e.eventDate, e.event.FactoID, e.event.SessionID, e.parentFactoID,
-				e.event.ActionType, e.event.Status, e.inputData, e.outputData,
-				e.modelID, e.modelHash, e.temperature, e.seed, e.maxTokens, string(e.toolCalls),
-				e.sdkVersion, e.sdkLanguage, e.event.ExecutionMeta.Tags,
-				[]byte(e.event.Proof.Signature), []byte(e.event.Proof.PublicKey),
-				e.event.Proof.PrevHash, e.event.Proof.EventHash,
-				time.Unix(0, e.event.StartedAt), e.completedTime, time.Now(),
-			)
+			addEventInsert(batch, e)
 		}
 
 		if err := s.session.ExecuteBatch(batch); err != nil {
@@ -195,24 +459,7 @@ func (s *Storage) storeByFactoIDBatch(ctx context.Context, events []eventData) e
 
 		batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
 		for _, e := range chunk {
-			batch.Query(`
-				INSERT INTO events_by_facto_id (
-					facto_id, agent_id, date, completed_at, session_id,
-					action_type, status, input_data, output_data,
-					model_id, model_hash, temperature, seed, max_tokens, tool_calls,
-					sdk_version, sdk_language, tags,
-					signature, public_key, prev_hash, event_hash,
-					parent_facto_id, started_at, received_at
-				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-			`,
-				e.event.FactoID, e.event.AgentID, e.eventDate, e.completedTime, e.event.SessionID,
-				e.event.ActionType, e.event.Status, e.inputData, e.outputData,
-				e.modelID, e.modelHash, e.temperature, e.seed, e.maxTokens, string(e.toolCalls),
-				e.sdkVersion, e.sdkLanguage, e.event.ExecutionMeta.Tags,
-				[]byte(e.event.Proof.Signature), []byte(e.event.Proof.PublicKey),
-				e.event.Proof.PrevHash, e.event.Proof.EventHash,
-				e.parentFactoID, time.Unix(0, e.event.StartedAt), time.Now(),
-			)
+			addByFactoIDInsert(batch, e)
 		}
 
 		if err := s.session.ExecuteBatch(batch); err != nil {
@@ -233,26 +480,7 @@ func (s *Storage) storeBySessionBatch(ctx context.Context, events []eventData) e
 
 		batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
 		for _, e := range chunk {
-			batch.Query(`
-				INSERT INTO events_by_session (
-					session_id, completed_at, facto_id, agent_id,
-					action_type, status, event_hash,
-					input_data, output_data,
-					model_id, temperature,
-					sdk_version, sdk_language, tags,
-					signature, public_key, prev_hash,
-					parent_facto_id, started_at, received_at
-				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-			`,
-				e.event.SessionID, e.completedTime, e.event.FactoID, e.event.AgentID,
-				e.event.ActionType, e.event.Status, e.event.Proof.EventHash,
-				e.inputData, e.outputData,
-				e.modelID, e.temperature,
-				e.sdkVersion, e.sdkLanguage, e.event.ExecutionMeta.Tags,
-				[]byte(e.event.Proof.Signature), []byte(e.event.Proof.PublicKey),
-				e.event.Proof.PrevHash,
-				e.parentFactoID, time.Unix(0, e.event.StartedAt), time.Now(),
-			)
+			addBySessionInsert(batch, e)
 		}
 
 		if err := s.session.ExecuteBatch(batch); err != nil {
@@ -262,18 +490,414 @@ func (s *Storage) storeBySessionBatch(ctx context.Context, events []eventData) e
 	return nil
 }
 
-// StoreMerkleRoot stores a Merkle root entry
-func (s *Storage) StoreMerkleRoot(ctx context.Context, bucketTime time.Time, rootHash string, eventCount int, firstFactoID, lastFactoID string, eventHashes []string) error {
+// snapshotCanonical records the canonical form the event was hashed and
+// signed over, for STORE_CANONICAL. An unknown version leaves no snapshot;
+// with VERIFY_INGEST off such an event is stored unverified.
+func (e *eventData) snapshotCanonical() {
+	if form, version, err := canonicalForm(&e.event); err == nil {
+		e.canonicalForm = form
+		e.canonicalEncoding = string(version.Encoding())
+	}
+}
+
+// addEventInsert queues an events table insert
+func addEventInsert(batch *gocql.Batch, e eventData) {
+	batch.Query(`
+		INSERT INTO events (
+			agent_id, date, facto_id, session_id, parent_facto_id,
+			action_type, status, input_data, output_data,
+			model_id, model_hash, temperature, seed, max_tokens, tool_calls, meta_present,
+			sdk_version, sdk_language, tags, custom_fields,
+			signature, public_key, sig_algo, canonical_version, prev_hash, event_hash,
+			started_at, completed_at, received_at, stream_seq
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`+usingTTL(e.ttl),
+		e.event.AgentID, e.eventDate, e.event.FactoID, e.event.SessionID, e.parentFactoID,
+		e.event.ActionType, e.event.Status, e.inputData, e.outputData,
+		e.modelID, e.modelHash, e.temperature, e.seed, e.maxTokens, string(e.toolCalls), e.metaPresent,
+		e.sdkVersion, e.sdkLanguage, e.event.ExecutionMeta.Tags, e.event.CustomFields,
+		[]byte(e.event.Proof.Signature), []byte(e.event.Proof.PublicKey), e.event.Proof.SigAlgo, e.canonicalVersion,
+		e.event.Proof.PrevHash, e.event.Proof.EventHash,
+		time.Unix(0, e.event.StartedAt), e.completedTime, time.Now(), int64(e.event.StreamSeq),
+	)
+}
+
+// insertByFactoIDCQL inserts an events_by_facto_id row; byFactoIDValues
+// returns its bind values
+const insertByFactoIDCQL = `
+		INSERT INTO events_by_facto_id (
+			facto_id, agent_id, date, completed_at, session_id,
+			action_type, status, input_data, output_data,
+			model_id, model_hash, temperature, seed, max_tokens, tool_calls, meta_present,
+			sdk_version, sdk_language, tags, custom_fields,
+			signature, public_key, sig_algo, canonical_version, prev_hash, event_hash,
+			parent_facto_id, started_at, received_at, stream_seq
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// addByFactoIDInsert queues an events_by_facto_id insert
+func addByFactoIDInsert(batch *gocql.Batch, e eventData) {
+	batch.Query(insertByFactoIDCQL+usingTTL(e.ttl), byFactoIDValues(e)...)
+}
+
+func byFactoIDValues(e eventData) []interface{} {
+	return []interface{}{
+		e.event.FactoID, e.event.AgentID, e.eventDate, e.completedTime, e.event.SessionID,
+		e.event.ActionType, e.event.Status, e.inputData, e.outputData,
+		e.modelID, e.modelHash, e.temperature, e.seed, e.maxTokens, string(e.toolCalls), e.metaPresent,
+		e.sdkVersion, e.sdkLanguage, e.event.ExecutionMeta.Tags, e.event.CustomFields,
+		[]byte(e.event.Proof.Signature), []byte(e.event.Proof.PublicKey), e.event.Proof.SigAlgo, e.canonicalVersion,
+		e.event.Proof.PrevHash, e.event.Proof.EventHash,
+		e.parentFactoID, time.Unix(0, e.event.StartedAt), time.Now(), int64(e.event.StreamSeq),
+	}
+}
+
+// addBySessionInsert queues an events_by_session insert
+func addBySessionInsert(batch *gocql.Batch, e eventData) {
+	batch.Query(`
+		INSERT INTO events_by_session (
+			session_id, completed_at, facto_id, agent_id,
+			action_type, status, event_hash,
+			input_data, output_data,
+			model_id, model_hash, temperature, seed, max_tokens, tool_calls, meta_present,
+			sdk_version, sdk_language, tags, custom_fields,
+			signature, public_key, sig_algo, canonical_version, prev_hash,
+			parent_facto_id, started_at, received_at, stream_seq
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`+usingTTL(e.ttl),
+		e.event.SessionID, e.completedTime, e.event.FactoID, e.event.AgentID,
+		e.event.ActionType, e.event.Status, e.event.Proof.EventHash,
+		e.inputData, e.outputData,
+		e.modelID, e.modelHash, e.temperature, e.seed, e.maxTokens, string(e.toolCalls), e.metaPresent,
+		e.sdkVersion, e.sdkLanguage, e.event.ExecutionMeta.Tags, e.event.CustomFields,
+		[]byte(e.event.Proof.Signature), []byte(e.event.Proof.PublicKey), e.event.Proof.SigAlgo, e.canonicalVersion,
+		e.event.Proof.PrevHash,
+		e.parentFactoID, time.Unix(0, e.event.StartedAt), time.Now(), int64(e.event.StreamSeq),
+	)
+
+	// A separate statement, so rows without a snapshot don't get null cells.
+	// It carries the row's TTL so the snapshot doesn't outlive the event.
+	if e.canonicalForm != "" {
+		batch.Query(`
+			UPDATE events_by_session`+usingTTL(e.ttl)+`
+			SET canonical_form = ?, canonical_encoding = ?
+			WHERE session_id = ? AND completed_at = ? AND facto_id = ?
+		`, e.canonicalForm, e.canonicalEncoding, e.event.SessionID, e.completedTime, e.event.FactoID)
+	}
+}
+
+// usingTTL returns the USING TTL clause for a TTL in seconds, or "" for no
+// expiry. The TTL is fixed per process, so each statement is still prepared
+// once.
+func usingTTL(ttl int) string {
+	if ttl <= 0 {
+		return ""
+	}
+	return " USING TTL " + strconv.Itoa(ttl)
+}
+
+// atomicChunkSize is the number of events per logged batch in atomic mode.
+// Each event contributes three (four with STORE_CANONICAL) statements, so this
+// keeps batches about the size of the per-table unlogged batches.
+const atomicChunkSize = maxBatchSize / 3
+
+// storeAtomicBatch writes each chunk's rows for all three event tables in one
+// logged batch, so a chunk is either in every table or in none. Logged batches
+// go through the batchlog (an extra replicated write and coordinator round
+// trip) and chunks are written sequentially rather than per table in
+// parallel, so expect noticeably lower throughput than the default mode.
+// withFactoID is false when events_by_facto_id rows were already written by
+// LWT claims, which can't join a multi-partition batch.
+func (s *Storage) storeAtomicBatch(ctx context.Context, events []eventData, withFactoID bool) error {
+	newBatch := func() *gocql.Batch {
+		return s.session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+	}
+	return writeAtomicChunks(events, withFactoID, newBatch, s.session.ExecuteBatch)
+}
+
+// writeAtomicChunks adds each chunk's rows for every event table to one batch
+// from newBatch and executes it, stopping at the first failed chunk
+func writeAtomicChunks(events []eventData, withFactoID bool, newBatch func() *gocql.Batch, execute func(*gocql.Batch) error) error {
+	for i := 0; i < len(events); i += atomicChunkSize {
+		end := i + atomicChunkSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		batch := newBatch()
+		for _, e := range events[i:end] {
+			addEventInsert(batch, e)
+			if withFactoID {
+				addByFactoIDInsert(batch, e)
+			}
+			addBySessionInsert(batch, e)
+		}
+
+		if err := execute(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureSessionMetadata creates a session_metadata row for each session in the
+// batch that isn't in the seen-session cache. IF NOT EXISTS keeps metadata
+// edited through the API from being overwritten; the API writes the table
+// with lightweight transactions too, so the two never race.
+func (s *Storage) ensureSessionMetadata(ctx context.Context, events []eventData) error {
+	for _, e := range events {
+		if s.seenSessions.contains(e.event.SessionID) {
+			continue
+		}
+
+		now := time.Now()
+		if _, err := s.session.Query(`
+			INSERT INTO session_metadata (
+				session_id, agent_id, tags, description, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?)
+			IF NOT EXISTS
+		`,
+			e.event.SessionID, e.event.AgentID, map[string]string{}, "", now, now,
+		).WithContext(ctx).MapScanCAS(map[string]interface{}{}); err != nil {
+			return err
+		}
+		s.seenSessions.add(e.event.SessionID)
+	}
+	return nil
+}
+
+// storeSessionStarts records a session_starts row for every genesis event
+// (prev_hash of all zeros). IF NOT EXISTS keeps the first marker on replays.
+func (s *Storage) storeSessionStarts(ctx context.Context, events []eventData) error {
+	for _, e := range events {
+		if e.event.Proof.PrevHash != genesisHash {
+			continue
+		}
+
+		if _, err := s.session.Query(`
+			INSERT INTO session_starts (
+				session_id, agent_id, first_facto_id, started_at
+			) VALUES (?, ?, ?, ?)
+			IF NOT EXISTS
+		`,
+			e.event.SessionID, e.event.AgentID, e.event.FactoID, time.Unix(0, e.event.StartedAt),
+		).WithContext(ctx).MapScanCAS(map[string]interface{}{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failedStatuses are the event statuses that mark a session as failed in
+// sessions_by_agent
+var failedStatuses = map[string]bool{"error": true, "failed": true}
+
+// sessionBounds is one session's share of a batch
+type sessionBounds struct {
+	agentID     string
+	first, last time.Time
+	failed      bool
+}
+
+// storeSessionsByAgent widens each session's sessions_by_agent row to cover
+// the batch's events and sets has_failures once any event failed. Like
+// UpdateAgentSummaries the row is read, then written, so concurrent flushes of
+// one session can briefly lose a widening. Rewriting it is idempotent, so a
+// redelivered batch leaves it unchanged.
+func (s *Storage) storeSessionsByAgent(ctx context.Context, events []eventData) error {
+	sessions := make(map[string]*sessionBounds)
+	for _, e := range events {
+		failed := failedStatuses[e.event.Status]
+		bounds, ok := sessions[e.event.SessionID]
+		if !ok {
+			sessions[e.event.SessionID] = &sessionBounds{
+				agentID: e.event.AgentID,
+				first:   e.completedTime,
+				last:    e.completedTime,
+				failed:  failed,
+			}
+			continue
+		}
+		if e.completedTime.Before(bounds.first) {
+			bounds.first = e.completedTime
+		}
+		if e.completedTime.After(bounds.last) {
+			bounds.last = e.completedTime
+		}
+		bounds.failed = bounds.failed || failed
+	}
+
+	for sessionID, bounds := range sessions {
+		var first, last time.Time
+		var failed bool
+		err := s.session.Query(`
+			SELECT first_event_at, last_event_at, has_failures FROM sessions_by_agent
+			WHERE agent_id = ? AND session_id = ?
+		`, bounds.agentID, sessionID).WithContext(ctx).Consistency(s.opts.ReadConsistency).Scan(&first, &last, &failed)
+		if err != nil && err != gocql.ErrNotFound {
+			return err
+		}
+		if !first.IsZero() && first.Before(bounds.first) {
+			bounds.first = first
+		}
+		if last.After(bounds.last) {
+			bounds.last = last
+		}
+
+		if err := s.session.Query(`
+			INSERT INTO sessions_by_agent (
+				agent_id, session_id, first_event_at, last_event_at, has_failures
+			) VALUES (?, ?, ?, ?, ?)`+usingTTL(s.opts.EventTTL),
+			bounds.agentID, sessionID, bounds.first, bounds.last, bounds.failed || failed,
+		).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSessionEventCount returns the number of stored events in a session
+func (s *Storage) GetSessionEventCount(ctx context.Context, sessionID string) (int64, error) {
+	var count int64
+	err := s.session.Query(`
+		SELECT event_count FROM session_summaries WHERE session_id = ?
+	`, sessionID).WithContext(ctx).Consistency(s.opts.ReadConsistency).Scan(&count)
+	if err == gocql.ErrNotFound {
+		return 0, nil
+	}
+	return count, err
+}
+
+// IncrementSessionEventCounts adds stored-event counts to session_summaries
+func (s *Storage) IncrementSessionEventCounts(ctx context.Context, counts map[string]int64) error {
+	for sessionID, n := range counts {
+		if err := s.session.Query(`
+			UPDATE session_summaries SET event_count = event_count + ? WHERE session_id = ?
+		`, n, sessionID).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// agentSummary is one agent's share of a batch
+type agentSummary struct {
+	count       int64
+	first, last time.Time
+}
+
+// UpdateAgentSummaries adds a batch's events to agent_summaries and widens
+// each agent's agent_event_ranges row to cover them. The range is read, then
+// written, so two processors or workers flushing the same agent at once can
+// lose a widening; the next flush for that agent repairs the latest time.
+func (s *Storage) UpdateAgentSummaries(ctx context.Context, events []FactoEvent) error {
+	return upsertAgentSummaries(ctx, cqlAgentSummaryTables{s}, events)
+}
+
+// agentSummaryTables is where upsertAgentSummaries keeps per-agent counts
+// and time ranges; cqlAgentSummaryTables outside tests
+type agentSummaryTables interface {
+	AddEventCount(ctx context.Context, agentID string, n int64) error
+	// EventRange returns zero times for an agent with no range yet
+	EventRange(ctx context.Context, agentID string) (first, last time.Time, err error)
+	SetEventRange(ctx context.Context, agentID string, first, last time.Time) error
+}
+
+// upsertAgentSummaries folds a batch into each agent's count and range
+func upsertAgentSummaries(ctx context.Context, tables agentSummaryTables, events []FactoEvent) error {
+	summaries := make(map[string]*agentSummary)
+	for _, event := range events {
+		completed := time.Unix(0, event.CompletedAt)
+		summary, ok := summaries[event.AgentID]
+		if !ok {
+			summaries[event.AgentID] = &agentSummary{count: 1, first: completed, last: completed}
+			continue
+		}
+		summary.count++
+		if completed.Before(summary.first) {
+			summary.first = completed
+		}
+		if completed.After(summary.last) {
+			summary.last = completed
+		}
+	}
+
+	for agentID, summary := range summaries {
+		if err := tables.AddEventCount(ctx, agentID, summary.count); err != nil {
+			return err
+		}
+
+		first, last, err := tables.EventRange(ctx, agentID)
+		if err != nil {
+			return err
+		}
+		if !first.IsZero() && first.Before(summary.first) {
+			summary.first = first
+		}
+		if last.After(summary.last) {
+			summary.last = last
+		}
+
+		if err := tables.SetEventRange(ctx, agentID, summary.first, summary.last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cqlAgentSummaryTables keeps agent summaries in agent_summaries (a counter
+// table) and agent_event_ranges
+type cqlAgentSummaryTables struct {
+	s *Storage
+}
+
+func (t cqlAgentSummaryTables) AddEventCount(ctx context.Context, agentID string, n int64) error {
+	return t.s.session.Query(`
+		UPDATE agent_summaries SET event_count = event_count + ? WHERE agent_id = ?
+	`, n, agentID).WithContext(ctx).Exec()
+}
+
+func (t cqlAgentSummaryTables) EventRange(ctx context.Context, agentID string) (first, last time.Time, err error) {
+	err = t.s.session.Query(`
+		SELECT first_event_at, last_event_at FROM agent_event_ranges WHERE agent_id = ?
+	`, agentID).WithContext(ctx).Consistency(t.s.opts.ReadConsistency).Scan(&first, &last)
+	if err == gocql.ErrNotFound {
+		return time.Time{}, time.Time{}, nil
+	}
+	return first, last, err
+}
+
+func (t cqlAgentSummaryTables) SetEventRange(ctx context.Context, agentID string, first, last time.Time) error {
+	return t.s.session.Query(`
+		INSERT INTO agent_event_ranges (agent_id, first_event_at, last_event_at) VALUES (?, ?, ?)
+	`, agentID, first, last).WithContext(ctx).Exec()
+}
+
+// Merkle root types stored in merkle_roots.root_type
+const (
+	RootTypeBatch    = "batch"    // One root per processed batch
+	RootTypeInterval = "interval" // Consolidated root per commitment interval, in merkle_interval_roots
+	RootTypeDaily    = "daily"    // Compacted root over a completed day's batch roots
+)
+
+// BuildMerkleRoot returns the root of the Merkle tree over hashes, built with
+// the scheme StoreMerkleRoot records
+func (s *Storage) BuildMerkleRoot(hashes []string) string {
+	tree, _ := merkle.Build(context.Background(), hashes, s.opts.MerkleScheme)
+	return tree.Root()
+}
+
+// StoreMerkleRoot stores a Merkle root entry built with BuildMerkleRoot
+func (s *Storage) StoreMerkleRoot(ctx context.Context, rootType string, bucketTime time.Time, rootHash string, eventCount int, firstFactoID, lastFactoID string, eventHashes []string) error {
 	date := bucketTime.UTC().Truncate(24 * time.Hour)
 
 	err := s.session.Query(`
 		INSERT INTO merkle_roots (
 			date, bucket_time, root_hash, event_count,
-			first_facto_id, last_facto_id, event_hashes, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`,
+			first_facto_id, last_facto_id, event_hashes, root_type, tree_scheme, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`+usingTTL(s.opts.MerkleRootTTL),
 		date, bucketTime, rootHash, eventCount,
-		firstFactoID, lastFactoID, eventHashes, time.Now(),
+		firstFactoID, lastFactoID, eventHashes, rootType, string(s.opts.MerkleScheme), time.Now(),
 	).WithContext(ctx).Exec()
 
 	if err != nil {
@@ -281,9 +905,450 @@ func (s *Storage) StoreMerkleRoot(ctx context.Context, bucketTime time.Time, roo
 		return err
 	}
 
+	if err := s.storeRootIndex(ctx, rootType, date, bucketTime, rootHash, eventHashes); err != nil {
+		log.Error().Err(err).Str("root_hash", rootHash).Msg("Failed to index Merkle root")
+		return err
+	}
+
+	if err := s.session.Query(`
+		INSERT INTO merkle_roots_by_hash (root_hash, date, bucket_time) VALUES (?, ?, ?)
+	`+usingTTL(s.opts.MerkleRootTTL), rootHash, date, bucketTime).WithContext(ctx).Exec(); err != nil {
+		log.Error().Err(err).Str("root_hash", rootHash).Msg("Failed to index Merkle root")
+		return err
+	}
+
+	return nil
+}
+
+// StoreAnchorReceipt saves an external anchor receipt on the root stored at
+// bucketTime. The receipt expires with the root. Roots deleted or replaced
+// since they were anchored (e.g. by compaction) are left alone.
+func (s *Storage) StoreAnchorReceipt(ctx context.Context, bucketTime time.Time, rootHash, receipt string) error {
+	date := bucketTime.UTC().Truncate(24 * time.Hour)
+
+	ttl, found, err := s.merkleRootTTL(ctx, date, bucketTime, rootHash)
+	if err != nil || !found {
+		return err
+	}
+
+	return s.session.Query(`
+		UPDATE merkle_roots`+usingTTL(ttl)+` SET anchor_receipt = ? WHERE date = ? AND bucket_time = ?
+	`, receipt, date, bucketTime).WithContext(ctx).Exec()
+}
+
+// StoreRootTimestamp saves an RFC 3161 timestamp token on the root stored at
+// bucketTime, like StoreAnchorReceipt
+func (s *Storage) StoreRootTimestamp(ctx context.Context, bucketTime time.Time, rootHash string, ts *RootTimestamp) error {
+	date := bucketTime.UTC().Truncate(24 * time.Hour)
+
+	ttl, found, err := s.merkleRootTTL(ctx, date, bucketTime, rootHash)
+	if err != nil || !found {
+		return err
+	}
+
+	return s.session.Query(`
+		UPDATE merkle_roots`+usingTTL(ttl)+` SET tsa_token = ?, tsa_time = ? WHERE date = ? AND bucket_time = ?
+	`, ts.Token, ts.GenTime, date, bucketTime).WithContext(ctx).Exec()
+}
+
+// rootChainBatch is the merkle_root_chain row batch roots are linked through
+const rootChainBatch = "batch"
+
+// logNodeChunkBits sets how many merkle_log_nodes indexes share a partition
+// per level: 2^16
+const logNodeChunkBits = 16
+
+// maxRootLinkAttempts bounds how often LinkBatchRoot retries after another
+// processor moved the chain head
+const maxRootLinkAttempts = 10
+
+// LinkBatchRoot appends the batch root stored at bucketTime to the global
+// root chain and the batch log. It records the chain head as the root's
+// prev_root_hash, then moves the head and the log to the root with a
+// lightweight transaction. state is the caller's guess at the current chain
+// row (nil when unknown); a wrong guess costs an extra round. It returns the
+// new chain row and the prev_root_hash stored.
+func (s *Storage) LinkBatchRoot(ctx context.Context, bucketTime time.Time, rootHash string, state *RootChainState) (*RootChainState, string, error) {
+	date := bucketTime.UTC().Truncate(24 * time.Hour)
+
+	ttl, found, err := s.merkleRootTTL(ctx, date, bucketTime, rootHash)
+	if err != nil {
+		return nil, "", err
+	}
+	if !found {
+		return nil, "", fmt.Errorf("merkle root %s not found at %s", rootHash, bucketTime)
+	}
+
+	if state == nil {
+		if state, err = s.getRootChain(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for attempt := 0; attempt < maxRootLinkAttempts; attempt++ {
+		head := ""
+		if state != nil {
+			head = state.RootHash
+		}
+		if err := s.session.Query(`
+			UPDATE merkle_roots`+usingTTL(ttl)+` SET prev_root_hash = ? WHERE date = ? AND bucket_time = ?
+		`, head, date, bucketTime).WithContext(ctx).Exec(); err != nil {
+			return nil, "", err
+		}
+
+		var next *RootChainState
+		var query *gocql.Query
+		if state == nil {
+			next = (&RootChainState{}).appendLeaf(rootHash)
+			query = s.session.Query(`
+				INSERT INTO merkle_root_chain (chain, root_hash, date, bucket_time, updated_at, log_size, log_frontier, log_tail)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS
+			`, rootChainBatch, rootHash, date, bucketTime, time.Now(), next.LogSize, next.LogFrontier, next.LogTail)
+		} else {
+			next = state.appendLeaf(rootHash)
+			query = s.session.Query(`
+				UPDATE merkle_root_chain
+				SET root_hash = ?, date = ?, bucket_time = ?, updated_at = ?, log_size = ?, log_frontier = ?, log_tail = ?
+				WHERE chain = ? IF root_hash = ?
+			`, rootHash, date, bucketTime, time.Now(), next.LogSize, next.LogFrontier, next.LogTail, rootChainBatch, head)
+		}
+
+		applied, err := query.WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return nil, "", err
+		}
+		if applied {
+			// Finish the previous append too, in case its processor stopped
+			// before writing its nodes; the writes are idempotent
+			if state != nil {
+				if err := s.storeLogAppend(ctx, state); err != nil {
+					return nil, "", err
+				}
+			}
+			if err := s.storeLogAppend(ctx, next); err != nil {
+				return nil, "", err
+			}
+			if err := s.session.Query(`
+				UPDATE merkle_roots`+usingTTL(ttl)+` SET log_root = ?, log_size = ? WHERE date = ? AND bucket_time = ?
+			`, next.logRoot(), next.LogSize, date, bucketTime).WithContext(ctx).Exec(); err != nil {
+				return nil, "", err
+			}
+			return next, head, nil
+		}
+
+		// Another processor linked a root first; chain onto it instead
+		if state, err = s.getRootChain(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+	return nil, "", fmt.Errorf("merkle root chain head kept moving after %d attempts", maxRootLinkAttempts)
+}
+
+// getRootChain reads the merkle_root_chain row at serial consistency, so it
+// sees the latest lightweight transaction. It returns nil when no root has
+// been linked yet. Rows written before the batch log existed read as an
+// empty log.
+func (s *Storage) getRootChain(ctx context.Context) (*RootChainState, error) {
+	var state RootChainState
+	if err := s.session.Query(`
+		SELECT root_hash, log_size, log_frontier, log_tail FROM merkle_root_chain WHERE chain = ?
+	`, rootChainBatch).WithContext(ctx).Consistency(gocql.Consistency(gocql.Serial)).Scan(
+		&state.RootHash, &state.LogSize, &state.LogFrontier, &state.LogTail,
+	); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(state.LogFrontier) != bits.OnesCount64(uint64(state.LogSize)) {
+		return nil, fmt.Errorf("merkle log frontier has %d hashes for size %d", len(state.LogFrontier), state.LogSize)
+	}
+	return &state, nil
+}
+
+// storeLogAppend writes the log nodes completed by the append that produced
+// state, and indexes its log root. Log data never expires: the log outlives
+// the batch roots it commits to.
+func (s *Storage) storeLogAppend(ctx context.Context, state *RootChainState) error {
+	if state.LogSize == 0 {
+		return nil
+	}
+
+	batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+	index := state.LogSize - 1
+	for level, hash := range state.LogTail {
+		batch.Query(`
+			INSERT INTO merkle_log_nodes (log, level, chunk, idx, hash) VALUES (?, ?, ?, ?, ?)
+		`, rootChainBatch, level, index>>logNodeChunkBits, index, hash)
+		index >>= 1
+	}
+	if err := s.session.ExecuteBatch(batch); err != nil {
+		return err
+	}
+
+	return s.session.Query(`
+		INSERT INTO merkle_log_roots (log_root, log, log_size, root_hash, created_at) VALUES (?, ?, ?, ?, ?)
+	`, state.logRoot(), rootChainBatch, state.LogSize, state.RootHash, time.Now()).WithContext(ctx).Exec()
+}
+
+// merkleRootTTL returns the remaining TTL of the root stored at
+// (date, bucketTime), so cells added later expire with it. found is false
+// when that row no longer holds rootHash.
+func (s *Storage) merkleRootTTL(ctx context.Context, date, bucketTime time.Time, rootHash string) (ttl int, found bool, err error) {
+	var storedHash string
+	if err := s.session.Query(`
+		SELECT root_hash, TTL(root_hash) FROM merkle_roots WHERE date = ? AND bucket_time = ?
+	`, date, bucketTime).WithContext(ctx).Consistency(s.opts.ReadConsistency).Scan(&storedHash, &ttl); err != nil {
+		if err == gocql.ErrNotFound {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return ttl, storedHash == rootHash, nil
+}
+
+// rootChainInterval is the merkle_root_chain row holding the end of the last
+// committed interval window. Advancing it with a lightweight transaction is
+// what claims a window, so processors never commit overlapping intervals.
+const rootChainInterval = "interval"
+
+// IntervalRoot is a merkle_interval_roots row: one root over the batch roots
+// stored in (WindowStart, BucketTime]
+type IntervalRoot struct {
+	WindowStart  time.Time
+	BucketTime   time.Time
+	RootHash     string
+	FirstFactoID string
+	LastFactoID  string
+	EventHashes  []string
+}
+
+// GetLastIntervalEnd returns the end of the last committed interval window,
+// read at serial consistency, or the zero time before the first commitment
+func (s *Storage) GetLastIntervalEnd(ctx context.Context) (time.Time, error) {
+	var end time.Time
+	if err := s.session.Query(`
+		SELECT bucket_time FROM merkle_root_chain WHERE chain = ?
+	`, rootChainInterval).WithContext(ctx).Consistency(gocql.Consistency(gocql.Serial)).Scan(&end); err != nil {
+		if err == gocql.ErrNotFound {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return end, nil
+}
+
+// GetBatchRootsBetween returns the batch roots with a bucket_time in
+// (after, upTo], oldest first
+func (s *Storage) GetBatchRootsBetween(ctx context.Context, after, upTo time.Time) ([]MerkleRootRow, error) {
+	var roots []MerkleRootRow
+	last := upTo.UTC().Truncate(24 * time.Hour)
+	for date := after.UTC().Truncate(24 * time.Hour); !date.After(last); date = date.AddDate(0, 0, 1) {
+		iter := s.session.Query(`
+			SELECT bucket_time, root_hash, root_type, first_facto_id, last_facto_id, event_hashes
+			FROM merkle_roots
+			WHERE date = ? AND bucket_time > ? AND bucket_time <= ?
+		`, date, after, upTo).WithContext(ctx).Consistency(s.opts.ReadConsistency).Iter()
+
+		// Rows are clustered newest first
+		var day []MerkleRootRow
+		var row MerkleRootRow
+		for iter.Scan(&row.BucketTime, &row.RootHash, &row.RootType, &row.FirstFactoID, &row.LastFactoID, &row.EventHashes) {
+			if row.RootType == RootTypeBatch || row.RootType == "" {
+				day = append(day, row)
+			}
+			row = MerkleRootRow{}
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		for i := len(day) - 1; i >= 0; i-- {
+			roots = append(roots, day[i])
+		}
+	}
+	return roots, nil
+}
+
+// ClaimInterval moves the interval chain row from prevEnd (zero for the
+// first commitment) to root's window end. It returns false when another
+// processor moved it first, in which case root must not be stored.
+func (s *Storage) ClaimInterval(ctx context.Context, prevEnd time.Time, root *IntervalRoot) (bool, error) {
+	date := root.BucketTime.UTC().Truncate(24 * time.Hour)
+
+	var query *gocql.Query
+	if prevEnd.IsZero() {
+		query = s.session.Query(`
+			INSERT INTO merkle_root_chain (chain, root_hash, date, bucket_time, updated_at)
+			VALUES (?, ?, ?, ?, ?) IF NOT EXISTS
+		`, rootChainInterval, root.RootHash, date, root.BucketTime, time.Now())
+	} else {
+		query = s.session.Query(`
+			UPDATE merkle_root_chain SET root_hash = ?, date = ?, bucket_time = ?, updated_at = ?
+			WHERE chain = ? IF bucket_time = ?
+		`, root.RootHash, date, root.BucketTime, time.Now(), rootChainInterval, prevEnd)
+	}
+	return query.WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+// StoreIntervalRoot stores a claimed interval root and indexes its events.
+// Interval roots have their own table, so one can never replace a batch or
+// daily root that happens to share its bucket_time. Writes are idempotent,
+// so a failed store can be retried.
+func (s *Storage) StoreIntervalRoot(ctx context.Context, root *IntervalRoot) error {
+	date := root.BucketTime.UTC().Truncate(24 * time.Hour)
+
+	if err := s.session.Query(`
+		INSERT INTO merkle_interval_roots (
+			date, bucket_time, window_start, root_hash, event_count,
+			first_facto_id, last_facto_id, event_hashes, tree_scheme, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`+usingTTL(s.opts.MerkleRootTTL),
+		date, root.BucketTime, root.WindowStart, root.RootHash, len(root.EventHashes),
+		root.FirstFactoID, root.LastFactoID, root.EventHashes, string(s.opts.MerkleScheme), time.Now(),
+	).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	return s.storeRootIndex(ctx, RootTypeInterval, date, root.BucketTime, root.RootHash, root.EventHashes)
+}
+
+// MerkleRootRow is a merkle_roots row as read back for compaction
+type MerkleRootRow struct {
+	BucketTime   time.Time
+	RootHash     string
+	RootType     string
+	FirstFactoID string
+	LastFactoID  string
+	EventHashes  []string
+}
+
+// GetMerkleRoots returns every Merkle root stored on date, newest first
+func (s *Storage) GetMerkleRoots(ctx context.Context, date time.Time) ([]MerkleRootRow, error) {
+	iter := s.session.Query(`
+		SELECT bucket_time, root_hash, root_type, first_facto_id, last_facto_id, event_hashes
+		FROM merkle_roots
+		WHERE date = ?
+	`, date).WithContext(ctx).Consistency(s.opts.ReadConsistency).Iter()
+
+	var roots []MerkleRootRow
+	var row MerkleRootRow
+	for iter.Scan(&row.BucketTime, &row.RootHash, &row.RootType, &row.FirstFactoID, &row.LastFactoID, &row.EventHashes) {
+		roots = append(roots, row)
+		row = MerkleRootRow{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// DeleteMerkleRoot deletes a root stored on date with its merkle_roots_by_hash
+// row and, with withIndex, its merkle_roots_by_event rows
+func (s *Storage) DeleteMerkleRoot(ctx context.Context, date time.Time, root MerkleRootRow, withIndex bool) error {
+	for i := 0; withIndex && i < len(root.EventHashes); i += maxBatchSize {
+		end := i + maxBatchSize
+		if end > len(root.EventHashes) {
+			end = len(root.EventHashes)
+		}
+
+		batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		for _, hash := range root.EventHashes[i:end] {
+			batch.Query(`DELETE FROM merkle_roots_by_event WHERE event_hash = ? AND root_hash = ?`, hash, root.RootHash)
+		}
+		if err := s.session.ExecuteBatch(batch); err != nil {
+			return err
+		}
+	}
+
+	if err := s.session.Query(`
+		DELETE FROM merkle_roots_by_hash WHERE root_hash = ? AND date = ? AND bucket_time = ?
+	`, root.RootHash, date, root.BucketTime).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+
+	// The root row goes last, so an interrupted delete leaves it to be found
+	// and retried
+	return s.session.Query(`
+		DELETE FROM merkle_roots WHERE date = ? AND bucket_time = ?
+	`, date, root.BucketTime).WithContext(ctx).Exec()
+}
+
+// storeRootIndex writes the merkle_roots_by_event reverse index so a root
+// (and the event's leaf position in it) can be found from an event hash
+func (s *Storage) storeRootIndex(ctx context.Context, rootType string, date, bucketTime time.Time, rootHash string, eventHashes []string) error {
+	for i := 0; i < len(eventHashes); i += maxBatchSize {
+		end := i + maxBatchSize
+		if end > len(eventHashes) {
+			end = len(eventHashes)
+		}
+
+		batch := s.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+		for j := i; j < end; j++ {
+			batch.Query(`
+				INSERT INTO merkle_roots_by_event (
+					event_hash, root_hash, date, bucket_time, root_type, leaf_index
+				) VALUES (?, ?, ?, ?, ?, ?)
+			`+usingTTL(s.opts.MerkleRootTTL), eventHashes[j], rootHash, date, bucketTime, rootType, j)
+		}
+
+		if err := s.session.ExecuteBatch(batch); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Ping runs a lightweight query to check that ScyllaDB is reachable
+func (s *Storage) Ping(ctx context.Context) error {
+	if !s.IsOpen() {
+		return errors.New("storage session is closed")
+	}
+	return s.session.Query(`SELECT now() FROM system.local`).WithContext(ctx).Exec()
+}
+
+// MonitorHealth pings ScyllaDB every interval until ctx is cancelled and
+// publishes the result as the facto_storage_up gauge. gocql reconnects on its
+// own, so the gauge returns to 1 as soon as a ping succeeds again.
+func (s *Storage) MonitorHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		setStorageUp(s.Ping(pingCtx))
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setStorageUp publishes a ping result as the facto_storage_up gauge,
+// logging only when it changes
+func setStorageUp(err error) {
+	if err != nil {
+		if storageUpValue.Load() {
+			log.Warn().Err(err).Msg("Storage ping failed")
+		}
+		storageUp.Set(0)
+		storageUpValue.Store(false)
+		return
+	}
+
+	if !storageUpValue.Load() {
+		log.Info().Msg("Storage ping succeeded")
+	}
+	storageUp.Set(1)
+	storageUpValue.Store(true)
+}
+
+// IsOpen reports whether the storage session exists and hasn't been closed
+func (s *Storage) IsOpen() bool {
+	return s.session != nil && !s.session.Closed()
+}
+
 // Close closes the storage connection
 func (s *Storage) Close() {
 	if s.session != nil {
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/cqlconn"
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStorageUpFollowsPing(t *testing.T) {
+	setStorageUp(errors.New("no hosts available"))
+	if got := testutil.ToFloat64(storageUp); got != 0 {
+		t.Errorf("after a failed ping facto_storage_up = %v, want 0", got)
+	}
+	setStorageUp(nil)
+	if got := testutil.ToFloat64(storageUp); got != 1 {
+		t.Errorf("after a successful ping facto_storage_up = %v, want 1", got)
+	}
+	setStorageUp(errors.New("no hosts available"))
+	if got := testutil.ToFloat64(storageUp); got != 0 {
+		t.Errorf("after the storage went down facto_storage_up = %v, want 0", got)
+	}
+}
+
+// memTables applies logged batches to in-memory event tables, all of a
+// batch's rows or none of them, failing the batch numbered failAt (from 1)
+type memTables struct {
+	rows    map[string]map[string]bool // table -> facto_ids
+	batches int
+	failAt  int
+}
+
+func (m *memTables) execute(batch *gocql.Batch) error {
+	m.batches++
+	if batch.Type != gocql.LoggedBatch {
+		return fmt.Errorf("batch %d is not logged", m.batches)
+	}
+	if m.batches == m.failAt {
+		return errors.New("write timeout")
+	}
+	for _, entry := range batch.Entries {
+		stmt := strings.TrimSpace(entry.Stmt)
+		table := strings.Fields(stmt)[2]
+		columns := strings.Split(stmt[strings.Index(stmt, "(")+1:strings.Index(stmt, ")")], ",")
+		for i, column := range columns {
+			if strings.TrimSpace(column) == "facto_id" {
+				if m.rows[table] == nil {
+					m.rows[table] = map[string]bool{}
+				}
+				m.rows[table][entry.Args[i].(string)] = true
+			}
+		}
+	}
+	return nil
+}
+
+// newTestBatch returns a batch made like Storage's, by Session.NewBatch, on a
+// session that never connects, for tests that only inspect its statements
+func newTestBatch(typ gocql.BatchType) *gocql.Batch {
+	return (&gocql.Session{}).NewBatch(typ)
+}
+
+func TestAtomicWritesAllOrNothing(t *testing.T) {
+	events := make([]eventData, 2*atomicChunkSize+1)
+	for i := range events {
+		events[i].event.FactoID = fmt.Sprintf("ft-%d", i)
+		events[i].event.SessionID = "session"
+	}
+	tables := &memTables{rows: map[string]map[string]bool{}, failAt: 2}
+	newBatch := func() *gocql.Batch { return newTestBatch(gocql.LoggedBatch) }
+
+	if err := writeAtomicChunks(events, true, newBatch, tables.execute); err == nil {
+		t.Fatal("expected the injected failure")
+	}
+	if tables.batches != 2 {
+		t.Errorf("%d batches executed, want 2: writing stops at the failed chunk", tables.batches)
+	}
+
+	// Every event is in all three tables or in none
+	for i, e := range events {
+		want := i < atomicChunkSize
+		for _, table := range []string{"events", "events_by_facto_id", "events_by_session"} {
+			if got := tables.rows[table][e.event.FactoID]; got != want {
+				t.Errorf("%s in %s: %v, want %v", e.event.FactoID, table, got, want)
+			}
+		}
+	}
+}
+
+func TestSnapshotCanonicalMatchesCanonicalForm(t *testing.T) {
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile("../../tests/golden/canonical_event.canonical")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e eventData
+	if err := json.Unmarshal(data, &e.event); err != nil {
+		t.Fatal(err)
+	}
+
+	e.snapshotCanonical()
+	form, _, err := canonicalForm(&e.event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.canonicalForm != form || e.canonicalForm != strings.TrimRight(string(golden), "\n") {
+		t.Errorf("stored canonical form:\ngot  %s\nwant %s", e.canonicalForm, form)
+	}
+	if computeHash(e.canonicalForm) != e.event.Proof.EventHash {
+		t.Error("stored canonical form doesn't hash to the event hash")
+	}
+
+	// The snapshot is written to events_by_session with the event's row
+	batch := newTestBatch(gocql.LoggedBatch)
+	addBySessionInsert(batch, e)
+	last := batch.Entries[len(batch.Entries)-1]
+	if !strings.Contains(last.Stmt, "SET canonical_form = ?, canonical_encoding = ?") || last.Args[0] != form || last.Args[1] != e.canonicalEncoding {
+		t.Errorf("snapshot statement %q bound %v", last.Stmt, last.Args[:2])
+	}
+
+	// An unknown canonical version leaves no snapshot
+	e = eventData{event: e.event}
+	e.event.Proof.CanonicalVersion = 99
+	e.snapshotCanonical()
+	if e.canonicalForm != "" {
+		t.Errorf("snapshot stored for an unknown version: %s", e.canonicalForm)
+	}
+}
+
+func TestClusterConfigNumConns(t *testing.T) {
+	cluster := newClusterConfig([]string{"scylla"}, StorageOptions{NumConns: 8, MaxRequestsPerConn: 256, Keyspace: "facto", WriteConsistency: gocql.LocalQuorum})
+	if cluster.NumConns != 8 || cluster.Keyspace != "facto" || cluster.Consistency != gocql.LocalQuorum {
+		t.Errorf("got num_conns %d, keyspace %q, consistency %v", cluster.NumConns, cluster.Keyspace, cluster.Consistency)
+	}
+	dialer, ok := cluster.Dialer.(*cqlconn.LimitDialer)
+	if !ok || dialer.MaxRequests != 256 {
+		t.Fatalf("dialer %#v doesn't limit connections to 256 requests", cluster.Dialer)
+	}
+	if dialer.Dialer.Timeout != cluster.ConnectTimeout {
+		t.Errorf("dial timeout %v, want the connect timeout %v", dialer.Dialer.Timeout, cluster.ConnectTimeout)
+	}
+
+	// Unset keeps the driver defaults
+	cluster = newClusterConfig([]string{"scylla"}, StorageOptions{})
+	if cluster.NumConns != gocql.NewCluster().NumConns {
+		t.Errorf("num_conns %d without SCYLLA_NUM_CONNS", cluster.NumConns)
+	}
+	if cluster.Dialer != nil {
+		t.Errorf("dialer %#v without SCYLLA_MAX_REQUESTS_PER_CONN", cluster.Dialer)
+	}
+}
+
+func TestEventInsertsUsingTTL(t *testing.T) {
+	e := eventData{canonicalForm: "{}", canonicalEncoding: "json"}
+	e.event.FactoID = "ft-1"
+
+	for _, ttl := range []int{0, 90 * 24 * 3600} {
+		e.ttl = ttl
+		batch := newTestBatch(gocql.UnloggedBatch)
+		addEventInsert(batch, e)
+		addByFactoIDInsert(batch, e)
+		addBySessionInsert(batch, e)
+
+		// Four statements, with the canonical snapshot update
+		if len(batch.Entries) != 4 {
+			t.Fatalf("%d statements", len(batch.Entries))
+		}
+		for _, entry := range batch.Entries {
+			stmt := strings.Join(strings.Fields(entry.Stmt), " ")
+			hasTTL := strings.Contains(stmt, "USING TTL")
+			if ttl == 0 && hasTTL {
+				t.Errorf("TTL clause without EVENT_TTL_SECONDS: %s", stmt)
+			}
+			if ttl > 0 && !strings.Contains(stmt, "USING TTL 7776000") {
+				t.Errorf("no TTL clause: %s", stmt)
+			}
+		}
+	}
+
+	if got := usingTTL(3600); got != " USING TTL 3600" {
+		t.Errorf("usingTTL(3600) = %q", got)
+	}
+}
+
+// memAgentSummaries holds agent_summaries and agent_event_ranges in memory
+type memAgentSummaries struct {
+	counts map[string]int64
+	ranges map[string][2]time.Time
+}
+
+func (m *memAgentSummaries) AddEventCount(ctx context.Context, agentID string, n int64) error {
+	m.counts[agentID] += n
+	return nil
+}
+
+func (m *memAgentSummaries) EventRange(ctx context.Context, agentID string) (time.Time, time.Time, error) {
+	r := m.ranges[agentID]
+	return r[0], r[1], nil
+}
+
+func (m *memAgentSummaries) SetEventRange(ctx context.Context, agentID string, first, last time.Time) error {
+	m.ranges[agentID] = [2]time.Time{first, last}
+	return nil
+}
+
+// Each flush adds to the count and only ever widens the range, whatever
+// order the batches' events arrive in
+func TestUpsertAgentSummariesAcrossBatches(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(agentID string, minutes int) FactoEvent {
+		return FactoEvent{AgentID: agentID, CompletedAt: base.Add(time.Duration(minutes) * time.Minute).UnixNano()}
+	}
+	batches := [][]FactoEvent{
+		{at("agent-a", 10), at("agent-a", 5), at("agent-b", 0)},
+		// Later events for a, and one earlier than anything seen
+		{at("agent-a", 30), at("agent-a", 1)},
+		// Events inside a's range leave it unchanged
+		{at("agent-a", 20), at("agent-b", -5), at("agent-b", 2)},
+	}
+	tables := &memAgentSummaries{counts: map[string]int64{}, ranges: map[string][2]time.Time{}}
+	for i, batch := range batches {
+		if err := upsertAgentSummaries(context.Background(), tables, batch); err != nil {
+			t.Fatalf("batch %d: %v", i, err)
+		}
+	}
+
+	want := map[string]struct {
+		count       int64
+		first, last int
+	}{
+		"agent-a": {5, 1, 30},
+		"agent-b": {3, -5, 2},
+	}
+	for agentID, w := range want {
+		if got := tables.counts[agentID]; got != w.count {
+			t.Errorf("%s event_count = %d, want %d", agentID, got, w.count)
+		}
+		r := tables.ranges[agentID]
+		first, last := base.Add(time.Duration(w.first)*time.Minute), base.Add(time.Duration(w.last)*time.Minute)
+		if !r[0].Equal(first) || !r[1].Equal(last) {
+			t.Errorf("%s range = %v..%v, want %v..%v", agentID, r[0], r[1], first, last)
+		}
+	}
+}
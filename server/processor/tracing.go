@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.opentelemetry.io/otel"
+)
+
+// tracingServiceName is the service.name spans are reported under, unless
+// OTEL_SERVICE_NAME overrides it
+const tracingServiceName = "facto-processor"
+
+// tracer creates the processor's spans. Until tracing.Init installs a
+// provider it is a no-op.
+var tracer = otel.Tracer("github.com/facto-ai/facto/server/processor")
+
+// messageContext returns ctx carrying the trace context from a message's
+// headers, so a publisher that sets traceparent links its trace to the
+// processor's spans
+func messageContext(ctx context.Context, msg jetstream.Msg) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, natsHeaderCarrier(msg.Headers()))
+}
+
+// natsHeaderCarrier adapts NATS headers to the propagator. Unlike HTTP
+// headers their keys aren't canonicalized, so lookups ignore case.
+type natsHeaderCarrier nats.Header
+
+func (h natsHeaderCarrier) Get(key string) string {
+	for k, values := range h {
+		if strings.EqualFold(k, key) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func (h natsHeaderCarrier) Set(key, value string) {
+	nats.Header(h).Set(key, value)
+}
+
+func (h natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	testProviderOnce sync.Once
+	testProvider     *sdktrace.TracerProvider
+)
+
+// recordSpans records the spans ended during the test. The provider is
+// installed once, as tracing.Init would, since the package tracer binds to
+// the first global provider.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	testProviderOnce.Do(func() {
+		testProvider = sdktrace.NewTracerProvider()
+		otel.SetTracerProvider(testProvider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	recorder := tracetest.NewSpanRecorder()
+	testProvider.RegisterSpanProcessor(recorder)
+	t.Cleanup(func() { testProvider.UnregisterSpanProcessor(recorder) })
+	return recorder
+}
+
+// spanAttribute returns the value of a span's attribute, if set
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestSpanPerMessageAndBatch(t *testing.T) {
+	recorder := recordSpans(t)
+	data, err := os.ReadFile("../../tests/golden/canonical_event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	w := &batchWorker{Consumer: &Consumer{
+		batchSize: 10,
+		storage:   &memBatchStore{calls: &calls},
+		anchorer:  NewRootAnchorer(noopAnchor{}, nil, 0),
+		lag:       &LagMonitor{refresh: make(chan struct{}, 1)},
+	}}
+
+	// The publisher's trace continues into the processor
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traced := &fakeMsg{data: data, headers: nats.Header{}}
+	traced.headers.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	ctx := context.Background()
+	w.handleMessage(ctx, traced)
+	w.handleMessage(ctx, &fakeMsg{data: data})
+	w.flush(ctx)
+
+	var messages, flushes []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "Consumer.handleMessage":
+			messages = append(messages, span)
+		case "Consumer.flush":
+			flushes = append(flushes, span)
+		}
+	}
+	if len(messages) != 2 {
+		t.Fatalf("got %d message spans, want 2", len(messages))
+	}
+	if got := messages[0].SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("message span in trace %s, want the publisher's %s", got, traceID)
+	}
+	if messages[1].SpanContext().TraceID().String() == traceID {
+		t.Errorf("untraced message joined the publisher's trace")
+	}
+
+	if len(flushes) != 1 {
+		t.Fatalf("got %d flush spans, want 1", len(flushes))
+	}
+	if size, _ := spanAttribute(flushes[0], "facto.batch_size"); size.AsInt64() != 2 {
+		t.Errorf("flush span batch size %v, want 2", size.AsInt64())
+	}
+	if root, ok := spanAttribute(flushes[0], "facto.merkle_root"); !ok || root.AsString() == "" {
+		t.Errorf("flush span has no merkle_root")
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var rootTimestampsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "facto_processor_root_timestamps_total",
+	Help: "Total number of RFC 3161 timestamp requests for Merkle roots by result",
+}, []string{"result"})
+
+// maxTSAResponseSize bounds a time-stamp response; real tokens, certificate
+// chain included, are a few KB
+const maxTSAResponseSize = 64 << 10
+
+var (
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// RFC 3161 structures, reduced to the fields needed to request a token and
+// check it covers the request. The token's signature is not verified here;
+// auditors check it against the TSA's certificate chain.
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type messageImprint struct {
+	HashAlgorithm algorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.RawValue `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+// signedData and tstInfo stop before fields that aren't needed
+// (certificates, signerInfos, the TSA name, extensions); encoding/asn1 skips
+// trailing elements
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapsulatedContentInfo
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// PKIStatus values that carry a token
+const (
+	tsaStatusGranted         = 0
+	tsaStatusGrantedWithMods = 1
+)
+
+// RootTimestamp is a TSA's token over a Merkle root
+type RootTimestamp struct {
+	Token   []byte    // DER TimeStampToken (CMS ContentInfo)
+	GenTime time.Time // Time the TSA asserts, from the token
+}
+
+// TSAClient requests RFC 3161 timestamp tokens over Merkle roots. The imprint
+// is the SHA-256 of the root's hex string, so a token can be checked with
+// e.g. `printf %s <root> > root.txt; openssl ts -verify -data root.txt
+// -in token.der -token_in -CAfile tsa-ca.pem`.
+type TSAClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewTSAClient creates a client for the Time Stamping Authority at url
+func NewTSAClient(url string) *TSAClient {
+	return &TSAClient{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Timestamp requests a token over rootHash and checks that it answers the
+// request
+func (t *TSAClient) Timestamp(ctx context.Context, rootHash string) (*RootTimestamp, error) {
+	digest := sha256.Sum256([]byte(rootHash))
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest[:],
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA returned status %d", resp.StatusCode)
+	}
+	der, err := io.ReadAll(io.LimitReader(resp.Body, maxTSAResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(der) > maxTSAResponseSize {
+		return nil, fmt.Errorf("TSA response exceeds %d bytes", maxTSAResponseSize)
+	}
+
+	return parseTimeStampResp(der, digest[:], nonce)
+}
+
+// parseTimeStampResp extracts the token from a TimeStampResp and checks its
+// TSTInfo carries the requested imprint
+func parseTimeStampResp(der, digest []byte, nonce *big.Int) (*RootTimestamp, error) {
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(der, &resp); err != nil {
+		return nil, fmt.Errorf("parsing TimeStampResp: %w", err)
+	}
+	if resp.Status.Status != tsaStatusGranted && resp.Status.Status != tsaStatusGrantedWithMods {
+		return nil, fmt.Errorf("TSA rejected the request with status %d", resp.Status.Status)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("TSA response has no token")
+	}
+
+	var token contentInfo
+	if _, err := asn1.Unmarshal(resp.TimeStampToken.FullBytes, &token); err != nil {
+		return nil, fmt.Errorf("parsing TimeStampToken: %w", err)
+	}
+	if !token.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("TimeStampToken is not CMS SignedData")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(token.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("parsing SignedData: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, errors.New("TimeStampToken does not contain a TSTInfo")
+	}
+
+	var info tstInfo
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &info); err != nil {
+		return nil, fmt.Errorf("parsing TSTInfo: %w", err)
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) ||
+		!bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, errors.New("TSTInfo imprint does not match the Merkle root")
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("TSTInfo nonce does not match the request")
+	}
+
+	return &RootTimestamp{Token: resp.TimeStampToken.FullBytes, GenTime: info.GenTime}, nil
+}
+
+// RootTimestamper timestamps stored Merkle roots in the background and saves
+// each token on its merkle_roots row, retrying with backoff. Like anchoring,
+// it never blocks or fails the batch.
+type RootTimestamper struct {
+	tsa     *TSAClient
+	storage *Storage
+	retries int
+}
+
+// NewRootTimestamper creates a timestamper that retries each root up to
+// retries times
+func NewRootTimestamper(tsa *TSAClient, storage *Storage, retries int) *RootTimestamper {
+	return &RootTimestamper{tsa: tsa, storage: storage, retries: retries}
+}
+
+// Submit timestamps the root stored at bucketTime in the background
+func (r *RootTimestamper) Submit(ctx context.Context, bucketTime time.Time, rootHash string) {
+	go r.deliver(ctx, bucketTime, rootHash)
+}
+
+func (r *RootTimestamper) deliver(ctx context.Context, bucketTime time.Time, rootHash string) {
+	var ts *RootTimestamp
+	var err error
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if ts, err = r.tsa.Timestamp(ctx, rootHash); err == nil {
+			break
+		}
+		if attempt >= r.retries || !sleepContext(ctx, backoff) {
+			log.Warn().Err(err).Str("root_hash", rootHash).Msg("Failed to timestamp Merkle root")
+			rootTimestampsTotal.WithLabelValues("failed").Inc()
+			return
+		}
+		rootTimestampsTotal.WithLabelValues("retried").Inc()
+		backoff *= 2
+	}
+
+	if err := r.storage.StoreRootTimestamp(ctx, bucketTime, rootHash, ts); err != nil {
+		log.Error().Err(err).Str("root_hash", rootHash).Msg("Failed to store Merkle root timestamp")
+		rootTimestampsTotal.WithLabelValues("failed").Inc()
+		return
+	}
+	rootTimestampsTotal.WithLabelValues("stamped").Inc()
+}
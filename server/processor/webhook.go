@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var commitWebhooksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "facto_processor_commit_webhooks_total",
+	Help: "Total number of batch commit webhook deliveries by result",
+}, []string{"result"})
+
+// CommitSummary is posted to the commit webhook after a batch is durably stored
+type CommitSummary struct {
+	MerkleRoot   string    `json:"merkle_root"`
+	EventCount   int       `json:"event_count"`
+	FirstFactoID string    `json:"first_facto_id"`
+	LastFactoID  string    `json:"last_facto_id"`
+	BucketTime   time.Time `json:"bucket_time"`
+}
+
+// CommitNotifier delivers commit summaries to a webhook. Delivery is
+// best-effort: failures are retried with backoff, then logged and counted,
+// and never block or fail the batch. With a secret, every delivery carries
+// an X-Facto-Signature HMAC over the body.
+type CommitNotifier struct {
+	url     string
+	secret  string
+	retries int
+	client  *http.Client
+}
+
+// NewCommitNotifier creates a notifier for the given webhook URL. An empty
+// secret sends deliveries unsigned.
+func NewCommitNotifier(url, secret string, retries int) *CommitNotifier {
+	return &CommitNotifier{
+		url:     url,
+		secret:  secret,
+		retries: retries,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Notify posts the summary in the background
+func (n *CommitNotifier) Notify(ctx context.Context, summary CommitSummary) {
+	go n.deliver(ctx, summary)
+}
+
+func (n *CommitNotifier) deliver(ctx context.Context, summary CommitSummary) {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode commit summary")
+		commitWebhooksTotal.WithLabelValues("failed").Inc()
+		return
+	}
+
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if err = n.post(ctx, body); err == nil {
+			commitWebhooksTotal.WithLabelValues("delivered").Inc()
+			return
+		}
+		if attempt >= n.retries || !sleepContext(ctx, backoff) {
+			break
+		}
+		commitWebhooksTotal.WithLabelValues("retried").Inc()
+		backoff *= 2
+	}
+
+	log.Warn().Err(err).
+		Str("merkle_root", summary.MerkleRoot).
+		Msg("Failed to deliver commit webhook")
+	commitWebhooksTotal.WithLabelValues("failed").Inc()
+}
+
+func (n *CommitNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(n.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sleepContext waits for d, returning false if the context ends first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facto-ai/facto/server/shared/webhook"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCommitNotifierPostsSummary(t *testing.T) {
+	var posted []CommitSummary
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// The first delivery fails, so the summary arrives on a retry
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type %q", ct)
+		}
+		var summary CommitSummary
+		if err := json.NewDecoder(r.Body).Decode(&summary); err != nil {
+			t.Errorf("decode summary: %v", err)
+		}
+		posted = append(posted, summary)
+	}))
+	defer server.Close()
+
+	retried := testutil.ToFloat64(commitWebhooksTotal.WithLabelValues("retried"))
+	delivered := testutil.ToFloat64(commitWebhooksTotal.WithLabelValues("delivered"))
+
+	want := CommitSummary{
+		MerkleRoot:   "ab12",
+		EventCount:   3,
+		FirstFactoID: "ft-1",
+		LastFactoID:  "ft-3",
+		BucketTime:   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	n := NewCommitNotifier(server.URL, "", 2)
+	n.deliver(context.Background(), want)
+
+	if len(posted) != 1 {
+		t.Fatalf("got %d summaries after %d attempts, want 1", len(posted), attempts)
+	}
+	if got := posted[0]; got.MerkleRoot != want.MerkleRoot || got.EventCount != want.EventCount ||
+		got.FirstFactoID != want.FirstFactoID || got.LastFactoID != want.LastFactoID || !got.BucketTime.Equal(want.BucketTime) {
+		t.Errorf("posted %+v, want %+v", got, want)
+	}
+	if got := testutil.ToFloat64(commitWebhooksTotal.WithLabelValues("retried")) - retried; got != 1 {
+		t.Errorf("retried counted %v times, want 1", got)
+	}
+	if got := testutil.ToFloat64(commitWebhooksTotal.WithLabelValues("delivered")) - delivered; got != 1 {
+		t.Errorf("delivered counted %v times, want 1", got)
+	}
+}
+
+func TestCommitNotifierSignsDeliveries(t *testing.T) {
+	const secret = "whsec-test"
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	receive := func(t *testing.T, secret string) delivery {
+		t.Helper()
+		var got delivery
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got.body, _ = io.ReadAll(r.Body)
+			got.signature = r.Header.Get(webhook.SignatureHeader)
+		}))
+		defer server.Close()
+		NewCommitNotifier(server.URL, secret, 0).deliver(context.Background(), CommitSummary{MerkleRoot: "ab12", EventCount: 3})
+		if got.body == nil {
+			t.Fatal("no delivery received")
+		}
+		return got
+	}
+
+	signed := receive(t, secret)
+	tampered := bytes.Replace(signed.body, []byte(`"event_count":3`), []byte(`"event_count":4`), 1)
+	for _, tc := range []struct {
+		name string
+		body []byte
+		want error
+	}{
+		{"valid", signed.body, nil},
+		{"tampered body", tampered, webhook.ErrInvalidSignature},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := webhook.Verify(secret, tc.body, signed.signature); !errors.Is(err, tc.want) {
+				t.Errorf("Verify = %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	t.Run("missing header", func(t *testing.T) {
+		unsigned := receive(t, "")
+		if unsigned.signature != "" {
+			t.Fatalf("unsigned notifier sent %q", unsigned.signature)
+		}
+		if err := webhook.Verify(secret, unsigned.body, unsigned.signature); !errors.Is(err, webhook.ErrMissingSignature) {
+			t.Errorf("Verify = %v, want %v", err, webhook.ErrMissingSignature)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+// Package canonical produces the byte-exact JSON that Facto events are hashed
+// and signed over.
+//
+// Two encodings exist. JCS follows the JSON Canonicalization Scheme (RFC 8785):
+// keys sorted by UTF-16 code units, ECMAScript number formatting and minimal
+// string escaping, so the output is defined by the spec rather than by any one
+// JSON library. Legacy reproduces the v1 form, which is encoding/json's compact
+// output with sorted map keys, and is kept so events signed before JCS still
+// verify.
+//
+// JCS departs from RFC 8785 in one place: an integer literal is written
+// exactly, digit for digit, instead of as the nearest IEEE 754 double.
+// Timestamps are int64 nanoseconds, well past the 2^53 a double holds
+// exactly, and the SDKs hash them as written. Integers a double does hold
+// exactly come out the same either way.
+//
+// The processor and the Query API both import this module, so there is one
+// implementation to keep in step with the SDKs.
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Encoding identifies a canonical JSON encoding
+type Encoding string
+
+const (
+	// JCS is the RFC 8785 JSON Canonicalization Scheme
+	JCS Encoding = "jcs"
+	// Legacy is the v1 encoding/json based form
+	Legacy Encoding = "legacy"
+)
+
+// Marshal encodes v using the encoding
+func (e Encoding) Marshal(v interface{}) ([]byte, error) {
+	if e == Legacy {
+		return MarshalLegacy(v)
+	}
+	return Marshal(v)
+}
+
+// Marshal returns the RFC 8785 canonical JSON encoding of v. v may be any value
+// encoding/json can marshal; it is first reduced to its generic JSON form so
+// struct tags and json.Marshaler implementations are honoured.
+func Marshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes JSON into v keeping numbers as json.Number, so integers
+// too large for a float64 survive being decoded and encoded again. Use it for
+// event data that will be canonicalized.
+func Unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("canonical: trailing data after JSON value")
+	}
+	return nil
+}
+
+// MarshalLegacy returns the v1 canonical form: encoding/json's compact output,
+// which sorts map keys bytewise and HTML-escapes <, > and &
+func MarshalLegacy(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if value {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case json.Number:
+		if isIntegerLiteral(string(value)) {
+			// Exact; big.Int only normalizes -0 to 0
+			n, _ := new(big.Int).SetString(string(value), 10)
+			buf.WriteString(n.String())
+			return nil
+		}
+		f, err := strconv.ParseFloat(value.String(), 64)
+		if err != nil {
+			return fmt.Errorf("canonical: invalid number %q: %w", value, err)
+		}
+		formatted, err := formatNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(formatted)
+	case string:
+		encodeString(buf, value)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeString(buf, k)
+			buf.WriteByte(':')
+			if err := encode(buf, value[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("canonical: unsupported type %T", v)
+	}
+	return nil
+}
+
+// isIntegerLiteral reports whether s, a JSON number, has no fraction or
+// exponent
+func isIntegerLiteral(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatNumber serializes a double the way ECMAScript's Number.prototype.toString
+// does, as RFC 8785 section 3.2.2.3 requires
+func formatNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("canonical: %v is not representable in JSON", f)
+	}
+	if f == 0 {
+		return "0", nil // also covers -0
+	}
+
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	// Exponential form: Go writes e-07 / e+21, ECMAScript writes e-7 / e+21
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	sign := exponent[:1]
+	digits := strings.TrimLeft(exponent[1:], "0")
+	return mantissa + "e" + sign + digits, nil
+}
+
+// encodeString writes s as a JSON string, escaping only what RFC 8785 requires
+func encodeString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 orders object keys by their UTF-16 code units
+func lessUTF16(a, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
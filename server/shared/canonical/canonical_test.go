@@ -0,0 +1,187 @@
+package canonical
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// RFC 8785 section 3.2.2: number formatting, string escaping and literals
+func TestMarshalRFC8785Example(t *testing.T) {
+	input := `{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+		"literals": [null, true, false]
+	}`
+	want := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+
+	assertCanonical(t, input, want)
+}
+
+// RFC 8785 section 3.2.3: keys sort by UTF-16 code units, so U+1F600 (a
+// surrogate pair) sorts before U+FB33
+func TestMarshalRFC8785Sorting(t *testing.T) {
+	input := `{
+		"\u20ac": "Euro Sign",
+		"\r": "Carriage Return",
+		"\ufb33": "Hebrew Letter Dalet With Dagesh",
+		"1": "One",
+		"\ud83d\ude00": "Emoji: Grinning Face",
+		"\u0080": "Control",
+		"\u00f6": "Latin Small Letter O With Diaeresis"
+	}`
+	want := "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\"," +
+		"\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\"," +
+		"\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"
+
+	assertCanonical(t, input, want)
+}
+
+// RFC 8785 appendix B: IEEE 754 doubles and their ECMAScript serialization
+func TestFormatNumberRFC8785Vectors(t *testing.T) {
+	vectors := []struct {
+		bits uint64
+		want string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+	for _, v := range vectors {
+		f := math.Float64frombits(v.bits)
+		got, err := formatNumber(f)
+		if err != nil {
+			t.Errorf("%#016x: %v", v.bits, err)
+			continue
+		}
+		if got != v.want {
+			t.Errorf("%#016x: got %s, want %s", v.bits, got, v.want)
+		}
+
+		// The same double marshaled as a Go value
+		out, err := Marshal(f)
+		if err != nil {
+			t.Errorf("%#016x: Marshal: %v", v.bits, err)
+			continue
+		}
+		if string(out) != v.want {
+			t.Errorf("%#016x: Marshal got %s, want %s", v.bits, out, v.want)
+		}
+	}
+
+	for _, bits := range []uint64{0x7fffffffffffffff, 0x7ff0000000000000} {
+		if _, err := formatNumber(math.Float64frombits(bits)); err == nil {
+			t.Errorf("%#016x: expected an error", bits)
+		}
+	}
+}
+
+func TestMarshalKeepsIntegersExact(t *testing.T) {
+	out, err := Marshal(map[string]interface{}{
+		"completed_at": int64(1718000000123456789),
+		"max":          uint64(math.MaxUint64),
+		"min":          int64(math.MinInt64),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"completed_at":1718000000123456789,"max":18446744073709551615,"min":-9223372036854775808}`
+	if string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+
+	assertCanonical(t, `[1718000000123456789, -0, 1.0, 1e2, -12345678901234567890123]`,
+		`[1718000000123456789,0,1,100,-12345678901234567890123]`)
+}
+
+func TestUnmarshalKeepsIntegersExact(t *testing.T) {
+	var v map[string]interface{}
+	if err := Unmarshal([]byte(`{"id": 1718000000123456789}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["id"] != json.Number("1718000000123456789") {
+		t.Errorf("got %#v", v["id"])
+	}
+
+	out, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"id":1718000000123456789}` {
+		t.Errorf("got %s", out)
+	}
+
+	if err := Unmarshal([]byte(`{} {}`), &v); err == nil {
+		t.Error("expected an error for trailing data")
+	}
+}
+
+func TestLegacyEscapesLikeV1(t *testing.T) {
+	value := map[string]interface{}{"b": "<&>", "a": 1}
+	legacy, err := MarshalLegacy(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(legacy) != `{"a":1,"b":"\u003c\u0026\u003e"}` {
+		t.Errorf("legacy got %s", legacy)
+	}
+
+	jcs, err := JCS.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(jcs) != `{"a":1,"b":"<&>"}` {
+		t.Errorf("jcs got %s", jcs)
+	}
+}
+
+// assertCanonical checks that input, decoded as generic JSON, marshals to
+// exactly want
+func assertCanonical(t *testing.T, input, want string) {
+	t.Helper()
+	var v interface{}
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	var v interface{}
+	if err := Unmarshal([]byte(readGolden(b, "canonical_event_v3.json")), &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
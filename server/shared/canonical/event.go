@@ -0,0 +1,175 @@
+package canonical
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Version identifies the canonical form an event was hashed and signed over.
+// Events carry it as proof.canonical_version, and verifiers build exactly
+// that form instead of guessing; an event without one is V1.
+type Version int
+
+const (
+	// V1 is the original form: the v1 field set in the legacy encoding
+	V1 Version = 1
+	// V2 is the v1 field set in JCS
+	V2 Version = 2
+	// V3 is the v3 field set, which authenticates every execution_meta
+	// field, in JCS
+	V3 Version = 3
+)
+
+// ErrUnknownVersion is returned for a canonical_version this build doesn't
+// know. Such an event can't be verified, so it must not be accepted.
+var ErrUnknownVersion = errors.New("unknown canonical_version")
+
+// Encoding returns the JSON encoding the version uses
+func (v Version) Encoding() Encoding {
+	if v == V2 || v == V3 {
+		return JCS
+	}
+	return Legacy
+}
+
+// Resolve returns v with 0 (absent) read as V1, or ErrUnknownVersion
+func (v Version) Resolve() (Version, error) {
+	switch v {
+	case 0:
+		return V1, nil
+	case V1, V2, V3:
+		return v, nil
+	}
+	return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, int(v))
+}
+
+// Event holds the fields of an event that canonical forms draw on. The
+// processor and the Query API each convert their own event type to it.
+type Event struct {
+	FactoID       string
+	AgentID       string
+	SessionID     string
+	ParentFactoID *string
+	ActionType    string
+	Status        string
+	InputData     map[string]interface{}
+	OutputData    map[string]interface{}
+
+	ModelID     *string
+	ModelHash   *string
+	Temperature *float64
+	Seed        *int64
+	MaxTokens   *int32
+	ToolCalls   []interface{}
+	SDKVersion  string
+	SDKLanguage string
+	Tags        map[string]string
+
+	PrevHash    string
+	StartedAt   int64
+	CompletedAt int64
+
+	// Version selects the form; 0 means V1
+	Version Version
+}
+
+// Form returns the bytes e is hashed and signed over, and the version used.
+// It must match the SDKs' canonical forms byte for byte;
+// tests/golden/canonical_event.canonical pins the expected output.
+//
+// V1 and V2 cover the same fields:
+//
+//   - Top level: action_type, agent_id, completed_at, execution_meta,
+//     facto_id, input_data, output_data, parent_facto_id (null when absent),
+//     prev_hash, session_id, started_at, status
+//   - execution_meta: model_id (omitted when absent), seed (null when absent),
+//     sdk_version, temperature (omitted when absent), tool_calls ([] when
+//     absent)
+//
+// model_hash, max_tokens, sdk_language and tags are outside both, so they
+// are unauthenticated in V1 and V2 events. V3 adds them to execution_meta
+// (model_hash and max_tokens omitted when absent, tags {} when absent) and
+// adds canonical_version at the top level, so the version can't be swapped
+// without breaking the signature. Changing the field set means a new Version
+// and matching SDK releases, never an edit to an existing one.
+func (e *Event) Form() (string, Version, error) {
+	version, err := e.Version.Resolve()
+	if err != nil {
+		return "", 0, err
+	}
+
+	execMeta := map[string]interface{}{
+		"seed":        e.Seed,
+		"sdk_version": e.SDKVersion,
+		"tool_calls":  orEmptySlice(e.ToolCalls),
+	}
+	if e.ModelID != nil {
+		execMeta["model_id"] = *e.ModelID
+	}
+	if e.Temperature != nil {
+		execMeta["temperature"] = *e.Temperature
+	}
+	if version == V3 {
+		if e.ModelHash != nil {
+			execMeta["model_hash"] = *e.ModelHash
+		}
+		if e.MaxTokens != nil {
+			execMeta["max_tokens"] = *e.MaxTokens
+		}
+		execMeta["sdk_language"] = e.SDKLanguage
+		execMeta["tags"] = orEmptyTags(e.Tags)
+	}
+
+	// Absent input/output data is canonicalized as an empty object, never
+	// null, so a "started" event logged before its output exists verifies the
+	// same way whether it was sent with null, {} or no output_data at all. The
+	// signature covers the event's state at signing time; a later finalized
+	// event carrying output is a separate, separately-signed event.
+	fields := map[string]interface{}{
+		"action_type":     e.ActionType,
+		"agent_id":        e.AgentID,
+		"completed_at":    e.CompletedAt,
+		"execution_meta":  execMeta,
+		"facto_id":        e.FactoID,
+		"input_data":      orEmptyMap(e.InputData),
+		"output_data":     orEmptyMap(e.OutputData),
+		"parent_facto_id": e.ParentFactoID,
+		"prev_hash":       e.PrevHash,
+		"session_id":      e.SessionID,
+		"started_at":      e.StartedAt,
+		"status":          e.Status,
+	}
+	if version == V3 {
+		fields["canonical_version"] = int(version)
+	}
+
+	form, err := version.Encoding().Marshal(fields)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(form), version, nil
+}
+
+func orEmptyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
+
+// orEmptySlice canonicalizes absent tool_calls as []. Non-LLM events (tool
+// calls, HTTP requests) may omit every model field; model_id and temperature
+// are then left out, seed is null and tool_calls is [], matching the SDKs.
+func orEmptySlice(s []interface{}) []interface{} {
+	if s == nil {
+		return []interface{}{}
+	}
+	return s
+}
+
+func orEmptyTags(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
@@ -0,0 +1,169 @@
+package canonical
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// goldenEvent is tests/golden/canonical_event.json as JSON-decoded by the
+// services
+type goldenEvent struct {
+	FactoID       string                 `json:"facto_id"`
+	AgentID       string                 `json:"agent_id"`
+	SessionID     string                 `json:"session_id"`
+	ParentFactoID *string                `json:"parent_facto_id"`
+	ActionType    string                 `json:"action_type"`
+	Status        string                 `json:"status"`
+	InputData     map[string]interface{} `json:"input_data"`
+	OutputData    map[string]interface{} `json:"output_data"`
+	ExecutionMeta struct {
+		ModelID     *string           `json:"model_id"`
+		ModelHash   *string           `json:"model_hash"`
+		Temperature *float64          `json:"temperature"`
+		Seed        *int64            `json:"seed"`
+		MaxTokens   *int32            `json:"max_tokens"`
+		ToolCalls   []interface{}     `json:"tool_calls"`
+		SDKVersion  string            `json:"sdk_version"`
+		SDKLanguage string            `json:"sdk_language"`
+		Tags        map[string]string `json:"tags"`
+	} `json:"execution_meta"`
+	Proof struct {
+		PrevHash         string  `json:"prev_hash"`
+		CanonicalVersion Version `json:"canonical_version"`
+	} `json:"proof"`
+	StartedAt   int64 `json:"started_at"`
+	CompletedAt int64 `json:"completed_at"`
+}
+
+func loadGolden(t testing.TB, name string) *Event {
+	t.Helper()
+	data, err := os.ReadFile("../../../tests/golden/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g goldenEvent
+	if err := Unmarshal(data, &g); err != nil {
+		t.Fatal(err)
+	}
+	meta := g.ExecutionMeta
+	return &Event{
+		FactoID: g.FactoID, AgentID: g.AgentID, SessionID: g.SessionID,
+		ParentFactoID: g.ParentFactoID, ActionType: g.ActionType, Status: g.Status,
+		InputData: g.InputData, OutputData: g.OutputData,
+		ModelID: meta.ModelID, ModelHash: meta.ModelHash, Temperature: meta.Temperature,
+		Seed: meta.Seed, MaxTokens: meta.MaxTokens, ToolCalls: meta.ToolCalls,
+		SDKVersion: meta.SDKVersion, SDKLanguage: meta.SDKLanguage, Tags: meta.Tags,
+		PrevHash: g.Proof.PrevHash, StartedAt: g.StartedAt, CompletedAt: g.CompletedAt,
+		Version: g.Proof.CanonicalVersion,
+	}
+}
+
+func readGolden(t testing.TB, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("../../../tests/golden/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimRight(string(data), "\n")
+}
+
+func TestFormMatchesGolden(t *testing.T) {
+	event := loadGolden(t, "canonical_event.json")
+	want := readGolden(t, "canonical_event.canonical")
+
+	// The golden event carries no canonical_version, so it is V1; it has no
+	// characters the encodings treat differently, so V2 gives the same bytes
+	for _, version := range []Version{0, V1, V2} {
+		event.Version = version
+		form, got, err := event.Form()
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		if form != want {
+			t.Errorf("version %d:\ngot  %s\nwant %s", version, form, want)
+		}
+		if version == 0 && got != V1 {
+			t.Errorf("absent version resolved to %d", got)
+		}
+	}
+}
+
+// V3 covers every execution_meta field and the version itself, in JCS. The
+// v3 golden event exercises the places JCS differs from the legacy encoding:
+// unescaped HTML and non-ASCII, UTF-16 key order and ECMAScript numbers.
+func TestFormV3MatchesGolden(t *testing.T) {
+	event := loadGolden(t, "canonical_event_v3.json")
+	want := readGolden(t, "canonical_event_v3.canonical")
+
+	form, version, err := event.Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != V3 || form != want {
+		t.Errorf("version %d:\ngot  %s\nwant %s", version, form, want)
+	}
+
+	// Each previously unsigned field now changes the form
+	mutations := map[string]func(e *Event){
+		"model_hash":   func(e *Event) { e.ModelHash = nil },
+		"max_tokens":   func(e *Event) { e.MaxTokens = nil },
+		"sdk_language": func(e *Event) { e.SDKLanguage = "go" },
+		"tags":         func(e *Event) { e.Tags = map[string]string{"env": "prod"} },
+		"version":      func(e *Event) { e.Version = V2 },
+	}
+	for name, mutate := range mutations {
+		mutated := loadGolden(t, "canonical_event_v3.json")
+		mutate(mutated)
+		if form, _, _ := mutated.Form(); form == want {
+			t.Errorf("changing %s left the V3 form unchanged", name)
+		}
+	}
+}
+
+func TestFormVersionSelectsEncoding(t *testing.T) {
+	event := &Event{ActionType: "<tool>", CompletedAt: 1718000000123456789}
+
+	event.Version = V1
+	legacy, _, err := event.Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(legacy, `"action_type":"\u003ctool\u003e"`) {
+		t.Errorf("V1 should HTML-escape: %s", legacy)
+	}
+
+	event.Version = V2
+	jcs, _, err := event.Form()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(jcs, `"action_type":"<tool>"`) {
+		t.Errorf("V2 should not escape: %s", jcs)
+	}
+	if !strings.Contains(jcs, `"completed_at":1718000000123456789`) {
+		t.Errorf("V2 lost timestamp precision: %s", jcs)
+	}
+
+	event.Version = 99
+	if _, _, err := event.Form(); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+// BenchmarkForm measures building the canonical form every event is hashed
+// and signed over, under the legacy and JCS encodings
+func BenchmarkForm(b *testing.B) {
+	for _, name := range []string{"canonical_event.json", "canonical_event_v3.json"} {
+		event := loadGolden(b, name)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := event.Form(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
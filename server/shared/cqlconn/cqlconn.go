@@ -0,0 +1,184 @@
+// Package cqlconn limits the requests in flight on each CQL connection.
+// gocql sizes a connection's concurrency by the protocol's stream IDs, 32768
+// from protocol v3 on, and has no setting to lower it. LimitDialer wraps the
+// connections gocql dials and reads the frame headers passing through them:
+// each request frame written takes a slot, each response read gives it back,
+// and a request is held until a slot is free. Both services use it for
+// SCYLLA_MAX_REQUESTS_PER_CONN.
+//
+// The frames must be readable, so the limit can't be combined with TLS set
+// up by gocql (SslOpts), which encrypts above the dialed connection.
+//
+// The package also reads the CQL settings both services share from the
+// environment: consistency levels and the keyspace name.
+package cqlconn
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// LimitDialer dials connections that allow at most MaxRequests requests in
+// flight. It satisfies gocql.Dialer.
+type LimitDialer struct {
+	Dialer      *net.Dialer
+	MaxRequests int
+}
+
+// DialContext dials addr and wraps the connection in the request limit
+func (d *LimitDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewLimitConn(conn, d.MaxRequests), nil
+}
+
+// limitConn is a CQL connection that holds back writes of request frames
+// while maxRequests requests are awaiting their response
+type limitConn struct {
+	net.Conn
+	slots  chan struct{}
+	closed chan struct{}
+	once   sync.Once
+
+	writeMu sync.Mutex
+	written frameScanner
+	read    frameScanner // Reads come from one goroutine
+
+	deadlineMu    sync.Mutex
+	writeDeadline time.Time
+}
+
+// NewLimitConn wraps conn so at most maxRequests requests are in flight
+func NewLimitConn(conn net.Conn, maxRequests int) net.Conn {
+	return &limitConn{
+		Conn:   conn,
+		slots:  make(chan struct{}, maxRequests),
+		closed: make(chan struct{}),
+	}
+}
+
+// Write writes p once every request frame starting in it has a slot. It
+// waits no longer than the write deadline.
+func (c *limitConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	requests := 0
+	c.written.scan(p, func(int) { requests++ })
+
+	c.deadlineMu.Lock()
+	deadline := c.writeDeadline
+	c.deadlineMu.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for i := 0; i < requests; i++ {
+		select {
+		case c.slots <- struct{}{}:
+		case <-c.closed:
+			return 0, net.ErrClosed
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+// Read reads from the connection, freeing a slot for every response.
+// Server-initiated events (stream -1) answer no request.
+func (c *limitConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.scan(p[:n], func(stream int) {
+		if stream < 0 {
+			return
+		}
+		select {
+		case <-c.slots:
+		default:
+		}
+	})
+	return n, err
+}
+
+func (c *limitConn) SetDeadline(t time.Time) error {
+	c.setWriteDeadline(t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *limitConn) SetWriteDeadline(t time.Time) error {
+	c.setWriteDeadline(t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// setWriteDeadline records t for writes waiting for a slot
+func (c *limitConn) setWriteDeadline(t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.writeDeadline = t
+}
+
+// Close closes the connection and fails writes waiting for a slot
+func (c *limitConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+// frameScanner follows CQL frame boundaries across a byte stream: a header,
+// 9 bytes from protocol v3 on and 8 before, holds the stream ID and the
+// length of the body after it
+type frameScanner struct {
+	header [9]byte
+	have   int // header bytes read
+	skip   int // body bytes left
+}
+
+// scan consumes p, calling frame with the stream ID of every header it
+// completes
+func (s *frameScanner) scan(p []byte, frame func(stream int)) {
+	for len(p) > 0 {
+		if s.skip > 0 {
+			n := min(len(p), s.skip)
+			s.skip -= n
+			p = p[n:]
+			continue
+		}
+
+		// The version byte decides the header's size
+		if s.have == 0 {
+			s.header[0] = p[0]
+			s.have = 1
+			p = p[1:]
+			continue
+		}
+		size := 9
+		if s.header[0]&0x7f < 3 {
+			size = 8
+		}
+		n := copy(s.header[s.have:size], p)
+		s.have += n
+		p = p[n:]
+		if s.have < size {
+			return
+		}
+
+		var stream int
+		if size == 9 {
+			stream = int(int16(binary.BigEndian.Uint16(s.header[2:4])))
+			s.skip = int(binary.BigEndian.Uint32(s.header[5:9]))
+		} else {
+			stream = int(int8(s.header[2]))
+			s.skip = int(binary.BigEndian.Uint32(s.header[4:8]))
+		}
+		s.have = 0
+		frame(stream)
+	}
+}
@@ -0,0 +1,125 @@
+package cqlconn
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// frame returns a protocol v4 frame on stream with a body of n bytes;
+// response frames have the direction bit set
+func frame(response bool, stream int16, n int) []byte {
+	f := make([]byte, 9+n)
+	f[0] = 0x04
+	if response {
+		f[0] |= 0x80
+	}
+	binary.BigEndian.PutUint16(f[2:4], uint16(stream))
+	f[4] = 0x07 // QUERY
+	binary.BigEndian.PutUint32(f[5:9], uint32(n))
+	return f
+}
+
+// writeAsync writes p on conn and reports the result on the returned channel
+func writeAsync(conn net.Conn, p []byte) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(p)
+		done <- err
+	}()
+	return done
+}
+
+func waitWrite(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write blocked")
+	}
+}
+
+func TestLimitConnHoldsRequestsOverLimit(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewLimitConn(client, 2)
+	defer conn.Close()
+	go io.Copy(io.Discard, server)
+	go io.Copy(io.Discard, conn)
+
+	waitWrite(t, writeAsync(conn, frame(false, 1, 20)))
+	waitWrite(t, writeAsync(conn, frame(false, 2, 0)))
+
+	// A third request waits for a response
+	third := writeAsync(conn, frame(false, 3, 5))
+	select {
+	case <-third:
+		t.Fatal("third request written with two in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A server event answers no request; split across reads, a response does
+	if _, err := server.Write(frame(true, -1, 4)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-third:
+		t.Fatal("an event freed a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	response := frame(true, 1, 30)
+	for _, part := range [][]byte{response[:3], response[3:12], response[12:]} {
+		if _, err := server.Write(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitWrite(t, third)
+}
+
+func TestLimitConnWriteDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewLimitConn(client, 1)
+	go io.Copy(io.Discard, server)
+
+	waitWrite(t, writeAsync(conn, frame(false, 1, 0)))
+	if err := conn.SetWriteDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(frame(false, 2, 0)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("write past the deadline: %v", err)
+	}
+
+	// Closing fails writes still waiting
+	if err := conn.SetWriteDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	blocked := writeAsync(conn, frame(false, 3, 0))
+	conn.Close()
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("write on a closed connection: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write still blocked after close")
+	}
+}
+
+func TestFrameScannerProtocolV2(t *testing.T) {
+	// v2 headers are 8 bytes with a one-byte stream
+	f := []byte{0x82, 0, 0xff, 0x08, 0, 0, 0, 2, 'o', 'k', 0x82, 0, 5, 0x08, 0, 0, 0, 0}
+	var streams []int
+	var s frameScanner
+	for _, b := range f {
+		s.scan([]byte{b}, func(stream int) { streams = append(streams, stream) })
+	}
+	if len(streams) != 2 || streams[0] != -1 || streams[1] != 5 {
+		t.Errorf("got streams %v", streams)
+	}
+}
@@ -0,0 +1,55 @@
+package cqlconn
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/rs/zerolog/log"
+)
+
+// ConsistencyEnv reads a consistency level name such as LOCAL_QUORUM (in any
+// case) from the named env var, keeping def when it is unset or invalid
+func ConsistencyEnv(name string, def gocql.Consistency) gocql.Consistency {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	consistency, err := gocql.ParseConsistencyWrapper(value)
+	if err != nil {
+		log.Warn().Err(err).Str("default", def.String()).Msg(name + " is not a consistency level, using the default")
+		return def
+	}
+	return consistency
+}
+
+// KeyspaceEnv reads a keyspace name from the named env var, keeping def when
+// it is unset or not a valid unquoted CQL identifier
+func KeyspaceEnv(name, def string) string {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	if !validKeyspace(value) {
+		log.Warn().Str("keyspace", value).Str("default", def).Msg(name + " is not a valid keyspace name, using the default")
+		return def
+	}
+	return value
+}
+
+// validKeyspace reports whether name is a keyspace name ScyllaDB accepts: up
+// to 48 letters, digits and underscores, starting with a letter
+func validKeyspace(name string) bool {
+	if len(name) == 0 || len(name) > 48 {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package cqlconn
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+)
+
+func TestConsistencyEnv(t *testing.T) {
+	cases := []struct {
+		value string
+		want  gocql.Consistency
+	}{
+		{"", gocql.LocalOne},
+		{"LOCAL_QUORUM", gocql.LocalQuorum},
+		{"local_quorum", gocql.LocalQuorum},
+		{" QUORUM ", gocql.Quorum},
+		{"EACH_QUORUM", gocql.EachQuorum},
+		// Invalid values keep the default
+		{"STRONG", gocql.LocalOne},
+	}
+	for _, tc := range cases {
+		t.Setenv("SCYLLA_READ_CONSISTENCY", tc.value)
+		if got := ConsistencyEnv("SCYLLA_READ_CONSISTENCY", gocql.LocalOne); got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestKeyspaceEnv(t *testing.T) {
+	cases := map[string]string{
+		"":           "facto",
+		"facto_prod": "facto_prod",
+		"staging2":   "staging2",
+		" trimmed ":  "trimmed",
+		// Not a valid unquoted identifier
+		"2facto":                "facto",
+		"facto; DROP":           "facto",
+		"_facto":                "facto",
+		"facto-prod":            "facto",
+		strings.Repeat("k", 48): strings.Repeat("k", 48),
+		strings.Repeat("k", 49): "facto",
+	}
+	for value, want := range cases {
+		t.Setenv("SCYLLA_KEYSPACE", value)
+		if got := KeyspaceEnv("SCYLLA_KEYSPACE", "facto"); got != want {
+			t.Errorf("%q: got %q, want %q", value, got, want)
+		}
+	}
+}
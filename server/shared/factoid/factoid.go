@@ -0,0 +1,55 @@
+// Package factoid defines the facto_id format: "ft-" followed by a
+// lowercase, hyphenated UUID. The SDKs generate UUIDv4 ids; New generates
+// time-ordered UUIDv7 ids for server-side ingest and backfill tools. The
+// facto_id is a primary key in every table and part of the signed canonical
+// form, so the processor and the Query API both reject any other format.
+package factoid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"regexp"
+	"time"
+)
+
+// Prefix starts every facto_id
+const Prefix = "ft-"
+
+// pattern is the canonical facto_id format
+var pattern = regexp.MustCompile(`^ft-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Valid reports whether id is a well-formed facto_id
+func Valid(id string) bool {
+	return pattern.MatchString(id)
+}
+
+// New returns a facto_id holding a UUIDv7 (RFC 9562) for the current time,
+// so ids generated later sort later
+func New() string {
+	return newAt(time.Now())
+}
+
+// newAt returns a UUIDv7 facto_id for t: 48 bits of Unix milliseconds, then
+// random bits around the version and variant
+func newAt(t time.Time) string {
+	var u [16]byte
+	rand.Read(u[:])
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(u[:6], ms[2:])
+	u[6] = u[6]&0x0f | 0x70 // Version 7
+	u[8] = u[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return Prefix + string(buf[:])
+}
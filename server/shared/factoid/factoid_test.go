@@ -0,0 +1,74 @@
+package factoid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValid(t *testing.T) {
+	for _, tc := range []struct {
+		id    string
+		valid bool
+	}{
+		{"ft-00000000-0000-4000-8000-000000000001", true},
+		{"ft-018e0c6a-7b2c-7d3e-9f40-0123456789ab", true},
+		{"", false},
+		{"ft-", false},
+		{"00000000-0000-4000-8000-000000000001", false},     // No prefix
+		{"FT-00000000-0000-4000-8000-000000000001", false},  // Uppercase prefix
+		{"ft-0000000A-0000-4000-8000-000000000001", false},  // Uppercase hex
+		{"ft-00000000000040008000000000000001", false},      // Unhyphenated
+		{"ft-00000000-0000-4000-8000-00000000001", false},   // Short last group
+		{"ft-00000000-0000-4000-8000-0000000000012", false}, // Long last group
+		{"ft-0000000g-0000-4000-8000-000000000001", false},  // Not hex
+		{"ft-00000000-0000-4000-8000-000000000001\n", false},
+		{"ft-00000000-0000-4000-8000-000000000001' OR '1'='1", false},
+		{" ft-00000000-0000-4000-8000-000000000001", false},
+	} {
+		if got := Valid(tc.id); got != tc.valid {
+			t.Errorf("Valid(%q) = %v, want %v", tc.id, got, tc.valid)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := New()
+		if !Valid(id) {
+			t.Fatalf("generated invalid id %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("generated %q twice", id)
+		}
+		seen[id] = true
+
+		// Version 7 and the RFC 9562 variant
+		if id[17] != '7' || (id[22] != '8' && id[22] != '9' && id[22] != 'a' && id[22] != 'b') {
+			t.Fatalf("%q isn't a UUIDv7", id)
+		}
+	}
+}
+
+func TestNewSortsByTime(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier, later := newAt(t0), newAt(t0.Add(time.Millisecond))
+	if earlier >= later {
+		t.Errorf("%s doesn't sort before %s", earlier, later)
+	}
+	// The first 48 bits are the Unix milliseconds
+	if got := earlier[3:11] + earlier[12:16]; got != unixMilliHex(t0) {
+		t.Errorf("timestamp bits %s, want %s", got, unixMilliHex(t0))
+	}
+}
+
+func unixMilliHex(t time.Time) string {
+	const digits = "0123456789abcdef"
+	ms := uint64(t.UnixMilli())
+	var out [12]byte
+	for i := 11; i >= 0; i-- {
+		out[i] = digits[ms&0xf]
+		ms >>= 4
+	}
+	return string(out[:])
+}
@@ -0,0 +1,13 @@
+// Package factopb holds the Go code generated from proto/facto/v1/facto.proto:
+// the Event messages and the Ingest and Query gRPC services. The processor
+// serves Ingest and the Query API serves Query, both from this package.
+//
+// From this module's directory:
+//
+//	protoc -I ../../proto \
+//		--go_out=. --go_opt=module=github.com/facto-ai/facto/server/shared \
+//		--go_opt=Mfacto/v1/facto.proto=github.com/facto-ai/facto/server/shared/factopb \
+//		--go-grpc_out=. --go-grpc_opt=module=github.com/facto-ai/facto/server/shared \
+//		--go-grpc_opt=Mfacto/v1/facto.proto=github.com/facto-ai/facto/server/shared/factopb \
+//		facto/v1/facto.proto
+package factopb
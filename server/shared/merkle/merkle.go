@@ -0,0 +1,213 @@
+// Package merkle builds the binary Merkle trees Facto commits event hashes
+// with, and produces and checks inclusion proofs against them. The processor
+// builds the roots it stores with it, and the Query API rebuilds the same
+// trees for proofs and evidence packages. Both import this one package, so
+// the two sides can't drift apart.
+//
+// Two tree schemes exist. SchemeLegacy hashes interior nodes as
+// SHA-256(left || right) over the raw leaves and pads every level with an
+// odd node count by repeating its last node, a lone leaf included. That
+// padding lets two leaf lists share a root ([a, b, c] and [a, b, c, c]: the
+// CVE-2012-2459 pattern), and since leaves and nodes hash alike an interior
+// node can be passed off as a leaf. SchemeRFC6962 hashes leaves as
+// SHA-256(0x00 || leaf) and interior nodes as SHA-256(0x01 || left || right),
+// and carries an odd last node up a level unchanged, which gives the RFC 6962
+// tree shape. SchemeLegacy stays the default so stored roots and existing
+// verifiers keep working.
+package merkle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Scheme selects how leaves and nodes are hashed and how odd levels are
+// handled
+type Scheme string
+
+const (
+	SchemeLegacy  Scheme = "legacy"
+	SchemeRFC6962 Scheme = "rfc6962"
+)
+
+// ParseScheme parses a scheme name. An empty name is SchemeLegacy, the
+// scheme of roots stored before schemes were recorded.
+func ParseScheme(name string) (Scheme, error) {
+	switch Scheme(name) {
+	case "", SchemeLegacy:
+		return SchemeLegacy, nil
+	case SchemeRFC6962:
+		return SchemeRFC6962, nil
+	default:
+		return "", fmt.Errorf("unknown Merkle tree scheme %q (want legacy or rfc6962)", name)
+	}
+}
+
+// LeafHash returns the tree node for a leaf hash
+func (s Scheme) LeafHash(leaf string) string {
+	if s != SchemeRFC6962 {
+		return leaf
+	}
+	leafBytes, _ := hex.DecodeString(leaf)
+	hash := sha256.Sum256(append([]byte{0x00}, leafBytes...))
+	return hex.EncodeToString(hash[:])
+}
+
+// NodeHash returns the hash of an interior node over two hex hashes
+func (s Scheme) NodeHash(left, right string) string {
+	leftBytes, _ := hex.DecodeString(left)
+	rightBytes, _ := hex.DecodeString(right)
+
+	buf := make([]byte, 0, 1+len(leftBytes)+len(rightBytes))
+	if s == SchemeRFC6962 {
+		buf = append(buf, 0x01)
+	}
+	buf = append(buf, leftBytes...)
+	buf = append(buf, rightBytes...)
+	hash := sha256.Sum256(buf)
+	return hex.EncodeToString(hash[:])
+}
+
+// ProofElement is one sibling on the path from a leaf to the root
+type ProofElement struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // "left" or "right"
+}
+
+// ErrInvalidPosition is returned for a proof element whose position is
+// neither "left" nor "right"
+var ErrInvalidPosition = errors.New("invalid Merkle proof position")
+
+// Tree is a built Merkle tree. It is read-only once built, so it can be
+// shared between goroutines.
+type Tree struct {
+	scheme Scheme
+	leaves int
+	levels [][]string // levels[0] holds the leaf nodes, the last level the root; none is padded
+}
+
+// Build builds the tree over hashes. Building is CPU-bound, so ctx is checked
+// between levels and its error returned once it is done. An empty tree's root
+// is the SHA-256 of nothing.
+func Build(ctx context.Context, hashes []string, scheme Scheme) (*Tree, error) {
+	if len(hashes) == 0 {
+		empty := sha256.Sum256(nil)
+		return &Tree{scheme: scheme, levels: [][]string{{hex.EncodeToString(empty[:])}}}, nil
+	}
+
+	level := make([]string, len(hashes))
+	for i, h := range hashes {
+		level[i] = scheme.LeafHash(h)
+	}
+	tree := &Tree{scheme: scheme, leaves: len(hashes), levels: [][]string{level}}
+
+	// Legacy pads a lone leaf too, so its root is hash(leaf || leaf)
+	for len(level) > 1 || (scheme == SchemeLegacy && len(tree.levels) == 1) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			switch {
+			case i+1 < len(level):
+				next = append(next, scheme.NodeHash(level[i], level[i+1]))
+			case scheme == SchemeLegacy:
+				next = append(next, scheme.NodeHash(level[i], level[i]))
+			default:
+				next = append(next, level[i])
+			}
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+
+	return tree, nil
+}
+
+// Root returns the root hash
+func (t *Tree) Root() string {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Len returns the number of leaves
+func (t *Tree) Len() int {
+	return t.leaves
+}
+
+// Proof returns the inclusion proof for the leaf at index, or nil if there
+// is no such leaf. ctx is checked between levels like Build.
+func (t *Tree) Proof(ctx context.Context, index int) ([]ProofElement, error) {
+	if index < 0 || index >= t.leaves {
+		return nil, nil
+	}
+
+	proof := []ProofElement{}
+	idx := index
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		sibling := idx ^ 1
+		position := "right"
+		if idx%2 == 1 {
+			position = "left"
+		}
+		switch {
+		case sibling < len(level):
+			proof = append(proof, ProofElement{Hash: level[sibling], Position: position})
+		case t.scheme == SchemeLegacy:
+			// The odd last node was paired with itself
+			proof = append(proof, ProofElement{Hash: level[idx], Position: position})
+		}
+		idx /= 2
+	}
+	return proof, nil
+}
+
+// TraceStep is one hash computed while walking a proof: the leaf, its leaf
+// node (SchemeRFC6962 only), then one hash_pair per proof element
+type TraceStep struct {
+	Step  string `json:"step"`
+	Left  string `json:"left,omitempty"`
+	Right string `json:"right,omitempty"`
+	Hash  string `json:"hash"`
+}
+
+// Trace recomputes the root from a leaf hash and its proof, recording every
+// hash computed on the way: the leaf first, the root last
+func Trace(scheme Scheme, leafHash string, proof []ProofElement) ([]TraceStep, error) {
+	trace := []TraceStep{{Step: "leaf", Hash: leafHash}}
+	current := scheme.LeafHash(leafHash)
+	if scheme == SchemeRFC6962 {
+		trace = append(trace, TraceStep{Step: "hash_leaf", Hash: current})
+	}
+	for i, element := range proof {
+		var left, right string
+		switch element.Position {
+		case "left":
+			left, right = element.Hash, current
+		case "right":
+			left, right = current, element.Hash
+		default:
+			return nil, fmt.Errorf("%w %q at proof element %d", ErrInvalidPosition, element.Position, i)
+		}
+		current = scheme.NodeHash(left, right)
+		trace = append(trace, TraceStep{Step: "hash_pair", Left: left, Right: right, Hash: current})
+	}
+	return trace, nil
+}
+
+// Verify recomputes the root from a leaf hash and its proof and compares it
+// with root. A malformed proof is an error rather than a mismatch.
+func Verify(scheme Scheme, leafHash string, proof []ProofElement, root string) (bool, error) {
+	trace, err := Trace(scheme, leafHash, proof)
+	if err != nil {
+		return false, err
+	}
+	return trace[len(trace)-1].Hash == root, nil
+}
@@ -0,0 +1,220 @@
+package merkle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// The Certificate Transparency reference test vectors: rfc6962Roots[n-1] is
+// the RFC 6962 root of the first n leaves
+var rfc6962Leaves = []string{
+	"", "00", "10", "2021", "3031", "40414243",
+	"5051525354555657", "606162636465666768696a6b6c6d6e6f",
+}
+
+var rfc6962Roots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func TestRFC6962Roots(t *testing.T) {
+	for n := 1; n <= len(rfc6962Leaves); n++ {
+		tree, err := Build(context.Background(), rfc6962Leaves[:n], SchemeRFC6962)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tree.Root(); got != rfc6962Roots[n-1] {
+			t.Errorf("%d leaves: got %s, want %s", n, got, rfc6962Roots[n-1])
+		}
+	}
+}
+
+func TestLegacyRoots(t *testing.T) {
+	a, b, c := leafHash("a"), leafHash("b"), leafHash("c")
+
+	tree, _ := Build(context.Background(), []string{a}, SchemeLegacy)
+	if tree.Root() != SchemeLegacy.NodeHash(a, a) {
+		t.Error("a lone legacy leaf should be paired with itself")
+	}
+
+	tree, _ = Build(context.Background(), []string{a, b, c}, SchemeLegacy)
+	want := SchemeLegacy.NodeHash(SchemeLegacy.NodeHash(a, b), SchemeLegacy.NodeHash(c, c))
+	if tree.Root() != want {
+		t.Errorf("got %s, want %s", tree.Root(), want)
+	}
+
+	tree, _ = Build(context.Background(), nil, SchemeLegacy)
+	empty := sha256.Sum256(nil)
+	if tree.Root() != hex.EncodeToString(empty[:]) {
+		t.Error("an empty tree's root should be SHA-256 of nothing")
+	}
+}
+
+// The processor stores Build's roots and the Query API proves inclusion
+// against them with Proof and Verify. Every leaf of every tree size must
+// verify, and a tampered leaf must not.
+func TestProofsVerifyAgainstBuiltRoots(t *testing.T) {
+	ctx := context.Background()
+	for _, scheme := range []Scheme{SchemeLegacy, SchemeRFC6962} {
+		for n := 1; n <= 33; n++ {
+			hashes := make([]string, n)
+			for i := range hashes {
+				hashes[i] = leafHash(fmt.Sprint(i))
+			}
+			tree, err := Build(ctx, hashes, scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, hash := range hashes {
+				proof, err := tree.Proof(ctx, i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ok, err := Verify(scheme, hash, proof, tree.Root())
+				if err != nil || !ok {
+					t.Errorf("%s, %d leaves: leaf %d doesn't verify (%v)", scheme, n, i, err)
+				}
+				if ok, _ := Verify(scheme, leafHash("tampered"), proof, tree.Root()); ok {
+					t.Errorf("%s, %d leaves: tampered leaf %d verifies", scheme, n, i)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyRejectsBadPosition(t *testing.T) {
+	_, err := Verify(SchemeRFC6962, leafHash("a"), []ProofElement{{Hash: leafHash("b"), Position: "up"}}, "")
+	if err == nil {
+		t.Error("expected an error for an invalid position")
+	}
+}
+
+func TestParseScheme(t *testing.T) {
+	for name, want := range map[string]Scheme{"": SchemeLegacy, "legacy": SchemeLegacy, "rfc6962": SchemeRFC6962} {
+		if got, err := ParseScheme(name); err != nil || got != want {
+			t.Errorf("ParseScheme(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseScheme("sha1"); err == nil {
+		t.Error("expected an error for an unknown scheme")
+	}
+}
+
+func leafHash(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+// cancelAfter is a context cancelled once Err has been checked after times
+type cancelAfter struct {
+	context.Context
+	after, checks int
+}
+
+func (c *cancelAfter) Err() error {
+	c.checks++
+	if c.checks > c.after {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestBuildStopsWhenCancelled(t *testing.T) {
+	hashes := make([]string, 1<<16)
+	for i := range hashes {
+		hashes[i] = fmt.Sprintf("%064x", i)
+	}
+
+	// Cancelled while building the third level of a 17-level tree
+	ctx := &cancelAfter{Context: context.Background(), after: 2}
+	tree, err := Build(ctx, hashes, SchemeRFC6962)
+	if !errors.Is(err, context.Canceled) || tree != nil {
+		t.Fatalf("got tree %v, error %v", tree, err)
+	}
+	if ctx.checks != 3 {
+		t.Errorf("checked for cancellation %d times, want 3: the build went on after it", ctx.checks)
+	}
+
+	tree, err = Build(context.Background(), hashes, SchemeRFC6962)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = &cancelAfter{Context: context.Background(), after: 1}
+	if proof, err := tree.Proof(ctx, 12345); !errors.Is(err, context.Canceled) || proof != nil {
+		t.Errorf("got proof %v, error %v", proof, err)
+	}
+	if ctx.checks != 2 {
+		t.Errorf("proof checked for cancellation %d times, want 2", ctx.checks)
+	}
+}
+
+// benchmarkHashes returns n distinct leaf hashes, as a batch of events would
+// supply
+func benchmarkHashes(n int) []string {
+	hashes := make([]string, n)
+	for i := range hashes {
+		hashes[i] = leafHash(fmt.Sprint(i))
+	}
+	return hashes
+}
+
+func BenchmarkBuild(b *testing.B) {
+	ctx := context.Background()
+	for _, scheme := range []Scheme{SchemeLegacy, SchemeRFC6962} {
+		for _, n := range []int{100, 10000} {
+			hashes := benchmarkHashes(n)
+			b.Run(fmt.Sprintf("%s/%d", scheme, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := Build(ctx, hashes, scheme); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkProof(b *testing.B) {
+	ctx := context.Background()
+	tree, err := Build(ctx, benchmarkHashes(10000), SchemeRFC6962)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := tree.Proof(ctx, i%tree.Len()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	ctx := context.Background()
+	hashes := benchmarkHashes(10000)
+	tree, err := Build(ctx, hashes, SchemeRFC6962)
+	if err != nil {
+		b.Fatal(err)
+	}
+	proof, err := tree.Proof(ctx, 4321)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if ok, err := Verify(SchemeRFC6962, hashes[4321], proof, tree.Root()); err != nil || !ok {
+			b.Fatalf("proof doesn't verify (%v)", err)
+		}
+	}
+}
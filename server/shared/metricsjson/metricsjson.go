@@ -0,0 +1,61 @@
+// Package metricsjson flattens a Prometheus registry into a {name: value}
+// map, for environments that want a point-in-time JSON view instead of
+// scraping. Both services serve it on /metrics.json.
+package metricsjson
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Snapshot gathers gatherer's metrics. Labeled series are keyed like the
+// exposition format, e.g.
+// facto_api_requests_total{endpoint="get_events",status="200"}. Histograms
+// and summaries contribute their _count and _sum series.
+func Snapshot(gatherer prometheus.Gatherer) (map[string]float64, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]float64)
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			labels := formatLabels(m.GetLabel())
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				snapshot[name+labels] = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				snapshot[name+labels] = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				snapshot[name+labels] = m.GetUntyped().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				snapshot[name+"_count"+labels] = float64(m.GetHistogram().GetSampleCount())
+				snapshot[name+"_sum"+labels] = m.GetHistogram().GetSampleSum()
+			case dto.MetricType_SUMMARY:
+				snapshot[name+"_count"+labels] = float64(m.GetSummary().GetSampleCount())
+				snapshot[name+"_sum"+labels] = m.GetSummary().GetSampleSum()
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// formatLabels renders pairs as {name="value",...}, sorted by name
+func formatLabels(pairs []*dto.LabelPair) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		parts = append(parts, p.GetName()+`="`+p.GetValue()+`"`)
+	}
+	sort.Strings(parts)
+	return "{" + strings.Join(parts, ",") + "}"
+}
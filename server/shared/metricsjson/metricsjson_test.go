@@ -0,0 +1,62 @@
+package metricsjson
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestSnapshot(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	events := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_events_total", Help: "test"})
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests_total", Help: "test"},
+		[]string{"status", "endpoint"})
+	lag := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_lag", Help: "test"})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_latency_seconds", Help: "test"})
+	size := prometheus.NewSummaryVec(prometheus.SummaryOpts{Name: "test_batch_size", Help: "test"}, []string{"worker"})
+	up := prometheus.NewUntypedFunc(prometheus.UntypedOpts{Name: "test_up", Help: "test"}, func() float64 { return 1 })
+	registry.MustRegister(events, requests, lag, latency, size, up)
+	events.Add(3)
+	requests.WithLabelValues("200", "get_events").Inc()
+	requests.WithLabelValues("500", "get_events").Add(2)
+	lag.Set(-4)
+	latency.Observe(0.25)
+	latency.Observe(0.5)
+	size.WithLabelValues("0").Observe(10)
+
+	snapshot, err := Snapshot(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Labels are sorted by name, whatever order they were declared in
+	want := map[string]float64{
+		"test_events_total": 3,
+		`test_requests_total{endpoint="get_events",status="200"}`: 1,
+		`test_requests_total{endpoint="get_events",status="500"}`: 2,
+		"test_lag":                          -4,
+		"test_latency_seconds_count":        2,
+		"test_latency_seconds_sum":          0.75,
+		`test_batch_size_count{worker="0"}`: 1,
+		`test_batch_size_sum{worker="0"}`:   10,
+		"test_up":                           1,
+	}
+	for name, value := range want {
+		if got, ok := snapshot[name]; !ok || got != value {
+			t.Errorf("%s = %v (present %v), want %v", name, got, ok, value)
+		}
+	}
+	if len(snapshot) != len(want) {
+		t.Errorf("snapshot has %d series, want %d: %v", len(snapshot), len(want), snapshot)
+	}
+}
+
+func TestSnapshotGatherError(t *testing.T) {
+	failing := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, errors.New("collector failed")
+	})
+	if _, err := Snapshot(failing); err == nil {
+		t.Error("expected the gather error")
+	}
+}
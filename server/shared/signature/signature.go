@@ -0,0 +1,135 @@
+// Package signature verifies event signatures under the algorithms accepted
+// in proof.sig_algo. The processor checks them at ingest and the Query API
+// on every verify, so both import this package rather than keeping copies.
+//
+// Ed25519 keys and signatures are raw (32 and 64 bytes). ECDSA P-256 and
+// RSA-PSS public keys are DER SubjectPublicKeyInfo, as exported by HSMs and
+// cloud KMSs; both sign the SHA-256 digest of the canonical form, ECDSA
+// signatures are ASN.1 DER and RSA-PSS uses MGF1-SHA256 with a 32-byte salt.
+// secp256k1 public keys are SEC 1 points (33 bytes compressed or 65
+// uncompressed), as wallets export them; it also signs the SHA-256 digest,
+// with either a DER or a 64-byte r||s signature. All are base64 in the
+// proof, like Ed25519.
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Signature algorithms accepted in proof.sig_algo. An empty sig_algo is
+// Ed25519, the only scheme before sig_algo existed.
+const (
+	AlgoEd25519   = "ed25519"
+	AlgoECDSAP256 = "ecdsa-p256"
+	AlgoSecp256k1 = "secp256k1"
+	AlgoRSAPSS    = "rsa-pss"
+)
+
+// MinRSAKeyBits is the smallest RSA modulus accepted for rsa-pss
+const MinRSAKeyBits = 2048
+
+// Verifier checks a signature over msg with a public key, both as decoded
+// from the proof. Malformed keys or signatures never verify.
+type Verifier interface {
+	Verify(pubKey, msg, sig []byte) bool
+}
+
+// verifiers maps each sig_algo to its Verifier
+var verifiers = map[string]Verifier{
+	AlgoEd25519:   ed25519Verifier{},
+	AlgoECDSAP256: ecdsaP256Verifier{},
+	AlgoSecp256k1: secp256k1Verifier{},
+	AlgoRSAPSS:    rsaPSSVerifier{},
+}
+
+// VerifierFor returns the Verifier for a declared sig_algo, or false when
+// the algorithm is unknown
+func VerifierFor(algo string) (Verifier, bool) {
+	if algo == "" {
+		algo = AlgoEd25519
+	}
+	v, ok := verifiers[algo]
+	return v, ok
+}
+
+// Verify checks sig over msg with pubKey under the named algorithm.
+// Unknown algorithms and malformed keys never verify.
+func Verify(algo string, pubKey, msg, sig []byte) bool {
+	v, ok := VerifierFor(algo)
+	if !ok {
+		return false
+	}
+	return v.Verify(pubKey, msg, sig)
+}
+
+type ed25519Verifier struct{}
+
+func (ed25519Verifier) Verify(pubKey, msg, sig []byte) bool {
+	if len(pubKey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(pubKey, msg, sig)
+}
+
+type ecdsaP256Verifier struct{}
+
+func (ecdsaP256Verifier) Verify(pubKey, msg, sig []byte) bool {
+	parsed, err := x509.ParsePKIXPublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return false
+	}
+	digest := sha256.Sum256(msg)
+	return ecdsa.VerifyASN1(key, digest[:], sig)
+}
+
+type secp256k1Verifier struct{}
+
+func (secp256k1Verifier) Verify(pubKey, msg, sig []byte) bool {
+	key, err := secp256k1.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	var parsed *secpecdsa.Signature
+	if len(sig) == 64 {
+		var r, s secp256k1.ModNScalar
+		if r.SetByteSlice(sig[:32]) || s.SetByteSlice(sig[32:]) || r.IsZero() || s.IsZero() {
+			return false
+		}
+		parsed = secpecdsa.NewSignature(&r, &s)
+	} else if parsed, err = secpecdsa.ParseDERSignature(sig); err != nil {
+		return false
+	}
+
+	digest := sha256.Sum256(msg)
+	return parsed.Verify(digest[:], key)
+}
+
+type rsaPSSVerifier struct{}
+
+func (rsaPSSVerifier) Verify(pubKey, msg, sig []byte) bool {
+	parsed, err := x509.ParsePKIXPublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok || key.N.BitLen() < MinRSAKeyBits {
+		return false
+	}
+	digest := sha256.Sum256(msg)
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	return rsa.VerifyPSS(key, crypto.SHA256, digest[:], sig, opts) == nil
+}
@@ -0,0 +1,112 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// signed is a public key and a signature over msg under algo
+type signed struct {
+	algo   string
+	pubKey []byte
+	sig    []byte
+}
+
+func TestVerify(t *testing.T) {
+	msg := []byte(`{"action_type":"llm_call","status":"success"}`)
+	digest := sha256.Sum256(msg)
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, MinRSAKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSig, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1Key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1Sig := secpecdsa.Sign(k1Key, digest[:])
+	r, s := k1Sig.R(), k1Sig.S()
+	var rs [64]byte
+	r.PutBytesUnchecked(rs[:32])
+	s.PutBytesUnchecked(rs[32:])
+	der := func(pub interface{}) []byte {
+		b, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	cases := map[string]signed{
+		// An empty sig_algo is Ed25519
+		"default":                {"", edPub, ed25519.Sign(edPriv, msg)},
+		"ed25519":                {AlgoEd25519, edPub, ed25519.Sign(edPriv, msg)},
+		"ecdsa-p256":             {AlgoECDSAP256, der(&ecKey.PublicKey), ecSig},
+		"rsa-pss":                {AlgoRSAPSS, der(&rsaKey.PublicKey), rsaSig},
+		"secp256k1 der":          {AlgoSecp256k1, k1Key.PubKey().SerializeCompressed(), k1Sig.Serialize()},
+		"secp256k1 r||s":         {AlgoSecp256k1, k1Key.PubKey().SerializeCompressed(), rs[:]},
+		"secp256k1 uncompressed": {AlgoSecp256k1, k1Key.PubKey().SerializeUncompressed(), k1Sig.Serialize()},
+	}
+	for name, c := range cases {
+		if !Verify(c.algo, c.pubKey, msg, c.sig) {
+			t.Errorf("%s: valid signature rejected", name)
+		}
+		// Tampering breaks each scheme
+		if Verify(c.algo, c.pubKey, append([]byte("x"), msg...), c.sig) {
+			t.Errorf("%s: tampered message verified", name)
+		}
+	}
+
+	// The same signature under another or an unknown sig_algo never verifies
+	if c := cases["ecdsa-p256"]; Verify(AlgoSecp256k1, c.pubKey, msg, c.sig) {
+		t.Error("P-256 signature verified as secp256k1")
+	}
+	if c := cases["secp256k1 der"]; Verify(AlgoECDSAP256, c.pubKey, msg, c.sig) {
+		t.Error("secp256k1 signature verified as P-256")
+	}
+	if c := cases["ed25519"]; Verify("dsa", c.pubKey, msg, c.sig) {
+		t.Error("unknown sig_algo verified")
+	}
+	if _, ok := VerifierFor("dsa"); ok {
+		t.Error("verifier found for an unknown sig_algo")
+	}
+
+	// RSA keys below MinRSAKeyBits are refused
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallSig, err := rsa.SignPSS(rand.Reader, small, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Verify(AlgoRSAPSS, der(&small.PublicKey), msg, smallSig) {
+		t.Error("1024-bit RSA key accepted")
+	}
+}
@@ -0,0 +1,126 @@
+// Package tracing sets up OpenTelemetry for the Query API and the processor:
+// an OTLP/HTTP trace exporter with W3C trace context propagation, and a
+// gocql observer recording each CQL statement as a span. Both services call
+// Init at startup under their own service.name.
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the CQL statement spans. Until Init installs a provider it
+// is a no-op.
+var tracer = otel.Tracer("github.com/facto-ai/facto/server/shared/tracing")
+
+// Enabled reports whether an OTLP endpoint is configured
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Init installs an OTLP/HTTP trace exporter and W3C trace context
+// propagation when OTEL_EXPORTER_OTLP_ENDPOINT (or the traces-specific
+// variant) is set, reporting spans under serviceName unless
+// OTEL_SERVICE_NAME overrides it. The exporter reads the standard
+// OTEL_EXPORTER_OTLP_* variables itself. Without an endpoint tracing stays a
+// no-op. The returned function flushes buffered spans on shutdown.
+func Init(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+	if env, err := resource.New(ctx, resource.WithFromEnv()); err == nil {
+		res, _ = resource.Merge(res, env)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// CQLObserver is a gocql query and batch observer that records each
+// statement sent to ScyllaDB as a span under the caller's span. Queries made
+// without a span in their context (health pings, background work) are not
+// recorded.
+type CQLObserver struct{}
+
+func (CQLObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	recordCQLSpan(ctx, "cql.query", q.Start, q.End, q.Err,
+		attribute.String("db.statement", compactCQL(q.Statement)),
+		attribute.Int("db.cassandra.rows", q.Rows),
+		attribute.Int("db.cassandra.attempt", q.Attempt),
+	)
+}
+
+func (CQLObserver) ObserveBatch(ctx context.Context, b gocql.ObservedBatch) {
+	recordCQLSpan(ctx, "cql.batch", b.Start, b.End, b.Err,
+		attribute.Int("db.cassandra.batch.statements", len(b.Statements)),
+	)
+}
+
+// recordCQLSpan records an already finished statement as a child span
+func recordCQLSpan(ctx context.Context, name string, start, end time.Time, err error, attrs ...attribute.KeyValue) {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return
+	}
+
+	_, span := tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(semconv.DBSystemCassandra),
+		trace.WithAttributes(attrs...),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// compactCQL collapses a statement's indentation to single spaces
+func compactCQL(statement string) string {
+	return strings.Join(strings.Fields(statement), " ")
+}
+
+// EndSpan records err on span, if any, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
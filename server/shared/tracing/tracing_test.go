@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInitWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if Enabled() {
+		t.Fatal("tracing enabled without an endpoint")
+	}
+	shutdown, err := Init(context.Background(), "facto-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("no-op shutdown: %v", err)
+	}
+}
+
+func TestCQLObserverRecordsChildSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	now := time.Now()
+	// Without a span in the context nothing is recorded
+	CQLObserver{}.ObserveQuery(context.Background(), gocql.ObservedQuery{Start: now, End: now})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	CQLObserver{}.ObserveQuery(ctx, gocql.ObservedQuery{
+		Statement: "SELECT *\n\t\tFROM events\n\t\tWHERE agent_id = ?",
+		Start:     now,
+		End:       now.Add(time.Millisecond),
+		Err:       errors.New("timeout"),
+	})
+	CQLObserver{}.ObserveBatch(ctx, gocql.ObservedBatch{Statements: []string{"INSERT", "INSERT"}, Start: now, End: now})
+	EndSpan(parent, nil)
+
+	var cql []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindClient {
+			cql = append(cql, span)
+		}
+	}
+	if len(cql) != 2 {
+		t.Fatalf("got %d CQL spans, want 2", len(cql))
+	}
+	query, batch := cql[0], cql[1]
+	if query.Name() != "cql.query" || batch.Name() != "cql.batch" {
+		t.Errorf("CQL spans named %q and %q", query.Name(), batch.Name())
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("CQL span isn't a child of the caller's span")
+	}
+	if query.Status().Code.String() != "Error" {
+		t.Errorf("query span status %v, want the statement's error", query.Status())
+	}
+	for _, kv := range query.Attributes() {
+		if kv.Key == "db.statement" && kv.Value.AsString() != "SELECT * FROM events WHERE agent_id = ?" {
+			t.Errorf("db.statement %q not compacted", kv.Value.AsString())
+		}
+	}
+	if !hasAttribute(batch, attribute.Int("db.cassandra.batch.statements", 2)) {
+		t.Errorf("batch span attributes %v", batch.Attributes())
+	}
+}
+
+// hasAttribute reports whether span carries kv
+func hasAttribute(span sdktrace.ReadOnlySpan, kv attribute.KeyValue) bool {
+	for _, got := range span.Attributes() {
+		if got == kv {
+			return true
+		}
+	}
+	return false
+}
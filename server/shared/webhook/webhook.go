@@ -0,0 +1,41 @@
+// Package webhook signs and verifies the webhooks the processor sends. The
+// signature is HMAC-SHA256 keyed with WEBHOOK_SECRET over the raw body, sent
+// as X-Facto-Signature: sha256=<hex>.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// SignatureHeader is the header set on every signed delivery
+const SignatureHeader = "X-Facto-Signature"
+
+// SignaturePrefix is the scheme prefix of the signature header value
+const SignaturePrefix = "sha256="
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("signature mismatch")
+)
+
+// Sign returns the signature header value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's signature header value against its body
+func Verify(secret string, body []byte, signature string) error {
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	if !hmac.Equal([]byte(Sign(secret, body)), []byte(strings.ToLower(signature))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
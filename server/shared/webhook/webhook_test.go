@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignMatchesDocumentedScheme(t *testing.T) {
+	body := []byte(`{"merkle_root":"ab12","event_count":3}`)
+	mac := hmac.New(sha256.New, []byte("whsec-test"))
+	mac.Write(body)
+	if got, want := Sign("whsec-test", body), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec-test"
+	body := []byte(`{"merkle_root":"ab12","event_count":3}`)
+	sig := Sign(secret, body)
+
+	for _, tc := range []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      error
+	}{
+		{"valid", secret, body, sig, nil},
+		{"upper-case hex", secret, body, SignaturePrefix + strings.ToUpper(sig[len(SignaturePrefix):]), nil},
+		{"tampered body", secret, []byte(`{"merkle_root":"ab12","event_count":4}`), sig, ErrInvalidSignature},
+		{"wrong secret", "other", body, sig, ErrInvalidSignature},
+		{"missing signature", secret, body, "", ErrMissingSignature},
+		{"no scheme prefix", secret, body, sig[len(SignaturePrefix):], ErrInvalidSignature},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Verify(tc.secret, tc.body, tc.signature); !errors.Is(err, tc.want) {
+				t.Errorf("Verify = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+#!/usr/bin/env python3
+"""
+In-memory stand-in for the transparency log Facto anchors Merkle roots to.
+
+- POST /anchors             append {root_hash, bucket_time, event_count}; the
+                            response is the receipt the processor stores
+- GET  /anchors/{root_hash} fetch the receipts issued for a root
+
+Usage:
+    python mock_anchor_log.py --port 9300
+
+then run the processor with ANCHOR_URL=http://localhost:9300/anchors.
+"""
+
+import argparse
+import hashlib
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from typing import Any, Dict, List
+
+LOG_ID = "mock-anchor-log"
+
+# Appended entries, in log order
+ENTRIES: List[Dict[str, Any]] = []
+LOCK = threading.Lock()
+
+
+class MockAnchorHandler(BaseHTTPRequestHandler):
+    def _send(self, status: int, body: Any) -> None:
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def do_POST(self) -> None:
+        if self.path.rstrip("/") != "/anchors":
+            self._send(404, {"error": "not found"})
+            return
+
+        entry = json.loads(self.rfile.read(int(self.headers.get("Content-Length", 0))))
+        if not all(key in entry for key in ("root_hash", "bucket_time", "event_count")):
+            self._send(400, {"error": "root_hash, bucket_time and event_count are required"})
+            return
+
+        with LOCK:
+            receipt = {
+                "log_id": LOG_ID,
+                "entry_index": len(ENTRIES),
+                "txid": hashlib.sha256(json.dumps(entry, sort_keys=True).encode()).hexdigest(),
+                "root_hash": entry["root_hash"],
+            }
+            ENTRIES.append({"entry": entry, "receipt": receipt})
+        self._send(200, receipt)
+
+    def do_GET(self) -> None:
+        parts = self.path.strip("/").split("/")
+        if len(parts) != 2 or parts[0] != "anchors":
+            self._send(404, {"error": "not found"})
+            return
+        with LOCK:
+            receipts = [e["receipt"] for e in ENTRIES if e["entry"]["root_hash"] == parts[1]]
+        self._send(200 if receipts else 404, {"receipts": receipts})
+
+    def log_message(self, format: str, *args: Any) -> None:
+        pass
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(description=__doc__)
+    parser.add_argument("--port", type=int, default=9300)
+    args = parser.parse_args()
+    ThreadingHTTPServer(("0.0.0.0", args.port), MockAnchorHandler).serve_forever()
+
+
+if __name__ == "__main__":
+    main()
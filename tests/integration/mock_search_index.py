@@ -0,0 +1,95 @@
+#!/usr/bin/env python3
+"""
+In-memory stand-in for the Elasticsearch/OpenSearch endpoints Facto uses.
+
+Serves just enough of the API for the search integration tests:
+- POST /_bulk                  index actions from the processor
+- POST /{index}/_search        match_phrase on text, term filter on agent_id
+- GET  /{index}/_doc/{id}      fetch a pushed document
+
+Usage:
+    python mock_search_index.py --port 9200
+
+then run the processor and Query API with SEARCH_INDEX_URL=http://localhost:9200.
+"""
+
+import argparse
+import json
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from typing import Any, Dict
+
+# index name -> facto_id -> document
+DOCUMENTS: Dict[str, Dict[str, Dict[str, Any]]] = {}
+
+
+class MockIndexHandler(BaseHTTPRequestHandler):
+    def _send(self, status: int, body: Dict[str, Any]) -> None:
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def _body(self) -> bytes:
+        return self.rfile.read(int(self.headers.get("Content-Length", 0)))
+
+    def do_POST(self) -> None:
+        parts = self.path.strip("/").split("/")
+        if parts == ["_bulk"]:
+            self._bulk()
+        elif len(parts) == 2 and parts[1] == "_search":
+            self._search(parts[0])
+        else:
+            self._send(404, {"error": "not found"})
+
+    def do_GET(self) -> None:
+        parts = self.path.strip("/").split("/")
+        if len(parts) == 3 and parts[1] == "_doc":
+            doc = DOCUMENTS.get(parts[0], {}).get(parts[2])
+            if doc is None:
+                self._send(404, {"found": False})
+            else:
+                self._send(200, {"_id": parts[2], "found": True, "_source": doc})
+        else:
+            self._send(404, {"error": "not found"})
+
+    def _bulk(self) -> None:
+        lines = [line for line in self._body().decode().split("\n") if line]
+        items = []
+        for action_line, doc_line in zip(lines[::2], lines[1::2]):
+            meta = json.loads(action_line)["index"]
+            DOCUMENTS.setdefault(meta["_index"], {})[meta["_id"]] = json.loads(doc_line)
+            items.append({"index": {"_id": meta["_id"], "status": 201}})
+        self._send(200, {"errors": False, "items": items})
+
+    def _search(self, index: str) -> None:
+        request = json.loads(self._body())
+        query = request["query"]["bool"]
+        phrase = query["must"][0]["match_phrase"]["text"].lower()
+        agent_id = None
+        for clause in query.get("filter", []):
+            agent_id = clause["term"]["agent_id"]
+
+        hits = []
+        for facto_id, doc in DOCUMENTS.get(index, {}).items():
+            if phrase not in doc["text"].lower():
+                continue
+            if agent_id is not None and doc["agent_id"] != agent_id:
+                continue
+            hits.append({"_index": index, "_id": facto_id})
+        self._send(200, {"hits": {"hits": hits[: request.get("size", 10)]}})
+
+    def log_message(self, format: str, *args: Any) -> None:
+        pass
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(description=__doc__)
+    parser.add_argument("--port", type=int, default=9200)
+    args = parser.parse_args()
+    ThreadingHTTPServer(("0.0.0.0", args.port), MockIndexHandler).serve_forever()
+
+
+if __name__ == "__main__":
+    main()
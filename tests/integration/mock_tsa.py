@@ -0,0 +1,128 @@
+#!/usr/bin/env python3
+"""
+Fake RFC 3161 Time Stamping Authority for the timestamp integration tests.
+
+- POST /   application/timestamp-query in, application/timestamp-reply out
+
+Each reply carries a TSTInfo echoing the request's message imprint and nonce
+with the current time. The token's SignedData has no signer, so it exercises
+request/response handling and storage but does not verify against any
+certificate chain.
+
+Usage:
+    python mock_tsa.py --port 9400
+
+then run the processor with TSA_URL=http://localhost:9400.
+"""
+
+import argparse
+import itertools
+import threading
+from datetime import datetime, timezone
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from typing import Any, List, Tuple
+
+OID_SIGNED_DATA = "1.2.840.113549.1.7.2"
+OID_TST_INFO = "1.2.840.113549.1.9.16.1.4"
+OID_SHA256 = "2.16.840.1.101.3.4.2.1"
+OID_POLICY = "1.3.6.1.4.1.55555.1"
+
+SERIALS = itertools.count(1)
+SERIAL_LOCK = threading.Lock()
+
+
+def der(tag: int, content: bytes) -> bytes:
+    length = len(content)
+    if length < 0x80:
+        return bytes([tag, length]) + content
+    encoded = length.to_bytes((length.bit_length() + 7) // 8, "big")
+    return bytes([tag, 0x80 | len(encoded)]) + encoded + content
+
+
+def der_int(value: int) -> bytes:
+    return der(0x02, value.to_bytes(value.bit_length() // 8 + 1, "big", signed=True))
+
+
+def der_oid(oid: str) -> bytes:
+    parts = [int(p) for p in oid.split(".")]
+    body = bytearray([parts[0] * 40 + parts[1]])
+    for part in parts[2:]:
+        chunk = [part & 0x7F]
+        part >>= 7
+        while part:
+            chunk.append(0x80 | (part & 0x7F))
+            part >>= 7
+        body.extend(reversed(chunk))
+    return der(0x06, bytes(body))
+
+
+def sequence(*items: bytes) -> bytes:
+    return der(0x30, b"".join(items))
+
+
+def parse(data: bytes) -> List[Tuple[int, bytes, bytes]]:
+    """Split DER into (tag, content, full encoding) triples."""
+    items = []
+    while data:
+        tag, length, offset = data[0], data[1], 2
+        if length & 0x80:
+            size = length & 0x7F
+            length = int.from_bytes(data[2:2 + size], "big")
+            offset += size
+        items.append((tag, data[offset:offset + length], data[:offset + length]))
+        data = data[offset + length:]
+    return items
+
+
+def reply(query: bytes) -> bytes:
+    (_, request, _), = parse(query)
+    fields = parse(request)
+    imprint = fields[1][2]
+    nonce = next((content for tag, content, _ in fields[2:] if tag == 0x02), None)
+
+    with SERIAL_LOCK:
+        serial = next(SERIALS)
+    gen_time = datetime.now(timezone.utc).strftime("%Y%m%d%H%M%SZ").encode()
+
+    tst_info = [der_int(1), der_oid(OID_POLICY), imprint, der_int(serial), der(0x18, gen_time)]
+    if nonce is not None:
+        tst_info.append(der(0x02, nonce))
+
+    signed_data = sequence(
+        der_int(3),
+        der(0x31, sequence(der_oid(OID_SHA256), der(0x05, b""))),
+        sequence(der_oid(OID_TST_INFO), der(0xA0, der(0x04, sequence(*tst_info)))),
+        der(0x31, b""),
+    )
+    token = sequence(der_oid(OID_SIGNED_DATA), der(0xA0, signed_data))
+    return sequence(sequence(der_int(0)), token)
+
+
+class MockTSAHandler(BaseHTTPRequestHandler):
+    def do_POST(self) -> None:
+        query = self.rfile.read(int(self.headers.get("Content-Length", 0)))
+        try:
+            body = reply(query)
+        except (IndexError, ValueError):
+            self.send_response(400)
+            self.end_headers()
+            return
+        self.send_response(200)
+        self.send_header("Content-Type", "application/timestamp-reply")
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+    def log_message(self, format: str, *args: Any) -> None:
+        pass
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(description=__doc__)
+    parser.add_argument("--port", type=int, default=9400)
+    args = parser.parse_args()
+    ThreadingHTTPServer(("0.0.0.0", args.port), MockTSAHandler).serve_forever()
+
+
+if __name__ == "__main__":
+    main()
@@ -243,5 +243,77 @@ class TestCrossComponentVerification:
             Path(bundle_path).unlink()
 
 
+    def test_evidence_package_live_verification_detects_divergence(self, services_ready):
+        """Test that live verification reports events that differ from the store."""
+        session_id = f"test-live-{uuid.uuid4().hex[:8]}"
+
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-live-agent",
+            session_id=session_id,
+            batch_size=1,
+        ))
+        client.record(
+            action_type="live_action",
+            input_data={"query": "live"},
+            output_data={"answer": "stored"},
+            execution_meta=ExecutionMeta(model_id="live-model"),
+        )
+        client.flush()
+        client.close()
+
+        time.sleep(2)
+
+        response = httpx.get(
+            f"{QUERY_API_URL}/v1/evidence-package",
+            params={"session_id": session_id},
+            timeout=30,
+        )
+
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+
+        assert response.status_code == 200
+        bundle = response.json()
+
+        # The untouched package matches the store
+        response = httpx.post(
+            f"{QUERY_API_URL}/v1/evidence-package/verify",
+            params={"live": "true"},
+            json=bundle,
+            timeout=30,
+        )
+        assert response.status_code == 200
+        result = response.json()
+        assert result["valid"], f"Untouched package failed verification: {result}"
+        assert result["live"]["matches"]
+
+        # Diverge the package from the store: one event's hash changes and
+        # another event is not in the store at all
+        event = bundle["events"][0]
+        stored_hash = event["proof"]["event_hash"]
+        event["proof"]["event_hash"] = "f" * 64
+
+        phantom = json.loads(json.dumps(event))
+        phantom["facto_id"] = f"ft-{uuid.uuid4()}"
+        bundle["events"].append(phantom)
+
+        response = httpx.post(
+            f"{QUERY_API_URL}/v1/evidence-package/verify",
+            params={"live": "true"},
+            json=bundle,
+            timeout=30,
+        )
+        assert response.status_code == 200
+        result = response.json()
+        assert not result["valid"]
+        assert not result["live"]["matches"]
+        assert result["live"]["mismatched"] == [{
+            "facto_id": event["facto_id"],
+            "package_hash": "f" * 64,
+            "stored_hash": stored_hash,
+        }]
+        assert result["live"]["missing"] == [phantom["facto_id"]]
+
 if __name__ == "__main__":
     pytest.main([__file__, "-v"])
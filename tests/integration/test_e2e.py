@@ -11,8 +11,18 @@ Prerequisites:
 """
 
 import asyncio
+import base64
+import hashlib
+import hmac
+import io
+import json
+import os
+import shutil
+import subprocess
 import time
 import uuid
+import zipfile
+from pathlib import Path
 from typing import Any, Dict, List
 
 import httpx
@@ -21,11 +31,22 @@ import pytest
 # Import facto SDK
 import sys
 sys.path.insert(0, '../../sdk/python/src')
-from facto import FactoClient, FactoConfig, AsyncFactoClient, verify_event
+from facto import FactoClient, FactoConfig, AsyncFactoClient, ExecutionMeta, verify_event
 
 
 INGESTION_URL = "http://localhost:8080"
 QUERY_API_URL = "http://localhost:8082"
+# Search index the services push to and query (mock_search_index.py in tests)
+SEARCH_INDEX_URL = os.environ.get("SEARCH_INDEX_URL", "http://localhost:9200")
+PROCESSOR_METRICS_URL = os.environ.get("PROCESSOR_METRICS_URL", "http://localhost:8081/metrics")
+# Transparency log the processor anchors Merkle roots to (mock_anchor_log.py in tests)
+ANCHOR_LOG_URL = os.environ.get("ANCHOR_LOG_URL", "http://localhost:9300")
+# gRPC services (GRPC_PORT on the processor and the Query API)
+PROCESSOR_GRPC_ADDR = os.environ.get("PROCESSOR_GRPC_ADDR", "localhost:9091")
+QUERY_API_GRPC_ADDR = os.environ.get("QUERY_API_GRPC_ADDR", "localhost:9092")
+PROTO_DIR = Path(__file__).resolve().parents[2] / "proto"
+# CURSOR_SECRET the Query API signs cursors with; cursor expiry tests need it
+CURSOR_SECRET = os.environ.get("CURSOR_SECRET", "")
 
 
 def wait_for_service(url: str, timeout: int = 60) -> bool:
@@ -42,6 +63,56 @@ def wait_for_service(url: str, timeout: int = 60) -> bool:
     return False
 
 
+def b64url(data: bytes) -> str:
+    """Unpadded base64url, as used in cursors."""
+    return base64.urlsafe_b64encode(data).rstrip(b"=").decode()
+
+
+def b64url_decode(text: str) -> bytes:
+    return base64.urlsafe_b64decode(text + "=" * (-len(text) % 4))
+
+
+def sign_cursor(token: Dict[str, Any], secret: str) -> str:
+    """Sign a cursor token the way the Query API does."""
+    payload = b64url(json.dumps(token, separators=(",", ":")).encode())
+    mac = hmac.new(secret.encode(), payload.encode(), hashlib.sha256).digest()
+    return f"{payload}.{b64url(mac)}"
+
+
+def log_node_hash(left: str, right: str) -> str:
+    """RFC 6962 interior node hash of two hex hashes."""
+    return hashlib.sha256(b"\x01" + bytes.fromhex(left) + bytes.fromhex(right)).hexdigest()
+
+
+def verify_consistency(first: int, second: int, first_root: str, second_root: str, proof: List[str]) -> bool:
+    """Check an RFC 6962 consistency proof as in RFC 9162 section 2.1.4.2."""
+    if first == second:
+        return not proof and first_root == second_root
+    if first <= 0 or first > second or not proof:
+        return False
+    if first & (first - 1) == 0:
+        proof = [first_root] + proof
+    fn, sn = first - 1, second - 1
+    while fn & 1:
+        fn >>= 1
+        sn >>= 1
+    fr = sr = proof[0]
+    for c in proof[1:]:
+        if sn == 0:
+            return False
+        if fn & 1 or fn == sn:
+            fr = log_node_hash(c, fr)
+            sr = log_node_hash(c, sr)
+            while not fn & 1 and fn != 0:
+                fn >>= 1
+                sn >>= 1
+        else:
+            sr = log_node_hash(sr, c)
+        fn >>= 1
+        sn >>= 1
+    return sn == 0 and fr == first_root and sr == second_root
+
+
 @pytest.fixture(scope="module")
 def services_ready():
     """Ensure all services are ready before running tests."""
@@ -104,6 +175,249 @@ class TestQueryAPI:
         data = response.json()
         assert data["status"] == "healthy"
 
+    def test_ready_check(self, services_ready):
+        """Test Query API readiness check reports storage."""
+        response = httpx.get(f"{QUERY_API_URL}/ready")
+        # Ready once ScyllaDB answers; 503 names the storage error otherwise
+        assert response.status_code in [200, 503]
+        data = response.json()
+        if response.status_code == 200:
+            assert data == {"status": "ready", "storage": "ok"}
+        else:
+            assert data["status"] == "not_ready"
+            assert data["storage"]
+
+    def test_processor_ready_check(self, services_ready):
+        """Test processor readiness check reports ScyllaDB and NATS."""
+        ready_url = PROCESSOR_METRICS_URL.rsplit("/", 1)[0] + "/ready"
+        try:
+            response = httpx.get(ready_url, timeout=5)
+        except httpx.HTTPError:
+            pytest.skip("Processor not available")
+        assert response.status_code in [200, 503]
+        data = response.json()
+        assert set(data) == {"status", "storage", "nats"}
+        assert (data["status"] == "ready") == (response.status_code == 200)
+
+    def test_canonical_form_matches_golden(self, query_client: httpx.Client):
+        """Test that the API rebuilds the canonical bytes pinned in tests/golden."""
+        golden = Path(__file__).resolve().parents[1] / "golden"
+        event = json.loads((golden / "canonical_event.json").read_text())
+        expected = (golden / "canonical_event.canonical").read_text()
+
+        response = query_client.post("/v1/verify", params={"dry_run": "true"}, json={"event": event})
+        assert response.status_code == 200
+        data = response.json()
+        assert data["canonical_form"] == expected
+        assert data["checks"]["hash_valid"]
+        assert data["canonical_version"] == 1
+
+    def test_canonical_v3_signs_execution_meta(self, query_client: httpx.Client):
+        """Test that version 3 events sign tags, model_hash, max_tokens and sdk_language."""
+        golden = Path(__file__).resolve().parents[1] / "golden"
+        event = json.loads((golden / "canonical_event_v3.json").read_text())
+        expected = (golden / "canonical_event_v3.canonical").read_text()
+
+        response = query_client.post("/v1/verify", params={"dry_run": "true"}, json={"event": event})
+        assert response.status_code == 200
+        data = response.json()
+        assert data["canonical_form"] == expected
+        assert data["checks"]["hash_valid"]
+        assert data["canonical_version"] == 3
+
+        for field, value in [("tags", {"env": "prod"}), ("model_hash", "sha256:def456"), ("max_tokens", 1), ("sdk_language", "go")]:
+            tampered = json.loads(json.dumps(event))
+            tampered["execution_meta"][field] = value
+            response = query_client.post("/v1/verify", json={"event": tampered})
+            assert response.status_code == 200
+            assert not response.json()["checks"]["hash_valid"], field
+
+    def test_unknown_canonical_version_fails(self, query_client: httpx.Client):
+        """Test that an event declaring an unknown canonical_version never verifies."""
+        golden = Path(__file__).resolve().parents[1] / "golden"
+        event = json.loads((golden / "canonical_event.json").read_text())
+
+        event["proof"]["canonical_version"] = 2
+        response = query_client.post("/v1/verify", json={"event": event})
+        assert response.status_code == 200
+        assert response.json()["checks"]["hash_valid"]
+
+        event["proof"]["canonical_version"] = 99
+        response = query_client.post("/v1/verify", json={"event": event})
+        assert response.status_code == 200
+        assert not response.json()["checks"]["hash_valid"]
+        assert not response.json()["valid"]
+
+    def test_verify_diff_reports_tampered_field(self, query_client: httpx.Client):
+        """Test that diff=true returns the recomputed canonical form of a tampered event."""
+        golden = Path(__file__).resolve().parents[1] / "golden"
+        event = json.loads((golden / "canonical_event.json").read_text())
+        signed = (golden / "canonical_event.canonical").read_text()
+
+        response = query_client.post("/v1/verify", params={"diff": "true"}, json={"event": event})
+        assert response.status_code == 200
+        assert "diff" not in response.json()
+
+        event["output_data"]["answer"] = "5"
+        response = query_client.post(
+            "/v1/verify",
+            params={"diff": "true"},
+            json={"event": event, "signed_form": signed},
+        )
+        assert response.status_code == 200
+        data = response.json()
+        assert not data["checks"]["hash_valid"]
+        diff = data["diff"]
+        assert diff["claimed_hash"] == event["proof"]["event_hash"]
+        assert diff["computed_hash"] != diff["claimed_hash"]
+        assert json.loads(diff["canonical_form"])["output_data"]["answer"] == "5"
+        assert diff["canonical_form"] == signed.replace('"answer":"4"', '"answer":"5"')
+        assert diff["changed_fields"] == ["output_data.answer"]
+
+        response = query_client.post(
+            "/v1/verify",
+            params={"diff": "true"},
+            json={"event": event, "signed_form": "not json"},
+        )
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_request"
+
+    def test_verify_batch(self, query_client: httpx.Client):
+        """Test batch verification of valid and tampered events, in request order."""
+        golden = Path(__file__).resolve().parents[1] / "golden"
+        event = json.loads((golden / "canonical_event.json").read_text())
+        vector = json.loads((golden / "signature_vectors.json").read_text())["vectors"][0]
+        event["proof"].update(public_key=vector["public_key"], signature=vector["signature"])
+
+        tampered = json.loads(json.dumps(event))
+        tampered["output_data"]["answer"] = "5"
+        bad_signature = json.loads(json.dumps(event))
+        bad_signature["proof"]["signature"] = ""
+
+        events = [event, tampered, event, bad_signature]
+        response = query_client.post("/v1/verify/batch", json={"events": events})
+        assert response.status_code == 200
+        data = response.json()
+        assert data["summary"] == {"total": 4, "valid": 2, "invalid": 2}
+        assert [r["valid"] for r in data["results"]] == [True, False, True, False]
+        assert data["results"][1]["checks"]["hash_valid"] is False
+        assert data["results"][3]["checks"] == {"hash_valid": True, "signature_valid": False, "chain_valid": None}
+
+        response = query_client.post("/v1/verify/batch", json={"events": []})
+        assert response.status_code == 400
+
+    def test_verify_batch_rejects_oversized_batch(self, query_client: httpx.Client):
+        """Test that batches over MAX_BATCH_VERIFY_EVENTS get a 413."""
+        golden = Path(__file__).resolve().parents[1] / "golden"
+        event = json.loads((golden / "canonical_event.json").read_text())
+        limit = int(os.environ.get("MAX_BATCH_VERIFY_EVENTS", "1000"))
+
+        response = query_client.post("/v1/verify/batch", json={"events": [event] * (limit + 1)})
+        assert response.status_code == 413
+        body = response.json()
+        assert body["code"] == "too_large"
+        assert body["details"] == {"event_count": limit + 1, "limit": limit}
+
+    def test_signature_vectors(self, query_client: httpx.Client):
+        """Test each sig_algo against the vectors pinned in tests/golden."""
+        golden = Path(__file__).resolve().parents[1] / "golden"
+        event = json.loads((golden / "canonical_event.json").read_text())
+        vectors = json.loads((golden / "signature_vectors.json").read_text())["vectors"]
+
+        for vector in vectors:
+            event["proof"].update(
+                sig_algo=vector["sig_algo"],
+                public_key=vector["public_key"],
+                signature=vector["signature"],
+            )
+            response = query_client.post("/v1/verify", json={"event": event})
+            assert response.status_code == 200
+            checks = response.json()["checks"]
+            assert checks["hash_valid"]
+            assert checks["signature_valid"] == vector["valid"], vector.get("note", vector["sig_algo"])
+
+    def test_rejects_overly_long_session_id(self, query_client: httpx.Client):
+        """Test that session ids over ID_MAX_LENGTH are rejected."""
+        response = query_client.get(f"/v1/sessions/{'s' * 129}/events")
+        assert response.status_code == 400
+        assert "session_id exceeds" in response.json()["error"]
+
+    def test_rejects_control_character_agent_id(self, query_client: httpx.Client):
+        """Test that agent ids containing control characters are rejected."""
+        response = query_client.get(
+            "/v1/agents/agent%0Aone/events",
+            params={"date": "2024-01-01"},
+        )
+        assert response.status_code == 400
+        assert "agent_id contains disallowed character" in response.json()["error"]
+
+        response = query_client.get(
+            "/v1/verify/batch-chain",
+            params={"agent_id": "agent\x00one", "date": "2024-01-01"},
+        )
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_id"
+
+    def test_events_errors_are_typed(self, query_client: httpx.Client):
+        """Test that GET /v1/events failures carry a stable error code."""
+        start, end = "2024-01-01T00:00:00Z", "2024-01-01T01:00:00Z"
+        cases = [
+            ({"start": start, "end": end}, "invalid_request"),
+            ({"agent_id": "a", "start": "yesterday", "end": end}, "invalid_time_format"),
+            ({"agent_id": "a", "start": start, "end": "today"}, "invalid_time_format"),
+            ({"agent_id": "a", "start": end, "end": start}, "invalid_time_range"),
+            ({"agent_id": "a", "start": start, "end": end, "cursor": "@@@"}, "invalid_cursor"),
+            ({"agent_id": "a", "start": start, "end": end, "tag": "no-colon"}, "invalid_filter"),
+            ({"agent_id": "a", "start": start, "end": end, "json_path": "$["}, "invalid_filter"),
+        ]
+        for params, code in cases:
+            response = query_client.get("/v1/events", params=params)
+            assert response.status_code == 400, params
+            body = response.json()
+            assert body["code"] == code, params
+            assert body["message"]
+            assert body["error"] == body["message"]
+
+    def test_events_partition_metrics(self, query_client: httpx.Client):
+        """Test that GET /v1/events records how many date partitions it scanned."""
+        def histogram(name: str) -> Dict[str, float]:
+            values = {}
+            for line in query_client.get("/metrics").text.splitlines():
+                if line.startswith(f"{name}_sum ") or line.startswith(f"{name}_count "):
+                    key, value = line.split()
+                    values[key[len(name) + 1:]] = float(value)
+            return values
+
+        before = histogram("facto_api_query_partitions")
+        if not before:
+            pytest.skip("Query API does not export partition metrics")
+        scans_before = histogram("facto_api_partition_scan_duration_seconds")
+
+        # An agent with no events reads every partition in the range
+        response = query_client.get("/v1/events", params={
+            "agent_id": f"test-agent-{uuid.uuid4().hex[:8]}",
+            "start": "2024-01-01T00:00:00Z",
+            "end": "2024-01-03T12:00:00Z",
+        })
+        assert response.status_code == 200
+
+        after = histogram("facto_api_query_partitions")
+        assert after["count"] == before["count"] + 1
+        assert after["sum"] == before["sum"] + 3
+        scans_after = histogram("facto_api_partition_scan_duration_seconds")
+        assert scans_after["count"] == scans_before["count"] + 3
+
+    def test_verify_errors_are_typed(self, query_client: httpx.Client):
+        """Test that POST /v1/verify failures carry a stable error code."""
+        response = query_client.post("/v1/verify", content=b"not json",
+                                     headers={"Content-Type": "application/json"})
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_request"
+
+        response = query_client.post("/v1/verify", json={"event": "not an object"})
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_request"
+
 
 class TestEndToEnd:
     """End-to-end integration tests."""
@@ -216,6 +530,434 @@ class TestEndToEnd:
         assert hash_valid, "Event hash should be valid"
         assert sig_valid, "Event signature should be valid"
 
+    def test_events_cursor_pagination(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that GET /v1/events pages resume where the previous page ended."""
+        facto_ids = set()
+        for i in range(6):
+            facto_ids.add(facto_client.record(
+                action_type=f"page_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            ))
+        facto_client.flush()
+
+        time.sleep(3)
+
+        now = time.time()
+        params = {
+            "agent_id": facto_client.config.agent_id,
+            "start": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now - 3600)),
+            "end": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now + 3600)),
+            "limit": 2,
+        }
+
+        pages = []
+        cursor = None
+        for _ in range(3):
+            page_params = dict(params, cursor=cursor) if cursor else params
+            response = query_client.get("/v1/events", params=page_params)
+            assert response.status_code == 200
+            data = response.json()
+            pages.append([e["facto_id"] for e in data["events"]])
+            cursor = data["next_cursor"]
+            if cursor is None:
+                break
+
+        seen = [facto_id for page in pages for facto_id in page]
+        if len(seen) < 6:
+            pytest.skip("Events not yet processed")
+
+        assert [len(page) for page in pages] == [2, 2, 2]
+        assert len(set(seen)) == len(seen), "pages overlap"
+        assert set(seen) == facto_ids, "pages skipped events"
+        assert cursor is None
+
+        response = query_client.get("/v1/events", params=dict(params, cursor="bm90LWEtY3Vyc29y"))
+        assert response.status_code == 400
+
+    def test_cursors_are_signed_and_scoped(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that altered cursors and cursors reused for another listing are rejected."""
+        for i in range(3):
+            facto_client.record(action_type=f"signed_cursor_{i}", input_data={}, output_data={})
+        facto_client.flush()
+
+        time.sleep(3)
+
+        now = time.time()
+        params = {
+            "agent_id": facto_client.config.agent_id,
+            "start": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now - 3600)),
+            "end": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now + 3600)),
+            "limit": 1,
+        }
+        response = query_client.get("/v1/events", params=params)
+        assert response.status_code == 200
+        cursor = response.json()["next_cursor"]
+        if cursor is None:
+            pytest.skip("Events not yet processed")
+
+        payload, mac = cursor.split(".")
+        token = json.loads(b64url_decode(payload))
+        assert token["v"] == 1
+        assert token["k"] == "events"
+        assert token["s"] == facto_client.config.agent_id
+        assert token["o"] == "asc"
+
+        # Pointing the cursor at another event invalidates the signature
+        forged = dict(token, f=f"ft-{uuid.uuid4()}")
+        forged_cursor = f"{b64url(json.dumps(forged).encode())}.{mac}"
+        # A cursor for one agent is no good for another
+        other_agent = dict(params, agent_id=f"other-{uuid.uuid4().hex[:8]}")
+        for page_params in (
+            dict(params, cursor=forged_cursor),
+            dict(params, cursor=payload),
+            dict(params, cursor=cursor + "x"),
+            dict(other_agent, cursor=cursor),
+        ):
+            response = query_client.get("/v1/events", params=page_params)
+            assert response.status_code == 400, page_params
+            assert response.json()["code"] == "invalid_cursor"
+
+        response = query_client.get(f"/v1/sessions/session-{uuid.uuid4().hex[:8]}/events", params={"cursor": cursor})
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_cursor"
+
+        response = query_client.get("/v1/events", params=dict(params, cursor=cursor))
+        assert response.status_code == 200
+
+    def test_expired_cursor_rejected(self, query_client: httpx.Client):
+        """Test that a correctly signed cursor past CURSOR_TTL_MS is rejected."""
+        if not CURSOR_SECRET:
+            pytest.skip("CURSOR_SECRET not set")
+
+        agent_id = f"agent-{uuid.uuid4().hex[:8]}"
+        token = {
+            "v": 1,
+            "k": "events",
+            "s": agent_id,
+            "o": "asc",
+            "d": "2024-01-01",
+            "t": 1704067200000000000,
+            "f": f"ft-{uuid.uuid4()}",
+        }
+        params = {
+            "agent_id": agent_id,
+            "start": "2024-01-01T00:00:00Z",
+            "end": "2024-01-02T00:00:00Z",
+        }
+
+        response = query_client.get("/v1/events", params=dict(params, cursor=sign_cursor(dict(token, i=0), CURSOR_SECRET)))
+        assert response.status_code == 400
+        assert response.json()["code"] == "cursor_expired"
+
+        fresh = sign_cursor(dict(token, i=int(time.time())), CURSOR_SECRET)
+        response = query_client.get("/v1/events", params=dict(params, cursor=fresh))
+        assert response.status_code == 200
+        assert response.json()["events"] == []
+
+    def test_session_events_cursor_pagination(self, services_ready, query_client: httpx.Client):
+        """Test that session event pages resume where the previous page ended."""
+        session_id = f"test-session-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-session-cursor",
+            session_id=session_id,
+        ))
+        facto_ids = [
+            client.record(action_type=f"session_page_{i}", input_data={"index": i}, output_data={})
+            for i in range(5)
+        ]
+        client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/sessions/{session_id}/events")
+        assert response.status_code == 200
+        if len(response.json()["events"]) < len(facto_ids):
+            pytest.skip("Events not yet processed")
+        in_order = [e["facto_id"] for e in response.json()["events"]]
+
+        seen = []
+        cursor = None
+        while True:
+            params = {"limit": 2}
+            if cursor:
+                params["cursor"] = cursor
+            response = query_client.get(f"/v1/sessions/{session_id}/events", params=params)
+            assert response.status_code == 200
+            data = response.json()
+            seen.extend(e["facto_id"] for e in data["events"])
+            cursor = data["next_cursor"]
+            if cursor is None:
+                break
+            assert "." in cursor
+            assert len(seen) <= len(facto_ids), "pagination did not advance"
+
+        assert seen == in_order
+        assert sorted(seen) == sorted(facto_ids)
+
+    def test_events_order(self, services_ready, query_client: httpx.Client):
+        """Test order=asc and order=desc on GET /v1/events, paged without gaps."""
+        agent_id = f"test-agent-order-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(endpoint=INGESTION_URL, agent_id=agent_id))
+        facto_ids = {
+            client.record(action_type=f"ordered_{i}", input_data={"index": i}, output_data={})
+            for i in range(5)
+        }
+        client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        now = time.time()
+        params = {
+            "agent_id": agent_id,
+            "start": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now - 3600)),
+            "end": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now + 3600)),
+            "limit": 2,
+        }
+
+        def fetch(order: str) -> List[Dict[str, Any]]:
+            events = []
+            cursor = None
+            while True:
+                page_params = dict(params, order=order)
+                if cursor:
+                    page_params["cursor"] = cursor
+                response = query_client.get("/v1/events", params=page_params)
+                assert response.status_code == 200
+                data = response.json()
+                events.extend(data["events"])
+                cursor = data["next_cursor"]
+                if cursor is None:
+                    return events
+
+        desc = fetch("desc")
+        if len(desc) < len(facto_ids):
+            pytest.skip("Events not yet processed")
+        asc = fetch("asc")
+
+        assert {e["facto_id"] for e in asc} == facto_ids
+        assert len(asc) == len(facto_ids), "pages overlap"
+        times = [e["completed_at"] for e in asc]
+        assert times == sorted(times)
+        assert [e["facto_id"] for e in desc] == [e["facto_id"] for e in reversed(asc)]
+
+        # The default is oldest first
+        response = query_client.get("/v1/events", params=dict(params, limit=5))
+        assert [e["facto_id"] for e in response.json()["events"]] == [e["facto_id"] for e in asc]
+
+        # A cursor only resumes the order it was issued for
+        cursor = query_client.get("/v1/events", params=dict(params, order="asc")).json()["next_cursor"]
+        response = query_client.get("/v1/events", params=dict(params, order="desc", cursor=cursor))
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_cursor"
+
+        response = query_client.get("/v1/events", params=dict(params, order="sideways"))
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_parameter"
+
+    def test_session_events_order(self, services_ready, query_client: httpx.Client):
+        """Test order=desc on session events, paged without gaps."""
+        session_id = f"test-session-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-session-order",
+            session_id=session_id,
+        ))
+        for i in range(5):
+            client.record(action_type=f"session_ordered_{i}", input_data={"index": i}, output_data={})
+        client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/sessions/{session_id}/events", params={"include_session_hash": "true"})
+        chain = response.json()
+        if len(chain["events"]) < 5:
+            pytest.skip("Events not yet processed")
+
+        desc = []
+        cursor = None
+        while True:
+            params = {"limit": 2, "order": "desc"}
+            if cursor:
+                params["cursor"] = cursor
+            response = query_client.get(f"/v1/sessions/{session_id}/events", params=params)
+            assert response.status_code == 200
+            data = response.json()
+            desc.extend(e["facto_id"] for e in data["events"])
+            cursor = data["next_cursor"]
+            if cursor is None:
+                break
+
+        assert desc == [e["facto_id"] for e in reversed(chain["events"])]
+
+        # The session hash is taken in chain order either way
+        response = query_client.get(
+            f"/v1/sessions/{session_id}/events",
+            params={"order": "desc", "include_session_hash": "true"},
+        )
+        assert response.json()["session_hash"] == chain["session_hash"]
+
+    def test_include_total(self, services_ready, query_client: httpx.Client):
+        """Test that include_total counts all matching events, not just the page."""
+        agent_id = f"test-agent-total-{uuid.uuid4().hex[:8]}"
+        session_id = f"test-session-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(endpoint=INGESTION_URL, agent_id=agent_id, session_id=session_id))
+        seeded = 7
+        for i in range(seeded):
+            client.record(action_type="counted", input_data={"index": i}, output_data={})
+        client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        now = time.time()
+        params = {
+            "agent_id": agent_id,
+            "start": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now - 3600)),
+            "end": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now + 3600)),
+            "limit": 2,
+        }
+        response = query_client.get("/v1/events", params=params)
+        assert response.status_code == 200
+        assert "total" not in response.json()
+
+        response = query_client.get("/v1/events", params=dict(params, include_total="true"))
+        assert response.status_code == 200
+        data = response.json()
+        if data["total"] < seeded:
+            pytest.skip("Events not yet processed")
+        assert data["total"] == seeded
+        assert len(data["events"]) == 2
+
+        response = query_client.get(f"/v1/sessions/{session_id}/events", params={"limit": 2, "include_total": "true"})
+        assert response.status_code == 200
+        data = response.json()
+        assert data["total"] == seeded
+        assert len(data["events"]) == 2
+
+        response = query_client.get("/v1/events", params=dict(params, include_total="true", tag="env:prod"))
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_filter"
+
+    def test_events_filter_by_action_type_and_status(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test the action_type and status filters on GET /v1/events, alone and combined."""
+        seeded = {}
+        for action_type, status in [
+            ("tool_call", "success"),
+            ("tool_call", "failed"),
+            ("tool_call", "failed"),
+            ("llm_call", "failed"),
+            ("llm_call", "success"),
+        ]:
+            facto_id = facto_client.record(
+                action_type=action_type,
+                input_data={},
+                output_data={},
+                status=status,
+            )
+            seeded[facto_id] = (action_type, status)
+        facto_client.flush()
+
+        time.sleep(3)
+
+        now = time.time()
+        params = {
+            "agent_id": facto_client.config.agent_id,
+            "start": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now - 3600)),
+            "end": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now + 3600)),
+        }
+
+        def fetch(**filters) -> List[str]:
+            """Page through the filtered events one at a time."""
+            facto_ids = []
+            cursor = None
+            while True:
+                page_params = dict(params, limit=1, **filters)
+                if cursor:
+                    page_params["cursor"] = cursor
+                response = query_client.get("/v1/events", params=page_params)
+                assert response.status_code == 200
+                data = response.json()
+                facto_ids.extend(e["facto_id"] for e in data["events"])
+                cursor = data["next_cursor"]
+                if not cursor:
+                    return facto_ids
+
+        if len(fetch()) < len(seeded):
+            pytest.skip("Events not yet processed")
+
+        def expected(action_type=None, status=None) -> List[str]:
+            return sorted(
+                facto_id for facto_id, (a, s) in seeded.items()
+                if (action_type is None or a == action_type) and (status is None or s == status)
+            )
+
+        assert sorted(fetch(action_type="tool_call")) == expected(action_type="tool_call")
+        assert sorted(fetch(status="failed")) == expected(status="failed")
+        combined = fetch(action_type="tool_call", status="failed")
+        assert sorted(combined) == expected(action_type="tool_call", status="failed")
+        assert len(combined) == 2
+        assert fetch(action_type="no_such_action") == []
+
+    def test_events_filter_by_tags(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test tag=key:value filters on GET /v1/events, alone and ANDed together."""
+        seeded = {}
+        for tags in [
+            {"env": "prod", "team": "search"},
+            {"env": "prod", "team": "billing"},
+            {"env": "staging", "team": "search"},
+            {"env": "prod", "url": "https://example.com:8443/x"},
+        ]:
+            facto_id = facto_client.record(
+                action_type="tagged",
+                input_data={},
+                output_data={},
+                execution_meta=ExecutionMeta(tags=tags),
+            )
+            seeded[facto_id] = tags
+        facto_client.flush()
+
+        time.sleep(3)
+
+        now = time.time()
+        params = {
+            "agent_id": facto_client.config.agent_id,
+            "start": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now - 3600)),
+            "end": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime(now + 3600)),
+            "limit": 50,
+        }
+
+        def fetch(*tags: str) -> List[str]:
+            response = query_client.get("/v1/events", params=dict(params, tag=list(tags)))
+            assert response.status_code == 200
+            return sorted(e["facto_id"] for e in response.json()["events"])
+
+        def expected(**tags: str) -> List[str]:
+            return sorted(
+                facto_id for facto_id, event_tags in seeded.items()
+                if all(event_tags.get(k) == v for k, v in tags.items())
+            )
+
+        if len(fetch()) < len(seeded):
+            pytest.skip("Events not yet processed")
+
+        assert fetch("env:prod") == expected(env="prod")
+        assert len(fetch("env:prod")) == 3
+        assert fetch("env:prod", "team:search") == expected(env="prod", team="search")
+        assert len(fetch("env:prod", "team:search")) == 1
+        assert fetch("env:staging", "team:billing") == []
+
+        # Everything after the first colon is the value
+        assert fetch("url:https://example.com:8443/x") == expected(url="https://example.com:8443/x")
+
+        for bad in (["no-colon"], [":value"], ["env:prod", "env:staging"]):
+            response = query_client.get("/v1/events", params=dict(params, tag=bad))
+            assert response.status_code == 400
+
     def test_session_events(self, services_ready, query_client: httpx.Client):
         """Test querying events by session."""
         session_id = f"test-session-{uuid.uuid4().hex[:8]}"
@@ -249,6 +991,1279 @@ class TestEndToEnd:
             # Events should be in the session
             assert "events" in data
 
+    def test_interleaved_sessions_stored_in_order(self, services_ready, query_client: httpx.Client):
+        """Test that interleaved sessions each keep their submission order, whichever workers handle them."""
+        clients = {}
+        facto_ids = {}
+        for name in ("a", "b"):
+            session_id = f"test-order-{name}-{uuid.uuid4().hex[:8]}"
+            clients[session_id] = FactoClient(FactoConfig(
+                endpoint=INGESTION_URL,
+                agent_id="test-agent-order",
+                session_id=session_id,
+                batch_size=1,
+            ))
+            facto_ids[session_id] = []
+
+        for i in range(6):
+            for session_id, client in clients.items():
+                facto_ids[session_id].append(client.record(
+                    action_type=f"order_action_{i}",
+                    input_data={"index": i},
+                    output_data={"result": i},
+                ))
+                client.flush()
+        for client in clients.values():
+            client.close()
+
+        time.sleep(3)
+
+        for session_id, ids in facto_ids.items():
+            response = query_client.get("/v1/verify/chain", params={"session_id": session_id})
+            if response.status_code == 404:
+                pytest.skip("Events not yet processed")
+            data = response.json()
+            assert data["valid"], data
+            assert data["event_count"] == len(ids)
+            assert data["first_event"] == ids[0]
+            assert data["last_event"] == ids[-1]
+
+            events = query_client.get(f"/v1/sessions/{session_id}/events").json()["events"]
+            by_id = {event["facto_id"]: event for event in events}
+            assert set(by_id) == set(ids)
+
+            # With STORE_STREAM_SEQ=true, the stream order matches submission order
+            seqs = [by_id[facto_id].get("stream_seq") for facto_id in ids]
+            if None not in seqs:
+                assert seqs == sorted(seqs)
+
+    def test_session_events_ndjson_stream(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that format=ndjson streams every session event, one per line."""
+        count = 25
+        for i in range(count):
+            facto_client.record(
+                action_type="stream",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        session_id = facto_client.config.session_id
+        events = []
+        with query_client.stream(
+            "GET", f"/v1/sessions/{session_id}/events", params={"format": "ndjson"}
+        ) as response:
+            assert response.status_code == 200
+            assert response.headers["content-type"] == "application/x-ndjson"
+            for line in response.iter_lines():
+                if line:
+                    events.append(json.loads(line))
+
+        if len(events) < count:
+            pytest.skip("Events not yet processed")
+
+        assert len(events) == count
+        assert all(e["session_id"] == session_id for e in events)
+        assert sorted(e["input_data"]["index"] for e in events) == list(range(count))
+
+        # The stream holds the same events as the paged JSON listing
+        response = query_client.get(f"/v1/sessions/{session_id}/events", params={"limit": 1000})
+        assert response.status_code == 200
+        assert [e["facto_id"] for e in events] == [e["facto_id"] for e in response.json()["events"]]
+
+        response = query_client.get(f"/v1/sessions/{session_id}/events", params={"format": "xml"})
+        assert response.status_code == 400
+
+    def test_session_and_facto_id_reads_share_canonical_form(self, services_ready, query_client: httpx.Client):
+        """Test that an event verifies the same whichever endpoint returned it."""
+        session_id = f"test-canon-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-canon",
+            session_id=session_id,
+            batch_size=1,
+        ))
+        facto_id = client.record(
+            action_type="llm_call",
+            input_data={"prompt": "2+2"},
+            output_data={"answer": "4"},
+            execution_meta=ExecutionMeta(
+                model_id="gpt-4",
+                model_hash="sha256:abc",
+                seed=42,
+                max_tokens=64,
+                tool_calls=[{"name": "calculator", "args": {"expr": "2+2"}}],
+            ),
+        )
+        client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        by_id = query_client.get(f"/v1/events/{facto_id}")
+        if by_id.status_code == 404:
+            pytest.skip("Event not yet processed")
+        by_session = query_client.get(f"/v1/sessions/{session_id}/events")
+        assert by_session.status_code == 200
+        session_event = next(e for e in by_session.json()["events"] if e["facto_id"] == facto_id)
+
+        forms = []
+        for event in (by_id.json(), session_event):
+            response = query_client.post("/v1/verify", params={"dry_run": "true"}, json={"event": event})
+            data = response.json()
+            assert data["checks"]["hash_valid"], data
+            forms.append(data["canonical_form"])
+        assert forms[0] == forms[1]
+        assert session_event["execution_meta"]["max_tokens"] == 64
+        assert session_event["execution_meta"]["model_hash"] == "sha256:abc"
+
+    def test_zero_valued_meta_round_trips(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that seed=0 and max_tokens=0 are not read back as absent."""
+        facto_id = facto_client.record(
+            action_type="llm_call",
+            input_data={"prompt": "deterministic"},
+            output_data={"answer": "ok"},
+            execution_meta=ExecutionMeta(model_id="gpt-4", temperature=0.7, seed=0, max_tokens=0),
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/events/{facto_id}")
+        if response.status_code == 404:
+            pytest.skip("Event not yet processed")
+        event = response.json()
+        assert event["execution_meta"]["seed"] == 0
+        assert event["execution_meta"]["max_tokens"] == 0
+        assert event["execution_meta"]["temperature"] == 0.7
+
+        response = query_client.post("/v1/verify", json={"event": event})
+        assert response.json()["checks"]["hash_valid"]
+
+    def test_ranged_session_export(self, services_ready, query_client: httpx.Client):
+        """Test resuming a session export with an event-offset Range."""
+        session_id = f"test-export-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-export",
+            session_id=session_id,
+            batch_size=1,
+        ))
+        for i in range(4):
+            client.record(
+                action_type=f"export_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+            client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/sessions/{session_id}/export")
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        assert response.status_code == 200
+        assert response.headers["accept-ranges"] == "events"
+        full = response.text.splitlines()
+        assert len(full) == 4
+
+        response = query_client.get(
+            f"/v1/sessions/{session_id}/export",
+            headers={"Range": "events=1-2"},
+        )
+        assert response.status_code == 206
+        assert response.headers["content-range"] == "events 1-2/4"
+        assert response.text.splitlines() == full[1:3]
+
+        response = query_client.get(
+            f"/v1/sessions/{session_id}/export",
+            headers={"Range": "events=4-"},
+        )
+        assert response.status_code == 416
+        assert response.headers["content-range"] == "events */4"
+
+    def test_verify_chain_endpoint(self, services_ready, query_client: httpx.Client):
+        """Test verifying a session chain via GET /v1/verify/chain."""
+        session_id = f"test-chain-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-chain",
+            session_id=session_id,
+            batch_size=1,
+        ))
+        facto_ids = []
+        for i in range(5):
+            facto_ids.append(client.record(
+                action_type=f"chain_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            ))
+            client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get("/v1/verify/chain", params={"session_id": session_id})
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        assert response.status_code == 200
+        data = response.json()
+        assert data["valid"], data
+        assert data["event_count"] == 5
+        assert data["first_event"] == facto_ids[0]
+        assert data["last_event"] == facto_ids[-1]
+
+        # The SDK starts chains at the all-zero hash
+        response = query_client.get(
+            "/v1/verify/chain",
+            params={"session_id": session_id, "genesis": "0" * 64},
+        )
+        assert response.json()["valid"]
+
+        # A different anchor is a genesis mismatch, not a broken link
+        response = query_client.get(
+            "/v1/verify/chain",
+            params={"session_id": session_id, "genesis": "a" * 64},
+        )
+        data = response.json()
+        assert not data["valid"]
+        assert data["errors"][0].startswith("Genesis mismatch at event: " + facto_ids[0])
+        assert not any(e.startswith("Chain link broken") for e in data["errors"])
+
+        response = query_client.get(
+            "/v1/verify/chain",
+            params={"session_id": session_id, "genesis": "not-a-hash"},
+        )
+        assert response.status_code == 400
+
+        # Without a genesis, a chain starting mid-stream is anchored at its
+        # first event, while a missing middle event breaks a link
+        bundle = query_client.get("/v1/evidence-package", params={"session_id": session_id}).json()
+        events = bundle["events"]
+
+        bundle["events"] = events[1:]
+        data = query_client.post("/v1/evidence-package/verify", json=bundle).json()
+        assert data["chain"]["checks"]["chain_integrity_valid"], data["chain"]
+
+        bundle["events"] = events[:2] + events[3:]
+        data = query_client.post("/v1/evidence-package/verify", json=bundle).json()
+        assert not data["chain"]["checks"]["chain_integrity_valid"]
+        assert all(e.startswith("Chain link broken") for e in data["chain"]["errors"])
+        assert events[3]["facto_id"] in data["chain"]["errors"][0]
+
+    def test_evidence_package_verify_reports_per_event(self, services_ready, query_client: httpx.Client):
+        """Test server-side evidence package verification with tampered events and proofs."""
+        session_id = f"test-package-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-package",
+            session_id=session_id,
+            batch_size=1,
+        ))
+        for i in range(3):
+            client.record(
+                action_type=f"package_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+            client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get("/v1/evidence-package", params={"session_id": session_id})
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        package = response.json()
+        if len(package["events"]) < 3:
+            pytest.skip("Events not yet processed")
+
+        data = query_client.post("/v1/evidence-package/verify", json=package).json()
+        assert data["valid"], data
+        assert [e["facto_id"] for e in data["events"]] == [e["facto_id"] for e in package["events"]]
+        assert all(e["hash_valid"] and e["signature_valid"] and e["proof_valid"] for e in data["events"])
+
+        # A tampered event fails its hash and signature; its proof still
+        # covers the recorded event_hash
+        tampered = json.loads(json.dumps(package))
+        tampered["events"][1]["output_data"] = {"result": "forged"}
+        data = query_client.post("/v1/evidence-package/verify", json=tampered).json()
+        assert not data["valid"]
+        assert not data["events"][1]["hash_valid"]
+        assert not data["events"][1]["signature_valid"]
+        assert data["events"][1]["proof_valid"]
+        assert data["events"][0]["hash_valid"] and data["events"][2]["hash_valid"]
+
+        # A tampered proof element no longer recomputes the root
+        tampered = json.loads(json.dumps(package))
+        facto_id = tampered["events"][2]["facto_id"]
+        proof = next(p for p in tampered["merkle_proofs"] if p["facto_id"] == facto_id)
+        proof["proof"][0]["hash"] = "f" * 64
+        data = query_client.post("/v1/evidence-package/verify", json=tampered).json()
+        assert not data["valid"]
+        assert not data["proofs_valid"]
+        assert not data["events"][2]["proof_valid"]
+        assert data["events"][2]["hash_valid"]
+        assert "Merkle proof invalid for event: " + facto_id in data["errors"]
+
+        # A proof missing for an event is a count mismatch
+        tampered = json.loads(json.dumps(package))
+        tampered["merkle_proofs"] = tampered["merkle_proofs"][:-1]
+        data = query_client.post("/v1/evidence-package/verify", json=tampered).json()
+        assert not data["proofs_valid"]
+        assert data["errors"][0] == "Package has 3 events but 2 Merkle proofs"
+
+        tampered["events"] = []
+        response = query_client.post("/v1/evidence-package/verify", json=tampered)
+        assert response.status_code == 400
+
+    def test_orphan_link_reported(self, services_ready, query_client: httpx.Client):
+        """Test that a prev_hash pointing outside the session is an orphan link."""
+        session_id = f"test-orphan-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-orphan",
+            session_id=session_id,
+            batch_size=1,
+        ))
+        for i in range(3):
+            client.record(
+                action_type=f"orphan_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+            client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get("/v1/evidence-package", params={"session_id": session_id})
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        bundle = response.json()
+
+        # Link the last event to a hash from no event of this session
+        bundle["events"][2]["proof"]["prev_hash"] = "b" * 64
+        chain = query_client.post("/v1/evidence-package/verify", json=bundle).json()["chain"]
+        if "no_orphan_links" not in chain["checks"]:
+            pytest.skip("API not running with REJECT_ORPHAN_LINKS=true")
+
+        assert not chain["valid"]
+        assert not chain["checks"]["no_orphan_links"]
+        assert chain["orphan_links"] == [bundle["events"][2]["facto_id"]]
+        assert any(e.startswith("Orphan link at event") for e in chain["errors"])
+        assert not any(e.startswith("Chain link broken") for e in chain["errors"])
+
+    def test_evidence_package_zip(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that format=zip returns an archive whose entries exist and parse."""
+        for i in range(3):
+            facto_client.record(
+                action_type=f"zip_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        session_id = facto_client.config.session_id
+        response = query_client.get(
+            "/v1/evidence-package", params={"session_id": session_id, "format": "zip"}
+        )
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        assert response.status_code == 200
+        assert response.headers["content-type"] == "application/zip"
+        assert response.headers["content-disposition"].startswith("attachment; filename=\"facto-evidence-")
+
+        with zipfile.ZipFile(io.BytesIO(response.content)) as archive:
+            assert sorted(archive.namelist()) == [
+                "VERIFY.txt", "events.json", "manifest.json", "merkle_proofs.json",
+            ]
+            events = json.loads(archive.read("events.json"))
+            proofs = json.loads(archive.read("merkle_proofs.json"))
+            manifest = json.loads(archive.read("manifest.json"))
+            instructions = archive.read("VERIFY.txt").decode()
+
+            for name, digest in manifest["files"].items():
+                assert hashlib.sha256(archive.read(name)).hexdigest() == digest
+
+        if len(events) < 3:
+            pytest.skip("Events not yet processed")
+        assert manifest["session_id"] == session_id
+        assert manifest["package_id"].startswith("ev-")
+        assert manifest["event_count"] == len(events) == 3
+        assert manifest["proof_count"] == len(proofs) == 3
+        assert all(p["root"] == manifest["merkle_root"] for p in proofs)
+        assert instructions.startswith("To verify this evidence package")
+
+        response = query_client.get(
+            "/v1/evidence-package", params={"session_id": session_id, "format": "tar"}
+        )
+        assert response.status_code == 400
+
+    def test_merkle_proof_trace(self, services_ready, query_client: httpx.Client):
+        """Test that a traced Merkle proof verification ends at the root."""
+        session_id = f"test-trace-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-trace",
+            session_id=session_id,
+            batch_size=1,
+        ))
+        for i in range(3):
+            client.record(
+                action_type=f"trace_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+            client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get("/v1/evidence-package", params={"session_id": session_id})
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        assert response.status_code == 200
+        proof = response.json()["merkle_proofs"][1]
+
+        body = {"leaf_hash": proof["event_hash"], "proof": proof["proof"], "root": proof["root"]}
+        response = query_client.post("/v1/verify/merkle-proof", params={"trace": "true"}, json=body)
+        assert response.status_code == 200
+        data = response.json()
+        assert data["valid"]
+        trace = data["trace"]
+        assert trace[0] == {"step": "leaf", "hash": proof["event_hash"]}
+        assert len(trace) == len(proof["proof"]) + 1
+        assert trace[-1]["hash"] == proof["root"]
+
+        # Without trace only the outcome is returned
+        data = query_client.post("/v1/verify/merkle-proof", json=body).json()
+        assert "trace" not in data
+        assert data["computed_root"] == proof["root"]
+
+    def test_event_proof_verifies_against_stored_root(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that a single event's Merkle proof recomputes its stored batch root."""
+        facto_ids = []
+        for i in range(3):
+            facto_ids.append(facto_client.record(
+                action_type=f"proof_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            ))
+        facto_client.flush()
+
+        time.sleep(3)
+
+        for facto_id in facto_ids:
+            response = query_client.get(f"/v1/events/{facto_id}/proof")
+            if response.status_code == 404:
+                pytest.skip("Event not yet anchored")
+            assert response.status_code == 200
+            proof = response.json()
+            assert proof["facto_id"] == facto_id
+            assert proof["root_type"] in ("batch", "daily", "interval")
+
+            response = query_client.post("/v1/verify/merkle-proof", json={
+                "leaf_hash": proof["event_hash"],
+                "proof": proof["proof"],
+                "root": proof["root"],
+            })
+            assert response.status_code == 200
+            assert response.json()["valid"]
+
+        response = query_client.get(f"/v1/events/ft-{uuid.uuid4()}/proof")
+        assert response.status_code == 404
+
+    def test_evidence_package_root_matches_stored_root(self, services_ready, query_client: httpx.Client):
+        """Test that the evidence package and the processor build the same tree over the same events."""
+        session_id = f"test-tree-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-tree",
+            session_id=session_id,
+        ))
+        for i in range(3):
+            client.record(
+                action_type=f"tree_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+        client.flush()
+        client.close()
+
+        time.sleep(3)
+
+        response = query_client.get("/v1/evidence-package", params={"session_id": session_id})
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        assert response.status_code == 200
+        proofs = response.json()["merkle_proofs"]
+
+        response = query_client.get(f"/v1/events/{proofs[0]['facto_id']}/proof", params={"root_type": "batch"})
+        if response.status_code == 404:
+            pytest.skip("Event not yet in a Merkle root")
+        stored = query_client.get(f"/v1/merkle-roots/{response.json()['root']}").json()
+        if stored["event_hashes"] != [p["event_hash"] for p in proofs]:
+            pytest.skip("Session events did not land in a batch of their own")
+
+        # Both services must run the same MERKLE_TREE_SCHEME
+        scheme = proofs[0].get("scheme", "legacy")
+        assert stored["tree_scheme"] == scheme
+        for p in proofs:
+            assert p["root"] == stored["root_hash"]
+
+            response = query_client.post("/v1/verify/merkle-proof", json={
+                "leaf_hash": p["event_hash"],
+                "proof": p["proof"],
+                "root": p["root"],
+                "scheme": scheme,
+            })
+            assert response.status_code == 200
+            assert response.json()["valid"]
+            assert response.json()["scheme"] == scheme
+
+        # A proof checked under the other scheme doesn't reach the root
+        other = "rfc6962" if scheme == "legacy" else "legacy"
+        response = query_client.post("/v1/verify/merkle-proof", json={
+            "leaf_hash": proofs[0]["event_hash"],
+            "proof": proofs[0]["proof"],
+            "root": proofs[0]["root"],
+            "scheme": other,
+        })
+        assert response.status_code == 200
+        assert not response.json()["valid"]
+
+        response = query_client.post("/v1/verify/merkle-proof", json={
+            "leaf_hash": proofs[0]["event_hash"],
+            "proof": proofs[0]["proof"],
+            "root": proofs[0]["root"],
+            "scheme": "sha1",
+        })
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_parameter"
+
+    def test_merkle_roots_listed_and_fetched(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that stored Merkle roots can be listed by day and fetched by hash."""
+        facto_id = facto_client.record(
+            action_type="merkle_roots",
+            input_data={"q": "anchor me"},
+            output_data={"a": "ok"},
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/events/{facto_id}/proof", params={"root_type": "batch"})
+        if response.status_code == 404:
+            pytest.skip("Event not yet anchored")
+        proof = response.json()
+        date = proof["bucket_time"][:10]
+
+        # Page through the day's roots until the event's batch root shows up
+        found = None
+        cursor = None
+        while found is None:
+            params = {"date": date, "limit": 50}
+            if cursor:
+                params["cursor"] = cursor
+            response = query_client.get("/v1/merkle-roots", params=params)
+            assert response.status_code == 200
+            data = response.json()
+            assert data["date"] == date
+            for root in data["roots"]:
+                assert "event_hashes" not in root
+                if root["root_hash"] == proof["root"]:
+                    found = root
+            cursor = data["next_cursor"]
+            if cursor is None:
+                break
+        assert found is not None
+        assert found["root_type"] == "batch"
+        assert found["event_count"] == proof["event_count"]
+
+        response = query_client.get(f"/v1/merkle-roots/{proof['root']}")
+        assert response.status_code == 200
+        root = response.json()
+        assert root["event_hashes"][proof["leaf_index"]] == proof["event_hash"]
+        assert root["event_count"] == len(root["event_hashes"])
+
+        assert query_client.get(f"/v1/merkle-roots/{'0' * 64}").status_code == 404
+        assert query_client.get("/v1/merkle-roots/not-a-hash").status_code == 400
+        assert query_client.get("/v1/merkle-roots", params={"date": "yesterday"}).status_code == 400
+
+    def test_stream_seq_persisted(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that the JetStream sequence is stored and returned with events."""
+        facto_ids = []
+        for i in range(2):
+            facto_ids.append(facto_client.record(
+                action_type=f"stream_seq_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            ))
+            facto_client.flush()
+
+        time.sleep(3)
+
+        seqs = []
+        for facto_id in facto_ids:
+            response = query_client.get(f"/v1/events/{facto_id}")
+            if response.status_code == 404:
+                pytest.skip("Events not yet processed")
+            assert response.status_code == 200
+            data = response.json()
+            if "stream_seq" not in data:
+                pytest.skip("Processor not running with STORE_STREAM_SEQ=true")
+            seqs.append(data["stream_seq"])
+
+        assert seqs[0] > 0
+        assert seqs[1] > seqs[0]
+
+    def test_bulk_ingest_reports_bad_signature(self, services_ready, query_client: httpx.Client):
+        """Test that POST /v1/events on the processor stores valid events and rejects a bad signature."""
+        session_id = f"test-bulk-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-bulk",
+            session_id=session_id,
+            batch_size=100,
+        ))
+        # Sign events with the SDK but keep them off the ingestion service
+        captured = []
+        client._send_batch = captured.extend
+        for i in range(4):
+            client.record(
+                action_type=f"bulk_action_{i}",
+                input_data={"index": i},
+                output_data={"result": i},
+            )
+        client.flush()
+        client.close()
+        events = [event.to_dict() for event in captured]
+        events[3]["proof"]["signature"] = events[0]["proof"]["signature"]
+
+        processor_url = PROCESSOR_METRICS_URL.rsplit("/", 1)[0]
+        response = httpx.post(f"{processor_url}/v1/events", json=events, timeout=10)
+        if response.status_code in (404, 405):
+            pytest.skip("Processor not running with BULK_INGEST=true")
+        assert response.status_code == 200
+        data = response.json()
+        assert data["accepted"] == 3
+        assert data["rejected"] == 1
+        assert [r["status"] for r in data["results"]] == ["accepted"] * 3 + ["rejected"]
+        assert data["results"][3] == {
+            "index": 3,
+            "facto_id": events[3]["facto_id"],
+            "status": "rejected",
+            "reason": "bad_signature",
+        }
+
+        # The accepted events form their own batch root
+        root = query_client.get(f"/v1/merkle-roots/{data['merkle_root']}").json()
+        assert root["event_hashes"] == [e["proof"]["event_hash"] for e in events[:3]]
+        assert query_client.get(f"/v1/events/{events[3]['facto_id']}").status_code == 404
+
+        chain = query_client.get("/v1/verify/chain", params={"session_id": session_id}).json()
+        assert chain["valid"], chain
+        assert chain["event_count"] == 3
+
+        response = httpx.post(f"{processor_url}/v1/events", json=[], timeout=10)
+        assert response.status_code == 400
+        response = httpx.post(f"{processor_url}/v1/events", json={"events": events}, timeout=10)
+        assert response.status_code == 400
+
+    def test_grpc_ingest_and_session_stream(self, services_ready, query_client: httpx.Client, tmp_path):
+        """Test IngestEvents on the processor and GetEvent/GetSessionEvents on the API over gRPC."""
+        grpc = pytest.importorskip("grpc")
+        protoc = pytest.importorskip("grpc_tools.protoc")
+        from google.protobuf import json_format
+
+        # Generate the Python stubs from the proto the services are built from
+        assert protoc.main([
+            "grpc_tools.protoc", f"-I{PROTO_DIR}", f"--python_out={tmp_path}",
+            f"--grpc_python_out={tmp_path}", "facto/v1/facto.proto",
+        ]) == 0
+        sys.path.insert(0, str(tmp_path))
+        try:
+            from facto.v1 import facto_pb2, facto_pb2_grpc
+        finally:
+            sys.path.remove(str(tmp_path))
+
+        channels = {}
+        for name, addr in (("processor", PROCESSOR_GRPC_ADDR), ("api", QUERY_API_GRPC_ADDR)):
+            channel = grpc.insecure_channel(addr)
+            try:
+                grpc.channel_ready_future(channel).result(timeout=5)
+            except grpc.FutureTimeoutError:
+                pytest.skip(f"{name} not serving gRPC at {addr} (GRPC_PORT)")
+            channels[name] = channel
+        ingest = facto_pb2_grpc.IngestStub(channels["processor"])
+        query = facto_pb2_grpc.QueryStub(channels["api"])
+
+        session_id = f"test-grpc-{uuid.uuid4().hex[:8]}"
+        client = FactoClient(FactoConfig(
+            endpoint=INGESTION_URL,
+            agent_id="test-agent-grpc",
+            session_id=session_id,
+            batch_size=100,
+        ))
+        # Sign events with the SDK but keep them off the ingestion service
+        captured = []
+        client._send_batch = captured.extend
+        for i in range(4):
+            client.record(
+                action_type=f"grpc_action_{i}",
+                input_data={"index": i, "nested": {"items": [1, 2.5, "three", None]}},
+                output_data={"result": i},
+                execution_meta=ExecutionMeta(model_id="gpt-4", temperature=0.0, seed=0),
+            )
+        client.flush()
+        client.close()
+        events = [event.to_dict() for event in captured]
+        events[3]["proof"]["signature"] = events[0]["proof"]["signature"]
+
+        messages = [json_format.ParseDict(e, facto_pb2.Event(), ignore_unknown_fields=True) for e in events]
+        response = ingest.IngestEvents(iter(messages), timeout=10)
+        assert response.accepted == 3
+        assert response.rejected == 1
+        statuses = [r.status for r in response.results]
+        assert statuses == [facto_pb2.IngestResult.STATUS_ACCEPTED] * 3 + [facto_pb2.IngestResult.STATUS_REJECTED]
+        assert response.results[3].reason == "bad_signature"
+        assert response.results[3].facto_id == events[3]["facto_id"]
+
+        # The accepted events form their own batch root and still verify, so
+        # the gRPC form kept the canonical form intact
+        root = query_client.get(f"/v1/merkle-roots/{response.merkle_root}").json()
+        assert root["event_hashes"] == [e["proof"]["event_hash"] for e in events[:3]]
+        chain = query_client.get("/v1/verify/chain", params={"session_id": session_id}).json()
+        assert chain["valid"], chain
+        assert chain["event_count"] == 3
+
+        streamed = list(query.GetSessionEvents(facto_pb2.GetSessionEventsRequest(session_id=session_id), timeout=10))
+        assert [e.facto_id for e in streamed] == [e["facto_id"] for e in events[:3]]
+        assert [e.proof.event_hash for e in streamed] == [e["proof"]["event_hash"] for e in events[:3]]
+
+        event = query.GetEvent(facto_pb2.GetEventRequest(facto_id=events[1]["facto_id"]), timeout=10)
+        assert event.session_id == session_id
+        assert event.execution_meta.model_id == "gpt-4"
+        assert event.execution_meta.HasField("seed") and event.execution_meta.seed == 0
+        assert not event.HasField("parent_facto_id")
+        assert json_format.MessageToDict(event.input_data) == {"index": 1, "nested": {"items": [1, 2.5, "three", None]}}
+        http_event = query_client.get(f"/v1/events/{events[1]['facto_id']}").json()
+        assert verify_event(http_event) == (True, True)
+
+        with pytest.raises(grpc.RpcError) as missing:
+            query.GetEvent(facto_pb2.GetEventRequest(facto_id=events[3]["facto_id"]), timeout=10)
+        assert missing.value.code() == grpc.StatusCode.NOT_FOUND
+        with pytest.raises(grpc.RpcError) as malformed:
+            query.GetEvent(facto_pb2.GetEventRequest(facto_id="not-an-id"), timeout=10)
+        assert malformed.value.code() == grpc.StatusCode.INVALID_ARGUMENT
+        with pytest.raises(grpc.RpcError) as empty:
+            ingest.IngestEvents(iter([]), timeout=10)
+        assert empty.value.code() == grpc.StatusCode.INVALID_ARGUMENT
+
+    def test_consumer_lag_exported(self, facto_client: FactoClient):
+        """Test that the processor exports the JetStream consumer's lag after a flush."""
+        facto_client.record(
+            action_type="lag",
+            input_data={"q": "how far behind"},
+            output_data={"a": "caught up"},
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        try:
+            metrics = httpx.get(PROCESSOR_METRICS_URL, timeout=5).text
+        except httpx.HTTPError:
+            pytest.skip("Processor metrics not available")
+        values = {}
+        for line in metrics.splitlines():
+            if line.startswith("facto_processor_consumer_"):
+                name, value = line.split()
+                values[name] = float(value)
+        if "facto_processor_consumer_pending" not in values:
+            pytest.skip("Processor does not export consumer lag")
+        for name in ("pending", "ack_pending", "redelivered"):
+            assert values[f"facto_processor_consumer_{name}"] >= 0
+
+    def test_replayed_event_deduplicated(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that submitting the same event twice stores and anchors it once."""
+        def duplicates() -> float:
+            try:
+                metrics = httpx.get(PROCESSOR_METRICS_URL, timeout=5).text
+            except httpx.HTTPError:
+                pytest.skip("Processor metrics not available")
+            for line in metrics.splitlines():
+                if line.startswith("facto_processor_events_duplicate_total "):
+                    return float(line.split()[1])
+            pytest.skip("Processor does not export facto_processor_events_duplicate_total")
+
+        facto_id = facto_client.record(
+            action_type="dedup",
+            input_data={"q": "only once"},
+            output_data={"a": "ok"},
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/events/{facto_id}")
+        if response.status_code == 404:
+            pytest.skip("Event not yet processed")
+        stored = response.json()
+        before = duplicates()
+
+        # Resubmit the signed event exactly as the SDK sent it
+        fields = [
+            "facto_id", "agent_id", "session_id", "parent_facto_id", "action_type",
+            "status", "input_data", "output_data", "execution_meta", "proof",
+            "started_at", "completed_at",
+        ]
+        replay = {field: stored[field] for field in fields if field in stored}
+        response = httpx.post(f"{INGESTION_URL}/v1/ingest", json=replay, timeout=10)
+        assert response.status_code == 202
+
+        time.sleep(3)
+
+        if duplicates() == before:
+            pytest.skip("Processor not running with DEDUP_MODE=select or lwt")
+        assert duplicates() == before + 1
+
+        # The replay did not produce a second row for the session
+        response = query_client.get(f"/v1/sessions/{stored['session_id']}/events")
+        assert response.status_code == 200
+        session_ids = [e["facto_id"] for e in response.json()["events"]]
+        assert session_ids.count(facto_id) == 1
+
+    def test_agents_listing(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that a recorded agent appears in /v1/agents with its count and range."""
+        facto_client.record(
+            action_type="tool_call",
+            input_data={"tool": "lookup"},
+            output_data={"result": "ok"},
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        agent_id = facto_client.config.agent_id
+        agent = None
+        cursor = None
+        while agent is None:
+            params = {"limit": 500}
+            if cursor:
+                params["cursor"] = cursor
+            response = query_client.get("/v1/agents", params=params)
+            assert response.status_code == 200
+            data = response.json()
+            agent = next((a for a in data["agents"] if a["agent_id"] == agent_id), None)
+            cursor = data["next_cursor"]
+            if not cursor:
+                break
+
+        if agent is None:
+            pytest.skip("Event not yet processed")
+        assert agent["event_count"] >= 1
+        assert agent["first_event_at"] <= agent["last_event_at"]
+
+    def test_agent_sessions_listing(self, services_ready, query_client: httpx.Client):
+        """Test that an agent's sessions are listed with counts, bounds and status."""
+        agent_id = f"test-agent-sessions-{uuid.uuid4().hex[:8]}"
+        session_ids = []
+        for statuses in (["success", "success"], ["success", "error"]):
+            client = FactoClient(FactoConfig(
+                endpoint=INGESTION_URL,
+                agent_id=agent_id,
+                batch_size=1,
+                flush_interval_seconds=0.1,
+            ))
+            for status in statuses:
+                client.record(
+                    action_type="tool_call",
+                    input_data={"status": status},
+                    output_data={},
+                    status=status,
+                )
+            client.flush()
+            session_ids.append(client.config.session_id)
+            client.close()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/agents/{agent_id}/sessions")
+        assert response.status_code == 200
+        data = response.json()
+        assert data["agent_id"] == agent_id
+        sessions = {s["session_id"]: s for s in data["sessions"]}
+        if len(sessions) < 2:
+            pytest.skip("Events not yet processed")
+        assert set(sessions) == set(session_ids)
+
+        clean, failed = sessions[session_ids[0]], sessions[session_ids[1]]
+        assert clean["event_count"] == 2
+        assert clean["status"] == "ok"
+        assert failed["event_count"] == 2
+        assert failed["status"] == "failed"
+        assert clean["first_event_at"] <= clean["last_event_at"]
+
+        # A range ending before the first session started excludes both
+        response = query_client.get(
+            f"/v1/agents/{agent_id}/sessions",
+            params={"end": "2000-01-01T00:00:00Z"},
+        )
+        assert response.status_code == 200
+        assert response.json()["sessions"] == []
+
+        # Paging one session at a time returns each session once
+        seen = []
+        cursor = None
+        while True:
+            params = {"limit": 1}
+            if cursor:
+                params["cursor"] = cursor
+            response = query_client.get(f"/v1/agents/{agent_id}/sessions", params=params)
+            assert response.status_code == 200
+            page = response.json()
+            seen.extend(s["session_id"] for s in page["sessions"])
+            cursor = page["next_cursor"]
+            if not cursor:
+                break
+        assert sorted(seen) == sorted(session_ids)
+
+        response = query_client.get(f"/v1/agents/{agent_id}/sessions", params={"start": "yesterday"})
+        assert response.status_code == 400
+
+    def test_session_summary(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test the session summary aggregates over a seeded session."""
+        base = time.time_ns()
+        seeded = [
+            ("llm_call", "success", "gpt-4", 0, 1_000_000_000),
+            ("llm_call", "error", "claude-3", 2_000_000_000, 3_000_000_000),
+            ("tool_call", "success", None, 4_000_000_000, 6_500_000_000),
+        ]
+        for action_type, status, model_id, started, completed in seeded:
+            facto_client.record(
+                action_type=action_type,
+                input_data={},
+                output_data={},
+                status=status,
+                execution_meta=ExecutionMeta(model_id=model_id),
+                started_at=base + started,
+                completed_at=base + completed,
+            )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        session_id = facto_client.config.session_id
+        response = query_client.get(f"/v1/sessions/{session_id}/summary")
+        if response.status_code == 404:
+            pytest.skip("Events not yet processed")
+        assert response.status_code == 200
+        summary = response.json()
+        if summary["event_count"] < len(seeded):
+            pytest.skip("Events not yet processed")
+
+        assert summary["session_id"] == session_id
+        assert summary["agent_id"] == facto_client.config.agent_id
+        assert summary["event_count"] == 3
+        assert summary["action_types"] == {"llm_call": 2, "tool_call": 1}
+        assert summary["statuses"] == {"success": 2, "error": 1}
+        assert summary["models"] == ["claude-3", "gpt-4"]
+        assert summary["started_at"] == base
+        assert summary["completed_at"] == base + 6_500_000_000
+        assert summary["duration_ms"] == 6500
+        assert summary["chain_valid"] is True
+
+        response = query_client.get(f"/v1/sessions/session-{uuid.uuid4().hex[:12]}/summary")
+        assert response.status_code == 404
+
+    def test_merkle_root_anchor_receipt(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that batch roots are anchored to the external log and their receipts served."""
+        try:
+            httpx.get(f"{ANCHOR_LOG_URL}/anchors/probe", timeout=5)
+        except httpx.HTTPError:
+            pytest.skip("Anchor log not available")
+
+        facto_id = facto_client.record(
+            action_type="anchor_test",
+            input_data={"data": "anchored"},
+            output_data={"result": "ok"},
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/events/{facto_id}/proof", params={"root_type": "batch"})
+        if response.status_code == 404:
+            pytest.skip("Event not yet in a Merkle root")
+        root_hash = response.json()["root"]
+
+        response = query_client.get(f"/v1/merkle-roots/{root_hash}/anchor")
+        if response.status_code == 404 and response.json()["code"] == "not_anchored":
+            pytest.skip("Processor not running with ANCHOR_URL, or root not yet anchored")
+        assert response.status_code == 200
+        anchor = response.json()
+        assert anchor["root_hash"] == root_hash
+        assert anchor["root_type"] == "batch"
+
+        # The stored receipt is the one the log issued for this root
+        logged = httpx.get(f"{ANCHOR_LOG_URL}/anchors/{root_hash}", timeout=5).json()["receipts"]
+        assert anchor["receipt"] in logged
+        assert anchor["receipt"]["root_hash"] == root_hash
+
+        root = query_client.get(f"/v1/merkle-roots/{root_hash}").json()
+        assert root["anchor_receipt"] == anchor["receipt"]
+
+        response = query_client.get(f"/v1/merkle-roots/{'0' * 64}/anchor")
+        assert response.status_code == 404
+        assert response.json()["code"] == "not_found"
+
+        assert query_client.get("/v1/merkle-roots/not-a-hash/anchor").status_code == 400
+
+    def test_merkle_root_tsa_timestamp(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that batch roots carry an RFC 3161 token over the root (mock_tsa.py)."""
+        facto_id = facto_client.record(
+            action_type="tsa_test",
+            input_data={"data": "timestamped"},
+            output_data={"result": "ok"},
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        response = query_client.get(f"/v1/events/{facto_id}/proof", params={"root_type": "batch"})
+        if response.status_code == 404:
+            pytest.skip("Event not yet in a Merkle root")
+        root_hash = response.json()["root"]
+
+        root = query_client.get(f"/v1/merkle-roots/{root_hash}").json()
+        if "tsa_token" not in root:
+            pytest.skip("Processor not running with TSA_URL, or root not yet timestamped")
+
+        # The token's TSTInfo carries the SHA-256 of the root hash string
+        token = base64.b64decode(root["tsa_token"])
+        assert token[0] == 0x30
+        assert hashlib.sha256(root_hash.encode()).digest() in token
+        assert root["tsa_time"]
+
+    def _record_batch_roots(self, facto_client: FactoClient, query_client: httpx.Client, count: int) -> List[Dict[str, Any]]:
+        """Record count events in separate batches and return each batch root."""
+        roots = []
+        for i in range(count):
+            facto_id = facto_client.record(
+                action_type="root_chain_test",
+                input_data={"batch": i},
+                output_data={"result": "ok"},
+            )
+            facto_client.flush()
+            time.sleep(3)
+
+            response = query_client.get(f"/v1/events/{facto_id}/proof", params={"root_type": "batch"})
+            if response.status_code == 404:
+                pytest.skip("Event not yet in a Merkle root")
+            roots.append(query_client.get(f"/v1/merkle-roots/{response.json()['root']}").json())
+        if len({root["root_hash"] for root in roots}) < count:
+            pytest.skip("Events landed in the same batch")
+        return roots
+
+    def test_merkle_root_chain_links_batches(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that consecutive batch roots link through prev_root_hash and the day's chain checks out."""
+        roots = self._record_batch_roots(facto_client, query_client, 3)
+        if any("prev_root_hash" not in root for root in roots):
+            pytest.skip("Processor not linking batch roots")
+
+        # Other processors may link roots in between, so follow the links
+        # back from the newest root rather than expecting direct neighbours
+        hashes = {root["root_hash"] for root in roots}
+        cur = roots[-1]
+        seen = {cur["root_hash"]}
+        while hashes - seen and cur.get("prev_root_hash"):
+            cur = query_client.get(f"/v1/merkle-roots/{cur['prev_root_hash']}").json()
+            seen.add(cur["root_hash"])
+        assert hashes <= seen
+
+        date = roots[-1]["date"]
+        response = query_client.get("/v1/merkle-roots/chain", params={"date": date})
+        assert response.status_code == 200
+        chain = response.json()
+        assert chain["date"] == date
+        assert chain["valid"], chain["errors"]
+        assert chain["errors"] == []
+        assert chain["root_count"] >= 3
+        assert chain["first_root"]
+        assert chain["genesis"] or chain["prev_root_hash"]
+
+        response = query_client.get("/v1/merkle-roots/chain", params={"date": "yesterday"})
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_date_format"
+        assert query_client.get("/v1/merkle-roots/chain").status_code == 400
+
+    def test_merkle_root_chain_detects_deleted_root(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that deleting a middle batch root breaks the day's chain, via cqlsh in the scylla container."""
+        if shutil.which("docker") is None:
+            pytest.skip("docker not available to edit ScyllaDB")
+
+        def cql(statement: str) -> str:
+            result = subprocess.run(
+                ["docker", "compose", "exec", "-T", "scylla", "cqlsh", "-k", "facto", "-e", statement],
+                cwd=Path(__file__).resolve().parents[2], capture_output=True, text=True, timeout=30,
+            )
+            if result.returncode != 0:
+                pytest.skip(f"cqlsh unavailable: {result.stderr.strip()}")
+            return result.stdout
+
+        roots = self._record_batch_roots(facto_client, query_client, 3)
+        if any("prev_root_hash" not in root for root in roots):
+            pytest.skip("Processor not linking batch roots")
+        middle = roots[1]
+        if roots[2]["prev_root_hash"] != middle["root_hash"]:
+            pytest.skip("Another processor linked a root between the test's batches")
+
+        # Keep the row so it can be put back for later runs; its bucket_time
+        # as cqlsh prints it doubles as the key literal
+        date = middle["date"]
+        rows = [
+            json.loads(line.strip())
+            for line in cql(f"SELECT JSON * FROM merkle_roots WHERE date = '{date}'").splitlines()
+            if line.strip().startswith("{")
+        ]
+        saved = next(row for row in rows if row["root_hash"] == middle["root_hash"])
+        where = f"date = '{date}' AND bucket_time = '{saved['bucket_time']}'"
+
+        cql(f"DELETE FROM merkle_roots WHERE {where}")
+        try:
+            chain = query_client.get("/v1/merkle-roots/chain", params={"date": date}).json()
+            assert not chain["valid"]
+            assert any(middle["root_hash"] in error for error in chain["errors"])
+            # The root before the gap is left dangling, or unlinked if it was
+            # the genesis root
+            assert roots[0]["root_hash"] in chain["unlinked"] or any(
+                roots[0]["root_hash"] in error for error in chain["errors"]
+            )
+        finally:
+            restored = json.dumps(saved).replace("'", "''")
+            cql(f"INSERT INTO merkle_roots JSON '{restored}'")
+
+        chain = query_client.get("/v1/merkle-roots/chain", params={"date": date}).json()
+        assert chain["valid"], chain["errors"]
+
+    def test_merkle_consistency_holds_for_appends(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that a later batch log root is proven to extend an earlier one."""
+        first, _, last = self._record_batch_roots(facto_client, query_client, 3)
+        if "log_root" not in first or "log_root" not in last:
+            pytest.skip("Processor not appending batch roots to the log")
+        assert last["log_size"] > first["log_size"]
+
+        # Batch roots stand for the log root right after their append
+        response = query_client.get(
+            "/v1/merkle-consistency", params={"from": first["root_hash"], "to": last["root_hash"]}
+        )
+        assert response.status_code == 200
+        data = response.json()
+        assert data["hash_algorithm"] == "rfc6962-sha256"
+        assert data["from"] == {"log_root": first["log_root"], "log_size": first["log_size"], "root_hash": first["root_hash"]}
+        assert data["to"]["log_root"] == last["log_root"]
+        assert verify_consistency(first["log_size"], last["log_size"], first["log_root"], last["log_root"], data["proof"])
+
+        # Log roots work too, and give the same proof
+        by_log_root = query_client.get(
+            "/v1/merkle-consistency", params={"from": first["log_root"], "to": last["log_root"]}
+        ).json()
+        assert by_log_root["proof"] == data["proof"]
+
+        same = query_client.get(
+            "/v1/merkle-consistency", params={"from": last["log_root"], "to": last["log_root"]}
+        ).json()
+        assert same["proof"] == []
+
+        response = query_client.get(
+            "/v1/merkle-consistency", params={"from": last["root_hash"], "to": first["root_hash"]}
+        )
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_parameter"
+
+        response = query_client.get("/v1/merkle-consistency", params={"from": "0" * 64, "to": last["root_hash"]})
+        assert response.status_code == 404
+        response = query_client.get("/v1/merkle-consistency", params={"from": "not-a-hash", "to": last["root_hash"]})
+        assert response.status_code == 400
+        assert response.json()["code"] == "invalid_hash"
+
+    def test_merkle_consistency_fails_for_rewrites(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that a consistency proof rejects a rewritten earlier log or a tampered proof."""
+        first, second = self._record_batch_roots(facto_client, query_client, 2)
+        if "log_root" not in first or "log_root" not in second:
+            pytest.skip("Processor not appending batch roots to the log")
+
+        data = query_client.get(
+            "/v1/merkle-consistency", params={"from": first["root_hash"], "to": second["root_hash"]}
+        ).json()
+        proof = data["proof"]
+        m, n = first["log_size"], second["log_size"]
+        assert verify_consistency(m, n, first["log_root"], second["log_root"], proof)
+
+        # A log whose first m leaves were rewritten has a different root at m
+        rewritten = hashlib.sha256(b"rewritten" + bytes.fromhex(first["log_root"])).hexdigest()
+        assert not verify_consistency(m, n, rewritten, second["log_root"], proof)
+
+        # So does a later log that rewrote history
+        assert not verify_consistency(m, n, first["log_root"], rewritten, proof)
+
+        for i in range(len(proof)):
+            tampered = list(proof)
+            tampered[i] = hashlib.sha256(bytes.fromhex(proof[i])).hexdigest()
+            assert not verify_consistency(m, n, first["log_root"], second["log_root"], tampered)
+
+        if m + 1 < n:
+            assert not verify_consistency(m + 1, n, first["log_root"], second["log_root"], proof)
+
+    def test_search_push_and_hydrate(self, facto_client: FactoClient, query_client: httpx.Client):
+        """Test that stored events are pushed to the search index and hydrated by facto_id."""
+        if query_client.get("/v1/search", params={"q": "probe"}).status_code == 404:
+            pytest.skip("Query API not running with SEARCH_INDEX_URL")
+
+        needle = f"needle-{uuid.uuid4().hex[:8]}"
+        facto_id = facto_client.record(
+            action_type="llm_call",
+            input_data={"messages": [{"role": "user", "content": f"find the {needle} please"}]},
+            output_data={"response": "found it"},
+        )
+        facto_client.record(
+            action_type="llm_call",
+            input_data={"prompt": "unrelated"},
+            output_data={"response": "nothing here"},
+        )
+        facto_client.flush()
+
+        time.sleep(3)
+
+        # The processor pushed the event's text, keyed by facto_id
+        doc = httpx.get(f"{SEARCH_INDEX_URL}/facto-events/_doc/{facto_id}", timeout=5)
+        if doc.status_code == 404:
+            pytest.skip("Event not yet indexed")
+        source = doc.json()["_source"]
+        assert source["agent_id"] == facto_client.config.agent_id
+        assert needle in source["text"]
+
+        # Search hits are hydrated into full, verifiable events
+        response = query_client.get("/v1/search", params={"q": needle})
+        assert response.status_code == 200
+        events = response.json()["events"]
+        assert [e["facto_id"] for e in events] == [facto_id]
+        assert events[0]["proof"]["event_hash"]
+        assert events[0]["input_data"]["messages"][0]["content"] == f"find the {needle} please"
+
+        response = query_client.get("/v1/search", params={"q": needle, "agent_id": "some-other-agent"})
+        assert response.status_code == 200
+        assert response.json()["events"] == []
+
+        assert query_client.get("/v1/search").status_code == 400
+
     def test_verify_endpoint(self, facto_client: FactoClient, query_client: httpx.Client):
         """Test the verify endpoint."""
         # Record an event